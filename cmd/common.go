@@ -15,6 +15,7 @@ import (
 func Init() {
 	bootstrap.InitConfig()
 	bootstrap.Log()
+	bootstrap.InitTracing()
 	bootstrap.InitDB()
 	data.InitData()
 	bootstrap.InitStreamLimit()
@@ -24,6 +25,7 @@ func Init() {
 
 func Release() {
 	db.Close()
+	bootstrap.ShutdownTracing()
 }
 
 var pid = -1
@@ -14,6 +14,8 @@ import (
 	"github.com/alist-org/alist/v3/internal/driver"
 	"github.com/alist-org/alist/v3/internal/errs"
 	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/sign"
+	"github.com/alist-org/alist/v3/pkg/singleflight"
 	"github.com/alist-org/alist/v3/pkg/utils"
 	"github.com/alist-org/alist/v3/server/common"
 	"github.com/go-resty/resty/v2"
@@ -23,6 +25,7 @@ import (
 type AListV3 struct {
 	model.Storage
 	Addition
+	loginG singleflight.Group[string]
 }
 
 func (d *AListV3) Config() driver.Config {
@@ -75,23 +78,18 @@ func (d *AListV3) Drop(ctx context.Context) error {
 }
 
 func (d *AListV3) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
-	var resp common.Resp[FsListResp]
-	_, _, err := d.request("/fs/list", http.MethodPost, func(req *resty.Request) {
-		req.SetResult(&resp).SetBody(ListReq{
-			PageReq: model.PageReq{
-				Page:    1,
-				PerPage: 0,
-			},
-			Path:     dir.GetPath(),
-			Password: d.MetaPassword,
-			Refresh:  false,
-		})
-	})
+	content, err := d.list(dir.GetPath())
 	if err != nil {
 		return nil, err
 	}
 	var files []model.Obj
-	for _, f := range resp.Data.Content {
+	for _, f := range content {
+		thumb := f.Thumb
+		if d.ProxyThumbnail && thumb != "" {
+			thumb = common.GetApiUrl(common.GetHttpReq(ctx)) + path.Join("/d", args.ReqPath, f.Name)
+			thumb = utils.EncodePath(thumb, true)
+			thumb += "?type=thumb&sign=" + sign.Sign(path.Join(args.ReqPath, f.Name))
+		}
 		file := model.ObjThumb{
 			Object: model.Object{
 				Name:     f.Name,
@@ -101,7 +99,7 @@ func (d *AListV3) List(ctx context.Context, dir model.Obj, args model.ListArgs)
 				IsFolder: f.IsDir,
 				HashInfo: utils.FromString(f.HashInfo),
 			},
-			Thumbnail: model.Thumbnail{Thumbnail: f.Thumb},
+			Thumbnail: model.Thumbnail{Thumbnail: thumb},
 		}
 		files = append(files, &file)
 	}
@@ -109,6 +107,9 @@ func (d *AListV3) List(ctx context.Context, dir model.Obj, args model.ListArgs)
 }
 
 func (d *AListV3) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	if args.Type == "thumb" && d.ProxyThumbnail {
+		return d.thumbLink(ctx, file)
+	}
 	var resp common.Resp[FsGetResp]
 	// if PassUAToUpsteam is true, then pass the user-agent to the upstream
 	userAgent := base.UserAgent
@@ -132,6 +133,14 @@ func (d *AListV3) Link(ctx context.Context, file model.Obj, args model.LinkArgs)
 	}, nil
 }
 
+// LinkVariant caches the proxied thumbnails apart from the links of the files
+func (d *AListV3) LinkVariant(args model.LinkArgs) string {
+	if args.Type == "thumb" && d.ProxyThumbnail {
+		return "thumb"
+	}
+	return ""
+}
+
 func (d *AListV3) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
 	_, _, err := d.request("/fs/mkdir", http.MethodPost, func(req *resty.Request) {
 		req.SetBody(MkdirOrLinkReq{
@@ -184,6 +193,10 @@ func (d *AListV3) Remove(ctx context.Context, obj model.Obj) error {
 }
 
 func (d *AListV3) Put(ctx context.Context, dstDir model.Obj, s model.FileStreamer, up driver.UpdateProgress) error {
+	// the stream can't be sent again after a 401, so refresh the token in advance
+	if err := d.refreshToken(); err != nil {
+		return err
+	}
 	reader := driver.NewLimitedUploadStream(ctx, &driver.ReaderUpdatingProgress{
 		Reader:         s,
 		UpdateProgress: up,
@@ -360,3 +373,4 @@ func (d *AListV3) ArchiveDecompress(ctx context.Context, srcObj, dstDir model.Ob
 //}
 
 var _ driver.Driver = (*AListV3)(nil)
+var _ driver.LinkVariant = (*AListV3)(nil)
@@ -0,0 +1,129 @@
+package alist_v3
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/drivers/base"
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/model"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestThumbnailLinkVariant(t *testing.T) {
+	var remote *httptest.Server
+	remote = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/fs/list":
+			_, _ = io.WriteString(w, `{"code":200,"data":{"content":[{"name":"a.png","thumb":"`+remote.URL+`/thumb/a.png"}]}}`)
+		case "/api/fs/get":
+			_, _ = io.WriteString(w, `{"code":200,"data":{"name":"a.png","raw_url":"`+remote.URL+`/raw/a.png"}}`)
+		case "/thumb/a.png":
+			w.Header().Set("Content-Type", "image/jpeg")
+			_, _ = io.WriteString(w, "thumb")
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer remote.Close()
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	base.InitClient()
+	d := &AListV3{Addition: Addition{Address: remote.URL, ProxyThumbnail: true}}
+	file := &model.Object{Path: "/a.png", Name: "a.png"}
+	ctx := context.Background()
+
+	// the proxied thumbnail and the file are cached under different keys
+	thumbArgs := model.LinkArgs{Type: "thumb"}
+	if v := d.LinkVariant(thumbArgs); v != "thumb" {
+		t.Fatalf("expect the thumbnail variant, got %q", v)
+	}
+	if v := d.LinkVariant(model.LinkArgs{}); v != "" {
+		t.Fatalf("expect the file itself, got %q", v)
+	}
+	thumb, err := d.Link(ctx, file, thumbArgs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(thumb.MFile)
+	if string(data) != "thumb" || thumb.Header.Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("expect the thumbnail, got %q %s", data, thumb.Header.Get("Content-Type"))
+	}
+	link, err := d.Link(ctx, file, model.LinkArgs{})
+	if err != nil || link.URL != remote.URL+"/raw/a.png" {
+		t.Fatalf("expect the raw url, got %+v %v", link, err)
+	}
+
+	// without the proxy the thumbnail links are the links of the files
+	d.ProxyThumbnail = false
+	if v := d.LinkVariant(thumbArgs); v != "" {
+		t.Fatalf("expect no variant without the proxy, got %q", v)
+	}
+}
+
+// testToken makes a jwt token expiring at exp, the signature isn't checked by the driver
+func testToken(exp time.Time) string {
+	payload := base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf(`{"exp":%d}`, exp.Unix())))
+	return "header." + payload + ".sig"
+}
+
+func TestTokenRefreshedAheadOfExpiry(t *testing.T) {
+	fresh := testToken(time.Now().Add(time.Hour))
+	var mu sync.Mutex
+	var logins int
+	var tokens []string
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		mu.Lock()
+		defer mu.Unlock()
+		switch r.URL.Path {
+		case "/api/auth/login":
+			logins++
+			_, _ = io.WriteString(w, `{"code":200,"data":{"token":"`+fresh+`"}}`)
+		case "/api/fs/list":
+			tokens = append(tokens, r.Header.Get("Authorization"))
+			_, _ = io.WriteString(w, `{"code":200,"data":{"content":[]}}`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer remote.Close()
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	db.Init(dB)
+	base.InitClient()
+	d := &AListV3{Addition: Addition{Address: remote.URL, Username: "u", Password: "p",
+		Token: testToken(time.Now().Add(time.Minute))}}
+
+	// the token expiring soon is refreshed before the request, not after it fails
+	if _, err := d.list("/"); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 || len(tokens) != 1 || tokens[0] != fresh {
+		t.Fatalf("expect a login before the request, got %d logins and tokens %v", logins, tokens)
+	}
+	// a fresh token is kept
+	if _, err := d.list("/"); err != nil {
+		t.Fatal(err)
+	}
+	if logins != 1 {
+		t.Fatalf("expect no login with a fresh token, got %d logins", logins)
+	}
+}
@@ -14,6 +14,7 @@ type Addition struct {
 	Token             string `json:"token"`
 	PassUAToUpsteam   bool   `json:"pass_ua_to_upsteam" default:"true"`
 	ForwardArchiveReq bool   `json:"forward_archive_requests" default:"true"`
+	ProxyThumbnail    bool   `json:"proxy_thumbnail" help:"serve the thumbnails of the remote through this site, for remotes that can't be reached by the clients"`
 }
 
 var config = driver.Config{
@@ -1,10 +1,18 @@
 package alist_v3
 
 import (
+	"bytes"
+	"context"
+	"encoding/base64"
 	"fmt"
 	"net/http"
+	"path"
+	"strings"
+	"time"
 
 	"github.com/alist-org/alist/v3/drivers/base"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
 	"github.com/alist-org/alist/v3/internal/op"
 	"github.com/alist-org/alist/v3/pkg/utils"
 	"github.com/alist-org/alist/v3/server/common"
@@ -12,26 +20,115 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// tokenRefreshAhead is how long before the expiration the token is refreshed
+const tokenRefreshAhead = 10 * time.Minute
+
 func (d *AListV3) login() error {
 	if d.Username == "" {
 		return nil
 	}
-	var resp common.Resp[LoginResp]
-	_, _, err := d.request("/auth/login", http.MethodPost, func(req *resty.Request) {
-		req.SetResult(&resp).SetBody(base.Json{
-			"username": d.Username,
-			"password": d.Password,
+	// concurrent requests failing with 401 only need to login once
+	_, err, _ := d.loginG.Do("login", func() (string, error) {
+		var resp common.Resp[LoginResp]
+		_, _, err := d.request("/auth/login", http.MethodPost, func(req *resty.Request) {
+			req.SetResult(&resp).SetBody(base.Json{
+				"username": d.Username,
+				"password": d.Password,
+			})
+		}, true)
+		if err != nil {
+			return "", err
+		}
+		d.Token = resp.Data.Token
+		op.MustSaveDriverStorage(d)
+		return d.Token, nil
+	})
+	return err
+}
+
+// tokenExpiring checks the exp claim of the jwt token
+func (d *AListV3) tokenExpiring() bool {
+	parts := strings.Split(d.Token, ".")
+	if len(parts) != 3 {
+		return false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return false
+	}
+	exp := utils.Json.Get(payload, "exp").ToInt64()
+	return exp > 0 && time.Until(time.Unix(exp, 0)) < tokenRefreshAhead
+}
+
+// refreshToken login again if the token is about to expire
+func (d *AListV3) refreshToken() error {
+	if d.Username == "" || !d.tokenExpiring() {
+		return nil
+	}
+	return d.login()
+}
+
+func (d *AListV3) list(dirPath string) ([]ObjResp, error) {
+	var resp common.Resp[FsListResp]
+	_, _, err := d.request("/fs/list", http.MethodPost, func(req *resty.Request) {
+		req.SetResult(&resp).SetBody(ListReq{
+			PageReq: model.PageReq{
+				Page:    1,
+				PerPage: 0,
+			},
+			Path:     dirPath,
+			Password: d.MetaPassword,
+			Refresh:  false,
 		})
 	})
 	if err != nil {
-		return err
+		return nil, err
 	}
-	d.Token = resp.Data.Token
-	op.MustSaveDriverStorage(d)
-	return nil
+	return resp.Data.Content, nil
+}
+
+// thumbLink fetches the thumbnail of the remote, so that the clients don't need to reach the remote
+func (d *AListV3) thumbLink(ctx context.Context, file model.Obj) (*model.Link, error) {
+	// list the parent instead of get the file, the list is cached by the remote
+	content, err := d.list(path.Dir(file.GetPath()))
+	if err != nil {
+		return nil, err
+	}
+	var thumb string
+	for _, f := range content {
+		if f.Name == file.GetName() {
+			thumb = f.Thumb
+			break
+		}
+	}
+	if thumb == "" {
+		return nil, errs.NotSupport
+	}
+	res, err := base.RestyClient.R().SetContext(ctx).Get(thumb)
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode() >= 400 {
+		return nil, fmt.Errorf("failed get thumbnail, status: %s", res.Status())
+	}
+	contentType := res.Header().Get("Content-Type")
+	if contentType == "" {
+		contentType = "image/png"
+	}
+	return &model.Link{
+		Header: http.Header{
+			"Content-Type": []string{contentType},
+		},
+		MFile: model.NewNopMFile(bytes.NewReader(res.Body())),
+	}, nil
 }
 
 func (d *AListV3) request(api, method string, callback base.ReqCallback, retry ...bool) ([]byte, int, error) {
+	if !utils.IsBool(retry...) {
+		if err := d.refreshToken(); err != nil {
+			return nil, 0, err
+		}
+	}
 	url := d.Address + "/api" + api
 	req := base.RestyClient.R()
 	req.SetHeader("Authorization", d.Token)
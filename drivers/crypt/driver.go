@@ -28,6 +28,7 @@ type Crypt struct {
 	model.Storage
 	Addition
 	cipher        *rcCrypt.Cipher
+	oldCipher     *rcCrypt.Cipher // only present while rotating the key
 	remoteStorage driver.Driver
 }
 
@@ -51,6 +52,19 @@ func (d *Crypt) Init(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to obfuscate salt: %w", err)
 	}
+	if d.OldPassword != "" {
+		if d.FileNameEnc == "off" {
+			return fmt.Errorf("key rotation needs filename encryption to tell which key a file is encrypted with")
+		}
+		err = d.updateObfusParm(&d.OldPassword)
+		if err != nil {
+			return fmt.Errorf("failed to obfuscate old password: %w", err)
+		}
+		err = d.updateObfusParm(&d.OldSalt)
+		if err != nil {
+			return fmt.Errorf("failed to obfuscate old salt: %w", err)
+		}
+	}
 
 	isCryptExt := regexp.MustCompile(`^[.][A-Za-z0-9-_]{2,}$`).MatchString
 	if !isCryptExt(d.EncryptedSuffix) {
@@ -68,8 +82,24 @@ func (d *Crypt) Init(ctx context.Context) error {
 	}
 	d.remoteStorage = storage
 
-	p, _ := strings.CutPrefix(d.Password, obfuscatedPrefix)
-	p2, _ := strings.CutPrefix(d.Salt, obfuscatedPrefix)
+	d.cipher, err = d.newCipher(d.Password, d.Salt)
+	if err != nil {
+		return fmt.Errorf("failed to create Cipher: %w", err)
+	}
+	d.oldCipher = nil
+	if d.OldPassword != "" {
+		d.oldCipher, err = d.newCipher(d.OldPassword, d.OldSalt)
+		if err != nil {
+			return fmt.Errorf("failed to create old Cipher: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (d *Crypt) newCipher(password, salt string) (*rcCrypt.Cipher, error) {
+	p, _ := strings.CutPrefix(password, obfuscatedPrefix)
+	p2, _ := strings.CutPrefix(salt, obfuscatedPrefix)
 	config := configmap.Simple{
 		"password":                  p,
 		"password2":                 p2,
@@ -79,13 +109,7 @@ func (d *Crypt) Init(ctx context.Context) error {
 		"suffix":                    d.EncryptedSuffix,
 		"pass_bad_blocks":           "",
 	}
-	c, err := rcCrypt.NewCipher(config)
-	if err != nil {
-		return fmt.Errorf("failed to create Cipher: %w", err)
-	}
-	d.cipher = c
-
-	return nil
+	return rcCrypt.NewCipher(config)
 }
 
 func (d *Crypt) updateObfusParm(str *string) error {
@@ -110,7 +134,8 @@ func (d *Crypt) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 	//return d.list(ctx, d.RemotePath, path)
 	//remoteFull
 
-	objs, err := fs.List(ctx, d.getPathForRemote(path, true), &fs.ListArgs{NoLog: true})
+	remoteFullPath, _ := d.resolvePathForRemote(ctx, path, true)
+	objs, err := fs.List(ctx, remoteFullPath, &fs.ListArgs{NoLog: true})
 	// the obj must implement the model.SetPath interface
 	// return objs, err
 	if err != nil {
@@ -120,7 +145,7 @@ func (d *Crypt) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 	var result []model.Obj
 	for _, obj := range objs {
 		if obj.IsDir() {
-			name, err := d.cipher.DecryptDirName(obj.GetName())
+			name, _, err := d.decryptName(obj.GetName(), true)
 			if err != nil {
 				//filter illegal files
 				continue
@@ -144,7 +169,7 @@ func (d *Crypt) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([
 				//filter illegal files
 				continue
 			}
-			name, err := d.cipher.DecryptFileName(obj.GetName())
+			name, _, err := d.decryptName(obj.GetName(), false)
 			if err != nil {
 				//filter illegal files
 				continue
@@ -196,12 +221,12 @@ func (d *Crypt) Get(ctx context.Context, path string) (model.Obj, error) {
 	var remoteObj model.Obj
 	var err, err2 error
 	firstTryIsFolder, secondTry := guessPath(path)
-	remoteFullPath = d.getPathForRemote(path, firstTryIsFolder)
+	remoteFullPath, _ = d.resolvePathForRemote(ctx, path, firstTryIsFolder)
 	remoteObj, err = fs.Get(ctx, remoteFullPath, &fs.GetArgs{NoLog: true})
 	if err != nil {
 		if errs.IsObjectNotFound(err) && secondTry {
 			//try the opposite
-			remoteFullPath = d.getPathForRemote(path, !firstTryIsFolder)
+			remoteFullPath, _ = d.resolvePathForRemote(ctx, path, !firstTryIsFolder)
 			remoteObj, err2 = fs.Get(ctx, remoteFullPath, &fs.GetArgs{NoLog: true})
 			if err2 != nil {
 				return nil, err2
@@ -218,13 +243,13 @@ func (d *Crypt) Get(ctx context.Context, path string) (model.Obj, error) {
 			log.Warnf("DecryptedSize failed for %s ,will use original size, err:%s", path, err)
 			size = remoteObj.GetSize()
 		}
-		name, err = d.cipher.DecryptFileName(remoteObj.GetName())
+		name, _, err = d.decryptName(remoteObj.GetName(), false)
 		if err != nil {
 			log.Warnf("DecryptFileName failed for %s ,will use original name, err:%s", path, err)
 			name = remoteObj.GetName()
 		}
 	} else {
-		name, err = d.cipher.DecryptDirName(remoteObj.GetName())
+		name, _, err = d.decryptName(remoteObj.GetName(), true)
 		if err != nil {
 			log.Warnf("DecryptDirName failed for %s ,will use original name, err:%s", path, err)
 			name = remoteObj.GetName()
@@ -242,7 +267,7 @@ func (d *Crypt) Get(ctx context.Context, path string) (model.Obj, error) {
 }
 
 func (d *Crypt) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
-	dstDirActualPath, err := d.getActualPathForRemote(file.GetPath(), false)
+	dstDirActualPath, cipher, err := d.getActualPathForRemote(ctx, file.GetPath(), false)
 	if err != nil {
 		return nil, fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
@@ -291,7 +316,7 @@ func (d *Crypt) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (
 
 	}
 	resultRangeReader := func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
-		readSeeker, err := d.cipher.DecryptDataSeek(ctx, rangeReaderFunc, httpRange.Start, httpRange.Length)
+		readSeeker, err := cipher.DecryptDataSeek(ctx, rangeReaderFunc, httpRange.Start, httpRange.Length)
 		if err != nil {
 			return nil, err
 		}
@@ -309,7 +334,7 @@ func (d *Crypt) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (
 }
 
 func (d *Crypt) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
-	dstDirActualPath, err := d.getActualPathForRemote(parentDir.GetPath(), true)
+	dstDirActualPath, _, err := d.getActualPathForRemote(ctx, parentDir.GetPath(), true)
 	if err != nil {
 		return fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
@@ -318,11 +343,11 @@ func (d *Crypt) MakeDir(ctx context.Context, parentDir model.Obj, dirName string
 }
 
 func (d *Crypt) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
-	srcRemoteActualPath, err := d.getActualPathForRemote(srcObj.GetPath(), srcObj.IsDir())
+	srcRemoteActualPath, _, err := d.getActualPathForRemote(ctx, srcObj.GetPath(), srcObj.IsDir())
 	if err != nil {
 		return fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
-	dstRemoteActualPath, err := d.getActualPathForRemote(dstDir.GetPath(), dstDir.IsDir())
+	dstRemoteActualPath, _, err := d.getActualPathForRemote(ctx, dstDir.GetPath(), dstDir.IsDir())
 	if err != nil {
 		return fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
@@ -330,7 +355,7 @@ func (d *Crypt) Move(ctx context.Context, srcObj, dstDir model.Obj) error {
 }
 
 func (d *Crypt) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
-	remoteActualPath, err := d.getActualPathForRemote(srcObj.GetPath(), srcObj.IsDir())
+	remoteActualPath, cipher, err := d.getActualPathForRemote(ctx, srcObj.GetPath(), srcObj.IsDir())
 	if err != nil {
 		return fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
@@ -338,17 +363,18 @@ func (d *Crypt) Rename(ctx context.Context, srcObj model.Obj, newName string) er
 	if srcObj.IsDir() {
 		newEncryptedName = d.cipher.EncryptDirName(newName)
 	} else {
-		newEncryptedName = d.cipher.EncryptFileName(newName)
+		// the name must be encrypted with the same key as the data
+		newEncryptedName = cipher.EncryptFileName(newName)
 	}
 	return op.Rename(ctx, d.remoteStorage, remoteActualPath, newEncryptedName)
 }
 
 func (d *Crypt) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
-	srcRemoteActualPath, err := d.getActualPathForRemote(srcObj.GetPath(), srcObj.IsDir())
+	srcRemoteActualPath, _, err := d.getActualPathForRemote(ctx, srcObj.GetPath(), srcObj.IsDir())
 	if err != nil {
 		return fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
-	dstRemoteActualPath, err := d.getActualPathForRemote(dstDir.GetPath(), dstDir.IsDir())
+	dstRemoteActualPath, _, err := d.getActualPathForRemote(ctx, dstDir.GetPath(), dstDir.IsDir())
 	if err != nil {
 		return fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
@@ -357,7 +383,7 @@ func (d *Crypt) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
 }
 
 func (d *Crypt) Remove(ctx context.Context, obj model.Obj) error {
-	remoteActualPath, err := d.getActualPathForRemote(obj.GetPath(), obj.IsDir())
+	remoteActualPath, _, err := d.getActualPathForRemote(ctx, obj.GetPath(), obj.IsDir())
 	if err != nil {
 		return fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
@@ -365,15 +391,31 @@ func (d *Crypt) Remove(ctx context.Context, obj model.Obj) error {
 }
 
 func (d *Crypt) Put(ctx context.Context, dstDir model.Obj, streamer model.FileStreamer, up driver.UpdateProgress) error {
-	dstDirActualPath, err := d.getActualPathForRemote(dstDir.GetPath(), true)
+	dstDirActualPath, err := d.put(ctx, dstDir, streamer, up)
 	if err != nil {
-		return fmt.Errorf("failed to convert path to remote path: %w", err)
+		return err
+	}
+	if d.oldCipher != nil {
+		// the file overwritten may still be encrypted with the old key, which has a different name
+		oldPath := stdpath.Join(dstDirActualPath, d.oldCipher.EncryptFileName(streamer.GetName()))
+		if _, err := op.Get(ctx, d.remoteStorage, oldPath); err == nil {
+			return op.Remove(ctx, d.remoteStorage, oldPath)
+		}
+	}
+	return nil
+}
+
+// put encrypts the stream with the current key and uploads it, returns the actual path of dstDir in the remote
+func (d *Crypt) put(ctx context.Context, dstDir model.Obj, streamer model.FileStreamer, up driver.UpdateProgress) (string, error) {
+	dstDirActualPath, _, err := d.getActualPathForRemote(ctx, dstDir.GetPath(), true)
+	if err != nil {
+		return "", fmt.Errorf("failed to convert path to remote path: %w", err)
 	}
 
 	// Encrypt the data into wrappedIn
 	wrappedIn, err := d.cipher.EncryptData(streamer)
 	if err != nil {
-		return fmt.Errorf("failed to EncryptData: %w", err)
+		return "", fmt.Errorf("failed to EncryptData: %w", err)
 	}
 
 	// doesn't support seekableStream, since rapid-upload is not working for encrypted data
@@ -394,9 +436,9 @@ func (d *Crypt) Put(ctx context.Context, dstDir model.Obj, streamer model.FileSt
 	}
 	err = op.Put(ctx, d.remoteStorage, dstDirActualPath, streamOut, up, false)
 	if err != nil {
-		return err
+		return "", err
 	}
-	return nil
+	return dstDirActualPath, nil
 }
 
 //func (d *Safe) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
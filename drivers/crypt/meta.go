@@ -17,6 +17,8 @@ type Addition struct {
 
 	Password         string `json:"password" required:"true" confidential:"true" help:"the main password"`
 	Salt             string `json:"salt" confidential:"true"  help:"If you don't know what is salt, treat it as a second password. Optional but recommended"`
+	OldPassword      string `json:"old_password" confidential:"true" help:"the previous password while rotating the key, files encrypted with it stay readable until they are re-encrypted"`
+	OldSalt          string `json:"old_salt" confidential:"true" help:"the previous salt while rotating the key"`
 	EncryptedSuffix  string `json:"encrypted_suffix" required:"true" default:".bin" help:"for advanced user only! encrypted files will have this suffix"`
 	FileNameEncoding string `json:"filename_encoding" type:"select" required:"true" options:"base64,base32,base32768" default:"base64" help:"for advanced user only!"`
 
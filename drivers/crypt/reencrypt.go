@@ -0,0 +1,105 @@
+package crypt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	stdpath "path"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// verifySize is how much is read back to verify a re-encrypted file, a block is authenticated as a whole
+const verifySize = 64 * 1024
+
+// Reencrypt re-encrypts the obj encrypted with the old key.
+// Folders only need to be renamed, files are uploaded again with the current key
+// and verified before the old one is removed, so both keys stay valid during the migration.
+func (d *Crypt) Reencrypt(ctx context.Context, obj model.Obj, up driver.UpdateProgress) (bool, error) {
+	if d.oldCipher == nil {
+		return false, fmt.Errorf("old password is not set")
+	}
+	remoteActualPath, cipher, err := d.getActualPathForRemote(ctx, obj.GetPath(), obj.IsDir())
+	if err != nil {
+		return false, fmt.Errorf("failed to convert path to remote path: %w", err)
+	}
+	if cipher == d.cipher {
+		return false, nil
+	}
+	if obj.IsDir() {
+		return true, op.Rename(ctx, d.remoteStorage, remoteActualPath, d.cipher.EncryptDirName(obj.GetName()))
+	}
+
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		return false, err
+	}
+	defer link.RangeReadCloser.Close()
+	rc, err := link.RangeReadCloser.RangeRead(ctx, http_range.Range{Length: -1})
+	if err != nil {
+		return false, err
+	}
+	s := &stream.FileStream{
+		Ctx: ctx,
+		Obj: &model.Object{
+			Name:     obj.GetName(),
+			Size:     obj.GetSize(),
+			Modified: obj.ModTime(),
+			Ctime:    obj.CreateTime(),
+		},
+		Reader:   rc,
+		Mimetype: utils.GetMimeType(obj.GetName()),
+		Closers:  utils.NewClosers(rc),
+	}
+	dstDir := &model.Object{
+		Path:     stdpath.Dir(obj.GetPath()),
+		IsFolder: true,
+	}
+	// not Put, which removes the old file before it's verified
+	if _, err = d.put(ctx, dstDir, s, up); err != nil {
+		return false, fmt.Errorf("failed to upload with the current key: %w", err)
+	}
+	if err = d.verify(ctx, remoteActualPath, obj); err != nil {
+		return false, fmt.Errorf("failed to verify: %w", err)
+	}
+	return true, op.Remove(ctx, d.remoteStorage, remoteActualPath)
+}
+
+// verify checks the file re-encrypted from oldRemoteActualPath has the same size and can be decrypted with the current key
+func (d *Crypt) verify(ctx context.Context, oldRemoteActualPath string, obj model.Obj) error {
+	newRemoteActualPath := stdpath.Join(stdpath.Dir(oldRemoteActualPath), d.cipher.EncryptFileName(obj.GetName()))
+	newObj, err := op.Get(ctx, d.remoteStorage, newRemoteActualPath)
+	if err != nil {
+		return err
+	}
+	size, err := d.cipher.DecryptedSize(newObj.GetSize())
+	if err != nil {
+		return err
+	}
+	if size != obj.GetSize() {
+		return fmt.Errorf("size mismatch, expect %d, got %d", obj.GetSize(), size)
+	}
+	if size == 0 {
+		return nil
+	}
+	// the path is resolved to the new file now
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		return err
+	}
+	defer link.RangeReadCloser.Close()
+	rc, err := link.RangeReadCloser.RangeRead(ctx, http_range.Range{Length: min(size, verifySize)})
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.Copy(io.Discard, rc)
+	return err
+}
+
+var _ driver.Reencrypt = (*Crypt)(nil)
@@ -0,0 +1,133 @@
+package crypt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/alist-org/alist/v3/drivers/local"
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/fs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+	"github.com/xhofe/tache"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// mountCrypt mounts a crypt storage at mountPath over /raw
+func mountCrypt(t *testing.T, mountPath, password, oldPassword string) *Crypt {
+	addition := fmt.Sprintf(`{"filename_encryption":"standard","directory_name_encryption":"true","remote_path":"/raw",`+
+		`"password":%q,"old_password":%q,"encrypted_suffix":".bin","filename_encoding":"base64","show_hidden":true}`, password, oldPassword)
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: "Crypt", MountPath: mountPath, Addition: addition}); err != nil {
+		t.Fatal(err)
+	}
+	d, err := op.GetStorageByMountPath(mountPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return d.(*Crypt)
+}
+
+func putFile(t *testing.T, d driver.Driver, dir, name, content string) {
+	s := &stream.FileStream{
+		Obj:    &model.Object{Name: name, Size: int64(len(content)), Modified: time.Now()},
+		Reader: strings.NewReader(content),
+	}
+	if err := op.Put(context.Background(), d, dir, s, nil); err != nil {
+		t.Fatalf("put %s: %v", name, err)
+	}
+}
+
+func readFile(t *testing.T, d *Crypt, path string) string {
+	obj, err := op.Get(context.Background(), d, path)
+	if err != nil {
+		t.Fatalf("get %s: %v", path, err)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatalf("link %s: %v", path, err)
+	}
+	defer link.RangeReadCloser.Close()
+	rc, err := link.RangeReadCloser.RangeRead(context.Background(), http_range.Range{Length: -1})
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read %s: %v", path, err)
+	}
+	return string(data)
+}
+
+func TestReencrypt(t *testing.T) {
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	db.Init(dB)
+	root := t.TempDir()
+	addition := fmt.Sprintf(`{"root_folder_path":%q,"mkdir_perm":"777","recycle_bin_path":"delete permanently","thumb_concurrency":"16","show_hidden":true}`, root)
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: "Local", MountPath: "/raw", Addition: addition}); err != nil {
+		t.Fatal(err)
+	}
+
+	old := mountCrypt(t, "/old", "old", "")
+	if err := op.MakeDir(context.Background(), old, "/dir"); err != nil {
+		t.Fatal(err)
+	}
+	putFile(t, old, "/dir", "a.txt", "hello")
+
+	// while rotating, the files encrypted with either key are readable
+	rotating := mountCrypt(t, "/rotating", "new", "old")
+	putFile(t, rotating, "/dir", "b.txt", "world")
+	if got := readFile(t, rotating, "/dir/a.txt"); got != "hello" {
+		t.Fatalf("expect the old file readable, got %q", got)
+	}
+	if got := readFile(t, rotating, "/dir/b.txt"); got != "world" {
+		t.Fatalf("expect the new file readable, got %q", got)
+	}
+
+	fs.ReencryptTaskManager = tache.NewManager[*fs.ReencryptTask](tache.WithWorks(1))
+	tsk, err := fs.Reencrypt(context.Background(), "/rotating")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fs.ReencryptTaskManager.Wait()
+	if err := tsk.GetErr(); err != nil {
+		t.Fatalf("task: %v", err)
+	}
+	if rt := tsk.(*fs.ReencryptTask); rt.Migrated != 2 || rt.Skipped != 1 {
+		t.Fatalf("expect the folder and a.txt migrated and b.txt current, got %d migrated, %d current", rt.Migrated, rt.Skipped)
+	}
+
+	// everything is readable with the new key alone, nothing is left encrypted with the old one
+	current := mountCrypt(t, "/current", "new", "")
+	for name, want := range map[string]string{"a.txt": "hello", "b.txt": "world"} {
+		if got := readFile(t, current, "/dir/"+name); got != want {
+			t.Fatalf("expect %s to be %q, got %q", name, want, got)
+		}
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expect a single folder in the remote, got %d entries", len(entries))
+	}
+	files, _ := os.ReadDir(root + "/" + entries[0].Name())
+	if len(files) != 2 {
+		t.Fatalf("expect the old copies removed, got %d files", len(files))
+	}
+}
@@ -1,11 +1,14 @@
 package crypt
 
 import (
+	"context"
 	stdpath "path"
 	"path/filepath"
 	"strings"
 
+	"github.com/alist-org/alist/v3/internal/fs"
 	"github.com/alist-org/alist/v3/internal/op"
+	rcCrypt "github.com/rclone/rclone/backend/crypt"
 )
 
 // will give the best guessing based on the path
@@ -38,7 +41,70 @@ func (d *Crypt) getPathForRemote(path string, isFolder bool) (remoteFullPath str
 }
 
 // actual path is used for internal only. any link for user should come from remoteFullPath
-func (d *Crypt) getActualPathForRemote(path string, isFolder bool) (string, error) {
-	_, remoteActualPath, err := op.GetStorageAndActualPath(d.getPathForRemote(path, isFolder))
-	return remoteActualPath, err
+// the cipher is the one the last element of the path is encrypted with
+func (d *Crypt) getActualPathForRemote(ctx context.Context, path string, isFolder bool) (string, *rcCrypt.Cipher, error) {
+	remoteFullPath, cipher := d.resolvePathForRemote(ctx, path, isFolder)
+	_, remoteActualPath, err := op.GetStorageAndActualPath(remoteFullPath)
+	return remoteActualPath, cipher, err
+}
+
+func (d *Crypt) ciphers() []*rcCrypt.Cipher {
+	if d.oldCipher == nil {
+		return []*rcCrypt.Cipher{d.cipher}
+	}
+	return []*rcCrypt.Cipher{d.cipher, d.oldCipher}
+}
+
+// decryptName tries the current key first and then the old key while rotating the key
+func (d *Crypt) decryptName(name string, isFolder bool) (string, *rcCrypt.Cipher, error) {
+	var err error
+	for _, c := range d.ciphers() {
+		var decrypted string
+		if isFolder {
+			decrypted, err = c.DecryptDirName(name)
+		} else {
+			decrypted, err = c.DecryptFileName(name)
+		}
+		if err == nil {
+			return decrypted, c, nil
+		}
+	}
+	return "", nil, err
+}
+
+// resolvePathForRemote is getPathForRemote which also works while rotating the key.
+// The elements of the path may be encrypted with either key, so every element is looked up in the remote.
+func (d *Crypt) resolvePathForRemote(ctx context.Context, path string, isFolder bool) (string, *rcCrypt.Cipher) {
+	if d.oldCipher == nil {
+		return d.getPathForRemote(path, isFolder), d.cipher
+	}
+	remoteFullPath := d.RemotePath
+	cipher := d.cipher
+	elems := strings.FieldsFunc(path, func(r rune) bool { return r == '/' })
+	for i, elem := range elems {
+		isFile := i == len(elems)-1 && !isFolder
+		var name string
+		cipher = d.cipher
+		for j, c := range d.ciphers() {
+			if isFile {
+				name = c.EncryptFileName(elem)
+			} else {
+				name = c.EncryptDirName(elem)
+			}
+			// the parent may not exist either, so any error is treated as not found
+			if _, err := fs.Get(ctx, stdpath.Join(remoteFullPath, name), &fs.GetArgs{NoLog: true}); err == nil {
+				cipher = c
+				break
+			}
+			if j == len(d.ciphers())-1 {
+				// not exist yet, such as the destination of put, use the current key
+				name = d.cipher.EncryptDirName(elem)
+				if isFile {
+					name = d.cipher.EncryptFileName(elem)
+				}
+			}
+		}
+		remoteFullPath = stdpath.Join(remoteFullPath, name)
+	}
+	return remoteFullPath, cipher
 }
@@ -0,0 +1,52 @@
+package notion
+
+import (
+	"fmt"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+)
+
+// 存储的访问模式
+const (
+	accessReadWrite = "read_write"
+	// accessReadOnly 拒绝所有修改
+	accessReadOnly = "read_only"
+	// accessWriteOnce 只允许新建文件和目录，已有的内容不能覆盖、修改、移动、重命名或删除
+	accessWriteOnce = "write_once"
+)
+
+// otherWrites 修改存储内容的Other方法，值表示方法是否只新建内容；
+// 快照只是元数据的备份，创建和删除快照不受访问模式限制
+var otherWrites = map[string]bool{
+	"restore_version":  false,
+	"delete_version":   false,
+	"restore_snapshot": false,
+	"purge_trash":      false,
+	"set_tags":         false,
+	"batch_update":     false,
+	"rebuild_meta":     false,
+	"adopt_orphans":    true,
+	"make_alias":       true,
+	"repair_chunks":    true, // 恢复的分块内容不变，只新建页面
+}
+
+// checkWrite 按访问模式检查写操作，create为true表示操作只新建文件或目录
+func (d *Notion) checkWrite(create bool) error {
+	switch d.AccessMode {
+	case accessReadOnly:
+		return fmt.Errorf("存储为只读模式: %w", errs.PermissionDenied)
+	case accessWriteOnce:
+		if !create {
+			return fmt.Errorf("存储为一次写入模式，已有的内容不能修改或删除: %w", errs.PermissionDenied)
+		}
+	}
+	return nil
+}
+
+// checkOverwrite 一次写入模式下拒绝覆盖已存在的文件
+func (d *Notion) checkOverwrite(existing *File) error {
+	if existing != nil && d.AccessMode == accessWriteOnce {
+		return fmt.Errorf("存储为一次写入模式，不能覆盖已存在的文件[%s]: %w", existing.Name, errs.PermissionDenied)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestAccessReadOnly(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := d.Put(ctx, dir, newTestStream("a.txt", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.AccessMode = accessReadOnly
+	denied := func(op string, err error) {
+		t.Helper()
+		if !errors.Is(err, errs.PermissionDenied) {
+			t.Fatalf("%s: expect denied, got %v", op, err)
+		}
+	}
+	_, err = d.MakeDir(ctx, rootDir(d), "new")
+	denied("make dir", err)
+	_, err = d.Put(ctx, dir, newTestStream("b.txt", testData(10)), func(float64) {})
+	denied("put", err)
+	_, err = d.Rename(ctx, file, "c.txt")
+	denied("rename", err)
+	_, err = d.Move(ctx, file, rootDir(d))
+	denied("move", err)
+	_, err = d.Copy(ctx, file, rootDir(d))
+	denied("copy", err)
+	denied("remove", d.Remove(ctx, file))
+	_, err = d.CreateUploadSession(ctx, dir, "d.txt", 10)
+	denied("upload session", err)
+	_, err = d.Other(ctx, model.OtherArgs{Obj: file, Method: "set_tags", Data: TagReq{Tags: map[string]string{"k": "v"}}})
+	denied("set tags", err)
+
+	// 读取不受限制
+	if names := listNames(t, d, dir); len(names) != 1 {
+		t.Fatalf("expect a.txt listed, got %v", names)
+	}
+	if _, err := d.Link(ctx, file, model.LinkArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Other(ctx, model.OtherArgs{Obj: file, Method: "get_tags"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestAccessWriteOnce(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.AccessMode = accessWriteOnce })
+	ctx := context.Background()
+	// 新建文件和目录
+	dir, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := d.Put(ctx, dir, newTestStream("a.txt", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Copy(ctx, file, rootDir(d)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 已有的内容不能覆盖、修改或删除
+	if _, err := d.Put(ctx, dir, newTestStream("a.txt", testData(20)), func(float64) {}); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect overwriting denied, got %v", err)
+	}
+	if _, err := d.CreateUploadSession(ctx, dir, "a.txt", 20); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect an upload session overwriting a.txt denied, got %v", err)
+	}
+	if _, err := d.Rename(ctx, file, "b.txt"); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect renaming denied, got %v", err)
+	}
+	if err := d.Remove(ctx, dir); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect removing denied, got %v", err)
+	}
+	if _, err := d.Append(ctx, file, newTestStream("a.txt", testData(5)), func(float64) {}); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect appending denied, got %v", err)
+	}
+	// 只新建内容的Other方法可以使用
+	if _, err := d.Other(ctx, model.OtherArgs{Obj: dir, Method: "make_alias", Data: AliasReq{TargetID: file.GetID(), Name: "alias.txt"}}); err != nil {
+		t.Fatalf("expect an alias made, got %v", err)
+	}
+	if _, err := d.Other(ctx, model.OtherArgs{Obj: file, Method: "set_tags", Data: TagReq{Tags: map[string]string{"k": "v"}}}); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect setting tags denied, got %v", err)
+	}
+	if names := listNames(t, d, dir); len(names) != 2 {
+		t.Fatalf("expect a.txt and its alias, got %v", names)
+	}
+}
@@ -0,0 +1,86 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// AliasReq make_alias的请求参数，在args.Obj目录下创建指向TargetID的别名，Dir表示目标为目录，
+// Name为空时使用目标的名称
+type AliasReq struct {
+	TargetID string `json:"target_id"`
+	Dir      bool   `json:"dir"`
+	Name     string `json:"name"`
+}
+
+// listAliases 目录中的别名，与目录或文件同名的别名不列出
+func (d *Notion) listAliases(ctx context.Context, args model.ListArgs, dirID int, names map[string]bool, fn func(obj model.Obj) error) error {
+	aliases, err := d.tree.ListAliases(dirID)
+	if err != nil {
+		return err
+	}
+	for i := range aliases {
+		if names[aliases[i].Name] {
+			continue
+		}
+		obj, err := d.aliasObj(ctx, args, &aliases[i])
+		if err != nil {
+			return err
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// aliasObj 别名在列表中的对象，ID为目标的ID，读取和列出时访问目标
+func (d *Notion) aliasObj(ctx context.Context, args model.ListArgs, a *Alias) (model.Obj, error) {
+	dir, f, err := d.tree.AliasTarget(a)
+	if err != nil {
+		return nil, err
+	}
+	obj := &dbfs.AliasObj{Alias: *a, Target: d.tree.TargetPath(dir, f)}
+	if dir != nil {
+		obj.Obj = dbfs.DirToObj(dir)
+	} else {
+		// 缩略图地址使用别名的名称
+		target := *f
+		target.Name = a.Name
+		obj.Obj = d.listFileObj(ctx, args, &target)
+	}
+	return obj, nil
+}
+
+// makeAlias 在目录下创建别名，同一文件可以出现在多个目录中而不复制元数据
+func (d *Notion) makeAlias(ctx context.Context, dir model.Obj, req AliasReq) (model.Obj, error) {
+	if dir == nil || !dir.IsDir() {
+		return nil, fmt.Errorf("别名只能创建在目录中")
+	}
+	targetID, err := strconv.Atoi(req.TargetID)
+	if err != nil {
+		return nil, fmt.Errorf("无效的目标ID: %s", req.TargetID)
+	}
+	name := req.Name
+	if name == "" {
+		target, f, err := d.tree.AliasTarget(&Alias{TargetID: targetID, IsDir: req.Dir})
+		if err != nil {
+			return nil, err
+		}
+		if target != nil {
+			name = target.Name
+		} else {
+			name = f.Name
+		}
+	}
+	dirID, _ := strconv.Atoi(dir.GetID())
+	a, err := d.tree.MakeAlias(dirID, name, targetID, req.Dir)
+	if err != nil {
+		return nil, err
+	}
+	return d.aliasObj(ctx, model.ListArgs{}, a)
+}
@@ -0,0 +1,182 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// Append 在文件末尾追加数据，已有的分块不变，末尾分块未满时与追加的数据一起重新上传为新页面
+// 未分块的文件先视为只有一个分块的分块文件；追加直接修改文件，不产生历史版本
+func (d *Notion) Append(ctx context.Context, obj model.Obj, file model.FileStreamer, up driver.UpdateProgress) (newObj model.Obj, err error) {
+	defer func() { d.audit(ctx, AuditAppend, "", newObj, err) }()
+	if err = d.checkWrite(false); err != nil {
+		return nil, err
+	}
+	f, err := d.tree.GetFile(obj.GetID())
+	if err != nil {
+		return nil, err
+	}
+	size := file.GetSize()
+	if size <= 0 {
+		return dbfs.FileToObj(f), nil
+	}
+	if err := d.checkUploadSize(f.Name, f.Size+size); err != nil {
+		return nil, err
+	}
+	if err := d.scanUpload(ctx, file); err != nil {
+		return nil, err
+	}
+	if f.IsInline() && f.Size+size <= d.inlineLimit() {
+		return d.appendInline(f, file)
+	}
+	// 数据库中和打包页面中的文件内容与追加的数据一起上传
+	prefix := f.Inline
+	if f.Packed {
+		if prefix, err = d.readPageFile(ctx, f); err != nil {
+			return nil, err
+		}
+	}
+
+	var chunks []chunkstore.Chunk
+	if f.IsChunked {
+		fileChunks, err := d.fileChunks(f.ID)
+		if err != nil {
+			return nil, err
+		}
+		chunks = toChunks(fileChunks)
+	} else if !f.IsInline() && !f.Packed && f.Size > 0 {
+		chunks = []chunkstore.Chunk{{Start: 0, End: f.Size, Key: f.BlobKey, Hash: f.SHA1}}
+	}
+	if len(chunks) > 0 && d.chunkKey == nil && chunks[len(chunks)-1].Nonce != "" {
+		return nil, fmt.Errorf("文件已加密，需要配置加密密钥")
+	}
+
+	tempFile, err := file.CacheFullInTempFile()
+	if err != nil {
+		return nil, fmt.Errorf("缓存文件失败: %w", err)
+	}
+	var r io.ReaderAt = tempFile
+	if len(prefix) > 0 {
+		tmp, err := utils.CreateTempFile(io.MultiReader(bytes.NewReader(prefix), io.NewSectionReader(tempFile, 0, size)), f.Size+size)
+		if err != nil {
+			return nil, fmt.Errorf("缓存文件失败: %w", err)
+		}
+		defer utils.RemoveTempFile(tmp)
+		r, size = tmp, f.Size+size
+	}
+	backend, err := d.newChunkBackend(f.Name, d.contentType(f.Name, nil))
+	if err != nil {
+		return nil, err
+	}
+	sizer := d.newSizer()
+	var uploaded []chunkstore.Chunk
+	err = d.uploadTask(ctx, f.DirectoryID, f.Name, size, 0, false, up, func(ctx context.Context, up driver.UpdateProgress) error {
+		var err error
+		uploaded, err = chunkstore.Append(ctx, backend, chunks, r, size, sizer, up)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("追加分块失败: %w", err)
+	}
+
+	// 被重新上传的末尾分块的页面不再被引用
+	var pageIDs []string
+	first := uploaded[0].Index
+	for _, chunk := range chunks[first:] {
+		pageIDs = append(pageIDs, chunk.Key)
+	}
+	if f.Packed {
+		pageIDs = append(pageIDs, f.BlobKey)
+	}
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		// 追加后分组变化，原有的校验分块不再适用
+		parityPageIDs, err := deleteParity(tx, f.ID)
+		if err != nil {
+			return err
+		}
+		pageIDs = append(pageIDs, parityPageIDs...)
+		if f.IsChunked {
+			if err := tx.Model(&FileChunk{}).Where("file_id = ? AND deleted = ? AND chunk_index >= ?", f.ID, false, first).
+				Update("deleted", true).Error; err != nil {
+				return fmt.Errorf("替换末尾分块失败: %w", err)
+			}
+		} else if first > 0 {
+			// 原文件的页面保留为第一个分块
+			if err := tx.Create(&FileChunk{
+				FileID:      f.ID,
+				ChunkIndex:  0,
+				ChunkSize:   f.Size,
+				StartOffset: 0,
+				EndOffset:   f.Size,
+				BlobKey:     f.BlobKey,
+				SHA1:        f.SHA1,
+			}).Error; err != nil {
+				return fmt.Errorf("保存分块记录失败: %w", err)
+			}
+		}
+		newChunks := make([]FileChunk, 0, len(uploaded))
+		for _, chunk := range uploaded {
+			newChunks = append(newChunks, FileChunk{
+				FileID:      f.ID,
+				ChunkIndex:  chunk.Index,
+				ChunkSize:   chunk.Size(),
+				StartOffset: chunk.Start,
+				EndOffset:   chunk.End,
+				BlobKey:     chunk.Key,
+				SHA1:        chunk.Hash,
+				Nonce:       chunk.Nonce,
+				KeyID:       chunk.KeyID,
+			})
+		}
+		if err := tx.Create(&newChunks).Error; err != nil {
+			return fmt.Errorf("保存分块记录失败: %w", err)
+		}
+		// 整个文件的哈希无法在追加时增量计算，清空后不再提供
+		f.Size = uploaded[len(uploaded)-1].End
+		f.IsChunked = true
+		f.ChunkSize = MaxChunkSize
+		f.BlobKey = ""
+		f.Packed, f.BlobIndex = false, 0
+		f.Inline = nil
+		f.SHA1, f.MD5, f.SHA256 = "", "", ""
+		f.Version++
+		if err := tx.Select("size", "is_chunked", "chunk_size", "notion_page_id", "packed", "blob_index", "inline_data", "sha1", "md5", "sha256", "version").Updates(f).Error; err != nil {
+			return fmt.Errorf("更新文件信息失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.archivePages(pageIDs)
+	d.mirrorFile(f)
+	return dbfs.FileToObj(f), nil
+}
+
+// appendInline 追加后仍不超过InlineSize的文件继续保存在数据库中，哈希按完整内容重新计算
+func (d *Notion) appendInline(f *File, file model.FileStreamer) (model.Obj, error) {
+	data := make([]byte, file.GetSize())
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	f.Inline = append(f.Inline, data...)
+	f.Size = int64(len(f.Inline))
+	hasher := utils.NewMultiHasher(d.hashTypes())
+	hasher.Write(f.Inline)
+	f.SHA1, f.MD5, f.SHA256 = "", "", ""
+	f.SetHashes(hasher.GetHashInfo())
+	f.Version++
+	if err := d.db.Select("size", "inline_data", "sha1", "md5", "sha256", "version").Updates(f).Error; err != nil {
+		return nil, fmt.Errorf("更新文件信息失败: %w", err)
+	}
+	return dbfs.FileToObj(f), nil
+}
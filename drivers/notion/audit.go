@@ -0,0 +1,89 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	AuditMakeDir = "mkdir"
+	AuditMove    = "move"
+	AuditRename  = "rename"
+	AuditCopy    = "copy"
+	AuditRemove  = "remove"
+	AuditPut     = "put"
+	AuditAppend  = "append"
+)
+
+// AuditReq list_audit_logs的请求参数，Path为空时不过滤路径
+type AuditReq struct {
+	Path    string `json:"path"`
+	Op      string `json:"op"`
+	Page    int    `json:"page"`
+	PerPage int    `json:"per_page"`
+}
+
+// AuditResp list_audit_logs的返回结果，新记录在前
+type AuditResp struct {
+	Content []AuditLog `json:"content"`
+	Total   int64      `json:"total"`
+}
+
+// auditPath 操作前对象的路径，未开启审计日志时不查询
+func (d *Notion) auditPath(obj model.Obj) string {
+	if !d.AuditLog {
+		return ""
+	}
+	return d.tree.ObjPath(obj)
+}
+
+// audit 记录一次成功的操作，obj为操作后的对象（删除时为nil），写入失败只记录日志
+func (d *Notion) audit(ctx context.Context, op, oldPath string, obj model.Obj, err error) {
+	if !d.AuditLog || err != nil {
+		return
+	}
+	l := AuditLog{
+		DatabaseID: d.NotionDatabaseID,
+		Op:         op,
+		OldPath:    oldPath,
+	}
+	if obj != nil {
+		l.NewPath = d.tree.ObjPath(obj)
+	}
+	if user, ok := ctx.Value("user").(*model.User); ok {
+		l.UserName = user.Username
+	}
+	if err := d.db.Create(&l).Error; err != nil {
+		log.Warnf("记录审计日志失败: %+v", err)
+	}
+}
+
+// listAuditLogs 分页查询当前存储的审计日志，Path会匹配旧路径或新路径在其之下的记录
+func (d *Notion) listAuditLogs(req AuditReq) (*AuditResp, error) {
+	if req.Page < 1 {
+		req.Page = 1
+	}
+	if req.PerPage < 1 {
+		req.PerPage = 100
+	}
+	q := d.db.Model(&AuditLog{}).Where("database_id = ?", d.NotionDatabaseID)
+	if req.Op != "" {
+		q = q.Where("op = ?", req.Op)
+	}
+	if req.Path != "" {
+		p := strings.TrimSuffix(req.Path, "/")
+		q = q.Where("old_path = ? OR new_path = ? OR old_path LIKE ? OR new_path LIKE ?", p, p, p+"/%", p+"/%")
+	}
+	var resp AuditResp
+	if err := q.Count(&resp.Total).Error; err != nil {
+		return nil, fmt.Errorf("统计审计日志失败: %w", err)
+	}
+	if err := q.Order("id DESC").Offset((req.Page - 1) * req.PerPage).Limit(req.PerPage).Find(&resp.Content).Error; err != nil {
+		return nil, fmt.Errorf("获取审计日志失败: %w", err)
+	}
+	return &resp, nil
+}
@@ -0,0 +1,84 @@
+package notion
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// listAudit 通过list_audit_logs查询审计日志
+func listAudit(t *testing.T, d *Notion, req AuditReq) *AuditResp {
+	t.Helper()
+	res, err := d.Other(context.Background(), model.OtherArgs{Obj: rootDir(d), Method: "list_audit_logs", Data: req})
+	if err != nil {
+		t.Fatalf("list_audit_logs: %v", err)
+	}
+	return res.(*AuditResp)
+}
+
+func TestAuditLog(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.AuditLog = true })
+	ctx := context.WithValue(context.Background(), "user", &model.User{Username: "alice"})
+	docs, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.Put(ctx, docs, newTestStream("a.bin", testData(100)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if obj, err = d.Rename(ctx, obj, "b.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if obj, err = d.Move(ctx, obj, rootDir(d)); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	// 失败的操作不记录
+	if _, err := d.Rename(ctx, &model.Object{ID: "999", Name: "missing"}, "x"); err == nil {
+		t.Fatal("expect renaming a missing file to fail")
+	}
+
+	all := listAudit(t, d, AuditReq{})
+	want := []AuditLog{
+		{Op: AuditRemove, OldPath: "/b.bin"},
+		{Op: AuditMove, OldPath: "/docs/b.bin", NewPath: "/b.bin"},
+		{Op: AuditRename, OldPath: "/docs/a.bin", NewPath: "/docs/b.bin"},
+		{Op: AuditPut, NewPath: "/docs/a.bin"},
+		{Op: AuditMakeDir, NewPath: "/docs"},
+	}
+	if all.Total != int64(len(want)) || len(all.Content) != len(want) {
+		t.Fatalf("expect %d records, got %d: %+v", len(want), all.Total, all.Content)
+	}
+	for i, w := range want {
+		got := all.Content[i]
+		if got.Op != w.Op || got.OldPath != w.OldPath || got.NewPath != w.NewPath || got.UserName != "alice" {
+			t.Fatalf("record %d is %+v, want %+v by alice", i, got, w)
+		}
+	}
+
+	// 按路径过滤时匹配其下的记录，按操作过滤，分页
+	if res := listAudit(t, d, AuditReq{Path: "/docs/"}); res.Total != 4 {
+		t.Fatalf("expect 4 records under /docs, got %+v", res.Content)
+	}
+	if res := listAudit(t, d, AuditReq{Path: "/b.bin", Op: AuditRemove}); res.Total != 1 || res.Content[0].Op != AuditRemove {
+		t.Fatalf("expect the removal of /b.bin, got %+v", res.Content)
+	}
+	res := listAudit(t, d, AuditReq{Page: 2, PerPage: 2})
+	if res.Total != 5 || len(res.Content) != 2 || res.Content[0].Op != AuditRename {
+		t.Fatalf("expect the second page starting with the rename, got %+v", res.Content)
+	}
+}
+
+func TestAuditLogDisabled(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	if _, err := d.MakeDir(context.Background(), rootDir(d), "docs"); err != nil {
+		t.Fatal(err)
+	}
+	if res := listAudit(t, d, AuditReq{}); res.Total != 0 {
+		t.Fatalf("expect no records, got %+v", res.Content)
+	}
+}
@@ -0,0 +1,144 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/op"
+	"gorm.io/gorm"
+)
+
+// batchMaxFiles 一次批量修改的文件数上限
+const batchMaxFiles = 10000
+
+// BatchReq batch_update的请求参数，Files为文件ID，对全部文件执行同样的修改：
+// 设置修改时间、设置和删除标签、移动到DstDirID目录。修改在一个事务中执行，任一文件失败时不修改任何文件
+type BatchReq struct {
+	Files    []string          `json:"files"`
+	Modified *time.Time        `json:"modified"`
+	Tags     map[string]string `json:"tags"`
+	Remove   []string          `json:"remove"`
+	DstDirID string            `json:"dst_dir_id"`
+}
+
+// BatchResp batch_update的返回结果
+type BatchResp struct {
+	Updated int `json:"updated"`
+}
+
+// batchUpdate 在一个事务中修改多个文件的元数据，只修改数据库，不读写Notion页面
+func (d *Notion) batchUpdate(ctx context.Context, req BatchReq) (*BatchResp, error) {
+	if len(req.Files) == 0 {
+		return nil, fmt.Errorf("没有要修改的文件")
+	}
+	if len(req.Files) > batchMaxFiles {
+		return nil, fmt.Errorf("一次最多修改%d个文件", batchMaxFiles)
+	}
+	if req.Modified == nil && len(req.Tags) == 0 && len(req.Remove) == 0 && req.DstDirID == "" {
+		return nil, fmt.Errorf("没有要执行的修改")
+	}
+	if err := checkTags(req.Tags); err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(req.Files))
+	seen := make(map[int]bool, len(req.Files))
+	for _, s := range req.Files {
+		id, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的文件ID: %s", s)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	var dstDirID int
+	if req.DstDirID != "" {
+		dir, err := d.tree.GetDir(req.DstDirID)
+		if err != nil || !d.tree.InBase(dir.ID) {
+			return nil, fmt.Errorf("目标目录%s不存在", req.DstDirID)
+		}
+		dstDirID = dir.ID
+	}
+
+	var files []File
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("id IN ? AND deleted = ?", ids, false).Find(&files).Error; err != nil {
+			return fmt.Errorf("查询文件失败: %w", err)
+		}
+		if len(files) != len(ids) {
+			return fmt.Errorf("%d个文件不存在", len(ids)-len(files))
+		}
+		names := make(map[string]bool, len(files))
+		for _, f := range files {
+			if !d.tree.InBase(f.DirectoryID) {
+				return fmt.Errorf("文件%d不在存储的根目录下", f.ID)
+			}
+			if dstDirID == 0 || f.DirectoryID == dstDirID {
+				continue
+			}
+			name := d.tree.NormName(f.Name)
+			if names[name] {
+				return fmt.Errorf("移动的文件中有多个%s", f.Name)
+			}
+			names[name] = true
+			var n int64
+			if err := d.tree.WhereName(tx.Model(&File{}), f.Name).
+				Where("directory_id = ? AND deleted = ?", dstDirID, false).Count(&n).Error; err != nil {
+				return err
+			}
+			if n > 0 {
+				return fmt.Errorf("目标目录中已有%s", f.Name)
+			}
+		}
+		columns := map[string]interface{}{}
+		if req.Modified != nil {
+			columns["updated_at"] = *req.Modified
+		}
+		if dstDirID != 0 {
+			columns["directory_id"] = dstDirID
+		}
+		if len(columns) > 0 {
+			columns["version"] = dbfs.NextVersion
+			if err := tx.Model(&File{}).Where("id IN ?", ids).UpdateColumns(columns).Error; err != nil {
+				return fmt.Errorf("更新文件失败: %w", err)
+			}
+		}
+		if len(req.Tags) > 0 || len(req.Remove) > 0 {
+			for _, id := range ids {
+				if err := dbfs.SetFileTags(tx, id, req.Tags, req.Remove); err != nil {
+					return fmt.Errorf("设置文件%d的标签失败: %w", id, err)
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 列表缓存中的文件已过期
+	op.ClearCache(d, "/")
+	for i := range files {
+		f := &files[i]
+		oldPath := d.auditPath(dbfs.FileToObj(f))
+		if req.Modified != nil {
+			f.UpdatedAt = *req.Modified
+		}
+		if dstDirID != 0 && f.DirectoryID != dstDirID {
+			f.DirectoryID = dstDirID
+			d.audit(ctx, AuditMove, oldPath, dbfs.FileToObj(f), nil)
+		}
+	}
+	if d.MirrorMeta {
+		go func() {
+			for i := range files {
+				d.mirrorFile(&files[i])
+			}
+		}()
+	}
+	return &BatchResp{Updated: len(files)}, nil
+}
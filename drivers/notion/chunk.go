@@ -0,0 +1,238 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/Xhofe/go-cache"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/alist-org/alist/v3/pkg/utils/random"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// defaultChunkName 分块标题模板为空或执行失败时使用的标题
+const defaultChunkName = "{{.Name}}.chunk{{.Index}}"
+
+// chunkModeCDC 按内容切分分块的模式
+const chunkModeCDC = "cdc"
+
+// chunkURLTTL 分块下载链接的缓存时间，短于Notion签名链接的有效期；
+// 播放视频和浏览压缩包时对同一分块的大量Range请求不再每次都查询页面属性
+const chunkURLTTL = 10 * time.Minute
+
+// ChunkNameVars 分块标题模板可以使用的变量
+type ChunkNameVars struct {
+	Name  string
+	Base  string
+	Ext   string
+	Index int
+}
+
+// chunkBackend 将分块存储为Notion页面的附件，分块的key为页面ID
+type chunkBackend struct {
+	d        *Notion
+	fileName string
+	mimetype string
+	// tried 已尝试上传的分块序号，再次上传同一分块是失败后的重试
+	tried   map[int]bool
+	triedMu sync.Mutex
+}
+
+// newChunkBackend 配置了加密密钥时，分块在上传前使用AES-256-GCM加密
+func (d *Notion) newChunkBackend(fileName, mimetype string) (chunkstore.Backend, error) {
+	b := &chunkBackend{
+		d:        d,
+		fileName: fileName,
+		mimetype: mimetype,
+		tried:    make(map[int]bool),
+	}
+	if d.chunkKey == nil {
+		if d.ChunkDedup {
+			return &dedupChunkBackend{b}, nil
+		}
+		return b, nil
+	}
+	return chunkstore.NewEncryptedBackend(b, d.chunkKey)
+}
+
+func (b *chunkBackend) NewChunk(ctx context.Context, index int) (string, error) {
+	return b.d.chunkClient.CreateDatabasePage(b.d.chunkPageTitle(b.fileName, index))
+}
+
+func (b *chunkBackend) Upload(ctx context.Context, chunk *chunkstore.Chunk, r io.Reader, size int64, up model.UpdateProgress) error {
+	hooks, _ := ctx.Value(chunkHooksKey{}).(*chunkHooks)
+	if hooks != nil {
+		if err := hooks.start(chunk.Index); err != nil {
+			return err
+		}
+	}
+	b.triedMu.Lock()
+	if b.tried[chunk.Index] {
+		retries.WithLabelValues("chunk_upload").Inc()
+	}
+	b.tried[chunk.Index] = true
+	b.triedMu.Unlock()
+	// 镜像到备用数据库时需要再次读取分块，加密后的数据流先缓存到临时文件
+	ra, ok := r.(io.ReaderAt)
+	if b.d.secondaryClient != nil && !ok {
+		tmp, err := utils.CreateTempFile(r, size)
+		if err != nil {
+			return err
+		}
+		defer utils.RemoveTempFile(tmp)
+		r, ra = tmp, tmp
+	}
+	ctx, span := tracer.Start(ctx, "notion.chunk.upload", trace.WithAttributes(
+		attribute.Int("chunk.index", chunk.Index), attribute.String("page_id", chunk.Key), attribute.Int64("bytes", size)))
+	title := b.d.chunkPageTitle(b.fileName, chunk.Index)
+	stream := &ChunkFileStream{
+		Reader:   r,
+		name:     title,
+		size:     size,
+		mimetype: b.mimetype,
+	}
+	hash, err := b.d.chunkClient.UploadAndUpdateFilePut(ctx, stream, chunk.Key, up)
+	endSpan(span, err)
+	if err != nil {
+		return err
+	}
+	chunk.Hash = hash
+	b.d.writeSecondary(ctx, chunk.Key, title, b.mimetype, ra, size)
+	if hooks != nil {
+		hooks.done(*chunk)
+	}
+	return nil
+}
+
+func (b *chunkBackend) Open(ctx context.Context, chunk chunkstore.Chunk, offset, length int64, refresh bool) (io.ReadCloser, error) {
+	if refresh {
+		retries.WithLabelValues("chunk_download").Inc()
+	}
+	ctx, span := tracer.Start(ctx, "notion.chunk.read", trace.WithAttributes(
+		attribute.Int("chunk.index", chunk.Index), attribute.String("page_id", chunk.Key),
+		attribute.Int64("offset", offset), attribute.Int64("length", length), attribute.Bool("refresh", refresh)))
+	url, err := b.chunkURL(chunk, refresh)
+	if err != nil {
+		err = fmt.Errorf("获取分块%d下载链接失败: %v", chunk.Index, err)
+		endSpan(span, err)
+		return nil, err
+	}
+	rc, err := chunkstore.RangeGet(ctx, url, offset, length)
+	if err != nil && b.d.secondaryClient != nil {
+		if src, serr := b.d.openSecondaryChunk(ctx, chunk, offset, length); serr == nil {
+			log.Warnf("读取分块%d失败，改用备用数据库: %v", chunk.Index, err)
+			rc, err = src, nil
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("读取分块%d失败: %v", chunk.Index, err)
+		endSpan(span, err)
+		return nil, err
+	}
+	return traceRead(countDownload(rc), span), nil
+}
+
+// chunkURL 获取分块的下载链接，refresh为true时忽略缓存（如链接已过期）
+func (b *chunkBackend) chunkURL(chunk chunkstore.Chunk, refresh bool) (string, error) {
+	if url, ok := b.d.chunkURLs.Get(chunk.Key); ok && !refresh {
+		countCache("chunk_url", true)
+		return url, nil
+	}
+	countCache("chunk_url", false)
+	url, _, err := b.d.pageFileURL(chunk.Key, 0)
+	if err != nil {
+		return "", err
+	}
+	b.d.chunkURLs.Set(chunk.Key, url, cache.WithEx[string](chunkURLTTL))
+	return url, nil
+}
+
+// cdcAvgSize 按内容切分时分块的平均字节数
+func (d *Notion) cdcAvgSize() int64 {
+	return min(max(int64(d.CDCAvgSize), 1)*1024*1024, MaxChunkSize/4)
+}
+
+// newSizer 按内容切分时分块在平均大小的1/4到4倍之间，
+// 否则为固定大小MaxChunkSize，开启自适应时随上传速度调整
+func (d *Notion) newSizer() *chunkstore.Sizer {
+	if d.ChunkMode == chunkModeCDC {
+		avg := d.cdcAvgSize()
+		return chunkstore.NewCDCSizer(avg/4, avg, avg*4)
+	}
+	return chunkstore.NewSizer(MaxChunkSize, d.MinChunkSize*1024*1024, d.AdaptiveChunk)
+}
+
+// chunkCount 按固定大小切分时size字节的分块数，按内容切分或自适应大小时无法预知，返回0
+func (d *Notion) chunkCount(size int64) int {
+	if d.ChunkMode == chunkModeCDC || d.AdaptiveChunk {
+		return 0
+	}
+	return int((size + MaxChunkSize - 1) / MaxChunkSize)
+}
+
+// toChunks 将分块记录转换为chunkstore的分块，chunks需按chunk_index排序
+func toChunks(chunks []FileChunk) []chunkstore.Chunk {
+	res := make([]chunkstore.Chunk, 0, len(chunks))
+	for _, chunk := range chunks {
+		res = append(res, chunkstore.Chunk{
+			Index: chunk.ChunkIndex,
+			Start: chunk.StartOffset,
+			End:   chunk.EndOffset,
+			Key:   chunk.BlobKey,
+			Hash:  chunk.SHA1,
+			Nonce: chunk.Nonce,
+			KeyID: chunk.KeyID,
+		})
+	}
+	return res
+}
+
+// initChunkNames 解析分块标题模板
+// 分块页面只能放在数据库中，附件保存在数据库的文件属性里，普通页面的子页面没有该属性
+func (d *Notion) initChunkNames() error {
+	text := d.ChunkNameTemplate
+	if strings.TrimSpace(text) == "" {
+		text = defaultChunkName
+	}
+	tmpl, err := template.New("chunk").Parse(text)
+	if err != nil {
+		return err
+	}
+	d.chunkNameTmpl = tmpl
+	return nil
+}
+
+// chunkPageTitle 分块页面的标题
+func (d *Notion) chunkPageTitle(fileName string, index int) string {
+	ext := path.Ext(fileName)
+	vars := ChunkNameVars{
+		Name:  fileName,
+		Base:  strings.TrimSuffix(fileName, ext),
+		Ext:   ext,
+		Index: index,
+	}
+	var sb strings.Builder
+	if err := d.chunkNameTmpl.Execute(&sb, vars); err != nil || sb.Len() == 0 {
+		log.Warnf("生成分块[%s]的标题失败: %v", fileName, err)
+		return d.pageTitle(fmt.Sprintf("%s.chunk%d", fileName, index))
+	}
+	return d.pageTitle(sb.String())
+}
+
+// pageTitle 新建页面的标题，开启文件名混淆时为随机ID，真实名称只保存在数据库中
+func (d *Notion) pageTitle(name string) string {
+	if !d.ObfuscateNames {
+		return sanitizeName(name)
+	}
+	return random.String(32)
+}
@@ -0,0 +1,146 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+)
+
+// TestChunkURLCached 浏览压缩包等随机读取不为每个Range请求查询分块页面
+func TestChunkURLCached(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	data := testData(3 * 1024 * 1024)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.zip", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks int64
+	if err := d.db.Model(&FileChunk{}).Where("file_id = ?", obj.GetID()).Count(&chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := fake.count(http.MethodGet, "/v1/pages/")
+	size := int64(len(data))
+	for i := int64(0); i < 20; i++ {
+		start := i * (size / 20)
+		if got := readRange(t, link, start, 100); !bytes.Equal(got, data[start:start+100]) {
+			t.Fatalf("range %d+100 differs", start)
+		}
+	}
+	if n := int64(fake.count(http.MethodGet, "/v1/pages/") - before); n > chunks {
+		t.Fatalf("expect at most a lookup per chunk, got %d lookups for %d chunks", n, chunks)
+	}
+
+	// 链接失效时重新获取分块的下载链接
+	before = fake.count(http.MethodGet, "/v1/pages/")
+	fake.failNext(http.MethodGet, "/s3/", http.StatusForbidden, 1)
+	if got := readRange(t, link, 0, 100); !bytes.Equal(got, data[:100]) {
+		t.Fatal("content differs after refreshing the url")
+	}
+	if n := fake.count(http.MethodGet, "/v1/pages/") - before; n != 1 {
+		t.Fatalf("expect the url refreshed once, got %d lookups", n)
+	}
+}
+
+func TestChunkReadCanceled(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.zip", testData(3*1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	rc, err := link.RangeReadCloser.RangeRead(ctx, http_range.Range{Start: 0, Length: 100})
+	if err == nil {
+		defer rc.Close()
+		if _, err = rc.Read(make([]byte, 100)); err == nil {
+			t.Fatal("expect a canceled read to fail")
+		}
+	}
+}
+
+func TestCDCAvgSize(t *testing.T) {
+	for avg, want := range map[int]int64{
+		0:       1024 * 1024,
+		64:      64 * 1024 * 1024,
+		1 << 20: MaxChunkSize / 4,
+	} {
+		d := &Notion{Addition: Addition{CDCAvgSize: avg}}
+		if got := d.cdcAvgSize(); got != want {
+			t.Errorf("%d: expect %d, got %d", avg, want, got)
+		}
+	}
+}
+
+// isChunked 文件是否分块上传
+func isChunked(t *testing.T, d *Notion, id string) bool {
+	var f File
+	if err := d.db.First(&f, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return f.IsChunked
+}
+
+// TestChunkModeCDC 按内容切分时超过平均大小的文件即分块上传，分块在平均大小的1/4到4倍之间
+func TestChunkModeCDC(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	ctx := context.Background()
+	data := testData(3 * 1024 * 1024)
+	fixed, err := d.Put(ctx, rootDir(d), newTestStream("fixed.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isChunked(t, d, fixed.GetID()) {
+		t.Fatal("expect a file under the threshold uploaded as a single page in the fixed mode")
+	}
+
+	d.ChunkMode = chunkModeCDC
+	d.CDCAvgSize = 1
+	small, err := d.Put(ctx, rootDir(d), newTestStream("small.bin", testData(1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if isChunked(t, d, small.GetID()) {
+		t.Fatal("expect a file of the average size uploaded as a single page")
+	}
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := callOther(t, d, "list_chunks", obj).([]ChunkInfo)
+	if !isChunked(t, d, obj.GetID()) || len(chunks) < 2 {
+		t.Fatalf("expect big.bin chunked, got %d chunks", len(chunks))
+	}
+	for _, c := range chunks[:len(chunks)-1] {
+		if c.Size < 256*1024 || c.Size > 4*1024*1024 {
+			t.Fatalf("chunk %d has %d bytes", c.Index, c.Size)
+		}
+	}
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+}
@@ -0,0 +1,108 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+const fakeChunkDatabaseID = "db-chunks"
+
+// pageDatabase 返回页面所在的数据库
+func (f *fakeNotion) pageDatabase(id string) string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if page, ok := f.pages[id]; ok {
+		return page.database
+	}
+	return ""
+}
+
+func TestChunkDatabase(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkDatabaseID = fakeChunkDatabaseID
+		d.ChunkNameTemplate = "{{.Base}}-part{{.Index}}{{.Ext}}"
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	small, err := d.Put(ctx, rootDir(d), newTestStream("small.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if db := fake.pageDatabase(blobKey(t, d, small.GetID())); db != fakeDatabaseID {
+		t.Fatalf("expect the page of a small file in the main database, got %q", db)
+	}
+
+	data := testData(3 * 1024 * 1024)
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := func() []FileChunk {
+		var chunks []FileChunk
+		if err := d.db.Where("file_id = ? AND deleted = ?", obj.GetID(), false).Order("chunk_index").Find(&chunks).Error; err != nil {
+			t.Fatal(err)
+		}
+		if len(chunks) < 2 {
+			t.Fatalf("expect several chunks, got %d", len(chunks))
+		}
+		return chunks
+	}
+	// 分块页面在分块数据库中，标题按模板生成
+	for _, c := range chunks() {
+		if db := fake.pageDatabase(c.BlobKey); db != fakeChunkDatabaseID {
+			t.Fatalf("chunk %d: expect the chunk database, got %q", c.ChunkIndex, db)
+		}
+		if titles := fake.pageTitles(c.BlobKey); len(titles) != 1 || titles[0] != fmt.Sprintf("big-part%d.bin", c.ChunkIndex) {
+			t.Fatalf("chunk %d: expect the templated title, got %v", c.ChunkIndex, titles)
+		}
+	}
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+
+	// 重命名后分块页面的标题同步更新
+	if _, err := d.Rename(ctx, obj, "video.mp4"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks() {
+		if titles := fake.pageTitles(c.BlobKey); len(titles) != 1 || titles[0] != fmt.Sprintf("video-part%d.mp4", c.ChunkIndex) {
+			t.Fatalf("chunk %d: expect the renamed title, got %v", c.ChunkIndex, titles)
+		}
+	}
+}
+
+func TestChunkNameTemplate(t *testing.T) {
+	d := &Notion{}
+	for _, c := range []struct {
+		tmpl, want string
+	}{
+		{"", "a.tar.gz.chunk2"},
+		{"{{.Name}}.chunk{{.Index}}", "a.tar.gz.chunk2"},
+		{"{{.Base}}_{{.Index}}{{.Ext}}", "a.tar_2.gz"},
+		// 模板执行失败或结果为空时使用默认标题
+		{"{{.Missing}}", "a.tar.gz.chunk2"},
+		{"{{if false}}x{{end}}", "a.tar.gz.chunk2"},
+	} {
+		d.ChunkNameTemplate = c.tmpl
+		if err := d.initChunkNames(); err != nil {
+			t.Fatalf("%q: %v", c.tmpl, err)
+		}
+		if got := d.chunkPageTitle("a.tar.gz", 2); got != c.want {
+			t.Errorf("%q: expect %q, got %q", c.tmpl, c.want, got)
+		}
+	}
+	d.ChunkNameTemplate = "{{.Name"
+	if err := d.initChunkNames(); err == nil {
+		t.Fatal("expect an invalid template rejected")
+	}
+}
@@ -0,0 +1,58 @@
+package notion
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// dedupChunkBackend 按内容寻址的分块存储，上传分块前计算SHA1，已有相同SHA1和大小的分块时
+// 直接引用其页面，相同的分块只上传一次；分块记录即共享表，页面的引用数为引用它的分块记录数，
+// 删除文件后由pageInUse确认页面不再被引用才会归档。加密的分块每次的nonce不同，不参与去重
+type dedupChunkBackend struct {
+	*chunkBackend
+}
+
+func (b *dedupChunkBackend) Find(ctx context.Context, r io.Reader, size int64) (string, string, error) {
+	h := sha1.New()
+	if _, err := io.Copy(h, r); err != nil {
+		return "", "", fmt.Errorf("计算分块哈希失败: %w", err)
+	}
+	hash := hex.EncodeToString(h.Sum(nil))
+	var c FileChunk
+	err := b.d.db.Where("sha1 = ? AND end_offset - start_offset = ? AND nonce = '' AND notion_page_id <> '' AND deleted = ?", hash, size, false).
+		First(&c).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		countCache("chunk_dedup", false)
+		return "", hash, nil
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("查询相同的分块失败: %w", err)
+	}
+	countCache("chunk_dedup", true)
+	log.Debugf("文件[%s]的分块与页面[%s]相同，不再上传", b.fileName, c.BlobKey)
+	return c.BlobKey, hash, nil
+}
+
+// chunkRefs 页面被未删除的分块记录引用的次数
+func (d *Notion) chunkRefs(pageIDs []string) (map[string]int64, error) {
+	var rows []struct {
+		PageID string
+		Refs   int64
+	}
+	if err := d.db.Model(&FileChunk{}).Select("notion_page_id AS page_id, COUNT(*) AS refs").
+		Where("notion_page_id IN ? AND deleted = ?", pageIDs, false).Group("notion_page_id").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("查询分块的引用数失败: %w", err)
+	}
+	refs := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		refs[row.PageID] = row.Refs
+	}
+	return refs, nil
+}
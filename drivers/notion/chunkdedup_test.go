@@ -0,0 +1,97 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestChunkDedup(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkDedup = true
+		d.ArchiveOnDelete = true
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	data := testData(3 * 1024 * 1024)
+	a, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := fake.livePages()
+	// 修改中间的内容，按内容切分时其余的分块不变
+	changed := bytes.Clone(data)
+	copy(changed[len(changed)/2:], "changed")
+	b, err := d.Put(ctx, rootDir(d), newTestStream("b.bin", changed), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := callOther(t, d, "list_chunks", b).([]ChunkInfo)
+	added := fake.livePages() - pages
+	if added == 0 || added >= len(chunks) {
+		t.Fatalf("expect only the changed chunks uploaded, got %d pages for %d chunks", added, len(chunks))
+	}
+	shared := 0
+	for _, c := range chunks {
+		if c.Refs > 1 {
+			shared++
+		}
+	}
+	if shared != len(chunks)-added {
+		t.Fatalf("expect %d shared chunks, got %d", len(chunks)-added, shared)
+	}
+	link, err := d.Link(ctx, b, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(changed))); !bytes.Equal(got, changed) {
+		t.Fatal("content mismatch")
+	}
+
+	// 删除文件后只归档不再被引用的页面
+	if err := d.Remove(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if n := waitLivePages(fake, len(chunks)); n != len(chunks) {
+		t.Fatalf("expect the pages of b.bin kept, got %d live pages for %d chunks", n, len(chunks))
+	}
+	if link, err = d.Link(ctx, b, model.LinkArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(changed))); !bytes.Equal(got, changed) {
+		t.Fatal("content mismatch after removing a.bin")
+	}
+	if err := d.Remove(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if n := waitLivePages(fake, 0); n != 0 {
+		t.Fatalf("expect all pages archived, got %d", n)
+	}
+}
+
+// TestChunkDedupEncrypted 加密的分块每次的nonce不同，不去重
+func TestChunkDedupEncrypted(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkDedup = true
+		d.EncryptionKey = "secret"
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	data := testData(3 * 1024 * 1024)
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", data), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	pages := fake.livePages()
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("b.bin", data), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.livePages(); n != 2*pages {
+		t.Fatalf("expect the encrypted chunks uploaded again, got %d pages after %d", n, pages)
+	}
+}
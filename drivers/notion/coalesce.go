@@ -0,0 +1,212 @@
+package notion
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+)
+
+const (
+	// coalesceMaxLength 长度不超过这么多字节的Range请求才从保留的读取器读取，更大或到文件结尾的请求单独读取
+	coalesceMaxLength = 2 * 1024 * 1024
+	// coalesceWindow 小范围请求打开的读取范围，之后相邻的请求从同一读取器继续读取
+	coalesceWindow = 16 * 1024 * 1024
+	// coalesceMaxGap 请求的起点在读取器位置之后不超过这么多字节时，丢弃中间的内容继续读取
+	coalesceMaxGap = 512 * 1024
+	// coalesceTail 保留最近读取的这么多字节，起点在读取器位置之前的重叠请求从中读取
+	coalesceTail = 64 * 1024
+)
+
+// openReader 同一客户端读取一个文件后保留的读取器
+type openReader struct {
+	rc     io.ReadCloser
+	cancel context.CancelFunc
+	// pos 下一个读取的字节在文件中的位置
+	pos int64
+	// end 读取器的结束位置
+	end int64
+	// tail 以pos结尾的最近读取的内容
+	tail  []byte
+	timer *time.Timer
+}
+
+// Write 记录从读取器读出的内容，移动位置并保留最后coalesceTail字节
+func (r *openReader) Write(p []byte) (int, error) {
+	r.pos += int64(len(p))
+	if len(p) >= coalesceTail {
+		r.tail = append(r.tail[:0], p[len(p)-coalesceTail:]...)
+		return len(p), nil
+	}
+	if keep := coalesceTail - len(p); len(r.tail) > keep {
+		r.tail = append(r.tail[:0], r.tail[len(r.tail)-keep:]...)
+	}
+	r.tail = append(r.tail, p...)
+	return len(p), nil
+}
+
+// covers 读取器能否提供[start, end)的内容
+func (r *openReader) covers(start, end int64) bool {
+	return start >= r.pos-int64(len(r.tail)) && start <= r.pos+coalesceMaxGap && end <= r.end
+}
+
+func (r *openReader) close() {
+	_ = r.rc.Close()
+	r.cancel()
+}
+
+// readerPool 按文件和客户端保留空闲的读取器，空闲超过idle后关闭
+type readerPool struct {
+	mu      sync.Mutex
+	idle    time.Duration
+	readers map[string]*openReader
+}
+
+func newReaderPool(idle time.Duration) *readerPool {
+	return &readerPool{idle: idle, readers: make(map[string]*openReader)}
+}
+
+// take 取出key的读取器，其他请求不会同时使用它
+func (p *readerPool) take(key string) *openReader {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	r, ok := p.readers[key]
+	if !ok {
+		return nil
+	}
+	delete(p.readers, key)
+	r.timer.Stop()
+	return r
+}
+
+// put 放回读取器，同一key已有读取器时关闭旧的
+func (p *readerPool) put(key string, r *openReader) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if old, ok := p.readers[key]; ok {
+		old.timer.Stop()
+		old.close()
+	}
+	p.readers[key] = r
+	r.timer = time.AfterFunc(p.idle, func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		if p.readers[key] == r {
+			delete(p.readers, key)
+			r.close()
+		}
+	})
+}
+
+func (p *readerPool) closeAll() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for key, r := range p.readers {
+		r.timer.Stop()
+		r.close()
+		delete(p.readers, key)
+	}
+}
+
+// coalescingRangeReadCloser 同一客户端对文件相邻或重叠的小范围请求从保留的读取器继续读取，
+// 不再每次打开新的下载连接
+type coalescingRangeReadCloser struct {
+	model.RangeReadCloserIF
+	pool *readerPool
+	key  string
+	size int64
+}
+
+// coalesceRanges 未开启reader_keep_alive时原样返回rrc
+func (d *Notion) coalesceRanges(rrc model.RangeReadCloserIF, fileID int, size int64, ip string) model.RangeReadCloserIF {
+	if d.readers == nil {
+		return rrc
+	}
+	return &coalescingRangeReadCloser{
+		RangeReadCloserIF: rrc,
+		pool:              d.readers,
+		key:               strconv.Itoa(fileID) + "|" + ip,
+		size:              size,
+	}
+}
+
+func (c *coalescingRangeReadCloser) RangeRead(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+	if httpRange.Length < 0 || httpRange.Length > coalesceMaxLength || httpRange.Start >= c.size {
+		return c.RangeReadCloserIF.RangeRead(ctx, httpRange)
+	}
+	end := min(httpRange.Start+httpRange.Length, c.size)
+	r := c.pool.take(c.key)
+	if r != nil && !r.covers(httpRange.Start, end) {
+		r.close()
+		r = nil
+	}
+	if r == nil {
+		// 读取器在请求结束后继续使用，不随请求取消
+		readCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		windowEnd := min(max(end, httpRange.Start+coalesceWindow), c.size)
+		rc, err := c.RangeReadCloserIF.RangeRead(readCtx, http_range.Range{Start: httpRange.Start, Length: windowEnd - httpRange.Start})
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+		r = &openReader{rc: rc, cancel: cancel, pos: httpRange.Start, end: windowEnd}
+	}
+	return &coalescedReader{ctx: ctx, c: c, r: r, off: httpRange.Start, end: end}, nil
+}
+
+// coalescedReader 从保留的读取器读取一个请求的范围，读完整个范围后放回读取器
+type coalescedReader struct {
+	ctx    context.Context
+	c      *coalescingRangeReadCloser
+	r      *openReader
+	off    int64
+	end    int64
+	failed bool
+}
+
+func (cr *coalescedReader) Read(p []byte) (int, error) {
+	if cr.off >= cr.end {
+		return 0, io.EOF
+	}
+	if err := cr.ctx.Err(); err != nil {
+		cr.failed = true
+		return 0, err
+	}
+	p = p[:min(int64(len(p)), cr.end-cr.off)]
+	r := cr.r
+	if cr.off < r.pos {
+		// 重叠的部分从最近读取的内容中复制
+		n := copy(p, r.tail[int64(len(r.tail))-(r.pos-cr.off):])
+		cr.off += int64(n)
+		return n, nil
+	}
+	if gap := cr.off - r.pos; gap > 0 {
+		if _, err := io.CopyN(r, r.rc, gap); err != nil {
+			cr.failed = true
+			return 0, err
+		}
+	}
+	n, err := r.rc.Read(p)
+	_, _ = r.Write(p[:n])
+	cr.off += int64(n)
+	if err == io.EOF && cr.off < cr.end {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil && err != io.EOF {
+		cr.failed = true
+	}
+	return n, err
+}
+
+func (cr *coalescedReader) Close() error {
+	if cr.failed || cr.off < cr.end || cr.r.pos >= cr.r.end {
+		cr.r.close()
+		return nil
+	}
+	cr.c.pool.put(cr.c.key, cr.r)
+	return nil
+}
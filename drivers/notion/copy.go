@@ -0,0 +1,163 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// copyFile 复制文件到目标目录
+// link模式下新文件与源文件共享Notion页面，duplicate模式下在Notion中上传一份独立的副本，
+// 删除源文件的页面不会影响副本
+func (d *Notion) copyFile(ctx context.Context, src *File, dstDirID int) (*File, error) {
+	if d.CopyMode == "duplicate" {
+		return d.duplicateFile(ctx, src, dstDirID)
+	}
+	return d.linkFile(src, src.Name, dstDirID)
+}
+
+// duplicateFile 下载源文件的附件并上传到新的Notion页面
+func (d *Notion) duplicateFile(ctx context.Context, src *File, dstDirID int) (newFile *File, err error) {
+	newFile = &File{
+		Name:        src.Name,
+		Size:        src.Size,
+		SHA1:        src.SHA1,
+		MD5:         src.MD5,
+		SHA256:      src.SHA256,
+		DirectoryID: dstDirID,
+		IsChunked:   src.IsChunked,
+		ChunkSize:   src.ChunkSize,
+	}
+	if src.IsInline() {
+		newFile.Inline = src.Inline
+		if err := d.db.Create(newFile).Error; err != nil {
+			return nil, fmt.Errorf("保存文件信息失败: %w", err)
+		}
+		return newFile, nil
+	}
+	if !src.IsChunked {
+		pageID, err := d.duplicatePage(ctx, d.notionClient, src.BlobKey, src.BlobIndex, src.Name, d.pageTitle(src.Name), src.Size, src.SHA1)
+		if err != nil {
+			return nil, err
+		}
+		newFile.BlobKey = pageID
+		if err := d.db.Create(newFile).Error; err != nil {
+			return nil, fmt.Errorf("保存文件信息失败: %w", err)
+		}
+		return newFile, nil
+	}
+
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ? AND deleted = ?", src.ID, false).Order("chunk_index").Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("获取文件分块信息失败: %w", err)
+	}
+	if err := d.db.Create(newFile).Error; err != nil {
+		return nil, fmt.Errorf("创建文件记录失败: %w", err)
+	}
+	defer func() {
+		if err != nil {
+			d.db.Model(&File{}).Where("id = ?", newFile.ID).Update("deleted", true)
+		}
+	}()
+	for i := range chunks {
+		chunk := &chunks[i]
+		chunkName := d.chunkPageTitle(src.Name, chunk.ChunkIndex)
+		// 加密的分块原样复制，沿用原分块的Nonce
+		size := chunk.ChunkSize
+		if chunk.Nonce != "" {
+			size = chunkstore.EncryptedSize(size)
+		}
+		pageID, err := d.duplicatePage(ctx, d.chunkClient, chunk.BlobKey, 0, src.Name, chunkName, size, chunk.SHA1)
+		if err != nil {
+			return nil, fmt.Errorf("复制分块%d失败: %v", chunk.ChunkIndex, err)
+		}
+		chunk.ID = 0
+		chunk.FileID = newFile.ID
+		chunk.BlobKey = pageID
+	}
+	if err := d.db.Create(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("保存分块记录失败: %w", err)
+	}
+	return newFile, nil
+}
+
+// duplicatePage 在client的数据库中创建新页面，并将源页面的第srcIndex个附件流式上传到新页面
+func (d *Notion) duplicatePage(ctx context.Context, client *NotionService, srcPageID string, srcIndex int, fileName, title string, size int64, sha1 string) (string, error) {
+	pageID, err := client.CreateDatabasePage(title)
+	if err != nil {
+		return "", fmt.Errorf("创建Notion页面失败: %w", err)
+	}
+	rc, err := d.openPageAttachment(ctx, srcPageID, srcIndex, 0, 0)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+	stream := &ChunkFileStream{
+		Reader:   rc,
+		name:     title,
+		size:     size,
+		mimetype: d.contentType(fileName, nil),
+		hash:     utils.NewHashInfo(utils.SHA1, sha1),
+	}
+	if _, err := client.UploadAndUpdateFilePut(ctx, stream, pageID, func(float64) {}); err != nil {
+		return "", fmt.Errorf("上传文件到Notion失败: %w", err)
+	}
+	return pageID, nil
+}
+
+// sharesBackend 判断src与当前存储是否使用同一个元数据数据库和Notion工作区，且分块加密的密钥相同，
+// 此时src的页面可以被当前存储直接引用
+func (d *Notion) sharesBackend(src *Notion) bool {
+	if d.DBHost != src.DBHost || d.DBPort != src.DBPort || d.DBName != src.DBName || d.NotionSpaceID != src.NotionSpaceID {
+		return false
+	}
+	if (d.chunkKey == nil) != (src.chunkKey == nil) {
+		return false
+	}
+	return d.chunkKey == nil || chunkstore.KeyID(d.chunkKey) == chunkstore.KeyID(src.chunkKey)
+}
+
+// CopyFrom 从共用同一个数据库和工作区的另一个Notion存储复制文件，只新建引用原有页面的文件记录，不下载和重新上传；
+// 目录、duplicate模式以及目标目录中已有同名文件时返回errs.NotSupport，由alist逐个文件或下载后上传复制
+func (d *Notion) CopyFrom(ctx context.Context, src driver.Driver, srcObj, dstDir model.Obj) (obj model.Obj, err error) {
+	from, ok := src.(*Notion)
+	if !ok || !d.sharesBackend(from) || srcObj.IsDir() || d.CopyMode == "duplicate" {
+		return nil, errs.NotSupport
+	}
+	// 审计记录源文件在alist中的完整路径
+	var oldPath string
+	if d.AuditLog {
+		oldPath = path.Join(from.GetStorage().MountPath, from.tree.ObjPath(srcObj))
+	}
+	defer func() { d.audit(ctx, AuditCopy, oldPath, obj, err) }()
+	if err = d.checkWrite(true); err != nil {
+		return nil, err
+	}
+	var srcFile File
+	if err := d.db.Where("id = ? AND deleted = ?", srcObj.GetID(), false).First(&srcFile).Error; err != nil {
+		return nil, d.lang().dbError(msgGetSrcFile, err)
+	}
+	srcFile.Name = srcObj.GetName()
+	dstDirID, _ := strconv.Atoi(dstDir.GetID())
+	existing, err := d.tree.FindFile(dstDirID, d.tree.NormName(srcFile.Name))
+	if err != nil {
+		return nil, err
+	}
+	if existing != nil {
+		return nil, errs.NotSupport
+	}
+	newFile, err := d.linkFile(&srcFile, d.tree.NormName(srcFile.Name), dstDirID)
+	if err != nil {
+		return nil, err
+	}
+	d.mirrorFile(newFile)
+	return dbfs.FileToObj(newFile), nil
+}
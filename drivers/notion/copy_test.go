@@ -0,0 +1,225 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// copyAndRemove 上传文件，复制到子目录后删除源文件，返回副本和副本的内容
+func copyAndRemove(t *testing.T, d *Notion, data []byte) (model.Obj, []byte) {
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := d.Copy(ctx, obj, dir)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if err := d.Remove(ctx, obj); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	link, err := d.Link(ctx, copied, model.LinkArgs{})
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	return copied, readURL(t, link)
+}
+
+func TestCopyLinkSharesPage(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.ArchiveOnDelete = true })
+	data := testData(1000)
+	_, got := copyAndRemove(t, d, data)
+	if !bytes.Equal(got, data) {
+		t.Fatal("copy has different content")
+	}
+	// 副本仍引用页面，删除源文件不会归档
+	if n := fake.livePages(); n != 1 {
+		t.Fatalf("expect the shared page kept, got %d live pages", n)
+	}
+	if n := fake.count("POST", "/api/v3/getUploadFileUrl"); n != 1 {
+		t.Fatalf("expect a single upload, got %d", n)
+	}
+}
+
+func TestCopyDuplicateUploadsNewPage(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ArchiveOnDelete = true
+		d.CopyMode = "duplicate"
+	})
+	data := testData(1000)
+	copied, got := copyAndRemove(t, d, data)
+	if !bytes.Equal(got, data) {
+		t.Fatal("copy has different content")
+	}
+	if n := fake.count("POST", "/api/v3/getUploadFileUrl"); n != 2 {
+		t.Fatalf("expect the copy uploaded again, got %d uploads", n)
+	}
+	// 源文件的页面被归档，只剩副本的页面
+	if n := fake.livePages(); n != 1 {
+		t.Fatalf("expect only the page of the copy, got %d live pages", n)
+	}
+	if copied.GetSize() != int64(len(data)) {
+		t.Fatalf("expect size %d, got %d", len(data), copied.GetSize())
+	}
+}
+
+func TestCopyDuplicateChunked(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ArchiveOnDelete = true
+		d.CopyMode = "duplicate"
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	data := testData(3 * 1024 * 1024)
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := fake.livePages()
+	copied, err := d.Copy(ctx, obj, dir)
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if n := fake.livePages(); n != 2*pages {
+		t.Fatalf("expect a new page per chunk, got %d pages for %d chunks", n-pages, pages)
+	}
+	if err := d.Remove(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(ctx, copied, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("copy has different content")
+	}
+}
+
+// TestCopyFrom 共用数据库和工作区的存储之间复制时只新建文件记录，引用原有的页面
+func TestCopyFrom(t *testing.T) {
+	fake := newFakeNotion(t)
+	a := newTestNotion(t, fake, func(d *Notion) {
+		d.RootPath = "a"
+		d.ArchiveOnDelete = true
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	b := newTestNotion(t, fake, func(d *Notion) {
+		d.RootPath = "b"
+		d.ArchiveOnDelete = true
+	})
+	ctx := context.Background()
+	small, big := testData(1000), testData(3*1024*1024)
+	for name, data := range map[string][]byte{"small.bin": small, "big.bin": big} {
+		obj, err := a.Put(ctx, rootDir(a), newTestStream(name, data), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		uploads, pages := fake.count("POST", "/api/v3/getUploadFileUrl"), fake.livePages()
+		copied, err := b.CopyFrom(ctx, a, obj, rootDir(b))
+		if err != nil {
+			t.Fatalf("copy %s: %v", name, err)
+		}
+		if fake.count("POST", "/api/v3/getUploadFileUrl") != uploads || fake.livePages() != pages {
+			t.Fatalf("expect %s copied without uploading", name)
+		}
+		// 删除源文件后副本仍引用页面
+		if err := a.Remove(ctx, obj); err != nil {
+			t.Fatal(err)
+		}
+		if n := waitLivePages(fake, pages); n != pages {
+			t.Fatalf("expect the pages of %s kept, got %d live pages after %d", name, n, pages)
+		}
+		link, err := b.Link(ctx, copied, model.LinkArgs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []byte
+		if link.RangeReadCloser != nil {
+			got = readRange(t, link, 0, int64(len(data)))
+		} else {
+			got = readURL(t, link)
+		}
+		if !bytes.Equal(got, data) {
+			t.Fatalf("copy of %s has different content", name)
+		}
+	}
+	names := listNames(t, b, rootDir(b))
+	sort.Strings(names)
+	if strings.Join(names, ",") != "big.bin,small.bin" {
+		t.Fatalf("expect the copies in b, got %v", names)
+	}
+}
+
+// TestCopyFromRefused 不能直接引用页面时返回errs.NotSupport，由alist下载后上传
+func TestCopyFromRefused(t *testing.T) {
+	fake := newFakeNotion(t)
+	ctx := context.Background()
+	src := newTestNotion(t, fake, func(d *Notion) {
+		d.RootPath = "src"
+		d.EncryptionKey = "secret"
+	})
+	obj, err := src.Put(ctx, rootDir(src), newTestStream("a.bin", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := src.MakeDir(ctx, rootDir(src), "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	same := func(d *Notion) {
+		d.RootPath = "dst"
+		d.EncryptionKey = "secret"
+	}
+	dst := newTestNotion(t, fake, same)
+	for name, c := range map[string]struct {
+		dst *Notion
+		obj model.Obj
+	}{
+		"another space": {newTestNotion(t, fake, func(d *Notion) {
+			same(d)
+			d.NotionSpaceID = "space-other"
+		}), obj},
+		"another key": {newTestNotion(t, fake, func(d *Notion) {
+			same(d)
+			d.EncryptionKey = "other"
+		}), obj},
+		"no key": {newTestNotion(t, fake, func(d *Notion) { d.RootPath = "dst" }), obj},
+		"duplicate": {newTestNotion(t, fake, func(d *Notion) {
+			same(d)
+			d.CopyMode = "duplicate"
+		}), obj},
+		"dir": {dst, dir},
+	} {
+		if _, err := c.dst.CopyFrom(ctx, src, c.obj, rootDir(c.dst)); !errors.Is(err, errs.NotSupport) {
+			t.Errorf("%s: expect errs.NotSupport, got %v", name, err)
+		}
+	}
+	// 目标目录中已有同名文件
+	if _, err := dst.CopyFrom(ctx, src, obj, rootDir(dst)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := dst.CopyFrom(ctx, src, obj, rootDir(dst)); !errors.Is(err, errs.NotSupport) {
+		t.Fatalf("expect copying over an existing file refused, got %v", err)
+	}
+}
@@ -0,0 +1,134 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"gorm.io/gorm"
+)
+
+// putDuplicate 上传的文件带有可信的SHA1（如从其他存储复制）且已存储相同内容的文件时，创建共享其页面的文件，
+// 没有可共享的文件时返回nil。客户端声明的哈希不可信，否则只知道哈希即可读取他人的文件
+func (d *Notion) putDuplicate(ctx context.Context, fileName string, dirID int, file model.FileStreamer, up driver.UpdateProgress) (model.Obj, error) {
+	sha1 := file.GetHash().GetHash(utils.SHA1)
+	if !d.Dedup || sha1 == "" || file.GetSize() <= 0 {
+		return nil, nil
+	}
+	if t, ok := file.(model.HashTrusted); !ok || !t.HashTrusted() {
+		return nil, nil
+	}
+	root, err := d.GetRoot(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rootID, _ := strconv.Atoi(root.GetID())
+	dup, err := d.findDuplicate(rootID, sha1, file.GetSize())
+	if err != nil || dup == nil {
+		return nil, err
+	}
+	f, err := d.linkFile(dup, fileName, dirID)
+	if err != nil {
+		return nil, err
+	}
+	up(100)
+	return dbfs.FileToObj(f), nil
+}
+
+// findDuplicate 按SHA1和大小查找rootID目录（存储或用户的根目录）下已存储的文件，
+// 不查找同一数据库下其他存储或其他用户的文件；没有可复用的文件时返回nil
+func (d *Notion) findDuplicate(rootID int, sha1 string, size int64) (*File, error) {
+	dirIDs, err := d.tree.SubtreeDirIDs(d.db, rootID)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	err = d.db.Where("sha1 = ? AND size = ? AND deleted = ? AND directory_id IN ?",
+		strings.ToLower(sha1), size, false, dirIDs).Order("id").Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("查找相同哈希的文件失败: %w", err)
+	}
+	for i := range files {
+		ok, err := d.canShare(&files[i])
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			return &files[i], nil
+		}
+	}
+	return nil, nil
+}
+
+// canShare 判断新上传的文件能否共享该文件的页面：配置了加密密钥时要求全部分块已用当前密钥加密，
+// 否则要求文件未加密，使共享的结果与重新上传一致
+func (d *Notion) canShare(f *File) (bool, error) {
+	if !f.IsChunked {
+		return d.chunkKey == nil && f.BlobKey != "", nil
+	}
+	chunks, err := d.fileChunks(f.ID)
+	if err != nil {
+		return false, err
+	}
+	if len(chunks) == 0 {
+		return false, nil
+	}
+	for _, chunk := range chunks {
+		if d.chunkKey == nil && chunk.Nonce != "" {
+			return false, nil
+		}
+		if d.chunkKey != nil && (chunk.Nonce == "" || chunk.KeyID != chunkstore.KeyID(d.chunkKey)) {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// linkFile 创建与源文件共享Notion页面（包括缩略图页面）的文件记录，页面在不再被任何文件引用前不会被归档
+func (d *Notion) linkFile(src *File, name string, dstDirID int) (*File, error) {
+	newFile := &File{
+		Name:        name,
+		Size:        src.Size,
+		SHA1:        src.SHA1,
+		MD5:         src.MD5,
+		SHA256:      src.SHA256,
+		BlobKey:     src.BlobKey,
+		Packed:      src.Packed,
+		BlobIndex:   src.BlobIndex,
+		Inline:      src.Inline,
+		ThumbKey:    src.ThumbKey,
+		DirectoryID: dstDirID,
+		IsChunked:   src.IsChunked,
+		ChunkSize:   src.ChunkSize,
+	}
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(newFile).Error; err != nil {
+			return fmt.Errorf("复制文件记录失败: %w", err)
+		}
+		if !src.IsChunked {
+			return nil
+		}
+		var chunks []FileChunk
+		if err := tx.Where("file_id = ? AND deleted = ?", src.ID, false).Order("chunk_index").Find(&chunks).Error; err != nil {
+			return fmt.Errorf("获取文件分块信息失败: %w", err)
+		}
+		for i := range chunks {
+			chunks[i].ID = 0
+			chunks[i].FileID = newFile.ID
+		}
+		if err := tx.Create(&chunks).Error; err != nil {
+			return fmt.Errorf("复制分块记录失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newFile, nil
+}
@@ -0,0 +1,218 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// newHashedStream 带有SHA1的上传流，trusted表示哈希来自其他存储（如复制）而非客户端
+func newHashedStream(name string, data []byte, trusted bool) model.FileStreamer {
+	sum := sha1.Sum(data)
+	s := newTestStream(name, data).(*stream.FileStream)
+	s.Obj = &model.Object{Name: name, Size: int64(len(data)), Modified: time.Now(),
+		HashInfo: utils.NewHashInfo(utils.SHA1, hex.EncodeToString(sum[:]))}
+	s.TrustedHash = trusted
+	return s
+}
+
+// blobKey 返回文件记录中的页面ID
+func blobKey(t *testing.T, d *Notion, id string) string {
+	var f File
+	if err := d.db.First(&f, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return f.BlobKey
+}
+
+func TestDedup(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.Dedup = true
+		d.ArchiveOnDelete = true
+	})
+	ctx := context.Background()
+	data := testData(10 * 1024)
+	src, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	put := func(name string, data []byte, trusted bool) model.Obj {
+		obj, err := d.Put(ctx, rootDir(d), newHashedStream(name, data, trusted), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return obj
+	}
+	// 客户端声明的哈希不可信，重新上传
+	if obj := put("b.bin", data, false); blobKey(t, d, obj.GetID()) == blobKey(t, d, src.GetID()) {
+		t.Fatal("expect a file with a client sha1 to be uploaded")
+	}
+	// 没有相同内容的文件时正常上传
+	if obj := put("other.bin", testData(2000), true); blobKey(t, d, obj.GetID()) == blobKey(t, d, src.GetID()) {
+		t.Fatal("expect a file of other content to be uploaded")
+	}
+	// 从其他存储复制的文件共享页面，不再上传
+	uploads := fake.count(http.MethodPost, "/api/v3/getUploadFileUrl")
+	dup := put("c.bin", data, true)
+	if blobKey(t, d, dup.GetID()) != blobKey(t, d, src.GetID()) {
+		t.Fatal("expect a copied file to share the pages")
+	}
+	if n := fake.count(http.MethodPost, "/api/v3/getUploadFileUrl"); n != uploads {
+		t.Fatalf("expect no upload for a shared file, got %d", n-uploads)
+	}
+
+	// 删除源文件后共享的页面不归档，副本仍可读取
+	if err := d.Remove(ctx, src); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	archived := fake.pages[blobKey(t, d, dup.GetID())].archived
+	fake.mu.Unlock()
+	if archived {
+		t.Fatal("expect the shared page kept")
+	}
+	link, err := d.Link(ctx, dup, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+}
+
+func TestDedupChunked(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.Dedup = true
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	data := testData(3 * 1024 * 1024)
+	src, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pages := fake.livePages()
+	dup, err := d.Put(ctx, rootDir(d), newHashedStream("b.bin", data, true), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.livePages(); n != pages {
+		t.Fatalf("expect no new pages for a shared chunked file, got %d", n-pages)
+	}
+	// 副本有自己的分块记录，指向相同的页面
+	chunkKeys := func(id string) []string {
+		var chunks []FileChunk
+		if err := d.db.Where("file_id = ?", id).Order("chunk_index").Find(&chunks).Error; err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		for _, c := range chunks {
+			keys = append(keys, c.BlobKey)
+		}
+		return keys
+	}
+	srcKeys, dupKeys := chunkKeys(src.GetID()), chunkKeys(dup.GetID())
+	if len(srcKeys) < 2 || strings.Join(srcKeys, ",") != strings.Join(dupKeys, ",") {
+		t.Fatalf("expect the chunks shared, got %v and %v", srcKeys, dupKeys)
+	}
+	link, err := d.Link(ctx, dup, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+}
+
+func TestDedupDisabled(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	data := testData(1000)
+	src, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dup, err := d.Put(ctx, rootDir(d), newHashedStream("b.bin", data, true), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if blobKey(t, d, dup.GetID()) == blobKey(t, d, src.GetID()) {
+		t.Fatal("expect the file uploaded without dedup")
+	}
+}
+
+func TestDedupSharesThumbnail(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.Dedup = true
+		d.ArchiveOnDelete = true
+	})
+	ctx := context.Background()
+	data := testData(10 * 1024)
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.png", data), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	thumb, err := d.notionClient.CreateDatabasePage("a.png.thumb.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.db.Model(&File{}).Where("name = ?", "a.png").Update("thumb_page_id", thumb).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := d.Put(ctx, rootDir(d), newHashedStream("b.png", data, true), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	var dup File
+	if err := d.db.Where("name = ?", "b.png").First(&dup).Error; err != nil {
+		t.Fatal(err)
+	}
+	if dup.ThumbKey != thumb {
+		t.Fatalf("expect the deduplicated file to keep the thumbnail, got %q", dup.ThumbKey)
+	}
+
+	archived := func() bool {
+		fake.mu.Lock()
+		defer fake.mu.Unlock()
+		return fake.pages[thumb].archived
+	}
+	remove := func(name string) {
+		objs, err := d.List(ctx, rootDir(d), model.ListArgs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, obj := range objs {
+			if obj.GetName() == name {
+				if err := d.Remove(ctx, obj); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+		}
+		t.Fatalf("%s not found", name)
+	}
+	// 缩略图页面仍被另一个文件引用时不归档
+	remove("a.png")
+	if archived() {
+		t.Fatal("expect the shared thumbnail not archived")
+	}
+	if inUse, err := d.pageInUse(thumb); err != nil || !inUse {
+		t.Fatalf("expect the thumbnail in use, got %v %v", inUse, err)
+	}
+	remove("b.png")
+	if !archived() {
+		t.Fatal("expect the thumbnail archived with its last file")
+	}
+}
@@ -0,0 +1,45 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// GetDetails 统计存储在Notion中的数据量
+// 同一页面可能被link模式复制出的多个文件共享，按页面去重后再求和；历史版本同样占用Notion空间，一并计入
+func (d *Notion) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
+	var details model.StorageDetails
+
+	// 当前文件及历史版本
+	versionFiles := d.db.Model(&FileVersion{}).Select("version_file_id")
+	liveFiles := d.db.Model(&File{}).Select("id").Where("deleted = ? OR id IN (?)", false, versionFiles)
+
+	// 未分块文件按文件页面统计
+	pages := d.db.Model(&File{}).Distinct("notion_page_id", "size").
+		Where("is_chunked = ? AND id IN (?)", false, liveFiles)
+	var fileBytes int64
+	if err := d.db.Table("(?) AS pages", pages).Select("COALESCE(SUM(size), 0)").Scan(&fileBytes).Error; err != nil {
+		return nil, fmt.Errorf("统计文件大小失败: %w", err)
+	}
+
+	// 分块文件按分块页面统计
+	chunkPages := d.db.Model(&FileChunk{}).Distinct("notion_page_id", "chunk_size").
+		Where("deleted = ? AND file_id IN (?)", false, liveFiles)
+	var chunkBytes int64
+	if err := d.db.Table("(?) AS pages", chunkPages).Select("COALESCE(SUM(chunk_size), 0)").Scan(&chunkBytes).Error; err != nil {
+		return nil, fmt.Errorf("统计分块大小失败: %w", err)
+	}
+	details.UsedSpace = fileBytes + chunkBytes
+
+	var fileCount, dirCount int64
+	if err := d.db.Model(&File{}).Where("deleted = ?", false).Count(&fileCount).Error; err != nil {
+		return nil, fmt.Errorf("统计文件数量失败: %w", err)
+	}
+	if err := d.db.Model(&Directory{}).Where("deleted = ?", false).Count(&dirCount).Error; err != nil {
+		return nil, fmt.Errorf("统计目录数量失败: %w", err)
+	}
+	details.ObjectCount = fileCount + dirCount
+	return &details, nil
+}
@@ -0,0 +1,50 @@
+package notion
+
+import (
+	"context"
+	"testing"
+)
+
+func TestDetailsCountSharedPagesOnce(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.VersionRetention = 1 })
+	ctx := context.Background()
+	before, err := d.GetDetails(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := d.MakeDir(ctx, rootDir(d), "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// link模式的副本与源文件共享页面
+	if _, err := d.Copy(ctx, obj, dir); err != nil {
+		t.Fatal(err)
+	}
+	details, err := d.GetDetails(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if used := details.UsedSpace - before.UsedSpace; used != 1000 {
+		t.Fatalf("expect the shared page counted once, got %d bytes", used)
+	}
+	if n := details.ObjectCount - before.ObjectCount; n != 3 {
+		t.Fatalf("expect 2 files and a folder, got %d objects", n)
+	}
+	// 覆盖后旧内容作为历史版本保留，仍占用空间
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(2000)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if details, err = d.GetDetails(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if used := details.UsedSpace - before.UsedSpace; used != 3000 {
+		t.Fatalf("expect the new content and the old version, got %d bytes", used)
+	}
+	if n := details.ObjectCount - before.ObjectCount; n != 3 {
+		t.Fatalf("expect the old version not listed as an object, got %d objects", n)
+	}
+}
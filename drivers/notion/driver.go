@@ -7,13 +7,22 @@ import (
 	"io"
 	"path/filepath"
 	"strconv"
+	"text/template"
 	"time"
 
+	"github.com/Xhofe/go-cache"
+	"github.com/alist-org/alist/v3/internal/dbfs"
 	"github.com/alist-org/alist/v3/internal/driver"
 	"github.com/alist-org/alist/v3/internal/errs"
 	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/alist-org/alist/v3/pkg/cron"
 	"github.com/alist-org/alist/v3/pkg/http_range"
 	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
 )
@@ -29,11 +38,60 @@ type Notion struct {
 	model.Storage
 	Addition
 	db           *gorm.DB
+	tree         *dbfs.Tree
 	notionClient *NotionService
+	// chunkClient 创建分块页面的客户端，未配置ChunkDatabaseID时与notionClient相同
+	chunkClient *NotionService
+	// secondaryClient 镜像写入的备用数据库的客户端，未配置时为nil
+	secondaryClient *NotionService
+	// chunkNameTmpl 分块页面的标题模板
+	chunkNameTmpl *template.Template
+	// apiLimiter 主数据库和分块数据库客户端共享的Notion API限速
+	apiLimiter *apiLimiter
+	// secret 解密后的凭据，由loadSecrets填写
+	secret secrets
+	// chunkKey 分块加密的密钥，未配置时为nil
+	chunkKey []byte
+	// downloadLimit 分块文件的下载限速，由存储下的全部连接共享，nil表示不限速
+	downloadLimit stream.Limiter
+	// mimeTypes 自定义的后缀到ContentType的映射
+	mimeTypes map[string]string
+	// uploadFilter 上传文件的后缀和大小限制
+	uploadFilter uploadFilter
+	// webhookTmpl webhook的请求体模板，未配置webhook时为nil
+	webhookTmpl *template.Template
+	// sessionCron 定期清理过期的上传会话
+	sessionCron *cron.Cron
+	// healthCron 定期检查数据库、Notion和S3的可用性，未开启时为nil
+	healthCron *cron.Cron
+	health     *healthMonitor
+	// scrubCron 定期校验页面的内容，未开启时为nil
+	scrubCron *cron.Cron
+	scrub     scrubState
+	// snapshotCron 定时创建元数据快照，未开启时为nil
+	snapshotCron *cron.Cron
+	pack         packState
+	// hotCache 最近读取的小文件内容，未开启时为nil
+	hotCache *hotCache
+	// chunkURLs 分块页面的下载链接缓存
+	chunkURLs cache.ICache[string]
+	// readers 保留的分块文件读取器，未开启时为nil
+	readers *readerPool
+	// resizeCache 缩放后的图片，未开启image_resize时为nil
+	resizeCache *hotCache
+	// prefetches 分块视频文件预读的开头和moov
+	prefetches cache.ICache[*moovPrefetch]
+	// rebuild 从Notion重建元数据的状态
+	rebuild rebuildState
+	// dialector 元数据数据库的连接，为nil时按配置连接MySQL，测试中替换为SQLite
+	dialector gorm.Dialector
 }
 
 func (d *Notion) Config() driver.Config {
-	return config
+	c := config
+	// 用户目录下的列表因用户而异，不使用按路径缓存的列表
+	c.NoCache = d.UserHome
+	return c
 }
 
 func (d *Notion) GetAddition() driver.Additional {
@@ -41,115 +99,294 @@ func (d *Notion) GetAddition() driver.Additional {
 }
 
 func (d *Notion) Init(ctx context.Context) error {
+	if err := d.loadSecrets(); err != nil {
+		return err
+	}
+	if err := d.resolveRefs(); err != nil {
+		return err
+	}
+	if d.ValidateOnly {
+		return d.validateOnly(ctx)
+	}
 	// 初始化数据库连接
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
-		d.DBUser, d.DBPass, d.DBHost, d.DBPort, d.DBName)
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(d.openDialector(), &gorm.Config{})
 	if err != nil {
-		return fmt.Errorf("连接数据库失败: %v", err)
+		return d.lang().errorf(msgConnectDB, err)
+	}
+	if err = registerDBMetrics(db); err != nil {
+		return d.lang().errorf(msgRegisterDBMetrics, err)
 	}
 
 	// 自动迁移数据库表
-	err = db.AutoMigrate(&Directory{}, &File{}, &FileChunk{})
+	if err = dbfs.Migrate(db); err != nil {
+		return d.lang().errorf(msgMigrateDB, err)
+	}
+	if err = db.AutoMigrate(&Snapshot{}, &AuditLog{}, &UploadSession{}, &PackPage{}, &ParityChunk{}, &SecondaryPage{}); err != nil {
+		return d.lang().errorf(msgMigrateDB, err)
+	}
+
+	// 目录树，不存在根目录时创建
+	d.tree, err = dbfs.NewTree(db, d.NotionDatabaseID)
 	if err != nil {
-		return fmt.Errorf("迁移数据库失败: %v", err)
-	}
-
-	// 检查是否存在根目录，如果不存在则创建
-	var rootDir Directory
-	if err := db.Where("parent_id IS NULL AND database_id = ?", d.NotionDatabaseID).First(&rootDir).Error; err != nil {
-		if err == gorm.ErrRecordNotFound {
-			// 创建根目录
-			rootDir = Directory{
-				Name:       "/",
-				ParentID:   nil,
-				DatabaseID: d.NotionDatabaseID,
-				Deleted:    false,
-				CreatedAt:  time.Now(),
-				UpdatedAt:  time.Now(),
-			}
-			if err := db.Create(&rootDir).Error; err != nil {
-				return fmt.Errorf("创建根目录失败: %v", err)
-			}
-		} else {
-			return fmt.Errorf("检查根目录失败: %v", err)
-		}
+		return err
+	}
+	if d.Normalization != "none" {
+		d.tree.Norm = d.Normalization
+	}
+	d.tree.FoldCase = d.CaseInsensitive
+	if err = d.initRoot(); err != nil {
+		return err
 	}
 
 	// 初始化Notion客户端
-	d.notionClient = NewNotionService(d.NotionCookie, d.NotionToken, d.NotionSpaceID, d.NotionDatabaseID, d.NotionFilePageID)
+	d.notionClient = NewNotionService(d.secret.notionCookie, d.secret.notionToken, d.NotionSpaceID, d.NotionDatabaseID, d.NotionFilePageID)
+	if d.notionClient == nil {
+		return d.lang().errorf(msgInitClient)
+	}
+	d.chunkClient = d.notionClient
+	if d.ChunkDatabaseID != "" && d.ChunkDatabaseID != d.NotionDatabaseID {
+		d.chunkClient = NewNotionService(d.secret.notionCookie, d.secret.notionToken, d.NotionSpaceID, d.ChunkDatabaseID, d.NotionFilePageID)
+	}
+	d.notionClient.lang = d.lang()
+	d.chunkClient.lang = d.lang()
+	// 两个客户端使用同一个令牌，共享Notion对其的限流
+	d.apiLimiter = newAPILimiter(d.APIRateLimit)
+	for _, client := range []*NotionService{d.notionClient, d.chunkClient} {
+		client.setBaseURLs(d.APIBaseURL, d.PublicAPIBaseURL, d.S3BaseURL)
+		client.useAPILimiter(d.apiLimiter)
+	}
+	if d.MirrorMeta {
+		if err = d.notionClient.EnsureProperties(metaProperties()); err != nil {
+			return err
+		}
+		if d.chunkClient != d.notionClient {
+			if err = d.chunkClient.EnsureProperties(metaProperties()); err != nil {
+				return err
+			}
+		}
+	}
+	if d.UploadLimit > 0 {
+		// 两个客户端共享同一个限速
+		d.notionClient.uploadLimit = newLimiter(d.UploadLimit)
+		d.chunkClient.uploadLimit = d.notionClient.uploadLimit
+	}
+	for _, client := range []*NotionService{d.notionClient, d.chunkClient} {
+		client.uploadThreads = d.UploadThreads
+		client.storageClass = d.S3StorageClass
+		client.tagging = d.S3Tagging
+	}
+	if err = d.initSecondary(); err != nil {
+		return err
+	}
+	if err = d.initChunkNames(); err != nil {
+		return d.lang().errorf(msgParseChunkTemplate, err)
+	}
+	d.mimeTypes, err = parseMimeTypes(d.MimeTypes)
+	if err != nil {
+		return err
+	}
+	d.initUploadFilter()
+	if err = d.checkParity(); err != nil {
+		return err
+	}
+	if err = d.initWebhook(); err != nil {
+		return d.lang().errorf(msgParseWebhookTemplate, err)
+	}
+	d.downloadLimit = nil
+	if d.DownloadLimit > 0 {
+		d.downloadLimit = newLimiter(d.DownloadLimit)
+	}
 	d.db = db
+	d.chunkKey = nil
+	if d.secret.encryptionKey != "" {
+		d.chunkKey = chunkstore.ParseKey(d.secret.encryptionKey)
+	}
+	d.sessionCron = cron.NewCron(sessionCleanInterval)
+	d.sessionCron.Do(d.cleanSessions)
+	d.healthCron = nil
+	if d.HealthCheckInterval > 0 {
+		d.health = &healthMonitor{failures: make(map[string]int), errs: make(map[string]error)}
+		d.healthCron = cron.NewCron(time.Duration(d.HealthCheckInterval) * time.Minute)
+		d.healthCron.Do(d.checkHealth)
+	}
+	d.snapshotCron = nil
+	if d.SnapshotInterval > 0 {
+		d.snapshotCron = cron.NewCron(time.Duration(d.SnapshotInterval) * time.Hour)
+		d.snapshotCron.Do(d.autoSnapshot)
+	}
+	d.hotCache = nil
+	if d.HotCacheSize > 0 && d.HotCacheFileSize > 0 && d.HotCacheTTL > 0 {
+		d.hotCache = newHotCache(int64(d.HotCacheSize)*1024*1024, time.Duration(d.HotCacheTTL)*time.Minute)
+	}
+	d.resizeCache = nil
+	if d.ImageResize {
+		d.resizeCache = newHotCache(int64(d.ResizeCacheSize)*1024*1024, resizeCacheTTL)
+	}
+	d.chunkURLs = cache.NewMemCache[string]()
+	d.prefetches = cache.NewMemCache[*moovPrefetch]()
+	d.readers = nil
+	if d.ReaderKeepAlive > 0 {
+		d.readers = newReaderPool(time.Duration(d.ReaderKeepAlive) * time.Second)
+	}
+	d.scrubCron = nil
+	if d.ScrubPerDay > 0 {
+		d.scrubCron = cron.NewCron(24 * time.Hour / time.Duration(d.ScrubPerDay))
+		d.scrubCron.Do(d.scrubOne)
+	}
 
 	return nil
 }
 
+// openDialector 元数据数据库的连接方式，未替换时按配置连接MySQL
+func (d *Notion) openDialector() gorm.Dialector {
+	if d.dialector != nil {
+		return d.dialector
+	}
+	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=True&loc=Local",
+		d.DBUser, d.secret.dbPass, d.DBHost, d.DBPort, d.DBName)
+	return mysql.Open(dsn)
+}
+
 func (d *Notion) Drop(ctx context.Context) error {
+	if d.sessionCron != nil {
+		d.sessionCron.Stop()
+	}
+	if d.healthCron != nil {
+		d.healthCron.Stop()
+	}
+	if d.scrubCron != nil {
+		d.scrubCron.Stop()
+	}
+	if d.snapshotCron != nil {
+		d.snapshotCron.Stop()
+	}
+	d.hotCache = nil
+	d.resizeCache = nil
+	if d.readers != nil {
+		d.readers.closeAll()
+	}
+	if d.prefetches != nil {
+		d.prefetches.Clear()
+	}
 	return nil
 }
 
-func (d *Notion) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
-	var objs []model.Obj
+func (d *Notion) List(ctx context.Context, dir model.Obj, args model.ListArgs) (objs []model.Obj, err error) {
 	dirID := 1
 	if dir != nil {
 		id, _ := strconv.Atoi(dir.GetID())
 		dirID = id
 	}
+	ctx, span := tracer.Start(ctx, "notion.List", trace.WithAttributes(attribute.Int("dir_id", dirID)))
+	defer func() {
+		span.SetAttributes(attribute.Int("count", len(objs)))
+		endSpan(span, err)
+	}()
 
-	// 获取目录列表
-	var directories []Directory
-	if err := d.db.Where("parent_id = ? AND database_id = ? AND deleted = ?", dirID, d.NotionDatabaseID, false).Find(&directories).Error; err != nil {
-		return nil, fmt.Errorf("获取目录列表失败: %v", err)
+	directories, files, err := d.tree.List(dirID)
+	if err != nil {
+		return nil, err
 	}
-
-	for _, dir := range directories {
-		objs = append(objs, &model.Object{
-			ID:       strconv.Itoa(dir.ID),
-			Name:     dir.Name,
-			Size:     0,
-			Modified: dir.UpdatedAt,
-			IsFolder: true,
-		})
+	names := make(map[string]bool, len(directories)+len(files))
+	for i := range directories {
+		objs = append(objs, dbfs.DirToObj(&directories[i]))
+		names[directories[i].Name] = true
+	}
+	for i := range files {
+		objs = append(objs, d.listFileObj(ctx, args, &files[i]))
+		names[files[i].Name] = true
 	}
+	err = d.listAliases(ctx, args, dirID, names, func(obj model.Obj) error {
+		objs = append(objs, obj)
+		return nil
+	})
+	return objs, err
+}
 
-	// 获取文件列表
-	var files []File
-	if err := d.db.Where("directory_id = ? AND deleted = ?", dirID, false).Find(&files).Error; err != nil {
-		return nil, fmt.Errorf("获取文件列表失败: %v", err)
+// ListStream 与List相同，但文件从数据库游标逐个读取并交给fn，WebDAV列出超大目录时可以边查询边响应
+func (d *Notion) ListStream(ctx context.Context, dir model.Obj, args model.ListArgs, fn func(obj model.Obj) error) (err error) {
+	dirID, _ := strconv.Atoi(dir.GetID())
+	ctx, span := tracer.Start(ctx, "notion.ListStream", trace.WithAttributes(attribute.Int("dir_id", dirID)))
+	count := 0
+	defer func() {
+		span.SetAttributes(attribute.Int("count", count))
+		endSpan(span, err)
+	}()
+	names := make(map[string]bool)
+	err = d.tree.ListFunc(dirID, func(dir *Directory, f *File) error {
+		count++
+		if dir != nil {
+			names[dir.Name] = true
+			return fn(dbfs.DirToObj(dir))
+		}
+		names[f.Name] = true
+		return fn(d.listFileObj(ctx, args, f))
+	})
+	if err != nil {
+		return err
 	}
+	return d.listAliases(ctx, args, dirID, names, func(obj model.Obj) error {
+		count++
+		return fn(obj)
+	})
+}
 
-	for _, file := range files {
-		objs = append(objs, &model.Object{
-			ID:       strconv.Itoa(file.ID),
-			Name:     file.Name,
-			Size:     file.Size,
-			Modified: file.UpdatedAt,
-			IsFolder: false,
-		})
+// listFileObj 列表中的文件，带有缩略图地址
+func (d *Notion) listFileObj(ctx context.Context, args model.ListArgs, file *File) model.Obj {
+	return &dbfs.ObjThumb{
+		ObjThumb: model.ObjThumb{
+			Object: model.Object{
+				ID:       strconv.Itoa(file.ID),
+				Name:     file.Name,
+				Size:     file.Size,
+				Modified: file.UpdatedAt,
+				IsFolder: false,
+				HashInfo: file.HashInfo(),
+			},
+			Thumbnail: model.Thumbnail{
+				Thumbnail: d.thumbURL(ctx, args.ReqPath, file.Name),
+			},
+		},
+		Ver: file.Version,
 	}
+}
 
-	return objs, nil
+// Search 直接在数据库中按名称搜索目录下的文件，不依赖alist的搜索索引
+func (d *Notion) Search(ctx context.Context, dir model.Obj, req model.SearchReq) ([]model.SearchNode, int64, error) {
+	dirID, _ := strconv.Atoi(dir.GetID())
+	return d.tree.Search(dirID, req.Keywords, req.Scope, (req.Page-1)*req.PerPage, req.PerPage)
 }
 
-func (d *Notion) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+func (d *Notion) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (link *model.Link, err error) {
+	ctx, span := tracer.Start(ctx, "notion.Link", trace.WithAttributes(attribute.String("file_id", file.GetID())))
+	defer func() { endSpan(span, err) }()
 	var f File
 	if err := d.db.Where("id = ? AND deleted = ?", file.GetID(), false).First(&f).Error; err != nil {
-		return nil, fmt.Errorf("获取文件信息失败: %v", err)
+		return nil, d.lang().dbError(msgGetFile, err)
+	}
+	span.SetAttributes(attribute.Int64("size", f.Size), attribute.Bool("chunked", f.IsChunked), attribute.String("page_id", f.BlobKey))
+
+	if args.Type == "thumb" && d.thumbEnabled() {
+		return d.thumbLink(ctx, &f)
+	}
+	if link, err := d.resizeLink(ctx, &f, args); link != nil || err != nil {
+		return link, err
+	}
+	if f.IsInline() {
+		return inlineLink(&f), nil
+	}
+	if !f.IsChunked && f.Size <= d.hotLimit() {
+		return d.hotFileLink(ctx, &f)
 	}
 
 	// 检查是否为分块文件
 	if f.IsChunked {
-		// 获取所有分块信息
-		var chunks []FileChunk
-		if err := d.db.Where("file_id = ? AND deleted = ?", f.ID, false).Order("chunk_index").Find(&chunks).Error; err != nil {
-			return nil, fmt.Errorf("获取文件分块信息失败: %v", err)
-		}
-
-		if len(chunks) == 0 {
-			return nil, fmt.Errorf("分块文件没有找到分块数据")
+		rangeReadCloser, err := d.chunkedReader(&f)
+		if err != nil {
+			return nil, err
 		}
-
-		// 创建分块Range读取器
-		rangeReadCloser := NewChunkedRangeReadCloser(d.notionClient, chunks, f.Size)
+		rangeReadCloser = d.coalesceRanges(rangeReadCloser, f.ID, f.Size, args.IP)
+		rangeReadCloser = d.prefetchMoov(&f, rangeReadCloser)
 
 		resultRangeReader := func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
 			return rangeReadCloser.RangeRead(ctx, httpRange)
@@ -162,351 +399,445 @@ func (d *Notion) Link(ctx context.Context, file model.Obj, args model.LinkArgs)
 		}, nil
 	} else {
 		// 单文件，返回直接URL
-		property, err := d.notionClient.GetPageProperty(f.NotionPageID, d.NotionFilePageID)
+		fileURL, client, err := d.pageFileURL(f.BlobKey, f.BlobIndex)
 		if err != nil {
-			return nil, fmt.Errorf("获取文件URL失败: %v", err)
+			return nil, d.lang().errorf(msgGetFileURL, err)
 		}
 
-		return &model.Link{
-			URL: property.Files[0].File.URL,
-		}, nil
+		return &model.Link{URL: fileURL, Header: client.FileHeader(fileURL)}, nil
+	}
+}
+
+// chunkedReader 创建分块文件的Range读取器
+func (d *Notion) chunkedReader(f *File) (model.RangeReadCloserIF, error) {
+	// 获取所有分块信息
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ? AND deleted = ?", f.ID, false).Order("chunk_index").Find(&chunks).Error; err != nil {
+		return nil, d.lang().errorf(msgGetChunks, err)
+	}
+
+	if len(chunks) == 0 {
+		return nil, d.lang().errorf(msgNoChunks)
+	}
+
+	if d.chunkKey == nil && chunks[0].Nonce != "" {
+		return nil, d.lang().errorf(msgNeedEncryptionKey)
+	}
+
+	backend, err := d.newChunkBackend(f.Name, "")
+	if err != nil {
+		return nil, err
 	}
+	return d.limitDownload(chunkstore.NewRangeReadCloser(backend, toChunks(chunks), f.Size)), nil
 }
 
-func (d *Notion) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) (model.Obj, error) {
+func (d *Notion) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) (obj model.Obj, err error) {
+	defer func() { d.audit(ctx, AuditMakeDir, "", obj, err) }()
+	if err = d.checkWrite(true); err != nil {
+		return nil, err
+	}
 	parentID := 1
 	if parentDir != nil {
 		id, _ := strconv.Atoi(parentDir.GetID())
 		parentID = id
 	}
-	//先检查是否存在同名目录
-	var existingDir Directory
-	if err := d.db.Where("parent_id = ? AND name = ? AND deleted = ?", parentID, dirName, false).First(&existingDir).Error; err == nil {
-		// 如果找到同名目录，直接返回该目录信息
-		return &model.Object{
-			ID:       strconv.Itoa(existingDir.ID),
-			Name:     existingDir.Name,
-			Size:     0,
-			Modified: existingDir.UpdatedAt,
-			IsFolder: true,
-		}, nil
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		// 如果是其他错误,返回错误信息
-		return nil, fmt.Errorf("检查目录是否存在时发生错误: %v", err)
+	// 存在同名目录时直接返回该目录
+	dir, err := d.tree.MakeDir(parentID, dirName)
+	if err != nil {
+		return nil, err
 	}
+	return dbfs.DirToObj(dir), nil
+}
 
-	dir := &Directory{
-		Name:       dirName,
-		ParentID:   &parentID,
-		DatabaseID: d.NotionDatabaseID,
+func (d *Notion) Move(ctx context.Context, srcObj, dstDir model.Obj) (obj model.Obj, err error) {
+	oldPath := d.auditPath(srcObj)
+	defer func() { d.audit(ctx, AuditMove, oldPath, obj, err) }()
+	if err = d.checkWrite(false); err != nil {
+		return nil, err
 	}
-	if err := d.db.Create(dir).Error; err != nil {
-		return nil, fmt.Errorf("创建目录失败: %v", err)
+	defer func() {
+		if err == nil {
+			d.mirrorObj(obj)
+		}
+	}()
+	parentID, _ := strconv.Atoi(dstDir.GetID())
+	if a, ok := srcObj.(*dbfs.AliasObj); ok {
+		alias, err := d.tree.MoveAlias(a.Alias.ID, parentID, a.Alias.Version)
+		if err != nil {
+			return nil, err
+		}
+		return d.aliasObj(ctx, model.ListArgs{}, alias)
 	}
-
-	return &model.Object{
-		ID:       strconv.Itoa(dir.ID),
-		Name:     dir.Name,
-		Size:     0,
-		Modified: dir.UpdatedAt,
-		IsFolder: true,
-	}, nil
-}
-
-func (d *Notion) Move(ctx context.Context, srcObj, dstDir model.Obj) (model.Obj, error) {
+	version, _ := model.GetVersion(srcObj)
 	if srcObj.IsDir() {
-		var dir Directory
-		if err := d.db.Where("id = ? AND deleted = ?", srcObj.GetID(), false).First(&dir).Error; err != nil {
-			return nil, fmt.Errorf("获取目录信息失败: %v", err)
+		dir, err := d.tree.MoveDir(srcObj.GetID(), parentID, version)
+		if err != nil {
+			return nil, err
 		}
+		return dbfs.DirToObj(dir), nil
+	}
+	file, err := d.tree.MoveFile(srcObj.GetID(), parentID, version)
+	if err != nil {
+		return nil, err
+	}
+	return dbfs.FileToObj(file), nil
+}
 
-		parentID, _ := strconv.Atoi(dstDir.GetID())
-		dir.ParentID = &parentID
-		if err := d.db.Save(&dir).Error; err != nil {
-			return nil, fmt.Errorf("移动目录失败: %v", err)
+func (d *Notion) Rename(ctx context.Context, srcObj model.Obj, newName string) (obj model.Obj, err error) {
+	oldPath := d.auditPath(srcObj)
+	defer func() { d.audit(ctx, AuditRename, oldPath, obj, err) }()
+	if err = d.checkWrite(false); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if err == nil {
+			d.mirrorObj(obj)
 		}
-
-		return &model.Object{
-			ID:       strconv.Itoa(dir.ID),
-			Name:     dir.Name,
-			Size:     0,
-			Modified: dir.UpdatedAt,
-			IsFolder: true,
-		}, nil
-	} else {
-		var file File
-		if err := d.db.Where("id = ? AND deleted = ?", srcObj.GetID(), false).First(&file).Error; err != nil {
-			return nil, fmt.Errorf("获取文件信息失败: %v", err)
+	}()
+	if a, ok := srcObj.(*dbfs.AliasObj); ok {
+		alias, err := d.tree.RenameAlias(a.Alias.ID, newName, a.Alias.Version)
+		if err != nil {
+			return nil, err
 		}
-
-		dirID, _ := strconv.Atoi(dstDir.GetID())
-		file.DirectoryID = dirID
-		if err := d.db.Save(&file).Error; err != nil {
-			return nil, fmt.Errorf("移动文件失败: %v", err)
+		return d.aliasObj(ctx, model.ListArgs{}, alias)
+	}
+	version, _ := model.GetVersion(srcObj)
+	if srcObj.IsDir() {
+		dir, err := d.tree.RenameDir(srcObj.GetID(), newName, version)
+		if err != nil {
+			return nil, err
 		}
-
-		return &model.Object{
-			ID:       strconv.Itoa(file.ID),
-			Name:     file.Name,
-			Size:     file.Size,
-			Modified: file.UpdatedAt,
-			IsFolder: false,
-		}, nil
+		return dbfs.DirToObj(dir), nil
 	}
+	file, err := d.tree.RenameFile(srcObj.GetID(), newName, version)
+	if err != nil {
+		return nil, err
+	}
+	d.renamePages(file)
+	return dbfs.FileToObj(file), nil
 }
 
-func (d *Notion) Rename(ctx context.Context, srcObj model.Obj, newName string) (model.Obj, error) {
-	if srcObj.IsDir() {
-		var dir Directory
-		if err := d.db.Where("id = ? AND deleted = ?", srcObj.GetID(), false).First(&dir).Error; err != nil {
-			return nil, fmt.Errorf("获取目录信息失败: %v", err)
+// renamePages 将文件名同步到Notion页面标题，页面标题仅用于在Notion中辨认文件，失败时只记录日志
+func (d *Notion) renamePages(f *File) {
+	// 混淆的页面标题与文件名无关，打包页面保存多个文件
+	if d.ObfuscateNames || f.IsInline() || f.Packed {
+		return
+	}
+	if !f.IsChunked {
+		if err := d.notionClient.UpdatePageTitle(f.BlobKey, d.pageTitle(f.Name)); err != nil {
+			log.Warnf("同步文件[%s]的页面标题失败: %+v", f.Name, err)
 		}
-
-		dir.Name = newName
-		if err := d.db.Save(&dir).Error; err != nil {
-			return nil, fmt.Errorf("重命名目录失败: %v", err)
+		return
+	}
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ? AND deleted = ?", f.ID, false).Find(&chunks).Error; err != nil {
+		log.Warnf("获取文件[%s]的分块失败: %+v", f.Name, err)
+		return
+	}
+	for _, chunk := range chunks {
+		chunkName := d.chunkPageTitle(f.Name, chunk.ChunkIndex)
+		if err := d.chunkClient.UpdatePageTitle(chunk.BlobKey, chunkName); err != nil {
+			log.Warnf("同步分块[%s]的页面标题失败: %+v", chunkName, err)
 		}
+	}
+}
 
-		return &model.Object{
-			ID:       strconv.Itoa(dir.ID),
-			Name:     dir.Name,
-			Size:     0,
-			Modified: dir.UpdatedAt,
-			IsFolder: true,
-		}, nil
+func (d *Notion) Copy(ctx context.Context, srcObj, dstDir model.Obj) (obj model.Obj, err error) {
+	defer func() { d.audit(ctx, AuditCopy, d.auditPath(srcObj), obj, err) }()
+	if err = d.checkWrite(true); err != nil {
+		return nil, err
+	}
+	if srcObj.IsDir() {
+		// 目录交给alist的复制任务逐个文件处理，避免在请求内同步遍历大目录导致超时，
+		// 任务中的每个文件仍会回到这里以元数据方式复制
+		return nil, errs.NotSupport
 	} else {
-		var file File
-		if err := d.db.Where("id = ? AND deleted = ?", srcObj.GetID(), false).First(&file).Error; err != nil {
-			return nil, fmt.Errorf("获取文件信息失败: %v", err)
+		// 复制文件
+		var srcFile File
+		if err := d.db.Where("id = ? AND deleted = ?", srcObj.GetID(), false).First(&srcFile).Error; err != nil {
+			return nil, d.lang().dbError(msgGetSrcFile, err)
 		}
+		// 复制别名时新文件使用别名的名称
+		srcFile.Name = srcObj.GetName()
 
-		file.Name = newName
-		if err := d.db.Save(&file).Error; err != nil {
-			return nil, fmt.Errorf("重命名文件失败: %v", err)
+		dstDirID, _ := strconv.Atoi(dstDir.GetID())
+		newFile, err := d.copyFile(ctx, &srcFile, dstDirID)
+		if err != nil {
+			return nil, err
 		}
+		d.mirrorFile(newFile)
+		return dbfs.FileToObj(newFile), nil
+	}
+}
 
-		return &model.Object{
-			ID:       strconv.Itoa(file.ID),
-			Name:     file.Name,
-			Size:     file.Size,
-			Modified: file.UpdatedAt,
-			IsFolder: false,
-		}, nil
+func (d *Notion) Remove(ctx context.Context, obj model.Obj) error {
+	if err := d.checkWrite(false); err != nil {
+		return err
+	}
+	var parentID int
+	if d.webhookTmpl != nil {
+		parentID = d.tree.ParentID(obj)
 	}
+	oldPath := d.auditPath(obj)
+	if err := d.remove(ctx, obj); err != nil {
+		return err
+	}
+	d.audit(ctx, AuditRemove, oldPath, nil, nil)
+	d.notify(ctx, EventDelete, parentID, obj.GetName(), obj.GetSize(), nil)
+	return nil
 }
 
-func (d *Notion) Copy(ctx context.Context, srcObj, dstDir model.Obj) (model.Obj, error) {
-	if srcObj.IsDir() {
-		// 复制目录
-		var srcDir Directory
-		if err := d.db.Where("id = ? AND deleted = ?", srcObj.GetID(), false).First(&srcDir).Error; err != nil {
-			return nil, fmt.Errorf("获取源目录信息失败: %v", err)
+// remove 删除文件或目录，目录会递归删除
+func (d *Notion) remove(ctx context.Context, obj model.Obj) error {
+	// 删除别名不影响目标
+	if a, ok := obj.(*dbfs.AliasObj); ok {
+		return d.tree.DeleteAlias(a.Alias.ID)
+	}
+	if obj.IsDir() || d.ArchiveOnDelete {
+		// 读取后被其他客户端修改过的对象不删除，未归档的文件在标记删除时检查
+		if err := d.tree.CheckVersion(obj); err != nil {
+			return err
 		}
-
-		// 创建新目录
-		dstDirID, _ := strconv.Atoi(dstDir.GetID())
-		newDir := &Directory{
-			Name:       srcDir.Name,
-			ParentID:   &dstDirID,
-			DatabaseID: d.NotionDatabaseID,
+	}
+	if obj.IsDir() {
+		return d.removeDir(obj)
+	}
+	if d.ArchiveOnDelete {
+		var f File
+		if err := d.db.Where("id = ? AND deleted = ?", obj.GetID(), false).First(&f).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil
+			}
+			return d.lang().errorf(msgGetFile, err)
 		}
-		if err := d.db.Create(newDir).Error; err != nil {
-			return nil, fmt.Errorf("创建目标目录失败: %v", err)
+		pageIDs, err := d.purgeFile(&f)
+		if err != nil {
+			return err
 		}
-
-		// 复制目录下的所有文件
-		var files []File
-		if err := d.db.Where("directory_id = ? AND deleted = ?", srcDir.ID, false).Find(&files).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("获取源目录文件列表失败: %v", err)
+		d.archivePages(pageIDs)
+	} else {
+		version, _ := model.GetVersion(obj)
+		if err := d.tree.DeleteFile(obj.GetID(), version); err != nil {
+			return d.lang().errorf(msgDeleteFile, err)
 		}
+	}
+	return nil
+}
 
-		for _, file := range files {
-			newFile := &File{
-				Name:         file.Name,
-				Size:         file.Size,
-				SHA1:         file.SHA1,
-				NotionPageID: file.NotionPageID,
-				DirectoryID:  newDir.ID,
-			}
-			if err := d.db.Create(newFile).Error; err != nil {
-				return nil, fmt.Errorf("复制文件失败: %v", err)
-			}
-		}
+func (d *Notion) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) (model.Obj, error) {
+	if err := d.checkWrite(true); err != nil {
+		return nil, err
+	}
+	if err := d.checkUpload(file.GetName(), file.GetSize()); err != nil {
+		return nil, err
+	}
+	dirID, _ := strconv.Atoi(dstDir.GetID())
+	ctx, span := tracer.Start(ctx, "notion.Put", trace.WithAttributes(
+		attribute.String("name", file.GetName()), attribute.Int64("size", file.GetSize()), attribute.Int("dir_id", dirID)))
+	obj, err := d.put(ctx, dstDir, file, up)
+	if err == nil {
+		span.SetAttributes(attribute.String("file_id", obj.GetID()))
+	}
+	endSpan(span, err)
+	if err != nil {
+		d.notify(ctx, EventUploadFailed, dirID, filepath.Base(file.GetName()), file.GetSize(), err)
+		return nil, err
+	}
+	d.notify(ctx, EventUpload, dirID, obj.GetName(), obj.GetSize(), nil)
+	d.audit(ctx, AuditPut, "", obj, nil)
+	d.mirrorObj(obj)
+	return obj, nil
+}
 
-		// 递归复制子目录
-		var subDirs []Directory
-		if err := d.db.Where("parent_id = ? AND deleted = ?", srcDir.ID, false).Find(&subDirs).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, fmt.Errorf("获取子目录列表失败: %v", err)
-		}
+// put 上传文件，存在同名文件时替换
+func (d *Notion) put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) (model.Obj, error) {
+	if err := d.scanUpload(ctx, file); err != nil {
+		return nil, err
+	}
+	fileSize := file.GetSize()
+	fileName := d.tree.NormName(filepath.Base(file.GetName()))
+	dirID, _ := strconv.Atoi(dstDir.GetID())
 
-		// 递归复制子目录
-		for _, subDir := range subDirs {
-			// 创建子目录对象
-			subDirObj := &model.Object{
-				ID:       strconv.Itoa(subDir.ID),
-				Name:     subDir.Name,
-				Size:     0,
-				Modified: subDir.UpdatedAt,
-				IsFolder: true,
-			}
-			// 递归复制子目录
-			if _, err := d.Copy(ctx, subDirObj, &model.Object{
-				ID:       strconv.Itoa(newDir.ID),
-				Name:     newDir.Name,
-				Size:     0,
-				Modified: newDir.UpdatedAt,
-				IsFolder: true,
-			}); err != nil {
-				return nil, fmt.Errorf("复制子目录 %s 失败: %v", subDir.Name, err)
-			}
-		}
+	// 检查是否存在同名文件，存在则在上传成功后替换
+	existingFile, err := d.tree.FindFile(dirID, fileName)
+	if err != nil {
+		return nil, err
+	}
+	if err := d.checkOverwrite(existingFile); err != nil {
+		return nil, err
+	}
 
-		return &model.Object{
-			ID:       strconv.Itoa(newDir.ID),
-			Name:     newDir.Name,
-			Size:     0,
-			Modified: newDir.UpdatedAt,
-			IsFolder: true,
-		}, nil
-	} else {
-		// 复制文件
-		var srcFile File
-		if err := d.db.Where("id = ? AND deleted = ?", srcObj.GetID(), false).First(&srcFile).Error; err != nil {
-			return nil, fmt.Errorf("获取源文件信息失败: %v", err)
+	// 已存储相同内容的文件时共享其页面，不再上传
+	obj, err := d.putDuplicate(ctx, fileName, dirID, file, up)
+	if err != nil {
+		return nil, err
+	}
+	if obj == nil && fileSize <= d.inlineLimit() {
+		obj, err = d.putInlineFile(ctx, fileName, fileSize, dirID, file, up)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if obj == nil {
+		// 判断是否需要分块上传，开启加密时非空文件都按分块上传，以便在分块记录中保存加密信息
+		if fileSize > ChunkThreshold || (d.chunkKey != nil && fileSize > 0) || (d.ChunkMode == chunkModeCDC && fileSize > d.cdcAvgSize()) {
+			obj, err = d.putChunkedFile(ctx, fileName, fileSize, dirID, file, up)
+		} else if fileSize <= d.packLimit() {
+			obj, err = d.putPackedFile(ctx, fileName, fileSize, dirID, file, up)
+		} else {
+			obj, err = d.putSingleFile(ctx, fileName, fileSize, dirID, file, up)
 		}
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		// 创建新文件记录
-		dstDirID, _ := strconv.Atoi(dstDir.GetID())
-		newFile := &File{
-			Name:         srcFile.Name,
-			Size:         srcFile.Size,
-			SHA1:         srcFile.SHA1,
-			NotionPageID: srcFile.NotionPageID,
-			DirectoryID:  dstDirID,
-		}
-		if err := d.db.Create(newFile).Error; err != nil {
-			return nil, fmt.Errorf("复制文件记录失败: %v", err)
-		}
-
-		return &model.Object{
-			ID:       strconv.Itoa(newFile.ID),
-			Name:     newFile.Name,
-			Size:     newFile.Size,
-			Modified: newFile.UpdatedAt,
-			IsFolder: false,
-		}, nil
+	// 新文件上传成功后再淘汰旧文件，上传失败时旧文件保持不变
+	if existingFile != nil {
+		newID, _ := strconv.Atoi(obj.GetID())
+		if err := d.retireFile(existingFile, newID); err != nil {
+			return nil, err
+		}
 	}
+	return obj, nil
 }
 
-func (d *Notion) Remove(ctx context.Context, obj model.Obj) error {
-	if obj.IsDir() {
-		if err := d.db.Model(&Directory{}).Where("id = ?", obj.GetID()).Update("deleted", true).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("删除目录失败: %v", err)
+// retireFile 淘汰被覆盖的旧文件，开启版本保留时将其记录为新文件的历史版本
+func (d *Notion) retireFile(f *File, newID int) error {
+	var pageIDs []string
+	if d.ArchiveOnDelete {
+		// 记录旧文件及其历史版本的页面，替换后归档其中不再被引用的页面
+		ids, err := filePageIDs(d.db, f)
+		if err != nil {
+			return err
 		}
-		// 删除目录下的所有文件
-		if err := d.db.Model(&File{}).Where("directory_id = ?", obj.GetID()).Update("deleted", true).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("删除目录下的文件失败: %v", err)
+		versionIDs, err := versionPageIDs(d.db, f.ID)
+		if err != nil {
+			return err
 		}
-
-		// 递归删除子目录及其文件
-		var subDirs []Directory
-		if err := d.db.Where("parent_id = ? AND deleted = ?", obj.GetID(), false).Find(&subDirs).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("获取子目录失败: %v", err)
+		pageIDs = append(ids, versionIDs...)
+	}
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&File{}).Where("id = ?", f.ID).Update("deleted", true).Error; err != nil {
+			return d.lang().errorf(msgReplaceFile, err)
 		}
-
-		for _, subDir := range subDirs {
-			subObj := &model.Object{
-				ID:       strconv.Itoa(subDir.ID),
-				Name:     subDir.Name,
-				IsFolder: true,
-			}
-			if err := d.Remove(ctx, subObj); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-				return fmt.Errorf("删除子目录 %s 失败: %v", subDir.Name, err)
-			}
+		// 标签属于文件路径而不是某次上传的内容，转移到新文件
+		if err := dbfs.MoveTags(tx, f.ID, newID); err != nil {
+			return err
 		}
-	} else {
-		if err := d.db.Model(&File{}).Where("id = ?", obj.GetID()).Update("deleted", true).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
-			return fmt.Errorf("删除文件失败: %v", err)
+		if d.VersionRetention <= 0 {
+			return d.deleteFileChunks(tx, f)
+		}
+		// 旧文件的历史版本转移到新文件下
+		if err := tx.Model(&FileVersion{}).Where("file_id = ?", f.ID).Update("file_id", newID).Error; err != nil {
+			return d.lang().errorf(msgMoveVersions, err)
+		}
+		if err := tx.Create(&FileVersion{FileID: newID, VersionFileID: f.ID}).Error; err != nil {
+			return d.lang().errorf(msgSaveVersion, err)
 		}
+		return d.pruneVersions(tx, newID)
+	})
+	if err != nil {
+		return err
 	}
+	d.archivePages(pageIDs)
 	return nil
 }
 
-func (d *Notion) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) (model.Obj, error) {
-	// 检查是否存在同名文件
-	var existingFile File
-	if err := d.db.Where("name = ? AND directory_id = ? AND deleted = ?", filepath.Base(file.GetName()), dstDir.GetID(), false).First(&existingFile).Error; err == nil {
-		return &model.Object{
-			ID:       strconv.Itoa(existingFile.ID),
-			Name:     existingFile.Name,
-			Size:     existingFile.Size,
-			Modified: existingFile.UpdatedAt,
-			IsFolder: false,
-		}, nil
-	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, fmt.Errorf("检查文件是否存在时发生错误: %v", err)
+// deleteFileChunks 将文件的分块标记为删除，并删除其校验分块
+func (d *Notion) deleteFileChunks(tx *gorm.DB, f *File) error {
+	if !f.IsChunked {
+		return nil
 	}
-
-	fileSize := file.GetSize()
-	fileName := filepath.Base(file.GetName())
-	dirID, _ := strconv.Atoi(dstDir.GetID())
-
-	// 判断是否需要分块上传
-	if fileSize > ChunkThreshold {
-		return d.putChunkedFile(ctx, fileName, fileSize, dirID, file, up)
-	} else {
-		return d.putSingleFile(ctx, fileName, fileSize, dirID, file, up)
+	if err := tx.Model(&FileChunk{}).Where("file_id = ?", f.ID).Update("deleted", true).Error; err != nil {
+		return d.lang().errorf(msgDeleteChunks, err)
 	}
+	_, err := deleteParity(tx, f.ID)
+	return err
 }
 
 // putSingleFile 上传单个文件（小于5GB）
 func (d *Notion) putSingleFile(ctx context.Context, fileName string, fileSize int64, dirID int, file model.FileStreamer, up driver.UpdateProgress) (model.Obj, error) {
 	// 创建Notion页面
-	pageID, err := d.notionClient.CreateDatabasePage(fileName)
+	title := d.pageTitle(fileName)
+	pageID, err := d.notionClient.CreateDatabasePage(title)
 	if err != nil {
-		return nil, fmt.Errorf("创建Notion页面失败: %v", err)
+		return nil, d.lang().errorf(msgCreateNotionPage, err)
+	}
+	// 镜像到备用数据库时需要再次读取文件
+	var cached io.ReaderAt
+	if d.secondaryClient != nil {
+		if cached, err = file.CacheFullInTempFile(); err != nil {
+			return nil, d.lang().errorf(msgCacheFile, err)
+		}
+	}
+	head, err := sniffHead(file)
+	if err != nil {
+		return nil, d.lang().errorf(msgReadFile, err)
+	}
+	mimetype := d.contentType(fileName, head)
+	file = &uploadFileStream{FileStreamer: file, name: title, mimetype: mimetype}
+	// SHA1由上传过程计算，其余哈希在上传读取时一并计算
+	var hasher *utils.MultiHasher
+	if d.ExtraHashes {
+		hasher = utils.NewMultiHasher(extraHashTypes)
+		file = &hashingStream{FileStreamer: file, hasher: hasher}
 	}
 
 	// 上传文件到Notion
-	hash1, err := d.notionClient.UploadAndUpdateFilePut(file, pageID, up)
+	var hash1 string
+	err = d.uploadTask(ctx, dirID, fileName, fileSize, 1, false, up, func(ctx context.Context, up driver.UpdateProgress) error {
+		var err error
+		hash1, err = d.notionClient.UploadAndUpdateFilePut(ctx, file, pageID, up)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("上传文件到Notion失败: %v", err)
+		return nil, d.lang().errorf(msgUploadToNotion, err)
+	}
+	if cached != nil {
+		d.writeSecondary(ctx, pageID, title, mimetype, cached, fileSize)
 	}
 
 	// 保存到数据库
 	f := &File{
-		Name:         fileName,
-		Size:         fileSize,
-		SHA1:         hash1,
-		NotionPageID: pageID,
-		DirectoryID:  dirID,
-		IsChunked:    false,
-		ChunkSize:    0,
+		Name:        fileName,
+		Size:        fileSize,
+		SHA1:        hash1,
+		BlobKey:     pageID,
+		DirectoryID: dirID,
+		IsChunked:   false,
+		ChunkSize:   0,
+	}
+	if hasher != nil {
+		f.SetHashes(hasher.GetHashInfo())
 	}
 	if err := d.db.Create(f).Error; err != nil {
-		return nil, fmt.Errorf("保存文件信息失败: %v", err)
+		return nil, d.lang().errorf(msgSaveFile, err)
 	}
 
-	return &model.Object{
-		ID:       strconv.Itoa(f.ID),
-		Name:     f.Name,
-		Size:     f.Size,
-		Modified: f.UpdatedAt,
-		IsFolder: false,
-	}, nil
+	return dbfs.FileToObj(f), nil
 }
 
 // putChunkedFile 上传分块文件（大于5GB）
-func (d *Notion) putChunkedFile(ctx context.Context, fileName string, fileSize int64, dirID int, file model.FileStreamer, up driver.UpdateProgress) (model.Obj, error) {
-	// 计算分块数量
-	chunkCount := (fileSize + MaxChunkSize - 1) / MaxChunkSize
-
-	// 缓存文件到临时文件以支持多次读取
-	tempFile, err := file.CacheFullInTempFile()
+func (d *Notion) putChunkedFile(ctx context.Context, fileName string, fileSize int64, dirID int, file model.FileStreamer, up driver.UpdateProgress) (obj model.Obj, err error) {
+	// 缓存文件到临时文件以支持多次读取；分块只保存各自的哈希，整个文件的哈希在缓存时计算，
+	// 上传层缓存时已计算则直接使用
+	hashes, staged := stagedHash(file, d.hashTypes())
+	var tempFile model.File
+	if staged {
+		tempFile, err = file.CacheFullInTempFile()
+	} else {
+		hasher := utils.NewMultiHasher(d.hashTypes())
+		tempFile, err = stream.CacheFullInTempFileAndWriter(file, hasher)
+		hashes = *hasher.GetHashInfo()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("缓存文件失败: %v", err)
+		return nil, d.lang().errorf(msgCacheFile, err)
 	}
 	defer tempFile.Close()
+	if err := checkSHA1(file.GetHash(), hashes.GetHash(utils.SHA1)); err != nil {
+		return nil, err
+	}
 
 	// 创建主文件记录
 	f := &File{
@@ -516,76 +847,60 @@ func (d *Notion) putChunkedFile(ctx context.Context, fileName string, fileSize i
 		IsChunked:   true,
 		ChunkSize:   MaxChunkSize,
 	}
+	f.SetHashes(&hashes)
 	if err := d.db.Create(f).Error; err != nil {
-		return nil, fmt.Errorf("创建文件记录失败: %v", err)
+		return nil, d.lang().errorf(msgCreateFileRecord, err)
 	}
-
-	// 上传每个分块
-	var chunks []FileChunk
-	for i := int64(0); i < chunkCount; i++ {
-		if utils.IsCanceled(ctx) {
-			return nil, ctx.Err()
-		}
-
-		startOffset := i * MaxChunkSize
-		endOffset := startOffset + MaxChunkSize
-		if endOffset > fileSize {
-			endOffset = fileSize
-		}
-		chunkSize := endOffset - startOffset
-
-		// 创建分块页面
-		chunkName := fmt.Sprintf("%s.chunk%d", fileName, i)
-		pageID, err := d.notionClient.CreateDatabasePage(chunkName)
+	// 上传失败时标记主文件记录为删除，避免残留同名的不完整文件
+	defer func() {
 		if err != nil {
-			return nil, fmt.Errorf("创建分块页面失败: %v", err)
+			d.db.Model(&File{}).Where("id = ?", f.ID).Update("deleted", true)
 		}
+	}()
 
-		// 创建分块读取器
-		chunkReader := io.NewSectionReader(tempFile, startOffset, chunkSize)
-		chunkStream := &ChunkFileStream{
-			Reader:   chunkReader,
-			name:     chunkName,
-			size:     chunkSize,
-			mimetype: file.GetMimetype(),
-		}
-
-		// 上传分块
-		chunkProgress := func(percentage float64) {
-			totalProgress := (float64(i) + percentage/100.0) / float64(chunkCount) * 100.0
-			up(totalProgress)
-		}
-
-		hash1, err := d.notionClient.UploadAndUpdateFilePut(chunkStream, pageID, chunkProgress)
-		if err != nil {
-			return nil, fmt.Errorf("上传分块%d失败: %v", i, err)
-		}
-
-		// 创建分块记录
-		chunk := FileChunk{
-			FileID:       f.ID,
-			ChunkIndex:   int(i),
-			ChunkSize:    chunkSize,
-			StartOffset:  startOffset,
-			EndOffset:    endOffset,
-			NotionPageID: pageID,
-			SHA1:         hash1,
-		}
-		chunks = append(chunks, chunk)
+	// 上传每个分块，失败时重试，自适应模式下每次重试都会缩小分块
+	sizer := d.newSizer()
+	head := make([]byte, min(int64(sniffSize), fileSize))
+	if _, err := tempFile.ReadAt(head, 0); err != nil {
+		return nil, d.lang().errorf(msgReadFile, err)
+	}
+	backend, err := d.newChunkBackend(fileName, d.contentType(fileName, head))
+	if err != nil {
+		return nil, err
+	}
+	var uploaded []chunkstore.Chunk
+	err = d.uploadTask(ctx, dirID, fileName, fileSize, d.chunkCount(fileSize), true, up, func(ctx context.Context, up driver.UpdateProgress) error {
+		var err error
+		uploaded, err = chunkstore.Split(ctx, backend, tempFile, fileSize, sizer, up)
+		return err
+	})
+	if err != nil {
+		return nil, d.lang().errorf(msgUploadChunk, err)
+	}
+	chunks := make([]FileChunk, 0, len(uploaded))
+	for _, chunk := range uploaded {
+		chunks = append(chunks, FileChunk{
+			FileID:      f.ID,
+			ChunkIndex:  chunk.Index,
+			ChunkSize:   chunk.Size(),
+			StartOffset: chunk.Start,
+			EndOffset:   chunk.End,
+			BlobKey:     chunk.Key,
+			SHA1:        chunk.Hash,
+			Nonce:       chunk.Nonce,
+			KeyID:       chunk.KeyID,
+		})
 	}
 
 	// 批量保存分块记录
 	if err := d.db.Create(&chunks).Error; err != nil {
-		return nil, fmt.Errorf("保存分块记录失败: %v", err)
+		return nil, d.lang().errorf(msgSaveChunk, err)
+	}
+	if err := d.putParity(ctx, f, tempFile, uploaded); err != nil {
+		return nil, err
 	}
 
-	return &model.Object{
-		ID:       strconv.Itoa(f.ID),
-		Name:     f.Name,
-		Size:     f.Size,
-		Modified: f.UpdatedAt,
-		IsFolder: false,
-	}, nil
+	return dbfs.FileToObj(f), nil
 }
 
 func (d *Notion) GetArchiveMeta(ctx context.Context, obj model.Obj, args model.ArchiveArgs) (model.ArchiveMeta, error) {
@@ -605,3 +920,10 @@ func (d *Notion) ArchiveDecompress(ctx context.Context, srcObj, dstDir model.Obj
 }
 
 var _ driver.Driver = (*Notion)(nil)
+var _ driver.Append = (*Notion)(nil)
+var _ driver.UploadSession = (*Notion)(nil)
+var _ driver.Search = (*Notion)(nil)
+var _ driver.GetRooter = (*Notion)(nil)
+var _ driver.UserScoped = (*Notion)(nil)
+var _ driver.LinkVariant = (*Notion)(nil)
+var _ driver.Locker = (*Notion)(nil)
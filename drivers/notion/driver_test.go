@@ -0,0 +1,1044 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/disintegration/imaging"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func rootDir(d *Notion) model.Obj {
+	return &model.Object{ID: d.RootFolderID, IsFolder: true}
+}
+
+func listNames(t *testing.T, d *Notion, dir model.Obj) []string {
+	objs, err := d.List(context.Background(), dir, model.ListArgs{})
+	if err != nil {
+		t.Fatalf("list: %v", err)
+	}
+	names := make([]string, 0, len(objs))
+	for _, obj := range objs {
+		names = append(names, obj.GetName())
+	}
+	return names
+}
+
+// readURL 下载单文件Link返回的地址
+func readURL(t *testing.T, link *model.Link) []byte {
+	if link.URL == "" {
+		t.Fatalf("expect a url, got %+v", link)
+	}
+	resp, err := http.Get(link.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("download: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// readRange 通过分块文件Link的RangeReadCloser读取一段内容
+func readRange(t *testing.T, link *model.Link, start, length int64) []byte {
+	if link.RangeReadCloser == nil {
+		t.Fatalf("expect a range reader, got %+v", link)
+	}
+	rc, err := link.RangeReadCloser.RangeRead(context.Background(), http_range.Range{Start: start, Length: length})
+	if err != nil {
+		t.Fatalf("range read %d+%d: %v", start, length, err)
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("range read %d+%d: %v", start, length, err)
+	}
+	return data
+}
+
+func TestPutLinkList(t *testing.T) {
+	for _, form := range []bool{false, true} {
+		fake := newFakeNotion(t)
+		fake.formUpload = form
+		d := newTestNotion(t, fake, nil)
+		data := testData(100 * 1024)
+		obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", data), func(float64) {})
+		if err != nil {
+			t.Fatalf("put (form %v): %v", form, err)
+		}
+		if obj.GetSize() != int64(len(data)) {
+			t.Fatalf("expect size %d, got %d", len(data), obj.GetSize())
+		}
+		if names := listNames(t, d, rootDir(d)); len(names) != 1 || names[0] != "a.bin" {
+			t.Fatalf("unexpected list %v", names)
+		}
+		link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+		if err != nil {
+			t.Fatalf("link: %v", err)
+		}
+		if got := readURL(t, link); !bytes.Equal(got, data) {
+			t.Fatalf("downloaded content differs (form %v)", form)
+		}
+		if n := fake.livePages(); n != 1 {
+			t.Fatalf("expect 1 page, got %d", n)
+		}
+	}
+}
+
+func TestPutReplace(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.ArchiveOnDelete = true })
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	data := testData(2000)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 {
+		t.Fatalf("expect the file to be replaced, got %v", names)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("expect the content of the new upload")
+	}
+	// 旧文件的页面不再被引用，被归档
+	if n := fake.livePages(); n != 1 {
+		t.Fatalf("expect 1 live page, got %d", n)
+	}
+}
+
+// TestPutChunked 按内容切分上传，检查分块首尾相接，并跨分块边界读取
+func TestPutChunked(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	data := testData(5 * 1024 * 1024)
+	size := int64(len(data))
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ?", obj.GetID()).Order("chunk_index").Find(&chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expect several chunks, got %d", len(chunks))
+	}
+	avg := d.cdcAvgSize()
+	var end int64
+	for i, c := range chunks {
+		if c.ChunkIndex != i || c.StartOffset != end || c.ChunkSize != c.EndOffset-c.StartOffset {
+			t.Fatalf("chunk %d doesn't follow the previous one: %+v", i, c)
+		}
+		if c.ChunkSize > avg*4 || (c.ChunkSize < avg/4 && i != len(chunks)-1) {
+			t.Fatalf("chunk %d has size %d out of [%d, %d]", i, c.ChunkSize, avg/4, avg*4)
+		}
+		end = c.EndOffset
+	}
+	if end != size {
+		t.Fatalf("chunks end at %d, expect %d", end, size)
+	}
+	if n := fake.livePages(); n != len(chunks) {
+		t.Fatalf("expect a page per chunk, got %d pages for %d chunks", n, len(chunks))
+	}
+
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatalf("link: %v", err)
+	}
+	boundary := chunks[1].StartOffset
+	ranges := []http_range.Range{
+		{Start: 0, Length: size},
+		{Start: boundary - 10, Length: 20},
+		{Start: boundary, Length: chunks[1].ChunkSize},
+		{Start: size - 100, Length: 100},
+	}
+	for _, r := range ranges {
+		if got := readRange(t, link, r.Start, r.Length); !bytes.Equal(got, data[r.Start:r.Start+r.Length]) {
+			t.Fatalf("range %d+%d differs", r.Start, r.Length)
+		}
+	}
+}
+
+func TestMove(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	dir, err := d.MakeDir(context.Background(), rootDir(d), "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testData(1000)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	moved, err := d.Move(context.Background(), obj, dir)
+	if err != nil {
+		t.Fatalf("move: %v", err)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 || names[0] != "sub" {
+		t.Fatalf("unexpected root list %v", names)
+	}
+	if names := listNames(t, d, dir); len(names) != 1 || names[0] != "a.bin" {
+		t.Fatalf("unexpected sub list %v", names)
+	}
+	link, err := d.Link(context.Background(), moved, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("moved file has different content")
+	}
+}
+
+// TestPutRetry S3上传失败和Notion API限流后重试
+func TestPutRetry(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	fake.failNext(http.MethodPost, "/v1/pages", http.StatusTooManyRequests, 1)
+	fake.failNext(http.MethodPut, "/s3/", http.StatusInternalServerError, 1)
+	data := testData(1000)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if n := fake.count(http.MethodPost, "/v1/pages"); n != 2 {
+		t.Fatalf("expect the throttled page creation to be retried once, got %d requests", n)
+	}
+	if n := fake.count(http.MethodPut, "/s3/"); n != 2 {
+		t.Fatalf("expect the failed put to be retried once, got %d requests", n)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("downloaded content differs")
+	}
+
+	// 客户端错误不重试
+	fake.failNext(http.MethodPut, "/s3/", http.StatusForbidden, 1)
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("b.bin", data), func(float64) {}); err == nil {
+		t.Fatal("expect the put to fail")
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 {
+		t.Fatalf("expect no file for the failed put, got %v", names)
+	}
+}
+
+// TestChunkReadRetry 读取分块失败后刷新下载地址重试
+func TestChunkReadRetry(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	data := testData(3 * 1024 * 1024)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fake.failNext(http.MethodGet, "/s3/", http.StatusForbidden, 1)
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content differs after retry")
+	}
+	if n := fake.count(http.MethodGet, "/s3/"); n < 2 {
+		t.Fatalf("expect the failed read to be retried, got %d requests", n)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	steps := d.validate(context.Background())
+	for _, s := range steps {
+		if !s.OK {
+			t.Fatalf("step %s failed: %+v", s.Name, s)
+		}
+	}
+	if len(steps) != 7 {
+		t.Fatalf("expect 7 steps, got %+v", steps)
+	}
+	// 检查时创建的页面被归档
+	if n := fake.livePages(); n != 0 {
+		t.Fatalf("expect no live page, got %d", n)
+	}
+
+	fake.failNext(http.MethodPost, "/v1/pages", http.StatusBadRequest, 1)
+	steps = d.validate(context.Background())
+	failed := map[string]string{}
+	for _, s := range steps {
+		switch {
+		case s.Skipped:
+			failed[s.Name] = "skipped"
+		case !s.OK:
+			failed[s.Name] = "failed"
+		}
+	}
+	if len(failed) != 3 || failed["page_create"] != "failed" || failed["upload_url"] != "skipped" || failed["page_archive"] != "skipped" {
+		t.Fatalf("unexpected results %v", failed)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	conf.Conf.MasterKey = "master"
+	defer func() { conf.Conf.MasterKey = "" }()
+	aead, err := secretAEAD()
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := encryptSecret(aead, "secret_test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(token, secretPrefix) || strings.Contains(token, "secret_test") {
+		t.Fatalf("unexpected ciphertext %s", token)
+	}
+
+	fake := newFakeNotion(t)
+	// 加密和明文的配置项都在内存中解密为明文，Addition中只有密文
+	d := newTestNotion(t, fake, func(d *Notion) { d.NotionToken = token })
+	if d.secret.notionToken != "secret_test" || d.secret.notionCookie != "notion_user_id=test-user" {
+		t.Fatalf("expect decrypted credentials, got %q %q", d.secret.notionToken, d.secret.notionCookie)
+	}
+	if d.NotionToken != token || !strings.HasPrefix(d.NotionCookie, secretPrefix) {
+		t.Fatalf("expect encrypted credentials in the addition, got %q %q", d.NotionToken, d.NotionCookie)
+	}
+
+	conf.Conf.MasterKey = "other"
+	d = &Notion{Addition: Addition{NotionToken: token}}
+	if err := d.loadSecrets(); err == nil {
+		t.Fatal("expect decrypting with another master key to fail")
+	}
+	conf.Conf.MasterKey = ""
+	if err := d.loadSecrets(); err == nil {
+		t.Fatal("expect an encrypted token without master key to fail")
+	}
+}
+
+func TestSecretRefs(t *testing.T) {
+	t.Setenv("NOTION_TEST_TOKEN", "secret_test")
+	cookie := filepath.Join(t.TempDir(), "cookie")
+	if err := os.WriteFile(cookie, []byte("notion_user_id=test-user\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.NotionToken = "env:NOTION_TEST_TOKEN"
+		d.NotionCookie = "file:" + cookie
+	})
+	if d.secret.notionToken != "secret_test" || d.secret.notionCookie != "notion_user_id=test-user" {
+		t.Fatalf("expect resolved credentials, got %q %q", d.secret.notionToken, d.secret.notionCookie)
+	}
+	if d.NotionToken != "env:NOTION_TEST_TOKEN" {
+		t.Fatalf("expect the reference kept in the addition, got %q", d.NotionToken)
+	}
+
+	d = &Notion{Addition: Addition{DBPass: "env:NOTION_TEST_MISSING"}}
+	if err := d.loadSecrets(); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.resolveRefs(); err == nil {
+		t.Fatal("expect a missing environment variable to fail")
+	}
+}
+
+func TestSecretsSaved(t *testing.T) {
+	dB, err := gorm.Open(sqlite.Open("file:TestSecretsSaved_storages?mode=memory&cache=shared"), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	conf.Conf.MasterKey = "master"
+	defer func() { conf.Conf.MasterKey = "" }()
+	db.Init(dB)
+	t.Setenv("NOTION_TEST_SHARE", "share_test")
+
+	// 元数据数据库无法连接，挂载失败时存储也会保存
+	ctx := context.Background()
+	id, _ := op.CreateStorage(ctx, model.Storage{Driver: "Notion", MountPath: "/secrets",
+		Addition: `{"notion_cookie":"notion_user_id=test-user","notion_token":"secret_test","share_secret":"env:NOTION_TEST_SHARE",` +
+			`"db_host":"127.0.0.1","db_port":"1"}`})
+	check := func() *model.Storage {
+		storage, err := db.GetStorageById(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var addition Addition
+		if err := utils.Json.UnmarshalFromString(storage.Addition, &addition); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(addition.NotionToken, secretPrefix) || !strings.HasPrefix(addition.NotionCookie, secretPrefix) {
+			t.Fatalf("expect encrypted credentials in the database, got %s", storage.Addition)
+		}
+		if addition.ShareSecret != "env:NOTION_TEST_SHARE" {
+			t.Fatalf("expect the reference kept in the database, got %s", storage.Addition)
+		}
+		d, err := op.GetStorageByMountPath("/secrets")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s := d.(*Notion).secret; s.notionToken != "secret_test" || s.shareSecret != "share_test" {
+			t.Fatal("expect the decrypted token and the resolved secret in memory")
+		}
+		return storage
+	}
+	storage := check()
+	// 重新加载时读取密文，保存的仍是密文
+	_ = op.LoadStorage(ctx, *storage)
+	check()
+}
+
+func TestUserHome(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.UserHome = true })
+	userCtx := func(name string, role int) context.Context {
+		return context.WithValue(context.Background(), "user", &model.User{Username: name, Role: role})
+	}
+	alice, bob, admin := userCtx("alice", model.GENERAL), userCtx("bob", model.GENERAL), userCtx("admin", model.ADMIN)
+	if !d.Config().NoCache {
+		t.Fatal("expect the list cache to be disabled")
+	}
+	if d.UserScope(alice) != "alice" || d.UserScope(admin) != "" || d.UserScope(context.Background()) != "" {
+		t.Fatal("unexpected user scopes")
+	}
+
+	aliceRoot, err := d.GetRoot(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Put(alice, aliceRoot, newTestStream("a.txt", []byte("alice")), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	bobRoot, err := d.GetRoot(bob)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if aliceRoot.GetID() == bobRoot.GetID() || aliceRoot.GetID() == d.RootFolderID {
+		t.Fatalf("expect separate homes, got %s and %s", aliceRoot.GetID(), bobRoot.GetID())
+	}
+	if names := listNames(t, d, bobRoot); len(names) != 0 {
+		t.Fatalf("bob sees %v", names)
+	}
+	if names := listNames(t, d, aliceRoot); len(names) != 1 || names[0] != "a.txt" {
+		t.Fatalf("alice sees %v", names)
+	}
+	// 再次访问时使用已有的目录
+	again, err := d.GetRoot(alice)
+	if err != nil || again.GetID() != aliceRoot.GetID() {
+		t.Fatalf("expect the same home, got %v, %v", again, err)
+	}
+
+	adminRoot, err := d.GetRoot(admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if adminRoot.GetID() != d.RootFolderID {
+		t.Fatalf("expect the storage root for admins, got %s", adminRoot.GetID())
+	}
+	if names := strings.Join(listNames(t, d, adminRoot), ","); names != "alice,bob" && names != "bob,alice" {
+		t.Fatalf("admin sees %s", names)
+	}
+
+	if _, err := d.Other(alice, model.OtherArgs{Obj: aliceRoot, Method: "stats"}); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect permission denied for stats, got %v", err)
+	}
+	if _, err := d.Other(admin, model.OtherArgs{Obj: adminRoot, Method: "stats"}); err != nil {
+		t.Fatalf("stats as admin: %v", err)
+	}
+}
+
+func TestRangeCoalescing(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+		d.ReaderKeepAlive = 10
+	})
+	data := testData(3 * 1024 * 1024)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks, err := d.listChunks(obj.GetID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	gets, props := fake.count(http.MethodGet, "/s3/"), fake.count(http.MethodGet, "/v1/pages")
+	// 顺序、重叠和略微跳过的小范围请求
+	var end int64
+	for start := int64(0); start < 1024*1024; {
+		if got := readRange(t, link, start, 64*1024); !bytes.Equal(got, data[start:start+64*1024]) {
+			t.Fatalf("content differs at %d", start)
+		}
+		end = start + 64*1024
+		switch start / (64 * 1024) % 3 {
+		case 0:
+			start = end
+		case 1:
+			start = end - 16*1024
+		default:
+			start = end + 8*1024
+		}
+	}
+	touched := 0
+	for _, c := range chunks {
+		if c.Start < end {
+			touched++
+		}
+	}
+	if n := fake.count(http.MethodGet, "/s3/") - gets; n > touched {
+		t.Fatalf("expect at most one download per chunk (%d), got %d", touched, n)
+	}
+	if n := fake.count(http.MethodGet, "/v1/pages") - props; n > touched {
+		t.Fatalf("expect the chunk urls to be cached, got %d page requests", n)
+	}
+
+	// 其他客户端和大范围请求不使用保留的读取器
+	other, err := d.Link(context.Background(), obj, model.LinkArgs{IP: "10.0.0.2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, other, 0, 4096); !bytes.Equal(got, data[:4096]) {
+		t.Fatal("content differs for another client")
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content differs for a full read")
+	}
+	if n := fake.count(http.MethodGet, "/v1/pages") - props; n > len(chunks) {
+		t.Fatalf("expect the chunk urls to be shared by links, got %d page requests", n)
+	}
+}
+
+// mp4Box 按MP4 box的格式包装payload
+func mp4Box(typ string, payload []byte) []byte {
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(payload)))
+	return append(append(box, typ...), payload...)
+}
+
+func TestVideoPrefetch(t *testing.T) {
+	ftyp := mp4Box("ftyp", []byte("isom\x00\x00\x02\x00isomiso2"))
+	moov := mp4Box("moov", testData(200*1024))
+	mdat := mp4Box("mdat", testData(3*1024*1024))
+	layouts := map[string][]byte{
+		"head.mp4": bytes.Join([][]byte{ftyp, moov, mdat}, nil),
+		"tail.mp4": bytes.Join([][]byte{ftyp, mdat, moov}, nil),
+	}
+	for name, data := range layouts {
+		fake := newFakeNotion(t)
+		d := newTestNotion(t, fake, func(d *Notion) {
+			d.ChunkMode = chunkModeCDC
+			d.CDCAvgSize = 1
+			d.VideoPrefetch = true
+		})
+		obj, err := d.Put(context.Background(), rootDir(d), newTestStream(name, data), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 开头的请求等待预读完成
+		if got := readRange(t, link, 0, 4096); !bytes.Equal(got, data[:4096]) {
+			t.Fatalf("%s: head differs", name)
+		}
+		moovStart := int64(bytes.Index(data, moov))
+		gets := fake.count(http.MethodGet, "/s3/")
+		if got := readRange(t, link, moovStart, int64(len(moov))); !bytes.Equal(got, moov) {
+			t.Fatalf("%s: moov differs", name)
+		}
+		if got := readRange(t, link, 100, 1000); !bytes.Equal(got, data[100:1100]) {
+			t.Fatalf("%s: head range differs", name)
+		}
+		if n := fake.count(http.MethodGet, "/s3/") - gets; n != 0 {
+			t.Fatalf("%s: expect the moov to be served from memory, got %d downloads", name, n)
+		}
+		// 超出预读内容的请求接着从分块读取
+		if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+			t.Fatalf("%s: full read differs", name)
+		}
+	}
+}
+
+func TestImageResize(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ImageResize = true
+		d.ResizeCacheSize = 8
+	})
+	var src bytes.Buffer
+	if err := imaging.Encode(&src, imaging.New(400, 300, color.NRGBA{R: 200, A: 255}), imaging.PNG); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.png", src.Bytes()), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	proxyArgs := func(query string) model.LinkArgs {
+		return model.LinkArgs{HttpReq: httptest.NewRequest(http.MethodGet, "/p/a.png?"+query, nil)}
+	}
+	args := proxyArgs("w=100&fmt=jpg")
+	link, err := d.Link(context.Background(), obj, args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.MFile == nil || link.Header.Get("Content-Type") != "image/jpeg" {
+		t.Fatalf("expect a resized jpeg, got %+v", link)
+	}
+	img, format, err := image.Decode(link.MFile)
+	if err != nil || format != "jpeg" || img.Bounds().Dx() != 100 || img.Bounds().Dy() != 75 {
+		t.Fatalf("expect a 100x75 jpeg, got %s %v, %v", format, img.Bounds(), err)
+	}
+	if d.LinkVariant(args) == d.LinkVariant(proxyArgs("w=200")) || d.LinkVariant(proxyArgs("")) != "" {
+		t.Fatal("expect a link variant per size")
+	}
+
+	// 缩放结果缓存在内存中
+	gets := fake.count(http.MethodGet, "/s3/")
+	if _, err := d.Link(context.Background(), obj, args); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count(http.MethodGet, "/s3/") - gets; n != 0 {
+		t.Fatalf("expect the resized image to be cached, got %d downloads", n)
+	}
+	// 不放大
+	link, err = d.Link(context.Background(), obj, proxyArgs("w=1000&h=1000"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg, _, err := image.DecodeConfig(link.MFile); err != nil || cfg.Width != 400 || cfg.Height != 300 {
+		t.Fatalf("expect the original size, got %+v, %v", cfg, err)
+	}
+	if _, err := d.Link(context.Background(), obj, proxyArgs("w=0")); err == nil {
+		t.Fatal("expect an error for an invalid width")
+	}
+	// 重定向时返回原图的地址
+	args.Redirect = true
+	if link, err := d.Link(context.Background(), obj, args); err != nil || link.URL == "" {
+		t.Fatalf("expect the url of the original, got %+v, %v", link, err)
+	}
+}
+
+func TestRehash(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	data := testData(10 * 1024)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sum := sha1.Sum(data)
+	want := hex.EncodeToString(sum[:])
+	// 追加或重建后的文件没有哈希
+	if err := d.db.Model(&File{}).Where("id = ?", obj.GetID()).Update("sha1", "").Error; err != nil {
+		t.Fatal(err)
+	}
+	res, err := d.Other(context.Background(), model.OtherArgs{Obj: rootDir(d), Method: "rehash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp := res.(*RehashResp); resp.Hashed != 1 || len(resp.Failed) != 0 {
+		t.Fatalf("unexpected result %+v", resp)
+	}
+	objs, err := d.List(context.Background(), rootDir(d), model.ListArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 || objs[0].GetHash().GetHash(utils.SHA1) != want {
+		t.Fatalf("expect the listed file to have sha1 %s, got %v", want, objs)
+	}
+	// 已有哈希的文件不再读取
+	res, err = d.Other(context.Background(), model.OtherArgs{Obj: rootDir(d), Method: "rehash"})
+	if err != nil || res.(*RehashResp).Hashed != 0 {
+		t.Fatalf("expect nothing to rehash, got %+v, %v", res, err)
+	}
+}
+
+func TestBatchUpdate(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	var ids []string
+	for _, name := range []string{"a.txt", "b.txt"} {
+		obj, err := d.Put(ctx, rootDir(d), newTestStream(name, testData(100)), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, obj.GetID())
+	}
+	dst, err := d.MakeDir(ctx, rootDir(d), "dst")
+	if err != nil {
+		t.Fatal(err)
+	}
+	batch := func(req BatchReq) (*BatchResp, error) {
+		res, err := d.Other(ctx, model.OtherArgs{Obj: rootDir(d), Method: "batch_update", Data: req})
+		if err != nil {
+			return nil, err
+		}
+		return res.(*BatchResp), nil
+	}
+	// 有一个文件不存在时不修改任何文件
+	if _, err := batch(BatchReq{Files: append(ids, "999999"), DstDirID: dst.GetID()}); err == nil {
+		t.Fatal("expect an error for a missing file")
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 3 {
+		t.Fatalf("expect nothing moved after rollback, got %v", names)
+	}
+	modified := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	resp, err := batch(BatchReq{Files: ids, Modified: &modified, Tags: map[string]string{"project": "x"}, DstDirID: dst.GetID()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.Updated != 2 {
+		t.Fatalf("expect 2 updated files, got %d", resp.Updated)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 {
+		t.Fatalf("expect only dst left in root, got %v", names)
+	}
+	objs, err := d.List(ctx, dst, model.ListArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 2 {
+		t.Fatalf("expect 2 files in dst, got %d", len(objs))
+	}
+	for _, obj := range objs {
+		if !obj.ModTime().Equal(modified) {
+			t.Fatalf("expect %s modified at %v, got %v", obj.GetName(), modified, obj.ModTime())
+		}
+		id, _ := strconv.Atoi(obj.GetID())
+		tags, err := d.tree.Tags(id)
+		if err != nil || tags["project"] != "x" {
+			t.Fatalf("expect %s tagged, got %v, %v", obj.GetName(), tags, err)
+		}
+	}
+}
+
+func TestAlias(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	file, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := d.MakeDir(ctx, rootDir(d), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := d.Other(ctx, model.OtherArgs{Obj: dir, Method: "make_alias", Data: AliasReq{TargetID: file.GetID(), Name: "b.bin"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alias := res.(model.Obj)
+	if target, ok := model.GetAliasTarget(alias); !ok || target != "/a.bin" {
+		t.Fatalf("expect an alias to /a.bin, got %q", target)
+	}
+	objs, err := d.List(ctx, dir, model.ListArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 1 || objs[0].GetName() != "b.bin" || objs[0].GetID() != file.GetID() || objs[0].GetSize() != 1024 {
+		t.Fatalf("expect the alias listed with the target, got %v", objs)
+	}
+	if _, err := d.Link(ctx, objs[0], model.LinkArgs{}); err != nil {
+		t.Fatalf("expect the alias to resolve to the target: %v", err)
+	}
+	// 目录的别名不能放在目录自身之中
+	if _, err := d.Other(ctx, model.OtherArgs{Obj: dir, Method: "make_alias", Data: AliasReq{TargetID: dir.GetID(), Dir: true}}); err == nil {
+		t.Fatal("expect an error aliasing a directory inside itself")
+	}
+	if _, err := d.Other(ctx, model.OtherArgs{Obj: dir, Method: "make_alias", Data: AliasReq{TargetID: file.GetID(), Name: "b.bin"}}); !errors.Is(err, errs.ObjectAlreadyExists) {
+		t.Fatalf("expect a name conflict, got %v", err)
+	}
+	renamed, err := d.Rename(ctx, objs[0], "c.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 2 {
+		t.Fatalf("expect the target untouched by the rename, got %v", names)
+	}
+	// 删除目标后别名不再列出，恢复后重新列出
+	if err := d.db.Model(&File{}).Where("id = ?", file.GetID()).Update("deleted", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	if names := listNames(t, d, dir); len(names) != 0 {
+		t.Fatalf("expect the alias of a removed target hidden, got %v", names)
+	}
+	if err := d.db.Model(&File{}).Where("id = ?", file.GetID()).Update("deleted", false).Error; err != nil {
+		t.Fatal(err)
+	}
+	if names := listNames(t, d, dir); len(names) != 1 || names[0] != "c.bin" {
+		t.Fatalf("expect the renamed alias back, got %v", names)
+	}
+	if err := d.Remove(ctx, renamed); err != nil {
+		t.Fatal(err)
+	}
+	if names := listNames(t, d, dir); len(names) != 0 {
+		t.Fatalf("expect the alias removed, got %v", names)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 2 {
+		t.Fatalf("expect the target kept after removing the alias, got %v", names)
+	}
+}
+
+func TestWebDAVLock(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+	if err := d.CreateLock(ctx, model.Lock{Token: "t1", Path: "/x/a.docx", ZeroDepth: true, Expires: expires}); err != nil {
+		t.Fatal(err)
+	}
+	for _, lock := range []model.Lock{
+		{Token: "t2", Path: "/x/a.docx", ZeroDepth: true, Expires: expires},
+		{Token: "t3", Path: "/x", Expires: expires},
+	} {
+		if err := d.CreateLock(ctx, lock); !errors.Is(err, errs.Locked) {
+			t.Fatalf("expect %s to conflict, got %v", lock.Path, err)
+		}
+	}
+	// 其他实例使用同一数据库时看到相同的锁
+	other := &Notion{tree: d.tree}
+	lock, err := other.GetLock(ctx, "t1")
+	if err != nil || lock.Path != "/x/a.docx" || !lock.ZeroDepth {
+		t.Fatalf("expect the lock shared, got %+v, %v", lock, err)
+	}
+	if err := d.CreateLock(ctx, model.Lock{Token: "t4", Path: "/x/b.docx", ZeroDepth: true, Expires: expires}); err != nil {
+		t.Fatalf("expect a lock on another file, got %v", err)
+	}
+	if _, err := d.RefreshLock(ctx, "t1", time.Now().Add(-time.Second)); err != nil {
+		t.Fatal(err)
+	}
+	// 过期的锁不再有效，也不阻止新的锁
+	if _, err := d.GetLock(ctx, "t1"); !errs.IsObjectNotFound(err) {
+		t.Fatalf("expect the expired lock not found, got %v", err)
+	}
+	if err := d.CreateLock(ctx, model.Lock{Token: "t5", Path: "/x/a.docx", ZeroDepth: true, Expires: expires}); err != nil {
+		t.Fatalf("expect the expired lock replaced, got %v", err)
+	}
+	if err := d.DeleteLock(ctx, "t5"); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.DeleteLock(ctx, "t5"); !errs.IsObjectNotFound(err) {
+		t.Fatalf("expect the lock deleted, got %v", err)
+	}
+}
+
+func TestWebDAVLockUserHome(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.UserHome = true })
+	userCtx := func(name string, role int) context.Context {
+		return context.WithValue(context.Background(), "user", &model.User{Username: name, Role: role})
+	}
+	alice, bob, admin := userCtx("alice", model.GENERAL), userCtx("bob", model.GENERAL), userCtx("admin", model.ADMIN)
+	expires := time.Now().Add(time.Hour)
+	// 不同用户目录中的相同路径不冲突
+	for i, ctx := range []context.Context{alice, bob} {
+		if err := d.CreateLock(ctx, model.Lock{Token: "t" + strconv.Itoa(i), Path: "/a.docx", ZeroDepth: true, Expires: expires}); err != nil {
+			t.Fatalf("expect the lock of each user, got %v", err)
+		}
+	}
+	if lock, err := d.GetLock(alice, "t0"); err != nil || lock.Path != "/a.docx" {
+		t.Fatalf("expect the lock in the home of alice, got %+v, %v", lock, err)
+	}
+	if lock, err := d.GetLock(admin, "t0"); err != nil || lock.Path != "/alice/a.docx" {
+		t.Fatalf("expect the admin to see the path in the storage, got %+v, %v", lock, err)
+	}
+	// 其他用户的锁视为不存在
+	if _, err := d.GetLock(bob, "t0"); !errs.IsObjectNotFound(err) {
+		t.Fatalf("expect the lock of alice not found by bob, got %v", err)
+	}
+	if err := d.DeleteLock(bob, "t0"); !errs.IsObjectNotFound(err) {
+		t.Fatalf("expect bob not to unlock the lock of alice, got %v", err)
+	}
+	if err := d.CreateLock(admin, model.Lock{Token: "t2", Path: "/alice", Expires: expires}); !errors.Is(err, errs.Locked) {
+		t.Fatalf("expect the lock of the home to conflict, got %v", err)
+	}
+}
+
+func TestConcurrentModification(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.txt", testData(100)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := d.MakeDir(ctx, rootDir(d), "x")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var file model.Obj
+	objs, err := d.List(ctx, rootDir(d), model.ListArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range objs {
+		if !obj.IsDir() {
+			file = obj
+		}
+	}
+	// 两个客户端列出了同一文件，第一个重命名后第二个的修改冲突
+	renamed, err := d.Rename(ctx, file, "b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Rename(ctx, file, "c.txt"); !errors.Is(err, errs.ObjectModified) {
+		t.Fatalf("expect a conflict renaming a stale object, got %v", err)
+	}
+	if _, err := d.Move(ctx, file, dir); !errors.Is(err, errs.ObjectModified) {
+		t.Fatalf("expect a conflict moving a stale object, got %v", err)
+	}
+	if err := d.Remove(ctx, file); !errors.Is(err, errs.ObjectModified) {
+		t.Fatalf("expect a conflict removing a stale object, got %v", err)
+	}
+	// 修改返回的对象带有新的版本
+	moved, err := d.Move(ctx, renamed, dir)
+	if err != nil {
+		t.Fatalf("expect the returned object to be current: %v", err)
+	}
+	y, err := d.Rename(ctx, dir, "y")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(ctx, dir); !errors.Is(err, errs.ObjectModified) {
+		t.Fatalf("expect a conflict removing a stale directory, got %v", err)
+	}
+	if err := d.Remove(ctx, moved); err != nil {
+		t.Fatal(err)
+	}
+	// 别名的重命名和移动同样检查版本，连续的修改即使在同一毫秒内也能区分
+	target, err := d.Put(ctx, rootDir(d), newTestStream("t.txt", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := d.Other(ctx, model.OtherArgs{Obj: rootDir(d), Method: "make_alias", Data: AliasReq{TargetID: target.GetID(), Name: "l.txt"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alias := res.(model.Obj)
+	renamedAlias, err := d.Rename(ctx, alias, "m.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Rename(ctx, alias, "n.txt"); !errors.Is(err, errs.ObjectModified) {
+		t.Fatalf("expect a conflict renaming a stale alias, got %v", err)
+	}
+	if _, err := d.Move(ctx, alias, y); !errors.Is(err, errs.ObjectModified) {
+		t.Fatalf("expect a conflict moving a stale alias, got %v", err)
+	}
+	if _, err := d.Move(ctx, renamedAlias, y); err != nil {
+		t.Fatalf("expect the returned alias to be current: %v", err)
+	}
+}
+
+func TestMySQLOffset(t *testing.T) {
+	dryDB, sqls := newDryRunMySQL(t)
+	d := &Notion{Addition: Addition{VersionRetention: 3}}
+	if err := d.pruneVersions(dryDB, 1); err != nil {
+		t.Fatal(err)
+	}
+	// MySQL中OFFSET必须跟在LIMIT之后
+	d.SnapshotRetention = 2
+	if _, err := d.expiredSnapshots(dryDB); err != nil {
+		t.Fatal(err)
+	}
+	if len(*sqls) != 2 || !strings.Contains((*sqls)[0], "LIMIT ? OFFSET ?") || !strings.Contains((*sqls)[1], "LIMIT ? OFFSET ?") {
+		t.Fatalf("unexpected queries %q", *sqls)
+	}
+}
+
+func TestUploadSHA1(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	data := testData(10 * 1024)
+	put := func(name, sha1 string) (model.Obj, error) {
+		s := newTestStream(name, data).(*stream.FileStream)
+		s.Obj = &model.Object{Name: name, Size: int64(len(data)), Modified: time.Now(),
+			HashInfo: utils.NewHashInfo(utils.SHA1, sha1)}
+		return d.Put(context.Background(), rootDir(d), s, func(float64) {})
+	}
+	// 提供的SHA-1与内容不一致时上传失败
+	if _, err := put("a.bin", strings.Repeat("0", 40)); err == nil {
+		t.Fatal("expect a wrong sha1 to fail the upload")
+	}
+	sum := sha1.Sum(data)
+	want := hex.EncodeToString(sum[:])
+	obj, err := put("b.bin", strings.ToUpper(want))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := obj.GetHash().GetHash(utils.SHA1); got != want {
+		t.Fatalf("expect sha1 %s, got %s", want, got)
+	}
+}
+
+func TestCorruptedCopy(t *testing.T) {
+	for name, configure := range map[string]func(d *Notion){
+		"single":  nil,
+		"inline":  func(d *Notion) { d.InlineSize = 64 },
+		"packed":  func(d *Notion) { d.PackSize = 64 },
+		"chunked": func(d *Notion) { d.EncryptionKey = "key" },
+	} {
+		t.Run(name, func(t *testing.T) {
+			d := newTestNotion(t, newFakeNotion(t), configure)
+			data := testData(10 * 1024)
+			sum := sha1.Sum(data)
+			// 源存储的哈希是原内容的，收到的内容在传输中损坏
+			corrupted := bytes.Clone(data)
+			corrupted[100] ^= 0xff
+			s := newTestStream("a.bin", corrupted).(*stream.FileStream)
+			s.Obj = &model.Object{Name: "a.bin", Size: int64(len(data)), Modified: time.Now(),
+				HashInfo: utils.NewHashInfo(utils.SHA1, hex.EncodeToString(sum[:]))}
+			s.TrustedHash = true
+			if _, err := d.Put(context.Background(), rootDir(d), s, func(float64) {}); err == nil {
+				t.Fatal("expect a corrupted copy to fail")
+			}
+			if names := listNames(t, d, rootDir(d)); len(names) != 0 {
+				t.Fatalf("expect no file left, got %v", names)
+			}
+		})
+	}
+}
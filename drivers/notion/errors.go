@@ -0,0 +1,51 @@
+package notion
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"gorm.io/gorm"
+)
+
+// quotaMarkers 响应中表示超出空间或单个文件大小限制的内容，S3为EntityTooLarge
+var quotaMarkers = []string{"entitytoolarge", "too large", "storage limit", "exceeds", "upgrade"}
+
+// apiError 将Notion接口的失败状态码转换为errs中的错误，使alist返回对应的状态码，
+// 无法对应的状态码只保留原始信息；msg为失败操作的前缀
+func (l language) apiError(msg msgCode, code int, body string) error {
+	var typed error
+	switch code {
+	case http.StatusNotFound:
+		typed = errs.ObjectNotFound
+	case http.StatusUnauthorized, http.StatusForbidden:
+		typed = errs.PermissionDenied
+	case http.StatusTooManyRequests:
+		typed = errs.TooManyRequests
+	case http.StatusRequestEntityTooLarge:
+		typed = errs.QuotaExceeded
+	case http.StatusBadRequest:
+		lower := strings.ToLower(body)
+		for _, marker := range quotaMarkers {
+			if strings.Contains(lower, marker) {
+				typed = errs.QuotaExceeded
+				break
+			}
+		}
+	}
+	err := l.errorf(msgAPIStatus, l.text(msg), code, body)
+	if typed == nil {
+		return err
+	}
+	return fmt.Errorf("%w: %w", err, typed)
+}
+
+// dbError 记录不存在时转换为errs.ObjectNotFound
+func (l language) dbError(msg msgCode, err error) error {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return l.errorf(msg, errs.ObjectNotFound)
+	}
+	return l.errorf(msg, err)
+}
@@ -0,0 +1,77 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"gorm.io/gorm"
+)
+
+func TestAPIError(t *testing.T) {
+	l := language(defaultLanguage)
+	for _, c := range []struct {
+		code int
+		body string
+		want error
+	}{
+		{http.StatusNotFound, "", errs.ObjectNotFound},
+		{http.StatusUnauthorized, "", errs.PermissionDenied},
+		{http.StatusForbidden, "", errs.PermissionDenied},
+		{http.StatusTooManyRequests, "", errs.TooManyRequests},
+		{http.StatusRequestEntityTooLarge, "", errs.QuotaExceeded},
+		{http.StatusBadRequest, "<Code>EntityTooLarge</Code>", errs.QuotaExceeded},
+		{http.StatusBadRequest, `{"message":"The file exceeds the limit"}`, errs.QuotaExceeded},
+	} {
+		err := l.apiError(msgAPIUpload, c.code, c.body)
+		if !errors.Is(err, c.want) {
+			t.Errorf("%d %s: expect %v, got %v", c.code, c.body, c.want, err)
+		}
+		// 原始的状态码和响应保留在信息中
+		if c.body != "" && !strings.Contains(err.Error(), c.body) {
+			t.Errorf("%d: expect the body kept, got %v", c.code, err)
+		}
+	}
+	// 无法对应的状态码不包装errs中的错误
+	for _, code := range []int{http.StatusBadRequest, http.StatusInternalServerError} {
+		err := l.apiError(msgAPIUpload, code, "invalid")
+		for _, typed := range []error{errs.ObjectNotFound, errs.PermissionDenied, errs.TooManyRequests, errs.QuotaExceeded} {
+			if errors.Is(err, typed) {
+				t.Errorf("%d: expect an untyped error, got %v", code, err)
+			}
+		}
+	}
+}
+
+func TestDBError(t *testing.T) {
+	l := language(defaultLanguage)
+	if err := l.dbError(msgGetFile, gorm.ErrRecordNotFound); !errs.IsObjectNotFound(err) {
+		t.Fatalf("expect a missing record not found, got %v", err)
+	}
+	cause := errors.New("connection refused")
+	if err := l.dbError(msgGetFile, cause); !errors.Is(err, cause) || errs.IsObjectNotFound(err) {
+		t.Fatalf("expect the database error wrapped, got %v", err)
+	}
+}
+
+// TestTypedErrors 驱动返回的错误可以用errs判断
+func TestTypedErrors(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	ctx := context.Background()
+	if _, err := d.Link(ctx, &model.Object{ID: "404"}, model.LinkArgs{}); !errs.IsObjectNotFound(err) {
+		t.Fatalf("expect an unknown file not found, got %v", err)
+	}
+	fake.failNext(http.MethodPost, "/v1/pages", http.StatusUnauthorized, 1)
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(10)), func(float64) {}); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect a refused page creation denied, got %v", err)
+	}
+	fake.failNext(http.MethodPut, "/s3/", http.StatusRequestEntityTooLarge, 1)
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("b.bin", testData(10)), func(float64) {}); !errors.Is(err, errs.QuotaExceeded) {
+		t.Fatalf("expect a too large upload over the quota, got %v", err)
+	}
+}
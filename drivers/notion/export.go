@@ -0,0 +1,75 @@
+package notion
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// ListEntry 导出清单中的一个文件，Path为相对于导出目录的路径
+type ListEntry struct {
+	Path     string    `json:"path"`
+	Size     int64     `json:"size"`
+	SHA1     string    `json:"sha1"`
+	Modified time.Time `json:"modified"`
+	Chunked  bool      `json:"chunked"`
+}
+
+// ExportList 按目录和名称的顺序导出目录下filter包含的文件的清单，边查询边写入，不整体加载到内存；
+// csv第一行为表头，json为对象数组
+func (d *Notion) ExportList(ctx context.Context, dir model.Obj, format string, filter model.WalkFilter, w io.Writer) error {
+	dirID, _ := strconv.Atoi(dir.GetID())
+	include := walkFilter(filter)
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		if err := cw.Write([]string{"path", "size", "sha1", "modified", "chunked"}); err != nil {
+			return err
+		}
+		err := d.tree.WalkFiles(dirID, func(p string, f *File) error {
+			if err := ctx.Err(); err != nil || !include(p) {
+				return err
+			}
+			return cw.Write([]string{p, strconv.FormatInt(f.Size, 10), f.SHA1,
+				f.UpdatedAt.UTC().Format(time.RFC3339), strconv.FormatBool(f.IsChunked)})
+		})
+		if err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		if _, err := io.WriteString(w, "["); err != nil {
+			return err
+		}
+		sep := "\n"
+		err := d.tree.WalkFiles(dirID, func(p string, f *File) error {
+			if err := ctx.Err(); err != nil || !include(p) {
+				return err
+			}
+			data, err := utils.Json.Marshal(ListEntry{Path: p, Size: f.Size, SHA1: f.SHA1, Modified: f.UpdatedAt, Chunked: f.IsChunked})
+			if err != nil {
+				return err
+			}
+			if _, err := io.WriteString(w, sep); err != nil {
+				return err
+			}
+			sep = ",\n"
+			_, err = w.Write(data)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		_, err = io.WriteString(w, "\n]\n")
+		return err
+	default:
+		return fmt.Errorf("不支持的导出格式: %s", format)
+	}
+}
@@ -0,0 +1,101 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestExportList(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	docs, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Put(ctx, docs, newTestStream("b,1.txt", testData(10)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", testData(3<<20)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	empty, err := d.MakeDir(ctx, rootDir(d), "empty")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// csv第一行为表头，包含逗号的名称被引用
+	var buf bytes.Buffer
+	if err := d.ExportList(ctx, rootDir(d), "csv", nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	rows, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || strings.Join(rows[0], ",") != "path,size,sha1,modified,chunked" {
+		t.Fatalf("expect a header and 2 files, got %v", rows)
+	}
+	paths := map[string][]string{}
+	for _, row := range rows[1:] {
+		paths[row[0]] = row
+	}
+	if row := paths["docs/b,1.txt"]; row == nil || row[1] != "10" || row[2] != sha1Hex(testData(10)) || row[4] != "false" {
+		t.Fatalf("expect docs/b,1.txt of 10 bytes with its sha1, got %v", rows)
+	}
+	if row := paths["big.bin"]; row == nil || row[1] != "3145728" || row[4] != "true" {
+		t.Fatalf("expect the chunked big.bin, got %v", rows)
+	}
+
+	buf.Reset()
+	if err := d.ExportList(ctx, docs, "json", nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	var entries []ListEntry
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	// 路径相对于导出的目录
+	if len(entries) != 1 || entries[0].Path != "b,1.txt" || entries[0].Size != 10 || entries[0].Modified.IsZero() {
+		t.Fatalf("expect b,1.txt relative to docs, got %+v", entries)
+	}
+
+	// 空目录导出为空数组
+	buf.Reset()
+	if err := d.ExportList(ctx, empty, "json", nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(buf.Bytes(), &entries); err != nil || len(entries) != 0 {
+		t.Fatalf("expect an empty array, got %q %v", buf.String(), err)
+	}
+	if err := d.ExportList(ctx, docs, "xml", nil, &buf); err == nil {
+		t.Fatal("expect an unknown format refused")
+	}
+}
+
+func TestExportListFilter(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	for _, name := range []string{"public", "private"} {
+		dir, err := d.MakeDir(ctx, rootDir(d), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Put(ctx, dir, newTestStream("a.txt", []byte(name)), func(float64) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var buf bytes.Buffer
+	if err := d.ExportList(ctx, rootDir(d), "csv", func(p string) bool { return p != "private" }, &buf); err != nil {
+		t.Fatal(err)
+	}
+	if out := buf.String(); !strings.Contains(out, "public/a.txt") || strings.Contains(out, "private") {
+		t.Fatalf("expect only the accessible files, got %s", out)
+	}
+}
@@ -0,0 +1,492 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/google/uuid"
+	"gorm.io/driver/mysql"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+const (
+	fakeDatabaseID = "db-test"
+	fakeFileProp   = "file%3Aprop"
+)
+
+// fakeNotion 模拟Notion的网页API（/api/v3）、公开API（/v1）和保存附件的S3（/s3/），
+// 页面和附件都保存在内存中，可以让指定的请求返回错误以测试重试
+type fakeNotion struct {
+	*httptest.Server
+
+	mu    sync.Mutex
+	pages map[string]*fakePage
+	// objects S3中的对象，键为上传地址中的key
+	objects map[string][]byte
+	// formUpload 为true时getUploadFileUrl不返回signedPutUrl，上传改为表单POST
+	formUpload bool
+	// fails 按"方法 路径前缀"排队的状态码，匹配的请求依次返回这些状态码而不处理
+	fails map[string][]int
+	// requests 按"方法 路径"统计的请求数，包括返回错误的请求
+	requests map[string]int
+	// contentTypes 按附件名称记录的上传ContentType
+	contentTypes map[string]string
+	// uploads 公开API文件上传接口创建的上传对象
+	uploads map[string]*fakeUpload
+	// objectAttrs 按对象key记录上传时的存储类型和标签
+	objectAttrs map[string][2]string
+	// badETags 之后保存的这么多个对象返回错误的ETag，模拟传输中损坏的上传
+	badETags int
+}
+
+type fakePage struct {
+	title    string
+	archived bool
+	created  time.Time
+	files    []FileObject
+	// database 创建页面时的父数据库
+	database string
+	// props 通过公开API写入的rich_text属性的文本
+	props map[string]string
+}
+
+func newFakeNotion(t *testing.T) *fakeNotion {
+	f := &fakeNotion{
+		pages:        make(map[string]*fakePage),
+		objects:      make(map[string][]byte),
+		fails:        make(map[string][]int),
+		requests:     make(map[string]int),
+		contentTypes: make(map[string]string),
+		uploads:      make(map[string]*fakeUpload),
+		objectAttrs:  make(map[string][2]string),
+	}
+	f.Server = httptest.NewServer(http.HandlerFunc(f.serve))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// failNext 让之后n个方法为method、路径以prefix开头的请求返回status
+func (f *fakeNotion) failNext(method, prefix string, status, n int) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	key := method + " " + prefix
+	for i := 0; i < n; i++ {
+		f.fails[key] = append(f.fails[key], status)
+	}
+}
+
+// count 返回方法为method、路径以prefix开头的请求数
+func (f *fakeNotion) count(method, prefix string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for key, c := range f.requests {
+		m, p, _ := strings.Cut(key, " ")
+		if m == method && strings.HasPrefix(p, prefix) {
+			n += c
+		}
+	}
+	return n
+}
+
+// livePages 返回未归档的页面数
+func (f *fakeNotion) livePages() int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, p := range f.pages {
+		if !p.archived {
+			n++
+		}
+	}
+	return n
+}
+
+func (f *fakeNotion) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.requests[r.Method+" "+r.URL.Path]++
+	for key, statuses := range f.fails {
+		method, prefix, _ := strings.Cut(key, " ")
+		if len(statuses) == 0 || method != r.Method || !strings.HasPrefix(r.URL.Path, prefix) {
+			continue
+		}
+		f.fails[key] = statuses[1:]
+		f.mu.Unlock()
+		_, _ = io.Copy(io.Discard, r.Body)
+		if statuses[0] == http.StatusTooManyRequests {
+			w.Header().Set("Retry-After", "0")
+		}
+		http.Error(w, "injected failure", statuses[0])
+		return
+	}
+	f.mu.Unlock()
+
+	path := r.URL.Path
+	switch {
+	case r.Method == http.MethodPost && path == "/api/v3/getUploadFileUrl":
+		f.getUploadFileURL(w, r)
+	case r.Method == http.MethodPost && path == "/api/v3/saveTransactionsFanout":
+		f.saveTransactions(w, r)
+	case r.Method == http.MethodPost && strings.HasPrefix(path, "/v1/file_uploads"):
+		f.fileUpload(w, r, strings.TrimPrefix(strings.TrimPrefix(path, "/v1/file_uploads"), "/"))
+	case r.Method == http.MethodPost && path == "/v1/pages":
+		f.createPage(w, r)
+	case r.Method == http.MethodPatch && strings.HasPrefix(path, "/v1/pages/"):
+		f.patchPage(w, r, strings.TrimPrefix(path, "/v1/pages/"))
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/v1/pages/"):
+		f.getProperty(w, r, strings.TrimPrefix(path, "/v1/pages/"))
+	case strings.HasPrefix(path, "/v1/databases/") && strings.HasSuffix(path, "/query"):
+		f.queryDatabase(w, r)
+	case strings.HasPrefix(path, "/v1/databases/"):
+		writeJSON(w, map[string]interface{}{"object": "database", "id": fakeDatabaseID})
+	case r.Method == http.MethodPut && strings.HasPrefix(path, "/s3/"):
+		f.putObject(w, r, strings.TrimPrefix(path, "/s3/"))
+	case r.Method == http.MethodPost && path == "/s3/":
+		f.postObject(w, r)
+	case r.Method == http.MethodGet && strings.HasPrefix(path, "/s3/"):
+		f.getObject(w, r, strings.TrimPrefix(path, "/s3/"))
+	default:
+		http.Error(w, "unexpected request "+r.Method+" "+path, http.StatusNotFound)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func (f *fakeNotion) getUploadFileURL(w http.ResponseWriter, r *http.Request) {
+	var req UploadFileRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := uuid.NewString() + "/" + req.Name
+	f.mu.Lock()
+	f.contentTypes[req.Name] = req.ContentType
+	f.mu.Unlock()
+	res := UploadResponse{
+		Type:   "POST",
+		URL:    f.URL + "/s3/" + key,
+		Fields: UploadFields{ContentType: req.ContentType, XAmzStorageClass: "STANDARD", Key: key},
+	}
+	f.mu.Lock()
+	form := f.formUpload
+	f.mu.Unlock()
+	if !form {
+		res.Type, res.SignedPutUrl = "PUT", res.URL
+	}
+	writeJSON(w, res)
+}
+
+// saveTransactions 按UpdateFileList的操作设置页面的附件
+func (f *fakeNotion) saveTransactions(w http.ResponseWriter, r *http.Request) {
+	var req UpdateFileStatusRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, tx := range req.Transactions {
+		for _, op := range tx.Ops {
+			if op.Command != "set" || len(op.Path) != 2 || op.Path[0] != "properties" {
+				continue
+			}
+			page, ok := f.pages[op.Pointer.ID]
+			if !ok {
+				http.Error(w, "no such page "+op.Pointer.ID, http.StatusNotFound)
+				return
+			}
+			files, err := parseFileValue(op.Args)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			page.files = files
+		}
+	}
+	writeJSON(w, map[string]interface{}{})
+}
+
+// parseFileValue 解析文件属性的值，附件为[名称, [["a", 地址]]]，附件之间以[","]分隔
+func parseFileValue(args interface{}) ([]FileObject, error) {
+	items, ok := args.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("unexpected value %v", args)
+	}
+	var files []FileObject
+	for _, item := range items {
+		parts, ok := item.([]interface{})
+		if !ok || len(parts) == 1 {
+			continue
+		}
+		name, _ := parts[0].(string)
+		links, _ := parts[1].([]interface{})
+		if len(links) == 0 {
+			return nil, fmt.Errorf("attachment %s has no url", name)
+		}
+		link, _ := links[0].([]interface{})
+		if len(link) != 2 {
+			return nil, fmt.Errorf("attachment %s has no url", name)
+		}
+		url, _ := link[1].(string)
+		files = append(files, FileObject{Type: "file", Name: name, File: NotionFile{URL: url}})
+	}
+	return files, nil
+}
+
+func (f *fakeNotion) createPage(w http.ResponseWriter, r *http.Request) {
+	var req CreatePageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	page := &fakePage{created: time.Now(), database: req.Parent.DatabaseID}
+	if t := req.Properties.Title.Title; len(t) > 0 {
+		page.title = t[0].Text.Content
+	}
+	id := uuid.NewString()
+	f.mu.Lock()
+	f.pages[id] = page
+	f.mu.Unlock()
+	writeJSON(w, CreatePageResponse{ID: id})
+}
+
+func (f *fakeNotion) patchPage(w http.ResponseWriter, r *http.Request, id string) {
+	var req struct {
+		Archived   *bool                      `json:"archived"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	page, ok := f.pages[id]
+	if !ok {
+		http.Error(w, "no such page", http.StatusNotFound)
+		return
+	}
+	if req.Archived != nil {
+		page.archived = *req.Archived
+	}
+	for name, raw := range req.Properties {
+		if name == fakeFileProp {
+			if err := f.attachUploads(page, raw); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			continue
+		}
+		if name == "Title" {
+			var title TitleProperty
+			if err := json.Unmarshal(raw, &title); err == nil && len(title.Title) > 0 {
+				page.title = title.Title[0].Text.Content
+			}
+			continue
+		}
+		var text struct {
+			RichText []RichText `json:"rich_text"`
+		}
+		if err := json.Unmarshal(raw, &text); err != nil || text.RichText == nil {
+			continue
+		}
+		var sb strings.Builder
+		for _, t := range text.RichText {
+			sb.WriteString(t.Text.Content)
+		}
+		if page.props == nil {
+			page.props = make(map[string]string)
+		}
+		page.props[name] = sb.String()
+	}
+	writeJSON(w, map[string]interface{}{"id": id})
+}
+
+func (f *fakeNotion) getProperty(w http.ResponseWriter, r *http.Request, rest string) {
+	id, _, _ := strings.Cut(rest, "/")
+	f.mu.Lock()
+	page, ok := f.pages[id]
+	var files []FileObject
+	if ok {
+		files = append(files, page.files...)
+	}
+	f.mu.Unlock()
+	if !ok {
+		http.Error(w, "no such page", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, PropertyResponse{Object: "property_item", Type: "files", Files: files})
+}
+
+func (f *fakeNotion) queryDatabase(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	res := QueryDatabaseResponse{Results: make([]QueriedPage, 0, len(f.pages))}
+	for id, page := range f.pages {
+		if page.archived {
+			continue
+		}
+		props := make(map[string]RichTextValue)
+		for name, text := range page.props {
+			var v RichTextValue
+			v.RichText = append(v.RichText, struct {
+				PlainText string `json:"plain_text"`
+			}{text})
+			props[name] = v
+		}
+		res.Results = append(res.Results, QueriedPage{ID: id, CreatedTime: page.created, Properties: props})
+	}
+	f.mu.Unlock()
+	writeJSON(w, res)
+}
+
+// storeObject 保存对象，像S3一样返回内容MD5的ETag
+func (f *fakeNotion) storeObject(w http.ResponseWriter, key string, data []byte, status int) {
+	f.mu.Lock()
+	f.objects[key] = data
+	sum := md5.Sum(data)
+	if f.badETags > 0 {
+		f.badETags--
+		sum[0]++
+	}
+	f.mu.Unlock()
+	w.Header().Set("ETag", `"`+hex.EncodeToString(sum[:])+`"`)
+	w.WriteHeader(status)
+}
+
+func (f *fakeNotion) putObject(w http.ResponseWriter, r *http.Request, key string) {
+	data, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if r.ContentLength != int64(len(data)) {
+		http.Error(w, "content length mismatch", http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.objectAttrs[key] = [2]string{r.Header.Get("x-amz-storage-class"), r.Header.Get("x-amz-tagging")}
+	f.mu.Unlock()
+	f.storeObject(w, key, data, http.StatusOK)
+}
+
+func (f *fakeNotion) postObject(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseMultipartForm(32 << 20); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	f.mu.Lock()
+	f.objectAttrs[r.FormValue("key")] = [2]string{r.FormValue("x-amz-storage-class"), r.FormValue("tagging")}
+	f.mu.Unlock()
+	f.storeObject(w, r.FormValue("key"), data, http.StatusNoContent)
+}
+
+func (f *fakeNotion) getObject(w http.ResponseWriter, r *http.Request, key string) {
+	f.mu.Lock()
+	data, ok := f.objects[key]
+	f.mu.Unlock()
+	if !ok {
+		http.Error(w, "no such key", http.StatusNotFound)
+		return
+	}
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(data))
+}
+
+// newTestNotion 创建连接到假服务器和内存SQLite数据库的存储，configure在Init之前修改配置
+func newTestNotion(t *testing.T, fake *fakeNotion, configure func(d *Notion)) *Notion {
+	d := &Notion{
+		Addition: Addition{
+			NotionCookie:     "notion_user_id=test-user",
+			NotionToken:      "secret_test",
+			NotionSpaceID:    "space-test",
+			NotionDatabaseID: fakeDatabaseID,
+			NotionFilePageID: fakeFileProp,
+			APIBaseURL:       fake.URL + "/api/v3",
+			PublicAPIBaseURL: fake.URL + "/v1",
+			S3BaseURL:        fake.URL + "/s3/",
+			Normalization:    "none",
+			PackCount:        50,
+		},
+		// 每个测试使用独立的内存数据库
+		dialector: sqlite.Open(fmt.Sprintf("file:%s?mode=memory&cache=shared", strings.ReplaceAll(t.Name(), "/", "_"))),
+	}
+	d.MountPath = "/notion"
+	if configure != nil {
+		configure(d)
+	}
+	if err := d.Init(context.Background()); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+	t.Cleanup(func() {
+		_ = d.Drop(context.Background())
+		if db, err := d.db.DB(); err == nil {
+			_ = db.Close()
+		}
+	})
+	return d
+}
+
+// memFile 可重复读取的内存文件，上传失败后可以重试
+type memFile struct {
+	*bytes.Reader
+}
+
+func (memFile) Close() error { return nil }
+
+func newTestStream(name string, data []byte) model.FileStreamer {
+	return &stream.FileStream{
+		Obj:    &model.Object{Name: name, Size: int64(len(data)), Modified: time.Now()},
+		Reader: memFile{bytes.NewReader(data)},
+	}
+}
+
+func testData(size int) []byte {
+	// 固定种子的随机内容，按内容切分的结果每次相同
+	data := make([]byte, size)
+	rand.New(rand.NewSource(int64(size))).Read(data)
+	return data
+}
+
+// newDryRunMySQL 不连接数据库、只生成MySQL语句的连接，返回执行过的查询语句
+func newDryRunMySQL(t *testing.T) (*gorm.DB, *[]string) {
+	db, err := gorm.Open(mysql.New(mysql.Config{DSN: "test:test@tcp(127.0.0.1:1)/test", SkipInitializeWithVersion: true}),
+		&gorm.Config{DryRun: true, DisableAutomaticPing: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var sqls []string
+	if err := db.Callback().Query().After("gorm:query").Register("test:record", func(db *gorm.DB) {
+		sqls = append(sqls, db.Statement.SQL.String())
+	}); err != nil {
+		t.Fatal(err)
+	}
+	return db, &sqls
+}
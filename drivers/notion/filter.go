@@ -0,0 +1,68 @@
+package notion
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+)
+
+// uploadFilter 上传文件的后缀和大小限制
+type uploadFilter struct {
+	// allow 非空时只允许这些后缀，block中的后缀总是拒绝，都为小写且不带点
+	allow []string
+	block []string
+	// maxSize 单个文件的最大字节数，0为不限制
+	maxSize int64
+}
+
+// parseExtList 解析逗号分隔的后缀列表，后缀可以带点，可以有多段，如tar.gz
+func parseExtList(s string) []string {
+	var res []string
+	for _, ext := range strings.Split(s, ",") {
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		if ext != "" {
+			res = append(res, ext)
+		}
+	}
+	return res
+}
+
+func (d *Notion) initUploadFilter() {
+	d.uploadFilter = uploadFilter{
+		allow:   parseExtList(d.UploadAllowExt),
+		block:   parseExtList(d.UploadBlockExt),
+		maxSize: int64(d.MaxUploadSize) * 1024 * 1024,
+	}
+}
+
+// hasExt 文件名是否以列表中的某个后缀结尾
+func hasExt(name string, exts []string) bool {
+	for _, ext := range exts {
+		if strings.HasSuffix(name, "."+ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkUpload 检查上传文件的后缀和大小
+func (d *Notion) checkUpload(name string, size int64) error {
+	lower := strings.ToLower(filepath.Base(name))
+	if len(d.uploadFilter.allow) > 0 && !hasExt(lower, d.uploadFilter.allow) {
+		return fmt.Errorf("不允许上传文件[%s]，只允许的后缀: %s: %w", name, strings.Join(d.uploadFilter.allow, ","), errs.PermissionDenied)
+	}
+	if hasExt(lower, d.uploadFilter.block) {
+		return fmt.Errorf("不允许上传文件[%s]，禁止的后缀: %s: %w", name, strings.Join(d.uploadFilter.block, ","), errs.PermissionDenied)
+	}
+	return d.checkUploadSize(name, size)
+}
+
+// checkUploadSize 检查文件大小是否超过限制，追加时为追加后的大小
+func (d *Notion) checkUploadSize(name string, size int64) error {
+	if d.uploadFilter.maxSize > 0 && size > d.uploadFilter.maxSize {
+		return fmt.Errorf("文件[%s]的大小%d超过了上传限制%d: %w", name, size, d.uploadFilter.maxSize, errs.QuotaExceeded)
+	}
+	return nil
+}
@@ -0,0 +1,83 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+)
+
+func TestParseExtList(t *testing.T) {
+	if got := strings.Join(parseExtList(" .JPG, png,,tar.gz ,"), ","); got != "jpg,png,tar.gz" {
+		t.Fatalf("expect jpg,png,tar.gz, got %s", got)
+	}
+	if got := parseExtList(""); len(got) != 0 {
+		t.Fatalf("expect no extension, got %v", got)
+	}
+}
+
+func TestCheckUpload(t *testing.T) {
+	d := &Notion{Addition: Addition{UploadAllowExt: "jpg,tar.gz,exe", UploadBlockExt: ".EXE", MaxUploadSize: 1}}
+	d.initUploadFilter()
+	for _, c := range []struct {
+		name string
+		size int64
+		want error
+	}{
+		{"a.jpg", 10, nil},
+		{"dir/A.JPG", 10, nil},
+		{"a.tar.gz", 10, nil},
+		{"a.gz", 10, errs.PermissionDenied},
+		{"jpg", 10, errs.PermissionDenied},
+		// 允许列表之后仍检查禁止的后缀
+		{"a.exe", 10, errs.PermissionDenied},
+		{"a.jpg", 1024 * 1024, nil},
+		{"a.jpg", 1024*1024 + 1, errs.QuotaExceeded},
+	} {
+		err := d.checkUpload(c.name, c.size)
+		if (c.want == nil && err != nil) || !errors.Is(err, c.want) {
+			t.Errorf("%s %d: expect %v, got %v", c.name, c.size, c.want, err)
+		}
+	}
+
+	// 未配置时不限制
+	d = &Notion{}
+	d.initUploadFilter()
+	if err := d.checkUpload("a.exe", 1<<40); err != nil {
+		t.Fatalf("expect everything allowed, got %v", err)
+	}
+}
+
+func TestUploadFilter(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.UploadBlockExt = "exe"
+		d.MaxUploadSize = 1
+	})
+	ctx := context.Background()
+	// 拒绝的文件不创建页面
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.exe", testData(10)), func(float64) {}); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect a.exe refused, got %v", err)
+	}
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", testData(1024*1024+1)), func(float64) {}); !errors.Is(err, errs.QuotaExceeded) {
+		t.Fatalf("expect big.bin refused, got %v", err)
+	}
+	if n := fake.count(http.MethodPost, "/v1/pages"); n != 0 {
+		t.Fatalf("expect no page created, got %d", n)
+	}
+	if _, err := d.CreateUploadSession(ctx, rootDir(d), "b.exe", 10); !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect an upload session of b.exe refused, got %v", err)
+	}
+
+	// 追加后的大小也不能超过限制
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Append(ctx, obj, newTestStream("a.bin", testData(1)), func(float64) {}); !errors.Is(err, errs.QuotaExceeded) {
+		t.Fatalf("expect appending over the limit refused, got %v", err)
+	}
+}
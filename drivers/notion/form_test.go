@@ -0,0 +1,53 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+)
+
+func TestUploadToS3(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	ctx := context.Background()
+	data := testData(1 << 20)
+	if err := d.notionClient.UploadToS3(ctx, bytes.NewReader(data), "a.bin", int64(len(data)), UploadFields{Key: "form/a.bin"}, func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	fake.mu.Lock()
+	got := fake.objects["form/a.bin"]
+	fake.mu.Unlock()
+	if !bytes.Equal(got, data) {
+		t.Fatalf("expect the content stored, got %d bytes", len(got))
+	}
+
+	// 服务端提前返回时只报告响应的错误，关闭管道导致的写入错误被忽略
+	fake.failNext(http.MethodPost, "/s3/", http.StatusForbidden, 1)
+	err := d.notionClient.UploadToS3(ctx, bytes.NewReader(data), "c.bin", int64(len(data)), UploadFields{Key: "form/c.bin"}, func(float64) {})
+	if !errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect a permission error, got %v", err)
+	}
+	if strings.Contains(err.Error(), "写入上传数据失败") {
+		t.Fatalf("expect no write error for a closed pipe, got %v", err)
+	}
+
+	// 读取文件失败时返回写入端的错误
+	broken := io.MultiReader(bytes.NewReader(data[:1000]), iotest.ErrReader(errors.New("disk gone")))
+	err = d.notionClient.UploadToS3(ctx, broken, "b.bin", int64(len(data)), UploadFields{Key: "form/b.bin"}, func(float64) {})
+	if err == nil || !strings.Contains(err.Error(), "disk gone") || !strings.Contains(err.Error(), "写入上传数据失败") {
+		t.Fatalf("expect the read error reported, got %v", err)
+	}
+	fake.mu.Lock()
+	_, stored := fake.objects["form/b.bin"]
+	fake.mu.Unlock()
+	if stored {
+		t.Fatal("expect nothing stored after a read error")
+	}
+}
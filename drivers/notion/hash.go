@@ -0,0 +1,115 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// extraHashTypes 开启ExtraHashes时额外计算的哈希，SHA1总是会计算
+var extraHashTypes = []*utils.HashType{utils.MD5, utils.SHA256}
+
+// hashTypes 上传时需要计算的哈希类型
+func (d *Notion) hashTypes() []*utils.HashType {
+	if d.ExtraHashes {
+		return append([]*utils.HashType{utils.SHA1}, extraHashTypes...)
+	}
+	return []*utils.HashType{utils.SHA1}
+}
+
+// checkSHA1 比对由收到的内容计算的SHA-1与上传层或源存储提供的SHA-1，不一致时内容在传输中已损坏，
+// 文件记录中只保存由内容计算的哈希，复制后的校验才能发现损坏
+func checkSHA1(hi utils.HashInfo, sum string) error {
+	if h := hi.GetHash(utils.SHA1); h != "" && !strings.EqualFold(h, sum) {
+		return fmt.Errorf("上传内容的SHA-1为%s，与提供的%s不一致", sum, h)
+	}
+	return nil
+}
+
+// stagedHash 返回上传层缓存文件时计算的哈希，缺少types中的任一哈希时返回false，需要自行计算
+func stagedHash(file model.FileStreamer, types []*utils.HashType) (utils.HashInfo, bool) {
+	s, ok := file.(model.StagedHash)
+	if !ok {
+		return utils.HashInfo{}, false
+	}
+	hi := s.StagedHash()
+	for _, t := range types {
+		if hi.GetHash(t) == "" {
+			return utils.HashInfo{}, false
+		}
+	}
+	return hi, true
+}
+
+// hashingStream 在上传读取文件的同时计算哈希
+type hashingStream struct {
+	model.FileStreamer
+	hasher *utils.MultiHasher
+}
+
+func (s *hashingStream) Read(p []byte) (int, error) {
+	n, err := s.FileStreamer.Read(p)
+	_, _ = s.hasher.Write(p[:n])
+	return n, err
+}
+
+// GetFile 数据必须经过Read才能计算哈希，因此不暴露底层文件，上传失败时也不会重试
+func (s *hashingStream) GetFile() model.File {
+	return nil
+}
+
+// RehashResp rehash方法的结果，Failed为计算失败的文件路径及原因
+type RehashResp struct {
+	Hashed int               `json:"hashed"`
+	Failed map[string]string `json:"failed,omitempty"`
+}
+
+// rehash 读取没有SHA1的文件计算哈希并保存，obj为目录时处理其下全部文件。追加后的文件和从Notion重建的文件没有哈希，
+// 计算后复制到其他存储时可以校验内容，支持秒传的存储可以跳过上传
+func (d *Notion) rehash(ctx context.Context, obj model.Obj) (*RehashResp, error) {
+	var files []File
+	if obj.IsDir() {
+		dirID, _ := strconv.Atoi(obj.GetID())
+		all, err := d.tree.SubtreeFiles(dirID)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range all {
+			if f.SHA1 == "" {
+				files = append(files, f)
+			}
+		}
+	} else {
+		f, err := d.tree.GetFile(obj.GetID())
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, *f)
+	}
+	resp := &RehashResp{Failed: map[string]string{}}
+	for i := range files {
+		f := &files[i]
+		if err := ctx.Err(); err != nil {
+			return resp, err
+		}
+		hasher := utils.NewMultiHasher(d.hashTypes())
+		err := d.writeFile(ctx, hasher, f)
+		if err == nil {
+			f.SHA1, f.MD5, f.SHA256 = "", "", ""
+			f.SetHashes(hasher.GetHashInfo())
+			err = d.db.Select("sha1", "md5", "sha256").Updates(f).Error
+		}
+		if err != nil {
+			resp.Failed[path.Join(d.tree.FullDirPath(f.DirectoryID), f.Name)] = err.Error()
+			continue
+		}
+		d.mirrorFile(f)
+		resp.Hashed++
+	}
+	return resp, nil
+}
@@ -0,0 +1,149 @@
+package notion
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+func sha1Hex(data []byte) string {
+	sum := sha1.Sum(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// checkHashes 检查哈希，want中为空的哈希应不存在
+func checkHashes(t *testing.T, name string, hi utils.HashInfo, want map[*utils.HashType]string) {
+	t.Helper()
+	for typ, h := range want {
+		if got := hi.GetHash(typ); got != h {
+			t.Fatalf("%s of %s is %q, want %q", typ.Name, name, got, h)
+		}
+	}
+}
+
+func TestHashInfo(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	data := testData(1000)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 未开启ExtraHashes时只有SHA1
+	want := map[*utils.HashType]string{utils.SHA1: sha1Hex(data), utils.MD5: "", utils.SHA256: ""}
+	checkHashes(t, obj.GetName(), obj.GetHash(), want)
+	objs, err := d.List(context.Background(), rootDir(d), model.ListArgs{})
+	if err != nil || len(objs) != 1 {
+		t.Fatalf("list: %v %v", objs, err)
+	}
+	checkHashes(t, "listed "+objs[0].GetName(), objs[0].GetHash(), want)
+	renamed, err := d.Rename(context.Background(), obj, "b.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkHashes(t, renamed.GetName(), renamed.GetHash(), want)
+}
+
+func TestExtraHashes(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ExtraHashes = true
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	for _, c := range []struct {
+		name string
+		data []byte
+	}{{"small.bin", testData(1000)}, {"big.bin", testData(3 * 1024 * 1024)}} {
+		data := c.data
+		md5Sum, sha256Sum := md5.Sum(data), sha256.Sum256(data)
+		want := map[*utils.HashType]string{
+			utils.SHA1:   sha1Hex(data),
+			utils.MD5:    hex.EncodeToString(md5Sum[:]),
+			utils.SHA256: hex.EncodeToString(sha256Sum[:]),
+		}
+		obj, err := d.Put(context.Background(), rootDir(d), newTestStream(c.name, data), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		checkHashes(t, obj.GetName(), obj.GetHash(), want)
+		// 分块文件的哈希为整个文件的哈希
+		var f File
+		if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+			t.Fatal(err)
+		}
+		checkHashes(t, "stored "+f.Name, f.HashInfo(), want)
+	}
+}
+
+func TestSHA1ReaderUsesStagedHash(t *testing.T) {
+	data := testData(4096)
+	file := newTestStream("a.bin", data).(*stream.FileStream)
+	if _, err := file.StageInTempFile(utils.MD5, utils.SHA1); err != nil {
+		t.Fatal(err)
+	}
+	reader, sum := sha1Reader(file, file)
+	if reader != io.Reader(file) {
+		t.Fatal("上传层已计算SHA-1时不应再次计算")
+	}
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		t.Fatal(err)
+	}
+	got, err := sum()
+	if err != nil || got != sha1Hex(data) {
+		t.Fatalf("SHA-1为%q（%v），应为%q", got, err, sha1Hex(data))
+	}
+}
+
+func TestSHA1ReaderHashesUnstagedStream(t *testing.T) {
+	data := testData(4096)
+	file := newTestStream("a.bin", data)
+	reader, sum := sha1Reader(file, file)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		t.Fatal(err)
+	}
+	got, err := sum()
+	if err != nil || got != sha1Hex(data) {
+		t.Fatalf("SHA-1为%q（%v），应为%q", got, err, sha1Hex(data))
+	}
+}
+
+func TestSHA1ReaderRejectsWrongClaimedHash(t *testing.T) {
+	data := testData(4096)
+	file := newTestStream("a.bin", data).(*stream.FileStream)
+	file.Obj.(*model.Object).HashInfo = utils.NewHashInfo(utils.SHA1, sha1Hex([]byte("other")))
+	if _, err := file.StageInTempFile(utils.SHA1); err != nil {
+		t.Fatal(err)
+	}
+	reader, sum := sha1Reader(file, file)
+	if _, err := io.Copy(io.Discard, reader); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sum(); err == nil {
+		t.Fatal("客户端提供的SHA-1与内容不一致时应返回错误")
+	}
+}
+
+func TestStagedHashNeedsAllTypes(t *testing.T) {
+	file := newTestStream("a.bin", testData(100)).(*stream.FileStream)
+	if _, ok := stagedHash(file, []*utils.HashType{utils.SHA1}); ok {
+		t.Fatal("未缓存的文件没有上传层的哈希")
+	}
+	if _, err := file.StageInTempFile(utils.SHA1); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := stagedHash(file, []*utils.HashType{utils.SHA1}); !ok {
+		t.Fatal("应使用上传层计算的SHA-1")
+	}
+	if _, ok := stagedHash(file, []*utils.HashType{utils.SHA1, utils.SHA256}); ok {
+		t.Fatal("缺少SHA-256时应自行计算")
+	}
+}
@@ -0,0 +1,130 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// healthFailures 连续失败该次数后才改变存储状态，避免偶发的网络错误
+	healthFailures = 3
+	// healthTimeout 单次探测的超时时间
+	healthTimeout = 30 * time.Second
+)
+
+// healthProbe 一项健康检查，disable为true时失败表示存储不可用，否则只是降级
+type healthProbe struct {
+	name    string
+	disable bool
+	probe   func(ctx context.Context) error
+}
+
+// healthMonitor 记录各项检查的连续失败次数，只在健康检查的定时任务中访问
+type healthMonitor struct {
+	failures map[string]int
+	errs     map[string]error
+}
+
+func (d *Notion) healthProbes() []healthProbe {
+	return []healthProbe{
+		{name: "mysql", disable: true, probe: d.probeDB},
+		{name: "notion", probe: d.notionClient.PingDatabase},
+		{name: "s3", probe: d.probeFileURL},
+	}
+}
+
+// probeDB 检查数据库连接
+func (d *Notion) probeDB(ctx context.Context) error {
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+// probeFileURL 读取一个分块或文件附件的第一个字节，检查存放附件的S3是否可以访问，存储为空时跳过
+func (d *Notion) probeFileURL(ctx context.Context) error {
+	var chunk FileChunk
+	err := d.db.Where("file_id IN (?) AND deleted = ?", d.tree.FileIDs(d.db), false).Order("id DESC").Limit(1).Find(&chunk).Error
+	if err != nil {
+		return err
+	}
+	pageID := chunk.BlobKey
+	if pageID == "" {
+		var f File
+		err := d.db.Where("directory_id IN (?) AND deleted = ? AND is_chunked = ? AND notion_page_id <> ''", d.tree.DirIDs(d.db), false, false).
+			Order("id DESC").Limit(1).Find(&f).Error
+		if err != nil {
+			return err
+		}
+		pageID = f.BlobKey
+	}
+	if pageID == "" {
+		return nil
+	}
+	property, err := d.notionClient.GetPageProperty(pageID, d.NotionFilePageID)
+	if err != nil {
+		return err
+	}
+	if len(property.Files) == 0 {
+		return fmt.Errorf("页面%s没有文件", pageID)
+	}
+	rc, err := chunkstore.RangeGet(ctx, property.Files[0].File.URL, 0, 1)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	_, err = io.ReadAll(rc)
+	return err
+}
+
+// checkHealth 执行全部检查，任一检查连续失败healthFailures次后将存储标记为降级或不可用，全部通过后恢复
+func (d *Notion) checkHealth() {
+	m := d.health
+	for _, p := range d.healthProbes() {
+		ctx, cancel := context.WithTimeout(context.Background(), healthTimeout)
+		err := p.probe(ctx)
+		cancel()
+		if err != nil {
+			m.failures[p.name]++
+			m.errs[p.name] = err
+		} else {
+			delete(m.failures, p.name)
+			delete(m.errs, p.name)
+		}
+	}
+
+	status := op.WORK
+	var reasons []string
+	for _, p := range d.healthProbes() {
+		if m.failures[p.name] < healthFailures {
+			continue
+		}
+		reasons = append(reasons, fmt.Sprintf("%s: %v", p.name, m.errs[p.name]))
+		if p.disable {
+			status = op.DISABLED
+		} else if status == op.WORK {
+			status = "degraded"
+		}
+	}
+	if status != op.WORK {
+		status += ": " + strings.Join(reasons, "; ")
+	}
+	storage := d.GetStorage()
+	if storage.Status == status {
+		return
+	}
+	if status == op.WORK {
+		log.Infof("Notion存储[%s]已恢复", storage.MountPath)
+	} else {
+		log.Warnf("Notion存储[%s]健康检查失败: %s", storage.MountPath, status)
+	}
+	storage.SetStatus(status)
+}
@@ -0,0 +1,80 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/op"
+)
+
+// newHealthNotion 创建不启动定时任务的存储，由测试直接调用checkHealth
+func newHealthNotion(t *testing.T, fake *fakeNotion) *Notion {
+	d := newTestNotion(t, fake, nil)
+	d.health = &healthMonitor{failures: make(map[string]int), errs: make(map[string]error)}
+	return d
+}
+
+func TestHealthDegraded(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newHealthNotion(t, fake)
+
+	// 存储为空时不探测S3
+	d.checkHealth()
+	if d.Status != op.WORK {
+		t.Fatalf("expect %s, got %s", op.WORK, d.Status)
+	}
+	if n := fake.count(http.MethodGet, "/s3/"); n != 0 {
+		t.Fatalf("expect no S3 probe for an empty storage, got %d", n)
+	}
+
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name, method, prefix string
+	}{
+		{"notion", http.MethodGet, "/v1/databases/"},
+		{"s3", http.MethodGet, "/s3/"},
+	}
+	for _, c := range cases {
+		fake.failNext(c.method, c.prefix, http.StatusForbidden, healthFailures)
+		// 连续失败次数不足时不改变状态
+		for i := 0; i < healthFailures-1; i++ {
+			d.checkHealth()
+			if d.Status != op.WORK {
+				t.Fatalf("%s: expect %s after %d failures, got %s", c.name, op.WORK, i+1, d.Status)
+			}
+		}
+		d.checkHealth()
+		if !strings.HasPrefix(d.Status, "degraded: ") || !strings.Contains(d.Status, c.name+": ") {
+			t.Fatalf("%s: expect degraded, got %s", c.name, d.Status)
+		}
+		// 一次成功即恢复
+		d.checkHealth()
+		if d.Status != op.WORK {
+			t.Fatalf("%s: expect %s after recovery, got %s", c.name, op.WORK, d.Status)
+		}
+	}
+}
+
+func TestHealthDisabled(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newHealthNotion(t, fake)
+	fake.failNext(http.MethodGet, "/v1/databases/", http.StatusForbidden, healthFailures)
+	sqlDB, err := d.db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < healthFailures; i++ {
+		d.checkHealth()
+	}
+	// 数据库不可用时存储被禁用，同时列出降级的原因
+	if !strings.HasPrefix(d.Status, op.DISABLED+": ") || !strings.Contains(d.Status, "mysql: ") || !strings.Contains(d.Status, "notion: ") {
+		t.Fatalf("expect disabled with the reasons, got %s", d.Status)
+	}
+}
@@ -0,0 +1,135 @@
+package notion
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/singleflight"
+)
+
+// hotG 合并同一文件的并发读取，缓存未命中时只从Notion下载一次
+var hotG singleflight.Group[[]byte]
+
+// hotCache 最近读取的小文件内容，按最近使用淘汰，总大小不超过maxBytes，条目在ttl后过期
+type hotCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	ttl      time.Duration
+	size     int64
+	lru      *list.List
+	items    map[string]*list.Element
+}
+
+type hotEntry struct {
+	key     string
+	data    []byte
+	expires time.Time
+}
+
+func newHotCache(maxBytes int64, ttl time.Duration) *hotCache {
+	return &hotCache{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		lru:      list.New(),
+		items:    make(map[string]*list.Element),
+	}
+}
+
+func (c *hotCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := e.Value.(*hotEntry)
+	if time.Now().After(entry.expires) {
+		c.removeLocked(e)
+		return nil, false
+	}
+	c.lru.MoveToFront(e)
+	return entry.data, true
+}
+
+func (c *hotCache) set(key string, data []byte) {
+	if int64(len(data)) > c.maxBytes {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.items[key]; ok {
+		c.removeLocked(e)
+	}
+	c.items[key] = c.lru.PushFront(&hotEntry{key: key, data: data, expires: time.Now().Add(c.ttl)})
+	c.size += int64(len(data))
+	for c.size > c.maxBytes {
+		c.removeLocked(c.lru.Back())
+	}
+}
+
+func (c *hotCache) removeLocked(e *list.Element) {
+	entry := c.lru.Remove(e).(*hotEntry)
+	delete(c.items, entry.key)
+	c.size -= int64(len(entry.data))
+}
+
+// hotLimit 缓存的文件大小上限，未开启缓存时为-1
+func (d *Notion) hotLimit() int64 {
+	if d.hotCache == nil {
+		return -1
+	}
+	return int64(d.HotCacheFileSize) * 1024
+}
+
+// hotFileLink 从缓存返回未分块小文件的内容，未命中时下载整个文件并缓存；
+// 键包含修改时间，覆盖或追加后的文件不会读到旧内容
+func (d *Notion) hotFileLink(ctx context.Context, f *File) (*model.Link, error) {
+	key := fmt.Sprintf("file-%d-%d", f.ID, f.UpdatedAt.UnixNano())
+	return d.hotLink(key, func() ([]byte, error) {
+		return d.readPageFile(ctx, f)
+	})
+}
+
+// hotThumbLink 从缓存返回缩略图的内容，缩略图页面生成后不再修改
+func (d *Notion) hotThumbLink(ctx context.Context, pageID string) (*model.Link, error) {
+	return d.hotLink("thumb-"+pageID, func() ([]byte, error) {
+		rc, err := d.notionClient.OpenPageFile(ctx, pageID, 0, 0)
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		data, err := io.ReadAll(rc)
+		if err != nil {
+			return nil, fmt.Errorf("读取缩略图失败: %w", err)
+		}
+		return data, nil
+	})
+}
+
+func (d *Notion) hotLink(key string, read func() ([]byte, error)) (*model.Link, error) {
+	cache := d.hotCache
+	data, ok := cache.get(key)
+	countCache("hot", ok)
+	if !ok {
+		var err error
+		data, err, _ = hotG.Do(fmt.Sprintf("%d-%s", d.ID, key), func() ([]byte, error) {
+			data, err := read()
+			if err != nil {
+				return nil, err
+			}
+			if int64(len(data)) <= int64(d.HotCacheFileSize)*1024 {
+				cache.set(key, data)
+			}
+			return data, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return bytesLink(data), nil
+}
@@ -0,0 +1,91 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestHotCacheEvict(t *testing.T) {
+	c := newHotCache(10, time.Minute)
+	c.set("a", []byte("aaaa"))
+	c.set("b", []byte("bbbb"))
+	// 读取后a变为最近使用，超出大小时淘汰b
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("expect a cached")
+	}
+	c.set("c", []byte("cccc"))
+	if _, ok := c.get("b"); ok {
+		t.Fatal("expect b evicted")
+	}
+	if _, ok := c.get("a"); !ok {
+		t.Fatal("expect a kept")
+	}
+	if c.size != 8 {
+		t.Fatalf("expect 8 bytes cached, got %d", c.size)
+	}
+	// 替换同一键时大小不重复计算，超过总大小的内容不缓存
+	c.set("a", []byte("aa"))
+	c.set("big", make([]byte, 11))
+	if _, ok := c.get("big"); ok || c.size != 6 {
+		t.Fatalf("expect 6 bytes cached without big, got %d", c.size)
+	}
+
+	expired := newHotCache(10, -time.Second)
+	expired.set("a", []byte("a"))
+	if _, ok := expired.get("a"); ok || expired.size != 0 {
+		t.Fatal("expect an expired entry removed")
+	}
+}
+
+func TestHotCacheLink(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.HotCacheSize = 1
+		d.HotCacheFileSize = 2
+		d.HotCacheTTL = 10
+	})
+	ctx := context.Background()
+	data := testData(1000)
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.srt", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只有第一次读取从Notion下载
+	for i := 0; i < 3; i++ {
+		if got := readInline(t, d, obj); !bytes.Equal(got, data) {
+			t.Fatal("content mismatch")
+		}
+	}
+	if n := fake.count(http.MethodGet, "/s3/"); n != 1 {
+		t.Fatalf("expect one download, got %d", n)
+	}
+
+	// 覆盖后不读到旧内容
+	time.Sleep(10 * time.Millisecond)
+	newData := testData(1001)
+	obj, err = d.Put(ctx, rootDir(d), newTestStream("a.srt", newData), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readInline(t, d, obj); !bytes.Equal(got, newData) {
+		t.Fatal("expect the new content after overwriting")
+	}
+
+	// 超过HotCacheFileSize的文件仍然重定向
+	big, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", testData(3000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(ctx, big, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.MFile != nil || link.URL == "" {
+		t.Fatalf("expect a redirect for a large file, got %+v", link)
+	}
+}
@@ -0,0 +1,56 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// maxInlineSize 保存在数据库中的文件大小上限，InlineSize超过时按此限制
+const maxInlineSize = 1024 * 1024
+
+// inlineLimit 不超过该大小的文件保存在数据库中，空文件总是只保存记录
+func (d *Notion) inlineLimit() int64 {
+	return min(int64(d.InlineSize)*1024, maxInlineSize)
+}
+
+// putInlineFile 将空文件或小文件的内容直接保存在文件记录中，不创建Notion页面
+func (d *Notion) putInlineFile(ctx context.Context, fileName string, fileSize int64, dirID int, file model.FileStreamer, up model.UpdateProgress) (model.Obj, error) {
+	data := make([]byte, fileSize)
+	if _, err := io.ReadFull(file, data); err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	hasher := utils.NewMultiHasher(d.hashTypes())
+	hasher.Write(data)
+	if err := checkSHA1(file.GetHash(), hasher.GetHashInfo().GetHash(utils.SHA1)); err != nil {
+		return nil, err
+	}
+	f := &File{
+		Name:        fileName,
+		Size:        fileSize,
+		DirectoryID: dirID,
+	}
+	if fileSize > 0 {
+		f.Inline = data
+	}
+	f.SetHashes(hasher.GetHashInfo())
+	if err := d.db.Create(f).Error; err != nil {
+		return nil, fmt.Errorf("保存文件信息失败: %w", err)
+	}
+	up(100)
+	return dbfs.FileToObj(f), nil
+}
+
+// inlineLink 保存在数据库中的文件直接从记录读取
+func inlineLink(f *File) *model.Link {
+	return bytesLink(f.Inline)
+}
+
+func bytesLink(data []byte) *model.Link {
+	return &model.Link{MFile: model.NewNopMFile(bytes.NewReader(data))}
+}
@@ -0,0 +1,156 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// readInline 读取保存在数据库中的文件
+func readInline(t *testing.T, d *Notion, obj model.Obj) []byte {
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.MFile == nil {
+		t.Fatalf("expect the content read from the database, got %+v", link)
+	}
+	data, err := io.ReadAll(link.MFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestInlineEmptyFile(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("empty.txt", nil), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 空文件只保存记录，不创建页面
+	if n := fake.livePages(); n != 0 {
+		t.Fatalf("expect no page for an empty file, got %d", n)
+	}
+	if got := readInline(t, d, obj); len(got) != 0 {
+		t.Fatalf("expect an empty file, got %d bytes", len(got))
+	}
+	if h := obj.GetHash().GetHash(utils.SHA1); h != sha1Hex(nil) {
+		t.Fatalf("expect the sha1 of an empty file, got %s", h)
+	}
+	// 默认只保存空文件
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.txt", testData(10)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.livePages(); n != 1 {
+		t.Fatalf("expect a page for a non-empty file, got %d", n)
+	}
+}
+
+func TestInlineSmallFile(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.InlineSize = 1 })
+	ctx := context.Background()
+	data := testData(1000)
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("small.txt", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.livePages(); n != 0 {
+		t.Fatalf("expect no page for a small file, got %d", n)
+	}
+	if got := readInline(t, d, obj); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+	if h := obj.GetHash().GetHash(utils.SHA1); h != sha1Hex(data) {
+		t.Fatalf("expect the sha1 of the content, got %s", h)
+	}
+
+	// 重命名和复制不访问Notion
+	requests := fake.count(http.MethodPatch, "/v1/pages/") + fake.count(http.MethodPost, "/v1/pages")
+	renamed, err := d.Rename(ctx, obj, "renamed.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir, err := d.MakeDir(ctx, rootDir(d), "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := d.Copy(ctx, renamed, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count(http.MethodPatch, "/v1/pages/") + fake.count(http.MethodPost, "/v1/pages"); n != requests {
+		t.Fatalf("expect no Notion request for a small file, got %d", n-requests)
+	}
+	if got := readInline(t, d, copied); !bytes.Equal(got, data) {
+		t.Fatal("copy content mismatch")
+	}
+
+	// 超过InlineSize的文件上传到Notion
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("large.txt", testData(2000)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.livePages(); n != 1 {
+		t.Fatalf("expect a page for a file over inline_size, got %d", n)
+	}
+}
+
+func TestInlineAppend(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.InlineSize = 1 })
+	ctx := context.Background()
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("log.txt", []byte("hello ")), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 追加后不超过InlineSize时仍保存在数据库中，哈希按完整内容计算
+	obj, err = d.Append(ctx, obj, newTestStream("log.txt", []byte("world")), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readInline(t, d, obj); string(got) != "hello world" {
+		t.Fatalf("expect hello world, got %q", got)
+	}
+	if h := obj.GetHash().GetHash(utils.SHA1); h != sha1Hex([]byte("hello world")) {
+		t.Fatalf("expect the sha1 of the whole content, got %s", h)
+	}
+	if n := fake.livePages(); n != 0 {
+		t.Fatalf("expect no page, got %d", n)
+	}
+
+	// 超过后数据库中的内容与追加的数据一起上传，需要缓存到临时文件
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	tempDir := conf.Conf.TempDir
+	conf.Conf.TempDir = t.TempDir()
+	defer func() { conf.Conf.TempDir = tempDir }()
+	more := testData(2000)
+	obj, err = d.Append(ctx, obj, newTestStream("log.txt", more), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if f.IsInline() || len(f.Inline) != 0 {
+		t.Fatal("expect the file moved to Notion")
+	}
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := append([]byte("hello world"), more...)
+	if got := readRange(t, link, 0, int64(len(want))); !bytes.Equal(got, want) {
+		t.Fatal("content mismatch after moving to Notion")
+	}
+}
@@ -0,0 +1,42 @@
+package notion
+
+import (
+	"context"
+	"io"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+	"golang.org/x/time/rate"
+)
+
+// newLimiter 创建速度为kbps KB/s的限速器
+func newLimiter(kbps int) stream.Limiter {
+	limit := kbps * 1024
+	return stream.BlockBurstLimiter{Limiter: rate.NewLimiter(rate.Limit(limit), limit)}
+}
+
+// limitDownload 对分块文件的读取应用存储的下载限速和单个连接的下载限速
+func (d *Notion) limitDownload(rrc model.RangeReadCloserIF) model.RangeReadCloserIF {
+	if d.downloadLimit != nil {
+		rrc = &stream.RateLimitRangeReadCloser{RangeReadCloserIF: rrc, Limiter: d.downloadLimit}
+	}
+	if d.ConnDownloadLimit > 0 {
+		rrc = &connLimitRangeReadCloser{RangeReadCloserIF: rrc, kbps: d.ConnDownloadLimit}
+	}
+	return rrc
+}
+
+// connLimitRangeReadCloser 每次RangeRead（即每个下载连接）使用单独的限速器
+type connLimitRangeReadCloser struct {
+	model.RangeReadCloserIF
+	kbps int
+}
+
+func (c *connLimitRangeReadCloser) RangeRead(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+	rc, err := c.RangeReadCloserIF.RangeRead(ctx, httpRange)
+	if err != nil {
+		return nil, err
+	}
+	return &stream.RateLimitReader{Reader: rc, Limiter: newLimiter(c.kbps), Ctx: ctx}, nil
+}
@@ -0,0 +1,81 @@
+package notion
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+)
+
+// chunkedLink 上传一个分块文件，返回它的Link
+func chunkedLink(t *testing.T, configure func(d *Notion)) *model.Link {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+		configure(d)
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", testData(3*1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.RangeReadCloser == nil {
+		t.Fatalf("expect a range reader, got %+v", link)
+	}
+	return link
+}
+
+// timeRangeReads 用n个连接同时各读取size字节，返回全部读完所用的时间
+func timeRangeReads(t *testing.T, link *model.Link, n int, size int64) time.Duration {
+	errs := make(chan error, n)
+	var wg sync.WaitGroup
+	start := time.Now()
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			rc, err := link.RangeReadCloser.RangeRead(context.Background(), http_range.Range{Start: int64(i) * size, Length: size})
+			if err != nil {
+				errs <- err
+				return
+			}
+			defer rc.Close()
+			if _, err := io.Copy(io.Discard, rc); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Fatalf("range read: %v", err)
+	}
+	return time.Since(start)
+}
+
+func TestDownloadLimitShared(t *testing.T) {
+	// 128KB/s由全部连接共享，两个连接共读256KB至少需要1秒
+	link := chunkedLink(t, func(d *Notion) { d.DownloadLimit = 128 })
+	if elapsed := timeRangeReads(t, link, 2, 128*1024); elapsed < 800*time.Millisecond {
+		t.Fatalf("expect the connections limited together, took %v", elapsed)
+	}
+}
+
+func TestConnDownloadLimit(t *testing.T) {
+	link := chunkedLink(t, func(d *Notion) { d.ConnDownloadLimit = 128 })
+	// 每个连接单独限速128KB/s，两个连接各读128KB都在突发额度内
+	if elapsed := timeRangeReads(t, link, 2, 128*1024); elapsed > 500*time.Millisecond {
+		t.Fatalf("expect each connection limited on its own, took %v", elapsed)
+	}
+	// 单个连接读256KB至少需要1秒
+	if elapsed := timeRangeReads(t, link, 1, 256*1024); elapsed < 800*time.Millisecond {
+		t.Fatalf("expect the connection limited, took %v", elapsed)
+	}
+}
@@ -0,0 +1,38 @@
+package notion
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// TestListStream 逐个列出的对象与List相同
+func TestListStream(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	if _, err := d.MakeDir(ctx, rootDir(d), "docs"); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a.bin", "b.bin"} {
+		if _, err := d.Put(ctx, rootDir(d), newTestStream(name, testData(10)), func(float64) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	objs, err := d.List(ctx, rootDir(d), model.ListArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var streamed []model.Obj
+	err = d.ListStream(ctx, rootDir(d), model.ListArgs{}, func(obj model.Obj) error {
+		streamed = append(streamed, obj)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(objs) != 3 || !reflect.DeepEqual(streamed, objs) {
+		t.Fatalf("expect the objects of List, got %v and %v", streamed, objs)
+	}
+}
@@ -0,0 +1,74 @@
+package notion
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// WebDAV锁保存在元数据数据库中，重启后仍然有效，使用同一数据库的多个alist实例共享
+
+func (d *Notion) CreateLock(ctx context.Context, lock model.Lock) error {
+	lock.Path = d.lockPath(ctx, lock.Path)
+	return d.tree.CreateLock(lock)
+}
+
+func (d *Notion) GetLock(ctx context.Context, token string) (*model.Lock, error) {
+	return d.userLock(ctx, token)
+}
+
+func (d *Notion) RefreshLock(ctx context.Context, token string, expires time.Time) (*model.Lock, error) {
+	if _, err := d.userLock(ctx, token); err != nil {
+		return nil, err
+	}
+	lock, err := d.tree.RefreshLock(token, expires)
+	if err != nil {
+		return nil, err
+	}
+	lock.Path = d.userPath(ctx, lock.Path)
+	return lock, nil
+}
+
+func (d *Notion) DeleteLock(ctx context.Context, token string) error {
+	if _, err := d.userLock(ctx, token); err != nil {
+		return err
+	}
+	return d.tree.DeleteLock(token)
+}
+
+// lockPath 开启用户目录时非管理员用户的路径相对其用户目录，锁保存相对存储根目录的路径，
+// 不同用户的相同路径不冲突
+func (d *Notion) lockPath(ctx context.Context, p string) string {
+	if username := d.UserScope(ctx); username != "" {
+		return path.Join("/", d.homeName(username), p)
+	}
+	return p
+}
+
+// userPath 将相对存储根目录的锁路径转换为用户看到的路径
+func (d *Notion) userPath(ctx context.Context, p string) string {
+	if username := d.UserScope(ctx); username != "" {
+		return "/" + strings.TrimPrefix(strings.TrimPrefix(p, path.Join("/", d.homeName(username))), "/")
+	}
+	return p
+}
+
+// userLock 获取锁，其他用户目录中的锁视为不存在
+func (d *Notion) userLock(ctx context.Context, token string) (*model.Lock, error) {
+	lock, err := d.tree.GetLock(token)
+	if err != nil {
+		return nil, err
+	}
+	if username := d.UserScope(ctx); username != "" {
+		home := path.Join("/", d.homeName(username))
+		if lock.Path != home && !strings.HasPrefix(lock.Path, home+"/") {
+			return nil, errs.ObjectNotFound
+		}
+	}
+	lock.Path = d.userPath(ctx, lock.Path)
+	return lock, nil
+}
@@ -0,0 +1,240 @@
+package notion
+
+import "fmt"
+
+// msgCode 错误信息的代码，用于在catalogue中查找各语言的文本
+type msgCode string
+
+const (
+	msgConnectDB            msgCode = "connect_db"
+	msgRegisterDBMetrics    msgCode = "register_db_metrics"
+	msgMigrateDB            msgCode = "migrate_db"
+	msgInitClient           msgCode = "init_client"
+	msgParseChunkTemplate   msgCode = "parse_chunk_template"
+	msgParseWebhookTemplate msgCode = "parse_webhook_template"
+	msgGetFile              msgCode = "get_file"
+	msgGetSrcFile           msgCode = "get_src_file"
+	msgGetFileURL           msgCode = "get_file_url"
+	msgGetChunks            msgCode = "get_chunks"
+	msgNoChunks             msgCode = "no_chunks"
+	msgNeedEncryptionKey    msgCode = "need_encryption_key"
+	msgListDirFiles         msgCode = "list_dir_files"
+	msgDeleteDir            msgCode = "delete_dir"
+	msgDeleteDirFiles       msgCode = "delete_dir_files"
+	msgGetSubDirs           msgCode = "get_sub_dirs"
+	msgDeleteFile           msgCode = "delete_file"
+	msgReplaceFile          msgCode = "replace_file"
+	msgMoveVersions         msgCode = "move_versions"
+	msgSaveVersion          msgCode = "save_version"
+	msgDeleteChunks         msgCode = "delete_chunks"
+	msgCreateNotionPage     msgCode = "create_notion_page"
+	msgReadFile             msgCode = "read_file"
+	msgUploadToNotion       msgCode = "upload_to_notion"
+	msgSaveFile             msgCode = "save_file"
+	msgCacheFile            msgCode = "cache_file"
+	msgCreateFileRecord     msgCode = "create_file_record"
+	msgUploadChunk          msgCode = "upload_chunk"
+	msgSaveChunk            msgCode = "save_chunk"
+	msgUploadParity         msgCode = "upload_parity"
+	msgSaveParity           msgCode = "save_parity"
+	msgMarshalBody          msgCode = "marshal_body"
+	msgNewRequest           msgCode = "new_request"
+	msgSendRequest          msgCode = "send_request"
+	msgDecodeResponse       msgCode = "decode_response"
+	msgUpdateTitle          msgCode = "update_title"
+	msgArchivePage          msgCode = "archive_page"
+	msgUploadFile           msgCode = "upload_file"
+	msgOpenFile             msgCode = "open_file"
+	msgUploadS3             msgCode = "upload_s3"
+	msgUpdateFileStatus     msgCode = "update_file_status"
+	msgWriteUpload          msgCode = "write_upload"
+	msgVerifyUpload         msgCode = "verify_upload"
+	msgWriteField           msgCode = "write_field"
+	msgCreateFormFile       msgCode = "create_form_file"
+	msgCopyContent          msgCode = "copy_content"
+	msgCloseForm            msgCode = "close_form"
+	msgUploadSizeMismatch   msgCode = "upload_size_mismatch"
+	msgETagMismatch         msgCode = "etag_mismatch"
+	msgPageNoFiles          msgCode = "page_no_files"
+	msgPageFileIndex        msgCode = "page_file_index"
+	msgHTTPStatus           msgCode = "http_status"
+	msgContentRange         msgCode = "content_range"
+	msgParseOtherData       msgCode = "parse_other_data"
+	msgUpdatePageProps      msgCode = "update_page_props"
+	// 接口请求失败的前缀，由apiError加上状态码和响应
+	msgAPIStatus           msgCode = "api_status"
+	msgAPIRequest          msgCode = "api_request"
+	msgAPICreatePage       msgCode = "api_create_page"
+	msgAPIGetUploadURL     msgCode = "api_get_upload_url"
+	msgAPIUpload           msgCode = "api_upload"
+	msgAPIUpdateFileStatus msgCode = "api_update_file_status"
+	msgAPIGetProperty      msgCode = "api_get_property"
+	msgAPIGetDatabase      msgCode = "api_get_database"
+	msgAPIUpdateDBProps    msgCode = "api_update_db_props"
+	msgAPIQueryDB          msgCode = "api_query_db"
+)
+
+// defaultLanguage 未知的语言及缺少的代码使用的语言
+const defaultLanguage = "zh-CN"
+
+// catalogue 各语言的错误信息，同一代码在各语言中的格式化动词及其顺序必须相同
+var catalogue = map[string]map[msgCode]string{
+	"zh-CN": {
+		msgConnectDB:            "连接数据库失败: %w",
+		msgRegisterDBMetrics:    "注册数据库监控失败: %w",
+		msgMigrateDB:            "迁移数据库失败: %w",
+		msgInitClient:           "初始化Notion客户端失败: 无法从cookie中提取userId",
+		msgParseChunkTemplate:   "解析分块标题模板失败: %w",
+		msgParseWebhookTemplate: "解析webhook模板失败: %w",
+		msgGetFile:              "获取文件信息失败: %w",
+		msgGetSrcFile:           "获取源文件信息失败: %w",
+		msgGetFileURL:           "获取文件URL失败: %w",
+		msgGetChunks:            "获取文件分块信息失败: %w",
+		msgNoChunks:             "分块文件没有找到分块数据",
+		msgNeedEncryptionKey:    "文件已加密，需要配置加密密钥",
+		msgListDirFiles:         "获取目录下的文件失败: %w",
+		msgDeleteDir:            "删除目录失败: %w",
+		msgDeleteDirFiles:       "删除目录下的文件失败: %w",
+		msgGetSubDirs:           "获取子目录失败: %w",
+		msgDeleteFile:           "删除文件失败: %w",
+		msgReplaceFile:          "替换旧文件失败: %w",
+		msgMoveVersions:         "转移历史版本失败: %w",
+		msgSaveVersion:          "保存历史版本失败: %w",
+		msgDeleteChunks:         "删除文件分块失败: %w",
+		msgCreateNotionPage:     "创建Notion页面失败: %w",
+		msgReadFile:             "读取文件失败: %w",
+		msgUploadToNotion:       "上传文件到Notion失败: %w",
+		msgSaveFile:             "保存文件信息失败: %w",
+		msgCacheFile:            "缓存文件失败: %w",
+		msgCreateFileRecord:     "创建文件记录失败: %w",
+		msgUploadChunk:          "上传分块失败: %w",
+		msgSaveChunk:            "保存分块记录失败: %w",
+		msgUploadParity:         "上传校验分块失败: %w",
+		msgSaveParity:           "保存校验分块记录失败: %w",
+		msgMarshalBody:          "序列化请求体失败: %w",
+		msgNewRequest:           "创建请求失败: %w",
+		msgSendRequest:          "发送请求失败: %w",
+		msgDecodeResponse:       "解析响应失败: %w",
+		msgUpdateTitle:          "更新页面标题失败: %w",
+		msgArchivePage:          "归档页面失败: %w",
+		msgUploadFile:           "上传文件失败: %w",
+		msgOpenFile:             "无法打开文件: %w",
+		msgUploadS3:             "上传到S3失败: %w",
+		msgUpdateFileStatus:     "更新文件状态失败: %w",
+		msgWriteUpload:          "写入上传数据失败: %w",
+		msgVerifyUpload:         "上传校验失败: %w",
+		msgWriteField:           "写入字段%s失败: %w",
+		msgCreateFormFile:       "创建文件字段失败: %w",
+		msgCopyContent:          "复制文件内容失败: %w",
+		msgCloseForm:            "结束表单失败: %w",
+		msgUploadSizeMismatch:   "上传了%d字节，文件大小为%d",
+		msgETagMismatch:         "S3返回的ETag为%s，上传内容的MD5为%s",
+		msgPageNoFiles:          "页面%s没有文件: %w",
+		msgPageFileIndex:        "页面%s只有%d个文件，没有第%d个",
+		msgHTTPStatus:           "HTTP请求失败，状态码: %d",
+		msgContentRange:         "无法解析Content-Range: %s",
+		msgParseOtherData:       "解析请求参数失败: %w",
+		msgUpdatePageProps:      "更新页面属性失败: %w",
+		msgAPIStatus:            "%s，状态码: %d, 响应: %s",
+		msgAPIRequest:           "请求失败",
+		msgAPICreatePage:        "创建页面失败",
+		msgAPIGetUploadURL:      "获取上传地址失败",
+		msgAPIUpload:            "上传失败",
+		msgAPIUpdateFileStatus:  "更新文件状态失败",
+		msgAPIGetProperty:       "获取属性失败",
+		msgAPIGetDatabase:       "获取数据库失败",
+		msgAPIUpdateDBProps:     "更新数据库属性失败",
+		msgAPIQueryDB:           "查询数据库失败",
+	},
+	"en": {
+		msgConnectDB:            "failed to connect to the database: %w",
+		msgRegisterDBMetrics:    "failed to register the database metrics: %w",
+		msgMigrateDB:            "failed to migrate the database: %w",
+		msgInitClient:           "failed to initialize the Notion client: no user ID in the cookie",
+		msgParseChunkTemplate:   "failed to parse the chunk name template: %w",
+		msgParseWebhookTemplate: "failed to parse the webhook template: %w",
+		msgGetFile:              "failed to get the file info: %w",
+		msgGetSrcFile:           "failed to get the source file info: %w",
+		msgGetFileURL:           "failed to get the file URL: %w",
+		msgGetChunks:            "failed to get the chunks of the file: %w",
+		msgNoChunks:             "no chunks found for the chunked file",
+		msgNeedEncryptionKey:    "the file is encrypted, configure the encryption key to read it",
+		msgListDirFiles:         "failed to list the files in the folder: %w",
+		msgDeleteDir:            "failed to delete the folder: %w",
+		msgDeleteDirFiles:       "failed to delete the files in the folder: %w",
+		msgGetSubDirs:           "failed to get the subfolders: %w",
+		msgDeleteFile:           "failed to delete the file: %w",
+		msgReplaceFile:          "failed to replace the old file: %w",
+		msgMoveVersions:         "failed to move the previous versions: %w",
+		msgSaveVersion:          "failed to save the previous version: %w",
+		msgDeleteChunks:         "failed to delete the chunks of the file: %w",
+		msgCreateNotionPage:     "failed to create the Notion page: %w",
+		msgReadFile:             "failed to read the file: %w",
+		msgUploadToNotion:       "failed to upload the file to Notion: %w",
+		msgSaveFile:             "failed to save the file info: %w",
+		msgCacheFile:            "failed to cache the file: %w",
+		msgCreateFileRecord:     "failed to create the file record: %w",
+		msgUploadChunk:          "failed to upload the chunk: %w",
+		msgSaveChunk:            "failed to save the chunk record: %w",
+		msgUploadParity:         "failed to upload the parity chunks: %w",
+		msgSaveParity:           "failed to save the parity chunk records: %w",
+		msgMarshalBody:          "failed to encode the request body: %w",
+		msgNewRequest:           "failed to create the request: %w",
+		msgSendRequest:          "failed to send the request: %w",
+		msgDecodeResponse:       "failed to decode the response: %w",
+		msgUpdateTitle:          "failed to update the page title: %w",
+		msgArchivePage:          "failed to archive the page: %w",
+		msgUploadFile:           "failed to upload the file: %w",
+		msgOpenFile:             "failed to open the file: %w",
+		msgUploadS3:             "failed to upload to S3: %w",
+		msgUpdateFileStatus:     "failed to update the file status: %w",
+		msgWriteUpload:          "failed to write the upload data: %w",
+		msgVerifyUpload:         "upload verification failed: %w",
+		msgWriteField:           "failed to write the field %s: %w",
+		msgCreateFormFile:       "failed to create the file field: %w",
+		msgCopyContent:          "failed to copy the file content: %w",
+		msgCloseForm:            "failed to close the form: %w",
+		msgUploadSizeMismatch:   "uploaded %d bytes, the file size is %d",
+		msgETagMismatch:         "S3 returned the ETag %s, the MD5 of the uploaded content is %s",
+		msgPageNoFiles:          "page %s has no files: %w",
+		msgPageFileIndex:        "page %s has only %d files, no file %d",
+		msgHTTPStatus:           "HTTP request failed, status code: %d",
+		msgContentRange:         "failed to parse Content-Range: %s",
+		msgParseOtherData:       "failed to parse the request parameters: %w",
+		msgUpdatePageProps:      "failed to update the page properties: %w",
+		msgAPIStatus:            "%s, status code: %d, response: %s",
+		msgAPIRequest:           "request failed",
+		msgAPICreatePage:        "failed to create the page",
+		msgAPIGetUploadURL:      "failed to get the upload URL",
+		msgAPIUpload:            "upload failed",
+		msgAPIUpdateFileStatus:  "failed to update the file status",
+		msgAPIGetProperty:       "failed to get the property",
+		msgAPIGetDatabase:       "failed to get the database",
+		msgAPIUpdateDBProps:     "failed to update the database properties",
+		msgAPIQueryDB:           "failed to query the database",
+	},
+}
+
+// language 错误信息的语言，对应Addition中的Language
+type language string
+
+// text 返回code在该语言中的文本
+func (l language) text(code msgCode) string {
+	if msg, ok := catalogue[string(l)][code]; ok {
+		return msg
+	}
+	if msg, ok := catalogue[defaultLanguage][code]; ok {
+		return msg
+	}
+	return string(code)
+}
+
+// lang 存储配置的错误信息语言
+func (d *Notion) lang() language {
+	return language(d.Language)
+}
+
+// errorf 按该语言的文本格式化错误，文本中的%w包装args中的错误
+func (l language) errorf(code msgCode, args ...interface{}) error {
+	return fmt.Errorf(l.text(code), args...)
+}
@@ -0,0 +1,65 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+var verbPattern = regexp.MustCompile(`%[a-z]`)
+
+// TestCatalogue 各语言包含相同的代码，格式化动词及其顺序相同
+func TestCatalogue(t *testing.T) {
+	base := catalogue[defaultLanguage]
+	for lang, msgs := range catalogue {
+		if len(msgs) != len(base) {
+			t.Errorf("%s: expect %d messages, got %d", lang, len(base), len(msgs))
+		}
+		for code, msg := range msgs {
+			want, ok := base[code]
+			if !ok {
+				t.Errorf("%s: %s is missing in %s", lang, code, defaultLanguage)
+				continue
+			}
+			if got, verbs := strings.Join(verbPattern.FindAllString(msg, -1), ""), strings.Join(verbPattern.FindAllString(want, -1), ""); got != verbs {
+				t.Errorf("%s: %s expects the verbs %q, got %q", lang, code, verbs, got)
+			}
+		}
+	}
+}
+
+func TestLanguageText(t *testing.T) {
+	if got := language("en").text(msgGetFile); got != "failed to get the file info: %w" {
+		t.Fatalf("expect the english text, got %s", got)
+	}
+	// 未知的语言使用默认语言，未知的代码返回代码本身
+	if got := language("fr").text(msgGetFile); got != catalogue[defaultLanguage][msgGetFile] {
+		t.Fatalf("expect the default language, got %s", got)
+	}
+	if got := language("en").text("no_such_code"); got != "no_such_code" {
+		t.Fatalf("expect the code returned, got %s", got)
+	}
+	cause := errors.New("boom")
+	err := language("en").errorf(msgGetFile, cause)
+	if err.Error() != "failed to get the file info: boom" || !errors.Is(err, cause) {
+		t.Fatalf("expect the cause wrapped, got %v", err)
+	}
+}
+
+func TestLanguage(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.Language = "en" })
+	_, err := d.Link(context.Background(), &model.Object{ID: "404"}, model.LinkArgs{})
+	if !errs.IsObjectNotFound(err) || !strings.HasPrefix(err.Error(), "failed to get the file info: ") {
+		t.Fatalf("expect an english not found error, got %v", err)
+	}
+	// 客户端的错误也使用配置的语言
+	if _, err := d.notionClient.PageFileURL("no-such-page", 0); err == nil || strings.ContainsAny(err.Error(), "失败获取") {
+		t.Fatalf("expect an english client error, got %v", err)
+	}
+}
@@ -7,16 +7,85 @@ import (
 
 type Addition struct {
 	driver.RootID
-	NotionCookie     string `json:"notion_cookie" required:"true"`
-	NotionToken      string `json:"notion_token" required:"true"`
-	NotionSpaceID    string `json:"notion_space_id" required:"true"`
-	NotionDatabaseID string `json:"notion_database_id" required:"true"`
-	NotionFilePageID string `json:"notion_file_page_id" required:"true"`
-	DBUser           string `json:"db_user" default:"root"`
-	DBPass           string `json:"db_pass" default:"123456"`
-	DBHost           string `json:"db_host" default:"localhost"`
-	DBPort           string `json:"db_port" default:"3306"`
-	DBName           string `json:"db_name" default:"filesystem"`
+	AccessMode          string `json:"access_mode" type:"select" options:"read_write,read_only,write_once" default:"read_write" help:"read_only rejects all changes; write_once only allows uploading new files and creating folders, existing files can't be overwritten, renamed, moved or removed, for archives shared with other users"`
+	RootPath            string `json:"root_path" help:"path of the directory in the tree of this Notion database mounted as the root, e.g. /media, created if missing; lets several storages on one database expose different subtrees; overrides root_folder_id"`
+	UserHome            bool   `json:"user_home" default:"false" help:"give each non-admin alist user a home folder named after the username under the root, created on first access, and show them only that folder as the root; admins and background tasks without a user see the whole tree; turns off the list cache, and methods that act on the whole storage, such as snapshots and stats, are only allowed for admins; the search index of alist, if built, covers the files of all users"`
+	NotionCookie        string `json:"notion_cookie" required:"true" help:"with master_key set in the server config, this and the token, db_pass and secondary credentials are saved encrypted in the database and only decrypted in memory; these, encryption_key and share_secret can also be env:NAME or file:/path, such as file:/run/secrets/notion_cookie, to read the value from an environment variable or a file when the storage is loaded"`
+	NotionToken         string `json:"notion_token" required:"true"`
+	NotionSpaceID       string `json:"notion_space_id" required:"true"`
+	NotionDatabaseID    string `json:"notion_database_id" required:"true"`
+	NotionFilePageID    string `json:"notion_file_page_id" required:"true"`
+	DBUser              string `json:"db_user" default:"root"`
+	DBPass              string `json:"db_pass" default:"123456"`
+	DBHost              string `json:"db_host" default:"localhost"`
+	DBPort              string `json:"db_port" default:"3306"`
+	DBName              string `json:"db_name" default:"filesystem"`
+	VersionRetention    int    `json:"version_retention" type:"number" default:"0" help:"keep N previous versions when a file is overwritten, 0 to disable"`
+	AdaptiveChunk       bool   `json:"adaptive_chunk" default:"false" help:"adapt chunk size to the measured upload throughput and failures"`
+	MinChunkSize        int64  `json:"min_chunk_size" type:"number" default:"256" help:"lower bound of adaptive chunk size in MB"`
+	CopyMode            string `json:"copy_mode" type:"select" options:"link,duplicate" default:"link" help:"link: copies share the Notion pages of the source; duplicate: upload a separate copy to Notion"`
+	ArchiveOnDelete     bool   `json:"archive_on_delete" default:"false" help:"archive the Notion pages of deleted or replaced files that are no longer referenced"`
+	Thumbnail           bool   `json:"thumbnail" default:"false" help:"generate thumbnails of images and videos on first request and store them in Notion, videos need ffmpeg"`
+	ImageResize         bool   `json:"image_resize" default:"false" help:"when a JPEG, PNG, GIF, TIFF or BMP image up to 50MB is served through the proxy of alist, resize it to fit within the w and h query parameters and convert it to the fmt parameter (jpeg, png or gif), with q as the JPEG quality, e.g. ?w=320&fmt=jpeg; images are never enlarged"`
+	ResizeCacheSize     int    `json:"resize_cache_size" type:"number" default:"64" help:"MB of resized images kept in memory for an hour, 0 to resize on every request"`
+	EncryptionKey       string `json:"encryption_key" help:"encrypt new uploads with AES-256-GCM before they are sent to Notion, 64 hex chars or a passphrase; files uploaded with a lost key can't be read, thumbnails are disabled"`
+	UploadLimit         int    `json:"upload_limit" type:"number" default:"0" help:"max upload speed to Notion in KB/s, 0 for unlimited; applied on top of the global server upload limit"`
+	UploadThreads       int    `json:"upload_threads" type:"number" default:"1" help:"upload attachments over 20MB as parts of 20MB in this many parallel requests through the Notion file upload API instead of one PUT to S3, up to threads+1 parts are kept in memory"`
+	S3StorageClass      string `json:"s3_storage_class" help:"override the x-amz-storage-class given by Notion for uploads to its S3, e.g. STANDARD_IA; empty to keep Notion's value; S3 rejects the upload if Notion's signature doesn't allow the value"`
+	S3Tagging           string `json:"s3_tagging" help:"override the S3 object tagging given by Notion for uploads, URL query encoded such as key1=value1&key2=value2; empty to keep Notion's value; S3 rejects the upload if Notion's signature doesn't allow the value"`
+	APIBaseURL          string `json:"api_base_url" default:"https://www.notion.so/api/v3" help:"base URL of the Notion web API used to get upload URLs and set attachments, change it to go through a proxy, a mirror or a test server"`
+	PublicAPIBaseURL    string `json:"public_api_base_url" default:"https://api.notion.com/v1" help:"base URL of the public Notion API used for pages, databases and multipart uploads"`
+	S3BaseURL           string `json:"s3_base_url" default:"https://prod-files-secure.s3.us-west-2.amazonaws.com/" help:"URL of the S3 bucket that form uploads are posted to, uploads to signed URLs given by Notion aren't affected"`
+	ShareSecret         string `json:"share_secret" help:"HMAC key of the expiring download links made by the create_share method, the links are proxied by this server and skip the sign and folder password; empty to disable, changing it revokes all links"`
+	DownloadLimit       int    `json:"download_limit" type:"number" default:"0" help:"max speed in KB/s of reading chunked files, shared by all connections of this storage, 0 for unlimited"`
+	APIRateLimit        int    `json:"api_rate_limit" type:"number" default:"0" help:"max Notion API requests per second of this storage, 0 for no limit; lowered when Notion throttles the requests, by the Retry-After or X-RateLimit headers, and raised back gradually"`
+	ConnDownloadLimit   int    `json:"conn_download_limit" type:"number" default:"0" help:"max speed in KB/s of reading chunked files per connection, 0 for unlimited"`
+	ExtraHashes         bool   `json:"extra_hashes" default:"false" help:"also compute MD5 and SHA256 of uploaded files"`
+	UploadAllowExt      string `json:"upload_allow_ext" help:"comma separated extensions allowed to upload, such as jpg,png,tar.gz; empty to allow all"`
+	UploadBlockExt      string `json:"upload_block_ext" help:"comma separated extensions rejected on upload, checked after upload_allow_ext"`
+	MaxUploadSize       int    `json:"max_upload_size" type:"number" default:"0" help:"max size in MB of an uploaded file, and of a file after appending; 0 for unlimited"`
+	ScanCommand         string `json:"scan_command" help:"command run before an upload is sent to Notion, with the path of the upload cached in a temp file in place of {path} or as the last argument, e.g. clamscan --no-summary; exit code 0 accepts the file, 1 rejects it, others are scanner errors; uploads through upload sessions aren't scanned"`
+	ScanURL             string `json:"scan_url" help:"URL the content of an upload is POSTed to before it's sent to Notion, with the X-File-Name and X-File-Size headers; 2xx accepts the file, 4xx rejects it with the body as the reason, others are scanner errors"`
+	ScanTimeout         int    `json:"scan_timeout" type:"number" default:"300" help:"seconds the scan of an upload may take, 0 for no limit"`
+	ScanReject          string `json:"scan_reject" type:"select" options:"reject,log" default:"reject" help:"reject: fail the upload of a rejected file; log: only log it, to try out the scanner"`
+	ScanOnError         string `json:"scan_on_error" type:"select" options:"reject,allow" default:"reject" help:"what to do with the upload when the scanner fails or times out"`
+	MimeTypes           string `json:"mime_types" type:"text" help:"override the content type of uploads by extension, one ext:type per line, e.g. mkv:video/x-matroska"`
+	WebhookURL          string `json:"webhook_url" help:"POST the webhook template to this URL when the events below happen"`
+	WebhookEvents       string `json:"webhook_events" default:"upload,delete,upload_failed" help:"comma separated events to send: upload, delete, upload_failed, scrub_failed"`
+	WebhookTemplate     string `json:"webhook_template" type:"text" default:"{\"event\":{{json .Event}},\"path\":{{json .Path}},\"size\":{{.Size}},\"user\":{{json .UserName}},\"error\":{{json .Error}}}" help:"Go template of the JSON body, variables: .Event .Storage .Path .Name .Size .UserName .Error .Time, use json to quote strings"`
+	AuditLog            bool   `json:"audit_log" default:"true" help:"record mkdir, move, rename, copy, remove, put and append in the database, query them with the list_audit_logs method"`
+	UploadSessionTTL    int    `json:"upload_session_ttl" type:"number" default:"24" help:"hours an upload session is kept after its last uploaded part, parts of expired sessions are archived"`
+	HealthCheckInterval int    `json:"health_check_interval" type:"number" default:"5" help:"minutes between checks of MySQL, the Notion API and the S3 storing the attachments; the storage status shows degraded or disabled with the reason after 3 failures in a row, 0 to disable"`
+	ScrubPerDay         int    `json:"scrub_per_day" type:"number" default:"0" help:"read back this many chunks or unchunked files a day, spread over the day, least recently checked first, and compare their SHA1 to detect corruption; mismatches are logged and sent as the scrub_failed webhook event, see the scrub_status method, 0 to disable"`
+	SnapshotInterval    int    `json:"snapshot_interval" type:"number" default:"0" help:"hours between automatic metadata snapshots, restore them with the restore_snapshot method to roll back a wrong delete or sync; 0 to disable"`
+	SnapshotRetention   int    `json:"snapshot_retention" type:"number" default:"7" help:"number of automatic snapshots kept, older ones are deleted; 0 to keep all"`
+	PackSize            int    `json:"pack_size" type:"number" default:"0" help:"store files up to this size in KB as attachments of shared Notion pages, pack_count files per page, cutting the page creations for folders of small files; at most 5120, 0 to disable; packed pages aren't renamed or mirrored"`
+	PackCount           int    `json:"pack_count" type:"number" default:"50" help:"number of files packed in one Notion page"`
+	HotCacheSize        int    `json:"hot_cache_size" type:"number" default:"0" help:"keep the content of recently read small files and thumbnails in memory, up to this many MB in total, so repeated reads of subtitles, NFO files and thumbnails don't go to Notion; such files are then served through this server instead of a redirect; 0 to disable"`
+	HotCacheFileSize    int    `json:"hot_cache_file_size" type:"number" default:"512" help:"max size in KB of a file kept in the hot cache"`
+	HotCacheTTL         int    `json:"hot_cache_ttl" type:"number" default:"10" help:"minutes a file is kept in the hot cache after it was read"`
+	ReaderKeepAlive     int    `json:"reader_keep_alive" type:"number" default:"10" help:"seconds the download of a chunked file opened for a small range request is kept open, so the next adjacent or overlapping small range of the same client is read from it instead of a new connection, as video players and WebDAV clients send many; 0 to disable"`
+	VideoPrefetch       bool   `json:"video_prefetch" default:"true" help:"when a chunked MP4, M4V, MOV, M4A or 3GP file is opened, read its first 64KB and its moov atom, at the head or the tail, in the background and serve the first requests of players from memory, so playback starts without waiting for several chunk reads; moov atoms over 16MB aren't prefetched"`
+	InlineSize          int    `json:"inline_size" type:"number" default:"0" help:"store files up to this size in KB in the database instead of a Notion page each, at most 1024, 0 to only keep empty files in the database"`
+	Normalization       string `json:"normalization" type:"select" options:"none,NFC,NFD" default:"none" help:"Unicode normalization of the names of new files and folders, an upload or new folder whose name differs from an existing one only in normalization replaces or reuses it; macOS clients often send NFD"`
+	CaseInsensitive     bool   `json:"case_insensitive" default:"false" help:"an upload or new folder whose name differs from an existing one only in case replaces or reuses it"`
+	Dedup               bool   `json:"dedup" default:"true" help:"when the uploaded file comes with a SHA1 read from another storage, such as a copy, share the pages of a file with the same SHA1 and size under the root of this storage (or of the user with user_home) instead of uploading it again; SHA1s sent by clients are not trusted"`
+	MirrorMeta          bool   `json:"mirror_meta" default:"false" help:"write the path, size, SHA1 and modified time of files as properties of their Notion pages, the properties are created in the database; makes the database readable in Notion and allows rebuilding the metadata from it; the real names are visible in Notion even with obfuscate_names"`
+	ObfuscateNames      bool   `json:"obfuscate_names" default:"false" help:"use random IDs as the titles and attachment names of new Notion pages, the real names are only kept in the database"`
+	ChunkDatabaseID     string `json:"chunk_database_id" help:"create the chunk pages of large files in this Notion database instead of the main one, keeping the main database to one page per file; duplicate the main database to create it, the file property must have the same ID"`
+	SecondaryDatabaseID string `json:"secondary_database_id" help:"mirror uploaded files and chunks to this Notion database, usually in another workspace, and read from it when the page or download of the main one fails; empty to disable; packed pages and thumbnails aren't mirrored, a failed mirror write is only logged"`
+	SecondarySpaceID    string `json:"secondary_space_id" help:"space ID of the secondary database, empty for notion_space_id"`
+	SecondaryToken      string `json:"secondary_token" help:"integration token with access to the secondary database, empty for notion_token"`
+	SecondaryCookie     string `json:"secondary_cookie" help:"cookie of a user of the secondary workspace, empty for notion_cookie"`
+	SecondaryFilePageID string `json:"secondary_file_page_id" help:"ID of the file property of the secondary database, empty for notion_file_page_id"`
+	ChunkMode           string `json:"chunk_mode" type:"select" options:"fixed,cdc" default:"fixed" help:"fixed: split files over 5GB into chunks of 4.5GB, or of adaptive size; cdc: split files over cdc_avg_size at offsets chosen by a rolling hash of the content, so the versions of a large file share most chunks, use with chunk_dedup"`
+	CDCAvgSize          int    `json:"cdc_avg_size" type:"number" default:"64" help:"average size in MB of the chunks in cdc mode, the chunks are between 1/4 and 4 times of it"`
+	ChunkDedup          bool   `json:"chunk_dedup" default:"false" help:"hash each chunk before uploading it and reuse the Notion page of a stored chunk with the same SHA1 and size, so identical chunks of different files are uploaded once; costs an extra read of each chunk, not used with encryption_key"`
+	ParityData          int    `json:"parity_data" type:"number" default:"0" help:"protect every this many chunks of a file uploaded in one request with parity_shards Reed-Solomon parity chunks stored as extra Notion pages, so lost or corrupted chunk pages can be rebuilt with the repair_chunks method; 0 to disable, appending to a file drops its parity"`
+	ParityShards        int    `json:"parity_shards" type:"number" default:"1" help:"number of parity chunks of every parity_data chunks, up to this many chunks of a group can be rebuilt"`
+	ChunkNameTemplate   string `json:"chunk_name_template" default:"{{.Name}}.chunk{{.Index}}" help:"Go template of the titles of new chunk pages, variables: .Name .Base .Ext .Index, .Base is the name without .Ext"`
+	ValidateOnly        bool   `json:"validate_only" default:"false" help:"check the configuration instead of mounting the storage: connect to MySQL and query it, query the Notion database, create a page, get an upload URL for it and archive it, then show in the status which steps passed and the error of the failed one; no files are written, turn it off to mount; the validate method of a mounted storage runs the same checks"`
+	Language            string `json:"language" type:"select" options:"zh-CN,en" default:"zh-CN" help:"language of the error messages returned by the driver"`
 }
 
 var config = driver.Config{
@@ -0,0 +1,178 @@
+package notion
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+// 驱动的监控指标，注册到默认的Registry，由/metrics输出
+var (
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alist",
+		Subsystem: "notion",
+		Name:      "api_requests_total",
+		Help:      "Requests sent to Notion and S3 by endpoint and status code, status is error if no response was received.",
+	}, []string{"endpoint", "status"})
+	apiDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "alist",
+		Subsystem: "notion",
+		Name:      "api_request_duration_seconds",
+		Help:      "Time until the response headers of the requests sent to Notion and S3 are received.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"endpoint"})
+	transferBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alist",
+		Subsystem: "notion",
+		Name:      "transfer_bytes_total",
+		Help:      "Bytes uploaded to and downloaded from Notion through this server, redirected downloads aren't counted.",
+	}, []string{"direction"})
+	retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alist",
+		Subsystem: "notion",
+		Name:      "retries_total",
+		Help:      "Retried uploads and downloads by operation.",
+	}, []string{"op"})
+	cacheRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "alist",
+		Subsystem: "notion",
+		Name:      "cache_requests_total",
+		Help:      "Lookups of the chunk link cache, the hot cache and the chunk dedup index by result, hit or miss.",
+	}, []string{"cache", "result"})
+	dbDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "alist",
+		Subsystem: "notion",
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of the queries to the metadata database by operation.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation"})
+)
+
+func init() {
+	prometheus.MustRegister(apiRequests, apiDuration, transferBytes, retries, cacheRequests, dbDuration)
+}
+
+// apiClient 发送Notion和S3请求的客户端，记录请求数和耗时，请求在span中时创建子span
+var apiClient = &http.Client{Transport: metricsTransport{base: http.DefaultTransport}}
+
+type metricsTransport struct {
+	base http.RoundTripper
+}
+
+func (t metricsTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := apiEndpoint(req.URL)
+	var span trace.Span
+	if trace.SpanContextFromContext(req.Context()).IsValid() {
+		_, span = tracer.Start(req.Context(), req.Method+" "+endpoint, trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(attribute.String("http.method", req.Method), attribute.String("endpoint", endpoint)))
+	}
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	apiDuration.WithLabelValues(endpoint).Observe(time.Since(start).Seconds())
+	status := "error"
+	if err == nil {
+		status = strconv.Itoa(resp.StatusCode)
+	}
+	apiRequests.WithLabelValues(endpoint, status).Inc()
+	if span != nil {
+		span.SetAttributes(attribute.String("http.status", status))
+		endSpan(span, err)
+	}
+	return resp, err
+}
+
+var idSegment = regexp.MustCompile(`^[0-9a-fA-F]{8}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{4}-?[0-9a-fA-F]{12}$`)
+
+// apiEndpoint 请求的接口，路径中的页面ID和属性ID替换为:id以限制标签的数量，
+// 下载附件的file.notion.so地址统一为file，上传和下载附件的S3地址统一为s3
+func apiEndpoint(u *url.URL) string {
+	host := u.Hostname()
+	if host == "file.notion.so" {
+		return "file"
+	}
+	if host != "notion.so" && !strings.HasSuffix(host, ".notion.so") && host != "api.notion.com" {
+		return "s3"
+	}
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	for i, s := range segments {
+		if idSegment.MatchString(s) || (i > 0 && segments[i-1] == "properties") {
+			segments[i] = ":id"
+		}
+	}
+	return "/" + strings.Join(segments, "/")
+}
+
+// countReader 统计读取的字节数
+type countReader struct {
+	io.Reader
+	counter prometheus.Counter
+}
+
+func (r *countReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.counter.Add(float64(n))
+	return n, err
+}
+
+// countDownload 统计从rc下载的字节数
+func countDownload(rc io.ReadCloser) io.ReadCloser {
+	return struct {
+		io.Reader
+		io.Closer
+	}{&countReader{Reader: rc, counter: transferBytes.WithLabelValues("download")}, rc}
+}
+
+// countCache 记录一次缓存查询的结果
+func countCache(cache string, hit bool) {
+	result := "miss"
+	if hit {
+		result = "hit"
+	}
+	cacheRequests.WithLabelValues(cache, result).Inc()
+}
+
+const dbStartKey = "notion:metrics_start"
+
+type dbCallback interface {
+	Register(name string, fn func(*gorm.DB)) error
+}
+
+// registerDBMetrics 在元数据数据库的各类操作前后记录耗时
+func registerDBMetrics(db *gorm.DB) error {
+	cb := db.Callback()
+	for _, p := range []struct {
+		operation     string
+		before, after dbCallback
+	}{
+		{"create", cb.Create().Before("*"), cb.Create().After("*")},
+		{"query", cb.Query().Before("*"), cb.Query().After("*")},
+		{"update", cb.Update().Before("*"), cb.Update().After("*")},
+		{"delete", cb.Delete().Before("*"), cb.Delete().After("*")},
+		{"row", cb.Row().Before("*"), cb.Row().After("*")},
+		{"raw", cb.Raw().Before("*"), cb.Raw().After("*")},
+	} {
+		observer := dbDuration.WithLabelValues(p.operation)
+		if err := p.before.Register(dbStartKey+"_before", func(tx *gorm.DB) {
+			tx.InstanceSet(dbStartKey, time.Now())
+		}); err != nil {
+			return err
+		}
+		if err := p.after.Register(dbStartKey+"_after", func(tx *gorm.DB) {
+			if start, ok := tx.InstanceGet(dbStartKey); ok {
+				observer.Observe(time.Since(start.(time.Time)).Seconds())
+			}
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestAPIEndpoint(t *testing.T) {
+	for raw, want := range map[string]string{
+		"https://www.notion.so/api/v3/getUploadFileUrl":                                     "/api/v3/getUploadFileUrl",
+		"https://api.notion.com/v1/pages/0123456789abcdef0123456789abcdef":                  "/v1/pages/:id",
+		"https://api.notion.com/v1/pages/01234567-89ab-cdef-0123-456789abcdef/properties/a": "/v1/pages/:id/properties/:id",
+		"https://api.notion.com/v1/databases/0123456789abcdef0123456789abcdef/query":        "/v1/databases/:id/query",
+		"https://file.notion.so/f/s/a.bin":                                                  "file",
+		"https://prod-files-secure.s3.us-west-2.amazonaws.com/a/b.bin":                      "s3",
+	} {
+		u, err := url.Parse(raw)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := apiEndpoint(u); got != want {
+			t.Errorf("%s: expect %s, got %s", raw, want, got)
+		}
+	}
+}
+
+// dbQueryCount 返回元数据数据库query操作的观测次数
+func dbQueryCount(t *testing.T) uint64 {
+	families, err := prometheus.DefaultGatherer.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, family := range families {
+		if family.GetName() != "alist_notion_db_query_duration_seconds" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			for _, label := range m.GetLabel() {
+				if label.GetName() == "operation" && label.GetValue() == "query" {
+					return m.GetHistogram().GetSampleCount()
+				}
+			}
+		}
+	}
+	return 0
+}
+
+func TestMetrics(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.HotCacheSize = 1
+		d.HotCacheFileSize = 2
+		d.HotCacheTTL = 10
+	})
+	ctx := context.Background()
+	// 假服务器的地址不是Notion的域名，都计为s3
+	ok := apiRequests.WithLabelValues("s3", "200")
+	failed := apiRequests.WithLabelValues("s3", "502")
+	upload := transferBytes.WithLabelValues("upload")
+	download := transferBytes.WithLabelValues("download")
+	putRetries := retries.WithLabelValues("put")
+	hit, miss := cacheRequests.WithLabelValues("hot", "hit"), cacheRequests.WithLabelValues("hot", "miss")
+	before := map[prometheus.Collector]float64{}
+	for _, c := range []prometheus.Collector{ok, failed, upload, download, putRetries, hit, miss} {
+		before[c] = testutil.ToFloat64(c)
+	}
+	delta := func(c prometheus.Collector) float64 { return testutil.ToFloat64(c) - before[c] }
+	queries := dbQueryCount(t)
+
+	fake.failNext(http.MethodPut, "/s3/", http.StatusBadGateway, 1)
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.txt", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if _, err := d.Link(ctx, obj, model.LinkArgs{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := delta(failed); n != 1 {
+		t.Fatalf("expect a failed request counted, got %v", n)
+	}
+	if n := delta(ok); n < 2 {
+		t.Fatalf("expect the successful requests counted, got %v", n)
+	}
+	if n := delta(putRetries); n != 1 {
+		t.Fatalf("expect a retry counted, got %v", n)
+	}
+	// 重试时重新上传，下载一次后由缓存返回
+	if n := delta(upload); n != 2000 {
+		t.Fatalf("expect 2000 bytes uploaded, got %v", n)
+	}
+	if n := delta(download); n != 1000 {
+		t.Fatalf("expect 1000 bytes downloaded, got %v", n)
+	}
+	if delta(miss) != 1 || delta(hit) != 1 {
+		t.Fatalf("expect a cache miss and a hit, got %v and %v", delta(miss), delta(hit))
+	}
+	if n := dbQueryCount(t); n <= queries {
+		t.Fatal("expect the database queries observed")
+	}
+}
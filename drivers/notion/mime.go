@@ -0,0 +1,69 @@
+package notion
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// sniffSize http.DetectContentType最多使用的字节数
+const sniffSize = 512
+
+// genericTypes 嗅探结果为这些类型时信息不足（如docx会被识别为zip），改用后缀判断
+var genericTypes = []string{"application/octet-stream", "text/plain; charset=utf-8", "application/zip"}
+
+// contentType 上传到Notion的ContentType，自定义的后缀映射优先，其次是内容嗅探，嗅探结果不明确时按后缀判断
+func (d *Notion) contentType(name string, head []byte) string {
+	ext := strings.ToLower(strings.TrimPrefix(filepath.Ext(name), "."))
+	if t, ok := d.mimeTypes[ext]; ok {
+		return t
+	}
+	if len(head) > 0 {
+		if t := http.DetectContentType(head); !utils.SliceContains(genericTypes, t) {
+			return t
+		}
+	}
+	return GetContentType(name)
+}
+
+// sniffHead 读取流的开头用于嗅探类型，不影响之后对流的读取
+func sniffHead(file model.FileStreamer) ([]byte, error) {
+	size := min(int64(sniffSize), file.GetSize())
+	if size <= 0 {
+		return nil, nil
+	}
+	r, err := file.RangeRead(http_range.Range{Start: 0, Length: size})
+	if err != nil {
+		return nil, err
+	}
+	head := make([]byte, size)
+	if _, err := io.ReadFull(r, head); err != nil {
+		return nil, err
+	}
+	return head, nil
+}
+
+// parseMimeTypes 解析自定义的后缀映射，每行一个 ext:type
+func parseMimeTypes(s string) (map[string]string, error) {
+	res := make(map[string]string)
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		ext, t, ok := strings.Cut(line, ":")
+		ext = strings.ToLower(strings.TrimPrefix(strings.TrimSpace(ext), "."))
+		t = strings.TrimSpace(t)
+		if !ok || ext == "" || t == "" {
+			return nil, fmt.Errorf("无效的MIME类型映射: %s", line)
+		}
+		res[ext] = t
+	}
+	return res, nil
+}
@@ -0,0 +1,95 @@
+package notion
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+func TestContentType(t *testing.T) {
+	d := &Notion{mimeTypes: map[string]string{"mkv": "video/x-matroska"}}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	zip := []byte("PK\x03\x04\x14\x00\x00\x00")
+	cases := []struct {
+		name string
+		head []byte
+		want string
+	}{
+		// 自定义映射优先，不区分大小写
+		{"a.mkv", nil, "video/x-matroska"},
+		{"a.MKV", png, "video/x-matroska"},
+		// 嗅探结果优先于后缀
+		{"a.bin", png, "image/png"},
+		// 嗅探结果不明确时按后缀判断
+		{"a.pdf", zip, "application/pdf"},
+		{"a.unknownext", nil, "application/octet-stream"},
+	}
+	for _, c := range cases {
+		if got := d.contentType(c.name, c.head); got != c.want {
+			t.Errorf("content type of %s is %q, want %q", c.name, got, c.want)
+		}
+	}
+}
+
+func TestParseMimeTypes(t *testing.T) {
+	types, err := parseMimeTypes(" .MKV : video/x-matroska \n\nm4b:audio/mp4\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(types) != 2 || types["mkv"] != "video/x-matroska" || types["m4b"] != "audio/mp4" {
+		t.Fatalf("unexpected mapping %v", types)
+	}
+	for _, s := range []string{"mkv", "mkv:", ":video/x-matroska"} {
+		if _, err := parseMimeTypes(s); err == nil {
+			t.Errorf("expect %q rejected", s)
+		}
+	}
+}
+
+func TestUploadContentType(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.MimeTypes = "bin:application/x-test" })
+	for name, data := range map[string][]byte{
+		"a.bin":   testData(1000),
+		"img.dat": append([]byte("\x89PNG\r\n\x1a\n"), testData(1000)...),
+	} {
+		if _, err := d.Put(context.Background(), rootDir(d), newTestStream(name, data), func(float64) {}); err != nil {
+			t.Fatalf("put %s: %v", name, err)
+		}
+	}
+	for name, want := range map[string]string{"a.bin": "application/x-test", "img.dat": "image/png"} {
+		if got := fake.contentTypes[name]; got != want {
+			t.Errorf("%s uploaded as %q, want %q", name, got, want)
+		}
+	}
+
+	d.MimeTypes = "bin"
+	if err := d.Init(context.Background()); err == nil {
+		t.Fatal("expect an invalid mapping rejected")
+	}
+}
+
+// TestChunkContentType 分块按整个文件的开头嗅探类型
+func TestChunkContentType(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	data := append([]byte("\x89PNG\r\n\x1a\n"), testData(3*1024*1024)...)
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("big.dat", data), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	chunks := 0
+	for name, typ := range fake.contentTypes {
+		if strings.HasPrefix(name, "big.dat.chunk") {
+			chunks++
+			if typ != "image/png" {
+				t.Errorf("%s uploaded as %q, want image/png", name, typ)
+			}
+		}
+	}
+	if chunks < 2 {
+		t.Fatalf("expect several chunks, got %d", chunks)
+	}
+}
@@ -0,0 +1,164 @@
+package notion
+
+import (
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// 镜像元数据时写入的页面属性
+const (
+	propPath     = "alist_path"
+	propSize     = "alist_size"
+	propSHA1     = "alist_sha1"
+	propModified = "alist_modified"
+	propChunk    = "alist_chunk"
+	// propMeta 完整元数据的JSON，用于从Notion重建数据库
+	propMeta = "alist_meta"
+)
+
+// richTextLimit Notion单个文本对象的最大字符数
+const richTextLimit = 2000
+
+// PageMeta 镜像到页面属性的文件元数据，分块页面的Chunk为该分块的信息
+type PageMeta struct {
+	Path      string     `json:"path"`
+	Size      int64      `json:"size"`
+	SHA1      string     `json:"sha1,omitempty"`
+	MD5       string     `json:"md5,omitempty"`
+	SHA256    string     `json:"sha256,omitempty"`
+	Modified  time.Time  `json:"modified"`
+	ChunkSize int64      `json:"chunk_size,omitempty"`
+	Chunk     *ChunkMeta `json:"chunk,omitempty"`
+}
+
+// ChunkMeta 分块页面对应的分块
+type ChunkMeta struct {
+	Index int    `json:"index"`
+	Start int64  `json:"start"`
+	End   int64  `json:"end"`
+	SHA1  string `json:"sha1,omitempty"`
+	Nonce string `json:"nonce,omitempty"`
+	KeyID string `json:"key_id,omitempty"`
+}
+
+// metaProperties 需要在数据库中创建的属性
+func metaProperties() map[string]interface{} {
+	return map[string]interface{}{
+		propPath:     map[string]interface{}{"rich_text": struct{}{}},
+		propSize:     map[string]interface{}{"number": map[string]string{"format": "number"}},
+		propSHA1:     map[string]interface{}{"rich_text": struct{}{}},
+		propModified: map[string]interface{}{"date": struct{}{}},
+		propChunk:    map[string]interface{}{"number": map[string]string{"format": "number"}},
+		propMeta:     map[string]interface{}{"rich_text": struct{}{}},
+	}
+}
+
+// richText 转换为rich_text属性的值，超长的文本拆分为多个文本对象
+func richText(s string) map[string]interface{} {
+	texts := []interface{}{}
+	runes := []rune(s)
+	for len(runes) > 0 {
+		n := min(len(runes), richTextLimit)
+		texts = append(texts, map[string]interface{}{"text": map[string]string{"content": string(runes[:n])}})
+		runes = runes[n:]
+	}
+	return map[string]interface{}{"rich_text": texts}
+}
+
+func (m *PageMeta) properties() (map[string]interface{}, error) {
+	data, err := utils.Json.MarshalToString(m)
+	if err != nil {
+		return nil, err
+	}
+	props := map[string]interface{}{
+		propPath:     richText(m.Path),
+		propSize:     map[string]interface{}{"number": m.Size},
+		propSHA1:     richText(m.SHA1),
+		propModified: map[string]interface{}{"date": map[string]string{"start": m.Modified.Format(time.RFC3339)}},
+		propChunk:    map[string]interface{}{"number": nil},
+		propMeta:     richText(data),
+	}
+	if m.Chunk != nil {
+		props[propChunk] = map[string]interface{}{"number": m.Chunk.Index}
+	}
+	return props, nil
+}
+
+// mirrorFile 将文件的元数据写入其全部页面的属性，保存在数据库中的文件没有页面，打包页面保存多个文件，都不镜像；
+// 与复制的文件共享的页面记录最后写入的文件。失败时只记录日志
+func (d *Notion) mirrorFile(f *File) {
+	if !d.MirrorMeta || f.IsInline() || f.Packed {
+		return
+	}
+	meta := PageMeta{
+		Path:     path.Join(d.tree.FullDirPath(f.DirectoryID), f.Name),
+		Size:     f.Size,
+		SHA1:     f.SHA1,
+		MD5:      f.MD5,
+		SHA256:   f.SHA256,
+		Modified: f.UpdatedAt,
+	}
+	if !f.IsChunked {
+		d.writePageMeta(f.BlobKey, &meta)
+		return
+	}
+	chunks, err := d.fileChunks(f.ID)
+	if err != nil {
+		log.Warnf("镜像文件[%s]的元数据失败: %+v", meta.Path, err)
+		return
+	}
+	meta.ChunkSize = f.ChunkSize
+	for _, chunk := range chunks {
+		meta.Chunk = &ChunkMeta{
+			Index: chunk.ChunkIndex,
+			Start: chunk.StartOffset,
+			End:   chunk.EndOffset,
+			SHA1:  chunk.SHA1,
+			Nonce: chunk.Nonce,
+			KeyID: chunk.KeyID,
+		}
+		d.writePageMeta(chunk.BlobKey, &meta)
+	}
+}
+
+func (d *Notion) writePageMeta(pageID string, meta *PageMeta) {
+	props, err := meta.properties()
+	if err == nil {
+		err = d.notionClient.UpdatePageProperties(pageID, props)
+	}
+	if err != nil {
+		log.Warnf("镜像文件[%s]的元数据到页面[%s]失败: %+v", meta.Path, pageID, err)
+	}
+}
+
+// mirrorObj 镜像obj的元数据，目录的移动和重命名改变了其下全部文件的路径，在后台逐个更新
+func (d *Notion) mirrorObj(obj model.Obj) {
+	if !d.MirrorMeta || obj == nil {
+		return
+	}
+	if !obj.IsDir() {
+		f, err := d.tree.GetFile(obj.GetID())
+		if err != nil {
+			log.Warnf("镜像文件[%s]的元数据失败: %+v", obj.GetName(), err)
+			return
+		}
+		d.mirrorFile(f)
+		return
+	}
+	dirID, _ := strconv.Atoi(obj.GetID())
+	go func() {
+		files, err := d.tree.SubtreeFiles(dirID)
+		if err != nil {
+			log.Warnf("镜像目录[%s]的元数据失败: %+v", obj.GetName(), err)
+			return
+		}
+		for i := range files {
+			d.mirrorFile(&files[i])
+		}
+	}()
+}
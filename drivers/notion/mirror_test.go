@@ -0,0 +1,127 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// pageProps 返回页面的rich_text属性
+func (f *fakeNotion) pageProps(id string) map[string]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	props := make(map[string]string)
+	if page, ok := f.pages[id]; ok {
+		for name, text := range page.props {
+			props[name] = text
+		}
+	}
+	return props
+}
+
+// pageMeta 解析页面上镜像的元数据，没有时返回nil
+func pageMeta(t *testing.T, fake *fakeNotion, id string) *PageMeta {
+	t.Helper()
+	props := fake.pageProps(id)
+	if props[propMeta] == "" {
+		return nil
+	}
+	var meta PageMeta
+	if err := utils.Json.UnmarshalFromString(props[propMeta], &meta); err != nil {
+		t.Fatalf("page %s: %v", id, err)
+	}
+	if props[propPath] != meta.Path || props[propSHA1] != meta.SHA1 {
+		t.Fatalf("page %s: expect the properties to match the metadata, got %v", id, props)
+	}
+	return &meta
+}
+
+func TestMirrorMeta(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.MirrorMeta = true })
+	// 初始化时在数据库中创建属性
+	if n := fake.count(http.MethodPatch, "/v1/databases/"); n != 1 {
+		t.Fatalf("expect the properties created once, got %d", n)
+	}
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := testData(1000)
+	obj, err := d.Put(ctx, dir, newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageID := blobKey(t, d, obj.GetID())
+	meta := pageMeta(t, fake, pageID)
+	if meta == nil || meta.Path != "/docs/a.bin" || meta.Size != 1000 || meta.SHA1 != sha1Hex(data) || meta.Chunk != nil {
+		t.Fatalf("expect the metadata of /docs/a.bin, got %+v", meta)
+	}
+
+	// 重命名文件和目录后更新路径，目录下的文件在后台更新
+	if _, err := d.Rename(ctx, obj, "b.bin"); err != nil {
+		t.Fatal(err)
+	}
+	if meta := pageMeta(t, fake, pageID); meta.Path != "/docs/b.bin" {
+		t.Fatalf("expect /docs/b.bin, got %s", meta.Path)
+	}
+	if _, err := d.Rename(ctx, dir, "papers"); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for pageMeta(t, fake, pageID).Path != "/papers/b.bin" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect /papers/b.bin, got %s", pageMeta(t, fake, pageID).Path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestMirrorMetaChunks(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.MirrorMeta = true
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", testData(3*1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ?", obj.GetID()).Order("chunk_index").Find(&chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expect several chunks, got %d", len(chunks))
+	}
+	// 每个分块页面记录文件和该分块的信息
+	for _, c := range chunks {
+		meta := pageMeta(t, fake, c.BlobKey)
+		if meta == nil || meta.Path != "/big.bin" || meta.Size != obj.GetSize() || meta.Chunk == nil {
+			t.Fatalf("chunk %d: expect the metadata of /big.bin, got %+v", c.ChunkIndex, meta)
+		}
+		if meta.Chunk.Index != c.ChunkIndex || meta.Chunk.Start != c.StartOffset || meta.Chunk.End != c.EndOffset {
+			t.Fatalf("chunk %d: expect %d-%d, got %+v", c.ChunkIndex, c.StartOffset, c.EndOffset, meta.Chunk)
+		}
+	}
+}
+
+func TestMirrorMetaDisabled(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta := pageMeta(t, fake, blobKey(t, d, obj.GetID())); meta != nil {
+		t.Fatalf("expect no metadata mirrored, got %+v", meta)
+	}
+	if n := fake.count(http.MethodPatch, "/v1/databases/"); n != 0 {
+		t.Fatalf("expect the database untouched, got %d", n)
+	}
+}
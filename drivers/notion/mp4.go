@@ -0,0 +1,212 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Xhofe/go-cache"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// mp4HeadSize 预读的文件开头的字节数，包含ftyp和通常位于开头的moov的起始部分
+	mp4HeadSize = 64 * 1024
+	// mp4MaxMoov 预读的moov的大小上限，更大的moov按普通请求读取
+	mp4MaxMoov = 16 * 1024 * 1024
+	// mp4MaxBoxes 查找moov时最多检查的顶层box数
+	mp4MaxBoxes = 16
+	// prefetchTTL 预读内容的保留时间
+	prefetchTTL = 10 * time.Minute
+)
+
+// mp4Exts 预读moov的视频格式，均为ISO BMFF
+var mp4Exts = map[string]bool{
+	".mp4": true,
+	".m4v": true,
+	".mov": true,
+	".m4a": true,
+	".3gp": true,
+}
+
+// prefetchedRange 预读的一段内容
+type prefetchedRange struct {
+	start int64
+	data  []byte
+}
+
+// moovPrefetch 分块视频文件打开时预读的开头和moov，播放器最先请求这些范围
+type moovPrefetch struct {
+	ready  chan struct{}
+	ranges []prefetchedRange
+}
+
+// get 返回从start开始、不超过end的预读内容
+func (p *moovPrefetch) get(start, end int64) ([]byte, bool) {
+	for _, r := range p.ranges {
+		if start >= r.start && start < r.start+int64(len(r.data)) {
+			return r.data[start-r.start : min(end, r.start+int64(len(r.data)))-r.start], true
+		}
+	}
+	return nil, false
+}
+
+var prefetchMu sync.Mutex
+
+// prefetchMoov 开启video_prefetch时在后台预读分块视频文件的开头和moov，返回从预读内容读取的rrc
+func (d *Notion) prefetchMoov(f *File, rrc model.RangeReadCloserIF) model.RangeReadCloserIF {
+	if !d.VideoPrefetch || !mp4Exts[strings.ToLower(path.Ext(f.Name))] {
+		return rrc
+	}
+	key := fmt.Sprintf("%d-%d-%d", d.ID, f.ID, f.UpdatedAt.UnixNano())
+	prefetchMu.Lock()
+	p, ok := d.prefetches.Get(key)
+	if !ok {
+		p = &moovPrefetch{ready: make(chan struct{})}
+		d.prefetches.Set(key, p, cache.WithEx[*moovPrefetch](prefetchTTL))
+	}
+	prefetchMu.Unlock()
+	if !ok {
+		go func() {
+			defer close(p.ready)
+			ranges, err := readMoov(context.Background(), rrc, f.Size)
+			if err != nil {
+				log.Warnf("预读视频[%s]失败: %v", f.Name, err)
+			}
+			p.ranges = ranges
+		}()
+	}
+	return &prefetchRangeReadCloser{RangeReadCloserIF: rrc, prefetch: p, size: f.Size}
+}
+
+// readMoov 读取文件开头，按顶层box找到moov后读取它；moov在mdat之后时只读取各box的头部跳到文件末尾的moov
+func readMoov(ctx context.Context, rrc model.RangeReadCloserIF, size int64) ([]prefetchedRange, error) {
+	head, err := readAt(ctx, rrc, 0, min(mp4HeadSize, size))
+	if err != nil {
+		return nil, err
+	}
+	ranges := []prefetchedRange{{start: 0, data: head}}
+	var offset int64
+	for i := 0; i < mp4MaxBoxes && offset+8 <= size; i++ {
+		header := head[min(offset, int64(len(head))):]
+		if len(header) < 16 {
+			if header, err = readAt(ctx, rrc, offset, min(16, size-offset)); err != nil {
+				return ranges, err
+			}
+		}
+		boxSize, boxType, ok := parseBox(header, size-offset)
+		if !ok {
+			return ranges, fmt.Errorf("不是有效的MP4文件，偏移%d处的box无效", offset)
+		}
+		if boxType == "moov" {
+			if boxSize > mp4MaxMoov {
+				return ranges, nil
+			}
+			if offset+boxSize <= int64(len(head)) {
+				return ranges, nil
+			}
+			if offset < int64(len(head)) {
+				// moov从开头的内容中开始时，接着开头读取到moov的结尾
+				rest, err := readAt(ctx, rrc, int64(len(head)), offset+boxSize-int64(len(head)))
+				if err != nil {
+					return ranges, err
+				}
+				ranges[0].data = append(head, rest...)
+				return ranges, nil
+			}
+			moov, err := readAt(ctx, rrc, offset, boxSize)
+			if err != nil {
+				return ranges, err
+			}
+			return append(ranges, prefetchedRange{start: offset, data: moov}), nil
+		}
+		offset += boxSize
+	}
+	return ranges, nil
+}
+
+// parseBox 解析box的头部，返回box的大小和类型；大小为0表示box到文件结尾，remaining为box起点之后的文件大小
+func parseBox(header []byte, remaining int64) (int64, string, bool) {
+	if len(header) < 8 {
+		return 0, "", false
+	}
+	boxSize := int64(binary.BigEndian.Uint32(header))
+	boxType := string(header[4:8])
+	switch boxSize {
+	case 0:
+		boxSize = remaining
+	case 1:
+		if len(header) < 16 {
+			return 0, "", false
+		}
+		boxSize = int64(binary.BigEndian.Uint64(header[8:16]))
+	}
+	if boxSize < 8 || boxSize > remaining {
+		return 0, "", false
+	}
+	return boxSize, boxType, true
+}
+
+func readAt(ctx context.Context, rrc model.RangeReadCloserIF, start, length int64) ([]byte, error) {
+	rc, err := rrc.RangeRead(ctx, http_range.Range{Start: start, Length: length})
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data := make([]byte, length)
+	if _, err = io.ReadFull(rc, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// prefetchRangeReadCloser 范围在预读内容内的请求等待预读完成后从内存读取
+type prefetchRangeReadCloser struct {
+	model.RangeReadCloserIF
+	prefetch *moovPrefetch
+	size     int64
+}
+
+func (c *prefetchRangeReadCloser) RangeRead(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+	end := c.size
+	if httpRange.Length >= 0 {
+		end = min(httpRange.Start+httpRange.Length, c.size)
+	}
+	// 预读完成前只有开头和结尾的请求等待，拖动进度条等其他请求不受影响
+	select {
+	case <-c.prefetch.ready:
+	default:
+		if httpRange.Start >= mp4HeadSize && httpRange.Start < c.size-mp4MaxMoov {
+			return c.RangeReadCloserIF.RangeRead(ctx, httpRange)
+		}
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-c.prefetch.ready:
+		}
+	}
+	data, ok := c.prefetch.get(httpRange.Start, end)
+	countCache("moov", ok)
+	if !ok {
+		return c.RangeReadCloserIF.RangeRead(ctx, httpRange)
+	}
+	rest := httpRange.Start + int64(len(data))
+	if rest >= end {
+		return io.NopCloser(bytes.NewReader(data)), nil
+	}
+	// 超出预读内容的部分从分块读取
+	rc, err := c.RangeReadCloserIF.RangeRead(ctx, http_range.Range{Start: rest, Length: end - rest})
+	if err != nil {
+		return nil, err
+	}
+	return utils.ReadCloser{Reader: io.MultiReader(bytes.NewReader(data), rc), Closer: rc}, nil
+}
@@ -0,0 +1,167 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/errgroup"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/avast/retry-go"
+)
+
+// multiPartSize 分片上传每个分片的大小，Notion要求除最后一个分片外为5MB到20MB；
+// 不超过该大小的文件只有一个分片，仍使用签名URL上传
+const multiPartSize = 20 * 1024 * 1024
+
+// FileUploadResponse Notion文件上传接口返回的上传对象
+type FileUploadResponse struct {
+	ID            string `json:"id"`
+	Status        string `json:"status"`
+	ContentLength int64  `json:"content_length"`
+}
+
+// UploadMultiPart 通过Notion的文件上传接口分片并发上传附件，上传完成后设置为页面的文件属性
+// S3签名URL只能一次PUT整个文件，高延迟链路上单个连接的吞吐有限；分片依次读入内存后交给空闲的协程发送，
+// 同时最多缓存threads+1个分片，因此流式的上传也能并发
+func (s *NotionService) UploadMultiPart(ctx context.Context, file model.FileStreamer, pageID string, threads int, up driver.UpdateProgress) (string, error) {
+	size := file.GetSize()
+	parts := int((size + multiPartSize - 1) / multiPartSize)
+	upload, err := s.fileUploadRequest(ctx, "", map[string]interface{}{
+		"mode":            "multi_part",
+		"number_of_parts": parts,
+		"filename":        file.GetName(),
+		"content_type":    file.GetMimetype(),
+	})
+	if err != nil {
+		return "", fmt.Errorf("创建分片上传失败: %w", err)
+	}
+
+	reader, sum := sha1Reader(s.limitUpload(ctx, file), file)
+	threadG, uploadCtx := errgroup.NewGroupWithContext(ctx, threads,
+		retry.Attempts(putRetries+1),
+		retry.Delay(time.Second),
+		retry.DelayType(retry.BackOffDelay),
+		retry.OnRetry(func(n uint, err error) {
+			retries.WithLabelValues("part").Inc()
+		}))
+	var sent int64
+	var sentMu sync.Mutex
+	for part := 1; part <= parts; part++ {
+		if utils.IsCanceled(uploadCtx) {
+			break
+		}
+		data := make([]byte, min(multiPartSize, size-int64(part-1)*multiPartSize))
+		if _, err := io.ReadFull(reader, data); err != nil {
+			threadG.Wait()
+			return "", fmt.Errorf("读取分片%d失败: %v", part, err)
+		}
+		part := part
+		threadG.Go(func(ctx context.Context) error {
+			if err := s.sendFilePart(ctx, upload.ID, part, file.GetName(), data); err != nil {
+				return fmt.Errorf("上传分片%d失败: %v", part, err)
+			}
+			sentMu.Lock()
+			defer sentMu.Unlock()
+			sent += int64(len(data))
+			up(float64(sent) / float64(size) * 100)
+			return nil
+		})
+	}
+	if err := threadG.Wait(); err != nil {
+		return "", err
+	}
+	hash, err := sum()
+	if err != nil {
+		return "", err
+	}
+	completed, err := s.fileUploadRequest(ctx, "/"+upload.ID+"/complete", map[string]interface{}{})
+	if err != nil {
+		return "", fmt.Errorf("完成分片上传失败: %w", err)
+	}
+	if completed.Status != "uploaded" || completed.ContentLength != size {
+		return "", fmt.Errorf("上传校验失败: 状态为%s，大小为%d，文件大小为%d", completed.Status, completed.ContentLength, size)
+	}
+	if err := s.patchPage(pageID, map[string]interface{}{
+		"properties": map[string]interface{}{
+			s.filePageID: map[string]interface{}{
+				"files": []interface{}{map[string]interface{}{
+					"type":        "file_upload",
+					"file_upload": map[string]string{"id": upload.ID},
+					"name":        file.GetName(),
+				}},
+			},
+		},
+	}); err != nil {
+		return "", fmt.Errorf("更新页面文件失败: %w", err)
+	}
+	return hash, nil
+}
+
+// fileUploadRequest 调用文件上传接口，path为空时创建上传对象
+func (s *NotionService) fileUploadRequest(ctx context.Context, path string, reqBody interface{}) (*FileUploadResponse, error) {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求体失败: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.publicAPIBase+"/file_uploads"+path, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return s.doFileUpload(req)
+}
+
+// sendFilePart 发送一个分片，part从1开始
+func (s *NotionService) sendFilePart(ctx context.Context, uploadID string, part int, fileName string, data []byte) error {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	if err := writer.WriteField("part_number", strconv.Itoa(part)); err != nil {
+		return err
+	}
+	fw, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return fmt.Errorf("创建文件字段失败: %w", err)
+	}
+	if _, err := fw.Write(data); err != nil {
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.publicAPIBase+"/file_uploads/"+uploadID+"/send", &body)
+	if err != nil {
+		return fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	_, err = s.doFileUpload(req)
+	return err
+}
+
+func (s *NotionService) doFileUpload(req *http.Request) (*FileUploadResponse, error) {
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Notion-Version", "2022-06-28")
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("发送请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, s.lang.apiError(msgAPIRequest, resp.StatusCode, string(body))
+	}
+	var res FileUploadResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("解析响应失败: %w", err)
+	}
+	return &res, nil
+}
@@ -0,0 +1,189 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/google/uuid"
+)
+
+// fakeUpload 分片上传对象，完成后内容保存为S3对象
+type fakeUpload struct {
+	parts  int
+	data   map[int][]byte
+	status string
+}
+
+// fileUpload 模拟公开API的文件上传接口：创建、发送分片和完成
+func (f *fakeNotion) fileUpload(w http.ResponseWriter, r *http.Request, rest string) {
+	if rest == "" {
+		var req struct {
+			Mode  string `json:"mode"`
+			Parts int    `json:"number_of_parts"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Mode != "multi_part" || req.Parts < 1 {
+			http.Error(w, fmt.Sprintf("bad upload request: %v", err), http.StatusBadRequest)
+			return
+		}
+		id := uuid.NewString()
+		f.mu.Lock()
+		f.uploads[id] = &fakeUpload{parts: req.Parts, data: make(map[int][]byte), status: "pending"}
+		f.mu.Unlock()
+		writeJSON(w, FileUploadResponse{ID: id, Status: "pending"})
+		return
+	}
+	id, action, _ := strings.Cut(rest, "/")
+	f.mu.Lock()
+	upload, ok := f.uploads[id]
+	f.mu.Unlock()
+	if !ok {
+		http.Error(w, "no such upload", http.StatusNotFound)
+		return
+	}
+	switch action {
+	case "send":
+		if err := r.ParseMultipartForm(64 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		part, err := strconv.Atoi(r.FormValue("part_number"))
+		if err != nil || part < 1 || part > upload.parts {
+			http.Error(w, "bad part number", http.StatusBadRequest)
+			return
+		}
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer file.Close()
+		data, err := io.ReadAll(file)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		f.mu.Lock()
+		upload.data[part] = data
+		f.mu.Unlock()
+		writeJSON(w, FileUploadResponse{ID: id, Status: "pending"})
+	case "complete":
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		var all []byte
+		for part := 1; part <= upload.parts; part++ {
+			data, ok := upload.data[part]
+			if !ok {
+				http.Error(w, fmt.Sprintf("part %d missing", part), http.StatusBadRequest)
+				return
+			}
+			all = append(all, data...)
+		}
+		upload.status = "uploaded"
+		f.objects[id] = all
+		writeJSON(w, FileUploadResponse{ID: id, Status: upload.status, ContentLength: int64(len(all))})
+	default:
+		http.Error(w, "unexpected upload action "+action, http.StatusNotFound)
+	}
+}
+
+// attachUploads 将完成的上传对象设置为页面的附件，调用时持有f.mu
+func (f *fakeNotion) attachUploads(page *fakePage, raw json.RawMessage) error {
+	var prop struct {
+		Files []struct {
+			Name       string `json:"name"`
+			FileUpload struct {
+				ID string `json:"id"`
+			} `json:"file_upload"`
+		} `json:"files"`
+	}
+	if err := json.Unmarshal(raw, &prop); err != nil {
+		return err
+	}
+	var files []FileObject
+	for _, file := range prop.Files {
+		upload, ok := f.uploads[file.FileUpload.ID]
+		if !ok || upload.status != "uploaded" {
+			return fmt.Errorf("upload %s not completed", file.FileUpload.ID)
+		}
+		files = append(files, FileObject{Type: "file", Name: file.Name, File: NotionFile{URL: f.URL + "/s3/" + file.FileUpload.ID}})
+	}
+	page.files = files
+	return nil
+}
+
+func TestUploadMultiPart(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.UploadThreads = 2 })
+	// 3个分片，最后一个不满
+	data := testData(2*multiPartSize + 1000)
+	var progress float64
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", data), func(p float64) { progress = p })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count(http.MethodPost, "/v1/file_uploads/"); n != 4 {
+		t.Fatalf("expect 3 parts sent and the upload completed, got %d requests", n)
+	}
+	if n := fake.count(http.MethodPut, "/s3/"); n != 0 {
+		t.Fatalf("expect no signed PUT, got %d", n)
+	}
+	if progress != 100 {
+		t.Fatalf("expect the progress at 100, got %v", progress)
+	}
+	if h := obj.GetHash().GetHash(utils.SHA1); h != sha1Hex(data) {
+		t.Fatalf("expect the sha1 of the content, got %s", h)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+}
+
+func TestUploadMultiPartRetry(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.UploadThreads = 2 })
+	data := testData(multiPartSize + 1000)
+	// 失败的分片重新发送
+	fake.failNext(http.MethodPost, "/v1/file_uploads/", http.StatusBadGateway, 1)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count(http.MethodPost, "/v1/file_uploads/"); n != 4 {
+		t.Fatalf("expect a part sent again, got %d requests", n)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+}
+
+func TestUploadSinglePartUsesPut(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.UploadThreads = 2 })
+	// 不超过一个分片的文件仍使用签名URL
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count(http.MethodPost, "/v1/file_uploads"); n != 0 {
+		t.Fatalf("expect no multipart upload, got %d requests", n)
+	}
+	if n := fake.count(http.MethodPut, "/s3/"); n != 1 {
+		t.Fatalf("expect a signed PUT, got %d", n)
+	}
+}
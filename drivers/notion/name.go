@@ -0,0 +1,50 @@
+package notion
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"path"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxTitleBytes Notion页面标题和附件名的最大字节数，更长的名称会导致上传失败
+const maxTitleBytes = 255
+
+// sanitizeName 将名称转换为Notion可以接受的页面标题和附件名，真实名称只保存在数据库中，
+// 列表和获取文件时总是使用数据库中的名称。
+// 控制字符、Windows和S3不允许的字符以及%按百分号编码，url.PathUnescape可还原；
+// 超长的名称截断后附加原名称的哈希，保留扩展名，此时不可还原
+func sanitizeName(name string) string {
+	var sb strings.Builder
+	for _, r := range name {
+		switch {
+		case r == utf8.RuneError, r < 0x20, r == 0x7f, strings.ContainsRune(`%/\:*?"<>|`, r):
+			var buf [utf8.UTFMax]byte
+			for _, b := range buf[:utf8.EncodeRune(buf[:], r)] {
+				fmt.Fprintf(&sb, "%%%02X", b)
+			}
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	s := sb.String()
+	if len(s) <= maxTitleBytes {
+		return s
+	}
+	sum := sha1.Sum([]byte(name))
+	suffix := "~" + hex.EncodeToString(sum[:4]) + path.Ext(s)
+	if len(suffix) > maxTitleBytes/2 {
+		suffix = suffix[:9]
+	}
+	head := s[:maxTitleBytes-len(suffix)]
+	// 不在UTF-8字符或百分号编码的中间截断
+	for len(head) > 0 && !utf8.RuneStart(s[len(head)]) {
+		head = head[:len(head)-1]
+	}
+	if i := strings.LastIndexByte(head, '%'); i >= len(head)-2 && i >= 0 {
+		head = head[:i]
+	}
+	return head + suffix
+}
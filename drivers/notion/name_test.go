@@ -0,0 +1,95 @@
+package notion
+
+import (
+	"context"
+	"net/url"
+	"path"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestSanitizeName(t *testing.T) {
+	cases := []struct {
+		name, want string
+	}{
+		{"report.pdf", "report.pdf"},
+		{"报告 2024.pdf", "报告 2024.pdf"},
+		{`a:b*c?"<>|.txt`, "a%3Ab%2Ac%3F%22%3C%3E%7C.txt"},
+		{`dir\name`, "dir%5Cname"},
+		{"100%.txt", "100%25.txt"},
+		{"tab\tline\n\x7f", "tab%09line%0A%7F"},
+	}
+	for _, c := range cases {
+		got := sanitizeName(c.name)
+		if got != c.want {
+			t.Errorf("%q: expect %q, got %q", c.name, c.want, got)
+			continue
+		}
+		// 编码可以还原
+		if back, err := url.PathUnescape(got); err != nil || back != c.name {
+			t.Errorf("%q: expect restored from %q, got %q %v", c.name, got, back, err)
+		}
+	}
+}
+
+func TestSanitizeLongName(t *testing.T) {
+	long := strings.Repeat("a", 300)
+	a, b := sanitizeName(long+"1.mp4"), sanitizeName(long+"2.mp4")
+	if len(a) > maxTitleBytes || !strings.HasSuffix(a, ".mp4") {
+		t.Fatalf("expect at most %d bytes ending with the extension, got %d %q", maxTitleBytes, len(a), a)
+	}
+	// 截断后附加原名称的哈希，前缀相同的名称不冲突
+	if a == b {
+		t.Fatal("expect the shortened names to differ")
+	}
+	if sanitizeName(long+"1.mp4") != a {
+		t.Fatal("expect the same name shortened the same way")
+	}
+
+	// 不在UTF-8字符或百分号编码的中间截断
+	for _, name := range []string{
+		strings.Repeat("文", 100) + ".txt",
+		strings.Repeat("?", 100) + ".txt",
+		"a" + strings.Repeat("?", 100) + ".txt",
+		"ab" + strings.Repeat("?", 100) + ".txt",
+	} {
+		s := sanitizeName(name)
+		if len(s) > maxTitleBytes || !utf8.ValidString(s) {
+			t.Fatalf("expect a valid name of at most %d bytes, got %d %q", maxTitleBytes, len(s), s)
+		}
+		head := strings.TrimSuffix(s, path.Ext(s))
+		head = head[:strings.LastIndexByte(head, '~')]
+		if _, err := url.PathUnescape(head); err != nil {
+			t.Fatalf("expect no partial escape in %q: %v", head, err)
+		}
+	}
+
+	// 过长的扩展名不保留完整
+	if s := sanitizeName(strings.Repeat("a", 100) + "." + strings.Repeat("b", 300)); len(s) > maxTitleBytes {
+		t.Fatalf("expect at most %d bytes, got %d", maxTitleBytes, len(s))
+	}
+}
+
+func TestPageTitleSanitized(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a:b?.txt", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if titles := fake.pageTitles(f.BlobKey); len(titles) != 1 || titles[0] != "a%3Ab%3F.txt" {
+		t.Fatalf("expect the page titled a%%3Ab%%3F.txt, got %v", titles)
+	}
+	if names := fake.pageFileNames(f.BlobKey); len(names) != 1 || names[0] != "a%3Ab%3F.txt" {
+		t.Fatalf("expect the attachment named a%%3Ab%%3F.txt, got %v", names)
+	}
+	// 列表使用数据库中的真实名称
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 || names[0] != "a:b?.txt" {
+		t.Fatalf("expect a:b?.txt listed, got %v", names)
+	}
+}
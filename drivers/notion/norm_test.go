@@ -0,0 +1,73 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+// putTwice 依次上传两个名称不同的文件，返回列出的名称和第二个文件
+func putTwice(t *testing.T, d *Notion, first, second string, data []byte) ([]string, model.Obj) {
+	ctx := context.Background()
+	if _, err := d.Put(ctx, rootDir(d), newTestStream(first, testData(100)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.Put(ctx, rootDir(d), newTestStream(second, data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return listNames(t, d, rootDir(d)), obj
+}
+
+func TestNormalization(t *testing.T) {
+	nfc, nfd := norm.NFC.String("café.txt"), norm.NFD.String("café.txt")
+	data := testData(200)
+	t.Run("nfc", func(t *testing.T) {
+		d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.Normalization = "NFC" })
+		// 只在规范化形式上不同的名称替换已有文件，名称保存为NFC
+		names, obj := putTwice(t, d, nfc, nfd, data)
+		if len(names) != 1 || names[0] != nfc {
+			t.Fatalf("expect the file replaced and named in NFC, got %q", names)
+		}
+		link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := readURL(t, link); !bytes.Equal(got, data) {
+			t.Fatal("expect the content of the second upload")
+		}
+	})
+	t.Run("none", func(t *testing.T) {
+		d := newTestNotion(t, newFakeNotion(t), nil)
+		if names, _ := putTwice(t, d, nfc, nfd, data); len(names) != 2 {
+			t.Fatalf("expect two files without normalization, got %q", names)
+		}
+	})
+}
+
+func TestCaseInsensitive(t *testing.T) {
+	t.Run("on", func(t *testing.T) {
+		d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.CaseInsensitive = true })
+		names, _ := putTwice(t, d, "Readme.md", "README.md", testData(200))
+		if len(names) != 1 {
+			t.Fatalf("expect the file replaced, got %q", names)
+		}
+		dir, err := d.MakeDir(context.Background(), rootDir(d), "Docs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		again, err := d.MakeDir(context.Background(), rootDir(d), "docs")
+		if err != nil || again.GetID() != dir.GetID() {
+			t.Fatalf("expect Docs reused, got %v %v", again, err)
+		}
+	})
+	t.Run("off", func(t *testing.T) {
+		d := newTestNotion(t, newFakeNotion(t), nil)
+		if names, _ := putTwice(t, d, "Readme.md", "README.md", testData(200)); len(names) != 2 {
+			t.Fatalf("expect two files, got %q", names)
+		}
+	})
+}
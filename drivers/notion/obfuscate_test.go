@@ -0,0 +1,108 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// pageFileNames 返回页面附件的名称
+func (f *fakeNotion) pageFileNames(id string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var names []string
+	if page, ok := f.pages[id]; ok {
+		for _, file := range page.files {
+			names = append(names, file.Name)
+		}
+	}
+	return names
+}
+
+func TestObfuscatedPageTitle(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.ObfuscateNames = true })
+	data := testData(1000)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("secret.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	titles := fake.pageTitles(f.BlobKey)
+	if len(titles) != 1 || len(titles[0]) != 32 || strings.Contains(titles[0], "secret") {
+		t.Fatalf("expect a random page title, got %v", titles)
+	}
+	if names := fake.pageFileNames(f.BlobKey); len(names) != 1 || names[0] != titles[0] {
+		t.Fatalf("expect the attachment named %s, got %v", titles[0], names)
+	}
+
+	// 真实名称保存在数据库中
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 || names[0] != "secret.bin" {
+		t.Fatalf("expect secret.bin listed, got %v", names)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+}
+
+func TestObfuscatedChunkTitles(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ObfuscateNames = true
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("secret.bin", testData(3*1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ?", obj.GetID()).Find(&chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expect several chunks, got %d", len(chunks))
+	}
+	seen := make(map[string]bool)
+	for _, c := range chunks {
+		titles := fake.pageTitles(c.BlobKey)
+		if len(titles) != 1 || strings.Contains(titles[0], "secret") || seen[titles[0]] {
+			t.Fatalf("expect a distinct random title for chunk %d, got %v", c.ChunkIndex, titles)
+		}
+		seen[titles[0]] = true
+		for _, name := range fake.pageFileNames(c.BlobKey) {
+			if strings.Contains(name, "secret") {
+				t.Fatalf("expect a random attachment name for chunk %d, got %s", c.ChunkIndex, name)
+			}
+		}
+	}
+}
+
+func TestPageTitleWithoutObfuscation(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("plain.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if titles := fake.pageTitles(f.BlobKey); len(titles) != 1 || titles[0] != "plain.bin" {
+		t.Fatalf("expect the page titled plain.bin, got %v", titles)
+	}
+	if names := fake.pageFileNames(f.BlobKey); len(names) != 1 || names[0] != "plain.bin" {
+		t.Fatalf("expect the attachment named plain.bin, got %v", names)
+	}
+}
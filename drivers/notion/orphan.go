@@ -0,0 +1,206 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	log "github.com/sirupsen/logrus"
+)
+
+// orphanMinAge 创建不久的页面可能属于正在进行的上传，不作为孤立页面
+const orphanMinAge = time.Hour
+
+// OrphanAttachment 孤立页面中的一个附件，Name为附件名还原后的文件名
+type OrphanAttachment struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	Size  int64  `json:"size"`
+}
+
+// OrphanPage 数据库中有附件、但没有被任何文件、分块、校验分块、缩略图或打包记录引用的页面，
+// 例如上传中断或元数据丢失后留下的页面
+type OrphanPage struct {
+	PageID      string             `json:"page_id"`
+	CreatedAt   time.Time          `json:"created_at"`
+	Attachments []OrphanAttachment `json:"attachments"`
+}
+
+// AdoptReq 收养孤立页面的参数
+type AdoptReq struct {
+	PageIDs []string `json:"page_ids"`
+}
+
+// AdoptResult 收养的结果，Skipped为未收养的页面及原因
+type AdoptResult struct {
+	Files   []model.Obj       `json:"files"`
+	Skipped map[string]string `json:"skipped"`
+}
+
+// referencedPages 元数据中引用的全部页面，包括回收站中的文件和其他共用数据库的存储
+func (d *Notion) referencedPages() (map[string]struct{}, error) {
+	refs := make(map[string]struct{})
+	for _, q := range []struct {
+		model  interface{}
+		column string
+	}{
+		{&File{}, "notion_page_id"},
+		{&File{}, "thumb_page_id"},
+		{&FileChunk{}, "notion_page_id"},
+		{&ParityChunk{}, "notion_page_id"},
+		{&PackPage{}, "page_id"},
+	} {
+		var ids []string
+		if err := d.db.Model(q.model).Where(q.column+" <> ''").Distinct().Pluck(q.column, &ids).Error; err != nil {
+			return nil, fmt.Errorf("查询引用的页面失败: %w", err)
+		}
+		for _, id := range ids {
+			refs[id] = struct{}{}
+		}
+	}
+	var sessions []UploadSession
+	if err := d.db.Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("查询上传会话失败: %w", err)
+	}
+	for i := range sessions {
+		parts, err := sessions[i].parts()
+		if err != nil {
+			return nil, err
+		}
+		for _, c := range parts {
+			refs[c.Key] = struct{}{}
+		}
+	}
+	return refs, nil
+}
+
+// orphanAttachments 读取页面的附件及其大小，附件名是sanitizeName转换后的名称，能还原时还原
+func (d *Notion) orphanAttachments(ctx context.Context, pageID string) ([]OrphanAttachment, error) {
+	property, err := d.notionClient.GetPageProperty(pageID, d.NotionFilePageID)
+	if err != nil {
+		return nil, err
+	}
+	attachments := make([]OrphanAttachment, 0, len(property.Files))
+	for i, file := range property.Files {
+		name := file.Name
+		if unescaped, err := url.PathUnescape(name); err == nil {
+			name = unescaped
+		}
+		size, err := d.notionClient.AttachmentSize(ctx, file.File.URL)
+		if err != nil {
+			return nil, fmt.Errorf("获取页面%s第%d个附件的大小失败: %v", pageID, i+1, err)
+		}
+		attachments = append(attachments, OrphanAttachment{Index: i, Name: name, Size: size})
+	}
+	return attachments, nil
+}
+
+// listOrphans 查询数据库中的全部页面，列出有附件但未被引用的页面
+func (d *Notion) listOrphans(ctx context.Context) ([]OrphanPage, error) {
+	refs, err := d.referencedPages()
+	if err != nil {
+		return nil, err
+	}
+	orphans := make([]OrphanPage, 0)
+	cursor := ""
+	for {
+		res, err := d.notionClient.QueryDatabase(ctx, nil, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range res.Results {
+			if _, ok := refs[page.ID]; ok || time.Since(page.CreatedTime) < orphanMinAge {
+				continue
+			}
+			attachments, err := d.orphanAttachments(ctx, page.ID)
+			if err != nil {
+				log.Warnf("读取页面[%s]的附件失败: %v", page.ID, err)
+				continue
+			}
+			if len(attachments) == 0 {
+				continue
+			}
+			orphans = append(orphans, OrphanPage{PageID: page.ID, CreatedAt: page.CreatedTime, Attachments: attachments})
+		}
+		if !res.HasMore || res.NextCursor == "" {
+			return orphans, nil
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// adoptOrphans 为孤立页面的每个附件在目录dir中创建文件，多个附件的页面按打包页面处理；
+// 大小从附件读取，没有哈希，已被引用、没有附件或有同名文件的页面跳过
+func (d *Notion) adoptOrphans(ctx context.Context, dir model.Obj, req AdoptReq) (*AdoptResult, error) {
+	if dir == nil || !dir.IsDir() {
+		return nil, fmt.Errorf("只能收养到目录中")
+	}
+	if len(req.PageIDs) == 0 {
+		return nil, fmt.Errorf("没有指定页面")
+	}
+	dirID, _ := strconv.Atoi(dir.GetID())
+	refs, err := d.referencedPages()
+	if err != nil {
+		return nil, err
+	}
+	result := &AdoptResult{Files: make([]model.Obj, 0), Skipped: make(map[string]string)}
+	for _, pageID := range req.PageIDs {
+		if _, ok := refs[pageID]; ok {
+			result.Skipped[pageID] = "页面已被引用"
+			continue
+		}
+		attachments, err := d.orphanAttachments(ctx, pageID)
+		if err != nil {
+			result.Skipped[pageID] = err.Error()
+			continue
+		}
+		if len(attachments) == 0 {
+			result.Skipped[pageID] = "页面没有附件"
+			continue
+		}
+		files, err := d.adoptPage(dirID, pageID, attachments)
+		if err != nil {
+			result.Skipped[pageID] = err.Error()
+			continue
+		}
+		for i := range files {
+			result.Files = append(result.Files, dbfs.FileToObj(&files[i]))
+		}
+		refs[pageID] = struct{}{}
+	}
+	return result, nil
+}
+
+func (d *Notion) adoptPage(dirID int, pageID string, attachments []OrphanAttachment) ([]File, error) {
+	now := time.Now()
+	files := make([]File, 0, len(attachments))
+	for _, a := range attachments {
+		name := d.tree.NormName(a.Name)
+		existing, err := d.tree.FindFile(dirID, name)
+		if err != nil {
+			return nil, err
+		}
+		if existing != nil {
+			return nil, fmt.Errorf("目录中已存在文件[%s]: %w", name, errs.ObjectAlreadyExists)
+		}
+		files = append(files, File{
+			Name:        name,
+			Size:        a.Size,
+			BlobKey:     pageID,
+			DirectoryID: dirID,
+			Packed:      len(attachments) > 1,
+			BlobIndex:   a.Index,
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+	}
+	if err := d.db.Create(&files).Error; err != nil {
+		return nil, fmt.Errorf("保存页面%s的文件失败: %v", pageID, err)
+	}
+	return files, nil
+}
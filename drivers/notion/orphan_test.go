@@ -0,0 +1,121 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// agePages 将全部页面的创建时间提前，使其不被当作正在上传的页面
+func (f *fakeNotion) agePages(d time.Duration) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, p := range f.pages {
+		p.created = p.created.Add(-d)
+	}
+}
+
+func listOrphans(t *testing.T, d *Notion) []OrphanPage {
+	t.Helper()
+	return callOther(t, d, "list_orphans", nil).([]OrphanPage)
+}
+
+func adoptOrphans(t *testing.T, d *Notion, dir model.Obj, pageIDs ...string) *AdoptResult {
+	t.Helper()
+	res, err := d.Other(context.Background(), model.OtherArgs{Obj: dir, Method: "adopt_orphans", Data: AdoptReq{PageIDs: pageIDs}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.(*AdoptResult)
+}
+
+func TestOrphans(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	ctx := context.Background()
+	data := testData(1000)
+	lost, err := d.Put(ctx, rootDir(d), newTestStream("a b.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("kept.bin", testData(10)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, lost.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 模拟元数据丢失
+	if err := d.db.Delete(&f).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	// 创建不久的页面可能正在上传，不列出
+	if orphans := listOrphans(t, d); len(orphans) != 0 {
+		t.Fatalf("expect new pages left out, got %+v", orphans)
+	}
+	fake.agePages(2 * time.Hour)
+	orphans := listOrphans(t, d)
+	if len(orphans) != 1 || orphans[0].PageID != f.BlobKey {
+		t.Fatalf("expect the page of a b.bin listed, got %+v", orphans)
+	}
+	if a := orphans[0].Attachments; len(a) != 1 || a[0].Name != "a b.bin" || a[0].Size != 1000 {
+		t.Fatalf("expect the attachment a b.bin of 1000 bytes, got %+v", a)
+	}
+
+	dir, err := d.MakeDir(ctx, rootDir(d), "recovered")
+	if err != nil {
+		t.Fatal(err)
+	}
+	res := adoptOrphans(t, d, dir, f.BlobKey)
+	if len(res.Files) != 1 || len(res.Skipped) != 0 || res.Files[0].GetName() != "a b.bin" {
+		t.Fatalf("expect a b.bin adopted, got %+v", res)
+	}
+	link, err := d.Link(ctx, res.Files[0], model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+	if orphans := listOrphans(t, d); len(orphans) != 0 {
+		t.Fatalf("expect no orphan after adopting, got %+v", orphans)
+	}
+	// 已被引用的页面不再收养
+	if res := adoptOrphans(t, d, dir, f.BlobKey); len(res.Files) != 0 || res.Skipped[f.BlobKey] == "" {
+		t.Fatalf("expect a referenced page skipped, got %+v", res)
+	}
+}
+
+func TestAdoptOrphanNameTaken(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	ctx := context.Background()
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := d.db.Delete(&f).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(10)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	res := adoptOrphans(t, d, rootDir(d), f.BlobKey, "no-such-page")
+	if len(res.Files) != 0 || res.Skipped[f.BlobKey] == "" || res.Skipped["no-such-page"] == "" {
+		t.Fatalf("expect both pages skipped, got %+v", res)
+	}
+	if _, err := d.Other(ctx, model.OtherArgs{Obj: obj, Method: "adopt_orphans", Data: AdoptReq{PageIDs: []string{f.BlobKey}}}); err == nil {
+		t.Fatal("expect adopting into a file refused")
+	}
+	if _, err := d.Other(ctx, model.OtherArgs{Obj: rootDir(d), Method: "adopt_orphans", Data: AdoptReq{}}); err == nil {
+		t.Fatal("expect a request without pages refused")
+	}
+}
@@ -0,0 +1,406 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// otherHandler 处理一个Other方法
+type otherHandler func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error)
+
+// withReq 解析请求参数后调用handler
+func withReq[T any](handler func(d *Notion, ctx context.Context, args model.OtherArgs, req T) (interface{}, error)) otherHandler {
+	return func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		var req T
+		if err := parseOtherData(d.lang(), args.Data, &req); err != nil {
+			return nil, err
+		}
+		return handler(d, ctx, args, req)
+	}
+}
+
+// otherHandlers Other方法的路由表，维护命令作用于整个存储时忽略args.Obj
+var otherHandlers = map[string]otherHandler{
+	"list_audit_logs": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req AuditReq) (interface{}, error) {
+		return d.listAuditLogs(req)
+	}),
+	"list_versions": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.listVersions(args.Obj.GetID())
+	},
+	"restore_version": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req VersionReq) (interface{}, error) {
+		f, err := d.restoreVersion(args.Obj.GetID(), req.VersionID)
+		if err != nil {
+			return nil, err
+		}
+		return dbfs.FileToObj(f), nil
+	}),
+	"delete_version": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req VersionReq) (interface{}, error) {
+		return nil, d.deleteVersion(args.Obj.GetID(), req.VersionID)
+	}),
+	"create_snapshot": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req SnapshotReq) (interface{}, error) {
+		return d.createSnapshot(req.Name, false)
+	}),
+	"list_snapshots": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.listSnapshots()
+	},
+	"restore_snapshot": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req SnapshotReq) (interface{}, error) {
+		return nil, d.restoreSnapshot(req.SnapshotID)
+	}),
+	"delete_snapshot": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req SnapshotReq) (interface{}, error) {
+		return nil, d.deleteSnapshot(req.SnapshotID)
+	}),
+	"verify": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.verifyFile(args.Obj.GetID())
+	},
+	"stats": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.stats()
+	},
+	"purge_trash": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.purgeTrash()
+	},
+	"export_meta": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		var data *snapshotData
+		err := d.db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			data, err = d.dumpStorage(tx)
+			return err
+		})
+		return data, err
+	},
+	"regen_link": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.regenLinks(args.Obj.GetID())
+	},
+	"list_chunks": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.listChunks(args.Obj.GetID())
+	},
+	"get_tags": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.getTags(args.Obj)
+	},
+	"set_tags": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req TagReq) (interface{}, error) {
+		return d.setTags(args.Obj, req)
+	}),
+	"list_by_tag": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req TagReq) (interface{}, error) {
+		return d.listByTag(req)
+	}),
+	"batch_update": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req BatchReq) (interface{}, error) {
+		return d.batchUpdate(ctx, req)
+	}),
+	"rebuild_meta": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.startRebuild()
+	},
+	"rebuild_status": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.rebuildStatus(), nil
+	},
+	"create_share": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req ShareReq) (interface{}, error) {
+		return d.createShare(ctx, args.Obj, req)
+	}),
+	"scrub_status": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.scrubStatus(), nil
+	},
+	"list_orphans": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.listOrphans(ctx)
+	},
+	"repair_chunks": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req RepairReq) (interface{}, error) {
+		return d.repairChunks(ctx, args.Obj.GetID(), req)
+	}),
+	"validate": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.validate(ctx), nil
+	},
+	"rehash": func(d *Notion, ctx context.Context, args model.OtherArgs) (interface{}, error) {
+		return d.rehash(ctx, args.Obj)
+	},
+	"make_alias": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req AliasReq) (interface{}, error) {
+		return d.makeAlias(ctx, args.Obj, req)
+	}),
+	"adopt_orphans": withReq(func(d *Notion, ctx context.Context, args model.OtherArgs, req AdoptReq) (interface{}, error) {
+		return d.adoptOrphans(ctx, args.Obj, req)
+	}),
+}
+
+func (d *Notion) Other(ctx context.Context, args model.OtherArgs) (interface{}, error) {
+	handler, ok := otherHandlers[args.Method]
+	if !ok {
+		return nil, errs.NotSupport
+	}
+	if err := d.checkUserMethod(ctx, args.Method); err != nil {
+		return nil, err
+	}
+	if create, ok := otherWrites[args.Method]; ok {
+		if err := d.checkWrite(create); err != nil {
+			return nil, err
+		}
+	}
+	return handler(d, ctx, args)
+}
+
+// ChunkInfo 分块信息
+type ChunkInfo struct {
+	Index     int    `json:"index"`
+	Start     int64  `json:"start"`
+	End       int64  `json:"end"`
+	Size      int64  `json:"size"`
+	SHA1      string `json:"sha1"`
+	PageID    string `json:"page_id"`
+	Encrypted bool   `json:"encrypted"`
+	// VerifiedAt 最近一次verify确认页面可用的时间，从未检查过时为空
+	VerifiedAt *time.Time `json:"verified_at"`
+	// Refs 引用该页面的分块数，大于1时页面与其他文件或版本共享
+	Refs int64 `json:"refs"`
+}
+
+// PageLink 页面附件的下载地址，Index为分块序号，未分块文件为0；Attachment为打包页面中附件的位置
+type PageLink struct {
+	Index      int    `json:"index"`
+	PageID     string `json:"page_id"`
+	Attachment int    `json:"attachment"`
+	URL        string `json:"url"`
+}
+
+// VerifyResult 文件的检查结果，Problems为空表示文件完整
+type VerifyResult struct {
+	Pages    int      `json:"pages"`
+	Problems []string `json:"problems"`
+}
+
+// Stats 存储的统计信息，包含已删除但尚未清理的记录
+type Stats struct {
+	Directories        int64 `json:"directories"`
+	DeletedDirectories int64 `json:"deleted_directories"`
+	Files              int64 `json:"files"`
+	DeletedFiles       int64 `json:"deleted_files"`
+	ChunkedFiles       int64 `json:"chunked_files"`
+	Chunks             int64 `json:"chunks"`
+	DeletedChunks      int64 `json:"deleted_chunks"`
+	Versions           int64 `json:"versions"`
+	Snapshots          int64 `json:"snapshots"`
+}
+
+// PurgeResult purge_trash清理的记录数
+type PurgeResult struct {
+	Files       int64 `json:"files"`
+	Chunks      int64 `json:"chunks"`
+	Directories int64 `json:"directories"`
+}
+
+// fileChunks 获取文件的分块记录，按序号排序
+func (d *Notion) fileChunks(fileID int) ([]FileChunk, error) {
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ? AND deleted = ?", fileID, false).Order("chunk_index").Find(&chunks).Error; err != nil {
+		return nil, fmt.Errorf("获取文件分块信息失败: %w", err)
+	}
+	return chunks, nil
+}
+
+// listChunks 列出分块文件的分块布局，用于排查播放卡顿和检查完整性
+func (d *Notion) listChunks(fileID string) ([]ChunkInfo, error) {
+	f, err := d.tree.GetFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsChunked {
+		return nil, fmt.Errorf("文件[%s]不是分块文件", f.Name)
+	}
+	chunks, err := d.fileChunks(f.ID)
+	if err != nil {
+		return nil, err
+	}
+	pageIDs := make([]string, 0, len(chunks))
+	for _, chunk := range chunks {
+		pageIDs = append(pageIDs, chunk.BlobKey)
+	}
+	refs, err := d.chunkRefs(pageIDs)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]ChunkInfo, 0, len(chunks))
+	for _, chunk := range chunks {
+		res = append(res, ChunkInfo{
+			Index:      chunk.ChunkIndex,
+			Start:      chunk.StartOffset,
+			End:        chunk.EndOffset,
+			Size:       chunk.ChunkSize,
+			SHA1:       chunk.SHA1,
+			PageID:     chunk.BlobKey,
+			Encrypted:  chunk.Nonce != "",
+			VerifiedAt: chunk.VerifiedAt,
+			Refs:       refs[chunk.BlobKey],
+		})
+	}
+	return res, nil
+}
+
+// filePages 文件内容所在的页面，未分块文件只有一个页面
+func (d *Notion) filePages(f *File) ([]PageLink, error) {
+	if f.IsInline() {
+		return []PageLink{}, nil
+	}
+	if !f.IsChunked {
+		return []PageLink{{PageID: f.BlobKey, Attachment: f.BlobIndex}}, nil
+	}
+	chunks, err := d.fileChunks(f.ID)
+	if err != nil {
+		return nil, err
+	}
+	pages := make([]PageLink, 0, len(chunks))
+	for _, chunk := range chunks {
+		pages = append(pages, PageLink{Index: chunk.ChunkIndex, PageID: chunk.BlobKey})
+	}
+	return pages, nil
+}
+
+// regenLinks 重新获取文件各页面附件的下载地址
+func (d *Notion) regenLinks(fileID string) ([]PageLink, error) {
+	f, err := d.tree.GetFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := d.filePages(f)
+	if err != nil {
+		return nil, err
+	}
+	for i := range pages {
+		pages[i].URL, err = d.notionClient.PageFileURL(pages[i].PageID, pages[i].Attachment)
+		if err != nil {
+			return nil, fmt.Errorf("获取页面%s的文件URL失败: %v", pages[i].PageID, err)
+		}
+	}
+	return pages, nil
+}
+
+// verifyFile 检查文件的分块是否连续覆盖整个文件，以及各页面的附件是否存在
+func (d *Notion) verifyFile(fileID string) (*VerifyResult, error) {
+	f, err := d.tree.GetFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	res := &VerifyResult{Problems: []string{}}
+	if f.IsChunked {
+		chunks, err := d.fileChunks(f.ID)
+		if err != nil {
+			return nil, err
+		}
+		var offset int64
+		for _, chunk := range chunks {
+			if chunk.StartOffset != offset {
+				res.Problems = append(res.Problems, fmt.Sprintf("分块%d的起始位置为%d，应为%d", chunk.ChunkIndex, chunk.StartOffset, offset))
+			}
+			offset = chunk.EndOffset
+		}
+		if offset != f.Size {
+			res.Problems = append(res.Problems, fmt.Sprintf("分块结束于%d，文件大小为%d", offset, f.Size))
+		}
+	}
+	if f.IsInline() && int64(len(f.Inline)) != f.Size {
+		res.Problems = append(res.Problems, fmt.Sprintf("数据库中保存了%d字节，文件大小为%d", len(f.Inline), f.Size))
+	}
+	pages, err := d.filePages(f)
+	if err != nil {
+		return nil, err
+	}
+	res.Pages = len(pages)
+	var verified []string
+	for _, page := range pages {
+		if _, err := d.notionClient.PageFileURL(page.PageID, page.Attachment); err != nil {
+			res.Problems = append(res.Problems, fmt.Sprintf("获取页面%s的文件失败: %v", page.PageID, err))
+		} else {
+			verified = append(verified, page.PageID)
+		}
+	}
+	// 记录分块的检查时间，list_chunks中可以看到哪些分块长期未检查
+	if f.IsChunked && len(verified) > 0 {
+		if err := d.db.Model(&FileChunk{}).Where("file_id = ? AND deleted = ? AND notion_page_id IN ?", f.ID, false, verified).
+			Update("verified_at", time.Now()).Error; err != nil {
+			log.Warnf("保存文件[%s]分块的检查时间失败: %v", f.Name, err)
+		}
+	}
+	return res, nil
+}
+
+// stats 统计当前存储的记录数
+func (d *Notion) stats() (*Stats, error) {
+	var s Stats
+	counts := []struct {
+		q *gorm.DB
+		v *int64
+	}{
+		{d.db.Model(&Directory{}).Where("database_id = ? AND deleted = ?", d.NotionDatabaseID, false), &s.Directories},
+		{d.db.Model(&Directory{}).Where("database_id = ? AND deleted = ?", d.NotionDatabaseID, true), &s.DeletedDirectories},
+		{d.db.Model(&File{}).Where("directory_id IN (?) AND deleted = ?", d.tree.DirIDs(d.db), false), &s.Files},
+		{d.db.Model(&File{}).Where("directory_id IN (?) AND deleted = ?", d.tree.DirIDs(d.db), true), &s.DeletedFiles},
+		{d.db.Model(&File{}).Where("directory_id IN (?) AND deleted = ? AND is_chunked = ?", d.tree.DirIDs(d.db), false, true), &s.ChunkedFiles},
+		{d.db.Model(&FileChunk{}).Where("file_id IN (?) AND deleted = ?", d.tree.FileIDs(d.db), false), &s.Chunks},
+		{d.db.Model(&FileChunk{}).Where("file_id IN (?) AND deleted = ?", d.tree.FileIDs(d.db), true), &s.DeletedChunks},
+		{d.db.Model(&FileVersion{}).Where("file_id IN (?)", d.tree.FileIDs(d.db)), &s.Versions},
+		{d.db.Model(&Snapshot{}).Where("database_id = ?", d.NotionDatabaseID), &s.Snapshots},
+	}
+	for _, c := range counts {
+		if err := c.q.Count(c.v).Error; err != nil {
+			return nil, fmt.Errorf("统计记录数失败: %w", err)
+		}
+	}
+	return &s, nil
+}
+
+// purgeTrash 从数据库中彻底删除已标记删除的文件、分块和目录，历史版本引用的文件会保留，
+// 开启ArchiveOnDelete时同时归档不再被引用的页面
+func (d *Notion) purgeTrash() (*PurgeResult, error) {
+	versionFiles := d.db.Model(&FileVersion{}).Select("version_file_id")
+	var files []File
+	if err := d.db.Where("directory_id IN (?) AND deleted = ? AND id NOT IN (?)", d.tree.DirIDs(d.db), true, versionFiles).
+		Find(&files).Error; err != nil {
+		return nil, fmt.Errorf("获取已删除的文件失败: %w", err)
+	}
+	// 先按删除文件的流程清理分块和历史版本，并收集可能需要归档的页面
+	var pageIDs []string
+	for i := range files {
+		ids, err := d.purgeFile(&files[i])
+		if err != nil {
+			return nil, err
+		}
+		pageIDs = append(pageIDs, ids...)
+	}
+	var res PurgeResult
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var chunkPageIDs []string
+		chunks := tx.Model(&FileChunk{}).Where("file_id IN (?) AND deleted = ?", d.tree.FileIDs(tx), true)
+		if err := chunks.Pluck("notion_page_id", &chunkPageIDs).Error; err != nil {
+			return fmt.Errorf("获取已删除的分块失败: %w", err)
+		}
+		pageIDs = append(pageIDs, chunkPageIDs...)
+		r := tx.Where("file_id IN (?) AND deleted = ?", d.tree.FileIDs(tx), true).Delete(&FileChunk{})
+		if r.Error != nil {
+			return fmt.Errorf("删除分块记录失败: %v", r.Error)
+		}
+		res.Chunks = r.RowsAffected
+		// 历史版本被删除后，其文件记录也不再被引用
+		purged := tx.Model(&File{}).Select("id").Where("directory_id IN (?) AND deleted = ? AND id NOT IN (?)", d.tree.DirIDs(tx), true,
+			tx.Model(&FileVersion{}).Select("version_file_id"))
+		if err := tx.Where("file_id IN (?)", purged).Delete(&FileTag{}).Error; err != nil {
+			return fmt.Errorf("删除标签失败: %w", err)
+		}
+		r = tx.Where("directory_id IN (?) AND deleted = ? AND id NOT IN (?)", d.tree.DirIDs(tx), true,
+			tx.Model(&FileVersion{}).Select("version_file_id")).Delete(&File{})
+		if r.Error != nil {
+			return fmt.Errorf("删除文件记录失败: %v", r.Error)
+		}
+		res.Files = r.RowsAffected
+		r = tx.Where("database_id = ? AND deleted = ?", d.NotionDatabaseID, true).Delete(&Directory{})
+		if r.Error != nil {
+			return fmt.Errorf("删除目录记录失败: %v", r.Error)
+		}
+		res.Directories = r.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.archivePages(pageIDs)
+	return &res, nil
+}
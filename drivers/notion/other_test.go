@@ -0,0 +1,165 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// callOther 调用Other方法，obj为nil时作用于根目录
+func callOther(t *testing.T, d *Notion, method string, obj model.Obj) interface{} {
+	t.Helper()
+	if obj == nil {
+		obj = rootDir(d)
+	}
+	res, err := d.Other(context.Background(), model.OtherArgs{Obj: obj, Method: method})
+	if err != nil {
+		t.Fatalf("%s: %v", method, err)
+	}
+	return res
+}
+
+// clearPageFiles 清空页面的附件，模拟在Notion中被误删
+func (f *fakeNotion) clearPageFiles(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if page, ok := f.pages[id]; ok {
+		page.files = nil
+	}
+}
+
+func TestOtherUnknownMethod(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	if _, err := d.Other(context.Background(), model.OtherArgs{Obj: rootDir(d), Method: "no_such_method"}); !errors.Is(err, errs.NotSupport) {
+		t.Fatalf("expect not supported, got %v", err)
+	}
+}
+
+func TestOtherChunkCommands(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	size := int64(3 * 1024 * 1024)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", testData(int(size))), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// list_chunks按顺序连续覆盖整个文件
+	chunks := callOther(t, d, "list_chunks", obj).([]ChunkInfo)
+	if len(chunks) < 2 {
+		t.Fatalf("expect several chunks, got %d", len(chunks))
+	}
+	var offset int64
+	for i, c := range chunks {
+		if c.Index != i || c.Start != offset || c.Size != c.End-c.Start || c.PageID == "" {
+			t.Fatalf("unexpected chunk %+v at offset %d", c, offset)
+		}
+		offset = c.End
+	}
+	if offset != size {
+		t.Fatalf("expect the chunks to end at %d, got %d", size, offset)
+	}
+
+	// regen_link返回每个分块页面的下载地址
+	links := callOther(t, d, "regen_link", obj).([]PageLink)
+	if len(links) != len(chunks) {
+		t.Fatalf("expect %d links, got %+v", len(chunks), links)
+	}
+	for i, l := range links {
+		if l.PageID != chunks[i].PageID || l.URL == "" {
+			t.Fatalf("unexpected link %+v for chunk %+v", l, chunks[i])
+		}
+	}
+
+	// verify发现丢失附件的页面
+	if res := callOther(t, d, "verify", obj).(*VerifyResult); res.Pages != len(chunks) || len(res.Problems) != 0 {
+		t.Fatalf("expect an intact file, got %+v", res)
+	}
+	fake.clearPageFiles(chunks[1].PageID)
+	if res := callOther(t, d, "verify", obj).(*VerifyResult); len(res.Problems) != 1 {
+		t.Fatalf("expect the emptied page reported, got %+v", res)
+	}
+
+	small, err := d.Put(context.Background(), rootDir(d), newTestStream("small.bin", testData(100)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Other(context.Background(), model.OtherArgs{Obj: small, Method: "list_chunks"}); err == nil {
+		t.Fatal("expect list_chunks to fail for a file that isn't chunked")
+	}
+}
+
+func TestOtherStatsAndPurge(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var objs []model.Obj
+	for _, name := range []string{"a.bin", "b.bin"} {
+		obj, err := d.Put(ctx, dir, newTestStream(name, testData(100)), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, obj)
+	}
+	if err := d.Remove(ctx, objs[0]); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := callOther(t, d, "stats", nil).(*Stats)
+	if stats.Files != 1 || stats.DeletedFiles != 1 {
+		t.Fatalf("expect 1 file and 1 deleted file, got %+v", stats)
+	}
+	purged := callOther(t, d, "purge_trash", nil).(*PurgeResult)
+	if purged.Files != 1 {
+		t.Fatalf("expect 1 file purged, got %+v", purged)
+	}
+	stats = callOther(t, d, "stats", nil).(*Stats)
+	if stats.Files != 1 || stats.DeletedFiles != 0 {
+		t.Fatalf("expect no deleted files left, got %+v", stats)
+	}
+	if names := listNames(t, d, dir); len(names) != 1 || names[0] != "b.bin" {
+		t.Fatalf("expect b.bin kept, got %v", names)
+	}
+}
+
+// TestVerifyRecordsChunkTime verify记录检查通过的分块的时间，list_chunks中可以看到
+func TestVerifyRecordsChunkTime(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", testData(3*1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := callOther(t, d, "list_chunks", obj).([]ChunkInfo)
+	for _, c := range chunks {
+		if c.VerifiedAt != nil {
+			t.Fatalf("expect chunk %d never verified, got %v", c.Index, c.VerifiedAt)
+		}
+	}
+
+	fake.clearPageFiles(chunks[0].PageID)
+	before := time.Now()
+	callOther(t, d, "verify", obj)
+	for _, c := range callOther(t, d, "list_chunks", obj).([]ChunkInfo) {
+		if c.Index == 0 {
+			if c.VerifiedAt != nil {
+				t.Fatalf("expect the broken chunk not marked verified, got %v", c.VerifiedAt)
+			}
+		} else if c.VerifiedAt == nil || c.VerifiedAt.Before(before.Add(-time.Second)) {
+			t.Fatalf("expect chunk %d verified after %v, got %v", c.Index, before, c.VerifiedAt)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/alist-org/alist/v3/pkg/utils/random"
+	"gorm.io/gorm"
+)
+
+// maxPackSize 打包的文件大小上限，每次添加附件都要重写页面的整个文件列表
+const maxPackSize = 5 * 1024 * 1024
+
+// packState 打包页面的分配，同一时间只有一个文件修改页面的文件列表
+type packState struct {
+	mu sync.Mutex
+	// reserved 各页面正在上传、尚未加入文件列表的文件数
+	reserved map[string]int
+}
+
+// packLimit 打包保存的文件大小上限，未开启时为0；开启加密时文件都按分块上传，不打包
+func (d *Notion) packLimit() int64 {
+	if d.PackCount <= 1 || d.chunkKey != nil {
+		return 0
+	}
+	return min(int64(d.PackSize)*1024, maxPackSize)
+}
+
+// putPackedFile 将小文件作为附件添加到未满的打包页面，页面满后再创建新页面
+func (d *Notion) putPackedFile(ctx context.Context, fileName string, fileSize int64, dirID int, file model.FileStreamer, up driver.UpdateProgress) (model.Obj, error) {
+	pageID, err := d.reservePackSlot()
+	if err != nil {
+		return nil, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			d.releasePackSlot(pageID)
+		}
+	}()
+	head, err := sniffHead(file)
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	title := d.pageTitle(fileName)
+	file = &uploadFileStream{FileStreamer: file, name: title, mimetype: d.contentType(fileName, head)}
+	var hasher *utils.MultiHasher
+	if d.ExtraHashes {
+		hasher = utils.NewMultiHasher(extraHashTypes)
+		file = &hashingStream{FileStreamer: file, hasher: hasher}
+	}
+	fileURL, hash1, err := d.notionClient.UploadAttachment(ctx, file, pageID, up)
+	if err != nil {
+		return nil, fmt.Errorf("上传文件到Notion失败: %w", err)
+	}
+
+	f := &File{
+		Name:        fileName,
+		Size:        fileSize,
+		SHA1:        hash1,
+		BlobKey:     pageID,
+		DirectoryID: dirID,
+		Packed:      true,
+	}
+	if hasher != nil {
+		f.SetHashes(hasher.GetHashInfo())
+	}
+	committed = true
+	if err := d.commitPackSlot(pageID, PageAttachment{Name: title, URL: fileURL}, f); err != nil {
+		return nil, err
+	}
+	return dbfs.FileToObj(f), nil
+}
+
+// reservePackSlot 选出还有空位的打包页面并占用一个位置
+func (d *Notion) reservePackSlot() (string, error) {
+	d.pack.mu.Lock()
+	defer d.pack.mu.Unlock()
+	if d.pack.reserved == nil {
+		d.pack.reserved = make(map[string]int)
+	}
+	var pages []PackPage
+	if err := d.db.Select("page_id", "count").Where("database_id = ? AND count < ?", d.NotionDatabaseID, d.PackCount).
+		Order("id").Find(&pages).Error; err != nil {
+		return "", fmt.Errorf("获取打包页面失败: %w", err)
+	}
+	for _, page := range pages {
+		if page.Count+d.pack.reserved[page.PageID] < d.PackCount {
+			d.pack.reserved[page.PageID]++
+			return page.PageID, nil
+		}
+	}
+	pageID, err := d.notionClient.CreateDatabasePage(d.pageTitle("pack-" + random.String(8)))
+	if err != nil {
+		return "", fmt.Errorf("创建打包页面失败: %w", err)
+	}
+	if err := d.db.Create(&PackPage{DatabaseID: d.NotionDatabaseID, PageID: pageID, Attachments: "[]"}).Error; err != nil {
+		return "", fmt.Errorf("保存打包页面失败: %w", err)
+	}
+	d.pack.reserved[pageID]++
+	return pageID, nil
+}
+
+func (d *Notion) releasePackSlot(pageID string) {
+	d.pack.mu.Lock()
+	defer d.pack.mu.Unlock()
+	d.releasePackSlotLocked(pageID)
+}
+
+func (d *Notion) releasePackSlotLocked(pageID string) {
+	if d.pack.reserved[pageID] <= 1 {
+		delete(d.pack.reserved, pageID)
+	} else {
+		d.pack.reserved[pageID]--
+	}
+}
+
+// commitPackSlot 将上传完成的附件追加到页面的文件列表并保存文件记录，f.BlobIndex为附件的位置；
+// 位置按完成的顺序分配，先占位的文件不一定排在前面。文件记录在释放占位前保存，页面不会被当作无引用归档
+func (d *Notion) commitPackSlot(pageID string, attachment PageAttachment, f *File) error {
+	d.pack.mu.Lock()
+	defer d.pack.mu.Unlock()
+	defer d.releasePackSlotLocked(pageID)
+	var page PackPage
+	if err := d.db.Where("page_id = ?", pageID).First(&page).Error; err != nil {
+		return fmt.Errorf("获取打包页面失败: %w", err)
+	}
+	var attachments []PageAttachment
+	if err := utils.Json.UnmarshalFromString(page.Attachments, &attachments); err != nil {
+		return fmt.Errorf("解析打包页面的附件失败: %w", err)
+	}
+	attachments = append(attachments, attachment)
+	if err := d.notionClient.UpdateFileList(d.notionClient.pageRecord(pageID), attachments); err != nil {
+		return fmt.Errorf("更新文件状态失败: %w", err)
+	}
+	data, err := utils.Json.MarshalToString(attachments)
+	if err != nil {
+		return err
+	}
+	if err := d.db.Model(&page).Updates(map[string]interface{}{"count": len(attachments), "attachments": data}).Error; err != nil {
+		return fmt.Errorf("保存打包页面失败: %w", err)
+	}
+	f.BlobIndex = len(attachments) - 1
+	if err := d.db.Create(f).Error; err != nil {
+		return fmt.Errorf("保存文件信息失败: %w", err)
+	}
+	return nil
+}
+
+// archivePackPage 归档不再被引用的打包页面，仍有文件在上传的页面保留；返回页面是否为打包页面
+func (d *Notion) archivePackPage(pageID string) (bool, error) {
+	d.pack.mu.Lock()
+	defer d.pack.mu.Unlock()
+	var page PackPage
+	err := d.db.Where("page_id = ?", pageID).First(&page).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return true, err
+	}
+	if d.pack.reserved[pageID] > 0 {
+		return true, nil
+	}
+	inUse, err := d.pageInUse(pageID)
+	if err != nil || inUse {
+		return true, err
+	}
+	if err := d.db.Delete(&page).Error; err != nil {
+		return true, err
+	}
+	return true, d.notionClient.ArchivePage(pageID)
+}
+
+// readPageFile 读取未分块文件的全部内容，包括打包保存的文件
+func (d *Notion) readPageFile(ctx context.Context, f *File) ([]byte, error) {
+	rc, err := d.openPageAttachment(ctx, f.BlobKey, f.BlobIndex, 0, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(io.LimitReader(rc, f.Size))
+	if err != nil {
+		return nil, fmt.Errorf("读取文件失败: %w", err)
+	}
+	if int64(len(data)) != f.Size {
+		return nil, fmt.Errorf("读取了%d字节，文件大小为%d", len(data), f.Size)
+	}
+	return data, nil
+}
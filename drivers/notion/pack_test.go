@@ -0,0 +1,108 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestPackSmallFiles(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.PackSize = 10
+		d.PackCount = 2
+		d.ArchiveOnDelete = true
+	})
+	ctx := context.Background()
+	var objs []model.Obj
+	for i, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		obj, err := d.Put(ctx, rootDir(d), newTestStream(name, testData(1000+i)), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		objs = append(objs, obj)
+	}
+	files := make([]File, len(objs))
+	for i, obj := range objs {
+		if err := d.db.First(&files[i], obj.GetID()).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 每个页面打包PackCount个文件，附件按添加的顺序排列
+	if !files[0].Packed || files[0].BlobKey != files[1].BlobKey || files[0].BlobIndex != 0 || files[1].BlobIndex != 1 {
+		t.Fatalf("expect a.txt and b.txt packed in one page, got %+v and %+v", files[0], files[1])
+	}
+	if files[2].BlobKey == files[0].BlobKey || files[2].BlobIndex != 0 {
+		t.Fatalf("expect c.txt in a new page, got %+v", files[2])
+	}
+	if n := fake.livePages(); n != 2 {
+		t.Fatalf("expect 2 pack pages, got %d", n)
+	}
+	if names := fake.pageFileNames(files[0].BlobKey); len(names) != 2 {
+		t.Fatalf("expect 2 attachments, got %v", names)
+	}
+	for i, obj := range objs {
+		link, err := d.Link(ctx, obj, model.LinkArgs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := readURL(t, link); !bytes.Equal(got, testData(1000+i)) {
+			t.Fatalf("%s: content mismatch", obj.GetName())
+		}
+	}
+
+	// 超过PackSize的文件单独创建页面
+	big, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", testData(20*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, big.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if f.Packed {
+		t.Fatal("expect a file over pack_size not packed")
+	}
+
+	// 页面中还有文件时不归档，最后一个文件删除后归档
+	if err := d.Remove(ctx, objs[0]); err != nil {
+		t.Fatal(err)
+	}
+	if n := waitLivePages(fake, 3); n != 3 {
+		t.Fatalf("expect the shared page kept, got %d live pages", n)
+	}
+	if err := d.Remove(ctx, objs[1]); err != nil {
+		t.Fatal(err)
+	}
+	if n := waitLivePages(fake, 2); n != 2 {
+		t.Fatalf("expect the pack page archived, got %d live pages", n)
+	}
+	var pages int64
+	if err := d.db.Model(&PackPage{}).Count(&pages).Error; err != nil {
+		t.Fatal(err)
+	}
+	if pages != 1 {
+		t.Fatalf("expect 1 pack page left, got %d", pages)
+	}
+}
+
+func TestPackDisabledWithEncryption(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.PackSize = 10
+		d.EncryptionKey = "secret passphrase"
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.txt", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if f.Packed {
+		t.Fatal("expect no packing with encryption")
+	}
+}
@@ -0,0 +1,191 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RepairReq 修复分块的参数，Indexes为空时校验全部分块的SHA1，修复读取失败或不一致的分块
+type RepairReq struct {
+	Indexes []int `json:"indexes"`
+}
+
+// RepairResult 修复的结果，Repaired为重新上传的分块序号
+type RepairResult struct {
+	Checked  int   `json:"checked"`
+	Repaired []int `json:"repaired"`
+}
+
+// checkParity 检查校验分块的配置，Reed-Solomon每组最多256个分块
+func (d *Notion) checkParity() error {
+	if d.ParityData <= 0 {
+		return nil
+	}
+	if d.ParityShards <= 0 || d.ParityData+d.ParityShards > 256 {
+		return fmt.Errorf("parity_data与parity_shards之和需在2到256之间，parity_shards至少为1")
+	}
+	return nil
+}
+
+// putParity 为刚上传的分块生成校验分块并保存，r从第一个分块的开头读取，未开启时不做任何事
+func (d *Notion) putParity(ctx context.Context, f *File, r io.ReaderAt, chunks []chunkstore.Chunk) error {
+	if d.ParityData <= 0 || len(chunks) == 0 {
+		return nil
+	}
+	backend, err := d.newChunkBackend(f.Name+".parity", "")
+	if err != nil {
+		return err
+	}
+	p := chunkstore.Parity{Data: d.ParityData, Shards: d.ParityShards}
+	parity, err := p.Encode(ctx, backend, r, chunks, func(float64) {})
+	if err != nil {
+		return d.lang().errorf(msgUploadParity, err)
+	}
+	rows := make([]ParityChunk, 0, len(parity))
+	for _, chunk := range parity {
+		rows = append(rows, ParityChunk{
+			FileID:       f.ID,
+			DataShards:   p.Data,
+			ParityShards: p.Shards,
+			ChunkIndex:   chunk.Index,
+			ChunkSize:    chunk.Size(),
+			BlobKey:      chunk.Key,
+			SHA1:         chunk.Hash,
+			Nonce:        chunk.Nonce,
+			KeyID:        chunk.KeyID,
+		})
+	}
+	if err := d.db.Create(&rows).Error; err != nil {
+		return d.lang().errorf(msgSaveParity, err)
+	}
+	return nil
+}
+
+// parityChunks 文件的校验分块，按序号排序
+func parityChunks(tx *gorm.DB, fileID int) ([]ParityChunk, error) {
+	var rows []ParityChunk
+	if err := tx.Where("file_id = ?", fileID).Order("chunk_index").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("获取校验分块失败: %w", err)
+	}
+	return rows, nil
+}
+
+// deleteParity 删除文件的校验分块记录，返回其页面；校验分块不与其他文件共享，不保留删除标记
+func deleteParity(tx *gorm.DB, fileID int) ([]string, error) {
+	var pageIDs []string
+	if err := tx.Model(&ParityChunk{}).Where("file_id = ?", fileID).Pluck("notion_page_id", &pageIDs).Error; err != nil {
+		return nil, fmt.Errorf("获取校验分块失败: %w", err)
+	}
+	if len(pageIDs) == 0 {
+		return nil, nil
+	}
+	if err := tx.Where("file_id = ?", fileID).Delete(&ParityChunk{}).Error; err != nil {
+		return nil, fmt.Errorf("删除校验分块失败: %w", err)
+	}
+	return pageIDs, nil
+}
+
+// repairChunks 由同组的其他分块和校验分块恢复丢失或损坏的分块，恢复的分块上传为新页面并替换分块记录；
+// 与其他文件共享的旧页面仍被引用时不归档
+func (d *Notion) repairChunks(ctx context.Context, fileID string, req RepairReq) (*RepairResult, error) {
+	f, err := d.tree.GetFile(fileID)
+	if err != nil {
+		return nil, err
+	}
+	if !f.IsChunked {
+		return nil, fmt.Errorf("文件[%s]不是分块文件", f.Name)
+	}
+	rows, err := parityChunks(d.db, f.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("文件[%s]没有校验分块", f.Name)
+	}
+	fileChunks, err := d.fileChunks(f.ID)
+	if err != nil {
+		return nil, err
+	}
+	result := &RepairResult{Repaired: make([]int, 0)}
+	lost := req.Indexes
+	if len(lost) == 0 {
+		for _, c := range fileChunks {
+			result.Checked++
+			size := c.ChunkSize
+			if c.Nonce != "" {
+				size = chunkstore.EncryptedSize(size)
+			}
+			sum, err := d.hashPage(ctx, c.BlobKey, 0, size)
+			if err != nil {
+				log.Warnf("读取文件[%s]的分块%d失败: %v", f.Name, c.ChunkIndex, err)
+				lost = append(lost, c.ChunkIndex)
+			} else if c.SHA1 != "" && !strings.EqualFold(sum, c.SHA1) {
+				log.Warnf("文件[%s]的分块%d的SHA1为%s，记录为%s", f.Name, c.ChunkIndex, sum, c.SHA1)
+				lost = append(lost, c.ChunkIndex)
+			}
+		}
+		if len(lost) == 0 {
+			return result, nil
+		}
+	}
+	if d.chunkKey == nil && (fileChunks[0].Nonce != "" || rows[0].Nonce != "") {
+		return nil, fmt.Errorf("文件已加密，需要配置加密密钥")
+	}
+	backend, err := d.newChunkBackend(f.Name, d.contentType(f.Name, nil))
+	if err != nil {
+		return nil, err
+	}
+	parityBackend, err := d.newChunkBackend(f.Name+".parity", "")
+	if err != nil {
+		return nil, err
+	}
+	parity := make([]chunkstore.Chunk, 0, len(rows))
+	for _, row := range rows {
+		parity = append(parity, chunkstore.Chunk{
+			Index: row.ChunkIndex,
+			End:   row.ChunkSize,
+			Key:   row.BlobKey,
+			Hash:  row.SHA1,
+			Nonce: row.Nonce,
+			KeyID: row.KeyID,
+		})
+	}
+	p := chunkstore.Parity{Data: rows[0].DataShards, Shards: rows[0].ParityShards}
+	rebuilt, err := p.Reconstruct(ctx, backend, parityBackend, toChunks(fileChunks), parity, lost)
+	if err != nil {
+		return nil, fmt.Errorf("恢复分块失败: %w", err)
+	}
+	var pageIDs []string
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		for _, chunk := range rebuilt {
+			for _, c := range fileChunks {
+				if c.ChunkIndex == chunk.Index {
+					pageIDs = append(pageIDs, c.BlobKey)
+				}
+			}
+			if err := tx.Model(&FileChunk{}).Where("file_id = ? AND chunk_index = ? AND deleted = ?", f.ID, chunk.Index, false).
+				Updates(map[string]interface{}{
+					"notion_page_id": chunk.Key,
+					"sha1":           chunk.Hash,
+					"nonce":          chunk.Nonce,
+					"key_id":         chunk.KeyID,
+					"verified_at":    nil,
+				}).Error; err != nil {
+				return fmt.Errorf("更新分块%d失败: %w", chunk.Index, err)
+			}
+			result.Repaired = append(result.Repaired, chunk.Index)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.archivePages(pageIDs)
+	return result, nil
+}
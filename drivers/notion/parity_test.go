@@ -0,0 +1,138 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// losePage 删除页面附件的内容，模拟丢失的分块
+func (f *fakeNotion) losePage(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, file := range f.pages[id].files {
+		_, key, _ := strings.Cut(file.File.URL, "/s3/")
+		delete(f.objects, key)
+	}
+}
+
+func repairChunks(t *testing.T, d *Notion, obj model.Obj, indexes ...int) *RepairResult {
+	t.Helper()
+	res, err := d.Other(context.Background(), model.OtherArgs{Obj: obj, Method: "repair_chunks", Data: RepairReq{Indexes: indexes}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res.(*RepairResult)
+}
+
+func TestRepairChunks(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ArchiveOnDelete = true
+		d.ParityData = 2
+		d.ParityShards = 1
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	data := testData(3 * 1024 * 1024)
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	fileID, _ := strconv.Atoi(obj.GetID())
+	chunks, err := d.fileChunks(fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parity, err := parityChunks(d.db, fileID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 每2个分块一个校验分块
+	if want := (len(chunks) + 1) / 2; len(parity) != want {
+		t.Fatalf("expect %d parity chunks for %d chunks, got %d", want, len(chunks), len(parity))
+	}
+	if res := repairChunks(t, d, obj); res.Checked != len(chunks) || len(res.Repaired) != 0 {
+		t.Fatalf("expect every chunk checked and none repaired, got %+v", res)
+	}
+
+	// 不同组中损坏和丢失的分块都可以恢复
+	fake.corruptPage(chunks[0].BlobKey)
+	fake.losePage(chunks[len(chunks)-1].BlobKey)
+	res := repairChunks(t, d, obj)
+	if len(res.Repaired) != 2 || res.Repaired[0] != chunks[0].ChunkIndex || res.Repaired[1] != chunks[len(chunks)-1].ChunkIndex {
+		t.Fatalf("expect the first and the last chunks repaired, got %+v", res)
+	}
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch after repairing")
+	}
+	// 指定序号时不校验，直接恢复
+	if res := repairChunks(t, d, obj, chunks[1].ChunkIndex); res.Checked != 0 || len(res.Repaired) != 1 {
+		t.Fatalf("expect chunk %d repaired, got %+v", chunks[1].ChunkIndex, res)
+	}
+
+	// 校验分块的页面不是孤立页面，删除文件时一并归档
+	fake.agePages(2 * time.Hour)
+	if orphans := listOrphans(t, d); len(orphans) != 0 {
+		t.Fatalf("expect no orphan, got %+v", orphans)
+	}
+	if err := d.Remove(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	if n := waitLivePages(fake, 0); n != 0 {
+		t.Fatalf("expect the chunk and parity pages archived, got %d live pages", n)
+	}
+	if rows, _ := parityChunks(d.db, fileID); len(rows) != 0 {
+		t.Fatalf("expect the parity records deleted, got %d", len(rows))
+	}
+}
+
+func TestRepairChunksRefused(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	small, err := d.Put(ctx, rootDir(d), newTestStream("small.bin", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 没有校验分块的文件不能修复
+	big, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", testData(3*1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, obj := range []model.Obj{small, big} {
+		if _, err := d.Other(ctx, model.OtherArgs{Obj: obj, Method: "repair_chunks", Data: RepairReq{}}); err == nil {
+			t.Fatalf("expect repairing %s refused", obj.GetName())
+		}
+	}
+}
+
+func TestCheckParity(t *testing.T) {
+	for _, c := range []struct {
+		data, shards int
+		ok           bool
+	}{
+		{0, 0, true},
+		{4, 2, true},
+		{254, 2, true},
+		{4, 0, false},
+		{255, 2, false},
+	} {
+		d := &Notion{Addition: Addition{ParityData: c.data, ParityShards: c.shards}}
+		if err := d.checkParity(); (err == nil) != c.ok {
+			t.Errorf("%d+%d: expect ok %v, got %v", c.data, c.shards, c.ok, err)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package notion
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// removeBatch 批量删除目录时每条语句处理的目录、文件或页面数
+const removeBatch = 500
+
+// filePageIDs 返回文件占用的Notion页面，分块文件返回全部分块和校验分块页面，另含缩略图页面
+func filePageIDs(tx *gorm.DB, f *File) ([]string, error) {
+	return filesPageIDs(tx, []File{*f})
+}
+
+// filesPageIDs 返回多个文件占用的Notion页面，分块和校验分块页面各用一次查询获取
+func filesPageIDs(tx *gorm.DB, files []File) ([]string, error) {
+	var pageIDs []string
+	var chunked []int
+	for i := range files {
+		f := &files[i]
+		if f.ThumbKey != "" {
+			pageIDs = append(pageIDs, f.ThumbKey)
+		}
+		if f.IsChunked {
+			chunked = append(chunked, f.ID)
+		} else if f.BlobKey != "" {
+			pageIDs = append(pageIDs, f.BlobKey)
+		}
+	}
+	if len(chunked) == 0 {
+		return pageIDs, nil
+	}
+	var chunkPageIDs []string
+	if err := tx.Model(&FileChunk{}).Where("file_id IN ?", chunked).Pluck("notion_page_id", &chunkPageIDs).Error; err != nil {
+		return nil, fmt.Errorf("获取文件分块页面失败: %w", err)
+	}
+	var parityPageIDs []string
+	if err := tx.Model(&ParityChunk{}).Where("file_id IN ?", chunked).Pluck("notion_page_id", &parityPageIDs).Error; err != nil {
+		return nil, fmt.Errorf("获取校验分块页面失败: %w", err)
+	}
+	pageIDs = append(pageIDs, chunkPageIDs...)
+	return append(pageIDs, parityPageIDs...), nil
+}
+
+// versionPageIDs 返回文件全部历史版本占用的Notion页面
+func versionPageIDs(tx *gorm.DB, fileID int) ([]string, error) {
+	var versions []FileVersion
+	if err := tx.Where("file_id = ?", fileID).Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("获取历史版本失败: %w", err)
+	}
+	var pageIDs []string
+	for _, v := range versions {
+		var f File
+		if err := tx.Where("id = ?", v.VersionFileID).First(&f).Error; err != nil {
+			return nil, fmt.Errorf("获取历史版本%d失败: %v", v.ID, err)
+		}
+		ids, err := filePageIDs(tx, &f)
+		if err != nil {
+			return nil, err
+		}
+		pageIDs = append(pageIDs, ids...)
+	}
+	return pageIDs, nil
+}
+
+// purgeFile 删除文件及其分块和历史版本，返回可能需要归档的页面
+func (d *Notion) purgeFile(f *File) ([]string, error) {
+	var pageIDs []string
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		ids, err := filePageIDs(tx, f)
+		if err != nil {
+			return err
+		}
+		versionIDs, err := versionPageIDs(tx, f.ID)
+		if err != nil {
+			return err
+		}
+		pageIDs = append(ids, versionIDs...)
+
+		if err := tx.Model(&File{}).Where("id = ?", f.ID).Update("deleted", true).Error; err != nil {
+			return fmt.Errorf("删除文件失败: %w", err)
+		}
+		if err := d.deleteFileChunks(tx, f); err != nil {
+			return err
+		}
+		var versions []FileVersion
+		if err := tx.Where("file_id = ?", f.ID).Find(&versions).Error; err != nil {
+			return fmt.Errorf("获取历史版本失败: %w", err)
+		}
+		for i := range versions {
+			if err := d.dropVersion(tx, &versions[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return pageIDs, err
+}
+
+// purgeFiles 与purgeFile相同，但在一个事务中用批量语句删除多个文件及其分块、校验分块和历史版本
+func (d *Notion) purgeFiles(files []File) ([]string, error) {
+	var pageIDs []string
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		ids := make([]int, 0, len(files))
+		for i := range files {
+			ids = append(ids, files[i].ID)
+		}
+		var versionFileIDs []int
+		if err := tx.Model(&FileVersion{}).Where("file_id IN ?", ids).Pluck("version_file_id", &versionFileIDs).Error; err != nil {
+			return fmt.Errorf("获取历史版本失败: %w", err)
+		}
+		all := files
+		if len(versionFileIDs) > 0 {
+			var versionFiles []File
+			if err := tx.Where("id IN ?", versionFileIDs).Find(&versionFiles).Error; err != nil {
+				return fmt.Errorf("获取历史版本文件失败: %w", err)
+			}
+			all = append(append([]File(nil), files...), versionFiles...)
+		}
+		var err error
+		if pageIDs, err = filesPageIDs(tx, all); err != nil {
+			return err
+		}
+
+		if err := tx.Model(&File{}).Where("id IN ?", ids).Update("deleted", true).Error; err != nil {
+			return fmt.Errorf("删除文件失败: %w", err)
+		}
+		var chunked []int
+		for i := range all {
+			if all[i].IsChunked {
+				chunked = append(chunked, all[i].ID)
+			}
+		}
+		if len(chunked) > 0 {
+			if err := tx.Model(&FileChunk{}).Where("file_id IN ?", chunked).Update("deleted", true).Error; err != nil {
+				return d.lang().errorf(msgDeleteChunks, err)
+			}
+			if err := tx.Where("file_id IN ?", chunked).Delete(&ParityChunk{}).Error; err != nil {
+				return fmt.Errorf("删除校验分块失败: %w", err)
+			}
+		}
+		if err := tx.Where("file_id IN ?", ids).Delete(&FileVersion{}).Error; err != nil {
+			return fmt.Errorf("删除历史版本失败: %w", err)
+		}
+		return nil
+	})
+	return pageIDs, err
+}
+
+// removeDir 删除目录及其全部子目录和文件，子目录由一次递归查询得到，目录和文件按批标记删除，
+// 子目录先于目录本身删除，中途失败时目录仍然可见，可以再次删除；
+// 归档模式下文件按批清理分块和历史版本，不再被引用的页面在后台批量检查和归档
+func (d *Notion) removeDir(obj model.Obj) error {
+	dirID, _ := strconv.Atoi(obj.GetID())
+	dirIDs, err := d.tree.SubtreeDirIDs(d.db, dirID)
+	if err != nil {
+		return d.lang().errorf(msgGetSubDirs, err)
+	}
+	slices.Reverse(dirIDs)
+	var pageIDs []string
+	defer func() {
+		if len(pageIDs) > 0 {
+			go d.archivePages(pageIDs)
+		}
+	}()
+	for ids := range slices.Chunk(dirIDs, removeBatch) {
+		if d.ArchiveOnDelete {
+			for {
+				var files []File
+				if err := d.db.Where("directory_id IN ? AND deleted = ?", ids, false).Limit(removeBatch).Find(&files).Error; err != nil {
+					return d.lang().errorf(msgListDirFiles, err)
+				}
+				if len(files) == 0 {
+					break
+				}
+				purged, err := d.purgeFiles(files)
+				if err != nil {
+					return err
+				}
+				pageIDs = append(pageIDs, purged...)
+			}
+		}
+		if err := d.db.Model(&File{}).Where("directory_id IN ? AND deleted = ?", ids, false).Update("deleted", true).Error; err != nil {
+			return d.lang().errorf(msgDeleteDirFiles, err)
+		}
+		if err := d.db.Model(&Directory{}).Where("id IN ?", ids).Update("deleted", true).Error; err != nil {
+			return d.lang().errorf(msgDeleteDir, err)
+		}
+	}
+	return nil
+}
+
+// pageInUse 判断页面是否仍被未删除的文件（包括缩略图）、分块、校验分块或历史版本引用，
+// link模式复制出的文件与源文件共享页面
+func (d *Notion) pageInUse(pageID string) (bool, error) {
+	var count int64
+	if err := d.db.Model(&File{}).Where("(notion_page_id = ? OR thumb_page_id = ?) AND deleted = ?", pageID, pageID, false).
+		Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := d.db.Model(&FileChunk{}).Where("notion_page_id = ? AND deleted = ?", pageID, false).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := d.db.Model(&ParityChunk{}).Where("notion_page_id = ?", pageID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	if count > 0 {
+		return true, nil
+	}
+	if err := d.db.Model(&FileVersion{}).
+		Joins("JOIN files ON files.id = file_versions.version_file_id").
+		Where("files.notion_page_id = ? OR files.thumb_page_id = ?", pageID, pageID).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// archivePages 归档不再被引用的页面，归档失败不影响删除结果，只记录日志
+func (d *Notion) archivePages(pageIDs []string) {
+	if !d.ArchiveOnDelete {
+		return
+	}
+	seen := make(map[string]struct{}, len(pageIDs))
+	unique := make([]string, 0, len(pageIDs))
+	for _, pageID := range pageIDs {
+		if _, ok := seen[pageID]; ok || pageID == "" {
+			continue
+		}
+		seen[pageID] = struct{}{}
+		unique = append(unique, pageID)
+	}
+	// 引用按批查询，删除大目录时不必为每个页面查询一次
+	for batch := range slices.Chunk(unique, removeBatch) {
+		var packed []string
+		if err := d.db.Model(&PackPage{}).Where("page_id IN ?", batch).Pluck("page_id", &packed).Error; err != nil {
+			log.Warnf("查询打包页面失败: %+v", err)
+			continue
+		}
+		inUse, err := d.pagesInUse(batch)
+		if err != nil {
+			log.Warnf("检查页面引用失败: %+v", err)
+			continue
+		}
+		for _, pageID := range batch {
+			// 打包页面在分配位置时加锁检查，避免归档正在添加附件的页面
+			if slices.Contains(packed, pageID) {
+				if _, err := d.archivePackPage(pageID); err != nil {
+					log.Warnf("归档打包页面[%s]失败: %+v", pageID, err)
+				}
+				continue
+			}
+			if _, ok := inUse[pageID]; ok {
+				continue
+			}
+			if err := d.notionClient.ArchivePage(pageID); err != nil {
+				log.Warnf("归档页面[%s]失败: %+v", pageID, err)
+				continue
+			}
+			d.archiveSecondary(pageID)
+		}
+	}
+}
+
+// pagesInUse 与pageInUse相同，批量查询pageIDs中仍被引用的页面
+func (d *Notion) pagesInUse(pageIDs []string) (map[string]struct{}, error) {
+	inUse := make(map[string]struct{})
+	versions := func() *gorm.DB {
+		return d.db.Model(&FileVersion{}).Joins("JOIN files ON files.id = file_versions.version_file_id")
+	}
+	for _, q := range []struct {
+		db     *gorm.DB
+		column string
+	}{
+		{d.db.Model(&File{}).Where("notion_page_id IN ? AND deleted = ?", pageIDs, false), "notion_page_id"},
+		{d.db.Model(&File{}).Where("thumb_page_id IN ? AND deleted = ?", pageIDs, false), "thumb_page_id"},
+		{d.db.Model(&FileChunk{}).Where("notion_page_id IN ? AND deleted = ?", pageIDs, false), "notion_page_id"},
+		{d.db.Model(&ParityChunk{}).Where("notion_page_id IN ?", pageIDs), "notion_page_id"},
+		{versions().Where("files.notion_page_id IN ?", pageIDs), "files.notion_page_id"},
+		{versions().Where("files.thumb_page_id IN ?", pageIDs), "files.thumb_page_id"},
+	} {
+		var ids []string
+		if err := q.db.Distinct().Pluck(q.column, &ids).Error; err != nil {
+			return nil, err
+		}
+		for _, id := range ids {
+			inUse[id] = struct{}{}
+		}
+	}
+	return inUse, nil
+}
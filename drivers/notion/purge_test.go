@@ -0,0 +1,191 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"gorm.io/gorm"
+)
+
+// waitLivePages 等待后台归档完成，返回最后一次的未归档页面数
+func waitLivePages(fake *fakeNotion, want int) int {
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		n := fake.livePages()
+		if n == want || time.Now().After(deadline) {
+			return n
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRemoveArchivesPage(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.ArchiveOnDelete = true })
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(context.Background(), obj); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if n := fake.livePages(); n != 0 {
+		t.Fatalf("expect the page archived, got %d live pages", n)
+	}
+}
+
+func TestRemoveKeepsPagesByDefault(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(context.Background(), obj); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 0 {
+		t.Fatalf("expect the file removed, got %v", names)
+	}
+	if n := fake.livePages(); n != 1 {
+		t.Fatalf("expect the page kept, got %d live pages", n)
+	}
+}
+
+func TestRemoveDirArchivesPages(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ArchiveOnDelete = true
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := d.MakeDir(ctx, dir, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Put(ctx, dir, newTestStream("a.bin", testData(1000)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Put(ctx, sub, newTestStream("big.bin", testData(3*1024*1024)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	// 根目录中的文件不受影响
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("keep.bin", testData(2000)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.livePages(); n < 4 {
+		t.Fatalf("expect pages for every file and chunk, got %d", n)
+	}
+	if err := d.Remove(ctx, dir); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if n := waitLivePages(fake, 1); n != 1 {
+		t.Fatalf("expect only the page of keep.bin, got %d live pages", n)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 || names[0] != "keep.bin" {
+		t.Fatalf("unexpected list %v", names)
+	}
+}
+
+// TestRemoveDirTree 删除多层目录时一并删除历史版本和分块，仍被其他文件引用的页面保留
+func TestRemoveDirTree(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ArchiveOnDelete = true
+		d.VersionRetention = 2
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+	deep, err := d.MakeDir(ctx, dir, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deep, err = d.MakeDir(ctx, deep, "deep"); err != nil {
+		t.Fatal(err)
+	}
+	// 覆盖上传保留历史版本
+	for _, size := range []int{1000, 2000} {
+		if _, err := d.Put(ctx, dir, newTestStream("a.bin", testData(size)), func(float64) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Put(ctx, deep, newTestStream("big.bin", testData(3*1024*1024)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	data := testData(3000)
+	shared, err := d.Put(ctx, deep, newTestStream("shared.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	copied, err := d.Copy(ctx, shared, rootDir(d))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := d.Remove(ctx, dir); err != nil {
+		t.Fatalf("remove: %v", err)
+	}
+	if n := waitLivePages(fake, 1); n != 1 {
+		t.Fatalf("expect only the page of the copy, got %d live pages", n)
+	}
+	link, err := d.Link(ctx, copied, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content of the copy differs")
+	}
+	for _, c := range []struct {
+		q    *gorm.DB
+		want int64
+	}{
+		{d.db.Model(&File{}).Where("deleted = ?", false), 1},
+		{d.db.Model(&Directory{}).Where("deleted = ? AND parent_id IS NOT NULL", false), 0},
+		{d.db.Model(&FileChunk{}).Where("deleted = ?", false), 0},
+		{d.db.Model(&FileVersion{}), 0},
+	} {
+		var n int64
+		if err := c.q.Count(&n).Error; err != nil || n != c.want {
+			t.Fatalf("expect %d records left, got %d %v", c.want, n, err)
+		}
+	}
+	// 再次删除已删除的目录不报错
+	if err := d.Remove(ctx, dir); err != nil {
+		t.Fatalf("expect removing again to succeed, got %v", err)
+	}
+}
+
+func TestPagesInUse(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageID := blobKey(t, d, obj.GetID())
+	inUse, err := d.pagesInUse([]string{pageID, "unknown"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := inUse[pageID]; !ok || len(inUse) != 1 {
+		t.Fatalf("expect only the page of a.bin in use, got %v", inUse)
+	}
+	if err := d.Remove(context.Background(), obj); err != nil {
+		t.Fatal(err)
+	}
+	if inUse, err = d.pagesInUse([]string{pageID}); err != nil || len(inUse) != 0 {
+		t.Fatalf("expect the page of the removed file unused, got %v %v", inUse, err)
+	}
+}
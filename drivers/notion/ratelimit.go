@@ -0,0 +1,193 @@
+package notion
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+const (
+	// throttledRate Notion限流后的初始速度，即Notion文档中的平均每秒3个请求
+	throttledRate = rate.Limit(3)
+	// minAPIRate 多次限流后速度的下限
+	minAPIRate = rate.Limit(0.1)
+	// apiRecoverInterval 未再被限流时每隔这段时间把速度提高四分之一
+	apiRecoverInterval = time.Minute
+	// throttleRetries 被限流的请求在暂停后重试的次数
+	throttleRetries = 3
+	// maxThrottleWait Retry-After等限流信息中等待时间的上限
+	maxThrottleWait = 10 * time.Minute
+)
+
+// apiLimiter 存储的Notion API请求限速，按响应中的Retry-After和X-RateLimit-*头自适应调整：
+// 被限流时暂停所有请求到限流结束并把速度减半，之后长时间未再被限流时逐步恢复到设置的上限
+type apiLimiter struct {
+	mu      sync.Mutex
+	limiter *rate.Limiter
+	// max 设置的速度上限，rate.Inf表示不限速
+	max rate.Limit
+	// burst 未被限流时的突发请求数，被限流时降为1
+	burst int
+	// pausedUntil 限流结束前暂停发送请求
+	pausedUntil time.Time
+	// changedAt 上次调整速度的时间
+	changedAt time.Time
+}
+
+func newAPILimiter(perSecond int) *apiLimiter {
+	limit := rate.Inf
+	if perSecond > 0 {
+		limit = rate.Limit(perSecond)
+	}
+	burst := max(1, perSecond)
+	return &apiLimiter{limiter: rate.NewLimiter(limit, burst), max: limit, burst: burst}
+}
+
+// wait 等待限流结束并取得一个请求的配额
+func (l *apiLimiter) wait(ctx context.Context) error {
+	l.mu.Lock()
+	pause := time.Until(l.pausedUntil)
+	l.mu.Unlock()
+	if pause > 0 {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(pause):
+		}
+	}
+	return l.limiter.Wait(ctx)
+}
+
+// observe 根据响应调整速度，返回是否被限流
+func (l *apiLimiter) observe(resp *http.Response) bool {
+	now := time.Now()
+	wait, throttled := throttleWait(resp, now)
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if !throttled {
+		l.recover(now)
+		return false
+	}
+	if until := now.Add(wait); until.After(l.pausedUntil) {
+		l.pausedUntil = until
+	}
+	limit := l.limiter.Limit()
+	if limit == rate.Inf || limit > throttledRate {
+		limit = throttledRate
+	} else {
+		limit = max(limit/2, minAPIRate)
+	}
+	l.limiter.SetLimitAt(now, limit)
+	l.limiter.SetBurstAt(now, 1)
+	l.changedAt = now
+	log.Warnf("Notion API被限流，暂停%s，请求速度降至每秒%.2f个", wait, float64(limit))
+	return true
+}
+
+// recover 距上次调整超过apiRecoverInterval时把速度提高四分之一，不超过上限，恢复到上限时同时恢复突发请求数
+func (l *apiLimiter) recover(now time.Time) {
+	limit := l.limiter.Limit()
+	if limit == l.max || now.Sub(l.changedAt) < apiRecoverInterval {
+		return
+	}
+	limit *= 1.25
+	if l.max == rate.Inf && limit >= throttledRate*2 || l.max != rate.Inf && limit >= l.max {
+		limit = l.max
+		l.limiter.SetBurstAt(now, l.burst)
+	}
+	l.limiter.SetLimitAt(now, limit)
+	l.changedAt = now
+}
+
+// throttleWait 从响应判断是否被限流以及需要等待的时间：429或503时按Retry-After等待，
+// X-RateLimit-Remaining为0时等待到X-RateLimit-Reset，没有给出时间时等待1秒
+func throttleWait(resp *http.Response, now time.Time) (time.Duration, bool) {
+	wait, ok := headerWait(resp.Header.Get("Retry-After"), now)
+	if resp.StatusCode == http.StatusTooManyRequests || (resp.StatusCode == http.StatusServiceUnavailable && ok) {
+		if !ok {
+			wait = time.Second
+		}
+		return min(wait, maxThrottleWait), true
+	}
+	if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+		wait, ok = headerWait(resp.Header.Get("X-RateLimit-Reset"), now)
+		if !ok {
+			wait = time.Second
+		}
+		return min(wait, maxThrottleWait), true
+	}
+	return 0, false
+}
+
+// headerWait 解析等待时间，值可以是秒数、Unix时间戳或HTTP日期
+func headerWait(value string, now time.Time) (time.Duration, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if n, err := strconv.ParseFloat(value, 64); err == nil {
+		// 大于一年的秒数按Unix时间戳处理
+		if n > 365*24*3600 {
+			return max(time.Unix(int64(n), 0).Sub(now), 0), true
+		}
+		return time.Duration(n * float64(time.Second)), true
+	}
+	if t, err := http.ParseTime(value); err == nil {
+		return max(t.Sub(now), 0), true
+	}
+	return 0, false
+}
+
+// limitTransport 对地址以apiBases之一开头的Notion API请求限速，被限流的请求在暂停后重试，
+// S3和附件下载的请求不受影响
+type limitTransport struct {
+	base     http.RoundTripper
+	limiter  *apiLimiter
+	apiBases []string
+}
+
+func (t limitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	u := req.URL.String()
+	if !slices.ContainsFunc(t.apiBases, func(base string) bool { return strings.HasPrefix(u, base) }) {
+		return t.base.RoundTrip(req)
+	}
+	for retry := 0; ; retry++ {
+		if err := t.limiter.wait(req.Context()); err != nil {
+			return nil, err
+		}
+		resp, err := t.base.RoundTrip(req)
+		if err != nil {
+			return nil, err
+		}
+		throttled := t.limiter.observe(resp)
+		// 没有请求体或请求体可以重新获取时才能重试
+		if !throttled || resp.StatusCode < 400 || retry >= throttleRetries || (req.Body != nil && req.GetBody == nil) {
+			return resp, nil
+		}
+		retries.WithLabelValues("throttled").Inc()
+		_, _ = io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		req = req.Clone(req.Context())
+		if req.Body != nil {
+			if req.Body, err = req.GetBody(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
+
+// useAPILimiter 客户端的Notion API请求使用限速器l，需要在setBaseURLs之后调用
+func (s *NotionService) useAPILimiter(l *apiLimiter) {
+	s.api = &http.Client{Transport: limitTransport{
+		base:     apiClient.Transport,
+		limiter:  l,
+		apiBases: []string{s.apiBase, s.publicAPIBase},
+	}}
+}
@@ -0,0 +1,200 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+func TestAPILimiterRecover(t *testing.T) {
+	l := newAPILimiter(10)
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0"}}}
+	if !l.observe(resp) {
+		t.Fatal("expect a 429 to throttle the requests")
+	}
+	if l.limiter.Burst() != 1 {
+		t.Fatalf("expect the burst to drop to 1, got %d", l.limiter.Burst())
+	}
+	// 长时间未被限流后逐步恢复到设置的速度和突发请求数
+	now := time.Now()
+	for i := 0; i < 10 && l.limiter.Limit() != l.max; i++ {
+		now = now.Add(apiRecoverInterval)
+		l.recover(now)
+	}
+	if l.limiter.Limit() != l.max || l.limiter.Burst() != 10 {
+		t.Fatalf("expect the limit 10 and the burst 10 restored, got %v and %d", l.limiter.Limit(), l.limiter.Burst())
+	}
+}
+
+func TestAPILimiterThrottle(t *testing.T) {
+	l := newAPILimiter(0)
+	if l.limiter.Limit() != rate.Inf {
+		t.Fatalf("expect no limit by default, got %v", l.limiter.Limit())
+	}
+	// 每次限流速度减半，不低于下限
+	resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0"}}}
+	for _, want := range []rate.Limit{throttledRate, throttledRate / 2, throttledRate / 4} {
+		if !l.observe(resp) || l.limiter.Limit() != want {
+			t.Fatalf("expect the limit %v, got %v", want, l.limiter.Limit())
+		}
+	}
+	for i := 0; i < 10; i++ {
+		l.observe(resp)
+	}
+	if l.limiter.Limit() != minAPIRate {
+		t.Fatalf("expect the limit kept at %v, got %v", minAPIRate, l.limiter.Limit())
+	}
+	// 限流后不久的正常响应不恢复速度
+	if l.observe(&http.Response{StatusCode: http.StatusOK}) || l.limiter.Limit() != minAPIRate {
+		t.Fatalf("expect the limit kept right after throttling, got %v", l.limiter.Limit())
+	}
+	// 不限速时恢复到限流初始速度的两倍后取消限速
+	now := time.Now()
+	for i := 0; i < 100 && l.limiter.Limit() != rate.Inf; i++ {
+		now = now.Add(apiRecoverInterval)
+		l.recover(now)
+	}
+	if l.limiter.Limit() != rate.Inf {
+		t.Fatalf("expect the limit removed, got %v", l.limiter.Limit())
+	}
+}
+
+// TestAPILimiterPause 限流期间暂停发送请求
+func TestAPILimiterPause(t *testing.T) {
+	l := newAPILimiter(0)
+	l.observe(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"0.2"}}})
+	start := time.Now()
+	if err := l.wait(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 150*time.Millisecond {
+		t.Fatalf("expect the request paused, waited %v", elapsed)
+	}
+	l.observe(&http.Response{StatusCode: http.StatusTooManyRequests, Header: http.Header{"Retry-After": {"60"}}})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := l.wait(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expect the wait canceled, got %v", err)
+	}
+}
+
+func TestThrottleWait(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	for _, c := range []struct {
+		status    int
+		header    http.Header
+		wait      time.Duration
+		throttled bool
+	}{
+		{http.StatusOK, nil, 0, false},
+		{http.StatusTooManyRequests, nil, time.Second, true},
+		{http.StatusTooManyRequests, http.Header{"Retry-After": {"2.5"}}, 2500 * time.Millisecond, true},
+		{http.StatusTooManyRequests, http.Header{"Retry-After": {now.Add(30 * time.Second).UTC().Format(http.TimeFormat)}}, 30 * time.Second, true},
+		{http.StatusTooManyRequests, http.Header{"Retry-After": {"86400"}}, maxThrottleWait, true},
+		{http.StatusServiceUnavailable, nil, 0, false},
+		{http.StatusServiceUnavailable, http.Header{"Retry-After": {"3"}}, 3 * time.Second, true},
+		{http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"1"}}, 0, false},
+		{http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"0"}, "X-Ratelimit-Reset": {"1700000005"}}, 5 * time.Second, true},
+		{http.StatusOK, http.Header{"X-Ratelimit-Remaining": {"0"}}, time.Second, true},
+	} {
+		wait, throttled := throttleWait(&http.Response{StatusCode: c.status, Header: c.header}, now)
+		if wait != c.wait || throttled != c.throttled {
+			t.Errorf("%d %v: expect %v %v, got %v %v", c.status, c.header, c.wait, c.throttled, wait, throttled)
+		}
+	}
+}
+
+// roundTripFunc 以函数实现的RoundTripper
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestLimitTransport(t *testing.T) {
+	// 按顺序返回statuses中的状态码，记录收到的请求体
+	var bodies []string
+	var statuses []int
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		body := ""
+		if req.Body != nil {
+			b, _ := io.ReadAll(req.Body)
+			body = string(b)
+		}
+		bodies = append(bodies, body)
+		status := http.StatusOK
+		if len(statuses) > 0 {
+			status, statuses = statuses[0], statuses[1:]
+		}
+		return &http.Response{StatusCode: status, Header: http.Header{"Retry-After": {"0"}}, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	tr := limitTransport{base: base, limiter: newAPILimiter(0), apiBases: []string{"https://api.notion.com/"}}
+	do := func(url string, body io.Reader) int {
+		req, _ := http.NewRequest(http.MethodPost, url, body)
+		resp, err := tr.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp.StatusCode
+	}
+
+	// 被限流的请求重试时重新发送请求体
+	statuses = []int{http.StatusTooManyRequests, http.StatusTooManyRequests}
+	if status := do("https://api.notion.com/v1/pages", bytes.NewReader([]byte("page"))); status != http.StatusOK {
+		t.Fatalf("expect the request retried, got %d", status)
+	}
+	if strings.Join(bodies, ",") != "page,page,page" {
+		t.Fatalf("expect the body sent on every try, got %q", bodies)
+	}
+	if tr.limiter.limiter.Limit() != throttledRate/2 {
+		t.Fatalf("expect the requests slowed, got %v", tr.limiter.limiter.Limit())
+	}
+	// 重试次数用完后返回限流的响应，每段使用新的限速器以免速度降得太低
+	tr.limiter = newAPILimiter(0)
+	bodies, statuses = nil, []int{429, 429, 429, 429, 429}
+	if status := do("https://api.notion.com/v1/pages", nil); status != http.StatusTooManyRequests || len(bodies) != throttleRetries+1 {
+		t.Fatalf("expect %d tries, got %d ending with %d", throttleRetries+1, len(bodies), status)
+	}
+	// 请求体无法重新获取时不重试
+	tr.limiter = newAPILimiter(0)
+	bodies, statuses = nil, []int{http.StatusTooManyRequests}
+	if status := do("https://api.notion.com/v1/pages", io.MultiReader(strings.NewReader("page"))); status != http.StatusTooManyRequests || len(bodies) != 1 {
+		t.Fatalf("expect no retry, got %d tries ending with %d", len(bodies), status)
+	}
+	// 其他地址的请求不限速也不重试
+	limit := tr.limiter.limiter.Limit()
+	bodies, statuses = nil, []int{http.StatusTooManyRequests}
+	if status := do("https://s3.example.com/bucket/key", nil); status != http.StatusTooManyRequests || len(bodies) != 1 {
+		t.Fatalf("expect the S3 request passed through, got %d tries ending with %d", len(bodies), status)
+	}
+	if tr.limiter.limiter.Limit() != limit {
+		t.Fatalf("expect the limit unchanged by S3, got %v", tr.limiter.limiter.Limit())
+	}
+}
+
+// TestAPIRateLimit 存储的两个客户端共享限速，Notion API限流后降低速度
+func TestAPIRateLimit(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.APIRateLimit = 100 })
+	if d.apiLimiter.limiter.Limit() != 100 || d.apiLimiter.limiter.Burst() != 100 {
+		t.Fatalf("expect 100 requests per second, got %v", d.apiLimiter.limiter.Limit())
+	}
+	fake.failNext(http.MethodPost, "/v1/pages", http.StatusTooManyRequests, 1)
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(10)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count(http.MethodPost, "/v1/pages"); n != 2 {
+		t.Fatalf("expect the throttled request retried once, got %d requests", n)
+	}
+	if d.apiLimiter.limiter.Limit() != throttledRate || d.apiLimiter.limiter.Burst() != 1 {
+		t.Fatalf("expect the requests slowed, got %v", d.apiLimiter.limiter.Limit())
+	}
+	if d.notionClient.api.Transport.(limitTransport).limiter != d.chunkClient.api.Transport.(limitTransport).limiter {
+		t.Fatal("expect the clients to share the limiter")
+	}
+}
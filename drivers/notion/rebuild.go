@@ -0,0 +1,293 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// RebuildStatus 从Notion重建元数据的进度，Error为中断重建的错误
+type RebuildStatus struct {
+	Running bool `json:"running"`
+	// Pages 读取到的带有元数据的页面数
+	Pages int `json:"pages"`
+	// Files 重建的文件数，Skipped为数据库中已存在的文件数
+	Files   int `json:"files"`
+	Skipped int `json:"skipped"`
+	// Incomplete 分块页面不全、无法重建的文件
+	Incomplete []string   `json:"incomplete"`
+	Error      string     `json:"error"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// rebuildState 重建任务的状态，由Other的请求和后台的重建共享
+type rebuildState struct {
+	mu     sync.Mutex
+	status RebuildStatus
+}
+
+// rebuildPage 带有元数据的页面
+type rebuildPage struct {
+	id   string
+	meta PageMeta
+}
+
+// rebuildGroup 同一文件同一次写入的全部页面
+type rebuildGroup struct {
+	meta  PageMeta
+	pages []rebuildPage
+}
+
+// startRebuild 在后台从页面属性重建元数据，需要开启MirrorMeta写入的属性；
+// 只补充数据库中缺少的文件，已存在的文件不变，保存在数据库中的小文件没有页面，无法重建
+func (d *Notion) startRebuild() (*RebuildStatus, error) {
+	d.rebuild.mu.Lock()
+	defer d.rebuild.mu.Unlock()
+	if d.rebuild.status.Running {
+		return nil, fmt.Errorf("重建正在进行中")
+	}
+	d.rebuild.status = RebuildStatus{Running: true, StartedAt: time.Now()}
+	status := d.rebuild.status
+	go func() {
+		err := d.rebuildMeta(context.Background())
+		if err != nil {
+			log.Warnf("从Notion重建元数据失败: %+v", err)
+		}
+		d.rebuild.mu.Lock()
+		defer d.rebuild.mu.Unlock()
+		now := time.Now()
+		d.rebuild.status.Running = false
+		d.rebuild.status.FinishedAt = &now
+		if err != nil {
+			d.rebuild.status.Error = err.Error()
+		}
+	}()
+	return &status, nil
+}
+
+func (d *Notion) rebuildStatus() *RebuildStatus {
+	d.rebuild.mu.Lock()
+	defer d.rebuild.mu.Unlock()
+	status := d.rebuild.status
+	return &status
+}
+
+func (d *Notion) updateRebuild(fn func(s *RebuildStatus)) {
+	d.rebuild.mu.Lock()
+	defer d.rebuild.mu.Unlock()
+	fn(&d.rebuild.status)
+}
+
+func (d *Notion) rebuildMeta(ctx context.Context) error {
+	groups, err := d.scanPageMeta(ctx)
+	if err != nil {
+		return err
+	}
+	// 同一路径保留最新的完整写入，旧版本和被覆盖的文件的页面被忽略
+	byPath := make(map[string][]*rebuildGroup)
+	for _, g := range groups {
+		byPath[g.meta.Path] = append(byPath[g.meta.Path], g)
+	}
+	paths := make([]string, 0, len(byPath))
+	for p := range byPath {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	root, err := d.tree.Root()
+	if err != nil {
+		return err
+	}
+	dirs := map[string]int{"/": root.ID}
+	for _, p := range paths {
+		candidates := byPath[p]
+		sort.Slice(candidates, func(i, j int) bool {
+			return candidates[i].meta.Modified.After(candidates[j].meta.Modified)
+		})
+		var g *rebuildGroup
+		for _, c := range candidates {
+			if c.complete() {
+				g = c
+				break
+			}
+		}
+		if g == nil {
+			d.updateRebuild(func(s *RebuildStatus) { s.Incomplete = append(s.Incomplete, p) })
+			continue
+		}
+		dirID, err := d.rebuildDir(dirs, path.Dir(p))
+		if err != nil {
+			return err
+		}
+		existing, err := d.tree.FindFile(dirID, path.Base(p))
+		if err != nil {
+			return err
+		}
+		if existing != nil {
+			d.updateRebuild(func(s *RebuildStatus) { s.Skipped++ })
+			continue
+		}
+		if err := d.rebuildFile(dirID, path.Base(p), g); err != nil {
+			return err
+		}
+		d.updateRebuild(func(s *RebuildStatus) { s.Files++ })
+	}
+	return nil
+}
+
+// scanPageMeta 读取数据库中全部带有元数据的页面，按文件和写入时间分组；
+// 配置了分块数据库时同时读取其中的分块页面
+func (d *Notion) scanPageMeta(ctx context.Context) ([]*rebuildGroup, error) {
+	index := make(map[string]*rebuildGroup)
+	var groups []*rebuildGroup
+	clients := []*NotionService{d.notionClient}
+	if d.chunkClient != d.notionClient {
+		clients = append(clients, d.chunkClient)
+	}
+	for _, client := range clients {
+		var err error
+		if groups, err = d.scanDatabaseMeta(ctx, client, index, groups); err != nil {
+			return nil, err
+		}
+	}
+	return groups, nil
+}
+
+func (d *Notion) scanDatabaseMeta(ctx context.Context, client *NotionService, index map[string]*rebuildGroup, groups []*rebuildGroup) ([]*rebuildGroup, error) {
+	filter := map[string]interface{}{
+		"property":  propMeta,
+		"rich_text": map[string]bool{"is_not_empty": true},
+	}
+	cursor := ""
+	for {
+		res, err := client.QueryDatabase(ctx, filter, cursor)
+		if err != nil {
+			return nil, err
+		}
+		for _, page := range res.Results {
+			var meta PageMeta
+			if err := utils.Json.UnmarshalFromString(page.Properties[propMeta].Text(), &meta); err != nil || meta.Path == "" {
+				log.Warnf("解析页面[%s]的元数据失败: %v", page.ID, err)
+				continue
+			}
+			key := fmt.Sprintf("%s\x00%d\x00%d", meta.Path, meta.Size, meta.Modified.UnixNano())
+			g, ok := index[key]
+			if !ok {
+				g = &rebuildGroup{meta: meta}
+				index[key] = g
+				groups = append(groups, g)
+			}
+			g.pages = append(g.pages, rebuildPage{id: page.ID, meta: meta})
+		}
+		d.updateRebuild(func(s *RebuildStatus) { s.Pages += len(res.Results) })
+		if !res.HasMore || res.NextCursor == "" {
+			return groups, nil
+		}
+		cursor = res.NextCursor
+	}
+}
+
+// complete 未分块的文件有一个页面，分块文件的分块从0开始连续覆盖整个文件
+func (g *rebuildGroup) complete() bool {
+	if g.meta.Chunk == nil {
+		return len(g.pages) == 1
+	}
+	chunks := g.chunks()
+	var offset int64
+	for i, p := range chunks {
+		if p.meta.Chunk == nil || p.meta.Chunk.Index != i || p.meta.Chunk.Start != offset {
+			return false
+		}
+		offset = p.meta.Chunk.End
+	}
+	return offset == g.meta.Size
+}
+
+// chunks 按序号排序的分块页面，同一分块有多个页面时保留一个
+func (g *rebuildGroup) chunks() []rebuildPage {
+	seen := make(map[int]bool)
+	var chunks []rebuildPage
+	for _, p := range g.pages {
+		if p.meta.Chunk == nil || seen[p.meta.Chunk.Index] {
+			continue
+		}
+		seen[p.meta.Chunk.Index] = true
+		chunks = append(chunks, p)
+	}
+	sort.Slice(chunks, func(i, j int) bool {
+		return chunks[i].meta.Chunk.Index < chunks[j].meta.Chunk.Index
+	})
+	return chunks
+}
+
+// rebuildDir 按路径逐级创建目录，dirs缓存已创建目录的ID
+func (d *Notion) rebuildDir(dirs map[string]int, dirPath string) (int, error) {
+	if id, ok := dirs[dirPath]; ok {
+		return id, nil
+	}
+	parentID, err := d.rebuildDir(dirs, path.Dir(dirPath))
+	if err != nil {
+		return 0, err
+	}
+	dir, err := d.tree.MakeDir(parentID, path.Base(dirPath))
+	if err != nil {
+		return 0, fmt.Errorf("创建目录[%s]失败: %v", dirPath, err)
+	}
+	dirs[dirPath] = dir.ID
+	return dir.ID, nil
+}
+
+func (d *Notion) rebuildFile(dirID int, name string, g *rebuildGroup) error {
+	meta := g.meta
+	f := File{
+		Name:        d.tree.NormName(name),
+		Size:        meta.Size,
+		SHA1:        meta.SHA1,
+		MD5:         meta.MD5,
+		SHA256:      meta.SHA256,
+		DirectoryID: dirID,
+		CreatedAt:   meta.Modified,
+		UpdatedAt:   meta.Modified,
+	}
+	if meta.Chunk == nil {
+		f.BlobKey = g.pages[0].id
+		if err := d.db.Create(&f).Error; err != nil {
+			return fmt.Errorf("保存文件[%s]失败: %v", meta.Path, err)
+		}
+		return nil
+	}
+	f.IsChunked = true
+	f.ChunkSize = meta.ChunkSize
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&f).Error; err != nil {
+			return fmt.Errorf("保存文件[%s]失败: %v", meta.Path, err)
+		}
+		var chunks []FileChunk
+		for _, p := range g.chunks() {
+			c := p.meta.Chunk
+			chunks = append(chunks, FileChunk{
+				FileID:      f.ID,
+				ChunkIndex:  c.Index,
+				ChunkSize:   c.End - c.Start,
+				StartOffset: c.Start,
+				EndOffset:   c.End,
+				BlobKey:     p.id,
+				SHA1:        c.SHA1,
+				Nonce:       c.Nonce,
+				KeyID:       c.KeyID,
+			})
+		}
+		if err := tx.Create(&chunks).Error; err != nil {
+			return fmt.Errorf("保存文件[%s]的分块记录失败: %v", meta.Path, err)
+		}
+		return nil
+	})
+}
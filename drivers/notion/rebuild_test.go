@@ -0,0 +1,121 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// waitRebuild 启动重建并等待完成
+func waitRebuild(t *testing.T, d *Notion) *RebuildStatus {
+	t.Helper()
+	callOther(t, d, "rebuild_meta", nil)
+	deadline := time.Now().Add(10 * time.Second)
+	for {
+		status := callOther(t, d, "rebuild_status", nil).(*RebuildStatus)
+		if !status.Running {
+			if status.Error != "" {
+				t.Fatalf("rebuild: %s", status.Error)
+			}
+			return status
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("rebuild timed out")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRebuildMeta(t *testing.T) {
+	fake := newFakeNotion(t)
+	small, big := testData(1000), testData(3*1024*1024)
+	configure := func(d *Notion) {
+		d.MirrorMeta = true
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	}
+	t.Run("write", func(t *testing.T) {
+		d := newTestNotion(t, fake, configure)
+		ctx := context.Background()
+		dir, err := d.MakeDir(ctx, rootDir(d), "docs")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Put(ctx, dir, newTestStream("a.bin", small), func(float64) {}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", big), func(float64) {}); err != nil {
+			t.Fatal(err)
+		}
+		broken, err := d.Put(ctx, rootDir(d), newTestStream("broken.bin", testData(3*1024*1024+1)), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 丢失一个分块页面的文件无法重建
+		var chunk FileChunk
+		if err := d.db.Where("file_id = ? AND chunk_index = 1", broken.GetID()).First(&chunk).Error; err != nil {
+			t.Fatal(err)
+		}
+		fake.mu.Lock()
+		fake.pages[chunk.BlobKey].archived = true
+		fake.mu.Unlock()
+	})
+
+	// 使用新的空数据库从页面属性重建
+	t.Run("rebuild", func(t *testing.T) {
+		d := newTestNotion(t, fake, configure)
+		status := waitRebuild(t, d)
+		if status.Files != 2 || status.Skipped != 0 {
+			t.Fatalf("expect 2 files rebuilt, got %+v", status)
+		}
+		if len(status.Incomplete) != 1 || status.Incomplete[0] != "/broken.bin" {
+			t.Fatalf("expect /broken.bin incomplete, got %v", status.Incomplete)
+		}
+		if names := listNames(t, d, rootDir(d)); len(names) != 2 {
+			t.Fatalf("expect docs and big.bin, got %v", names)
+		}
+
+		ctx := context.Background()
+		objs, err := d.List(ctx, rootDir(d), model.ListArgs{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, obj := range objs {
+			switch obj.GetName() {
+			case "docs":
+				sub, err := d.List(ctx, obj, model.ListArgs{})
+				if err != nil || len(sub) != 1 || sub[0].GetName() != "a.bin" {
+					t.Fatalf("expect docs/a.bin, got %v %v", sub, err)
+				}
+				link, err := d.Link(ctx, sub[0], model.LinkArgs{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := readURL(t, link); !bytes.Equal(got, small) {
+					t.Fatal("a.bin content mismatch")
+				}
+				if sha1 := sub[0].GetHash().GetHash(utils.SHA1); sha1 != sha1Hex(small) {
+					t.Fatalf("expect the sha1 of a.bin rebuilt, got %s", sha1)
+				}
+			case "big.bin":
+				link, err := d.Link(ctx, obj, model.LinkArgs{})
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got := readRange(t, link, 0, int64(len(big))); !bytes.Equal(got, big) {
+					t.Fatal("big.bin content mismatch")
+				}
+			}
+		}
+
+		// 已存在的文件不重复创建
+		status = waitRebuild(t, d)
+		if status.Files != 0 || status.Skipped != 2 {
+			t.Fatalf("expect the files skipped, got %+v", status)
+		}
+	})
+}
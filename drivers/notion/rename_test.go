@@ -0,0 +1,87 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+// pageTitles 返回页面ID对应的标题
+func (f *fakeNotion) pageTitles(ids ...string) []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	titles := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if page, ok := f.pages[id]; ok {
+			titles = append(titles, page.title)
+		}
+	}
+	return titles
+}
+
+func TestRenameSyncsPageTitle(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Rename(context.Background(), obj, "b.bin"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if titles := fake.pageTitles(f.BlobKey); len(titles) != 1 || titles[0] != "b.bin" {
+		t.Fatalf("expect the page titled b.bin, got %v", titles)
+	}
+}
+
+func TestRenameSyncsChunkTitles(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", testData(3*1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Rename(context.Background(), obj, "new.bin"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ?", obj.GetID()).Order("chunk_index").Find(&chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expect several chunks, got %d", len(chunks))
+	}
+	for _, c := range chunks {
+		want := fmt.Sprintf("new.bin.chunk%d", c.ChunkIndex)
+		if titles := fake.pageTitles(c.BlobKey); len(titles) != 1 || titles[0] != want {
+			t.Fatalf("expect chunk %d titled %s, got %v", c.ChunkIndex, want, titles)
+		}
+	}
+}
+
+func TestRenameKeepsObfuscatedTitle(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.ObfuscateNames = true })
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	before := fake.pageTitles(f.BlobKey)
+	if _, err := d.Rename(context.Background(), obj, "b.bin"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+	if after := fake.pageTitles(f.BlobKey); len(after) != 1 || after[0] != before[0] {
+		t.Fatalf("expect the obfuscated title kept, got %v then %v", before, after)
+	}
+}
@@ -0,0 +1,169 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/singleflight"
+	"github.com/disintegration/imaging"
+)
+
+const (
+	// resizeMaxSide 缩放后图片边长的上限
+	resizeMaxSide = 4096
+	// resizeQuality 未指定q时JPEG的质量
+	resizeQuality = 85
+	// resizeCacheTTL 缩放后的图片在缓存中保留的时间
+	resizeCacheTTL = time.Hour
+)
+
+var resizeG singleflight.Group[[]byte]
+
+// resizeFormats fmt参数可以指定的输出格式
+var resizeFormats = map[string]imaging.Format{
+	"jpeg": imaging.JPEG,
+	"png":  imaging.PNG,
+	"gif":  imaging.GIF,
+}
+
+// resizeOptions 代理图片时查询参数指定的缩放：w和h为最大宽高，只给出一个时按比例缩放，
+// fmt为输出格式，q为JPEG的质量
+type resizeOptions struct {
+	Width   int
+	Height  int
+	Format  string
+	Quality int
+}
+
+// parseResize 解析查询参数，没有缩放参数时返回false
+func parseResize(query url.Values) (resizeOptions, bool, error) {
+	opts := resizeOptions{Quality: resizeQuality}
+	if query.Get("w") == "" && query.Get("h") == "" && query.Get("fmt") == "" {
+		return opts, false, nil
+	}
+	for name, side := range map[string]*int{"w": &opts.Width, "h": &opts.Height} {
+		value := query.Get(name)
+		if value == "" {
+			continue
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n <= 0 || n > resizeMaxSide {
+			return opts, false, fmt.Errorf("无效的缩放参数%s=%s，应为1到%d", name, value, resizeMaxSide)
+		}
+		*side = n
+	}
+	if value := query.Get("q"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 100 {
+			return opts, false, fmt.Errorf("无效的缩放参数q=%s，应为1到100", value)
+		}
+		opts.Quality = n
+	}
+	opts.Format = strings.ToLower(query.Get("fmt"))
+	if opts.Format == "jpg" {
+		opts.Format = "jpeg"
+	}
+	if _, ok := resizeFormats[opts.Format]; opts.Format != "" && !ok {
+		return opts, false, fmt.Errorf("不支持的输出格式fmt=%s", opts.Format)
+	}
+	return opts, true, nil
+}
+
+func (o resizeOptions) key() string {
+	return fmt.Sprintf("%dx%d-%s-%d", o.Width, o.Height, o.Format, o.Quality)
+}
+
+// LinkVariant 缩略图和缩放后的图片与原文件的Link分开缓存和合并
+func (d *Notion) LinkVariant(args model.LinkArgs) string {
+	if args.Type == "thumb" {
+		return "thumb"
+	}
+	if d.resizeCache == nil || args.Redirect || args.HttpReq == nil {
+		return ""
+	}
+	if opts, ok, _ := parseResize(args.HttpReq.URL.Query()); ok {
+		return "resize-" + opts.key()
+	}
+	return ""
+}
+
+// resizeLink 开启image_resize时，按代理请求的查询参数返回缩放或转换格式后的图片，缩放结果缓存在内存中；
+// 不是图片、不需要缩放或图片无法缩放时返回nil
+func (d *Notion) resizeLink(ctx context.Context, f *File, args model.LinkArgs) (*model.Link, error) {
+	if d.resizeCache == nil || args.Redirect || args.HttpReq == nil || f.IsChunked || f.Size > thumbMaxImageSize {
+		return nil, nil
+	}
+	// 只处理可以解码的图片格式
+	format, err := imaging.FormatFromFilename(f.Name)
+	if err != nil {
+		return nil, nil
+	}
+	opts, ok, err := parseResize(args.HttpReq.URL.Query())
+	if err != nil || !ok {
+		return nil, err
+	}
+	if opts.Format != "" {
+		format = resizeFormats[opts.Format]
+	}
+	key := fmt.Sprintf("resize-%d-%d-%s", f.ID, f.UpdatedAt.UnixNano(), opts.key())
+	data, ok := d.resizeCache.get(key)
+	countCache("resize", ok)
+	if !ok {
+		data, err, _ = resizeG.Do(fmt.Sprintf("%d-%s", d.ID, key), func() ([]byte, error) {
+			data, err := d.resizeImage(ctx, f, opts, format)
+			if err != nil {
+				return nil, err
+			}
+			d.resizeCache.set(key, data)
+			return data, nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	link := bytesLink(data)
+	link.Header = http.Header{"Content-Type": []string{mime.TypeByExtension("." + strings.ToLower(format.String()))}}
+	return link, nil
+}
+
+// resizeImage 读取原图并缩放到opts指定的大小以内，不放大
+func (d *Notion) resizeImage(ctx context.Context, f *File, opts resizeOptions, format imaging.Format) ([]byte, error) {
+	rc, err := d.openImage(ctx, f)
+	if err != nil {
+		return nil, fmt.Errorf("读取图片失败: %w", err)
+	}
+	defer rc.Close()
+	img, err := imaging.Decode(rc, imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("解码图片失败: %w", err)
+	}
+	bounds := img.Bounds()
+	width, height := opts.Width, opts.Height
+	switch {
+	case width > 0 && height > 0:
+		if width < bounds.Dx() || height < bounds.Dy() {
+			img = imaging.Fit(img, width, height, imaging.Lanczos)
+		}
+	case width > 0:
+		if width < bounds.Dx() {
+			img = imaging.Resize(img, width, 0, imaging.Lanczos)
+		}
+	case height > 0:
+		if height < bounds.Dy() {
+			img = imaging.Resize(img, 0, height, imaging.Lanczos)
+		}
+	}
+	var buf bytes.Buffer
+	if err = imaging.Encode(&buf, img, format, imaging.JPEGQuality(opts.Quality)); err != nil {
+		return nil, fmt.Errorf("编码图片失败: %w", err)
+	}
+	return buf.Bytes(), nil
+}
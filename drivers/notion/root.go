@@ -0,0 +1,44 @@
+package notion
+
+import (
+	"fmt"
+	stdpath "path"
+	"strconv"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	log "github.com/sirupsen/logrus"
+)
+
+// initRoot 确定挂载为存储根目录的目录：配置了RootPath时按路径从目录树的根目录查找，缺少的目录会被创建；
+// 否则使用root_folder_id，为空时使用目录树的根目录。多个存储共用一个数据库时各自挂载不同的子目录
+func (d *Notion) initRoot() error {
+	root, err := d.tree.Root()
+	if err != nil {
+		return err
+	}
+	rootID := root.ID
+	if p := strings.Trim(stdpath.Clean("/"+d.RootPath), "/"); p != "" {
+		for _, name := range strings.Split(p, "/") {
+			dir, err := d.tree.MakeDir(rootID, d.tree.NormName(name))
+			if err != nil {
+				return fmt.Errorf("创建根目录[%s]失败: %w", d.RootPath, err)
+			}
+			rootID = dir.ID
+		}
+	} else if d.RootFolderID != "" {
+		dir, err := d.tree.GetDir(d.RootFolderID)
+		switch {
+		case err == nil && dir.Scope == d.NotionDatabaseID:
+			rootID = dir.ID
+		case d.RootFolderID == config.DefaultRoot:
+			// 默认的根目录ID不一定是当前数据库的根目录，使用数据库的根目录
+			log.Warnf("根目录%s不属于数据库%s，使用数据库的根目录%d", d.RootFolderID, d.NotionDatabaseID, root.ID)
+		default:
+			return fmt.Errorf("根目录%s不存在或不属于当前数据库: %w", d.RootFolderID, errs.ObjectNotFound)
+		}
+	}
+	d.RootFolderID = strconv.Itoa(rootID)
+	d.tree.Base = rootID
+	return nil
+}
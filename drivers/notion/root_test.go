@@ -0,0 +1,103 @@
+package notion
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// TestRootPath 共用一个数据库的存储各自挂载不同的子目录
+func TestRootPath(t *testing.T) {
+	fake := newFakeNotion(t)
+	// 同一测试中的存储使用同一个内存数据库
+	media := newTestNotion(t, fake, func(d *Notion) { d.RootPath = "/media/movies/" })
+	docs := newTestNotion(t, fake, func(d *Notion) { d.RootPath = "docs" })
+	ctx := context.Background()
+	for _, c := range []struct {
+		d    *Notion
+		name string
+	}{{media, "a.mp4"}, {docs, "b.txt"}} {
+		obj, err := c.d.Put(ctx, rootDir(c.d), newTestStream(c.name, testData(10)), func(float64) {})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := c.d.Other(ctx, model.OtherArgs{Obj: obj, Method: "set_tags", Data: TagReq{Tags: map[string]string{"k": "v"}}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if names := listNames(t, media, rootDir(media)); strings.Join(names, ",") != "a.mp4" {
+		t.Fatalf("expect only a.mp4 in the media root, got %v", names)
+	}
+	if names := listNames(t, docs, rootDir(docs)); strings.Join(names, ",") != "b.txt" {
+		t.Fatalf("expect only b.txt in the docs root, got %v", names)
+	}
+	// 按标签列出时只包含挂载的子目录中的文件，路径相对于挂载的根目录
+	res, err := media.Other(ctx, model.OtherArgs{Obj: rootDir(media), Method: "list_by_tag", Data: TagReq{Key: "k"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if files := res.([]TaggedFile); len(files) != 1 || files[0].Path != "/a.mp4" {
+		t.Fatalf("expect only /a.mp4 tagged, got %+v", files)
+	}
+
+	// 未配置时挂载整个目录树，已有的目录不重复创建
+	whole := newTestNotion(t, fake, nil)
+	names := listNames(t, whole, rootDir(whole))
+	sort.Strings(names)
+	if strings.Join(names, ",") != "docs,media" {
+		t.Fatalf("expect the subdirectories in the tree root, got %v", names)
+	}
+	again := newTestNotion(t, fake, func(d *Notion) { d.RootPath = "/media/movies" })
+	if again.RootFolderID != media.RootFolderID {
+		t.Fatalf("expect the same root %s, got %s", media.RootFolderID, again.RootFolderID)
+	}
+	// root_path优先于root_folder_id
+	over := newTestNotion(t, fake, func(d *Notion) {
+		d.RootFolderID = whole.RootFolderID
+		d.RootPath = "/docs"
+	})
+	if over.RootFolderID != docs.RootFolderID {
+		t.Fatalf("expect the root path used, got %s", over.RootFolderID)
+	}
+}
+
+func TestRootFolderID(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.RootPath = "/docs" })
+	sub := newTestNotion(t, fake, func(s *Notion) { s.RootFolderID = d.RootFolderID })
+	if sub.RootFolderID != d.RootFolderID {
+		t.Fatalf("expect the folder %s mounted, got %s", d.RootFolderID, sub.RootFolderID)
+	}
+
+	// initOther 初始化使用同一数据库中另一个Notion数据库的存储
+	initOther := func(rootID string) (*Notion, error) {
+		other := &Notion{Addition: d.Addition, dialector: d.dialector}
+		other.RootPath = ""
+		other.NotionDatabaseID = "other-database"
+		other.RootFolderID = rootID
+		err := other.Init(context.Background())
+		t.Cleanup(func() { _ = other.Drop(context.Background()) })
+		return other, err
+	}
+	// 其他数据库的目录和不存在的目录都不能挂载
+	for _, id := range []string{d.RootFolderID, "999"} {
+		if _, err := initOther(id); !errs.IsObjectNotFound(err) {
+			t.Fatalf("%s: expect the folder refused, got %v", id, err)
+		}
+	}
+	// 默认的根目录ID属于其他数据库时使用当前数据库的根目录
+	other, err := initOther(config.DefaultRoot)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.RootFolderID == config.DefaultRoot || other.RootFolderID == d.RootFolderID {
+		t.Fatalf("expect the root of the other database, got %s", other.RootFolderID)
+	}
+	if names := listNames(t, other, rootDir(other)); len(names) != 0 {
+		t.Fatalf("expect an empty root, got %v", names)
+	}
+}
@@ -0,0 +1,150 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/drivers/base"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+// errScanRejected 扫描程序拒绝了文件，区别于扫描程序本身的错误
+var errScanRejected = errors.New("rejected by the upload scanner")
+
+// scanReasonLimit 拒绝原因最多保留的字节数
+const scanReasonLimit = 1024
+
+// scanEnabled 是否配置了上传前的扫描
+func (d *Notion) scanEnabled() bool {
+	return d.ScanCommand != "" || d.ScanURL != ""
+}
+
+// scanUpload 在上传到Notion前将文件缓存到临时文件并交给扫描命令和扫描地址检查；
+// 被拒绝时按ScanReject处理，扫描程序出错时按ScanOnError处理
+func (d *Notion) scanUpload(ctx context.Context, file model.FileStreamer) error {
+	if !d.scanEnabled() {
+		return nil
+	}
+	tmp, err := file.CacheFullInTempFile()
+	if err != nil {
+		return fmt.Errorf("缓存文件失败: %w", err)
+	}
+	if d.ScanTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(d.ScanTimeout)*time.Second)
+		defer cancel()
+	}
+	name := filepath.Base(file.GetName())
+	err = d.scan(ctx, tmp, name, file.GetSize())
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, errScanRejected):
+		if d.ScanReject == "log" {
+			log.Warnf("文件[%s]未通过扫描，仅记录日志: %v", name, err)
+			return nil
+		}
+		return fmt.Errorf("文件[%s]未通过扫描: %w: %w", name, err, errs.PermissionDenied)
+	case d.ScanOnError == "allow":
+		log.Warnf("扫描文件[%s]失败，继续上传: %v", name, err)
+		return nil
+	default:
+		return fmt.Errorf("扫描文件[%s]失败: %w", name, err)
+	}
+}
+
+func (d *Notion) scan(ctx context.Context, tmp model.File, name string, size int64) error {
+	if d.ScanCommand != "" {
+		if err := d.scanCommand(ctx, tmp, size); err != nil {
+			return err
+		}
+	}
+	if d.ScanURL != "" {
+		return d.scanURL(ctx, io.NewSectionReader(tmp, 0, size), name, size)
+	}
+	return nil
+}
+
+// scanCommand 执行扫描命令，命令中的{path}替换为临时文件的路径，没有{path}时路径作为最后一个参数；
+// 退出码0为通过，1为拒绝，与clamscan相同，其他为扫描程序的错误
+func (d *Notion) scanCommand(ctx context.Context, tmp model.File, size int64) error {
+	f, ok := tmp.(*os.File)
+	if !ok {
+		var err error
+		if f, err = utils.CreateTempFile(io.NewSectionReader(tmp, 0, size), size); err != nil {
+			return fmt.Errorf("缓存文件失败: %w", err)
+		}
+		defer utils.RemoveTempFile(f)
+	}
+	args := strings.Fields(d.ScanCommand)
+	hasPath := false
+	for i, arg := range args {
+		if strings.Contains(arg, "{path}") {
+			args[i] = strings.ReplaceAll(arg, "{path}", f.Name())
+			hasPath = true
+		}
+	}
+	if !hasPath {
+		args = append(args, f.Name())
+	}
+	var out bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdout = &out
+	cmd.Stderr = &out
+	err := cmd.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) && exitErr.ExitCode() == 1 {
+		return fmt.Errorf("%w: %s", errScanRejected, scanReason(out.Bytes()))
+	}
+	if err != nil {
+		return fmt.Errorf("执行扫描命令失败: %w: %s", err, scanReason(out.Bytes()))
+	}
+	return nil
+}
+
+// scanURL 将文件内容POST到扫描地址，2xx为通过，4xx为拒绝，响应体为原因，其他为扫描程序的错误
+func (d *Notion) scanURL(ctx context.Context, r io.Reader, name string, size int64) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.ScanURL, r)
+	if err != nil {
+		return fmt.Errorf("创建扫描请求失败: %w", err)
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("X-File-Name", name)
+	req.Header.Set("X-File-Size", strconv.FormatInt(size, 10))
+	resp, err := base.HttpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("发送扫描请求失败: %w", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, scanReasonLimit))
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode >= 400 && resp.StatusCode < 500:
+		return fmt.Errorf("%w: %s", errScanRejected, scanReason(body))
+	default:
+		return fmt.Errorf("扫描地址返回%s: %s", resp.Status, scanReason(body))
+	}
+}
+
+// scanReason 扫描程序输出的原因，截断过长的内容
+func scanReason(out []byte) string {
+	if len(out) > scanReasonLimit {
+		out = out[:scanReasonLimit]
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,152 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/drivers/base"
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/errs"
+)
+
+// newScanServer 拒绝内容中含有EVIL的文件，内容含有FAIL时返回500
+func newScanServer(t *testing.T) (*httptest.Server, *[]string) {
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	base.InitClient()
+	var names []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		names = append(names, r.Header.Get("X-File-Name")+":"+r.Header.Get("X-File-Size"))
+		switch {
+		case strings.Contains(string(data), "FAIL"):
+			http.Error(w, "scanner down", http.StatusInternalServerError)
+		case strings.Contains(string(data), "EVIL"):
+			http.Error(w, "Eicar-Test-Signature FOUND", http.StatusUnprocessableEntity)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &names
+}
+
+// useTempDir 扫描前缓存上传的临时文件放在测试的临时目录中
+func useTempDir(t *testing.T) {
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	tempDir := conf.Conf.TempDir
+	conf.Conf.TempDir = t.TempDir()
+	t.Cleanup(func() { conf.Conf.TempDir = tempDir })
+}
+
+func TestScanURL(t *testing.T) {
+	srv, names := newScanServer(t)
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.ScanURL = srv.URL })
+	ctx := context.Background()
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.txt", []byte("clean")), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(*names, ",") != "a.txt:5" {
+		t.Fatalf("expect the name and size sent, got %v", *names)
+	}
+	_, err := d.Put(ctx, rootDir(d), newTestStream("b.txt", []byte("EVIL")), func(float64) {})
+	if !errors.Is(err, errs.PermissionDenied) || !errors.Is(err, errScanRejected) || !strings.Contains(err.Error(), "Eicar-Test-Signature") {
+		t.Fatalf("expect b.txt rejected with the reason, got %v", err)
+	}
+	// 扫描程序出错时默认拒绝上传，但不是被扫描拒绝
+	_, err = d.Put(ctx, rootDir(d), newTestStream("c.txt", []byte("FAIL")), func(float64) {})
+	if err == nil || errors.Is(err, errScanRejected) || errors.Is(err, errs.PermissionDenied) {
+		t.Fatalf("expect the scanner error returned, got %v", err)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 {
+		t.Fatalf("expect only a.txt uploaded, got %v", names)
+	}
+	if n := fake.count(http.MethodPost, "/v1/pages"); n != 1 {
+		t.Fatalf("expect a page only for a.txt, got %d", n)
+	}
+
+	// 只记录日志，扫描程序出错时继续上传
+	d.ScanReject = "log"
+	d.ScanOnError = "allow"
+	for _, name := range []string{"b.txt", "c.txt"} {
+		data := "EVIL"
+		if name == "c.txt" {
+			data = "FAIL"
+		}
+		if _, err := d.Put(ctx, rootDir(d), newTestStream(name, []byte(data)), func(float64) {}); err != nil {
+			t.Fatalf("%s: expect the upload allowed, got %v", name, err)
+		}
+	}
+}
+
+func TestScanCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the scanner is a shell script")
+	}
+	useTempDir(t)
+	script := filepath.Join(t.TempDir(), "scan.sh")
+	// 与clamscan相同，退出码1为拒绝
+	if err := os.WriteFile(script, []byte("#!/bin/sh\ngrep -q EVIL \"$2\" && { echo \"$2: EVIL FOUND\"; exit 1; }\ngrep -q FAIL \"$2\" && exit 2\nexit 0\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.ScanCommand = script + " --file {path}" })
+	ctx := context.Background()
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("a.txt", []byte("clean")), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	_, err := d.Put(ctx, rootDir(d), newTestStream("b.txt", []byte("EVIL")), func(float64) {})
+	if !errors.Is(err, errScanRejected) || !strings.Contains(err.Error(), "EVIL FOUND") {
+		t.Fatalf("expect b.txt rejected with the output, got %v", err)
+	}
+	_, err = d.Put(ctx, rootDir(d), newTestStream("c.txt", []byte("FAIL")), func(float64) {})
+	if err == nil || errors.Is(err, errScanRejected) {
+		t.Fatalf("expect the scanner error returned, got %v", err)
+	}
+	// 追加的内容也要扫描
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("d.txt", []byte("clean")), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Append(ctx, obj, newTestStream("d.txt", []byte("EVIL")), func(float64) {}); !errors.Is(err, errScanRejected) {
+		t.Fatalf("expect the appended content rejected, got %v", err)
+	}
+}
+
+func TestScanTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the scanner is a shell script")
+	}
+	useTempDir(t)
+	script := filepath.Join(t.TempDir(), "scan.sh")
+	if err := os.WriteFile(script, []byte("#!/bin/sh\nexec sleep 10\n"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) {
+		d.ScanCommand = script
+		d.ScanTimeout = 1
+	})
+	_, err := d.Put(context.Background(), rootDir(d), newTestStream("a.txt", []byte("clean")), func(float64) {})
+	if err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Fatalf("expect the scan timed out, got %v", err)
+	}
+}
+
+func TestScanReason(t *testing.T) {
+	long := strings.Repeat("x", scanReasonLimit+10)
+	if got := scanReason([]byte(" " + long)); len(got) != scanReasonLimit-1 {
+		t.Fatalf("expect the reason truncated, got %d bytes", len(got))
+	}
+	if got := scanReason([]byte(" found \n")); got != "found" {
+		t.Fatalf("expect the reason trimmed, got %q", got)
+	}
+}
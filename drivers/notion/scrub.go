@@ -0,0 +1,216 @@
+package notion
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+const (
+	// scrubRangeSize 校验时每次范围读取的大小，连接中断只需重新读取一段
+	scrubRangeSize = 256 * 1024 * 1024
+	// scrubMaxFailures 状态中保留的最近校验失败数
+	scrubMaxFailures = 100
+	// scrubRetryDelay 读取失败的页面推迟到该时间之后再校验，避免一直重试同一个页面
+	scrubRetryDelay = 24 * time.Hour
+)
+
+// ScrubFailure 一次校验失败，Index为分块序号，未分块文件为-1
+type ScrubFailure struct {
+	Path  string    `json:"path"`
+	Index int       `json:"index"`
+	Error string    `json:"error"`
+	Time  time.Time `json:"time"`
+}
+
+// ScrubStatus 后台校验的统计，只保存在内存中
+type ScrubStatus struct {
+	Checked   int            `json:"checked"`
+	Corrupted int            `json:"corrupted"`
+	LastCheck *time.Time     `json:"last_check"`
+	Failures  []ScrubFailure `json:"failures"`
+}
+
+// scrubState 后台校验的状态，由定时任务和Other的请求共享
+type scrubState struct {
+	mu     sync.Mutex
+	status ScrubStatus
+	// deferred 读取失败的页面及其下次校验的时间
+	deferred map[string]time.Time
+}
+
+// scrubTarget 一次校验的对象，chunk为nil时校验未分块文件的页面
+type scrubTarget struct {
+	file  File
+	chunk *FileChunk
+}
+
+// scrubOne 校验最久未检查的一个分块或未分块文件：读取页面的附件计算SHA1并与记录比对，
+// 一致时记录检查时间，不一致时记录错误并发送scrub_failed事件；读取失败和不一致的页面一天后再校验
+func (d *Notion) scrubOne() {
+	target, err := d.nextScrubTarget()
+	if err != nil {
+		log.Warnf("获取待校验的页面失败: %+v", err)
+		return
+	}
+	if target == nil {
+		return
+	}
+	pageID, attachment, size, sum, index := target.file.BlobKey, target.file.BlobIndex, target.file.Size, target.file.SHA1, -1
+	if c := target.chunk; c != nil {
+		pageID, attachment, size, sum, index = c.BlobKey, 0, c.ChunkSize, c.SHA1, c.ChunkIndex
+		if c.Nonce != "" {
+			size = chunkstore.EncryptedSize(size)
+		}
+	}
+	ctx := context.Background()
+	filePath := path.Join(d.tree.DirPath(target.file.DirectoryID), target.file.Name)
+	actual, err := d.hashPage(ctx, pageID, attachment, size)
+	now := time.Now()
+	if err != nil {
+		log.Warnf("校验文件[%s]的页面[%s]失败: %+v", filePath, pageID, err)
+		d.scrubFailed(ScrubFailure{Path: filePath, Index: index, Error: err.Error(), Time: now}, pageID, false)
+		return
+	}
+	if !strings.EqualFold(actual, sum) {
+		err = fmt.Errorf("页面[%s]的SHA1为%s，记录为%s", pageID, actual, sum)
+		log.Errorf("文件[%s]已损坏: %v", filePath, err)
+		d.scrubFailed(ScrubFailure{Path: filePath, Index: index, Error: err.Error(), Time: now}, pageID, true)
+		d.notify(ctx, EventScrubFailed, target.file.DirectoryID, target.file.Name, target.file.Size, err)
+		return
+	}
+	d.scrub.mu.Lock()
+	d.scrub.status.Checked++
+	d.scrub.status.LastCheck = &now
+	d.scrub.mu.Unlock()
+	if target.chunk != nil {
+		err = d.db.Model(target.chunk).Update("verified_at", now).Error
+	} else {
+		err = d.db.Model(&target.file).Update("verified_at", now).Error
+	}
+	if err != nil {
+		log.Warnf("保存文件[%s]的检查时间失败: %v", filePath, err)
+	}
+}
+
+// scrubFailed 记录校验失败，推迟该页面的下次校验
+func (d *Notion) scrubFailed(failure ScrubFailure, pageID string, corrupted bool) {
+	d.scrub.mu.Lock()
+	defer d.scrub.mu.Unlock()
+	s := &d.scrub.status
+	s.LastCheck = &failure.Time
+	if corrupted {
+		s.Checked++
+		s.Corrupted++
+	}
+	s.Failures = append(s.Failures, failure)
+	if n := len(s.Failures); n > scrubMaxFailures {
+		s.Failures = s.Failures[n-scrubMaxFailures:]
+	}
+	if d.scrub.deferred == nil {
+		d.scrub.deferred = make(map[string]time.Time)
+	}
+	d.scrub.deferred[pageID] = failure.Time.Add(scrubRetryDelay)
+}
+
+// deferredPages 暂不校验的页面，过期的记录被清除
+func (d *Notion) deferredPages() []string {
+	d.scrub.mu.Lock()
+	defer d.scrub.mu.Unlock()
+	pages := []string{""}
+	now := time.Now()
+	for pageID, until := range d.scrub.deferred {
+		if now.After(until) {
+			delete(d.scrub.deferred, pageID)
+			continue
+		}
+		pages = append(pages, pageID)
+	}
+	return pages
+}
+
+// nextScrubTarget 在分块和未分块文件中选出检查时间最早的一个，从未检查过的优先
+func (d *Notion) nextScrubTarget() (*scrubTarget, error) {
+	deferred := d.deferredPages()
+	var chunk FileChunk
+	chunkErr := d.db.Where("deleted = ? AND sha1 <> '' AND notion_page_id NOT IN ? AND file_id IN (?)", false, deferred,
+		d.tree.FileIDs(d.db).Where("deleted = ?", false)).Order("verified_at").First(&chunk).Error
+	if chunkErr != nil && !errors.Is(chunkErr, gorm.ErrRecordNotFound) {
+		return nil, chunkErr
+	}
+	var file File
+	fileErr := d.db.Where("deleted = ? AND is_chunked = ? AND sha1 <> '' AND notion_page_id NOT IN ? AND directory_id IN (?)",
+		false, false, deferred, d.tree.DirIDs(d.db)).Order("verified_at").First(&file).Error
+	if fileErr != nil && !errors.Is(fileErr, gorm.ErrRecordNotFound) {
+		return nil, fileErr
+	}
+	switch {
+	case chunkErr != nil && fileErr != nil:
+		return nil, nil
+	case fileErr != nil || (chunkErr == nil && olderThan(chunk.VerifiedAt, file.VerifiedAt)):
+		if err := d.db.Where("id = ?", chunk.FileID).First(&file).Error; err != nil {
+			return nil, err
+		}
+		return &scrubTarget{file: file, chunk: &chunk}, nil
+	default:
+		return &scrubTarget{file: file}, nil
+	}
+}
+
+// olderThan 检查时间a是否早于b，nil表示从未检查过
+func olderThan(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == nil
+	}
+	return a.Before(*b)
+}
+
+// hashPage 分段读取页面第attachment个附件的原始数据并计算SHA1，加密的分块计算的是密文的SHA1；
+// 每段重新获取下载地址，限速时读取整个分块的时间可能超过地址的有效期；配置了下载限速时与下载共享限速
+func (d *Notion) hashPage(ctx context.Context, pageID string, attachment int, size int64) (string, error) {
+	hash := sha1.New()
+	for offset := int64(0); offset < size; offset += scrubRangeSize {
+		length := min(scrubRangeSize, size-offset)
+		fileURL, err := d.notionClient.PageFileURL(pageID, attachment)
+		if err != nil {
+			return "", fmt.Errorf("获取文件URL失败: %w", err)
+		}
+		rc, err := chunkstore.RangeGet(ctx, fileURL, offset, length)
+		if err != nil {
+			return "", err
+		}
+		var r io.Reader = rc
+		if d.downloadLimit != nil {
+			r = &driver.RateLimitReader{Reader: rc, Limiter: d.downloadLimit, Ctx: ctx}
+		}
+		n, err := io.Copy(hash, r)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		if n != length {
+			return "", fmt.Errorf("读取了%d字节，应为%d字节", n, length)
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func (d *Notion) scrubStatus() *ScrubStatus {
+	d.scrub.mu.Lock()
+	defer d.scrub.mu.Unlock()
+	status := d.scrub.status
+	status.Failures = append([]ScrubFailure{}, status.Failures...)
+	return &status
+}
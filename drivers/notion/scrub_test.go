@@ -0,0 +1,135 @@
+package notion
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// corruptPage 修改页面所有附件的S3对象内容，模拟存储中的数据损坏
+func (f *fakeNotion) corruptPage(id string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, file := range f.pages[id].files {
+		_, key, _ := strings.Cut(file.File.URL, "/s3/")
+		if data := f.objects[key]; len(data) > 0 {
+			data[0]++
+		}
+	}
+}
+
+func TestScrub(t *testing.T) {
+	url, bodies := newWebhookServer(t)
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.WebhookURL = url
+		d.WebhookEvents = EventScrubFailed
+		d.WebhookTemplate = defaultAddition(t, "WebhookTemplate")
+	})
+	ctx := context.Background()
+	objA, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	objB, err := d.Put(ctx, rootDir(d), newTestStream("b.bin", testData(2000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verified := func(obj model.Obj) bool {
+		var f File
+		if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+			t.Fatal(err)
+		}
+		return f.VerifiedAt != nil
+	}
+
+	// 从未检查过的文件依次校验
+	d.scrubOne()
+	d.scrubOne()
+	if s := d.scrubStatus(); s.Checked != 2 || s.Corrupted != 0 || s.LastCheck == nil || len(s.Failures) != 0 {
+		t.Fatalf("expect 2 files checked, got %+v", s)
+	}
+	if !verified(objA) || !verified(objB) {
+		t.Fatal("expect both files marked verified")
+	}
+
+	// 损坏的页面计入统计并发送事件，之后推迟校验
+	var a File
+	if err := d.db.First(&a, objA.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	fake.corruptPage(a.BlobKey)
+	d.scrubOne()
+	s := d.scrubStatus()
+	if s.Checked != 3 || s.Corrupted != 1 || len(s.Failures) != 1 {
+		t.Fatalf("expect a.bin found corrupted, got %+v", s)
+	}
+	if f := s.Failures[0]; f.Path != "/a.bin" || f.Index != -1 || !strings.Contains(f.Error, a.BlobKey) {
+		t.Fatalf("expect the failure of /a.bin, got %+v", f)
+	}
+	if got := nextWebhook(t, bodies); got.Event != EventScrubFailed || got.Path != "/notion/a.bin" || got.Error == "" {
+		t.Fatalf("expect a scrub_failed webhook for a.bin, got %+v", got)
+	}
+	requests := fake.count(http.MethodGet, "/s3/")
+	d.scrubOne()
+	if s := d.scrubStatus(); s.Checked != 4 || s.Corrupted != 1 {
+		t.Fatalf("expect b.bin checked while a.bin deferred, got %+v", s)
+	}
+	if n := fake.count(http.MethodGet, "/s3/") - requests; n != 1 {
+		t.Fatalf("expect one page read, got %d", n)
+	}
+
+	// 读取失败不算作损坏
+	fake.failNext(http.MethodGet, "/s3/", http.StatusInternalServerError, 1)
+	d.scrubOne()
+	s = callOther(t, d, "scrub_status", nil).(*ScrubStatus)
+	if s.Checked != 4 || s.Corrupted != 1 || len(s.Failures) != 2 || s.Failures[1].Path != "/b.bin" {
+		t.Fatalf("expect a read failure of b.bin recorded, got %+v", s)
+	}
+	// 两个页面都推迟后没有可校验的页面
+	requests = fake.count(http.MethodGet, "/s3/")
+	d.scrubOne()
+	if n := fake.count(http.MethodGet, "/s3/") - requests; n != 0 {
+		t.Fatalf("expect no page read, got %d", n)
+	}
+}
+
+func TestScrubChunks(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", testData(3<<20)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ?", obj.GetID()).Find(&chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) < 2 {
+		t.Fatalf("expect the file chunked, got %d chunks", len(chunks))
+	}
+	fake.corruptPage(chunks[1].BlobKey)
+	for range chunks {
+		d.scrubOne()
+	}
+	s := d.scrubStatus()
+	if s.Checked != len(chunks) || s.Corrupted != 1 || len(s.Failures) != 1 {
+		t.Fatalf("expect %d chunks checked and one corrupted, got %+v", len(chunks), s)
+	}
+	if f := s.Failures[0]; f.Path != "/big.bin" || f.Index != chunks[1].ChunkIndex {
+		t.Fatalf("expect chunk %d of /big.bin failed, got %+v", chunks[1].ChunkIndex, f)
+	}
+	var unverified int64
+	if err := d.db.Model(&FileChunk{}).Where("file_id = ? AND verified_at IS NULL", obj.GetID()).Count(&unverified).Error; err != nil {
+		t.Fatal(err)
+	}
+	if unverified != 1 {
+		t.Fatalf("expect only the corrupted chunk unverified, got %d", unverified)
+	}
+}
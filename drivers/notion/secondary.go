@@ -0,0 +1,171 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// initSecondary 创建备用数据库的客户端，未填写的认证信息使用主数据库的
+func (d *Notion) initSecondary() error {
+	d.secondaryClient = nil
+	if d.SecondaryDatabaseID == "" {
+		return nil
+	}
+	client := NewNotionService(
+		utils.GetNoneEmpty(d.secret.secondaryCookie, d.secret.notionCookie),
+		utils.GetNoneEmpty(d.secret.secondaryToken, d.secret.notionToken),
+		utils.GetNoneEmpty(d.SecondarySpaceID, d.NotionSpaceID),
+		d.SecondaryDatabaseID,
+		utils.GetNoneEmpty(d.SecondaryFilePageID, d.NotionFilePageID))
+	if client == nil {
+		return fmt.Errorf("初始化备用数据库的客户端失败，无法从cookie中获取用户ID")
+	}
+	client.lang = d.lang()
+	client.uploadLimit = d.notionClient.uploadLimit
+	client.setBaseURLs(d.APIBaseURL, d.PublicAPIBaseURL, d.S3BaseURL)
+	// 使用其他令牌时Notion单独限流
+	if client.token == d.secret.notionToken {
+		client.useAPILimiter(d.apiLimiter)
+	} else {
+		client.useAPILimiter(newAPILimiter(d.APIRateLimit))
+	}
+	client.uploadThreads = d.UploadThreads
+	d.secondaryClient = client
+	return nil
+}
+
+// writeSecondary 将已上传到页面pageID的size字节数据同样上传到备用数据库的新页面并记录对应关系；
+// 镜像是尽力而为的，失败只记录日志，不影响主数据库中的上传
+func (d *Notion) writeSecondary(ctx context.Context, pageID, title, mimetype string, r io.ReaderAt, size int64) {
+	if d.secondaryClient == nil {
+		return
+	}
+	if err := d.uploadSecondary(ctx, pageID, title, mimetype, r, size); err != nil {
+		log.Warnf("镜像页面[%s]到备用数据库失败: %+v", pageID, err)
+	}
+}
+
+func (d *Notion) uploadSecondary(ctx context.Context, pageID, title, mimetype string, r io.ReaderAt, size int64) error {
+	secondaryID, err := d.secondaryClient.CreateDatabasePage(title)
+	if err != nil {
+		return fmt.Errorf("创建页面失败: %w", err)
+	}
+	stream := &ChunkFileStream{
+		Reader:   io.NewSectionReader(r, 0, size),
+		name:     title,
+		size:     size,
+		mimetype: mimetype,
+	}
+	if _, err := d.secondaryClient.UploadAndUpdateFilePut(ctx, stream, secondaryID, func(float64) {}); err != nil {
+		return fmt.Errorf("上传文件失败: %w", err)
+	}
+	// 页面重新上传时替换旧的镜像
+	old, err := d.secondaryPage(pageID)
+	if err != nil {
+		return err
+	}
+	if old != "" {
+		if err := d.secondaryClient.ArchivePage(old); err != nil {
+			log.Warnf("归档备用数据库的页面[%s]失败: %+v", old, err)
+		}
+		if err := d.db.Where("page_id = ?", pageID).Delete(&SecondaryPage{}).Error; err != nil {
+			return fmt.Errorf("删除镜像记录失败: %w", err)
+		}
+	}
+	if err := d.db.Create(&SecondaryPage{PageID: pageID, SecondaryPageID: secondaryID}).Error; err != nil {
+		return fmt.Errorf("保存镜像记录失败: %w", err)
+	}
+	return nil
+}
+
+// secondaryPage 页面在备用数据库中的镜像，没有镜像时返回空字符串
+func (d *Notion) secondaryPage(pageID string) (string, error) {
+	var page SecondaryPage
+	err := d.db.Where("page_id = ?", pageID).First(&page).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("查询镜像记录失败: %w", err)
+	}
+	return page.SecondaryPageID, nil
+}
+
+// secondaryURL 页面镜像的下载地址，镜像页面只有一个附件
+func (d *Notion) secondaryURL(pageID string) (string, error) {
+	if d.secondaryClient == nil {
+		return "", fmt.Errorf("未配置备用数据库")
+	}
+	secondaryID, err := d.secondaryPage(pageID)
+	if err != nil {
+		return "", err
+	}
+	if secondaryID == "" {
+		return "", fmt.Errorf("页面[%s]没有镜像", pageID)
+	}
+	return d.secondaryClient.PageFileURL(secondaryID, 0)
+}
+
+// pageFileURL 获取页面第index个附件的下载地址，主数据库的页面读取失败时改用镜像，
+// 返回地址所属的客户端，下载file.notion.so的地址需要对应工作区的cookie
+func (d *Notion) pageFileURL(pageID string, index int) (string, *NotionService, error) {
+	fileURL, err := d.notionClient.PageFileURL(pageID, index)
+	if err == nil || d.secondaryClient == nil || index != 0 {
+		return fileURL, d.notionClient, err
+	}
+	secondaryURL, serr := d.secondaryURL(pageID)
+	if serr != nil {
+		log.Debugf("页面[%s]无法从备用数据库读取: %v", pageID, serr)
+		return "", nil, err
+	}
+	log.Warnf("读取页面[%s]失败，改用备用数据库: %v", pageID, err)
+	return secondaryURL, d.secondaryClient, nil
+}
+
+// openPageAttachment 打开页面中的第index个附件，主数据库的页面或下载失败时从镜像读取
+func (d *Notion) openPageAttachment(ctx context.Context, pageID string, index int, offset, length int64) (io.ReadCloser, error) {
+	rc, err := d.notionClient.OpenPageAttachment(ctx, pageID, index, offset, length)
+	if err == nil || d.secondaryClient == nil || index != 0 {
+		return rc, err
+	}
+	secondaryID, serr := d.secondaryPage(pageID)
+	if serr != nil || secondaryID == "" {
+		return nil, err
+	}
+	log.Warnf("读取页面[%s]失败，改用备用数据库: %v", pageID, err)
+	return d.secondaryClient.OpenPageAttachment(ctx, secondaryID, 0, offset, length)
+}
+
+// openSecondaryChunk 从镜像读取分块，主数据库的分块页面或下载失败时使用
+func (d *Notion) openSecondaryChunk(ctx context.Context, chunk chunkstore.Chunk, offset, length int64) (io.ReadCloser, error) {
+	fileURL, err := d.secondaryURL(chunk.Key)
+	if err != nil {
+		return nil, err
+	}
+	return chunkstore.RangeGet(ctx, fileURL, offset, length)
+}
+
+// archiveSecondary 归档页面在备用数据库中的镜像并删除记录
+func (d *Notion) archiveSecondary(pageID string) {
+	if d.secondaryClient == nil {
+		return
+	}
+	secondaryID, err := d.secondaryPage(pageID)
+	if err != nil || secondaryID == "" {
+		return
+	}
+	if err := d.secondaryClient.ArchivePage(secondaryID); err != nil {
+		log.Warnf("归档备用数据库的页面[%s]失败: %+v", secondaryID, err)
+		return
+	}
+	if err := d.db.Where("page_id = ?", pageID).Delete(&SecondaryPage{}).Error; err != nil {
+		log.Warnf("删除页面[%s]的镜像记录失败: %v", pageID, err)
+	}
+}
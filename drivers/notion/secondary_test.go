@@ -0,0 +1,119 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+const secondaryDatabaseID = "secondary-database"
+
+// databasePages 返回父数据库为database的未归档页面数
+func (f *fakeNotion) databasePages(database string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	n := 0
+	for _, p := range f.pages {
+		if p.database == database && !p.archived {
+			n++
+		}
+	}
+	return n
+}
+
+func newSecondaryNotion(t *testing.T, fake *fakeNotion, configure func(d *Notion)) *Notion {
+	return newTestNotion(t, fake, func(d *Notion) {
+		d.SecondaryDatabaseID = secondaryDatabaseID
+		d.ArchiveOnDelete = true
+		if configure != nil {
+			configure(d)
+		}
+	})
+}
+
+func TestSecondary(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newSecondaryNotion(t, fake, nil)
+	ctx := context.Background()
+	data := testData(1000)
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pageID := blobKey(t, d, obj.GetID())
+	if fake.databasePages(fakeDatabaseID) != 1 || fake.databasePages(secondaryDatabaseID) != 1 {
+		t.Fatalf("expect a page in each database, got %d and %d", fake.databasePages(fakeDatabaseID), fake.databasePages(secondaryDatabaseID))
+	}
+	mirror, err := d.secondaryPage(pageID)
+	if err != nil || mirror == "" {
+		t.Fatalf("expect the mirror recorded, got %q %v", mirror, err)
+	}
+
+	// 主数据库的页面读取失败时使用镜像
+	fake.failNext(http.MethodGet, "/v1/pages/"+pageID, http.StatusNotFound, 1)
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatalf("expect the link of the mirror, got %v", err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content of the mirror differs")
+	}
+
+	// 删除文件时镜像一并归档
+	if err := d.Remove(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	if n := waitLivePages(fake, 0); n != 0 {
+		t.Fatalf("expect both pages archived, got %d live pages", n)
+	}
+	if mirror, _ := d.secondaryPage(pageID); mirror != "" {
+		t.Fatalf("expect the mirror record deleted, got %s", mirror)
+	}
+}
+
+func TestSecondaryChunks(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newSecondaryNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	data := testData(3 * 1024 * 1024)
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := callOther(t, d, "list_chunks", obj).([]ChunkInfo)
+	if n := fake.databasePages(secondaryDatabaseID); n != len(chunks) {
+		t.Fatalf("expect every chunk mirrored, got %d pages for %d chunks", n, len(chunks))
+	}
+	// 分块内容丢失时从镜像读取
+	fake.losePage(chunks[1].PageID)
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch with a lost chunk")
+	}
+}
+
+func TestNoSecondary(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fake.livePages() != 1 {
+		t.Fatalf("expect no mirror, got %d pages", fake.livePages())
+	}
+	pageID := blobKey(t, d, obj.GetID())
+	fake.failNext(http.MethodGet, "/v1/pages/"+pageID, http.StatusNotFound, 1)
+	if _, err := d.Link(context.Background(), obj, model.LinkArgs{}); err == nil {
+		t.Fatal("expect the failed read returned without a mirror")
+	}
+}
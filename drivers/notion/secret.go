@@ -0,0 +1,186 @@
+package notion
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// secretPrefix 加密保存的配置项的前缀，其后为nonce和密文的base64
+	secretPrefix = "enc:"
+	// envRefPrefix 和 fileRefPrefix 配置项引用环境变量或文件（如Docker secrets）时的前缀，
+	// 引用本身不是机密，不加密保存
+	envRefPrefix  = "env:"
+	fileRefPrefix = "file:"
+)
+
+// secrets 解密和读取引用后的凭据，只存在于内存中。Addition保存到存储表，其中始终保留密文和引用
+type secrets struct {
+	notionCookie    string
+	notionToken     string
+	dbPass          string
+	secondaryCookie string
+	secondaryToken  string
+	encryptionKey   string
+	shareSecret     string
+}
+
+// secretFields 存储表中加密保存的配置项
+func (d *Notion) secretFields() map[string]*string {
+	return map[string]*string{
+		"notion_cookie":    &d.NotionCookie,
+		"notion_token":     &d.NotionToken,
+		"db_pass":          &d.DBPass,
+		"secondary_cookie": &d.SecondaryCookie,
+		"secondary_token":  &d.SecondaryToken,
+	}
+}
+
+// secretValues 加密保存的配置项解密后的值
+func (d *Notion) secretValues() map[string]*string {
+	return map[string]*string{
+		"notion_cookie":    &d.secret.notionCookie,
+		"notion_token":     &d.secret.notionToken,
+		"db_pass":          &d.secret.dbPass,
+		"secondary_cookie": &d.secret.secondaryCookie,
+		"secondary_token":  &d.secret.secondaryToken,
+	}
+}
+
+// refFields 可以引用环境变量或文件的配置项
+func (d *Notion) refFields() map[string]*string {
+	fields := d.secretValues()
+	fields["encryption_key"] = &d.secret.encryptionKey
+	fields["share_secret"] = &d.secret.shareSecret
+	return fields
+}
+
+func isSecretRef(value string) bool {
+	return strings.HasPrefix(value, envRefPrefix) || strings.HasPrefix(value, fileRefPrefix)
+}
+
+// resolveRef 读取引用的环境变量或文件，去掉文件结尾的换行
+func resolveRef(value string) (string, error) {
+	if name, ok := strings.CutPrefix(value, envRefPrefix); ok {
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("环境变量%s未设置", name)
+		}
+		return v, nil
+	}
+	data, err := os.ReadFile(strings.TrimPrefix(value, fileRefPrefix))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// secretAEAD 由服务器配置的master_key生成的AES-256-GCM，未配置时返回nil
+func secretAEAD() (cipher.AEAD, error) {
+	if conf.Conf == nil || conf.Conf.MasterKey == "" {
+		return nil, nil
+	}
+	block, err := aes.NewCipher(chunkstore.ParseKey(conf.Conf.MasterKey))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func encryptSecret(aead cipher.AEAD, plain string) (string, error) {
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	return secretPrefix + base64.RawURLEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(plain), nil)), nil
+}
+
+func decryptSecret(aead cipher.AEAD, value string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimPrefix(value, secretPrefix))
+	if err != nil {
+		return "", err
+	}
+	if len(data) < aead.NonceSize() {
+		return "", errors.New("密文太短")
+	}
+	plain, err := aead.Open(nil, data[:aead.NonceSize()], data[aead.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("密钥错误或密文已损坏")
+	}
+	return string(plain), nil
+}
+
+// loadSecrets 配置了master_key时，先将明文的配置项加密后保存到存储表，再将全部配置项解密到d.secret；
+// Addition中只保留密文，挂载后保存存储时也不会写回明文
+func (d *Notion) loadSecrets() error {
+	aead, err := secretAEAD()
+	if err != nil {
+		return fmt.Errorf("master_key无效: %w", err)
+	}
+	d.secret = secrets{encryptionKey: d.EncryptionKey, shareSecret: d.ShareSecret}
+	fields, values := d.secretFields(), d.secretValues()
+	for name, field := range fields {
+		*values[name] = *field
+	}
+	if aead == nil {
+		for name, field := range fields {
+			if strings.HasPrefix(*field, secretPrefix) {
+				return fmt.Errorf("%s已加密，服务器需要配置加密时的master_key", name)
+			}
+		}
+		return nil
+	}
+	encrypted := false
+	for name, field := range fields {
+		if *field == "" || strings.HasPrefix(*field, secretPrefix) || isSecretRef(*field) {
+			continue
+		}
+		sealed, err := encryptSecret(aead, *field)
+		if err != nil {
+			return fmt.Errorf("加密%s失败: %w", name, err)
+		}
+		*field = sealed
+		encrypted = true
+	}
+	if encrypted && d.ID != 0 {
+		op.MustSaveDriverStorage(d)
+		log.Infof("已加密Notion存储[%s]的凭据", d.MountPath)
+	}
+	for name, field := range fields {
+		if !strings.HasPrefix(*field, secretPrefix) {
+			continue
+		}
+		plain, err := decryptSecret(aead, *field)
+		if err != nil {
+			return fmt.Errorf("解密%s失败: %w", name, err)
+		}
+		*values[name] = plain
+	}
+	return nil
+}
+
+// resolveRefs 将d.secret中引用环境变量或文件的配置项替换为读取到的值，Addition中保留引用
+func (d *Notion) resolveRefs() error {
+	for name, field := range d.refFields() {
+		if !isSecretRef(*field) {
+			continue
+		}
+		value, err := resolveRef(*field)
+		if err != nil {
+			return fmt.Errorf("读取%s引用的%s失败: %w", name, *field, err)
+		}
+		*field = value
+	}
+	return nil
+}
@@ -0,0 +1,301 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/alist-org/alist/v3/pkg/utils/random"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// sessionPartSize 上传会话每个分块的大小，每个分块对应一个Notion页面
+	sessionPartSize = 256 * 1024 * 1024
+	// sessionCleanInterval 清理过期上传会话的间隔
+	sessionCleanInterval = 10 * time.Minute
+)
+
+// sessionParts 会话已收到的分块，key为分块序号
+type sessionParts map[int]chunkstore.Chunk
+
+func (d *Notion) sessionTTL() time.Duration {
+	return time.Duration(d.UploadSessionTTL) * time.Hour
+}
+
+func sessionInfo(s *UploadSession, parts sessionParts) *model.UploadSession {
+	received := make([]int, 0, len(parts))
+	for index := range parts {
+		received = append(received, index)
+	}
+	sort.Ints(received)
+	return &model.UploadSession{
+		ID:       s.ID,
+		Name:     s.Name,
+		Size:     s.Size,
+		PartSize: s.PartSize,
+		Parts:    s.partCount(),
+		Received: received,
+		Expires:  s.ExpiresAt,
+	}
+}
+
+func (s *UploadSession) partCount() int {
+	return int((s.Size + s.PartSize - 1) / s.PartSize)
+}
+
+func (s *UploadSession) parts() (sessionParts, error) {
+	parts := make(sessionParts)
+	if s.Parts == "" {
+		return parts, nil
+	}
+	if err := utils.Json.UnmarshalFromString(s.Parts, &parts); err != nil {
+		return nil, fmt.Errorf("解析上传会话的分块失败: %w", err)
+	}
+	return parts, nil
+}
+
+// getSession 获取当前存储未过期的上传会话，lock为true时锁定记录直到事务结束
+func (d *Notion) getSession(tx *gorm.DB, id string, lock bool) (*UploadSession, error) {
+	if lock {
+		tx = tx.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var s UploadSession
+	if err := tx.Where("id = ? AND database_id = ? AND expires_at > ?", id, d.NotionDatabaseID, time.Now()).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("上传会话%s不存在或已过期: %w", id, errs.ObjectNotFound)
+		}
+		return nil, fmt.Errorf("获取上传会话失败: %w", err)
+	}
+	return &s, nil
+}
+
+func (d *Notion) CreateUploadSession(ctx context.Context, dstDir model.Obj, name string, size int64) (*model.UploadSession, error) {
+	if err := d.checkWrite(true); err != nil {
+		return nil, err
+	}
+	if size <= 0 {
+		return nil, fmt.Errorf("文件大小必须大于0")
+	}
+	if err := d.checkUpload(name, size); err != nil {
+		return nil, err
+	}
+	dirID, _ := strconv.Atoi(dstDir.GetID())
+	if d.AccessMode == accessWriteOnce {
+		existing, err := d.tree.FindFile(dirID, d.tree.NormName(filepath.Base(name)))
+		if err != nil {
+			return nil, err
+		}
+		if err := d.checkOverwrite(existing); err != nil {
+			return nil, err
+		}
+	}
+	s := &UploadSession{
+		ID:          random.String(32),
+		DatabaseID:  d.NotionDatabaseID,
+		DirectoryID: dirID,
+		Name:        d.tree.NormName(filepath.Base(name)),
+		Size:        size,
+		PartSize:    sessionPartSize,
+		ExpiresAt:   time.Now().Add(d.sessionTTL()),
+	}
+	if err := d.db.Create(s).Error; err != nil {
+		return nil, fmt.Errorf("创建上传会话失败: %w", err)
+	}
+	return sessionInfo(s, nil), nil
+}
+
+func (d *Notion) GetUploadSession(ctx context.Context, id string) (*model.UploadSession, error) {
+	s, err := d.getSession(d.db, id, false)
+	if err != nil {
+		return nil, err
+	}
+	parts, err := s.parts()
+	if err != nil {
+		return nil, err
+	}
+	return sessionInfo(s, parts), nil
+}
+
+// PutUploadPart 将分块上传为一个Notion页面，重复上传的分块替换之前的页面，每次上传都会延长会话的有效期
+func (d *Notion) PutUploadPart(ctx context.Context, id string, index int, file model.FileStreamer, up driver.UpdateProgress) error {
+	s, err := d.getSession(d.db, id, false)
+	if err != nil {
+		return err
+	}
+	if index < 0 || index >= s.partCount() {
+		return fmt.Errorf("分块序号%d超出范围[0, %d)", index, s.partCount())
+	}
+	chunk := chunkstore.Chunk{
+		Index: index,
+		Start: int64(index) * s.PartSize,
+		End:   min(int64(index+1)*s.PartSize, s.Size),
+	}
+	if file.GetSize() != chunk.Size() {
+		return fmt.Errorf("分块%d的大小应为%d，实际为%d", index, chunk.Size(), file.GetSize())
+	}
+	backend, err := d.newChunkBackend(s.Name, d.contentType(s.Name, nil))
+	if err != nil {
+		return err
+	}
+	chunk.Key, err = backend.NewChunk(ctx, index)
+	if err != nil {
+		return fmt.Errorf("创建分块页面失败: %w", err)
+	}
+	if err := backend.Upload(ctx, &chunk, file, chunk.Size(), up); err != nil {
+		d.archiveSessionPages([]string{chunk.Key})
+		return fmt.Errorf("上传分块%d失败: %v", index, err)
+	}
+
+	var replaced []string
+	err = d.db.Transaction(func(tx *gorm.DB) error {
+		s, err := d.getSession(tx, id, true)
+		if err != nil {
+			return err
+		}
+		parts, err := s.parts()
+		if err != nil {
+			return err
+		}
+		if old, ok := parts[index]; ok {
+			replaced = append(replaced, old.Key)
+		}
+		parts[index] = chunk
+		data, err := utils.Json.MarshalToString(parts)
+		if err != nil {
+			return fmt.Errorf("序列化上传会话的分块失败: %w", err)
+		}
+		if err := tx.Model(s).Updates(map[string]interface{}{
+			"parts":      data,
+			"expires_at": time.Now().Add(d.sessionTTL()),
+		}).Error; err != nil {
+			return fmt.Errorf("保存上传会话失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		replaced = []string{chunk.Key}
+	}
+	d.archiveSessionPages(replaced)
+	return err
+}
+
+// CompleteUploadSession 收到全部分块后创建分块文件，存在同名文件时替换
+func (d *Notion) CompleteUploadSession(ctx context.Context, id string) (model.Obj, error) {
+	var f *File
+	var existingFile *File
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		s, err := d.getSession(tx, id, true)
+		if err != nil {
+			return err
+		}
+		parts, err := s.parts()
+		if err != nil {
+			return err
+		}
+		if len(parts) != s.partCount() {
+			return fmt.Errorf("上传会话还有%d个分块未上传", s.partCount()-len(parts))
+		}
+		var existing File
+		if err := d.tree.WhereName(tx, s.Name).Where("directory_id = ? AND deleted = ?", s.DirectoryID, false).First(&existing).Error; err == nil {
+			existingFile = &existing
+			if err := d.checkOverwrite(existingFile); err != nil {
+				return err
+			}
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("检查文件是否存在时发生错误: %w", err)
+		}
+		// 分块的哈希各自保存，整个文件的哈希未知
+		f = &File{
+			Name:        s.Name,
+			Size:        s.Size,
+			DirectoryID: s.DirectoryID,
+			IsChunked:   true,
+			ChunkSize:   s.PartSize,
+		}
+		if err := tx.Create(f).Error; err != nil {
+			return fmt.Errorf("创建文件记录失败: %w", err)
+		}
+		chunks := make([]FileChunk, 0, len(parts))
+		for index := 0; index < s.partCount(); index++ {
+			chunk := parts[index]
+			chunks = append(chunks, FileChunk{
+				FileID:      f.ID,
+				ChunkIndex:  chunk.Index,
+				ChunkSize:   chunk.Size(),
+				StartOffset: chunk.Start,
+				EndOffset:   chunk.End,
+				BlobKey:     chunk.Key,
+				SHA1:        chunk.Hash,
+				Nonce:       chunk.Nonce,
+				KeyID:       chunk.KeyID,
+			})
+		}
+		if err := tx.Create(&chunks).Error; err != nil {
+			return fmt.Errorf("保存分块记录失败: %w", err)
+		}
+		if err := tx.Delete(s).Error; err != nil {
+			return fmt.Errorf("删除上传会话失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if existingFile != nil {
+		if err := d.retireFile(existingFile, f.ID); err != nil {
+			return nil, err
+		}
+	}
+	obj := dbfs.FileToObj(f)
+	d.notify(ctx, EventUpload, f.DirectoryID, f.Name, f.Size, nil)
+	d.audit(ctx, AuditPut, "", obj, nil)
+	d.mirrorFile(f)
+	return obj, nil
+}
+
+// cleanSessions 删除过期的上传会话，并归档其已上传的分块页面
+func (d *Notion) cleanSessions() {
+	var sessions []UploadSession
+	if err := d.db.Where("database_id = ? AND expires_at <= ?", d.NotionDatabaseID, time.Now()).Find(&sessions).Error; err != nil {
+		log.Warnf("获取过期的上传会话失败: %+v", err)
+		return
+	}
+	for i := range sessions {
+		parts, err := sessions[i].parts()
+		if err != nil {
+			log.Warnf("清理上传会话%s失败: %+v", sessions[i].ID, err)
+			continue
+		}
+		if err := d.db.Delete(&sessions[i]).Error; err != nil {
+			log.Warnf("删除上传会话%s失败: %+v", sessions[i].ID, err)
+			continue
+		}
+		pageIDs := make([]string, 0, len(parts))
+		for _, chunk := range parts {
+			pageIDs = append(pageIDs, chunk.Key)
+		}
+		d.archiveSessionPages(pageIDs)
+	}
+}
+
+// archiveSessionPages 归档未完成的上传会话的分块页面，这些页面没有被任何文件引用，因此不受ArchiveOnDelete限制
+func (d *Notion) archiveSessionPages(pageIDs []string) {
+	for _, pageID := range pageIDs {
+		if err := d.notionClient.ArchivePage(pageID); err != nil {
+			log.Warnf("归档页面[%s]失败: %+v", pageID, err)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestUploadSession(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.UploadSessionTTL = 24 })
+	ctx := context.Background()
+	data := testData(1000)
+	s, err := d.CreateUploadSession(ctx, rootDir(d), "big.bin", int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Parts != 1 || len(s.Received) != 0 {
+		t.Fatalf("unexpected session %+v", s)
+	}
+	if _, err := d.CompleteUploadSession(ctx, s.ID); err == nil {
+		t.Fatal("expect completing a session with missing parts to fail")
+	}
+	if err := d.PutUploadPart(ctx, s.ID, 1, newTestStream("p", data), func(float64) {}); err == nil {
+		t.Fatal("expect a part out of range rejected")
+	}
+	if err := d.PutUploadPart(ctx, s.ID, 0, newTestStream("p", data[:10]), func(float64) {}); err == nil {
+		t.Fatal("expect a part of the wrong size rejected")
+	}
+
+	// 重复上传的分块替换之前的页面
+	if err := d.PutUploadPart(ctx, s.ID, 0, newTestStream("p", bytes.Repeat([]byte{1}, len(data))), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.PutUploadPart(ctx, s.ID, 0, newTestStream("p", data), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := d.GetUploadSession(ctx, s.ID); err != nil || len(got.Received) != 1 || got.Received[0] != 0 {
+		t.Fatalf("expect part 0 received, got %+v, %v", got, err)
+	}
+	if n := fake.livePages(); n != 1 {
+		t.Fatalf("expect the replaced part archived, got %d live pages", n)
+	}
+
+	obj, err := d.CompleteUploadSession(ctx, s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 || names[0] != "big.bin" {
+		t.Fatalf("expect big.bin, got %v", names)
+	}
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+	// 完成后会话被删除
+	if _, err := d.GetUploadSession(ctx, s.ID); !errors.Is(err, errs.ObjectNotFound) {
+		t.Fatalf("expect the completed session gone, got %v", err)
+	}
+}
+
+func TestUploadSessionExpired(t *testing.T) {
+	fake := newFakeNotion(t)
+	// 有效期为0，会话创建后即过期
+	d := newTestNotion(t, fake, func(d *Notion) { d.UploadSessionTTL = 0 })
+	s, err := d.CreateUploadSession(context.Background(), rootDir(d), "big.bin", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.GetUploadSession(context.Background(), s.ID); !errors.Is(err, errs.ObjectNotFound) {
+		t.Fatalf("expect the expired session not found, got %v", err)
+	}
+	err = d.PutUploadPart(context.Background(), s.ID, 0, newTestStream("p", testData(100)), func(float64) {})
+	if !errors.Is(err, errs.ObjectNotFound) {
+		t.Fatalf("expect no part uploaded to an expired session, got %v", err)
+	}
+}
@@ -0,0 +1,81 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	stdpath "path"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/sign"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/alist-org/alist/v3/server/common"
+)
+
+// maxShareExpiry 分享令牌的最长有效期
+const maxShareExpiry = 30 * 24 * time.Hour
+
+// ShareReq 创建分享令牌的参数，ExpiresIn为有效期的秒数，默认一天
+type ShareReq struct {
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+// ShareLink 分享的下载地址，下载经由本服务器代理，不暴露Notion的S3地址
+type ShareLink struct {
+	URL       string    `json:"url"`
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func (d *Notion) shareSign() (sign.Sign, error) {
+	if d.secret.shareSecret == "" {
+		return nil, fmt.Errorf("未配置分享密钥")
+	}
+	return sign.NewHMACSign([]byte(d.secret.shareSecret)), nil
+}
+
+// shareData 令牌签名的内容，绑定存储和文件ID，覆盖后的文件有新的ID，旧令牌失效
+func (d *Notion) shareData(fileID string) string {
+	return fmt.Sprintf("share:%d:%s", d.ID, fileID)
+}
+
+// createShare 为文件创建带有效期的分享令牌，令牌格式为 文件ID.签名:过期时间
+func (d *Notion) createShare(ctx context.Context, obj model.Obj, req ShareReq) (*ShareLink, error) {
+	if obj.IsDir() {
+		return nil, fmt.Errorf("只能分享文件")
+	}
+	s, err := d.shareSign()
+	if err != nil {
+		return nil, err
+	}
+	expiry := time.Duration(req.ExpiresIn) * time.Second
+	if expiry <= 0 {
+		expiry = 24 * time.Hour
+	}
+	if expiry > maxShareExpiry {
+		return nil, fmt.Errorf("有效期不能超过%d天", int(maxShareExpiry.Hours()/24))
+	}
+	expiresAt := time.Now().Add(expiry)
+	token := obj.GetID() + "." + s.Sign(d.shareData(obj.GetID()), expiresAt.Unix())
+	filePath := stdpath.Join(d.GetStorage().MountPath, d.tree.ObjPath(obj))
+	link := common.GetApiUrl(common.GetHttpReq(ctx)) + utils.EncodePath(stdpath.Join("/d", filePath), true) + "?share=" + token
+	return &ShareLink{URL: link, Token: token, ExpiresAt: expiresAt}, nil
+}
+
+// VerifyShare 校验下载请求中的分享令牌
+func (d *Notion) VerifyShare(ctx context.Context, obj model.Obj, token string) error {
+	s, err := d.shareSign()
+	if err != nil {
+		return err
+	}
+	fileID, signature, ok := strings.Cut(token, ".")
+	if !ok || fileID != obj.GetID() {
+		return sign.ErrSignInvalid
+	}
+	// 过期时间为0的签名永不过期，不是分享令牌
+	if strings.HasSuffix(signature, ":0") {
+		return sign.ErrExpireInvalid
+	}
+	return s.Verify(d.shareData(fileID), signature)
+}
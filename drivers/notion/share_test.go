@@ -0,0 +1,104 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/sign"
+)
+
+// createShare 通过Other创建分享令牌
+func createShare(d *Notion, obj model.Obj, expiresIn int64) (*ShareLink, error) {
+	res, err := d.Other(context.Background(), model.OtherArgs{Obj: obj, Method: "create_share", Data: ShareReq{ExpiresIn: expiresIn}})
+	if err != nil {
+		return nil, err
+	}
+	return res.(*ShareLink), nil
+}
+
+func TestShare(t *testing.T) {
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	siteURL := conf.Conf.SiteURL
+	conf.Conf.SiteURL = "https://alist.example.com"
+	defer func() { conf.Conf.SiteURL = siteURL }()
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.ShareSecret = "share secret" })
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := d.Put(ctx, dir, newTestStream("a b.txt", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.Put(ctx, dir, newTestStream("b.txt", testData(20)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	before := time.Now()
+	link, err := createShare(d, a, 3600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "https://alist.example.com/d/notion/docs/a%20b.txt?share=" + link.Token; link.URL != want {
+		t.Fatalf("expect %s, got %s", want, link.URL)
+	}
+	if left := link.ExpiresAt.Sub(before); left < time.Hour-time.Second || left > time.Hour+time.Second {
+		t.Fatalf("expect the token to expire in an hour, got %v", left)
+	}
+	if err := d.VerifyShare(ctx, a, link.Token); err != nil {
+		t.Fatalf("expect the token accepted, got %v", err)
+	}
+	// 令牌只对创建时的文件有效，签名不能修改
+	if err := d.VerifyShare(ctx, b, link.Token); !errors.Is(err, sign.ErrSignInvalid) {
+		t.Fatalf("expect the token refused for another file, got %v", err)
+	}
+	forged := b.GetID() + strings.TrimPrefix(link.Token, a.GetID())
+	if err := d.VerifyShare(ctx, b, forged); !errors.Is(err, sign.ErrSignInvalid) {
+		t.Fatalf("expect a forged token refused, got %v", err)
+	}
+	// 过期的令牌和永不过期的签名都不接受
+	s, _ := d.shareSign()
+	expired := a.GetID() + "." + s.Sign(d.shareData(a.GetID()), time.Now().Add(-time.Minute).Unix())
+	if err := d.VerifyShare(ctx, a, expired); !errors.Is(err, sign.ErrSignExpired) {
+		t.Fatalf("expect an expired token refused, got %v", err)
+	}
+	forever := a.GetID() + "." + s.Sign(d.shareData(a.GetID()), 0)
+	if err := d.VerifyShare(ctx, a, forever); !errors.Is(err, sign.ErrExpireInvalid) {
+		t.Fatalf("expect a token without expiry refused, got %v", err)
+	}
+
+	// 默认有效期为一天，最长30天，不能分享目录
+	if link, err := createShare(d, a, 0); err != nil || link.ExpiresAt.Sub(time.Now()) < 23*time.Hour {
+		t.Fatalf("expect a token valid for a day, got %+v %v", link, err)
+	}
+	if _, err := createShare(d, a, int64(31*24*3600)); err == nil {
+		t.Fatal("expect an expiry over 30 days refused")
+	}
+	if _, err := createShare(d, dir, 60); err == nil {
+		t.Fatal("expect a folder not shared")
+	}
+}
+
+func TestShareNoSecret(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.txt", testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := createShare(d, obj, 60); err == nil {
+		t.Fatal("expect sharing refused without a share secret")
+	}
+	if err := d.VerifyShare(context.Background(), obj, obj.GetID()+".x:1"); err == nil {
+		t.Fatal("expect every token refused without a share secret")
+	}
+}
@@ -0,0 +1,267 @@
+package notion
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// snapshotBatchSize 恢复快照时每批插入的记录数
+const snapshotBatchSize = 500
+
+// SnapshotReq create_snapshot/restore_snapshot/delete_snapshot的请求参数
+type SnapshotReq struct {
+	SnapshotID int    `json:"snapshot_id"`
+	Name       string `json:"name"`
+}
+
+// SnapshotInfo 快照信息
+type SnapshotInfo struct {
+	ID          int       `json:"id"`
+	Name        string    `json:"name"`
+	Auto        bool      `json:"auto"`
+	Directories int       `json:"directories"`
+	Files       int       `json:"files"`
+	Created     time.Time `json:"created"`
+}
+
+// snapshotData 快照内容，包含存储下的全部记录（含已删除的记录）
+type snapshotData struct {
+	Directories []Directory   `json:"directories"`
+	Files       []File        `json:"files"`
+	Chunks      []FileChunk   `json:"chunks"`
+	Versions    []FileVersion `json:"versions"`
+	Tags        []FileTag     `json:"tags"`
+}
+
+// dumpStorage 读取当前存储的全部元数据记录，需要在事务中调用以保证一致
+func (d *Notion) dumpStorage(tx *gorm.DB) (*snapshotData, error) {
+	var data snapshotData
+	if err := tx.Where("database_id = ?", d.NotionDatabaseID).Find(&data.Directories).Error; err != nil {
+		return nil, fmt.Errorf("获取目录失败: %w", err)
+	}
+	dirIDs := make([]int, 0, len(data.Directories))
+	for _, dir := range data.Directories {
+		dirIDs = append(dirIDs, dir.ID)
+	}
+	if len(dirIDs) == 0 {
+		return &data, nil
+	}
+	if err := tx.Where("directory_id IN ?", dirIDs).Find(&data.Files).Error; err != nil {
+		return nil, fmt.Errorf("获取文件失败: %w", err)
+	}
+	fileIDs := make([]int, 0, len(data.Files))
+	for _, f := range data.Files {
+		fileIDs = append(fileIDs, f.ID)
+	}
+	if len(fileIDs) == 0 {
+		return &data, nil
+	}
+	if err := tx.Where("file_id IN ?", fileIDs).Find(&data.Chunks).Error; err != nil {
+		return nil, fmt.Errorf("获取文件分块失败: %w", err)
+	}
+	if err := tx.Where("file_id IN ?", fileIDs).Find(&data.Versions).Error; err != nil {
+		return nil, fmt.Errorf("获取历史版本失败: %w", err)
+	}
+	if err := tx.Where("file_id IN ?", fileIDs).Find(&data.Tags).Error; err != nil {
+		return nil, fmt.Errorf("获取标签失败: %w", err)
+	}
+	return &data, nil
+}
+
+// createSnapshot 在一个事务中导出存储的元数据并保存为快照，auto为定时创建的快照
+func (d *Notion) createSnapshot(name string, auto bool) (*SnapshotInfo, error) {
+	var s Snapshot
+	var data *snapshotData
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var err error
+		data, err = d.dumpStorage(tx)
+		if err != nil {
+			return err
+		}
+		b, err := utils.Json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("序列化快照失败: %w", err)
+		}
+		if name == "" {
+			name = time.Now().Format("2006-01-02 15:04:05")
+		}
+		s = Snapshot{DatabaseID: d.NotionDatabaseID, Name: name, Auto: auto, Data: string(b)}
+		if err := tx.Create(&s).Error; err != nil {
+			return fmt.Errorf("保存快照失败: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &SnapshotInfo{
+		ID:          s.ID,
+		Name:        s.Name,
+		Auto:        s.Auto,
+		Directories: len(data.Directories),
+		Files:       len(data.Files),
+		Created:     s.CreatedAt,
+	}, nil
+}
+
+// listSnapshots 列出当前存储的快照，新快照在前
+func (d *Notion) listSnapshots() ([]SnapshotInfo, error) {
+	var snapshots []Snapshot
+	if err := d.db.Where("database_id = ?", d.NotionDatabaseID).Order("id DESC").Find(&snapshots).Error; err != nil {
+		return nil, fmt.Errorf("获取快照失败: %w", err)
+	}
+	res := make([]SnapshotInfo, 0, len(snapshots))
+	for _, s := range snapshots {
+		var data snapshotData
+		if err := utils.Json.UnmarshalFromString(s.Data, &data); err != nil {
+			return nil, fmt.Errorf("解析快照%d失败: %v", s.ID, err)
+		}
+		res = append(res, SnapshotInfo{
+			ID:          s.ID,
+			Name:        s.Name,
+			Auto:        s.Auto,
+			Directories: len(data.Directories),
+			Files:       len(data.Files),
+			Created:     s.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+// getSnapshot 获取属于当前存储的快照
+func (d *Notion) getSnapshot(tx *gorm.DB, snapshotID int) (*Snapshot, error) {
+	var s Snapshot
+	if err := tx.Where("id = ? AND database_id = ?", snapshotID, d.NotionDatabaseID).First(&s).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("快照%d不存在: %w", snapshotID, errs.ObjectNotFound)
+		}
+		return nil, fmt.Errorf("获取快照失败: %w", err)
+	}
+	return &s, nil
+}
+
+// restoreSnapshot 将存储的元数据恢复到快照时的状态，恢复前先为当前状态创建快照，恢复错了可以再恢复回来
+// 快照之后新建的记录会被删除，其Notion页面不会归档；快照之后已被归档的页面无法通过恢复找回
+func (d *Notion) restoreSnapshot(snapshotID int) error {
+	if _, err := d.getSnapshot(d.db, snapshotID); err != nil {
+		return err
+	}
+	if _, err := d.createSnapshot(fmt.Sprintf("恢复快照%d前", snapshotID), false); err != nil {
+		return err
+	}
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		s, err := d.getSnapshot(tx, snapshotID)
+		if err != nil {
+			return err
+		}
+		var data snapshotData
+		if err := utils.Json.UnmarshalFromString(s.Data, &data); err != nil {
+			return fmt.Errorf("解析快照失败: %w", err)
+		}
+		if err := d.deleteStorageRows(tx); err != nil {
+			return err
+		}
+		if len(data.Directories) > 0 {
+			if err := tx.CreateInBatches(data.Directories, snapshotBatchSize).Error; err != nil {
+				return fmt.Errorf("恢复目录失败: %w", err)
+			}
+		}
+		if len(data.Files) > 0 {
+			if err := tx.CreateInBatches(data.Files, snapshotBatchSize).Error; err != nil {
+				return fmt.Errorf("恢复文件失败: %w", err)
+			}
+		}
+		if len(data.Chunks) > 0 {
+			if err := tx.CreateInBatches(data.Chunks, snapshotBatchSize).Error; err != nil {
+				return fmt.Errorf("恢复文件分块失败: %w", err)
+			}
+		}
+		if len(data.Versions) > 0 {
+			if err := tx.CreateInBatches(data.Versions, snapshotBatchSize).Error; err != nil {
+				return fmt.Errorf("恢复历史版本失败: %w", err)
+			}
+		}
+		if len(data.Tags) > 0 {
+			if err := tx.CreateInBatches(data.Tags, snapshotBatchSize).Error; err != nil {
+				return fmt.Errorf("恢复标签失败: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+// deleteStorageRows 删除当前存储的全部目录、文件、分块、历史版本和标签记录
+func (d *Notion) deleteStorageRows(tx *gorm.DB) error {
+	var fileIDs []int
+	if err := d.tree.FileIDs(tx).Pluck("id", &fileIDs).Error; err != nil {
+		return fmt.Errorf("获取文件失败: %w", err)
+	}
+	if len(fileIDs) > 0 {
+		if err := tx.Where("file_id IN ?", fileIDs).Delete(&FileVersion{}).Error; err != nil {
+			return fmt.Errorf("删除历史版本失败: %w", err)
+		}
+		if err := tx.Where("file_id IN ?", fileIDs).Delete(&FileTag{}).Error; err != nil {
+			return fmt.Errorf("删除标签失败: %w", err)
+		}
+		if err := tx.Where("file_id IN ?", fileIDs).Delete(&FileChunk{}).Error; err != nil {
+			return fmt.Errorf("删除文件分块失败: %w", err)
+		}
+		if err := tx.Where("id IN ?", fileIDs).Delete(&File{}).Error; err != nil {
+			return fmt.Errorf("删除文件失败: %w", err)
+		}
+	}
+	if err := tx.Where("database_id = ?", d.NotionDatabaseID).Delete(&Directory{}).Error; err != nil {
+		return fmt.Errorf("删除目录失败: %w", err)
+	}
+	return nil
+}
+
+// deleteSnapshot 删除快照，不影响当前数据
+func (d *Notion) deleteSnapshot(snapshotID int) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		s, err := d.getSnapshot(tx, snapshotID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Delete(s).Error; err != nil {
+			return fmt.Errorf("删除快照失败: %w", err)
+		}
+		return nil
+	})
+}
+
+// expiredSnapshots 超出保留数量的旧的定时快照的ID
+func (d *Notion) expiredSnapshots(tx *gorm.DB) ([]int, error) {
+	var ids []int
+	err := tx.Model(&Snapshot{}).Where("database_id = ? AND auto = ?", d.NotionDatabaseID, true).
+		Order("id DESC").Limit(offsetLimit).Offset(d.SnapshotRetention).Pluck("id", &ids).Error
+	return ids, err
+}
+
+// autoSnapshot 定时创建快照，并删除超出保留数量的旧的定时快照
+func (d *Notion) autoSnapshot() {
+	if _, err := d.createSnapshot("", true); err != nil {
+		log.Warnf("定时创建快照失败: %+v", err)
+		return
+	}
+	if d.SnapshotRetention <= 0 {
+		return
+	}
+	ids, err := d.expiredSnapshots(d.db)
+	if err != nil {
+		log.Warnf("获取过期的定时快照失败: %v", err)
+		return
+	}
+	if len(ids) == 0 {
+		return
+	}
+	if err := d.db.Delete(&Snapshot{}, ids).Error; err != nil {
+		log.Warnf("删除过期的定时快照失败: %v", err)
+	}
+}
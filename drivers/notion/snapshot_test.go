@@ -0,0 +1,89 @@
+package notion
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestSnapshotRestore(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	a, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.MakeDir(ctx, rootDir(d), "dir"); err != nil {
+		t.Fatal(err)
+	}
+	info, err := d.createSnapshot("before", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Files != 1 {
+		t.Fatalf("expect a file in the snapshot, got %+v", info)
+	}
+
+	if err := d.Remove(ctx, a); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("b.bin", testData(2000)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.restoreSnapshot(info.ID); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	names := listNames(t, d, rootDir(d))
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"a.bin", "dir"}) {
+		t.Fatalf("expect the listing of the snapshot, got %v", names)
+	}
+
+	// 恢复前的状态保存为快照，可以再恢复回来
+	snapshots, err := d.listSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 2 || snapshots[1].ID != info.ID {
+		t.Fatalf("expect a snapshot taken before restoring, got %+v", snapshots)
+	}
+	if err := d.restoreSnapshot(snapshots[0].ID); err != nil {
+		t.Fatal(err)
+	}
+	names = listNames(t, d, rootDir(d))
+	slices.Sort(names)
+	if !slices.Equal(names, []string{"b.bin", "dir"}) {
+		t.Fatalf("expect the listing before restoring, got %v", names)
+	}
+
+	if err := d.deleteSnapshot(info.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.restoreSnapshot(info.ID); err == nil {
+		t.Fatal("expect a deleted snapshot not to be restored")
+	}
+}
+
+func TestAutoSnapshotRetention(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.SnapshotRetention = 2 })
+	if _, err := d.createSnapshot("manual", false); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		d.autoSnapshot()
+	}
+	snapshots, err := d.listSnapshots()
+	if err != nil {
+		t.Fatal(err)
+	}
+	auto := 0
+	for _, s := range snapshots {
+		if s.Auto {
+			auto++
+		}
+	}
+	// 只清理超出保留数量的定时快照，手动创建的快照不受影响
+	if auto != 2 || len(snapshots) != 3 {
+		t.Fatalf("expect 2 auto snapshots and the manual one, got %+v", snapshots)
+	}
+}
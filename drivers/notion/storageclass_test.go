@@ -0,0 +1,77 @@
+package notion
+
+import (
+	"context"
+	"strings"
+	"testing"
+)
+
+// pageObjectAttrs 返回页面第一个附件上传时的存储类型和标签
+func (f *fakeNotion) pageObjectAttrs(id string) [2]string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	page, ok := f.pages[id]
+	if !ok || len(page.files) == 0 {
+		return [2]string{}
+	}
+	_, key, _ := strings.Cut(page.files[0].File.URL, "/s3/")
+	return f.objectAttrs[key]
+}
+
+func TestS3StorageClass(t *testing.T) {
+	for _, c := range []struct {
+		name               string
+		form               bool
+		class, tagging     string
+		wantClass, wantTag string
+	}{
+		// 未设置时PUT不发送请求头，表单保留Notion返回的值
+		{name: "put default"},
+		{name: "form default", form: true, wantClass: "STANDARD"},
+		{name: "put", class: "STANDARD_IA", tagging: "a=1&b=2", wantClass: "STANDARD_IA", wantTag: "a=1&b=2"},
+		{name: "form", form: true, class: "STANDARD_IA", tagging: "a=1&b=2", wantClass: "STANDARD_IA", wantTag: "a=1&b=2"},
+	} {
+		t.Run(c.name, func(t *testing.T) {
+			fake := newFakeNotion(t)
+			fake.formUpload = c.form
+			d := newTestNotion(t, fake, func(d *Notion) {
+				d.S3StorageClass = c.class
+				d.S3Tagging = c.tagging
+			})
+			obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1000)), func(float64) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var f File
+			if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+				t.Fatal(err)
+			}
+			if got := fake.pageObjectAttrs(f.BlobKey); got != [2]string{c.wantClass, c.wantTag} {
+				t.Fatalf("expect storage class %q and tagging %q, got %q", c.wantClass, c.wantTag, got)
+			}
+		})
+	}
+}
+
+func TestS3StorageClassChunks(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+		d.S3StorageClass = "GLACIER_IR"
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", testData(3<<20)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var chunks []FileChunk
+	if err := d.db.Where("file_id = ?", obj.GetID()).Find(&chunks).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 分块页面同样使用设置的存储类型
+	for _, c := range chunks {
+		if got := fake.pageObjectAttrs(c.BlobKey); got[0] != "GLACIER_IR" {
+			t.Fatalf("expect chunk %d stored as GLACIER_IR, got %q", c.ChunkIndex, got[0])
+		}
+	}
+}
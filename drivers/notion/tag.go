@@ -0,0 +1,112 @@
+package notion
+
+import (
+	"fmt"
+	"path"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// maxTagLength 标签键和值的最大字符数，与数据库中列的长度一致
+const maxTagLength = 191
+
+// TagReq set_tags/list_by_tag的请求参数
+type TagReq struct {
+	// Tags set_tags设置的标签，已有的键会被覆盖
+	Tags map[string]string `json:"tags"`
+	// Remove set_tags删除的标签键
+	Remove []string `json:"remove"`
+	// Key、Value list_by_tag查询的标签，Value为空时匹配该键的任意值
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// TaggedFile list_by_tag返回的文件
+type TaggedFile struct {
+	ID   string            `json:"id"`
+	Path string            `json:"path"`
+	Size int64             `json:"size"`
+	Tags map[string]string `json:"tags"`
+}
+
+// taggedFileID 标签只能设置在文件上
+func taggedFileID(obj model.Obj) (int, error) {
+	if obj == nil || obj.IsDir() {
+		return 0, fmt.Errorf("只能为文件设置标签")
+	}
+	return strconv.Atoi(obj.GetID())
+}
+
+// checkTags 检查要设置的标签的键和值
+func checkTags(tags map[string]string) error {
+	for key, value := range tags {
+		if key == "" {
+			return fmt.Errorf("标签键不能为空")
+		}
+		if utf8.RuneCountInString(key) > maxTagLength || utf8.RuneCountInString(value) > maxTagLength {
+			return fmt.Errorf("标签%s过长，键和值最多%d个字符", key, maxTagLength)
+		}
+	}
+	return nil
+}
+
+func (d *Notion) getTags(obj model.Obj) (map[string]string, error) {
+	fileID, err := taggedFileID(obj)
+	if err != nil {
+		return nil, err
+	}
+	return d.tree.Tags(fileID)
+}
+
+func (d *Notion) setTags(obj model.Obj, req TagReq) (map[string]string, error) {
+	fileID, err := taggedFileID(obj)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := d.tree.GetFile(strconv.Itoa(fileID)); err != nil {
+		return nil, err
+	}
+	if err := checkTags(req.Tags); err != nil {
+		return nil, err
+	}
+	if err := d.tree.SetTags(fileID, req.Tags, req.Remove); err != nil {
+		return nil, fmt.Errorf("设置标签失败: %w", err)
+	}
+	return d.tree.Tags(fileID)
+}
+
+// listByTag 列出存储中带有指定标签的文件，挂载子目录时只列出子目录中的文件
+func (d *Notion) listByTag(req TagReq) ([]TaggedFile, error) {
+	if req.Key == "" {
+		return nil, fmt.Errorf("标签键不能为空")
+	}
+	files, err := d.tree.FindByTag(req.Key, req.Value)
+	if err != nil {
+		return nil, err
+	}
+	dirPaths := make(map[int]string)
+	res := make([]TaggedFile, 0, len(files))
+	for _, f := range files {
+		dirPath, ok := dirPaths[f.DirectoryID]
+		if !ok {
+			if !d.tree.InBase(f.DirectoryID) {
+				continue
+			}
+			dirPath = d.tree.DirPath(f.DirectoryID)
+			dirPaths[f.DirectoryID] = dirPath
+		}
+		tags, err := d.tree.Tags(f.ID)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, TaggedFile{
+			ID:   strconv.Itoa(f.ID),
+			Path: path.Join(dirPath, f.Name),
+			Size: f.Size,
+			Tags: tags,
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,114 @@
+package notion
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// callTags 调用标签相关的Other方法
+func callTags(d *Notion, method string, obj model.Obj, req TagReq) (interface{}, error) {
+	return d.Other(context.Background(), model.OtherArgs{Obj: obj, Method: method, Data: req})
+}
+
+func TestTags(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := d.Put(ctx, rootDir(d), newTestStream("a.txt", testData(100)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := d.Put(ctx, dir, newTestStream("b.txt", testData(200)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res, err := callTags(d, "set_tags", a, TagReq{Tags: map[string]string{"project": "x", "status": "draft"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := res.(map[string]string); len(tags) != 2 || tags["status"] != "draft" {
+		t.Fatalf("expect the tags set, got %v", tags)
+	}
+	// 已有的键被覆盖，Remove中的键被删除
+	if _, err := callTags(d, "set_tags", a, TagReq{Tags: map[string]string{"status": "final"}, Remove: []string{"project"}}); err != nil {
+		t.Fatal(err)
+	}
+	if tags := callOther(t, d, "get_tags", a).(map[string]string); len(tags) != 1 || tags["status"] != "final" {
+		t.Fatalf("expect status=final only, got %v", tags)
+	}
+	if _, err := callTags(d, "set_tags", b, TagReq{Tags: map[string]string{"status": "draft"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(key, value string) []string {
+		res, err := callTags(d, "list_by_tag", rootDir(d), TagReq{Key: key, Value: value})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var paths []string
+		for _, f := range res.([]TaggedFile) {
+			paths = append(paths, f.Path)
+		}
+		return paths
+	}
+	if paths := list("status", ""); strings.Join(paths, ",") != "/a.txt,/docs/b.txt" {
+		t.Fatalf("expect both files tagged status, got %v", paths)
+	}
+	if paths := list("status", "draft"); strings.Join(paths, ",") != "/docs/b.txt" {
+		t.Fatalf("expect b.txt tagged draft, got %v", paths)
+	}
+	if paths := list("project", ""); len(paths) != 0 {
+		t.Fatalf("expect no file tagged project, got %v", paths)
+	}
+
+	// 上传替换文件后保留标签，删除的文件不再列出
+	a, err = d.Put(ctx, rootDir(d), newTestStream("a.txt", testData(300)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags := callOther(t, d, "get_tags", a).(map[string]string); tags["status"] != "final" {
+		t.Fatalf("expect the tags kept after replacing, got %v", tags)
+	}
+	if err := d.Remove(ctx, b); err != nil {
+		t.Fatal(err)
+	}
+	if paths := list("status", ""); strings.Join(paths, ",") != "/a.txt" {
+		t.Fatalf("expect only a.txt listed, got %v", paths)
+	}
+}
+
+func TestTagsInvalid(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.txt", testData(100)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		name, method string
+		obj          model.Obj
+		req          TagReq
+	}{
+		{"folder", "set_tags", rootDir(d), TagReq{Tags: map[string]string{"k": "v"}}},
+		{"empty key", "set_tags", obj, TagReq{Tags: map[string]string{"": "v"}}},
+		{"long value", "set_tags", obj, TagReq{Tags: map[string]string{"k": strings.Repeat("v", maxTagLength+1)}}},
+		{"list without key", "list_by_tag", rootDir(d), TagReq{}},
+	}
+	for _, c := range cases {
+		if _, err := callTags(d, c.method, c.obj, c.req); err == nil {
+			t.Errorf("%s: expect an error", c.name)
+		}
+	}
+	// 最长的键和值可以设置
+	long := strings.Repeat("键", maxTagLength)
+	if _, err := callTags(d, "set_tags", obj, TagReq{Tags: map[string]string{long: long}}); err != nil {
+		t.Fatalf("expect the longest tag set, got %v", err)
+	}
+}
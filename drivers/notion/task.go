@@ -0,0 +1,129 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/fs"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/alist-org/alist/v3/pkg/utils/random"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// chunkHooksKey chunkHooks在context中的key
+type chunkHooksKey struct{}
+
+// chunkHooks 上传任务中chunkBackend上传每个分块前后的回调，start返回错误时不上传该分块
+type chunkHooks struct {
+	start func(index int) error
+	done  func(chunk chunkstore.Chunk)
+}
+
+// uploadTask 将上传作为任务显示在任务列表中，显示进度、当前分块、已上传的字节数、速度和剩余时间，可以在任务列表中取消或暂停；
+// 暂停时当前分块上传完成后等待继续，chunks为分块总数，未知时为0，不分块上传时为1；
+// resumable为true时暂停期间已上传的分块保存为上传会话，服务重启后可以通过上传会话补传其余分块
+func (d *Notion) uploadTask(ctx context.Context, dirID int, name string, size int64, chunks int, resumable bool,
+	up driver.UpdateProgress, upload func(ctx context.Context, up driver.UpdateProgress) error) error {
+	filePath := path.Join(d.GetStorage().MountPath, d.tree.DirPath(dirID), name)
+	return fs.RunChunkUpload(ctx, filePath, size, chunks, func(ctx context.Context, t *fs.ChunkUploadTask) error {
+		var mu sync.Mutex
+		var uploaded []chunkstore.Chunk
+		hooks := &chunkHooks{
+			start: func(index int) error {
+				t.SetChunk(index, chunks)
+				if !t.Paused() {
+					return nil
+				}
+				note := "the uploaded chunks are kept until it's resumed"
+				var session *UploadSession
+				if resumable {
+					mu.Lock()
+					parts := append([]chunkstore.Chunk(nil), uploaded...)
+					mu.Unlock()
+					var err error
+					if session, err = d.pauseSession(dirID, name, size, parts); err != nil {
+						log.Warnf("保存暂停的上传[%s]失败: %+v", filePath, err)
+					} else if session != nil {
+						note = "the uploaded chunks are kept in upload session " + session.ID
+					}
+				}
+				if err := t.WaitResume(ctx, note); err != nil {
+					return err
+				}
+				if session != nil {
+					return d.resumeSession(session.ID)
+				}
+				return nil
+			},
+			done: func(chunk chunkstore.Chunk) {
+				mu.Lock()
+				defer mu.Unlock()
+				uploaded = append(uploaded, chunk)
+			},
+		}
+		ctx = context.WithValue(ctx, chunkHooksKey{}, hooks)
+		return upload(ctx, func(p float64) {
+			up(p)
+			t.SetProgress(p)
+		})
+	})
+}
+
+// pauseSession 将暂停时已上传的分块保存为上传会话，会话的分块大小为第一个分块的大小，
+// 大小不一致的分块（自适应大小或按内容切分）无法表示为会话，返回nil
+func (d *Notion) pauseSession(dirID int, name string, size int64, uploaded []chunkstore.Chunk) (*UploadSession, error) {
+	if len(uploaded) == 0 {
+		return nil, nil
+	}
+	partSize := uploaded[0].Size()
+	parts := make(sessionParts, len(uploaded))
+	for _, chunk := range uploaded {
+		if chunk.Start != int64(chunk.Index)*partSize || chunk.End != min(chunk.Start+partSize, size) {
+			return nil, nil
+		}
+		parts[chunk.Index] = chunk
+	}
+	data, err := utils.Json.MarshalToString(parts)
+	if err != nil {
+		return nil, fmt.Errorf("序列化上传会话的分块失败: %w", err)
+	}
+	s := &UploadSession{
+		ID:          random.String(32),
+		DatabaseID:  d.NotionDatabaseID,
+		DirectoryID: dirID,
+		Name:        name,
+		Size:        size,
+		PartSize:    partSize,
+		Parts:       data,
+		ExpiresAt:   time.Now().Add(d.sessionTTL()),
+	}
+	if err := d.db.Create(s).Error; err != nil {
+		return nil, fmt.Errorf("创建上传会话失败: %w", err)
+	}
+	return s, nil
+}
+
+// resumeSession 继续暂停的上传时删除其上传会话，会话已过期被清理或已被完成时上传失败
+func (d *Notion) resumeSession(id string) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		s, err := d.getSession(tx, id, true)
+		if err != nil {
+			if errors.Is(err, errs.ObjectNotFound) {
+				return fmt.Errorf("暂停期间上传会话%s已过期或已完成，无法继续上传", id)
+			}
+			return err
+		}
+		if err := tx.Delete(s).Error; err != nil {
+			return fmt.Errorf("删除上传会话失败: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,185 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/fs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/chunkstore"
+	"github.com/xhofe/tache"
+)
+
+// useChunkUploadTasks 测试期间上传作为任务运行
+func useChunkUploadTasks(t *testing.T) {
+	old := fs.ChunkUploadTaskManager
+	fs.ChunkUploadTaskManager = tache.NewManager[*fs.ChunkUploadTask](tache.WithWorks(1), tache.WithMaxRetry(0))
+	t.Cleanup(func() { fs.ChunkUploadTaskManager = old })
+}
+
+func TestUploadTask(t *testing.T) {
+	useChunkUploadTasks(t)
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	dir, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj, err := d.Put(ctx, dir, newTestStream("big.bin", testData(3*1024*1024)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	chunks := callOther(t, d, "list_chunks", obj).([]ChunkInfo)
+	fs.ChunkUploadTaskManager.Wait()
+	tasks := fs.ChunkUploadTaskManager.GetAll()
+	if len(tasks) != 1 {
+		t.Fatalf("expect an upload task, got %d", len(tasks))
+	}
+	tsk := tasks[0]
+	if tsk.Path != "/notion/docs/big.bin" || tsk.GetState() != tache.StateSucceeded || tsk.GetProgress() != 100 {
+		t.Fatalf("expect the succeeded upload of big.bin, got %s %v %v", tsk.Path, tsk.GetState(), tsk.GetProgress())
+	}
+	// 按内容切分时分块数未知
+	if want := fmt.Sprintf("chunk %d", len(chunks)); tsk.GetStatus() != want {
+		t.Fatalf("expect %s, got %s", want, tsk.GetStatus())
+	}
+}
+
+func TestUploadTaskCanceled(t *testing.T) {
+	useChunkUploadTasks(t)
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	// 第一个分块上传后在任务列表中取消
+	up := func(float64) {
+		for _, tsk := range fs.ChunkUploadTaskManager.GetAll() {
+			fs.ChunkUploadTaskManager.Cancel(tsk.GetID())
+		}
+	}
+	_, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", testData(3*1024*1024)), up)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect the upload canceled, got %v", err)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 0 {
+		t.Fatalf("expect no file for the canceled upload, got %v", names)
+	}
+}
+
+// TestUploadTaskPause 暂停的上传在当前分块完成后等待，已上传的分块保存为上传会话，继续后删除会话并上传其余分块
+func TestUploadTaskPause(t *testing.T) {
+	useChunkUploadTasks(t)
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) {
+		d.UploadSessionTTL = 24
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	var once sync.Once
+	paused := make(chan string, 1)
+	up := func(float64) {
+		once.Do(func() {
+			tsk := fs.ChunkUploadTaskManager.GetAll()[0]
+			tsk.Pause()
+			go func() {
+				// 等待上传在下一个分块前停下
+				deadline := time.Now().Add(5 * time.Second)
+				for !strings.Contains(tsk.GetStatus(), "kept") && time.Now().Before(deadline) {
+					time.Sleep(10 * time.Millisecond)
+				}
+				paused <- tsk.GetStatus()
+				tsk.Resume()
+			}()
+		})
+	}
+	data := testData(3 * 1024 * 1024)
+	start := time.Now()
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("big.bin", data), up)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 继续时不重试分块
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Fatalf("expect the upload resumed at once, took %v", elapsed)
+	}
+	status := <-paused
+	const prefix = "paused before chunk 2, the uploaded chunks are kept in upload session "
+	if !strings.HasPrefix(status, prefix) {
+		t.Fatalf("expect the paused status with the session, got %s", status)
+	}
+	if _, err := d.GetUploadSession(context.Background(), strings.TrimPrefix(status, prefix)); !errors.Is(err, errs.ObjectNotFound) {
+		t.Fatalf("expect the session removed after resuming, got %v", err)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readRange(t, link, 0, int64(len(data))); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch after resuming")
+	}
+}
+
+func TestPauseSession(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) { d.UploadSessionTTL = 24 })
+	dirID, _ := strconv.Atoi(d.RootFolderID)
+	// 没有已上传的分块或分块大小不一致时不保存
+	if s, err := d.pauseSession(dirID, "a.bin", 250, nil); s != nil || err != nil {
+		t.Fatalf("expect no session without chunks, got %v %v", s, err)
+	}
+	uneven := []chunkstore.Chunk{{Index: 0, End: 100, Key: "p0"}, {Index: 1, Start: 100, End: 150, Key: "p1"}}
+	if s, err := d.pauseSession(dirID, "a.bin", 250, uneven); s != nil || err != nil {
+		t.Fatalf("expect no session for uneven chunks, got %v %v", s, err)
+	}
+	s, err := d.pauseSession(dirID, "a.bin", 250, []chunkstore.Chunk{{Index: 0, End: 100, Key: "p0"}, {Index: 1, Start: 100, End: 200, Key: "p1"}})
+	if err != nil || s == nil {
+		t.Fatalf("expect a session, got %v", err)
+	}
+	// 上传会话中可以看到已上传的分块
+	info, err := d.GetUploadSession(context.Background(), s.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PartSize != 100 || len(info.Received) != 2 {
+		t.Fatalf("expect 2 parts of 100 bytes, got %+v", info)
+	}
+	// 继续时删除会话，会话已不存在时无法继续
+	if err := d.resumeSession(s.ID); err != nil {
+		t.Fatal(err)
+	}
+	if err := d.resumeSession(s.ID); err == nil {
+		t.Fatal("expect resuming a removed session to fail")
+	}
+}
+
+// TestUploadTaskSingle 整个上传的文件也作为任务运行，显示已发送的字节数
+func TestUploadTaskSingle(t *testing.T) {
+	useChunkUploadTasks(t)
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	var status string
+	up := func(float64) {
+		if tasks := fs.ChunkUploadTaskManager.GetAll(); len(tasks) == 1 {
+			status = tasks[0].GetStatus()
+		}
+	}
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(1024*1024)), up); err != nil {
+		t.Fatal(err)
+	}
+	fs.ChunkUploadTaskManager.Wait()
+	tasks := fs.ChunkUploadTaskManager.GetAll()
+	if len(tasks) != 1 || tasks[0].GetName() != "upload [/notion/a.bin]" || tasks[0].GetState() != tache.StateSucceeded {
+		t.Fatalf("expect the succeeded upload of a.bin, got %d tasks", len(tasks))
+	}
+	if !strings.Contains(status, "/1.00 MB, ") || !strings.Contains(status, "MB/s now") {
+		t.Fatalf("expect the bytes sent shown, got %s", status)
+	}
+}
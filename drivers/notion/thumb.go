@@ -0,0 +1,184 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	stdpath "path"
+	"strconv"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/sign"
+	"github.com/alist-org/alist/v3/pkg/singleflight"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/alist-org/alist/v3/server/common"
+	"github.com/disintegration/imaging"
+	log "github.com/sirupsen/logrus"
+	ffmpeg "github.com/u2takey/ffmpeg-go"
+)
+
+const (
+	// thumbWidth 缩略图宽度，与本地存储一致
+	thumbWidth = 144
+	// thumbMaxImageSize 超过该大小的图片不生成缩略图，避免整张下载
+	thumbMaxImageSize = 50 * 1024 * 1024
+	// thumbVideoPos 视频截图位置占总时长的比例
+	thumbVideoPos = 0.2
+)
+
+var thumbG singleflight.Group[string]
+
+// thumbURL 返回文件的缩略图地址，由Link(type=thumb)重定向到缩略图页面的附件
+func (d *Notion) thumbURL(ctx context.Context, reqPath, name string) string {
+	typeName := utils.GetFileType(name)
+	if !d.thumbEnabled() || (typeName != conf.IMAGE && typeName != conf.VIDEO) || utils.Ext(name) == "svg" {
+		return ""
+	}
+	thumb := common.GetApiUrl(common.GetHttpReq(ctx)) + stdpath.Join("/d", reqPath, name)
+	thumb = utils.EncodePath(thumb, true)
+	thumb += "?type=thumb&sign=" + sign.Sign(stdpath.Join(reqPath, name))
+	return thumb
+}
+
+// thumbEnabled 开启加密时缩略图会以明文上传到Notion，因此不生成缩略图
+func (d *Notion) thumbEnabled() bool {
+	return d.Thumbnail && d.chunkKey == nil
+}
+
+// thumbLink 获取缩略图的下载地址，首次请求时生成缩略图并作为附件上传到单独的Notion页面
+func (d *Notion) thumbLink(ctx context.Context, f *File) (*model.Link, error) {
+	if f.ThumbKey != "" {
+		property, err := d.notionClient.GetPageProperty(f.ThumbKey, d.NotionFilePageID)
+		if err == nil && len(property.Files) > 0 {
+			if d.hotCache != nil {
+				return d.hotThumbLink(ctx, f.ThumbKey)
+			}
+			return d.fileLink(property.Files[0].File.URL), nil
+		}
+		// 缩略图页面不可用（如已被归档）时重新生成
+		log.Warnf("获取文件[%s]的缩略图失败，重新生成: %v", f.Name, err)
+	}
+	pageID, err, _ := thumbG.Do(strconv.Itoa(f.ID), func() (string, error) {
+		return d.createThumb(ctx, f)
+	})
+	if err != nil {
+		return nil, err
+	}
+	if d.hotCache != nil {
+		return d.hotThumbLink(ctx, pageID)
+	}
+	property, err := d.notionClient.GetPageProperty(pageID, d.NotionFilePageID)
+	if err != nil {
+		return nil, fmt.Errorf("获取缩略图URL失败: %w", err)
+	}
+	if len(property.Files) == 0 {
+		return nil, fmt.Errorf("缩略图页面没有文件")
+	}
+	return d.fileLink(property.Files[0].File.URL), nil
+}
+
+// createThumb 生成缩略图并上传，返回缩略图页面ID
+func (d *Notion) createThumb(ctx context.Context, f *File) (string, error) {
+	var src io.Reader
+	if utils.GetFileType(f.Name) == conf.VIDEO {
+		buf, err := d.videoSnapshot(ctx, f)
+		if err != nil {
+			return "", fmt.Errorf("视频截图失败: %w", err)
+		}
+		src = buf
+	} else {
+		rc, err := d.openImage(ctx, f)
+		if err != nil {
+			return "", fmt.Errorf("读取图片失败: %w", err)
+		}
+		defer rc.Close()
+		src = rc
+	}
+	img, err := imaging.Decode(src, imaging.AutoOrientation(true))
+	if err != nil {
+		return "", fmt.Errorf("解码图片失败: %w", err)
+	}
+	var buf bytes.Buffer
+	if err = imaging.Encode(&buf, imaging.Resize(img, thumbWidth, 0, imaging.Lanczos), imaging.PNG); err != nil {
+		return "", fmt.Errorf("编码缩略图失败: %w", err)
+	}
+
+	title := d.pageTitle(f.Name + ".thumb.png")
+	pageID, err := d.notionClient.CreateDatabasePage(title)
+	if err != nil {
+		return "", fmt.Errorf("创建缩略图页面失败: %w", err)
+	}
+	stream := &ChunkFileStream{
+		Reader:   bytes.NewReader(buf.Bytes()),
+		name:     title,
+		size:     int64(buf.Len()),
+		mimetype: "image/png",
+	}
+	if _, err := d.notionClient.UploadAndUpdateFilePut(ctx, stream, pageID, func(float64) {}); err != nil {
+		return "", fmt.Errorf("上传缩略图失败: %w", err)
+	}
+	if err := d.db.Model(&File{}).Where("id = ?", f.ID).Update("thumb_page_id", pageID).Error; err != nil {
+		return "", fmt.Errorf("保存缩略图信息失败: %w", err)
+	}
+	// 旧的缩略图页面不再被引用
+	if f.ThumbKey != "" {
+		d.archivePages([]string{f.ThumbKey})
+	}
+	return pageID, nil
+}
+
+// openImage 打开图片文件的内容，分块或过大的图片不整张下载
+func (d *Notion) openImage(ctx context.Context, f *File) (io.ReadCloser, error) {
+	if f.IsChunked || f.Size > thumbMaxImageSize {
+		return nil, fmt.Errorf("图片过大")
+	}
+	if f.IsInline() {
+		return io.NopCloser(bytes.NewReader(f.Inline)), nil
+	}
+	return d.notionClient.OpenPageAttachment(ctx, f.BlobKey, f.BlobIndex, 0, 0)
+}
+
+// videoSnapshot 使用ffmpeg从视频的下载地址截取一帧，分块视频只截取第一个分块
+func (d *Notion) videoSnapshot(ctx context.Context, f *File) (*bytes.Buffer, error) {
+	if f.IsInline() {
+		return nil, fmt.Errorf("视频保存在数据库中，不支持截图")
+	}
+	pageID, index := f.BlobKey, f.BlobIndex
+	if f.IsChunked {
+		var chunk FileChunk
+		if err := d.db.Where("file_id = ? AND deleted = ?", f.ID, false).Order("chunk_index").First(&chunk).Error; err != nil {
+			return nil, fmt.Errorf("获取文件分块信息失败: %w", err)
+		}
+		pageID, index = chunk.BlobKey, 0
+	}
+	url, err := d.notionClient.PageFileURL(pageID, index)
+	if err != nil {
+		return nil, fmt.Errorf("获取文件URL失败: %w", err)
+	}
+
+	ss := "0"
+	if probe, err := ffmpeg.Probe(url); err == nil {
+		var data struct {
+			Format struct {
+				Duration string `json:"duration"`
+			} `json:"format"`
+		}
+		if json.Unmarshal([]byte(probe), &data) == nil {
+			if duration, err := strconv.ParseFloat(data.Format.Duration, 64); err == nil {
+				ss = fmt.Sprintf("%f", duration*thumbVideoPos)
+			}
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	err = ffmpeg.Input(url, ffmpeg.KwArgs{"ss": ss, "noaccurate_seek": ""}).
+		Output("pipe:", ffmpeg.KwArgs{"vframes": 1, "format": "image2", "vcodec": "mjpeg"}).
+		GlobalArgs("-loglevel", "error").Silent(true).
+		WithOutput(buf).Run()
+	if err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
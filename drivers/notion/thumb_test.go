@@ -0,0 +1,82 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func testPNG(t *testing.T, width, height int) []byte {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewNRGBA(image.Rect(0, 0, width, height))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestImageThumbnail(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) { d.Thumbnail = true })
+	ctx := context.Background()
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.png", testPNG(t, 400, 300)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	link, err := d.Link(ctx, obj, model.LinkArgs{Type: "thumb"})
+	if err != nil {
+		t.Fatalf("thumb link: %v", err)
+	}
+	img, err := png.Decode(bytes.NewReader(readURL(t, link)))
+	if err != nil {
+		t.Fatalf("decode thumbnail: %v", err)
+	}
+	if b := img.Bounds(); b.Dx() != thumbWidth || b.Dy() != thumbWidth*300/400 {
+		t.Fatalf("expect a %dx%d thumbnail, got %v", thumbWidth, thumbWidth*300/400, b)
+	}
+	// 缩略图只生成一次，保存在单独的页面中
+	pages := fake.livePages()
+	if pages != 2 {
+		t.Fatalf("expect a page for the thumbnail, got %d pages", pages)
+	}
+	if _, err := d.Link(ctx, obj, model.LinkArgs{Type: "thumb"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.livePages(); n != pages {
+		t.Fatalf("expect the thumbnail reused, got %d pages", n)
+	}
+}
+
+func TestThumbnailDisabled(t *testing.T) {
+	for name, configure := range map[string]func(d *Notion){
+		"off": nil,
+		// 加密的文件不生成明文缩略图
+		"encrypted": func(d *Notion) {
+			d.Thumbnail = true
+			d.EncryptionKey = "key"
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			fake := newFakeNotion(t)
+			d := newTestNotion(t, fake, configure)
+			if d.thumbURL(context.Background(), "/", "a.png") != "" {
+				t.Fatal("expect no thumbnail url")
+			}
+			data := testPNG(t, 400, 300)
+			obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.png", data), func(float64) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			pages := fake.livePages()
+			if _, err := d.Link(context.Background(), obj, model.LinkArgs{Type: "thumb"}); err != nil {
+				t.Fatal(err)
+			}
+			if n := fake.livePages(); n != pages {
+				t.Fatalf("expect no thumbnail page, got %d pages", n-pages)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package notion
+
+import (
+	"io"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer 未配置导出时为空实现，开启tracing后span发送到OTLP接收端
+var tracer = otel.Tracer("github.com/alist-org/alist/v3/drivers/notion")
+
+// endSpan 结束span，err不为nil时记录为失败
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// tracedReadCloser 在关闭时结束span并记录读取的字节数，
+// span覆盖整个读取过程，流式读取中的停顿体现为span的耗时
+type tracedReadCloser struct {
+	io.ReadCloser
+	span   trace.Span
+	n      int64
+	err    error
+	closed bool
+}
+
+func traceRead(rc io.ReadCloser, span trace.Span) io.ReadCloser {
+	return &tracedReadCloser{ReadCloser: rc, span: span}
+}
+
+func (r *tracedReadCloser) Read(p []byte) (int, error) {
+	n, err := r.ReadCloser.Read(p)
+	r.n += int64(n)
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+func (r *tracedReadCloser) Close() error {
+	err := r.ReadCloser.Close()
+	if !r.closed {
+		r.closed = true
+		r.span.SetAttributes(attribute.Int64("bytes", r.n))
+		endSpan(r.span, r.err)
+	}
+	return err
+}
@@ -0,0 +1,128 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// recordSpans 将tracer替换为记录span的实现，测试结束后恢复
+func recordSpans(t *testing.T) *tracetest.SpanRecorder {
+	sr := tracetest.NewSpanRecorder()
+	old := tracer
+	tracer = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)).Tracer("test")
+	t.Cleanup(func() { tracer = old })
+	return sr
+}
+
+// endedSpans 返回名称为name的已结束span
+func endedSpans(sr *tracetest.SpanRecorder, name string) []sdktrace.ReadOnlySpan {
+	var spans []sdktrace.ReadOnlySpan
+	for _, s := range sr.Ended() {
+		if s.Name() == name {
+			spans = append(spans, s)
+		}
+	}
+	return spans
+}
+
+func spanAttr(s sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range s.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing(t *testing.T) {
+	sr := recordSpans(t)
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	data := testData(3 * 1024 * 1024)
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("big.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	puts := endedSpans(sr, "notion.Put")
+	if len(puts) != 1 || spanAttr(puts[0], "file_id").AsString() != obj.GetID() {
+		t.Fatalf("expect a Put span of file %s, got %d spans", obj.GetID(), len(puts))
+	}
+	put := puts[0].SpanContext()
+	// 分块上传和其中的请求都是Put的子span
+	chunks := endedSpans(sr, "notion.chunk.upload")
+	if len(chunks) < 2 {
+		t.Fatalf("expect a span per chunk, got %d", len(chunks))
+	}
+	for _, s := range chunks {
+		if s.Parent().SpanID() != put.SpanID() {
+			t.Fatalf("expect chunk %d uploaded in the Put span", spanAttr(s, "chunk.index").AsInt64())
+		}
+	}
+	requests := endedSpans(sr, "PUT s3")
+	if len(requests) < len(chunks) {
+		t.Fatalf("expect a span per request, got %d for %d chunks", len(requests), len(chunks))
+	}
+	for _, s := range requests {
+		if s.SpanContext().TraceID() != put.TraceID() || spanAttr(s, "http.status").AsString() != "200" {
+			t.Fatalf("expect the request traced in the Put, got %v", s.Attributes())
+		}
+	}
+
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(endedSpans(sr, "notion.Link")) != 1 {
+		t.Fatal("expect a Link span")
+	}
+	if got := readRange(t, link, 10, 100); !bytes.Equal(got, data[10:110]) {
+		t.Fatal("content differs")
+	}
+	// 读取的span在关闭后结束，记录读取的字节数
+	reads := endedSpans(sr, "notion.chunk.read")
+	if len(reads) != 1 || spanAttr(reads[0], "bytes").AsInt64() != 100 || spanAttr(reads[0], "offset").AsInt64() != 10 {
+		t.Fatalf("expect a span of the 100 bytes read, got %d spans", len(reads))
+	}
+}
+
+func TestTracingError(t *testing.T) {
+	sr := recordSpans(t)
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	fake.failNext(http.MethodPut, "/s3/", http.StatusForbidden, 1)
+	if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(10)), func(float64) {}); err == nil {
+		t.Fatal("expect the put to fail")
+	}
+	puts := endedSpans(sr, "notion.Put")
+	if len(puts) != 1 || puts[0].Status().Code != codes.Error || len(puts[0].Events()) == 0 {
+		t.Fatalf("expect the Put span failed with the error recorded, got %+v", puts)
+	}
+	requests := endedSpans(sr, "PUT s3")
+	if len(requests) != 1 || spanAttr(requests[0], "http.status").AsString() != "403" {
+		t.Fatalf("expect the request span with status 403, got %d spans", len(requests))
+	}
+}
+
+// TestNoTracing 请求不在span中时不创建请求的span
+func TestNoTracing(t *testing.T) {
+	sr := recordSpans(t)
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	if _, err := d.notionClient.PageFileURL("no-such-page", 0); err == nil {
+		t.Fatal("expect an unknown page to fail")
+	}
+	if n := len(sr.Ended()); n != 0 {
+		t.Fatalf("expect no span, got %d", n)
+	}
+}
@@ -1,14 +1,16 @@
 package notion
 
 import (
-	"context"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
+	"github.com/alist-org/alist/v3/internal/dbfs"
 	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/stream"
 	"github.com/alist-org/alist/v3/pkg/http_range"
 	"github.com/alist-org/alist/v3/pkg/utils"
 )
@@ -20,6 +22,21 @@ type NotionService struct {
 	databaseID string
 	filePageID string
 	userId     string
+	// uploadLimit 存储的上传限速，nil表示不限速
+	uploadLimit stream.Limiter
+	// storageClass和tagging非空时替换上传表单中Notion返回的值，PUT上传时作为请求头发送
+	storageClass string
+	tagging      string
+	// uploadThreads 大于1时超过一个分片的附件通过文件上传接口分片并发上传
+	uploadThreads int
+	// lang 返回的错误信息的语言
+	lang language
+	// api 发送Notion API请求的客户端，使用存储的API限速器
+	api *http.Client
+	// apiBase、publicAPIBase和s3Base 网页端接口、公开接口和S3的地址，末尾没有斜杠，s3Base除外
+	apiBase       string
+	publicAPIBase string
+	s3Base        string
 }
 
 type FileInfo struct {
@@ -33,43 +50,90 @@ type FileInfo struct {
 	UpdatedAt    time.Time `json:"updated_at"`
 }
 
-type Directory struct {
-	ID         int       `json:"id" gorm:"primaryKey"`
-	Name       string    `json:"name"`
-	ParentID   *int      `json:"parent_id" gorm:"index"`
-	DatabaseID string    `json:"database_id" gorm:"index"`
-	Deleted    bool      `json:"deleted" gorm:"default:false"`
-	CreatedAt  time.Time `json:"created_at"`
-	UpdatedAt  time.Time `json:"updated_at"`
-}
-
-type File struct {
-	ID           int       `json:"id" gorm:"primaryKey"`
-	Name         string    `json:"name"`
-	Size         int64     `json:"size"`
-	SHA1         string    `json:"sha1" gorm:"index"`
-	NotionPageID string    `json:"notion_page_id"`
-	DirectoryID  int       `json:"directory_id" gorm:"index"`
-	IsChunked    bool      `json:"is_chunked" gorm:"default:false"`
-	ChunkSize    int64     `json:"chunk_size" gorm:"default:0"`
-	Deleted      bool      `json:"deleted" gorm:"default:false"`
-	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-}
+// 目录树、文件、分块和历史版本由dbfs维护，Notion页面作为文件和分块的数据块
+type (
+	Directory   = dbfs.Directory
+	File        = dbfs.File
+	FileChunk   = dbfs.FileChunk
+	FileVersion = dbfs.FileVersion
+	FileTag     = dbfs.FileTag
+	Alias       = dbfs.Alias
+)
 
-// FileChunk 存储文件分块信息
-type FileChunk struct {
+// Snapshot 存储元数据快照，Data为该存储下目录、文件、分块和历史版本记录的JSON
+type Snapshot struct {
+	ID         int    `json:"id" gorm:"primaryKey"`
+	DatabaseID string `json:"database_id" gorm:"index"`
+	Name       string `json:"name"`
+	// Auto 定时创建的快照，超出保留数量时被删除
+	Auto      bool      `json:"auto"`
+	Data      string    `json:"-" gorm:"type:longtext"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// UploadSession 分多次请求上传的大文件，完成前分块只记录在Parts中，过期后由后台清理
+type UploadSession struct {
+	ID          string    `json:"id" gorm:"primaryKey;size:32"`
+	DatabaseID  string    `json:"database_id" gorm:"index"`
+	DirectoryID int       `json:"directory_id"`
+	Name        string    `json:"name"`
+	Size        int64     `json:"size"`
+	PartSize    int64     `json:"part_size"`
+	Parts       string    `json:"parts" gorm:"type:longtext"` // 已收到的分块，JSON对象，key为分块序号
+	ExpiresAt   time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PackPage 打包保存多个小文件的页面，每个文件是页面文件属性中的一个附件，
+// Attachments为全部附件的JSON数组，顺序与文件的BlobIndex对应
+type PackPage struct {
+	ID          int       `json:"id" gorm:"primaryKey"`
+	DatabaseID  string    `json:"database_id" gorm:"index"`
+	PageID      string    `json:"page_id" gorm:"uniqueIndex;size:64"`
+	Count       int       `json:"count"`
+	Attachments string    `json:"attachments" gorm:"type:longtext"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ParityChunk 分块文件的一个Reed-Solomon校验分块，文件的分块按顺序每DataShards个为一组，
+// 每组有ParityShards个校验分块，ChunkIndex从组序号*ParityShards开始；加密时校验分块同样加密
+type ParityChunk struct {
 	ID           int       `json:"id" gorm:"primaryKey"`
 	FileID       int       `json:"file_id" gorm:"index"`
+	DataShards   int       `json:"data_shards"`
+	ParityShards int       `json:"parity_shards"`
 	ChunkIndex   int       `json:"chunk_index"`
 	ChunkSize    int64     `json:"chunk_size"`
-	StartOffset  int64     `json:"start_offset"`
-	EndOffset    int64     `json:"end_offset"`
-	NotionPageID string    `json:"notion_page_id"`
+	BlobKey      string    `json:"notion_page_id" gorm:"column:notion_page_id;index"`
 	SHA1         string    `json:"sha1"`
-	Deleted      bool      `json:"deleted" gorm:"default:false"`
+	Nonce        string    `json:"nonce"`
+	KeyID        string    `json:"key_id"`
 	CreatedAt    time.Time `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// SecondaryPage 页面在备用数据库中的镜像，PageID为主数据库或分块数据库中的页面
+type SecondaryPage struct {
+	ID              int       `json:"id" gorm:"primaryKey"`
+	PageID          string    `json:"page_id" gorm:"uniqueIndex;size:64"`
+	SecondaryPageID string    `json:"secondary_page_id"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+// PageAttachment 页面文件属性中的一个附件
+type PageAttachment struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// AuditLog 记录对元数据的修改操作，Notion中没有这些操作的历史
+type AuditLog struct {
+	ID         int       `json:"id" gorm:"primaryKey"`
+	DatabaseID string    `json:"-" gorm:"index"`
+	Op         string    `json:"op"`
+	UserName   string    `json:"user_name"`
+	OldPath    string    `json:"old_path"`
+	NewPath    string    `json:"new_path"`
+	CreatedAt  time.Time `json:"created_at" gorm:"index"`
 }
 
 type NotionFile struct {
@@ -83,6 +147,35 @@ type PropertyResponse struct {
 	Files  []FileObject `json:"files"`
 }
 
+// QueryDatabaseResponse 查询数据库的一页结果
+type QueryDatabaseResponse struct {
+	Results    []QueriedPage `json:"results"`
+	HasMore    bool          `json:"has_more"`
+	NextCursor string        `json:"next_cursor"`
+}
+
+// QueriedPage 查询结果中的页面，只解析rich_text类型的属性
+type QueriedPage struct {
+	ID          string                   `json:"id"`
+	CreatedTime time.Time                `json:"created_time"`
+	Properties  map[string]RichTextValue `json:"properties"`
+}
+
+type RichTextValue struct {
+	RichText []struct {
+		PlainText string `json:"plain_text"`
+	} `json:"rich_text"`
+}
+
+// Text 拼接属性的全部文本
+func (v RichTextValue) Text() string {
+	var sb strings.Builder
+	for _, t := range v.RichText {
+		sb.WriteString(t.PlainText)
+	}
+	return sb.String()
+}
+
 type FileObject struct {
 	Type string     `json:"type"`
 	Name string     `json:"name"`
@@ -196,6 +289,14 @@ type TextContent struct {
 	Content string `json:"content"`
 }
 
+type UpdatePageRequest struct {
+	Properties Properties `json:"properties"`
+}
+
+type ArchivePageRequest struct {
+	Archived bool `json:"archived"`
+}
+
 type CreatePageResponse struct {
 	ID         string     `json:"id"`
 	Parent     Parent     `json:"parent"`
@@ -208,6 +309,7 @@ type ChunkFileStream struct {
 	name     string
 	size     int64
 	mimetype string
+	hash     utils.HashInfo
 }
 
 func (c *ChunkFileStream) GetName() string {
@@ -246,7 +348,7 @@ func (c *ChunkFileStream) CreateTime() time.Time {
 }
 
 func (c *ChunkFileStream) GetHash() utils.HashInfo {
-	return utils.HashInfo{}
+	return c.hash
 }
 
 func (c *ChunkFileStream) IsDir() bool {
@@ -283,215 +385,17 @@ func (c *ChunkFileStream) GetFile() model.File {
 	return nil
 }
 
-// ChunkedRangeReadCloser 处理分块文件的Range请求
-type ChunkedRangeReadCloser struct {
-	notionClient *NotionService
-	chunks       []FileChunk
-	fileSize     int64
-	utils.Closers
-}
-
-func NewChunkedRangeReadCloser(notionClient *NotionService, chunks []FileChunk, fileSize int64) *ChunkedRangeReadCloser {
-	return &ChunkedRangeReadCloser{
-		notionClient: notionClient,
-		chunks:       chunks,
-		fileSize:     fileSize,
-		Closers:      utils.EmptyClosers(),
-	}
-}
-
-func (c *ChunkedRangeReadCloser) RangeRead(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
-	// 边界检查
-	if httpRange.Start < 0 {
-		return nil, fmt.Errorf("invalid range start: %d", httpRange.Start)
-	}
-	if httpRange.Start >= c.fileSize {
-		return nil, fmt.Errorf("range start %d exceeds file size %d", httpRange.Start, c.fileSize)
-	}
-
-	if httpRange.Length == -1 {
-		httpRange.Length = c.fileSize - httpRange.Start
-	}
-
-	// 确保请求范围不超过文件大小
-	requestEnd := httpRange.Start + httpRange.Length
-	if requestEnd > c.fileSize {
-		httpRange.Length = c.fileSize - httpRange.Start
-		requestEnd = c.fileSize
-	}
-
-	// 找到需要的分块
-	var neededChunks []FileChunk
-	for _, chunk := range c.chunks {
-		// 检查分块是否与请求范围重叠
-		if chunk.StartOffset < requestEnd && chunk.EndOffset > httpRange.Start {
-			neededChunks = append(neededChunks, chunk)
-		}
-	}
-
-	if len(neededChunks) == 0 {
-		return nil, fmt.Errorf("no chunks found for range %d-%d (file size: %d, total chunks: %d)",
-			httpRange.Start, requestEnd-1, c.fileSize, len(c.chunks))
-	}
-
-	return &ChunkedReader{
-		notionClient: c.notionClient,
-		chunks:       neededChunks,
-		requestStart: httpRange.Start,
-		requestEnd:   requestEnd,
-		currentChunk: 0,
-	}, nil
-}
-
-// ChunkedReader 实现跨分块的流式读取
-type ChunkedReader struct {
-	notionClient  *NotionService
-	chunks        []FileChunk
-	requestStart  int64
-	requestEnd    int64
-	currentChunk  int
-	currentReader io.ReadCloser
-	currentOffset int64
-	totalRead     int64
-}
-
-func (r *ChunkedReader) Read(p []byte) (n int, err error) {
-	if r.totalRead >= r.requestEnd-r.requestStart {
-		return 0, io.EOF
-	}
-
-	// 如果当前没有reader或者当前reader已读完，切换到下一个分块
-	if r.currentReader == nil {
-		if r.currentChunk >= len(r.chunks) {
-			return 0, io.EOF
-		}
-
-		chunk := r.chunks[r.currentChunk]
-
-		// 计算在当前分块中的读取范围
-		chunkStart := max(r.requestStart, chunk.StartOffset) - chunk.StartOffset
-		chunkEnd := min(r.requestEnd, chunk.EndOffset) - chunk.StartOffset
-
-		// 重试逻辑：最多重试3次
-		var reader io.ReadCloser
-		maxRetries := 3
-		for retry := 0; retry < maxRetries; retry++ {
-			// 获取分块的下载链接
-			property, err := r.notionClient.GetPageProperty(chunk.NotionPageID, r.notionClient.filePageID)
-			if err != nil {
-				if retry == maxRetries-1 {
-					return 0, fmt.Errorf("获取分块%d下载链接失败(重试%d次): %v", r.currentChunk, retry+1, err)
-				}
-				time.Sleep(time.Second * time.Duration(retry+1)) // 递增延迟
-				continue
-			}
-
-			if len(property.Files) == 0 {
-				return 0, fmt.Errorf("分块%d没有文件", r.currentChunk)
-			}
-
-			// 创建HTTP请求获取分块数据
-			reader, err = r.createChunkReader(property.Files[0].File.URL, chunkStart, chunkEnd-chunkStart)
-			if err != nil {
-				if retry == maxRetries-1 {
-					return 0, fmt.Errorf("创建分块%d读取器失败(重试%d次): %v", r.currentChunk, retry+1, err)
-				}
-				time.Sleep(time.Second * time.Duration(retry+1)) // 递增延迟
-				continue
-			}
-
-			// 成功创建reader，跳出重试循环
-			break
-		}
-
-		r.currentReader = reader
-		r.currentOffset = chunkStart
-	}
-
-	// 从当前reader读取数据
-	remainingBytes := r.requestEnd - r.requestStart - r.totalRead
-	if int64(len(p)) > remainingBytes {
-		p = p[:remainingBytes]
-	}
-
-	n, err = r.currentReader.Read(p)
-	r.totalRead += int64(n)
-
-	// 处理读取错误和EOF
-	if err != nil {
-		if err == io.EOF {
-			r.currentReader.Close()
-			r.currentReader = nil
-			r.currentChunk++
-
-			// 如果还有更多数据要读取，继续下一个分块
-			if r.totalRead < r.requestEnd-r.requestStart && r.currentChunk < len(r.chunks) {
-				err = nil
-			}
-		} else {
-			// 非EOF错误，关闭当前reader，下次读取时会重新创建
-			r.currentReader.Close()
-			r.currentReader = nil
-		}
-	}
-
-	return n, err
-}
-
-func (r *ChunkedReader) Close() error {
-	if r.currentReader != nil {
-		return r.currentReader.Close()
-	}
-	return nil
-}
-
-func (r *ChunkedReader) createChunkReader(url string, offset, length int64) (io.ReadCloser, error) {
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("创建HTTP请求失败: %v", err)
-	}
-
-	// 设置Range头
-	if length > 0 {
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
-	} else {
-		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
-	}
-
-	// 设置User-Agent和其他必要的头部
-	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36")
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Accept-Encoding", "identity")
-
-	// 创建带超时的HTTP客户端，适合大文件下载
-	client := &http.Client{
-		Timeout: time.Minute * 30, // 30分钟超时，适合大文件分块下载
-	}
-
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("发送HTTP请求失败: %v", err)
-	}
-
-	// 检查状态码，206是部分内容，200是完整内容
-	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
-		resp.Body.Close()
-		return nil, fmt.Errorf("HTTP请求失败，状态码: %d, URL: %s", resp.StatusCode, url)
-	}
-
-	return resp.Body, nil
+// uploadFileStream 以指定的名称和类型上传文件，用于文件名混淆和修正ContentType
+type uploadFileStream struct {
+	model.FileStreamer
+	name     string
+	mimetype string
 }
 
-func max(a, b int64) int64 {
-	if a > b {
-		return a
-	}
-	return b
+func (s *uploadFileStream) GetName() string {
+	return s.name
 }
 
-func min(a, b int64) int64 {
-	if a < b {
-		return a
-	}
-	return b
+func (s *uploadFileStream) GetMimetype() string {
+	return s.mimetype
 }
@@ -0,0 +1,78 @@
+package notion
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+)
+
+// userMethods 开启用户目录时非管理员用户可以执行的Other方法，只作用于请求路径上的文件；
+// 其余方法作用于整个存储，只有管理员可以执行
+var userMethods = map[string]bool{
+	"list_versions":   true,
+	"restore_version": true,
+	"delete_version":  true,
+	"verify":          true,
+	"regen_link":      true,
+	"list_chunks":     true,
+	"get_tags":        true,
+	"set_tags":        true,
+	"create_share":    true,
+	"rehash":          true,
+}
+
+// UserScope 开启用户目录时返回请求用户的用户名，管理员和没有用户的请求（如后台任务）返回空
+func (d *Notion) UserScope(ctx context.Context) string {
+	if !d.UserHome {
+		return ""
+	}
+	user, ok := ctx.Value("user").(*model.User)
+	if !ok || user.IsAdmin() {
+		return ""
+	}
+	return user.Username
+}
+
+// HasUserScopes 开启用户目录时不同用户看到不同的目录树
+func (d *Notion) HasUserScopes() bool {
+	return d.UserHome
+}
+
+// homeName 用户目录的名称，用户名中的/替换为_
+func (d *Notion) homeName(username string) string {
+	return d.tree.NormName(strings.ReplaceAll(username, "/", "_"))
+}
+
+// GetRoot 开启用户目录时非管理员用户的根目录为存储根目录下以用户名命名的目录，不存在时创建；
+// 其他情况为存储的根目录
+func (d *Notion) GetRoot(ctx context.Context) (model.Obj, error) {
+	username := d.UserScope(ctx)
+	if username == "" {
+		return &model.Object{
+			ID:       d.RootFolderID,
+			Name:     op.RootName,
+			Modified: d.Modified,
+			IsFolder: true,
+		}, nil
+	}
+	rootID, _ := strconv.Atoi(d.RootFolderID)
+	dir, err := d.tree.MakeDir(rootID, d.homeName(username))
+	if err != nil {
+		return nil, fmt.Errorf("创建用户%s的目录失败: %w", username, err)
+	}
+	return dbfs.DirToObj(dir), nil
+}
+
+// checkUserMethod 开启用户目录时非管理员用户只能执行userMethods中的方法
+func (d *Notion) checkUserMethod(ctx context.Context, method string) error {
+	if d.UserScope(ctx) == "" || userMethods[method] {
+		return nil
+	}
+	return fmt.Errorf("开启用户目录时只有管理员可以执行%s: %w", method, errs.PermissionDenied)
+}
@@ -3,11 +3,15 @@ package notion
 import (
 	"bytes"
 	"context"
+	"crypto/md5"
 	"crypto/sha1"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"hash"
 	"io"
+	"mime"
 	"mime/multipart"
 	"net/http"
 	"net/url"
@@ -18,20 +22,33 @@ import (
 	"time"
 
 	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/fs"
 	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
 	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// 默认的接口地址，存储可以改为代理、镜像或测试服务的地址
 const (
+	// NotionAPIBaseURL Notion网页端使用的接口，用于获取上传地址和更新附件
 	NotionAPIBaseURL = "https://www.notion.so/api/v3"
-	S3BaseURL        = "https://prod-files-secure.s3.us-west-2.amazonaws.com/"
+	// NotionPublicAPIBaseURL Notion公开接口，用于页面、数据库和分片上传
+	NotionPublicAPIBaseURL = "https://api.notion.com/v1"
+	// S3BaseURL 表单上传的S3地址
+	S3BaseURL = "https://prod-files-secure.s3.us-west-2.amazonaws.com/"
+	// putRetries 签名URL上传失败后的重试次数
+	putRetries = 3
 )
 
 func NewNotionService(cookie, token, spaceID, databaseID string, filePageID string) *NotionService {
 	//从cookie中获取userId
 	userId := extractUserID(cookie)
 	if userId == "" {
-		fmt.Println("无法从cookie中提取userId")
+		log.Debugf("无法从cookie中提取userId")
 		return nil
 	}
 	// 创建 NotionService 实例
@@ -42,9 +59,21 @@ func NewNotionService(cookie, token, spaceID, databaseID string, filePageID stri
 		databaseID: databaseID,
 		filePageID: filePageID,
 		userId:     userId,
+		api:        apiClient,
+
+		apiBase:       NotionAPIBaseURL,
+		publicAPIBase: NotionPublicAPIBaseURL,
+		s3Base:        S3BaseURL,
 	}
 }
 
+// setBaseURLs 使用存储设置的接口地址，为空时使用默认地址
+func (s *NotionService) setBaseURLs(api, publicAPI, s3 string) {
+	s.apiBase = strings.TrimSuffix(utils.GetNoneEmpty(api, NotionAPIBaseURL), "/")
+	s.publicAPIBase = strings.TrimSuffix(utils.GetNoneEmpty(publicAPI, NotionPublicAPIBaseURL), "/")
+	s.s3Base = utils.GetNoneEmpty(s3, S3BaseURL)
+}
+
 // extractUserID 从cookie字符串中提取 notion_user_id
 func extractUserID(cookie string) string {
 	// 查找 "notion_user_id=" 后面的部分
@@ -102,12 +131,12 @@ func (s *NotionService) CreateDatabasePage(title string) (string, error) {
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return "", fmt.Errorf("序列化请求体失败: %v", err)
+		return "", s.lang.errorf(msgMarshalBody, err)
 	}
 
-	req, err := http.NewRequest("POST", "https://api.notion.com/v1/pages", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", s.publicAPIBase+"/pages", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %v", err)
+		return "", s.lang.errorf(msgNewRequest, err)
 	}
 
 	// 设置 Notion API 特定的请求头
@@ -115,29 +144,85 @@ func (s *NotionService) CreateDatabasePage(title string) (string, error) {
 	req.Header.Set("Notion-Version", "2022-06-28")
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.api.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		return "", s.lang.errorf(msgSendRequest, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("创建页面失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return "", s.lang.apiError(msgAPICreatePage, resp.StatusCode, string(body))
 	}
 
 	body, _ := io.ReadAll(resp.Body)
 	var page CreatePageResponse
 	err = json.Unmarshal(body, &page)
 	if err != nil {
-		return "", fmt.Errorf("解析响应体失败: %v", err)
+		return "", s.lang.errorf(msgDecodeResponse, err)
 	}
-	fmt.Printf("创建页面成功，页面ID: %s\n", page.ID)
-	fmt.Printf("页面创建成功，状态码: %d\n", resp.StatusCode)
+	log.Debugf("创建页面成功，页面ID: %s", page.ID)
 	return page.ID, nil
 }
 
+// UpdatePageTitle 更新数据库页面的标题
+func (s *NotionService) UpdatePageTitle(pageID string, title string) error {
+	reqBody := UpdatePageRequest{
+		Properties: Properties{
+			Title: TitleProperty{
+				Title: []TitleText{
+					{
+						Text: TextContent{
+							Content: title,
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if err := s.patchPage(pageID, reqBody); err != nil {
+		return s.lang.errorf(msgUpdateTitle, err)
+	}
+	return nil
+}
+
+// ArchivePage 归档数据库页面，归档后的页面进入Notion回收站
+func (s *NotionService) ArchivePage(pageID string) error {
+	if err := s.patchPage(pageID, ArchivePageRequest{Archived: true}); err != nil {
+		return s.lang.errorf(msgArchivePage, err)
+	}
+	return nil
+}
+
+func (s *NotionService) patchPage(pageID string, reqBody interface{}) error {
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return s.lang.errorf(msgMarshalBody, err)
+	}
+
+	req, err := http.NewRequest("PATCH", s.publicAPIBase+"/pages/"+pageID, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return s.lang.errorf(msgNewRequest, err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Notion-Version", "2022-06-28")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return s.lang.errorf(msgSendRequest, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return s.lang.apiError(msgAPIRequest, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
 func (s *NotionService) UploadAndUpdateFile(filePath string, id string) error {
 	record := RecordInfo{
 		Table:   "block",
@@ -147,56 +232,92 @@ func (s *NotionService) UploadAndUpdateFile(filePath string, id string) error {
 	// 1. 上传文件到Notion
 	uploadResponse, err := s.UploadFile(filePath, record)
 	if err != nil {
-		return fmt.Errorf("上传文件失败: %v", err)
+		return s.lang.errorf(msgUploadFile, err)
 	}
 
 	// 2. 上传文件到S3
-	err = s.UploadToS3(filePath, uploadResponse.Fields)
+	file, err := os.Open(filePath)
+	if err != nil {
+		return s.lang.errorf(msgOpenFile, err)
+	}
+	defer file.Close()
+	fileInfo, err := file.Stat()
+	if err != nil {
+		return s.lang.errorf(msgGetFile, err)
+	}
+	err = s.UploadToS3(context.Background(), file, filepath.Base(filePath), fileInfo.Size(), uploadResponse.Fields, func(float64) {})
 	if err != nil {
-		return fmt.Errorf("上传到S3失败: %v", err)
+		return s.lang.errorf(msgUploadS3, err)
 	}
 
 	fileName := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filepath.Base(filePath)))
 	// 3. 更新文件状态
 	err = s.UpdateFileStatus(record, fileName, uploadResponse.URL)
 	if err != nil {
-		return fmt.Errorf("更新文件状态失败: %v", err)
+		return s.lang.errorf(msgUpdateFileStatus, err)
 	}
 
 	return nil
 }
 
-func (s *NotionService) UploadAndUpdateFilePut(file model.FileStreamer, id string, up driver.UpdateProgress) (string, error) {
-	record := RecordInfo{
-		Table:   "block",
-		ID:      id,
-		SpaceID: s.spaceID,
+func (s *NotionService) UploadAndUpdateFilePut(ctx context.Context, file model.FileStreamer, id string, up driver.UpdateProgress) (sum string, err error) {
+	ctx, span := tracer.Start(ctx, "notion.upload_attachment", trace.WithAttributes(
+		attribute.String("page_id", id), attribute.Int64("bytes", file.GetSize())))
+	defer func() { endSpan(span, err) }()
+	if s.uploadThreads > 1 && file.GetSize() > multiPartSize {
+		return s.UploadMultiPart(ctx, file, id, s.uploadThreads, up)
 	}
-	// 1. 上传文件到Notion
-	uploadResponse, err := s.UploadFilePut(file, record)
-	if err != nil {
-		return "", fmt.Errorf("上传文件失败: %v", err)
-	}
-
-	// 2. 上传文件到S3
-	hash1, err := s.UploadToS3Put(file, uploadResponse, up)
+	// 1-2. 上传文件到Notion和S3
+	fileURL, hash1, err := s.UploadAttachment(ctx, file, id, up)
 	if err != nil {
-		return "", fmt.Errorf("上传到S3失败: %v", err)
+		return "", err
 	}
 
 	fileName := file.GetName()
 	// 3. 更新文件状态
-	err = s.UpdateFileStatus(record, fileName, uploadResponse.URL)
+	err = s.UpdateFileStatus(s.pageRecord(id), fileName, fileURL)
 
 	// 4. 更新文件的SHA1值
 
 	if err != nil {
-		return "", fmt.Errorf("更新文件状态失败: %v", err)
+		return "", s.lang.errorf(msgUpdateFileStatus, err)
 	}
 
 	return hash1, nil
 }
 
+func (s *NotionService) pageRecord(id string) RecordInfo {
+	return RecordInfo{
+		Table:   "block",
+		ID:      id,
+		SpaceID: s.spaceID,
+	}
+}
+
+// UploadAttachment 上传文件到页面id所属的存储，返回附件地址和SHA-1，附件需要再通过UpdateFileStatus设置到页面上
+func (s *NotionService) UploadAttachment(ctx context.Context, file model.FileStreamer, id string, up driver.UpdateProgress) (string, string, error) {
+	uploadResponse, err := s.UploadFilePut(file, s.pageRecord(id))
+	if err != nil {
+		return "", "", s.lang.errorf(msgUploadFile, err)
+	}
+
+	// 没有signedPutUrl时回退到表单上传
+	var hash1 string
+	if uploadResponse.SignedPutUrl != "" {
+		hash1, err = s.UploadToS3Put(ctx, file, uploadResponse, up)
+	} else {
+		reader, sum := sha1Reader(file, file)
+		err = s.UploadToS3(ctx, reader, file.GetName(), file.GetSize(), uploadResponse.Fields, up)
+		if err == nil {
+			hash1, err = sum()
+		}
+	}
+	if err != nil {
+		return "", "", s.lang.errorf(msgUploadS3, err)
+	}
+	return uploadResponse.URL, hash1, nil
+}
+
 // GetContentType 根据文件后缀获取ContentType
 func GetContentType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -236,6 +357,9 @@ func GetContentType(filename string) string {
 	case ".xml":
 		return "application/xml"
 	default:
+		if t := mime.TypeByExtension(ext); t != "" {
+			return t
+		}
 		return "application/octet-stream"
 	}
 }
@@ -243,7 +367,7 @@ func GetContentType(filename string) string {
 func (s *NotionService) UploadFile(filePath string, recordInfo RecordInfo) (*UploadResponse, error) {
 	fileInfo, err := os.Stat(filePath)
 	if err != nil {
-		return nil, fmt.Errorf("无法读取文件: %v", err)
+		return nil, s.lang.errorf(msgOpenFile, err)
 	}
 	// 去除文件后缀
 	fileName := strings.TrimSuffix(fileInfo.Name(), filepath.Ext(fileInfo.Name()))
@@ -261,25 +385,27 @@ func (s *NotionService) UploadFile(filePath string, recordInfo RecordInfo) (*Upl
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", NotionAPIBaseURL+"/getUploadFileUrl", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", s.apiBase+"/getUploadFileUrl", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	s.setCommonHeaders(req)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.api.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("上传文件请求状态: %s\n", resp.Status)
+	log.Debugf("上传文件请求状态: %s", resp.Status)
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.lang.apiError(msgAPIGetUploadURL, resp.StatusCode, string(body))
+	}
 
 	var uploadResponse UploadResponse
 	err = json.Unmarshal(body, &uploadResponse)
@@ -306,25 +432,27 @@ func (s *NotionService) UploadFilePut(file model.FileStreamer, recordInfo Record
 		return nil, err
 	}
 
-	req, err := http.NewRequest("POST", NotionAPIBaseURL+"/getUploadFileUrl", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", s.apiBase+"/getUploadFileUrl", bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, err
 	}
 
 	s.setPutCommonHeaders(req)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.api.Do(req)
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
 
-	fmt.Printf("上传文件请求状态: %s\n", resp.Status)
+	log.Debugf("上传文件请求状态: %s", resp.Status)
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return nil, err
 	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, s.lang.apiError(msgAPIGetUploadURL, resp.StatusCode, string(body))
+	}
 
 	var uploadResponse UploadResponse
 	err = json.Unmarshal(body, &uploadResponse)
@@ -335,171 +463,301 @@ func (s *NotionService) UploadFilePut(file model.FileStreamer, recordInfo Record
 	return &uploadResponse, nil
 }
 
-func (s *NotionService) UploadToS3(filePath string, fields UploadFields) error {
-	file, err := os.Open(filePath)
-	if err != nil {
-		return fmt.Errorf("无法打开文件: %v", err)
+// UploadToS3 以表单方式流式上传到S3，表单由协程写入管道，读取文件失败等写入端的错误会与请求的错误一起返回
+func (s *NotionService) UploadToS3(ctx context.Context, reader io.Reader, fileName string, fileSize int64, fields UploadFields, up driver.UpdateProgress) error {
+	// 包装读取流，按字节上报进度
+	checker := newUploadChecker(io.LimitReader(reader, fileSize))
+	progressReader := &driver.ReaderUpdatingProgress{
+		Reader: &driver.SimpleReaderWithSize{
+			Reader: s.limitUpload(ctx, checker),
+			Size:   fileSize,
+		},
+		UpdateProgress: up,
 	}
-	defer file.Close()
 
-	// 获取文件大小
-	fileInfo, err := file.Stat()
-	if err != nil {
-		return fmt.Errorf("获取文件信息失败: %v", err)
+	if s.storageClass != "" {
+		fields.XAmzStorageClass = s.storageClass
+	}
+	if s.tagging != "" {
+		fields.Tagging = s.tagging
+	}
+	// 表单字段，按顺序写入
+	formFields := [][2]string{
+		{"Content-Type", fields.ContentType},
+		{"x-amz-storage-class", fields.XAmzStorageClass},
+		{"tagging", fields.Tagging},
+		{"bucket", fields.Bucket},
+		{"X-Amz-Algorithm", fields.XAmzAlgorithm},
+		{"X-Amz-Credential", fields.XAmzCredential},
+		{"X-Amz-Date", fields.XAmzDate},
+		{"X-Amz-Security-Token", fields.XAmzSecurityToken},
+		{"key", fields.Key},
+		{"Policy", fields.Policy},
+		{"X-Amz-Signature", fields.XAmzSignature},
 	}
-	fileSize := fileInfo.Size()
-	// 创建带限速的文件流
-	rateLimited := io.LimitReader(file, fileSize)
 
 	// 创建 pipe，实现边写边读
 	pr, pw := io.Pipe()
 	writer := multipart.NewWriter(pw)
 
-	// 计算 multipart 表单的边界长度
-	boundary := writer.Boundary()
-	boundaryPrefix := "--" + boundary + "\r\n"
-	boundarySuffix := "\r\n--" + boundary + "--\r\n"
-	boundaryLength := len(boundaryPrefix) + len(boundarySuffix)
-
-	// 计算表单字段的总长度
-	fieldsLength := 0
-	// 每个字段的格式: Content-Disposition: form-data; name="fieldname"\r\n\r\nvalue\r\n
-	fieldHeader := "Content-Disposition: form-data; name=\""
-	fieldFooter := "\"\r\n\r\n"
-	fieldEnd := "\r\n"
-
-	fieldsLength += len(fieldHeader + "Content-Type" + fieldFooter + fields.ContentType + fieldEnd)
-	fieldsLength += len(fieldHeader + "x-amz-storage-class" + fieldFooter + fields.XAmzStorageClass + fieldEnd)
-	fieldsLength += len(fieldHeader + "tagging" + fieldFooter + fields.Tagging + fieldEnd)
-	fieldsLength += len(fieldHeader + "bucket" + fieldFooter + fields.Bucket + fieldEnd)
-	fieldsLength += len(fieldHeader + "X-Amz-Algorithm" + fieldFooter + fields.XAmzAlgorithm + fieldEnd)
-	fieldsLength += len(fieldHeader + "X-Amz-Credential" + fieldFooter + fields.XAmzCredential + fieldEnd)
-	fieldsLength += len(fieldHeader + "X-Amz-Date" + fieldFooter + fields.XAmzDate + fieldEnd)
-	fieldsLength += len(fieldHeader + "X-Amz-Security-Token" + fieldFooter + fields.XAmzSecurityToken + fieldEnd)
-	fieldsLength += len(fieldHeader + "key" + fieldFooter + fields.Key + fieldEnd)
-	fieldsLength += len(fieldHeader + "Policy" + fieldFooter + fields.Policy + fieldEnd)
-	fieldsLength += len(fieldHeader + "X-Amz-Signature" + fieldFooter + fields.XAmzSignature + fieldEnd)
-
-	// 计算文件字段的头部长度
-	fileHeader := "Content-Disposition: form-data; name=\"file\"; filename=\"" + filepath.Base(filePath) + "\"\r\n"
-	fileHeader += "Content-Type: " + fields.ContentType + "\r\n\r\n"
-	fileHeaderLength := len(fileHeader)
-
-	// 计算总长度
-	totalLength := int64(boundaryLength+fieldsLength+fileHeaderLength) + fileSize
-
-	// 创建错误通道
-	errChan := make(chan error, 1)
+	// 请求长度为表单结构的长度加上文件大小，表单结构按相同的边界写入一个空文件得到
+	var form bytes.Buffer
+	formWriter := multipart.NewWriter(&form)
+	if err := formWriter.SetBoundary(writer.Boundary()); err != nil {
+		return s.lang.errorf(msgWriteUpload, err)
+	}
+	if err := writeS3Form(s.lang, formWriter, formFields, fileName, strings.NewReader("")); err != nil {
+		return s.lang.errorf(msgWriteUpload, err)
+	}
+	totalLength := int64(form.Len()) + fileSize
 
-	// 异步写入 multipart 数据
+	// 异步写入 multipart 数据，写入的结果通过errChan返回
+	errChan := make(chan error, 1)
 	go func() {
-		defer pw.Close()
-		defer file.Close()
-
-		// 写字段
-		writer.WriteField("Content-Type", fields.ContentType)
-		writer.WriteField("x-amz-storage-class", fields.XAmzStorageClass)
-		writer.WriteField("tagging", fields.Tagging)
-		writer.WriteField("bucket", fields.Bucket)
-		writer.WriteField("X-Amz-Algorithm", fields.XAmzAlgorithm)
-		writer.WriteField("X-Amz-Credential", fields.XAmzCredential)
-		writer.WriteField("X-Amz-Date", fields.XAmzDate)
-		writer.WriteField("X-Amz-Security-Token", fields.XAmzSecurityToken)
-		writer.WriteField("key", fields.Key)
-		writer.WriteField("Policy", fields.Policy)
-		writer.WriteField("X-Amz-Signature", fields.XAmzSignature)
-
-		// 写入文件字段
-		part, err := writer.CreateFormFile("file", filepath.Base(filePath))
-		if err != nil {
-			pw.CloseWithError(fmt.Errorf("创建文件字段失败: %v", err))
-			return
-		}
-
-		// 使用带限速的文件流
-		_, err = io.Copy(part, rateLimited)
-		if err != nil {
-			pw.CloseWithError(fmt.Errorf("复制文件内容失败: %v", err))
-			return
-		}
-
-		writer.Close()
+		err := writeS3Form(s.lang, writer, formFields, fileName, progressReader)
+		// err为nil时正常关闭，请求读到EOF；否则请求读取时得到该错误
+		pw.CloseWithError(err)
+		errChan <- err
 	}()
 
 	// 创建请求
-	req, err := http.NewRequestWithContext(context.Background(), "POST", S3BaseURL, pr)
+	req, err := http.NewRequestWithContext(ctx, "POST", s.s3Base, pr)
 	if err != nil {
-		return fmt.Errorf("创建请求失败: %v", err)
+		pr.Close()
+		<-errChan
+		return s.lang.errorf(msgNewRequest, err)
 	}
 
 	// 设置请求头
 	req.Header.Set("Content-Type", writer.FormDataContentType())
-	req.Header.Set("Content-Length", strconv.FormatInt(totalLength, 10))
+	req.ContentLength = totalLength
 
 	// 创建带超时的客户端
 	client := &http.Client{
-		Timeout: 30 * time.Minute, // 设置较长的超时时间，适合大文件上传
+		Transport: apiClient.Transport,
+		Timeout:   30 * time.Minute, // 设置较长的超时时间，适合大文件上传
 	}
 
 	// 发送请求
 	resp, err := client.Do(req)
+	// 服务端可能在读完请求体之前就返回，关闭读取端使写入协程退出
+	pr.Close()
+	writeErr := <-errChan
+	if errors.Is(writeErr, io.ErrClosedPipe) {
+		// 由上面关闭读取端导致，原因在请求的错误或响应中
+		writeErr = nil
+	}
+	if writeErr != nil {
+		writeErr = s.lang.errorf(msgWriteUpload, writeErr)
+	}
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %v", err)
+		return errors.Join(writeErr, s.lang.errorf(msgSendRequest, err))
 	}
 	defer resp.Body.Close()
 
-	// 检查是否有写入错误
-	select {
-	case err := <-errChan:
-		return err
-	default:
-	}
-
 	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("上传失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return errors.Join(writeErr, s.lang.apiError(msgAPIUpload, resp.StatusCode, string(body)))
+	}
+	if writeErr != nil {
+		return writeErr
+	}
+	if err := checker.verify(s.lang, fileSize, resp.Header); err != nil {
+		return s.lang.errorf(msgVerifyUpload, err)
 	}
 
-	fmt.Printf("文件上传成功，状态码: %d\n", resp.StatusCode)
+	log.Debugf("文件上传成功，状态码: %d", resp.StatusCode)
 	return nil
 }
 
-func (s *NotionService) UploadToS3Put(file model.FileStreamer, resp *UploadResponse, up driver.UpdateProgress) (string, error) {
-	// 创建 SHA-1 哈希计算器
-	hash := sha1.New()
-	tee := io.TeeReader(file, hash)
-	progress := driver.NewProgress(file.GetSize(), up)
-	tee1 := io.TeeReader(tee, progress)
-	req, err := http.NewRequest("PUT", resp.SignedPutUrl, tee1)
+// writeS3Form 写入表单字段和文件内容，返回的错误指明失败的步骤
+func writeS3Form(l language, writer *multipart.Writer, formFields [][2]string, fileName string, r io.Reader) error {
+	for _, field := range formFields {
+		if err := writer.WriteField(field[0], field[1]); err != nil {
+			return l.errorf(msgWriteField, field[0], err)
+		}
+	}
+	part, err := writer.CreateFormFile("file", fileName)
+	if err != nil {
+		return l.errorf(msgCreateFormFile, err)
+	}
+	// 边上传边上报进度
+	if _, err = io.Copy(part, r); err != nil {
+		return l.errorf(msgCopyContent, err)
+	}
+	if err = writer.Close(); err != nil {
+		return l.errorf(msgCloseForm, err)
+	}
+	return nil
+}
+
+// UploadToS3Put 通过签名URL以PUT方式上传，请求体不需要重新组装表单，
+// 文件已缓存到临时文件时可以重新读取，网络错误或服务端错误后重试
+func (s *NotionService) UploadToS3Put(ctx context.Context, file model.FileStreamer, resp *UploadResponse, up driver.UpdateProgress) (string, error) {
+	var err error
+	for retry := 0; retry <= putRetries; retry++ {
+		var body io.Reader = file
+		if f := file.GetFile(); f != nil {
+			body = io.NewSectionReader(f, 0, file.GetSize())
+		} else if retry > 0 {
+			// 流只能读取一次，无法重试
+			break
+		}
+		if retry > 0 {
+			retries.WithLabelValues("put").Inc()
+			log.Warnf("上传文件[%s]失败，第%d次重试: %v", file.GetName(), retry, err)
+			select {
+			case <-ctx.Done():
+				return "", ctx.Err()
+			case <-time.After(time.Duration(retry) * time.Second):
+			}
+		}
+		var hash string
+		var retryable bool
+		hash, retryable, err = s.putToS3(ctx, body, file, resp, up)
+		if err == nil {
+			return hash, nil
+		}
+		if !retryable || utils.IsCanceled(ctx) {
+			break
+		}
+	}
+	return "", err
+}
+
+// putToS3 发送一次PUT请求，返回SHA-1以及失败时是否可以重试
+func (s *NotionService) putToS3(ctx context.Context, body io.Reader, file model.FileStreamer, resp *UploadResponse, up driver.UpdateProgress) (string, bool, error) {
+	// 边上传边计算SHA-1，与上传层提供的SHA-1比对
+	reader, sum := sha1Reader(body, file)
+	checker := newUploadChecker(reader)
+	tee := &driver.ReaderUpdatingProgress{
+		Reader: &driver.SimpleReaderWithSize{
+			Reader: s.limitUpload(ctx, checker),
+			Size:   file.GetSize(),
+		},
+		UpdateProgress: up,
+	}
+	req, err := http.NewRequestWithContext(ctx, "PUT", resp.SignedPutUrl, tee)
 	if err != nil {
-		return "", fmt.Errorf("创建请求失败: %v", err)
+		return "", false, s.lang.errorf(msgNewRequest, err)
 	}
 
 	//设置请求头
 	for _, header := range resp.PutHeaders {
 		req.Header.Set(header.Name, header.Value)
 	}
+	if s.storageClass != "" {
+		req.Header.Set("x-amz-storage-class", s.storageClass)
+	}
+	if s.tagging != "" {
+		req.Header.Set("x-amz-tagging", s.tagging)
+	}
 	req.Header.Set("Content-Type", "application/octet-stream")
-	// 手动设置 Content-Length
+	// 签名URL要求提供长度，不能使用chunked编码
 	req.ContentLength = file.GetSize()
-	client := &http.Client{}
-	response, err := client.Do(req)
+	response, err := s.api.Do(req)
 	if err != nil {
-		return "", fmt.Errorf("发送请求失败: %v", err)
+		return "", true, s.lang.errorf(msgSendRequest, err)
 	}
 	defer response.Body.Close()
 	if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusNoContent {
 		body, _ := io.ReadAll(response.Body)
-		return "", fmt.Errorf("上传失败，状态码: %d, 响应: %s", response.StatusCode, string(body))
+		return "", response.StatusCode >= 500, s.lang.apiError(msgAPIUpload, response.StatusCode, string(body))
+	}
+	// 数据在传输中损坏时重新上传
+	if err := checker.verify(s.lang, file.GetSize(), response.Header); err != nil {
+		return "", true, s.lang.errorf(msgVerifyUpload, err)
+	}
+	log.Debugf("文件上传成功，状态码: %d", response.StatusCode)
+	hash, err := sum()
+	return hash, false, err
+}
+
+// limitUpload 对上传流应用全局的服务端上传限速和存储的上传限速，在上传任务中时按字节统计任务的速度
+func (s *NotionService) limitUpload(ctx context.Context, r io.Reader) io.Reader {
+	r = fs.CountUpload(ctx, driver.NewLimitedUploadStream(ctx, r))
+	if s.uploadLimit != nil {
+		r = &driver.RateLimitReader{Reader: r, Limiter: s.uploadLimit, Ctx: ctx}
+	}
+	return &countReader{Reader: r, counter: transferBytes.WithLabelValues("upload")}
+}
+
+// uploadChecker 统计实际上传的字节数并计算MD5，上传后与S3返回的ETag比对，
+// 在写入文件记录前发现被截断或损坏的上传
+type uploadChecker struct {
+	r   io.Reader
+	n   int64
+	md5 hash.Hash
+}
+
+func newUploadChecker(r io.Reader) *uploadChecker {
+	return &uploadChecker{r: r, md5: md5.New()}
+}
+
+func (c *uploadChecker) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	c.md5.Write(p[:n])
+	return n, err
+}
+
+// verify 检查上传的字节数和响应的ETag；分片上传和KMS加密的对象的ETag不是MD5，只检查字节数
+func (c *uploadChecker) verify(l language, size int64, header http.Header) error {
+	if c.n != size {
+		return l.errorf(msgUploadSizeMismatch, c.n, size)
+	}
+	etag := strings.Trim(header.Get("ETag"), `"`)
+	if len(etag) != 32 || strings.Contains(etag, "-") || header.Get("x-amz-server-side-encryption") == "aws:kms" {
+		return nil
+	}
+	if sum := hex.EncodeToString(c.md5.Sum(nil)); !strings.EqualFold(etag, sum) {
+		return l.errorf(msgETagMismatch, etag, sum)
+	}
+	return nil
+}
+
+// sha1Reader 返回上传用的读取流和获取SHA-1的函数，上传层缓存文件时已计算SHA-1则直接使用，否则由实际读取的内容计算；
+// 客户端（如网页上传、WebDAV）提供的SHA-1只用于校验，不一致时返回错误
+func sha1Reader(r io.Reader, file model.FileStreamer) (io.Reader, func() (string, error)) {
+	check := func(sum string) (string, error) {
+		if err := checkSHA1(file.GetHash(), sum); err != nil {
+			return "", err
+		}
+		return sum, nil
+	}
+	if hi, ok := stagedHash(file, []*utils.HashType{utils.SHA1}); ok {
+		return r, func() (string, error) {
+			return check(hi.GetHash(utils.SHA1))
+		}
+	}
+	hash := sha1.New()
+	return io.TeeReader(r, hash), func() (string, error) {
+		return check(hex.EncodeToString(hash.Sum(nil)))
 	}
-	fmt.Printf("文件上传成功，状态码: %d\n", response.StatusCode)
-	// 计算文件的 SHA-1 值
-	sha1Value := hash.Sum(nil)
-	sha1Hex := hex.EncodeToString(sha1Value)
-	return sha1Hex, nil
 }
 
 func (s *NotionService) UpdateFileStatus(record RecordInfo, fileName string, fileURL string) error {
+	return s.UpdateFileList(record, []PageAttachment{{Name: fileName, URL: fileURL}})
+}
+
+// UpdateFileList 将页面的文件属性设置为files中的全部附件，按顺序排列
+func (s *NotionService) UpdateFileList(record RecordInfo, files []PageAttachment) error {
+	// 多个附件之间以","分隔
+	value := make([]interface{}, 0, len(files)*2)
+	for i, file := range files {
+		if i > 0 {
+			value = append(value, []interface{}{","})
+		}
+		value = append(value, []interface{}{
+			file.Name,
+			[]interface{}{
+				[]interface{}{
+					"a",
+					file.URL,
+				},
+			},
+		})
+	}
 	requestID := uuid.New().String()
 	transactionID := uuid.New().String()
 	currentTime := time.Now().UnixMilli()
@@ -522,17 +780,7 @@ func (s *NotionService) UpdateFileStatus(record RecordInfo, fileName string, fil
 						},
 						Path:    []string{"properties", s.filePageID},
 						Command: "set",
-						Args: []interface{}{
-							[]interface{}{
-								fileName,
-								[]interface{}{
-									[]interface{}{
-										"a",
-										fileURL,
-									},
-								},
-							},
-						},
+						Args:    value,
 					},
 					{
 						Pointer: Pointer{
@@ -555,29 +803,28 @@ func (s *NotionService) UpdateFileStatus(record RecordInfo, fileName string, fil
 
 	jsonData, err := json.Marshal(reqBody)
 	if err != nil {
-		return fmt.Errorf("序列化请求体失败: %v", err)
+		return s.lang.errorf(msgMarshalBody, err)
 	}
 
-	req, err := http.NewRequest("POST", NotionAPIBaseURL+"/saveTransactionsFanout", bytes.NewBuffer(jsonData))
+	req, err := http.NewRequest("POST", s.apiBase+"/saveTransactionsFanout", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return fmt.Errorf("创建请求失败: %v", err)
+		return s.lang.errorf(msgNewRequest, err)
 	}
 
 	s.setCommonHeaders(req)
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.api.Do(req)
 	if err != nil {
-		return fmt.Errorf("发送请求失败: %v", err)
+		return s.lang.errorf(msgSendRequest, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("更新文件状态失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return s.lang.apiError(msgAPIUpdateFileStatus, resp.StatusCode, string(body))
 	}
 
-	fmt.Printf("文件状态更新成功，状态码: %d\n", resp.StatusCode)
+	log.Debugf("文件状态更新成功，状态码: %d", resp.StatusCode)
 	return nil
 }
 
@@ -600,37 +847,180 @@ func (s *NotionService) setPutCommonHeaders(req *http.Request) {
 func (s *NotionService) GetPageProperty(pageID string, propertyID string) (*PropertyResponse, error) {
 	//propertyID 转义
 	propertyIDNew := url.PathEscape(propertyID)
-	url := fmt.Sprintf("https://api.notion.com/v1/pages/%s/properties/%s", pageID, propertyIDNew)
+	url := fmt.Sprintf("%s/pages/%s/properties/%s", s.publicAPIBase, pageID, propertyIDNew)
 
 	req, err := http.NewRequest("GET", url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("创建请求失败: %v", err)
+		return nil, s.lang.errorf(msgNewRequest, err)
 	}
 
 	req.Header.Set("Authorization", "Bearer "+s.token)
 	req.Header.Set("Notion-Version", "2022-06-28")
 	req.Header.Set("Content-Type", "application/json")
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := s.api.Do(req)
 	if err != nil {
-		return nil, fmt.Errorf("发送请求失败: %v", err)
+		return nil, s.lang.errorf(msgSendRequest, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("获取属性失败，状态码: %d, 响应: %s", resp.StatusCode, string(body))
+		return nil, s.lang.apiError(msgAPIGetProperty, resp.StatusCode, string(body))
 	}
 
 	var propertyResponse PropertyResponse
 	if err := json.NewDecoder(resp.Body).Decode(&propertyResponse); err != nil {
-		return nil, fmt.Errorf("解析响应失败: %v", err)
+		return nil, s.lang.errorf(msgDecodeResponse, err)
 	}
 
 	return &propertyResponse, nil
 }
 
+// PingDatabase 检查令牌能否访问数据库，用于健康检查
+func (s *NotionService) PingDatabase(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", s.publicAPIBase+"/databases/"+s.databaseID, nil)
+	if err != nil {
+		return s.lang.errorf(msgNewRequest, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Notion-Version", "2022-06-28")
+
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return s.lang.errorf(msgSendRequest, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return s.lang.apiError(msgAPIGetDatabase, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// PageFileURL 获取页面第index个附件的下载地址，未打包的页面只有一个附件
+func (s *NotionService) PageFileURL(pageID string, index int) (string, error) {
+	property, err := s.GetPageProperty(pageID, s.filePageID)
+	if err != nil {
+		return "", err
+	}
+	if len(property.Files) == 0 {
+		return "", s.lang.errorf(msgPageNoFiles, pageID, errs.ObjectNotFound)
+	}
+	if index >= len(property.Files) {
+		return "", s.lang.errorf(msgPageFileIndex, pageID, len(property.Files), index+1)
+	}
+	return property.Files[index].File.URL, nil
+}
+
+// OpenPageFile 打开页面中的附件，返回从offset开始的数据流，length<=0时读取到结尾
+func (s *NotionService) OpenPageFile(ctx context.Context, pageID string, offset, length int64) (io.ReadCloser, error) {
+	return s.OpenPageAttachment(ctx, pageID, 0, offset, length)
+}
+
+// OpenPageAttachment 打开页面中的第index个附件
+func (s *NotionService) OpenPageAttachment(ctx context.Context, pageID string, index int, offset, length int64) (rc io.ReadCloser, err error) {
+	ctx, span := tracer.Start(ctx, "notion.read_attachment", trace.WithAttributes(attribute.String("page_id", pageID),
+		attribute.Int("index", index), attribute.Int64("offset", offset), attribute.Int64("length", length)))
+	defer func() {
+		// 成功时span在读取结束后结束
+		if err != nil {
+			endSpan(span, err)
+		}
+	}()
+	fileURL, err := s.PageFileURL(pageID, index)
+	if err != nil {
+		return nil, s.lang.errorf(msgGetFileURL, err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return nil, s.lang.errorf(msgNewRequest, err)
+	}
+	if length > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	} else if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	req.Header.Set("Accept-Encoding", "identity")
+	for k, v := range s.FileHeader(fileURL) {
+		req.Header[k] = v
+	}
+
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return nil, s.lang.errorf(msgSendRequest, err)
+	}
+	if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, fmt.Errorf("%w: %w", s.lang.errorf(msgHTTPStatus, resp.StatusCode), errs.ObjectNotFound)
+		}
+		return nil, s.lang.errorf(msgHTTPStatus, resp.StatusCode)
+	}
+	return traceRead(countDownload(resp.Body), span), nil
+}
+
+// AttachmentSize 通过只读取第一个字节的请求获取附件的大小，Notion的附件属性中没有大小
+func (s *NotionService) AttachmentSize(ctx context.Context, fileURL string) (int64, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", fileURL, nil)
+	if err != nil {
+		return 0, s.lang.errorf(msgNewRequest, err)
+	}
+	req.Header.Set("Range", "bytes=0-0")
+	req.Header.Set("Accept-Encoding", "identity")
+	for k, v := range s.FileHeader(fileURL) {
+		req.Header[k] = v
+	}
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return 0, s.lang.errorf(msgSendRequest, err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return resp.ContentLength, nil
+	case http.StatusPartialContent, http.StatusRequestedRangeNotSatisfiable:
+		// Content-Range为 bytes 0-0/总大小，空文件为 bytes */0
+		contentRange := resp.Header.Get("Content-Range")
+		i := strings.LastIndexByte(contentRange, '/')
+		if i < 0 {
+			return 0, s.lang.errorf(msgContentRange, contentRange)
+		}
+		size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+		if err != nil {
+			return 0, s.lang.errorf(msgContentRange, contentRange)
+		}
+		return size, nil
+	default:
+		return 0, s.lang.errorf(msgHTTPStatus, resp.StatusCode)
+	}
+}
+
+// FileHeader 返回下载附件需要携带的请求头
+// S3签名地址可以直接访问，返回nil；Notion自身域名下的地址（如file.notion.so）需要登录cookie
+func (s *NotionService) FileHeader(fileURL string) http.Header {
+	u, err := url.Parse(fileURL)
+	if err != nil {
+		return nil
+	}
+	host := u.Hostname()
+	if host != "notion.so" && !strings.HasSuffix(host, ".notion.so") {
+		return nil
+	}
+	header := http.Header{}
+	header.Set("Cookie", s.cookie)
+	return header
+}
+
+// fileLink 返回附件的下载链接，需要请求头的地址只能通过代理下载，由代理转发请求头
+func (d *Notion) fileLink(fileURL string) *model.Link {
+	return &model.Link{
+		URL:    fileURL,
+		Header: d.notionClient.FileHeader(fileURL),
+	}
+}
+
 // GetFileSize 获取文件大小
 func GetFileSize(filePath string) (int64, error) {
 	fileInfo, err := os.Stat(filePath)
@@ -649,4 +1039,87 @@ func IsDir(path string) bool {
 	return fileInfo.IsDir()
 }
 
+// parseOtherData 将Other请求中的Data解析到v
+func parseOtherData(l language, data interface{}, v interface{}) error {
+	b, err := utils.Json.Marshal(data)
+	if err != nil {
+		return l.errorf(msgParseOtherData, err)
+	}
+	if err := utils.Json.Unmarshal(b, v); err != nil {
+		return l.errorf(msgParseOtherData, err)
+	}
+	return nil
+}
+
 // do others that not defined in Driver interface
+
+// EnsureProperties 在数据库中创建缺少的属性，已存在的同类型属性不受影响
+func (s *NotionService) EnsureProperties(properties map[string]interface{}) error {
+	jsonData, err := json.Marshal(map[string]interface{}{"properties": properties})
+	if err != nil {
+		return s.lang.errorf(msgMarshalBody, err)
+	}
+	req, err := http.NewRequest("PATCH", s.publicAPIBase+"/databases/"+s.databaseID, bytes.NewBuffer(jsonData))
+	if err != nil {
+		return s.lang.errorf(msgNewRequest, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Notion-Version", "2022-06-28")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return s.lang.errorf(msgSendRequest, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return s.lang.apiError(msgAPIUpdateDBProps, resp.StatusCode, string(body))
+	}
+	return nil
+}
+
+// UpdatePageProperties 更新页面的属性
+func (s *NotionService) UpdatePageProperties(pageID string, properties map[string]interface{}) error {
+	if err := s.patchPage(pageID, map[string]interface{}{"properties": properties}); err != nil {
+		return s.lang.errorf(msgUpdatePageProps, err)
+	}
+	return nil
+}
+
+// QueryDatabase 分页查询数据库中的页面，cursor为空时从头开始
+func (s *NotionService) QueryDatabase(ctx context.Context, filter interface{}, cursor string) (*QueryDatabaseResponse, error) {
+	reqBody := map[string]interface{}{"page_size": 100}
+	if filter != nil {
+		reqBody["filter"] = filter
+	}
+	if cursor != "" {
+		reqBody["start_cursor"] = cursor
+	}
+	jsonData, err := json.Marshal(reqBody)
+	if err != nil {
+		return nil, s.lang.errorf(msgMarshalBody, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", s.publicAPIBase+"/databases/"+s.databaseID+"/query", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, s.lang.errorf(msgNewRequest, err)
+	}
+	req.Header.Set("Authorization", "Bearer "+s.token)
+	req.Header.Set("Notion-Version", "2022-06-28")
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.api.Do(req)
+	if err != nil {
+		return nil, s.lang.errorf(msgSendRequest, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, s.lang.apiError(msgAPIQueryDB, resp.StatusCode, string(body))
+	}
+	var res QueryDatabaseResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, s.lang.errorf(msgDecodeResponse, err)
+	}
+	return &res, nil
+}
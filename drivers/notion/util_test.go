@@ -0,0 +1,191 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/stream"
+)
+
+func TestUploadWritesNothingToStdout(t *testing.T) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	for _, form := range []bool{false, true} {
+		fake := newFakeNotion(t)
+		fake.formUpload = form
+		d := newTestNotion(t, fake, nil)
+		if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(100*1024)), func(float64) {}); err != nil {
+			t.Fatalf("put (form %v): %v", form, err)
+		}
+	}
+	os.Stdout = stdout
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if len(out) > 0 {
+		t.Fatalf("expect no output on stdout, got %q", out)
+	}
+}
+
+func TestUploadLimit(t *testing.T) {
+	for _, form := range []bool{false, true} {
+		t.Run(fmt.Sprintf("form=%v", form), func(t *testing.T) {
+			fake := newFakeNotion(t)
+			fake.formUpload = form
+			// 64KB/s，突发64KB，上传128KB至少需要1秒
+			d := newTestNotion(t, fake, func(d *Notion) { d.UploadLimit = 64 })
+			start := time.Now()
+			if _, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(128*1024)), func(float64) {}); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+			if elapsed := time.Since(start); elapsed < 800*time.Millisecond {
+				t.Fatalf("expect the upload limited, took %v", elapsed)
+			}
+		})
+	}
+}
+
+// TestPutRetryRereadsFile 可重新读取的文件在服务端错误后重新上传完整内容
+func TestPutRetryRereadsFile(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	fake.failNext(http.MethodPut, "/s3/", http.StatusBadGateway, 2)
+	data := testData(100 * 1024)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatalf("put: %v", err)
+	}
+	if n := fake.count(http.MethodPut, "/s3/"); n != 3 {
+		t.Fatalf("expect the put retried twice, got %d requests", n)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("downloaded content differs")
+	}
+}
+
+// TestPutNoRetryForStream 只能读取一次的流失败后不重试
+func TestPutNoRetryForStream(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	fake.failNext(http.MethodPut, "/s3/", http.StatusInternalServerError, 1)
+	data := testData(1000)
+	file := &stream.FileStream{
+		Obj:    &model.Object{Name: "a.bin", Size: int64(len(data)), Modified: time.Now()},
+		Reader: io.NopCloser(bytes.NewReader(data)),
+	}
+	if _, err := d.Put(context.Background(), rootDir(d), file, func(float64) {}); err == nil {
+		t.Fatal("expect the put to fail")
+	}
+	if n := fake.count(http.MethodPut, "/s3/"); n != 1 {
+		t.Fatalf("expect no retry of a stream, got %d requests", n)
+	}
+}
+
+func TestFileHeader(t *testing.T) {
+	s := &NotionService{cookie: "token_v2=abc"}
+	cases := map[string]bool{
+		"https://file.notion.so/f/f/space/file.bin?sig=1":                     true,
+		"https://notion.so/image/file.png":                                    true,
+		"https://prod-files-secure.s3.us-west-2.amazonaws.com/space/file.bin": false,
+		"https://notion.so.example.com/file.bin":                              false,
+		"https://evilnotion.so/file.bin":                                      false,
+	}
+	for u, cookie := range cases {
+		h := s.FileHeader(u)
+		if got := h.Get("Cookie"); (got == "token_v2=abc") != cookie || (!cookie && h != nil) {
+			t.Errorf("header of %s is %v, expect cookie %v", u, h, cookie)
+		}
+	}
+}
+
+func TestFileLink(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", testData(100)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// S3签名地址不需要请求头
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.Header != nil {
+		t.Fatalf("expect no header for a signed url, got %v", link.Header)
+	}
+
+	var f File
+	if err := d.db.First(&f, obj.GetID()).Error; err != nil {
+		t.Fatal(err)
+	}
+	fake.clearPageFiles(f.BlobKey)
+	if _, err := d.Link(context.Background(), obj, model.LinkArgs{}); err == nil {
+		t.Fatal("expect an error for a page without attachment")
+	}
+}
+
+func TestBaseURLs(t *testing.T) {
+	s := NewNotionService("notion_user_id=u", "secret_test", "space", "db", "file")
+	if s.apiBase != NotionAPIBaseURL || s.publicAPIBase != NotionPublicAPIBaseURL || s.s3Base != S3BaseURL {
+		t.Fatalf("expect the default URLs, got %s %s %s", s.apiBase, s.publicAPIBase, s.s3Base)
+	}
+	// 接口地址末尾的斜杠被去掉，S3地址保持不变
+	s.setBaseURLs("http://proxy/api/v3/", "http://proxy/v1/", "http://proxy/s3/")
+	if s.apiBase != "http://proxy/api/v3" || s.publicAPIBase != "http://proxy/v1" || s.s3Base != "http://proxy/s3/" {
+		t.Fatalf("expect the configured URLs, got %s %s %s", s.apiBase, s.publicAPIBase, s.s3Base)
+	}
+	s.setBaseURLs("", "", "")
+	if s.apiBase != NotionAPIBaseURL || s.publicAPIBase != NotionPublicAPIBaseURL || s.s3Base != S3BaseURL {
+		t.Fatalf("expect the defaults for empty URLs, got %s %s %s", s.apiBase, s.publicAPIBase, s.s3Base)
+	}
+}
+
+// TestBaseURLsStorage 存储的所有客户端使用配置的地址，只对接口地址的请求限速
+func TestBaseURLsStorage(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.APIBaseURL = fake.URL + "/api/v3/"
+		d.PublicAPIBaseURL = fake.URL + "/v1/"
+		d.SecondaryDatabaseID = secondaryDatabaseID
+	})
+	for _, client := range []*NotionService{d.notionClient, d.chunkClient, d.secondaryClient} {
+		if client.apiBase != fake.URL+"/api/v3" || client.publicAPIBase != fake.URL+"/v1" || client.s3Base != fake.URL+"/s3/" {
+			t.Fatalf("expect the URLs of the fake, got %s %s %s", client.apiBase, client.publicAPIBase, client.s3Base)
+		}
+		bases := client.api.Transport.(limitTransport).apiBases
+		if len(bases) != 2 || bases[0] != client.apiBase || bases[1] != client.publicAPIBase {
+			t.Fatalf("expect the API URLs limited, got %v", bases)
+		}
+	}
+	data := testData(1000)
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 主数据库和备用数据库各上传一次
+	if n := fake.count(http.MethodPost, "/api/v3/getUploadFileUrl"); n != 2 {
+		t.Fatalf("expect the upload URLs got from the fake, got %d requests", n)
+	}
+	link, err := d.Link(context.Background(), obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+}
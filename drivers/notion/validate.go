@@ -0,0 +1,160 @@
+package notion
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// validateTitle 检查配置时创建的页面的标题，页面随后被归档
+const validateTitle = "alist-validate"
+
+// ValidateStep 配置检查的一个步骤，OK为false时Error为失败的原因，
+// 依赖的步骤失败时不执行，Skipped为true
+type ValidateStep struct {
+	Name     string `json:"name"`
+	OK       bool   `json:"ok"`
+	Skipped  bool   `json:"skipped"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+// validator 依次执行检查步骤并记录结果
+type validator struct {
+	ctx   context.Context
+	steps []ValidateStep
+}
+
+// run 执行一个步骤，ok为false时跳过，返回步骤是否成功
+func (v *validator) run(name string, ok bool, fn func(ctx context.Context) error) bool {
+	if !ok {
+		v.steps = append(v.steps, ValidateStep{Name: name, Skipped: true})
+		return false
+	}
+	ctx, cancel := context.WithTimeout(v.ctx, healthTimeout)
+	defer cancel()
+	start := time.Now()
+	err := fn(ctx)
+	step := ValidateStep{Name: name, OK: err == nil, Duration: time.Since(start).Milliseconds()}
+	if err != nil {
+		step.Error = err.Error()
+	}
+	v.steps = append(v.steps, step)
+	return err == nil
+}
+
+// validate 按当前配置检查MySQL、Notion数据库、创建和归档页面以及获取上传地址，不写入元数据和文件，
+// 使用新的数据库连接和客户端，存储未挂载时也可以执行
+func (d *Notion) validate(ctx context.Context) []ValidateStep {
+	v := &validator{ctx: ctx}
+
+	var db *gorm.DB
+	dbOK := v.run("db_connect", true, func(ctx context.Context) error {
+		var err error
+		db, err = gorm.Open(d.openDialector(), &gorm.Config{Logger: logger.Discard})
+		if err != nil {
+			return err
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	})
+	if db != nil {
+		defer func() {
+			if sqlDB, err := db.DB(); err == nil {
+				_ = sqlDB.Close()
+			}
+		}()
+	}
+	v.run("db_query", dbOK, func(ctx context.Context) error {
+		// 表在挂载时创建，尚未创建时只检查能否执行查询
+		if !db.Migrator().HasTable(&Directory{}) {
+			return db.WithContext(ctx).Exec("SELECT 1").Error
+		}
+		var n int64
+		return db.WithContext(ctx).Model(&Directory{}).Count(&n).Error
+	})
+
+	var client, chunkClient *NotionService
+	clientOK := v.run("notion_client", true, func(ctx context.Context) error {
+		client = NewNotionService(d.secret.notionCookie, d.secret.notionToken, d.NotionSpaceID, d.NotionDatabaseID, d.NotionFilePageID)
+		if client == nil {
+			return fmt.Errorf("cookie中没有notion_user_id")
+		}
+		client.lang = d.lang()
+		client.setBaseURLs(d.APIBaseURL, d.PublicAPIBaseURL, d.S3BaseURL)
+		if d.ChunkDatabaseID != "" && d.ChunkDatabaseID != d.NotionDatabaseID {
+			chunkClient = NewNotionService(d.secret.notionCookie, d.secret.notionToken, d.NotionSpaceID, d.ChunkDatabaseID, d.NotionFilePageID)
+			chunkClient.lang = client.lang
+			chunkClient.setBaseURLs(d.APIBaseURL, d.PublicAPIBaseURL, d.S3BaseURL)
+		}
+		return nil
+	})
+	queryOK := v.run("notion_database", clientOK, func(ctx context.Context) error {
+		_, err := client.QueryDatabase(ctx, nil, "")
+		return err
+	})
+	if chunkClient != nil {
+		v.run("chunk_database", clientOK, func(ctx context.Context) error {
+			_, err := chunkClient.QueryDatabase(ctx, nil, "")
+			return err
+		})
+	}
+	var pageID string
+	pageOK := v.run("page_create", queryOK, func(ctx context.Context) error {
+		var err error
+		pageID, err = client.CreateDatabasePage(validateTitle)
+		return err
+	})
+	v.run("upload_url", pageOK, func(ctx context.Context) error {
+		// 只获取上传地址，不上传内容
+		file := &stream.FileStream{
+			Obj:      &model.Object{Name: validateTitle + ".txt", Size: 1},
+			Mimetype: "text/plain",
+		}
+		res, err := client.UploadFilePut(file, client.pageRecord(pageID))
+		if err != nil {
+			return err
+		}
+		if res.SignedPutUrl == "" && res.Fields.Key == "" {
+			return fmt.Errorf("响应中没有上传地址")
+		}
+		return nil
+	})
+	// 创建的页面总是归档，即使获取上传地址失败
+	v.run("page_archive", pageOK, func(ctx context.Context) error {
+		return client.ArchivePage(pageID)
+	})
+	return v.steps
+}
+
+// validateOnly 只检查配置而不挂载存储，返回的错误显示在存储状态中，列出各步骤的结果
+func (d *Notion) validateOnly(ctx context.Context) error {
+	steps := d.validate(ctx)
+	failed := false
+	results := make([]string, 0, len(steps))
+	for _, s := range steps {
+		switch {
+		case s.OK:
+			results = append(results, s.Name+": ok")
+		case s.Skipped:
+			results = append(results, s.Name+": 跳过")
+		default:
+			failed = true
+			results = append(results, s.Name+": 失败: "+s.Error)
+		}
+	}
+	if failed {
+		return errors.New("配置检查失败: " + strings.Join(results, "; "))
+	}
+	return errors.New("配置检查通过，关闭validate_only后挂载存储: " + strings.Join(results, "; "))
+}
@@ -0,0 +1,95 @@
+package notion
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestUploadCheckerVerify(t *testing.T) {
+	data := testData(1000)
+	sum := md5.Sum(data)
+	etag := `"` + hex.EncodeToString(sum[:]) + `"`
+	read := func(n int) *uploadChecker {
+		c := newUploadChecker(bytes.NewReader(data[:n]))
+		if _, err := io.Copy(io.Discard, c); err != nil {
+			t.Fatal(err)
+		}
+		return c
+	}
+	header := func(kv ...string) http.Header {
+		h := http.Header{}
+		for i := 0; i < len(kv); i += 2 {
+			h.Set(kv[i], kv[i+1])
+		}
+		return h
+	}
+	var l language
+
+	if err := read(1000).verify(l, 1000, header("ETag", etag)); err != nil {
+		t.Fatalf("expect the content verified, got %v", err)
+	}
+	if err := read(1000).verify(l, 1000, header("ETag", strings.ToUpper(etag))); err != nil {
+		t.Fatalf("expect the ETag compared case-insensitively, got %v", err)
+	}
+	// 字节数不足时不论ETag都失败
+	if err := read(900).verify(l, 1000, header()); err == nil || !strings.Contains(err.Error(), "上传了900字节") {
+		t.Fatalf("expect a size mismatch, got %v", err)
+	}
+	bad := `"` + strings.Repeat("0", 32) + `"`
+	if err := read(1000).verify(l, 1000, header("ETag", bad)); err == nil || !strings.Contains(err.Error(), "ETag") {
+		t.Fatalf("expect an ETag mismatch, got %v", err)
+	}
+	// 分片上传、KMS加密或没有ETag时只检查字节数
+	for _, h := range []http.Header{
+		header(),
+		header("ETag", `"`+strings.Repeat("0", 30)+`-2"`),
+		header("ETag", bad, "x-amz-server-side-encryption", "aws:kms"),
+	} {
+		if err := read(1000).verify(l, 1000, h); err != nil {
+			t.Fatalf("expect %v not compared with the MD5, got %v", h, err)
+		}
+	}
+}
+
+func TestPutETagMismatch(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, nil)
+	ctx := context.Background()
+	data := testData(1000)
+
+	// 签名URL上传的ETag不一致时重新上传
+	fake.badETags = 1
+	obj, err := d.Put(ctx, rootDir(d), newTestStream("a.bin", data), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := fake.count(http.MethodPut, "/s3/"); n != 2 {
+		t.Fatalf("expect the upload retried once, got %d uploads", n)
+	}
+	link, err := d.Link(ctx, obj, model.LinkArgs{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := readURL(t, link); !bytes.Equal(got, data) {
+		t.Fatal("content mismatch")
+	}
+
+	// 表单上传不能重新读取，返回校验错误且不保存文件记录
+	fake.formUpload = true
+	fake.badETags = 1
+	_, err = d.Put(ctx, rootDir(d), newTestStream("b.bin", data), func(float64) {})
+	if err == nil || !strings.Contains(err.Error(), "上传校验失败") {
+		t.Fatalf("expect a verification error, got %v", err)
+	}
+	if names := listNames(t, d, rootDir(d)); len(names) != 1 || names[0] != "a.bin" {
+		t.Fatalf("expect only a.bin listed, got %v", names)
+	}
+}
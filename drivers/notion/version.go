@@ -0,0 +1,159 @@
+package notion
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/dbfs"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"gorm.io/gorm"
+)
+
+// offsetLimit MySQL不支持没有LIMIT的OFFSET，跳过最新的N行、读取其余全部行时用作LIMIT
+const offsetLimit = math.MaxInt32
+
+// VersionInfo 历史版本信息
+type VersionInfo struct {
+	ID       int       `json:"id"`
+	Size     int64     `json:"size"`
+	SHA1     string    `json:"sha1"`
+	Modified time.Time `json:"modified"`
+	Created  time.Time `json:"created"`
+}
+
+// VersionReq restore_version/delete_version的请求参数
+type VersionReq struct {
+	VersionID int `json:"version_id"`
+}
+
+// listVersions 列出文件的历史版本，新版本在前
+func (d *Notion) listVersions(fileID string) ([]VersionInfo, error) {
+	var versions []FileVersion
+	if err := d.db.Where("file_id = ?", fileID).Order("id DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("获取历史版本失败: %w", err)
+	}
+	res := make([]VersionInfo, 0, len(versions))
+	for _, v := range versions {
+		var f File
+		if err := d.db.Where("id = ?", v.VersionFileID).First(&f).Error; err != nil {
+			return nil, fmt.Errorf("获取历史版本%d失败: %v", v.ID, err)
+		}
+		res = append(res, VersionInfo{
+			ID:       v.ID,
+			Size:     f.Size,
+			SHA1:     f.SHA1,
+			Modified: f.UpdatedAt,
+			Created:  v.CreatedAt,
+		})
+	}
+	return res, nil
+}
+
+// getVersion 获取属于指定文件的历史版本
+func (d *Notion) getVersion(tx *gorm.DB, fileID string, versionID int) (*FileVersion, error) {
+	var v FileVersion
+	if err := tx.Where("id = ? AND file_id = ?", versionID, fileID).First(&v).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("历史版本%d不存在: %w", versionID, errs.ObjectNotFound)
+		}
+		return nil, fmt.Errorf("获取历史版本失败: %w", err)
+	}
+	return &v, nil
+}
+
+// restoreVersion 将历史版本恢复为当前版本，当前版本转为历史版本
+// 恢复后文件ID会变为历史版本对应的记录ID
+func (d *Notion) restoreVersion(fileID string, versionID int) (*File, error) {
+	var restored File
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		var current File
+		if err := tx.Where("id = ? AND deleted = ?", fileID, false).First(&current).Error; err != nil {
+			return fmt.Errorf("获取文件信息失败: %w", err)
+		}
+		v, err := d.getVersion(tx, fileID, versionID)
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", v.VersionFileID).First(&restored).Error; err != nil {
+			return fmt.Errorf("获取历史版本文件失败: %w", err)
+		}
+		// 历史版本继承当前文件的名称和位置
+		restored.Name = current.Name
+		restored.DirectoryID = current.DirectoryID
+		restored.Deleted = false
+		restored.Version++
+		if err := tx.Save(&restored).Error; err != nil {
+			return fmt.Errorf("恢复历史版本失败: %w", err)
+		}
+		if err := tx.Model(&File{}).Where("id = ?", current.ID).Update("deleted", true).Error; err != nil {
+			return fmt.Errorf("替换当前版本失败: %w", err)
+		}
+		// 当前版本占据被恢复版本的位置，所有版本转移到恢复后的文件下
+		if err := tx.Model(v).Update("version_file_id", current.ID).Error; err != nil {
+			return fmt.Errorf("保存历史版本失败: %w", err)
+		}
+		if err := tx.Model(&FileVersion{}).Where("file_id = ?", current.ID).Update("file_id", restored.ID).Error; err != nil {
+			return fmt.Errorf("转移历史版本失败: %w", err)
+		}
+		return dbfs.MoveTags(tx, current.ID, restored.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	d.mirrorFile(&restored)
+	return &restored, nil
+}
+
+// deleteVersion 删除文件的一个历史版本
+func (d *Notion) deleteVersion(fileID string, versionID int) error {
+	var pageIDs []string
+	err := d.db.Transaction(func(tx *gorm.DB) error {
+		v, err := d.getVersion(tx, fileID, versionID)
+		if err != nil {
+			return err
+		}
+		var f File
+		if err := tx.Where("id = ?", v.VersionFileID).First(&f).Error; err == nil {
+			if pageIDs, err = filePageIDs(tx, &f); err != nil {
+				return err
+			}
+		}
+		return d.dropVersion(tx, v)
+	})
+	if err != nil {
+		return err
+	}
+	d.archivePages(pageIDs)
+	return nil
+}
+
+// pruneVersions 只保留最新的VersionRetention个历史版本
+func (d *Notion) pruneVersions(tx *gorm.DB, fileID int) error {
+	var versions []FileVersion
+	if err := tx.Where("file_id = ?", fileID).Order("id DESC").Limit(offsetLimit).Offset(d.VersionRetention).Find(&versions).Error; err != nil {
+		return fmt.Errorf("获取历史版本失败: %w", err)
+	}
+	for i := range versions {
+		if err := d.dropVersion(tx, &versions[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropVersion 删除版本记录及其文件分块
+func (d *Notion) dropVersion(tx *gorm.DB, v *FileVersion) error {
+	var f File
+	if err := tx.Where("id = ?", v.VersionFileID).First(&f).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("获取历史版本文件失败: %w", err)
+	}
+	if err := d.deleteFileChunks(tx, &f); err != nil {
+		return err
+	}
+	if err := tx.Delete(v).Error; err != nil {
+		return fmt.Errorf("删除历史版本%d失败: %v", v.ID, err)
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package notion
+
+import (
+	"context"
+	stdpath "path"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/alist-org/alist/v3/drivers/base"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	EventUpload       = "upload"
+	EventDelete       = "delete"
+	EventUploadFailed = "upload_failed"
+	// EventScrubFailed 后台校验发现页面的内容与记录的SHA1不一致
+	EventScrubFailed = "scrub_failed"
+)
+
+// webhookTimeout 发送webhook的超时时间
+const webhookTimeout = 10 * time.Second
+
+// WebhookVars webhook模板可以使用的变量
+type WebhookVars struct {
+	Event    string
+	Storage  string
+	Path     string
+	Name     string
+	Size     int64
+	UserName string
+	Error    string
+	Time     time.Time
+}
+
+var webhookFuncs = template.FuncMap{
+	// json 将值编码为JSON，用于在模板中安全地嵌入文件名等字符串
+	"json": func(v interface{}) (string, error) {
+		return utils.Json.MarshalToString(v)
+	},
+}
+
+// initWebhook 解析webhook模板，未配置URL时不发送
+func (d *Notion) initWebhook() error {
+	d.webhookTmpl = nil
+	if d.WebhookURL == "" {
+		return nil
+	}
+	tmpl, err := template.New("webhook").Funcs(webhookFuncs).Parse(d.WebhookTemplate)
+	if err != nil {
+		return err
+	}
+	d.webhookTmpl = tmpl
+	return nil
+}
+
+// notify 异步发送webhook，dirID和name为对象所在的目录和名称，发送失败只记录日志
+func (d *Notion) notify(ctx context.Context, event string, dirID int, name string, size int64, err error) {
+	if d.webhookTmpl == nil || !utils.SliceContains(strings.Split(d.WebhookEvents, ","), event) {
+		return
+	}
+	path := stdpath.Join(d.tree.DirPath(dirID), name)
+	vars := WebhookVars{
+		Event:   event,
+		Storage: d.GetStorage().MountPath,
+		Path:    stdpath.Join(d.GetStorage().MountPath, path),
+		Name:    name,
+		Size:    size,
+		Time:    time.Now(),
+	}
+	if user, ok := ctx.Value("user").(*model.User); ok {
+		vars.UserName = user.Username
+	}
+	if err != nil {
+		vars.Error = err.Error()
+	}
+	var sb strings.Builder
+	if err := d.webhookTmpl.Execute(&sb, vars); err != nil {
+		log.Warnf("生成webhook内容失败: %+v", err)
+		return
+	}
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), webhookTimeout)
+		defer cancel()
+		res, err := base.RestyClient.R().
+			SetContext(ctx).
+			SetHeader("Content-Type", "application/json").
+			SetBody(sb.String()).
+			Post(d.WebhookURL)
+		if err != nil {
+			log.Warnf("发送webhook[%s]失败: %+v", event, err)
+			return
+		}
+		if res.IsError() {
+			log.Warnf("发送webhook[%s]失败: %s", event, res.Status())
+		}
+	}()
+}
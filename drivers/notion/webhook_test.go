@@ -0,0 +1,135 @@
+package notion
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/drivers/base"
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+type webhookBody struct {
+	Event string `json:"event"`
+	Path  string `json:"path"`
+	Size  int64  `json:"size"`
+	User  string `json:"user"`
+	Error string `json:"error"`
+}
+
+// newWebhookServer 返回接收webhook的服务器地址和收到的请求体
+func newWebhookServer(t *testing.T) (string, chan webhookBody) {
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	base.InitClient()
+	bodies := make(chan webhookBody, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var body webhookBody
+		if err := json.Unmarshal(data, &body); err != nil {
+			t.Errorf("invalid webhook body %q: %v", data, err)
+		}
+		bodies <- body
+	}))
+	t.Cleanup(srv.Close)
+	return srv.URL, bodies
+}
+
+// defaultAddition 配置项的默认值
+func defaultAddition(t *testing.T, name string) string {
+	field, ok := reflect.TypeOf(Addition{}).FieldByName(name)
+	if !ok {
+		t.Fatalf("no option %s", name)
+	}
+	return field.Tag.Get("default")
+}
+
+func nextWebhook(t *testing.T, bodies chan webhookBody) webhookBody {
+	t.Helper()
+	select {
+	case body := <-bodies:
+		return body
+	case <-time.After(5 * time.Second):
+		t.Fatal("expect a webhook")
+		return webhookBody{}
+	}
+}
+
+func TestWebhook(t *testing.T) {
+	url, bodies := newWebhookServer(t)
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.WebhookURL = url
+		// 使用默认的事件和模板
+		d.WebhookEvents = defaultAddition(t, "WebhookEvents")
+		d.WebhookTemplate = defaultAddition(t, "WebhookTemplate")
+	})
+	ctx := context.WithValue(context.Background(), "user", &model.User{Username: "alice"})
+	dir, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	obj, err := d.Put(ctx, dir, newTestStream("a.bin", testData(1000)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := webhookBody{Event: EventUpload, Path: "/notion/docs/a.bin", Size: 1000, User: "alice"}
+	if got := nextWebhook(t, bodies); got != want {
+		t.Fatalf("got webhook %+v, want %+v", got, want)
+	}
+
+	fake.failNext(http.MethodPut, "/s3/", http.StatusForbidden, 1)
+	if _, err := d.Put(ctx, dir, newTestStream("b.bin", testData(10)), func(float64) {}); err == nil {
+		t.Fatal("expect the put to fail")
+	}
+	if got := nextWebhook(t, bodies); got.Event != EventUploadFailed || got.Path != "/notion/docs/b.bin" || got.Error == "" {
+		t.Fatalf("expect a failed upload webhook with the error, got %+v", got)
+	}
+
+	if err := d.Remove(ctx, obj); err != nil {
+		t.Fatal(err)
+	}
+	want = webhookBody{Event: EventDelete, Path: "/notion/docs/a.bin", Size: 1000, User: "alice"}
+	if got := nextWebhook(t, bodies); got != want {
+		t.Fatalf("got webhook %+v, want %+v", got, want)
+	}
+}
+
+func TestWebhookEvents(t *testing.T) {
+	url, bodies := newWebhookServer(t)
+	d := newTestNotion(t, newFakeNotion(t), func(d *Notion) {
+		d.WebhookURL = url
+		d.WebhookEvents = EventDelete
+		d.WebhookTemplate = `{"event":{{json .Event}},"path":{{json .Name}}}`
+	})
+	obj, err := d.Put(context.Background(), rootDir(d), newTestStream(`a "quoted".bin`, testData(10)), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.Remove(context.Background(), obj); err != nil {
+		t.Fatal(err)
+	}
+	// 只发送订阅的事件，模板中的名称经过JSON转义
+	want := webhookBody{Event: EventDelete, Path: `a "quoted".bin`}
+	if got := nextWebhook(t, bodies); got != want {
+		t.Fatalf("got webhook %+v, want %+v", got, want)
+	}
+	select {
+	case body := <-bodies:
+		t.Fatalf("expect no other webhook, got %+v", body)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	d.WebhookTemplate = "{{"
+	if err := d.Init(context.Background()); err == nil {
+		t.Fatal("expect an invalid template rejected")
+	}
+}
@@ -0,0 +1,123 @@
+package notion
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+)
+
+// walkFilter 按filter检查文件及其各级上级目录，目录的结果只检查一次，不包含的目录下的文件都跳过
+func walkFilter(filter model.WalkFilter) func(p string) bool {
+	if filter == nil {
+		return func(string) bool { return true }
+	}
+	dirs := map[string]bool{".": true}
+	var dirOK func(dir string) bool
+	dirOK = func(dir string) bool {
+		ok, found := dirs[dir]
+		if !found {
+			ok = dirOK(path.Dir(dir)) && filter(dir)
+			dirs[dir] = ok
+		}
+		return ok
+	}
+	return func(p string) bool {
+		return dirOK(path.Dir(p)) && filter(p)
+	}
+}
+
+// ZipDir 将目录下filter包含的文件按原始数据（不压缩）逐个写入zip，内容边读边写，不落盘也不整体缓存；
+// 空目录不写入
+func (d *Notion) ZipDir(ctx context.Context, dir model.Obj, filter model.WalkFilter, w io.Writer) error {
+	dirID, _ := strconv.Atoi(dir.GetID())
+	files, err := d.tree.SubtreeFiles(dirID)
+	if err != nil {
+		return err
+	}
+	root := d.tree.DirPath(dirID)
+	dirPaths := make(map[int]string)
+	names := make(map[int]string, len(files))
+	include := walkFilter(filter)
+	n := 0
+	for _, f := range files {
+		p, ok := dirPaths[f.DirectoryID]
+		if !ok {
+			p = d.tree.DirPath(f.DirectoryID)
+			dirPaths[f.DirectoryID] = p
+		}
+		name := strings.TrimPrefix(path.Join(strings.TrimPrefix(p, root), f.Name), "/")
+		if include(name) {
+			names[f.ID] = name
+			files[n] = f
+			n++
+		}
+	}
+	files = files[:n]
+	sort.Slice(files, func(i, j int) bool {
+		return names[files[i].ID] < names[files[j].ID]
+	})
+
+	zw := zip.NewWriter(w)
+	for i := range files {
+		f := &files[i]
+		fw, err := zw.CreateHeader(&zip.FileHeader{
+			Name:               names[f.ID],
+			Method:             zip.Store,
+			Modified:           f.UpdatedAt,
+			UncompressedSize64: uint64(f.Size),
+		})
+		if err != nil {
+			return err
+		}
+		if err := d.writeFile(ctx, fw, f); err != nil {
+			return fmt.Errorf("写入文件[%s]失败: %v", names[f.ID], err)
+		}
+	}
+	return zw.Close()
+}
+
+// writeFile 将文件的全部内容写入w
+func (d *Notion) writeFile(ctx context.Context, w io.Writer, f *File) error {
+	var rc io.ReadCloser
+	switch {
+	case f.IsInline() || f.Size == 0:
+		rc = io.NopCloser(bytes.NewReader(f.Inline))
+	case f.IsChunked:
+		rrc, err := d.chunkedReader(f)
+		if err != nil {
+			return err
+		}
+		defer rrc.Close()
+		if rc, err = rrc.RangeRead(ctx, http_range.Range{Start: 0, Length: f.Size}); err != nil {
+			return err
+		}
+	default:
+		var err error
+		if rc, err = d.openPageAttachment(ctx, f.BlobKey, f.BlobIndex, 0, 0); err != nil {
+			return err
+		}
+	}
+	defer rc.Close()
+	var r io.Reader = rc
+	if d.downloadLimit != nil && !f.IsChunked {
+		r = &driver.RateLimitReader{Reader: rc, Limiter: d.downloadLimit, Ctx: ctx}
+	}
+	n, err := io.Copy(w, io.LimitReader(r, f.Size))
+	if err != nil {
+		return err
+	}
+	if n != f.Size {
+		return fmt.Errorf("读取了%d字节，文件大小为%d", n, f.Size)
+	}
+	return nil
+}
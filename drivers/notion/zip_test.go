@@ -0,0 +1,138 @@
+package notion
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// zipEntries 解析zip的内容，键为文件路径
+func zipEntries(t *testing.T, data []byte) map[string][]byte {
+	t.Helper()
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries := make(map[string][]byte, len(zr.File))
+	for _, f := range zr.File {
+		// 只保存原始数据，不压缩
+		if f.Method != zip.Store {
+			t.Fatalf("%s: expect stored, got method %d", f.Name, f.Method)
+		}
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatalf("%s: %v", f.Name, err)
+		}
+		entries[f.Name] = content
+	}
+	return entries
+}
+
+func TestZipDir(t *testing.T) {
+	fake := newFakeNotion(t)
+	d := newTestNotion(t, fake, func(d *Notion) {
+		d.ChunkMode = chunkModeCDC
+		d.CDCAvgSize = 1
+	})
+	ctx := context.Background()
+	docs, err := d.MakeDir(ctx, rootDir(d), "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sub, err := d.MakeDir(ctx, docs, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := d.MakeDir(ctx, docs, "empty"); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]byte{
+		"a.txt":       testData(1000),
+		"sub/big.bin": testData(3 << 20),
+		"sub/b.txt":   nil,
+	}
+	for name, data := range want {
+		dir := docs
+		if strings.HasPrefix(name, "sub/") {
+			dir = sub
+		}
+		if _, err := d.Put(ctx, dir, newTestStream(strings.TrimPrefix(name, "sub/"), data), func(float64) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("outside.txt", testData(10)), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 路径相对于打包的目录，空目录和目录外的文件不写入，分块文件按顺序拼接
+	var buf bytes.Buffer
+	if err := d.ZipDir(ctx, docs, nil, &buf); err != nil {
+		t.Fatal(err)
+	}
+	entries := zipEntries(t, buf.Bytes())
+	var names []string
+	for name := range entries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a.txt,sub/b.txt,sub/big.bin" {
+		t.Fatalf("expect the files under docs, got %v", names)
+	}
+	for name, data := range want {
+		if !bytes.Equal(entries[name], data) {
+			t.Fatalf("%s: content mismatch", name)
+		}
+	}
+}
+
+func TestZipDirFilter(t *testing.T) {
+	d := newTestNotion(t, newFakeNotion(t), nil)
+	ctx := context.Background()
+	for _, name := range []string{"public", "private"} {
+		dir, err := d.MakeDir(ctx, rootDir(d), name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := d.Put(ctx, dir, newTestStream("a.txt", []byte(name)), func(float64) {}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := d.Put(ctx, rootDir(d), newTestStream("hidden.txt", []byte("hidden")), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	// 跳过有其他密码的目录和隐藏的文件
+	var checked []string
+	err := d.ZipDir(ctx, rootDir(d), func(p string) bool {
+		checked = append(checked, p)
+		return p != "private" && p != "hidden.txt"
+	}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if strings.Join(names, ",") != "public/a.txt" {
+		t.Fatalf("expect only the accessible files, got %v", names)
+	}
+	for _, p := range checked {
+		if p == "private/a.txt" {
+			t.Fatal("expect the files of a skipped folder not checked")
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"github.com/alist-org/alist/v3/internal/offline_download/tool"
 	"github.com/alist-org/alist/v3/internal/op"
 	"github.com/alist-org/alist/v3/internal/setting"
+	"github.com/alist-org/alist/v3/pkg/utils"
 	"github.com/xhofe/tache"
 )
 
@@ -36,6 +37,9 @@ func InitTaskManager() {
 	})
 	if len(tool.TransferTaskManager.GetAll()) == 0 { //prevent offline downloaded files from being deleted
 		CleanTempDir()
+	} else {
+		// staging files of interrupted uploads can't be resumed, remove them anyway
+		utils.SweepTempFiles()
 	}
 	fs.ArchiveDownloadTaskManager = tache.NewManager[*fs.ArchiveDownloadTask](tache.WithWorks(setting.GetInt(conf.TaskDecompressDownloadThreadsNum, conf.Conf.Tasks.Decompress.Workers)), tache.WithPersistFunction(db.GetTaskDataFunc("decompress", conf.Conf.Tasks.Decompress.TaskPersistant), db.UpdateTaskDataFunc("decompress", conf.Conf.Tasks.Decompress.TaskPersistant)), tache.WithMaxRetry(conf.Conf.Tasks.Decompress.MaxRetry))
 	op.RegisterSettingChangingCallback(func() {
@@ -45,4 +49,25 @@ func InitTaskManager() {
 	op.RegisterSettingChangingCallback(func() {
 		fs.ArchiveContentUploadTaskManager.SetWorkersNumActive(taskFilterNegative(setting.GetInt(conf.TaskDecompressUploadThreadsNum, conf.Conf.Tasks.DecompressUpload.Workers)))
 	})
+	fs.HashTaskManager = tache.NewManager[*fs.HashManifestTask](tache.WithWorks(setting.GetInt(conf.TaskHashThreadsNum, conf.Conf.Tasks.Hash.Workers)), tache.WithMaxRetry(conf.Conf.Tasks.Hash.MaxRetry)) //hash will not support persist
+	op.RegisterSettingChangingCallback(func() {
+		fs.HashTaskManager.SetWorkersNumActive(taskFilterNegative(setting.GetInt(conf.TaskHashThreadsNum, conf.Conf.Tasks.Hash.Workers)))
+	})
+	fs.ReencryptTaskManager = tache.NewManager[*fs.ReencryptTask](tache.WithWorks(setting.GetInt(conf.TaskReencryptThreadsNum, conf.Conf.Tasks.Reencrypt.Workers)), tache.WithMaxRetry(conf.Conf.Tasks.Reencrypt.MaxRetry)) //reencrypt will not support persist
+	op.RegisterSettingChangingCallback(func() {
+		fs.ReencryptTaskManager.SetWorkersNumActive(taskFilterNegative(setting.GetInt(conf.TaskReencryptThreadsNum, conf.Conf.Tasks.Reencrypt.Workers)))
+	})
+	fs.MigrateTaskManager = tache.NewManager[*fs.MigrateTask](tache.WithWorks(setting.GetInt(conf.TaskMigrateThreadsNum, conf.Conf.Tasks.Migrate.Workers)), tache.WithMaxRetry(conf.Conf.Tasks.Migrate.MaxRetry)) //migrate will not support persist
+	op.RegisterSettingChangingCallback(func() {
+		fs.MigrateTaskManager.SetWorkersNumActive(taskFilterNegative(setting.GetInt(conf.TaskMigrateThreadsNum, conf.Conf.Tasks.Migrate.Workers)))
+	})
+	fs.SyncTaskManager = tache.NewManager[*fs.SyncTask](tache.WithWorks(setting.GetInt(conf.TaskSyncThreadsNum, conf.Conf.Tasks.Sync.Workers)), tache.WithMaxRetry(conf.Conf.Tasks.Sync.MaxRetry)) //sync will not support persist, the schedule starts it again
+	op.RegisterSettingChangingCallback(func() {
+		fs.SyncTaskManager.SetWorkersNumActive(taskFilterNegative(setting.GetInt(conf.TaskSyncThreadsNum, conf.Conf.Tasks.Sync.Workers)))
+	})
+	fs.ChunkUploadTaskManager = tache.NewManager[*fs.ChunkUploadTask](tache.WithWorks(setting.GetInt(conf.TaskChunkUploadThreadsNum, conf.Conf.Tasks.ChunkUpload.Workers))) //chunk upload will not support persist or retry, the request waiting for it has returned
+	op.RegisterSettingChangingCallback(func() {
+		fs.ChunkUploadTaskManager.SetWorkersNumActive(taskFilterNegative(setting.GetInt(conf.TaskChunkUploadThreadsNum, conf.Conf.Tasks.ChunkUpload.Workers)))
+	})
+	fs.StartSyncJobs()
 }
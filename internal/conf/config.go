@@ -60,6 +60,11 @@ type TasksConfig struct {
 	Copy               TaskConfig `json:"copy" envPrefix:"COPY_"`
 	Decompress         TaskConfig `json:"decompress" envPrefix:"DECOMPRESS_"`
 	DecompressUpload   TaskConfig `json:"decompress_upload" envPrefix:"DECOMPRESS_UPLOAD_"`
+	Hash               TaskConfig `json:"hash" envPrefix:"HASH_"`
+	Reencrypt          TaskConfig `json:"reencrypt" envPrefix:"REENCRYPT_"`
+	Migrate            TaskConfig `json:"migrate" envPrefix:"MIGRATE_"`
+	Sync               TaskConfig `json:"sync" envPrefix:"SYNC_"`
+	ChunkUpload        TaskConfig `json:"chunk_upload" envPrefix:"CHUNK_UPLOAD_"`
 	AllowRetryCanceled bool       `json:"allow_retry_canceled" env:"ALLOW_RETRY_CANCELED"`
 }
 
@@ -93,11 +98,27 @@ type SFTP struct {
 	Listen string `json:"listen" env:"LISTEN"`
 }
 
+type Metrics struct {
+	Enable bool `json:"enable" env:"ENABLE"`
+	// Token is required as a bearer token by /metrics if it's set
+	Token string `json:"token" env:"TOKEN"`
+}
+
+type Tracing struct {
+	Enable bool `json:"enable" env:"ENABLE"`
+	// Endpoint is the url of the OTLP/HTTP traces receiver, such as http://localhost:4318/v1/traces,
+	// the OTEL_EXPORTER_OTLP_* environment variables are used if it's empty
+	Endpoint    string  `json:"endpoint" env:"ENDPOINT"`
+	ServiceName string  `json:"service_name" env:"SERVICE_NAME"`
+	SampleRatio float64 `json:"sample_ratio" env:"SAMPLE_RATIO"`
+}
+
 type Config struct {
 	Force                 bool        `json:"force" env:"FORCE"`
 	SiteURL               string      `json:"site_url" env:"SITE_URL"`
 	Cdn                   string      `json:"cdn" env:"CDN"`
 	JwtSecret             string      `json:"jwt_secret" env:"JWT_SECRET"`
+	MasterKey             string      `json:"master_key" env:"MASTER_KEY"`
 	TokenExpiresIn        int         `json:"token_expires_in" env:"TOKEN_EXPIRES_IN"`
 	Database              Database    `json:"database" envPrefix:"DB_"`
 	Meilisearch           Meilisearch `json:"meilisearch" envPrefix:"MEILISEARCH_"`
@@ -115,6 +136,8 @@ type Config struct {
 	S3                    S3          `json:"s3" envPrefix:"S3_"`
 	FTP                   FTP         `json:"ftp" envPrefix:"FTP_"`
 	SFTP                  SFTP        `json:"sftp" envPrefix:"SFTP_"`
+	Metrics               Metrics     `json:"metrics" envPrefix:"METRICS_"`
+	Tracing               Tracing     `json:"tracing" envPrefix:"TRACING_"`
 	LastLaunchedVersion   string      `json:"last_launched_version"`
 }
 
@@ -184,6 +207,25 @@ func DefaultConfig() *Config {
 				Workers:  5,
 				MaxRetry: 2,
 			},
+			Hash: TaskConfig{
+				Workers:  2,
+				MaxRetry: 1,
+			},
+			Reencrypt: TaskConfig{
+				Workers:  1,
+				MaxRetry: 1,
+			},
+			Migrate: TaskConfig{
+				Workers:  1,
+				MaxRetry: 1,
+			},
+			Sync: TaskConfig{
+				Workers:  1,
+				MaxRetry: 1,
+			},
+			ChunkUpload: TaskConfig{
+				Workers: 5,
+			},
 			AllowRetryCanceled: false,
 		},
 		Cors: Cors{
@@ -212,6 +254,14 @@ func DefaultConfig() *Config {
 			Enable: false,
 			Listen: ":5222",
 		},
+		Metrics: Metrics{
+			Enable: false,
+		},
+		Tracing: Tracing{
+			Enable:      false,
+			ServiceName: "alist",
+			SampleRatio: 1,
+		},
 		LastLaunchedVersion: "",
 	}
 }
@@ -33,6 +33,7 @@ const (
 	PreviewArchivesByDefault = "preview_archives_by_default"
 	ReadMeAutoRender         = "readme_autorender"
 	FilterReadMeScripts      = "filter_readme_scripts"
+	WatermarkPaths           = "watermark_paths"
 	// global
 	HideFiles               = "hide_files"
 	CustomizeHead           = "customize_head"
@@ -125,6 +126,11 @@ const (
 	TaskCopyThreadsNum                    = "copy_task_threads_num"
 	TaskDecompressDownloadThreadsNum      = "decompress_download_task_threads_num"
 	TaskDecompressUploadThreadsNum        = "decompress_upload_task_threads_num"
+	TaskHashThreadsNum                    = "hash_task_threads_num"
+	TaskReencryptThreadsNum               = "reencrypt_task_threads_num"
+	TaskMigrateThreadsNum                 = "migrate_task_threads_num"
+	TaskSyncThreadsNum                    = "sync_task_threads_num"
+	TaskChunkUploadThreadsNum             = "chunk_upload_task_threads_num"
 	StreamMaxClientDownloadSpeed          = "max_client_download_speed"
 	StreamMaxClientUploadSpeed            = "max_client_upload_speed"
 	StreamMaxServerDownloadSpeed          = "max_server_download_speed"
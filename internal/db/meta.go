@@ -43,3 +43,11 @@ func GetMetas(pageIndex, pageSize int) (metas []model.Meta, count int64, err err
 func DeleteMetaById(id uint) error {
 	return errors.WithStack(db.Delete(&model.Meta{}, id).Error)
 }
+
+func GetAllMetas() ([]model.Meta, error) {
+	var metas []model.Meta
+	if err := db.Order(columnName("id")).Find(&metas).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get all metas")
+	}
+	return metas, nil
+}
@@ -0,0 +1,67 @@
+package db
+
+import (
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+func GetSyncJobById(id uint) (*model.SyncJob, error) {
+	var j model.SyncJob
+	if err := db.First(&j, id).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get sync job")
+	}
+	return &j, nil
+}
+
+func CreateSyncJob(j *model.SyncJob) error {
+	return errors.WithStack(db.Create(j).Error)
+}
+
+func UpdateSyncJob(j *model.SyncJob) error {
+	return errors.WithStack(db.Save(j).Error)
+}
+
+func DeleteSyncJobById(id uint) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_id = ?", id).Delete(&model.SyncState{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.SyncJob{}, id).Error
+	}))
+}
+
+func GetSyncJobs() ([]model.SyncJob, error) {
+	var jobs []model.SyncJob
+	if err := db.Order(columnName("id")).Find(&jobs).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get sync jobs")
+	}
+	return jobs, nil
+}
+
+func SetSyncJobLastRun(id uint, t time.Time) error {
+	return errors.WithStack(db.Model(&model.SyncJob{}).Where("id = ?", id).Update("last_run_at", t).Error)
+}
+
+func GetSyncStates(jobID uint) ([]model.SyncState, error) {
+	var states []model.SyncState
+	if err := db.Where("job_id = ?", jobID).Find(&states).Error; err != nil {
+		return nil, errors.Wrapf(err, "failed get sync states")
+	}
+	return states, nil
+}
+
+// ReplaceSyncStates replaces the states of the job with states
+func ReplaceSyncStates(jobID uint, states []model.SyncState) error {
+	return errors.WithStack(db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("job_id = ?", jobID).Delete(&model.SyncState{}).Error; err != nil {
+			return err
+		}
+		if len(states) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(states, 100).Error
+	}))
+}
@@ -0,0 +1,176 @@
+package dbfs
+
+import (
+	stdpath "path"
+	"strconv"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/pkg/errors"
+)
+
+// AliasObj is an alias listed in a directory: the object of its target under the name of the alias.
+// It carries the ID of the target, so that reading or listing it reads the target,
+// while renaming, moving or removing it changes only the alias.
+type AliasObj struct {
+	model.Obj
+	Alias Alias
+	// Target is the path of the target relative to Base
+	Target string
+	path   string
+}
+
+func (a *AliasObj) GetName() string {
+	return a.Alias.Name
+}
+
+func (a *AliasObj) GetPath() string {
+	return a.path
+}
+
+func (a *AliasObj) SetPath(path string) {
+	a.path = path
+}
+
+func (a *AliasObj) Thumb() string {
+	thumb, _ := model.GetThumb(a.Obj)
+	return thumb
+}
+
+// AliasTarget implements model.Alias
+func (a *AliasObj) AliasTarget() string {
+	return a.Target
+}
+
+// ListAliases returns the aliases not deleted in the directory whose targets are not deleted.
+// The aliases of a removed target are kept, they are listed again once it's restored.
+func (t *Tree) ListAliases(dirID int) ([]Alias, error) {
+	var aliases []Alias
+	err := t.DB.Where("directory_id = ? AND deleted = ?", dirID, false).
+		Where(t.DB.Where("is_dir = ? AND target_id IN (?)", false, t.DB.Model(&File{}).Select("id").Where("deleted = ?", false)).
+			Or("is_dir = ? AND target_id IN (?)", true, t.DB.Model(&Directory{}).Select("id").Where("deleted = ?", false))).
+		Find(&aliases).Error
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list aliases")
+	}
+	return aliases, nil
+}
+
+// GetAlias returns the alias not deleted
+func (t *Tree) GetAlias(id int) (*Alias, error) {
+	var a Alias
+	if err := t.DB.Where("id = ? AND deleted = ?", id, false).First(&a).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to get alias")
+	}
+	return &a, nil
+}
+
+// AliasTarget returns the target of the alias, a directory or a file
+func (t *Tree) AliasTarget(a *Alias) (*Directory, *File, error) {
+	if a.IsDir {
+		dir, err := t.GetDir(strconv.Itoa(a.TargetID))
+		return dir, nil, err
+	}
+	f, err := t.GetFile(strconv.Itoa(a.TargetID))
+	return nil, f, err
+}
+
+// TargetPath returns the path of the target of the alias relative to Base
+func (t *Tree) TargetPath(dir *Directory, f *File) string {
+	if dir != nil {
+		return t.DirPath(dir.ID)
+	}
+	return stdpath.Join(t.DirPath(f.DirectoryID), f.Name)
+}
+
+// MakeAlias creates an alias named name in the directory dirID to the file or directory targetID.
+// The target must be inside Base, and a directory can't be aliased inside itself.
+func (t *Tree) MakeAlias(dirID int, name string, targetID int, isDir bool) (*Alias, error) {
+	a := Alias{Name: t.NormName(name), DirectoryID: dirID, TargetID: targetID, IsDir: isDir}
+	dir, f, err := t.AliasTarget(&a)
+	if err != nil {
+		return nil, err
+	}
+	if dir != nil && (!t.InBase(dir.ID) || t.inside(dirID, dir.ID)) {
+		return nil, errors.New("can't alias the directory here")
+	}
+	if f != nil && !t.InBase(f.DirectoryID) {
+		return nil, errors.New("can't alias the file outside the root")
+	}
+	if err := t.checkName(dirID, name); err != nil {
+		return nil, err
+	}
+	if err := t.DB.Create(&a).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to create alias")
+	}
+	return &a, nil
+}
+
+// RenameAlias renames the alias, the target keeps its name. version is checked as in MoveDir.
+func (t *Tree) RenameAlias(id int, name string, version int64) (*Alias, error) {
+	a, err := t.GetAlias(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.checkName(a.DirectoryID, name); err != nil {
+		return nil, err
+	}
+	name = t.NormName(name)
+	if err := t.updateIf(a, &a.Version, version, map[string]interface{}{"name": name}); err != nil {
+		return nil, errors.Wrap(err, "failed to rename alias")
+	}
+	a.Name = name
+	return a, nil
+}
+
+// MoveAlias moves the alias into the directory dirID, the target stays where it is.
+// version is checked as in MoveDir.
+func (t *Tree) MoveAlias(id int, dirID int, version int64) (*Alias, error) {
+	a, err := t.GetAlias(id)
+	if err != nil {
+		return nil, err
+	}
+	if a.IsDir && t.inside(dirID, a.TargetID) {
+		return nil, errors.New("can't move the alias into its target")
+	}
+	if err := t.checkName(dirID, a.Name); err != nil {
+		return nil, err
+	}
+	if err := t.updateIf(a, &a.Version, version, map[string]interface{}{"directory_id": dirID}); err != nil {
+		return nil, errors.Wrap(err, "failed to move alias")
+	}
+	a.DirectoryID = dirID
+	return a, nil
+}
+
+// DeleteAlias removes the alias, the target is kept
+func (t *Tree) DeleteAlias(id int) error {
+	err := t.DB.Model(&Alias{}).Where("id = ?", id).Update("deleted", true).Error
+	return errors.Wrap(err, "failed to delete alias")
+}
+
+// inside reports whether the directory is dirID or one of its subdirectories
+func (t *Tree) inside(id, dirID int) bool {
+	_, ok := t.dirPath(id, dirID)
+	return ok
+}
+
+// checkName returns errs.ObjectAlreadyExists if a directory, file or alias named name is in the directory
+func (t *Tree) checkName(dirID int, name string) error {
+	for _, m := range []struct {
+		model  interface{}
+		column string
+	}{{&Directory{}, "parent_id"}, {&File{}, "directory_id"}, {&Alias{}, "directory_id"}} {
+		var n int64
+		if err := t.WhereName(t.DB.Model(m.model), name).Where(m.column+" = ? AND deleted = ?", dirID, false).
+			Count(&n).Error; err != nil {
+			return errors.Wrap(err, "failed to check existing names")
+		}
+		if n > 0 {
+			return errors.WithStack(errs.ObjectAlreadyExists)
+		}
+	}
+	return nil
+}
+
+var _ model.Alias = (*AliasObj)(nil)
@@ -0,0 +1,138 @@
+package dbfs
+
+import (
+	stdpath "path"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CreateLock saves the lock on a path relative to Base, see driver.Locker. The locks of the tree
+// are few, they're checked for conflicts in the transaction instead of by path queries.
+// The transaction locks the root directory of the tree first, so that the locks of the tree
+// are created one by one, by all the servers sharing the database.
+func (t *Tree) CreateLock(lock model.Lock) error {
+	l := PathLock{
+		Scope:     t.Scope,
+		Token:     lock.Token,
+		Path:      t.fullPath(lock.Path),
+		ZeroDepth: lock.ZeroDepth,
+		OwnerXML:  lock.OwnerXML,
+		Expires:   lock.Expires,
+	}
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		var root Directory
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("parent_id IS NULL AND database_id = ?", t.Scope).First(&root).Error; err != nil {
+			return errors.Wrap(err, "failed to lock root directory")
+		}
+		now := time.Now()
+		if err := tx.Where("database_id = ? AND expires < ?", t.Scope, now).Delete(&PathLock{}).Error; err != nil {
+			return errors.Wrap(err, "failed to delete expired locks")
+		}
+		var locks []PathLock
+		if err := tx.Where("database_id = ?", t.Scope).Find(&locks).Error; err != nil {
+			return errors.Wrap(err, "failed to get locks")
+		}
+		for _, other := range locks {
+			if other.Path == l.Path || (!other.ZeroDepth && isAncestor(other.Path, l.Path)) ||
+				(!l.ZeroDepth && isAncestor(l.Path, other.Path)) {
+				return errors.WithStack(errs.Locked)
+			}
+		}
+		return errors.Wrap(tx.Create(&l).Error, "failed to create lock")
+	})
+}
+
+// GetLock returns the lock not expired with the path relative to Base
+func (t *Tree) GetLock(token string) (*model.Lock, error) {
+	l, err := t.getLock(t.DB, token)
+	if err != nil {
+		return nil, err
+	}
+	return t.toLock(l)
+}
+
+// RefreshLock sets the expiry of the lock not expired. The expiry is checked by the update itself,
+// so a lock expiring meanwhile is not brought back
+func (t *Tree) RefreshLock(token string, expires time.Time) (*model.Lock, error) {
+	res := t.DB.Model(&PathLock{}).Where("database_id = ? AND token = ? AND expires >= ?", t.Scope, token, time.Now()).
+		UpdateColumn("expires", expires)
+	if res.Error != nil {
+		return nil, errors.Wrap(res.Error, "failed to refresh lock")
+	}
+	if res.RowsAffected == 0 {
+		return nil, errors.WithStack(errs.ObjectNotFound)
+	}
+	var l PathLock
+	err := t.DB.Where("database_id = ? AND token = ?", t.Scope, token).First(&l).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.WithStack(errs.ObjectNotFound)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get lock")
+	}
+	return t.toLock(&l)
+}
+
+// DeleteLock removes the lock, errs.ObjectNotFound if there isn't one
+func (t *Tree) DeleteLock(token string) error {
+	res := t.DB.Where("database_id = ? AND token = ?", t.Scope, token).Delete(&PathLock{})
+	if res.Error != nil {
+		return errors.Wrap(res.Error, "failed to delete lock")
+	}
+	if res.RowsAffected == 0 {
+		return errors.WithStack(errs.ObjectNotFound)
+	}
+	return nil
+}
+
+func (t *Tree) getLock(tx *gorm.DB, token string) (*PathLock, error) {
+	var l PathLock
+	err := tx.Where("database_id = ? AND token = ? AND expires >= ?", t.Scope, token, time.Now()).First(&l).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.WithStack(errs.ObjectNotFound)
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get lock")
+	}
+	return &l, nil
+}
+
+// toLock converts the path of the lock to be relative to Base,
+// the locks outside of Base are not found
+func (t *Tree) toLock(l *PathLock) (*model.Lock, error) {
+	base := t.fullPath("/")
+	p := l.Path
+	if base != "/" {
+		if p != base && !isAncestor(base, p) {
+			return nil, errors.WithStack(errs.ObjectNotFound)
+		}
+		p = "/" + strings.TrimPrefix(strings.TrimPrefix(p, base), "/")
+	}
+	return &model.Lock{
+		Token:     l.Token,
+		Path:      p,
+		ZeroDepth: l.ZeroDepth,
+		OwnerXML:  l.OwnerXML,
+		Expires:   l.Expires,
+	}, nil
+}
+
+// fullPath returns the path from the root of the tree of p relative to Base
+func (t *Tree) fullPath(p string) string {
+	if t.Base == 0 {
+		return stdpath.Join("/", p)
+	}
+	return stdpath.Join(t.FullDirPath(t.Base), p)
+}
+
+// isAncestor reports whether dir is a proper ancestor of p
+func isAncestor(dir, p string) bool {
+	return dir == "/" && p != "/" || strings.HasPrefix(p, dir+"/")
+}
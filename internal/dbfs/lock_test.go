@@ -0,0 +1,48 @@
+package dbfs
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestRefreshLockKeepsExpiredLock(t *testing.T) {
+	db, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&PathLock{}); err != nil {
+		t.Fatal(err)
+	}
+	tree := &Tree{DB: db, Scope: "s"}
+	expired := time.Now().Add(-time.Minute).Truncate(time.Second)
+	live := time.Now().Add(time.Minute).Truncate(time.Second)
+	locks := []PathLock{
+		{Scope: "s", Token: "expired", Path: "/a", Expires: expired},
+		{Scope: "s", Token: "live", Path: "/b", Expires: live},
+		{Scope: "other", Token: "other", Path: "/c", Expires: live},
+	}
+	if err := db.Create(&locks).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	later := time.Now().Add(time.Hour).Truncate(time.Second)
+	if _, err := tree.RefreshLock("expired", later); !errs.IsObjectNotFound(err) {
+		t.Fatalf("expect the expired lock not found, got %v", err)
+	}
+	var l PathLock
+	if err := db.Where("token = ?", "expired").First(&l).Error; err != nil || !l.Expires.Equal(expired) {
+		t.Fatalf("expect the expired lock not brought back, got %v %v", l.Expires, err)
+	}
+	if _, err := tree.RefreshLock("other", later); !errs.IsObjectNotFound(err) {
+		t.Fatalf("expect the lock of another tree not found, got %v", err)
+	}
+	lock, err := tree.RefreshLock("live", later)
+	if err != nil || lock.Path != "/b" || !lock.Expires.Equal(later) {
+		t.Fatalf("expect the lock refreshed, got %+v %v", lock, err)
+	}
+}
@@ -0,0 +1,199 @@
+package dbfs
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// Directory is a directory of a tree, the root of a tree has no parent
+type Directory struct {
+	ID       int    `json:"id" gorm:"primaryKey"`
+	Name     string `json:"name"`
+	ParentID *int   `json:"parent_id" gorm:"index"`
+	// Scope tells apart the trees sharing the tables, such as the storages of a driver
+	Scope     string    `json:"database_id" gorm:"column:database_id;index"`
+	Deleted   bool      `json:"deleted" gorm:"default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Version is increased by every change of the row, see Tree.CheckVersion
+	Version int64 `json:"version" gorm:"default:1"`
+}
+
+// File is a file of a tree. The data of a chunked file is kept in its FileChunk rows,
+// otherwise it's a single blob identified by BlobKey, or kept in Inline if BlobKey is empty
+type File struct {
+	ID          int    `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name"`
+	Size        int64  `json:"size"`
+	SHA1        string `json:"sha1" gorm:"index"`
+	MD5         string `json:"md5"`
+	SHA256      string `json:"sha256"`
+	BlobKey     string `json:"notion_page_id" gorm:"column:notion_page_id"`
+	DirectoryID int    `json:"directory_id" gorm:"index"`
+	IsChunked   bool   `json:"is_chunked" gorm:"default:false"`
+	ChunkSize   int64  `json:"chunk_size" gorm:"default:0"`
+	// Packed is set when several small files are stored in the blob BlobKey,
+	// BlobIndex is the position of this file among them
+	Packed    bool `json:"packed" gorm:"default:false"`
+	BlobIndex int  `json:"blob_index" gorm:"default:0"`
+	// Inline is the data of a small file stored in the row instead of a blob, nil for an empty file
+	Inline []byte `json:"inline" gorm:"column:inline_data"`
+	// ThumbKey is the blob of the thumbnail, empty if it's not generated yet
+	ThumbKey string `json:"thumb_page_id" gorm:"column:thumb_page_id"`
+	// VerifiedAt is the last time the blob of an unchunked file was checked against its SHA1
+	VerifiedAt *time.Time `json:"verified_at"`
+	Deleted    bool       `json:"deleted" gorm:"default:false"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	// Version is increased by every change of the row, see Tree.CheckVersion
+	Version int64 `json:"version" gorm:"default:1"`
+}
+
+// FileChunk is a chunk of a chunked file, see chunkstore.Chunk
+type FileChunk struct {
+	ID          int    `json:"id" gorm:"primaryKey"`
+	FileID      int    `json:"file_id" gorm:"index"`
+	ChunkIndex  int    `json:"chunk_index"`
+	ChunkSize   int64  `json:"chunk_size"`
+	StartOffset int64  `json:"start_offset"`
+	EndOffset   int64  `json:"end_offset"`
+	BlobKey     string `json:"notion_page_id" gorm:"column:notion_page_id"`
+	// SHA1 is indexed to find the chunks with the same data, see chunkstore.Deduper
+	SHA1 string `json:"sha1" gorm:"index"`
+	// Nonce and KeyID are set when the chunk is encrypted, SHA1 is the hash of the encrypted data then
+	Nonce string `json:"nonce"`
+	KeyID string `json:"key_id"`
+	// VerifiedAt is the last time the blob of the chunk was checked to be available
+	VerifiedAt *time.Time `json:"verified_at"`
+	Deleted    bool       `json:"deleted" gorm:"default:false"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+}
+
+// FileVersion is a previous version of a file kept when it's overwritten,
+// pointing to the replaced File row which is marked as deleted
+type FileVersion struct {
+	ID            int       `json:"id" gorm:"primaryKey"`
+	FileID        int       `json:"file_id" gorm:"index"`
+	VersionFileID int       `json:"version_file_id"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// IsInline tells if the data of the file is kept in the row, which is the case of the empty files
+func (f *File) IsInline() bool {
+	return !f.IsChunked && f.BlobKey == ""
+}
+
+// FileTag is a key/value tag of a file, each key is set at most once on a file
+type FileTag struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	FileID    int       `json:"file_id" gorm:"uniqueIndex:idx_file_tag_key"`
+	Key       string    `json:"key" gorm:"size:191;uniqueIndex:idx_file_tag_key;index:idx_tag_key_value"`
+	Value     string    `json:"value" gorm:"size:191;index:idx_tag_key_value"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Alias is an entry of a directory pointing to a file or directory of the same tree by its ID,
+// so that the same data is listed in several directories without copying its metadata
+type Alias struct {
+	ID          int    `json:"id" gorm:"primaryKey"`
+	Name        string `json:"name"`
+	DirectoryID int    `json:"directory_id" gorm:"index"`
+	TargetID    int    `json:"target_id" gorm:"index"`
+	// IsDir tells whether TargetID is a Directory or a File
+	IsDir     bool      `json:"is_dir" gorm:"default:false"`
+	Deleted   bool      `json:"deleted" gorm:"default:false"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	// Version is increased by every change of the row, see Tree.CheckVersion
+	Version int64 `json:"version" gorm:"default:1"`
+}
+
+// PathLock is a WebDAV lock on a path of a tree. The path is from the root of the tree,
+// so the storages mounting different directories of the tree see the same locks.
+type PathLock struct {
+	ID        int       `json:"id" gorm:"primaryKey"`
+	Scope     string    `json:"database_id" gorm:"column:database_id;index"`
+	Token     string    `json:"token" gorm:"size:191;uniqueIndex"`
+	Path      string    `json:"path"`
+	ZeroDepth bool      `json:"zero_depth" gorm:"default:false"`
+	OwnerXML  string    `json:"owner_xml"`
+	Expires   time.Time `json:"expires" gorm:"index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// SetHashes saves the hashes computed in hi, the other hashes are kept
+func (f *File) SetHashes(hi *utils.HashInfo) {
+	if h := hi.GetHash(utils.SHA1); h != "" {
+		f.SHA1 = h
+	}
+	if h := hi.GetHash(utils.MD5); h != "" {
+		f.MD5 = h
+	}
+	if h := hi.GetHash(utils.SHA256); h != "" {
+		f.SHA256 = h
+	}
+}
+
+// HashInfo returns the hashes saved of the file
+func (f *File) HashInfo() utils.HashInfo {
+	h := make(map[*utils.HashType]string)
+	if f.SHA1 != "" {
+		h[utils.SHA1] = f.SHA1
+	}
+	if f.MD5 != "" {
+		h[utils.MD5] = f.MD5
+	}
+	if f.SHA256 != "" {
+		h[utils.SHA256] = f.SHA256
+	}
+	return utils.NewHashInfoByMap(h)
+}
+
+// Object is a directory or file of a tree with the version of its row
+type Object struct {
+	model.Object
+	Ver int64
+}
+
+func (o *Object) Version() int64 {
+	return o.Ver
+}
+
+// ObjThumb is a file of a tree with its thumbnail and the version of its row
+type ObjThumb struct {
+	model.ObjThumb
+	Ver int64
+}
+
+func (o *ObjThumb) Version() int64 {
+	return o.Ver
+}
+
+func DirToObj(dir *Directory) model.Obj {
+	return &Object{
+		Object: model.Object{
+			ID:       strconv.Itoa(dir.ID),
+			Name:     dir.Name,
+			Modified: dir.UpdatedAt,
+			IsFolder: true,
+		},
+		Ver: dir.Version,
+	}
+}
+
+func FileToObj(f *File) model.Obj {
+	return &Object{
+		Object: model.Object{
+			ID:       strconv.Itoa(f.ID),
+			Name:     f.Name,
+			Size:     f.Size,
+			Modified: f.UpdatedAt,
+			HashInfo: f.HashInfo(),
+		},
+		Ver: f.Version,
+	}
+}
@@ -0,0 +1,41 @@
+package dbfs
+
+import (
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+)
+
+// NormName returns the name in the normalization form of the tree
+func (t *Tree) NormName(name string) string {
+	switch t.Norm {
+	case "NFC":
+		return norm.NFC.String(name)
+	case "NFD":
+		return norm.NFD.String(name)
+	default:
+		return name
+	}
+}
+
+// WhereName adds the condition of the rows named name to tx. With a normalization form set,
+// the names in the other forms match too, so that the rows created before or by clients
+// sending another form are found. With FoldCase the names are compared in lower case.
+func (t *Tree) WhereName(tx *gorm.DB, name string) *gorm.DB {
+	names := []string{name}
+	if t.Norm != "" {
+		for _, n := range []string{norm.NFC.String(name), norm.NFD.String(name)} {
+			if n != name {
+				names = append(names, n)
+			}
+		}
+	}
+	if !t.FoldCase {
+		return tx.Where("name IN ?", names)
+	}
+	for i := range names {
+		names[i] = strings.ToLower(names[i])
+	}
+	return tx.Where("LOWER(name) IN ?", names)
+}
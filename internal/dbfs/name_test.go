@@ -0,0 +1,80 @@
+package dbfs
+
+import (
+	"strconv"
+	"testing"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestTreeNormalization(t *testing.T) {
+	nfc, nfd := norm.NFC.String("café"), norm.NFD.String("café")
+	tree := newTestTree(t, "s", nil)
+	tree.Norm = "NFC"
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// new names are normalized
+	dir, err := tree.MakeDir(root.ID, nfd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dir.Name != nfc {
+		t.Fatalf("expect the name in NFC, got %q", dir.Name)
+	}
+	if again, err := tree.MakeDir(root.ID, nfc); err != nil || again.ID != dir.ID {
+		t.Fatalf("expect the directory reused, got %v %v", again, err)
+	}
+	// the rows kept in another form are found too
+	f := &File{Name: nfd, DirectoryID: root.ID}
+	if err := tree.DB.Create(f).Error; err != nil {
+		t.Fatal(err)
+	}
+	if found, err := tree.FindFile(root.ID, nfc); err != nil || found == nil || found.ID != f.ID {
+		t.Fatalf("expect the NFD file found by its NFC name, got %v %v", found, err)
+	}
+	renamed, err := tree.RenameFile(strconv.Itoa(f.ID), nfd, 0)
+	if err != nil || renamed.Name != nfc {
+		t.Fatalf("expect the renamed file in NFC, got %v %v", renamed, err)
+	}
+
+	// without a form the names are kept and compared as they are
+	plain := newTestTree(t, "p", tree.DB)
+	proot, err := plain.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := plain.MakeDir(proot.ID, nfd)
+	if err != nil || a.Name != nfd {
+		t.Fatalf("expect the name kept, got %v %v", a, err)
+	}
+	if b, err := plain.MakeDir(proot.ID, nfc); err != nil || b.ID == a.ID {
+		t.Fatalf("expect another directory, got %v %v", b, err)
+	}
+}
+
+func TestTreeFoldCase(t *testing.T) {
+	tree := newTestTree(t, "s", nil)
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.DB.Create(&File{Name: "Readme.md", DirectoryID: root.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+	if found, err := tree.FindFile(root.ID, "README.MD"); err != nil || found != nil {
+		t.Fatalf("expect the names case-sensitive by default, got %v %v", found, err)
+	}
+	tree.FoldCase = true
+	if found, err := tree.FindFile(root.ID, "README.MD"); err != nil || found == nil || found.Name != "Readme.md" {
+		t.Fatalf("expect Readme.md found, got %v %v", found, err)
+	}
+	dir, err := tree.MakeDir(root.ID, "Docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again, err := tree.MakeDir(root.ID, "docs"); err != nil || again.ID != dir.ID || again.Name != "Docs" {
+		t.Fatalf("expect Docs reused, got %v %v", again, err)
+	}
+}
@@ -0,0 +1,87 @@
+package dbfs
+
+import (
+	stdpath "path"
+	"sort"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// likeEscaper escapes the wildcards of LIKE with "!", given by ESCAPE as SQLite has no default
+// escape character and the backslash would need escaping in the string literals of MySQL
+var likeEscaper = strings.NewReplacer(`!`, `!!`, `%`, `!%`, `_`, `!_`)
+
+// Search returns the directories and files under the directory dirID whose name contains keywords,
+// directories first, from offset and at most limit nodes. scope is that of model.SearchReq and the
+// parents of the nodes are relative to the directory dirID. The directories are loaded to find the
+// subtree, so only the files are queried by name in the database, which is fast with many files.
+func (t *Tree) Search(dirID int, keywords string, scope, offset, limit int) ([]model.SearchNode, int64, error) {
+	var dirs []Directory
+	if err := t.DB.Select("id", "name", "parent_id").Where("database_id = ? AND deleted = ?", t.Scope, false).Find(&dirs).Error; err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list directories")
+	}
+	children := make(map[int][]Directory)
+	for _, dir := range dirs {
+		if dir.ParentID != nil {
+			children[*dir.ParentID] = append(children[*dir.ParentID], dir)
+		}
+	}
+	// relative paths of the directories of the subtree, walked level by level
+	paths := map[int]string{dirID: "/"}
+	ids := []int{dirID}
+	var dirNodes []model.SearchNode
+	lower := strings.ToLower(keywords)
+	level := []int{dirID}
+	for depth := 0; depth < maxDirDepth && len(level) > 0; depth++ {
+		var next []int
+		for _, id := range level {
+			for _, child := range children[id] {
+				if _, ok := paths[child.ID]; ok {
+					continue
+				}
+				paths[child.ID] = stdpath.Join(paths[id], child.Name)
+				ids = append(ids, child.ID)
+				next = append(next, child.ID)
+				if scope != 2 && strings.Contains(strings.ToLower(child.Name), lower) {
+					dirNodes = append(dirNodes, model.SearchNode{Parent: paths[id], Name: child.Name, IsDir: true})
+				}
+			}
+		}
+		level = next
+	}
+	sort.Slice(dirNodes, func(i, j int) bool {
+		return stdpath.Join(dirNodes[i].Parent, dirNodes[i].Name) < stdpath.Join(dirNodes[j].Parent, dirNodes[j].Name)
+	})
+
+	var fileCount int64
+	var files []File
+	if scope != 1 {
+		query := func() *gorm.DB {
+			return t.DB.Model(&File{}).Where("name LIKE ? ESCAPE '!' AND deleted = ? AND directory_id IN ?",
+				"%"+likeEscaper.Replace(keywords)+"%", false, ids)
+		}
+		if err := query().Count(&fileCount).Error; err != nil {
+			return nil, 0, errors.Wrap(err, "failed to count files")
+		}
+		fileOffset := max(offset-len(dirNodes), 0)
+		fileLimit := limit - max(min(len(dirNodes)-offset, limit), 0)
+		if fileLimit > 0 && int64(fileOffset) < fileCount {
+			if err := query().Select("id", "name", "size", "directory_id").Order("id").
+				Offset(fileOffset).Limit(fileLimit).Find(&files).Error; err != nil {
+				return nil, 0, errors.Wrap(err, "failed to search files")
+			}
+		}
+	}
+
+	var nodes []model.SearchNode
+	if offset < len(dirNodes) {
+		nodes = append(nodes, dirNodes[offset:min(offset+limit, len(dirNodes))]...)
+	}
+	for _, f := range files {
+		nodes = append(nodes, model.SearchNode{Parent: paths[f.DirectoryID], Name: f.Name, Size: f.Size})
+	}
+	return nodes, int64(len(dirNodes)) + fileCount, nil
+}
@@ -0,0 +1,109 @@
+package dbfs
+
+import (
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestTreeSearch(t *testing.T) {
+	tree := newTestTree(t, "s", nil)
+	other := newTestTree(t, "o", tree.DB)
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reports, err := tree.MakeDir(root.ID, "Reports")
+	if err != nil {
+		t.Fatal(err)
+	}
+	old, err := tree.MakeDir(reports.ID, "old report")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, f := range []*File{
+		{Name: "report-1.pdf", Size: 1, DirectoryID: root.ID},
+		{Name: "report-2.pdf", Size: 2, DirectoryID: reports.ID},
+		{Name: "report-3.pdf", Size: 3, DirectoryID: old.ID},
+		{Name: "notes.txt", Size: 4, DirectoryID: reports.ID},
+		{Name: "100%.txt", Size: 5, DirectoryID: root.ID},
+		{Name: "1000.txt", Size: 6, DirectoryID: root.ID},
+		{Name: "report-deleted.pdf", Size: 7, DirectoryID: root.ID, Deleted: true},
+	} {
+		if err := tree.DB.Create(f).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	otherRoot, err := other.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.DB.Create(&File{Name: "report-other.pdf", DirectoryID: otherRoot.ID}).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	search := func(dirID int, keywords string, scope, offset, limit int) ([]model.SearchNode, int64) {
+		nodes, total, err := tree.Search(dirID, keywords, scope, offset, limit)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nodes, total
+	}
+	paths := func(nodes []model.SearchNode) []string {
+		var ps []string
+		for _, n := range nodes {
+			ps = append(ps, n.Parent+"|"+n.Name)
+		}
+		return ps
+	}
+	equal := func(got, want []string) bool {
+		if len(got) != len(want) {
+			return false
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				return false
+			}
+		}
+		return true
+	}
+
+	// the directories match case-insensitively and come first, the deleted files and other scopes are left out
+	nodes, total := search(root.ID, "report", 0, 0, 10)
+	want := []string{"/|Reports", "/Reports|old report", "/|report-1.pdf", "/Reports|report-2.pdf", "/Reports/old report|report-3.pdf"}
+	if got := paths(nodes); total != 5 || !equal(got, want) {
+		t.Fatalf("expect %v, got %v of %d", want, got, total)
+	}
+	if !nodes[0].IsDir || nodes[2].IsDir || nodes[2].Size != 1 {
+		t.Fatalf("expect the directory and file flags kept, got %+v", nodes)
+	}
+
+	// a page may span the directories and the files
+	nodes, total = search(root.ID, "report", 0, 1, 2)
+	if got := paths(nodes); total != 5 || !equal(got, want[1:3]) {
+		t.Fatalf("expect %v, got %v of %d", want[1:3], got, total)
+	}
+	nodes, _ = search(root.ID, "report", 0, 4, 2)
+	if got := paths(nodes); !equal(got, want[4:]) {
+		t.Fatalf("expect %v, got %v", want[4:], got)
+	}
+
+	// scope 1 searches the directories only, scope 2 the files only
+	if nodes, total = search(root.ID, "report", 1, 0, 10); total != 2 || !equal(paths(nodes), want[:2]) {
+		t.Fatalf("expect the directories only, got %v of %d", paths(nodes), total)
+	}
+	if nodes, total = search(root.ID, "report", 2, 0, 10); total != 3 || !equal(paths(nodes), want[2:]) {
+		t.Fatalf("expect the files only, got %v of %d", paths(nodes), total)
+	}
+
+	// the parents are relative to the searched directory
+	nodes, _ = search(reports.ID, "report", 0, 0, 10)
+	if got, want := paths(nodes), []string{"/|old report", "/|report-2.pdf", "/old report|report-3.pdf"}; !equal(got, want) {
+		t.Fatalf("expect %v, got %v", want, got)
+	}
+
+	// the wildcards of LIKE match literally
+	if nodes, _ = search(root.ID, "100%", 0, 0, 10); !equal(paths(nodes), []string{"/|100%.txt"}) {
+		t.Fatalf("expect 100%%.txt only, got %v", paths(nodes))
+	}
+}
@@ -0,0 +1,75 @@
+package dbfs
+
+import (
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// Tags returns the tags of the file
+func (t *Tree) Tags(fileID int) (map[string]string, error) {
+	var tags []FileTag
+	if err := t.DB.Where("file_id = ?", fileID).Find(&tags).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to get tags")
+	}
+	res := make(map[string]string, len(tags))
+	for _, tag := range tags {
+		res[tag.Key] = tag.Value
+	}
+	return res, nil
+}
+
+// SetTags sets the tags of set on the file, replacing the values of the keys already set,
+// and removes the keys of remove
+func (t *Tree) SetTags(fileID int, set map[string]string, remove []string) error {
+	return t.DB.Transaction(func(tx *gorm.DB) error {
+		return SetFileTags(tx, fileID, set, remove)
+	})
+}
+
+// SetFileTags is SetTags in the transaction tx, such as when updating many files at once
+func SetFileTags(tx *gorm.DB, fileID int, set map[string]string, remove []string) error {
+	keys := append([]string{}, remove...)
+	for key := range set {
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := tx.Where("file_id = ? AND `key` IN ?", fileID, keys).Delete(&FileTag{}).Error; err != nil {
+		return errors.Wrap(err, "failed to delete tags")
+	}
+	if len(set) == 0 {
+		return nil
+	}
+	tags := make([]FileTag, 0, len(set))
+	for key, value := range set {
+		tags = append(tags, FileTag{FileID: fileID, Key: key, Value: value})
+	}
+	if err := tx.Create(&tags).Error; err != nil {
+		return errors.Wrap(err, "failed to create tags")
+	}
+	return nil
+}
+
+// MoveTags moves the tags of the file from to the file to, such as when a file is replaced by a new upload
+func MoveTags(tx *gorm.DB, from, to int) error {
+	if err := tx.Model(&FileTag{}).Where("file_id = ?", from).Update("file_id", to).Error; err != nil {
+		return errors.Wrap(err, "failed to move tags")
+	}
+	return nil
+}
+
+// FindByTag returns the files not deleted of the tree having the tag key,
+// with the value if value isn't empty
+func (t *Tree) FindByTag(key, value string) ([]File, error) {
+	tagged := t.DB.Model(&FileTag{}).Select("file_id").Where("`key` = ?", key)
+	if value != "" {
+		tagged = tagged.Where("value = ?", value)
+	}
+	var files []File
+	if err := t.DB.Where("id IN (?) AND directory_id IN (?) AND deleted = ?", tagged, t.DirIDs(t.DB), false).
+		Order("id").Find(&files).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to find files by tag")
+	}
+	return files, nil
+}
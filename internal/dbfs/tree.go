@@ -0,0 +1,430 @@
+// Package dbfs keeps a virtual file system in database tables: the directory tree, the files
+// and the chunks of large files, while the data is stored as blobs by a pluggable backend
+// (see chunkstore.Backend). A driver built on it only implements the blob storage.
+package dbfs
+
+import (
+	stdpath "path"
+	"strconv"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/pkg/errors"
+	"gorm.io/gorm"
+)
+
+// maxDirDepth limits the levels walked up to the root, in case the parents form a loop
+const maxDirDepth = 256
+
+// Migrate creates or updates the tables of the trees
+func Migrate(db *gorm.DB) error {
+	return db.AutoMigrate(&Directory{}, &File{}, &FileChunk{}, &FileVersion{}, &FileTag{}, &Alias{}, &PathLock{})
+}
+
+// Tree is the directory tree of a scope in the tables
+type Tree struct {
+	DB    *gorm.DB
+	Scope string
+	// Norm is the Unicode normalization form of the names of new directories and files,
+	// "NFC" or "NFD", empty to keep the names as they are
+	Norm string
+	// FoldCase makes the name lookups of FindFile and MakeDir case-insensitive
+	FoldCase bool
+	// Base is the directory mounted as the root, the paths of DirPath and ObjPath are relative to it;
+	// 0 for the root of the tree
+	Base int
+}
+
+// NewTree returns the tree of scope, creating its root directory if missing
+func NewTree(db *gorm.DB, scope string) (*Tree, error) {
+	t := &Tree{DB: db, Scope: scope}
+	var root Directory
+	err := db.Where("parent_id IS NULL AND database_id = ?", scope).First(&root).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		root = Directory{Name: "/", Scope: scope}
+		err = db.Create(&root).Error
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to init root directory")
+	}
+	return t, nil
+}
+
+// Root returns the root directory of the tree
+func (t *Tree) Root() (*Directory, error) {
+	var root Directory
+	if err := t.DB.Where("parent_id IS NULL AND database_id = ?", t.Scope).First(&root).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to get root directory")
+	}
+	return &root, nil
+}
+
+// DirIDs is the subquery of the ids of all directories of the tree
+func (t *Tree) DirIDs(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&Directory{}).Select("id").Where("database_id = ?", t.Scope)
+}
+
+// FileIDs is the subquery of the ids of all files of the tree
+func (t *Tree) FileIDs(tx *gorm.DB) *gorm.DB {
+	return tx.Model(&File{}).Select("id").Where("directory_id IN (?)", t.DirIDs(tx))
+}
+
+// List returns the directories and files not deleted in the directory
+func (t *Tree) List(dirID int) ([]Directory, []File, error) {
+	var dirs []Directory
+	if err := t.DB.Where("parent_id = ? AND database_id = ? AND deleted = ?", dirID, t.Scope, false).Find(&dirs).Error; err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list directories")
+	}
+	var files []File
+	if err := t.DB.Where("directory_id = ? AND deleted = ?", dirID, false).Find(&files).Error; err != nil {
+		return nil, nil, errors.Wrap(err, "failed to list files")
+	}
+	return dirs, files, nil
+}
+
+// ListFunc calls fn with the directories and then the files not deleted in the directory,
+// one of dir and f is set. The files are read from a cursor instead of loaded at once.
+func (t *Tree) ListFunc(dirID int, fn func(dir *Directory, f *File) error) error {
+	var dirs []Directory
+	if err := t.DB.Where("parent_id = ? AND database_id = ? AND deleted = ?", dirID, t.Scope, false).Find(&dirs).Error; err != nil {
+		return errors.Wrap(err, "failed to list directories")
+	}
+	for i := range dirs {
+		if err := fn(&dirs[i], nil); err != nil {
+			return err
+		}
+	}
+	rows, err := t.DB.Model(&File{}).Where("directory_id = ? AND deleted = ?", dirID, false).Rows()
+	if err != nil {
+		return errors.Wrap(err, "failed to list files")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f File
+		if err := t.DB.ScanRows(rows, &f); err != nil {
+			return errors.Wrap(err, "failed to read file")
+		}
+		if err := fn(nil, &f); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(rows.Err(), "failed to list files")
+}
+
+// GetDir returns the directory not deleted
+func (t *Tree) GetDir(id string) (*Directory, error) {
+	var dir Directory
+	if err := t.DB.Where("id = ? AND deleted = ?", id, false).First(&dir).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to get directory")
+	}
+	return &dir, nil
+}
+
+// GetFile returns the file not deleted
+func (t *Tree) GetFile(id string) (*File, error) {
+	var f File
+	if err := t.DB.Where("id = ? AND deleted = ?", id, false).First(&f).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to get file")
+	}
+	return &f, nil
+}
+
+// FindFile returns the file named name in the directory, nil if there isn't one
+func (t *Tree) FindFile(dirID int, name string) (*File, error) {
+	var f File
+	err := t.WhereName(t.DB, name).Where("directory_id = ? AND deleted = ?", dirID, false).First(&f).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find file")
+	}
+	return &f, nil
+}
+
+// MakeDir creates the directory, or returns the existing one with the same name
+func (t *Tree) MakeDir(parentID int, name string) (*Directory, error) {
+	var dir Directory
+	err := t.WhereName(t.DB, name).Where("parent_id = ? AND deleted = ?", parentID, false).First(&dir).Error
+	if err == nil {
+		return &dir, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errors.Wrap(err, "failed to check existing directory")
+	}
+	dir = Directory{Name: t.NormName(name), ParentID: &parentID, Scope: t.Scope}
+	if err := t.DB.Create(&dir).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to create directory")
+	}
+	return &dir, nil
+}
+
+// MoveDir moves the directory into the directory parentID. version is the Version of the directory
+// when it was read by the caller, errs.ObjectModified is returned if it was changed since then;
+// a zero version skips the check.
+func (t *Tree) MoveDir(id string, parentID int, version int64) (*Directory, error) {
+	dir, err := t.GetDir(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.updateIf(dir, &dir.Version, version, map[string]interface{}{"parent_id": parentID}); err != nil {
+		return nil, errors.Wrap(err, "failed to move directory")
+	}
+	dir.ParentID = &parentID
+	return dir, nil
+}
+
+// MoveFile moves the file into the directory dirID, version is checked as in MoveDir
+func (t *Tree) MoveFile(id string, dirID int, version int64) (*File, error) {
+	f, err := t.GetFile(id)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.updateIf(f, &f.Version, version, map[string]interface{}{"directory_id": dirID}); err != nil {
+		return nil, errors.Wrap(err, "failed to move file")
+	}
+	f.DirectoryID = dirID
+	return f, nil
+}
+
+// RenameDir renames the directory, version is checked as in MoveDir
+func (t *Tree) RenameDir(id, name string, version int64) (*Directory, error) {
+	dir, err := t.GetDir(id)
+	if err != nil {
+		return nil, err
+	}
+	name = t.NormName(name)
+	if err := t.updateIf(dir, &dir.Version, version, map[string]interface{}{"name": name}); err != nil {
+		return nil, errors.Wrap(err, "failed to rename directory")
+	}
+	dir.Name = name
+	return dir, nil
+}
+
+// RenameFile renames the file, version is checked as in MoveDir
+func (t *Tree) RenameFile(id, name string, version int64) (*File, error) {
+	f, err := t.GetFile(id)
+	if err != nil {
+		return nil, err
+	}
+	name = t.NormName(name)
+	if err := t.updateIf(f, &f.Version, version, map[string]interface{}{"name": name}); err != nil {
+		return nil, errors.Wrap(err, "failed to rename file")
+	}
+	f.Name = name
+	return f, nil
+}
+
+// DeleteFile marks the file deleted, version is checked as in MoveDir.
+// A file already deleted or missing is not an error.
+func (t *Tree) DeleteFile(id string, version int64) error {
+	f, err := t.GetFile(id)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return errors.Wrap(t.updateIf(f, &f.Version, version, map[string]interface{}{"deleted": true}), "failed to delete file")
+}
+
+// CheckVersion returns errs.ObjectModified if the directory or file of obj was changed since obj was read,
+// that's the Version of its row isn't the one obj carries, see model.Versioned.
+// The objects without a version are not checked.
+func (t *Tree) CheckVersion(obj model.Obj) error {
+	version, ok := model.GetVersion(obj)
+	if !ok {
+		return nil
+	}
+	var current int64
+	var err error
+	if obj.IsDir() {
+		var dir *Directory
+		if dir, err = t.GetDir(obj.GetID()); err == nil {
+			current = dir.Version
+		}
+	} else {
+		var f *File
+		if f, err = t.GetFile(obj.GetID()); err == nil {
+			current = f.Version
+		}
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if current != version {
+		return errors.WithStack(errs.ObjectModified)
+	}
+	return nil
+}
+
+// NextVersion is the update of the Version column of a row changed
+var NextVersion = gorm.Expr("version + 1")
+
+// updateIf updates the columns of value, a row read with the version *current, unless it was changed
+// since version or is changed concurrently: the update is conditional on the version being still *current,
+// and increases it. The new version is set to *current.
+func (t *Tree) updateIf(value interface{}, current *int64, version int64, columns map[string]interface{}) error {
+	if version != 0 && *current != version {
+		return errors.WithStack(errs.ObjectModified)
+	}
+	columns["version"] = NextVersion
+	res := t.DB.Model(value).Where("version = ?", *current).Updates(columns)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return errors.WithStack(errs.ObjectModified)
+	}
+	*current++
+	return nil
+}
+
+// DirPath returns the path of the directory relative to Base, walking up the parents
+// since the objects listed don't carry their paths
+func (t *Tree) DirPath(dirID int) string {
+	p, _ := t.dirPath(dirID, t.Base)
+	return p
+}
+
+// FullDirPath returns the path of the directory from the root of the tree, ignoring Base
+func (t *Tree) FullDirPath(dirID int) string {
+	p, _ := t.dirPath(dirID, 0)
+	return p
+}
+
+// InBase reports whether the directory is Base or inside it
+func (t *Tree) InBase(dirID int) bool {
+	_, ok := t.dirPath(dirID, t.Base)
+	return ok
+}
+
+// dirPath walks up from the directory until base, or the root of the tree if base is 0,
+// and reports whether base was reached
+func (t *Tree) dirPath(dirID, base int) (string, bool) {
+	var names []string
+	reached := false
+	for depth := 0; depth < maxDirDepth; depth++ {
+		if dirID == base {
+			reached = true
+			break
+		}
+		var dir Directory
+		if err := t.DB.Where("id = ?", dirID).First(&dir).Error; err != nil {
+			break
+		}
+		if dir.ParentID == nil {
+			reached = base == 0
+			break
+		}
+		names = append([]string{dir.Name}, names...)
+		dirID = *dir.ParentID
+	}
+	return "/" + strings.Join(names, "/"), reached
+}
+
+// ParentID returns the id of the directory of obj, 0 if it's not found
+func (t *Tree) ParentID(obj model.Obj) int {
+	if a, ok := obj.(*AliasObj); ok {
+		return a.Alias.DirectoryID
+	}
+	if obj.IsDir() {
+		var dir Directory
+		if err := t.DB.Where("id = ?", obj.GetID()).First(&dir).Error; err == nil && dir.ParentID != nil {
+			return *dir.ParentID
+		}
+		return 0
+	}
+	var f File
+	if err := t.DB.Where("id = ?", obj.GetID()).First(&f).Error; err == nil {
+		return f.DirectoryID
+	}
+	return 0
+}
+
+// ObjPath returns the path of obj relative to Base
+func (t *Tree) ObjPath(obj model.Obj) string {
+	if a, ok := obj.(*AliasObj); ok {
+		return stdpath.Join(t.DirPath(a.Alias.DirectoryID), a.Alias.Name)
+	}
+	if obj.IsDir() {
+		id, _ := strconv.Atoi(obj.GetID())
+		return t.DirPath(id)
+	}
+	return stdpath.Join(t.DirPath(t.ParentID(obj)), obj.GetName())
+}
+
+// subtreeSQL selects the ids of a directory and its subdirectories not deleted, up to maxDirDepth levels
+const subtreeSQL = `WITH RECURSIVE subtree (id, depth) AS (
+	SELECT id, 0 FROM directories WHERE id = ?
+	UNION ALL
+	SELECT directories.id, subtree.depth + 1 FROM directories JOIN subtree ON directories.parent_id = subtree.id
+	WHERE directories.deleted = ? AND subtree.depth < ?
+) SELECT id FROM subtree`
+
+// SubtreeDirIDs returns the ids of the directory and all its subdirectories not deleted,
+// read by one recursive query instead of a query per level
+func (t *Tree) SubtreeDirIDs(tx *gorm.DB, dirID int) ([]int, error) {
+	var ids []int
+	if err := tx.Raw(subtreeSQL, dirID, false, maxDirDepth).Scan(&ids).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to list subdirectories")
+	}
+	return ids, nil
+}
+
+// SubtreeFiles returns the files not deleted in the directory and all its subdirectories
+func (t *Tree) SubtreeFiles(dirID int) ([]File, error) {
+	ids, err := t.SubtreeDirIDs(t.DB, dirID)
+	if err != nil {
+		return nil, err
+	}
+	var files []File
+	if err := t.DB.Where("directory_id IN ? AND deleted = ?", ids, false).Find(&files).Error; err != nil {
+		return nil, errors.Wrap(err, "failed to list files")
+	}
+	return files, nil
+}
+
+// WalkFiles calls fn with the files not deleted in the directory and all its subdirectories,
+// and their paths relative to the directory. The files are read from a cursor ordered by
+// directory and name instead of loaded at once, only the subdirectories are kept in memory.
+func (t *Tree) WalkFiles(dirID int, fn func(p string, f *File) error) error {
+	paths := map[int]string{dirID: ""}
+	level := []int{dirID}
+	for depth := 0; depth < maxDirDepth && len(level) > 0; depth++ {
+		var dirs []Directory
+		if err := t.DB.Select("id", "name", "parent_id").Where("parent_id IN ? AND deleted = ?", level, false).
+			Find(&dirs).Error; err != nil {
+			return errors.Wrap(err, "failed to list subdirectories")
+		}
+		level = level[:0]
+		for _, dir := range dirs {
+			paths[dir.ID] = stdpath.Join(paths[*dir.ParentID], dir.Name)
+			level = append(level, dir.ID)
+		}
+	}
+	ids := make([]int, 0, len(paths))
+	for id := range paths {
+		ids = append(ids, id)
+	}
+	rows, err := t.DB.Model(&File{}).Where("directory_id IN ? AND deleted = ?", ids, false).
+		Order("directory_id, name").Rows()
+	if err != nil {
+		return errors.Wrap(err, "failed to list files")
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var f File
+		if err := t.DB.ScanRows(rows, &f); err != nil {
+			return errors.Wrap(err, "failed to read file")
+		}
+		if err := fn(stdpath.Join(paths[f.DirectoryID], f.Name), &f); err != nil {
+			return err
+		}
+	}
+	return errors.Wrap(rows.Err(), "failed to list files")
+}
@@ -0,0 +1,282 @@
+package dbfs
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestTree(t *testing.T, scope string, db *gorm.DB) *Tree {
+	if db == nil {
+		var err error
+		db, err = gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := Migrate(db); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tree, err := NewTree(db, scope)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tree
+}
+
+func TestTreeScopes(t *testing.T) {
+	a := newTestTree(t, "a", nil)
+	b := newTestTree(t, "b", a.DB)
+	rootA, err := a.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	rootB, err := b.Root()
+	if err != nil || rootA.ID == rootB.ID {
+		t.Fatalf("expect a root per scope, got %d and %v %v", rootA.ID, rootB, err)
+	}
+	// the root is created once
+	again := newTestTree(t, "a", a.DB)
+	if root, err := again.Root(); err != nil || root.ID != rootA.ID {
+		t.Fatalf("expect the root of a kept, got %v %v", root, err)
+	}
+
+	if _, err := a.MakeDir(rootA.ID, "docs"); err != nil {
+		t.Fatal(err)
+	}
+	var n int64
+	if err := b.DirIDs(b.DB).Count(&n).Error; err != nil || n != 1 {
+		t.Fatalf("expect only the root in scope b, got %d %v", n, err)
+	}
+}
+
+func TestTreeDirsAndFiles(t *testing.T) {
+	tree := newTestTree(t, "s", nil)
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := tree.MakeDir(root.ID, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// making an existing directory returns it
+	if again, err := tree.MakeDir(root.ID, "docs"); err != nil || again.ID != docs.ID {
+		t.Fatalf("expect the existing directory, got %v %v", again, err)
+	}
+	sub, err := tree.MakeDir(docs.ID, "sub")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := &File{Name: "a.txt", Size: 3, DirectoryID: sub.ID}
+	if err := tree.DB.Create(f).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if p := tree.DirPath(sub.ID); p != "/docs/sub" {
+		t.Fatalf("expect /docs/sub, got %s", p)
+	}
+	obj := FileToObj(f)
+	if p := tree.ObjPath(obj); p != "/docs/sub/a.txt" {
+		t.Fatalf("expect /docs/sub/a.txt, got %s", p)
+	}
+	if id := tree.ParentID(DirToObj(sub)); id != docs.ID {
+		t.Fatalf("expect the parent %d, got %d", docs.ID, id)
+	}
+	if found, err := tree.FindFile(sub.ID, "a.txt"); err != nil || found == nil || found.ID != f.ID {
+		t.Fatalf("expect a.txt found, got %v %v", found, err)
+	}
+	if found, err := tree.FindFile(sub.ID, "b.txt"); err != nil || found != nil {
+		t.Fatalf("expect no b.txt, got %v %v", found, err)
+	}
+
+	id := strconv.Itoa(f.ID)
+	if _, err := tree.RenameFile(id, "b.txt", 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.MoveFile(id, docs.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tree.RenameDir(strconv.Itoa(sub.ID), "inner", 0); err != nil {
+		t.Fatal(err)
+	}
+	dirs, files, err := tree.List(docs.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 1 || dirs[0].Name != "inner" || len(files) != 1 || files[0].Name != "b.txt" {
+		t.Fatalf("unexpected listing %v %v", dirs, files)
+	}
+
+	if err := tree.DeleteFile(id, 0); err != nil {
+		t.Fatal(err)
+	}
+	// deleting a deleted file is not an error
+	if err := tree.DeleteFile(id, 0); err != nil {
+		t.Fatalf("expect deleting again to succeed, got %v", err)
+	}
+	if _, files, err := tree.List(docs.ID); err != nil || len(files) != 0 {
+		t.Fatalf("expect the deleted file not listed, got %v %v", files, err)
+	}
+}
+
+func TestTreeBase(t *testing.T) {
+	tree := newTestTree(t, "s", nil)
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	media, err := tree.MakeDir(root.ID, "media")
+	if err != nil {
+		t.Fatal(err)
+	}
+	movies, err := tree.MakeDir(media.ID, "movies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	docs, err := tree.MakeDir(root.ID, "docs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p := tree.DirPath(movies.ID); p != "/media/movies" || !tree.InBase(docs.ID) {
+		t.Fatalf("expect the paths from the root without a base, got %s", p)
+	}
+
+	tree.Base = media.ID
+	if p := tree.DirPath(movies.ID); p != "/movies" {
+		t.Fatalf("expect the path relative to the base, got %s", p)
+	}
+	if p := tree.DirPath(media.ID); p != "/" {
+		t.Fatalf("expect the base at /, got %s", p)
+	}
+	if p := tree.FullDirPath(movies.ID); p != "/media/movies" {
+		t.Fatalf("expect the full path, got %s", p)
+	}
+	if !tree.InBase(movies.ID) || !tree.InBase(media.ID) {
+		t.Fatal("expect the base and its subdirectories in the base")
+	}
+	if tree.InBase(docs.ID) || tree.InBase(root.ID) {
+		t.Fatal("expect the directories outside the base left out")
+	}
+}
+
+func TestTreeSubtree(t *testing.T) {
+	tree := newTestTree(t, "s", nil)
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dirs := map[string]*Directory{"": root}
+	for _, p := range []string{"a", "a/b", "a/b/c", "a/d", "a/x", "e"} {
+		parent, name := "", p
+		if i := strings.LastIndex(p, "/"); i >= 0 {
+			parent, name = p[:i], p[i+1:]
+		}
+		if dirs[p], err = tree.MakeDir(dirs[parent].ID, name); err != nil {
+			t.Fatal(err)
+		}
+		if err := tree.DB.Create(&File{Name: name + ".txt", DirectoryID: dirs[p].ID}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tree.DB.Model(&Directory{}).Where("id = ?", dirs["a/x"].ID).Update("deleted", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := tree.DB.Model(&File{}).Where("name = ?", "d.txt").Update("deleted", true).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := tree.SubtreeDirIDs(tree.DB, dirs["a"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the directory comes first and every parent before its subdirectories
+	pos := map[int]int{}
+	for i, id := range ids {
+		pos[id] = i
+	}
+	if len(ids) != 4 || ids[0] != dirs["a"].ID {
+		t.Fatalf("expect a, b, c and d, got %v", ids)
+	}
+	for _, p := range []string{"a/b", "a/b/c", "a/d"} {
+		parent := dirs[p[:strings.LastIndex(p, "/")]]
+		if i, ok := pos[dirs[p].ID]; !ok || i < pos[parent.ID] {
+			t.Fatalf("expect %s after its parent, got %v", p, ids)
+		}
+	}
+	files, err := tree.SubtreeFiles(dirs["a"].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range files {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if strings.Join(names, ",") != "a.txt,b.txt,c.txt" {
+		t.Fatalf("expect the files of the subtree not deleted, got %v", names)
+	}
+
+	// the depth is limited if the parents form a loop
+	if err := tree.DB.Model(&Directory{}).Where("id = ?", dirs["a"].ID).Update("parent_id", dirs["a/b/c"].ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if ids, err := tree.SubtreeDirIDs(tree.DB, dirs["a"].ID); err != nil || len(ids) > 4*(maxDirDepth+1) {
+		t.Fatalf("expect the walk to stop, got %d ids %v", len(ids), err)
+	}
+}
+
+func TestTreeListFunc(t *testing.T) {
+	tree := newTestTree(t, "s", nil)
+	root, err := tree.Root()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"docs", "gone"} {
+		if _, err := tree.MakeDir(root.ID, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		if err := tree.DB.Create(&File{Name: name, DirectoryID: root.ID, Deleted: name == "c.txt"}).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := tree.DB.Model(&Directory{}).Where("name = ?", "gone").Update("deleted", true).Error; err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	err = tree.ListFunc(root.ID, func(dir *Directory, f *File) error {
+		if dir != nil {
+			names = append(names, dir.Name+"/")
+		} else {
+			names = append(names, f.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the directories come before the files
+	if got := strings.Join(names, ","); got != "docs/,a.txt,b.txt" {
+		t.Fatalf("expect docs/,a.txt,b.txt, got %s", got)
+	}
+	// the error of fn stops the listing
+	stop := errors.New("stop")
+	n := 0
+	err = tree.ListFunc(root.ID, func(dir *Directory, f *File) error {
+		if n++; f != nil {
+			return stop
+		}
+		return nil
+	})
+	if !errors.Is(err, stop) || n != 2 {
+		t.Fatalf("expect the listing stopped at the first file, got %v after %d", err, n)
+	}
+}
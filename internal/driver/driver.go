@@ -2,6 +2,8 @@ package driver
 
 import (
 	"context"
+	"io"
+	"time"
 
 	"github.com/alist-org/alist/v3/internal/model"
 )
@@ -42,6 +44,23 @@ type GetRooter interface {
 	GetRoot(ctx context.Context) (model.Obj, error)
 }
 
+// UserScoped is implemented by drivers that show different trees to different users,
+// so that the cached and merged results of one user aren't returned to another
+type UserScoped interface {
+	// UserScope returns the scope of the user in ctx, "" if the whole tree is visible
+	UserScope(ctx context.Context) string
+	// HasUserScopes reports whether the users see different trees, the links to the storage
+	// then carry the user they were signed for, see sign.Scoped
+	HasUserScopes() bool
+}
+
+// LinkVariant is implemented by drivers returning different content for the same file
+// depending on the link args, such as a thumbnail or a resized image
+type LinkVariant interface {
+	// LinkVariant returns the variant of the content for args, "" for the file itself
+	LinkVariant(args model.LinkArgs) string
+}
+
 type Getter interface {
 	// Get file by path, the path haven't been joined with root path
 	Get(ctx context.Context, path string) (model.Obj, error)
@@ -135,6 +154,13 @@ type CopyResult interface {
 	Copy(ctx context.Context, srcObj, dstDir model.Obj) (model.Obj, error)
 }
 
+type CopyFrom interface {
+	// CopyFrom copies srcObj of the storage src into dstDir without transferring its data,
+	// for storages keeping the data in the same backend. It returns errs.NotSupport if it can't,
+	// then the file is downloaded from src and uploaded instead.
+	CopyFrom(ctx context.Context, src Driver, srcObj, dstDir model.Obj) (model.Obj, error)
+}
+
 type PutResult interface {
 	// Put a file (provided as a FileStreamer) into the driver and return the put obj
 	// Besides the most basic upload functionality, the following features also need to be implemented:
@@ -169,6 +195,74 @@ type PutURLResult interface {
 	PutURL(ctx context.Context, dstDir model.Obj, name, url string) (model.Obj, error)
 }
 
+type Append interface {
+	// Append writes the data of file after the end of the existing file obj and returns the updated obj.
+	// Used by WebDAV to grow a file (e.g. a log or a backup) without uploading it again.
+	Append(ctx context.Context, obj model.Obj, file model.FileStreamer, up UpdateProgress) (model.Obj, error)
+}
+
+type ListStream interface {
+	// ListStream calls fn with each object in dir as it's read, so that a huge folder can be sent
+	// before the whole folder is read. It stops and returns the error of fn if fn fails.
+	// List is still used when the list is cached or has to be sorted.
+	ListStream(ctx context.Context, dir model.Obj, args model.ListArgs, fn func(obj model.Obj) error) error
+}
+
+type ZipDir interface {
+	// ZipDir writes all files under dir included by filter, with their paths relative to dir, to w as a zip archive.
+	// The archive is streamed while the files are read, so the download starts before the whole folder is read.
+	ZipDir(ctx context.Context, dir model.Obj, filter model.WalkFilter, w io.Writer) error
+}
+
+type ExportList interface {
+	// ExportList writes the path, size, SHA1, modified time and whether it's chunked of all files under dir
+	// included by filter to w in format, "csv" or "json", for inventories and audits. The list is streamed as it's read.
+	ExportList(ctx context.Context, dir model.Obj, format string, filter model.WalkFilter, w io.Writer) error
+}
+
+type Share interface {
+	// VerifyShare checks a share token handed out by the storage for obj,
+	// a valid token grants the download of obj without the sign or the password of its folder
+	VerifyShare(ctx context.Context, obj model.Obj, token string) error
+}
+
+type UploadSession interface {
+	// CreateUploadSession starts the upload of a file of size bytes into dstDir.
+	// The parts of the file are uploaded by PutUploadPart in any order, possibly in parallel,
+	// and assembled into the file by CompleteUploadSession.
+	// Sessions not completed before they expire are dropped by the driver.
+	CreateUploadSession(ctx context.Context, dstDir model.Obj, name string, size int64) (*model.UploadSession, error)
+	// GetUploadSession returns the session with the parts received so far, used to resume an upload
+	GetUploadSession(ctx context.Context, id string) (*model.UploadSession, error)
+	// PutUploadPart uploads the index-th part, a part uploaded again replaces the previous one
+	PutUploadPart(ctx context.Context, id string, index int, file model.FileStreamer, up UpdateProgress) error
+	// CompleteUploadSession creates the file from the parts, replacing the existing file with the same name
+	CompleteUploadSession(ctx context.Context, id string) (model.Obj, error)
+}
+
+// Locker keeps the WebDAV locks in the storage. The paths of the locks are the ones seen by the user
+// of ctx, so the storages giving each user its own root keep the locks of the users apart.
+type Locker interface {
+	// CreateLock saves the WebDAV lock, so that it's kept across restarts and seen by the other servers
+	// using the same storage. Return errs.Locked if it conflicts with a lock not expired:
+	// a lock on the same path, an infinite depth lock on an ancestor, or for an infinite depth lock,
+	// a lock on a descendant.
+	CreateLock(ctx context.Context, lock model.Lock) error
+	// GetLock returns the lock not expired, errs.ObjectNotFound if there isn't one
+	GetLock(ctx context.Context, token string) (*model.Lock, error)
+	// RefreshLock sets the expiry of the lock not expired
+	RefreshLock(ctx context.Context, token string, expires time.Time) (*model.Lock, error)
+	// DeleteLock removes the lock, errs.ObjectNotFound if there isn't one
+	DeleteLock(ctx context.Context, token string) error
+}
+
+type Search interface {
+	// Search returns a page of the objs under dir whose name contains req.Keywords, req.Parent is ignored.
+	// The parents of the nodes are relative to dir. Used instead of the search index for the paths
+	// inside the storage, for drivers that can query their own tree quickly (e.g. kept in a database).
+	Search(ctx context.Context, dir model.Obj, req model.SearchReq) ([]model.SearchNode, int64, error)
+}
+
 type ArchiveReader interface {
 	// GetArchiveMeta get the meta-info of an archive
 	// return errs.WrongArchivePassword if the meta-info is also encrypted but provided password is wrong or empty
@@ -205,6 +299,17 @@ type ArchiveDecompressResult interface {
 	ArchiveDecompress(ctx context.Context, srcObj, dstDir model.Obj, args model.ArchiveDecompressArgs) ([]model.Obj, error)
 }
 
+type WithDetails interface {
+	// GetDetails get the space usage of the storage
+	GetDetails(ctx context.Context) (*model.StorageDetails, error)
+}
+
+type Reencrypt interface {
+	// Reencrypt re-encrypts the obj with the current key while rotating the key,
+	// return false if the obj is already encrypted with the current key
+	Reencrypt(ctx context.Context, obj model.Obj, up UpdateProgress) (bool, error)
+}
+
 type Reference interface {
 	InitReference(storage Driver) error
 }
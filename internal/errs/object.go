@@ -10,6 +10,10 @@ var (
 	ObjectNotFound = errors.New("object not found")
 	NotFolder      = errors.New("not a folder")
 	NotFile        = errors.New("not a file")
+
+	ObjectAlreadyExists = errors.New("object already exists")
+	// ObjectModified is returned when the object was changed by another request since it was read
+	ObjectModified = errors.New("object has been modified")
 )
 
 func IsObjectNotFound(err error) bool {
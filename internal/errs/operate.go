@@ -4,4 +4,7 @@ import "errors"
 
 var (
 	PermissionDenied = errors.New("permission denied")
+	QuotaExceeded    = errors.New("quota exceeded")
+	TooManyRequests  = errors.New("too many requests")
+	Locked           = errors.New("object is locked")
 )
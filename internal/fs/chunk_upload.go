@@ -0,0 +1,274 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/pkg/errors"
+	"github.com/xhofe/tache"
+)
+
+// ChunkUploadTask shows a long upload done by a driver in the task list, in chunks or as a single file.
+// The upload runs in a worker while the request that started it waits for the result,
+// so canceling the task or the request stops the upload. It's not persisted or retried,
+// the request that could save the result has returned by then.
+// A paused upload finishes the current chunk and waits before the next one, see WaitResume.
+type ChunkUploadTask struct {
+	task.TaskExtension
+	Path string `json:"path"`
+	Size int64  `json:"size"`
+	// ctx of the request, the upload keeps its values and stops when it's canceled
+	ctx  context.Context
+	work func(ctx context.Context, t *ChunkUploadTask) error
+	// claimed is set by the first of Run and the waiting request giving up on a pending task
+	claimed atomic.Bool
+	done    chan struct{}
+	err     error
+
+	mu     sync.Mutex
+	chunk  int
+	chunks int
+	// resume is closed when a paused upload is resumed, nil if it's not paused
+	resume chan struct{}
+	// note is shown in the status while paused, such as where the uploaded chunks are kept
+	note string
+	// sent counts the bytes read by the requests of the upload, including retried ones,
+	// samples are its recent values to compute the current speed
+	sent    atomic.Int64
+	samples []speedSample
+}
+
+type speedSample struct {
+	at   time.Time
+	sent int64
+}
+
+// speedWindow is the time the current speed is averaged over
+const speedWindow = 5 * time.Second
+
+func (t *ChunkUploadTask) GetName() string {
+	t.mu.Lock()
+	chunks := t.chunks
+	t.mu.Unlock()
+	if chunks == 1 {
+		return fmt.Sprintf("upload [%s]", t.Path)
+	}
+	return fmt.Sprintf("upload [%s] in chunks", t.Path)
+}
+
+// GetStatus shows the chunk being uploaded, the uploaded bytes, the current and average speed
+// and the estimated time left
+func (t *ChunkUploadTask) GetStatus() string {
+	start := t.GetStartTime()
+	if start == nil {
+		return "waiting for a worker"
+	}
+	t.mu.Lock()
+	chunk, chunks, paused, note := t.chunk, t.chunks, t.resume != nil, t.note
+	t.mu.Unlock()
+	status := fmt.Sprintf("chunk %d", chunk+1)
+	if chunks == 1 {
+		status = "uploading"
+	} else if chunks > 0 {
+		status = fmt.Sprintf("chunk %d/%d", chunk+1, chunks)
+	}
+	if t.GetEndTime() != nil {
+		return status
+	}
+	if paused {
+		status = "paused before " + status
+		if note != "" {
+			status += ", " + note
+		}
+		return status
+	}
+	uploaded := t.GetProgress() / 100 * float64(t.Size)
+	status = fmt.Sprintf("%s, %.2f/%.2f MB", status, uploaded/1024/1024, float64(t.Size)/1024/1024)
+	current, average := t.speed(*start)
+	if average <= 0 {
+		return status
+	}
+	status = fmt.Sprintf("%s, %.2f MB/s now, %.2f MB/s average", status, current/1024/1024, average/1024/1024)
+	speed := current
+	if speed <= 0 {
+		speed = average
+	}
+	eta := time.Duration((float64(t.Size) - uploaded) / speed * float64(time.Second)).Round(time.Second)
+	return fmt.Sprintf("%s, ETA %s", status, eta)
+}
+
+// AddSent records n bytes read by a request of the upload
+func (t *ChunkUploadTask) AddSent(n int) {
+	if n <= 0 {
+		return
+	}
+	sent := t.sent.Add(int64(n))
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	// samples closer than a tenth of the window are merged
+	if l := len(t.samples); l > 1 && now.Sub(t.samples[l-2].at) < speedWindow/10 {
+		t.samples[l-1] = speedSample{at: now, sent: sent}
+	} else {
+		t.samples = append(t.samples, speedSample{at: now, sent: sent})
+	}
+	// keep one sample older than the window as the base of the current speed
+	i := 0
+	for i < len(t.samples)-1 && now.Sub(t.samples[i+1].at) >= speedWindow {
+		i++
+	}
+	t.samples = t.samples[i:]
+}
+
+// speed returns the bytes sent per second in the last speedWindow and since start
+func (t *ChunkUploadTask) speed(start time.Time) (current, average float64) {
+	now := time.Now()
+	sent := t.sent.Load()
+	if elapsed := now.Sub(start).Seconds(); elapsed > 0 {
+		average = float64(sent) / elapsed
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.samples) == 0 {
+		return 0, average
+	}
+	base := t.samples[0]
+	if elapsed := now.Sub(base.at).Seconds(); elapsed > 0 {
+		current = float64(sent-base.sent) / elapsed
+	}
+	return current, average
+}
+
+// SetChunk records the index of the chunk being uploaded, chunks is the total number or 0 if unknown
+func (t *ChunkUploadTask) SetChunk(index, chunks int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.chunk, t.chunks = index, chunks
+}
+
+// Pause asks the upload to stop after the current chunk until Resume is called
+func (t *ChunkUploadTask) Pause() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.resume == nil {
+		t.resume = make(chan struct{})
+	}
+}
+
+func (t *ChunkUploadTask) Resume() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.resume != nil {
+		close(t.resume)
+		t.resume, t.note = nil, ""
+	}
+}
+
+func (t *ChunkUploadTask) Paused() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.resume != nil
+}
+
+// WaitResume blocks while the upload is paused, note is shown in the status meanwhile.
+// The upload calls it between chunks, it returns the error of ctx if the task is canceled.
+func (t *ChunkUploadTask) WaitResume(ctx context.Context, note string) error {
+	t.mu.Lock()
+	resume := t.resume
+	if resume != nil {
+		t.note = note
+	}
+	t.mu.Unlock()
+	if resume == nil {
+		return nil
+	}
+	select {
+	case <-resume:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *ChunkUploadTask) Run() error {
+	if !t.claimed.CompareAndSwap(false, true) {
+		return errors.New("the upload has ended, upload the file again instead")
+	}
+	defer close(t.done)
+	t.SetStartTime(time.Now())
+	defer func() { t.SetEndTime(time.Now()) }()
+	ctx, cancel := context.WithCancel(t.ctx)
+	defer cancel()
+	stop := context.AfterFunc(t.Ctx(), cancel)
+	defer stop()
+	t.err = t.work(context.WithValue(ctx, chunkUploadTaskKey{}, t), t)
+	return t.err
+}
+
+type chunkUploadTaskKey struct{}
+
+// CountUpload counts the bytes read from r into the speed of the upload task running with ctx,
+// drivers wrap the bodies of their upload requests with it. It returns r if there's no task.
+func CountUpload(ctx context.Context, r io.Reader) io.Reader {
+	t, ok := ctx.Value(chunkUploadTaskKey{}).(*ChunkUploadTask)
+	if !ok {
+		return r
+	}
+	return &sentReader{Reader: r, t: t}
+}
+
+type sentReader struct {
+	io.Reader
+	t *ChunkUploadTask
+}
+
+func (r *sentReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.t.AddSent(n)
+	return n, err
+}
+
+var ChunkUploadTaskManager *tache.Manager[*ChunkUploadTask]
+
+// RunChunkUpload runs work as a task shown in the task list and returns its error after it ends.
+// path is the full path of the uploaded file, chunks is the number of chunks or 0 if unknown, work reports the progress to the task with SetProgress
+// and the bytes it sends through CountUpload.
+func RunChunkUpload(ctx context.Context, path string, size int64, chunks int, work func(ctx context.Context, t *ChunkUploadTask) error) error {
+	taskCreator, _ := ctx.Value("user").(*model.User)
+	t := &ChunkUploadTask{
+		TaskExtension: task.TaskExtension{
+			Creator: taskCreator,
+		},
+		Path:   path,
+		Size:   size,
+		ctx:    ctx,
+		work:   work,
+		done:   make(chan struct{}),
+		chunks: chunks,
+	}
+	t.SetTotalBytes(size)
+	if ChunkUploadTaskManager == nil {
+		t.claimed.Store(true)
+		return work(context.WithValue(ctx, chunkUploadTaskKey{}, t), t)
+	}
+	ChunkUploadTaskManager.Add(t)
+	select {
+	case <-t.done:
+		return t.err
+	case <-ctx.Done():
+		ChunkUploadTaskManager.Cancel(t.GetID())
+	case <-t.Base.Ctx().Done():
+	}
+	// canceled before a worker picked it up
+	if t.claimed.CompareAndSwap(false, true) {
+		return context.Canceled
+	}
+	<-t.done
+	return t.err
+}
@@ -0,0 +1,247 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/xhofe/tache"
+)
+
+// newChunkUploadManager replaces ChunkUploadTaskManager with a manager of one worker for the test
+func newChunkUploadManager(t *testing.T) {
+	old := ChunkUploadTaskManager
+	ChunkUploadTaskManager = tache.NewManager[*ChunkUploadTask](tache.WithWorks(1), tache.WithMaxRetry(0))
+	t.Cleanup(func() { ChunkUploadTaskManager = old })
+}
+
+// runningChunkUpload starts an upload whose work blocks until its ctx is done or release is closed,
+// and returns once the work is running
+func runningChunkUpload(t *testing.T, ctx context.Context, path string) (*ChunkUploadTask, chan struct{}, chan error) {
+	started, release, result := make(chan *ChunkUploadTask), make(chan struct{}), make(chan error, 1)
+	go func() {
+		result <- RunChunkUpload(ctx, path, 100, 0, func(ctx context.Context, t *ChunkUploadTask) error {
+			started <- t
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-release:
+				return nil
+			}
+		})
+	}()
+	select {
+	case tsk := <-started:
+		return tsk, release, result
+	case <-time.After(5 * time.Second):
+		t.Fatal("the upload didn't start")
+		return nil, nil, nil
+	}
+}
+
+func TestRunChunkUploadWithoutManager(t *testing.T) {
+	old := ChunkUploadTaskManager
+	ChunkUploadTaskManager = nil
+	defer func() { ChunkUploadTaskManager = old }()
+	failed := errors.New("failed")
+	err := RunChunkUpload(context.Background(), "/d/a.bin", 100, 1, func(ctx context.Context, t *ChunkUploadTask) error {
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expect the error of the work, got %v", err)
+	}
+}
+
+func TestRunChunkUpload(t *testing.T) {
+	newChunkUploadManager(t)
+	user := &model.User{Username: "alice"}
+	ctx := context.WithValue(context.Background(), "user", user)
+	err := RunChunkUpload(ctx, "/d/a.bin", 100, 3, func(ctx context.Context, t *ChunkUploadTask) error {
+		t.SetChunk(2, 3)
+		t.SetProgress(100)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the request returns once the work ends, the manager sets the state after it
+	ChunkUploadTaskManager.Wait()
+	tasks := ChunkUploadTaskManager.GetAll()
+	if len(tasks) != 1 {
+		t.Fatalf("expect a task, got %d", len(tasks))
+	}
+	tsk := tasks[0]
+	if tsk.GetState() != tache.StateSucceeded || tsk.Creator != user {
+		t.Fatalf("expect a succeeded task of alice, got %v %v", tsk.GetState(), tsk.Creator)
+	}
+	if tsk.GetName() != "upload [/d/a.bin] in chunks" || tsk.GetStatus() != "chunk 3/3" {
+		t.Fatalf("expect the chunk shown, got %s: %s", tsk.GetName(), tsk.GetStatus())
+	}
+
+	// a file uploaded as a whole isn't shown in chunks
+	failed := errors.New("failed")
+	err = RunChunkUpload(context.Background(), "/d/b.bin", 100, 1, func(ctx context.Context, t *ChunkUploadTask) error {
+		return failed
+	})
+	if !errors.Is(err, failed) {
+		t.Fatalf("expect the error of the work, got %v", err)
+	}
+	ChunkUploadTaskManager.Wait()
+	for _, tsk := range ChunkUploadTaskManager.GetAll() {
+		if tsk.Path == "/d/b.bin" && (tsk.GetName() != "upload [/d/b.bin]" || tsk.GetState() != tache.StateFailed) {
+			t.Fatalf("expect a failed upload of b.bin, got %s %v", tsk.GetName(), tsk.GetState())
+		}
+	}
+}
+
+func TestChunkUploadCancel(t *testing.T) {
+	newChunkUploadManager(t)
+	// canceled in the task list
+	tsk, _, result := runningChunkUpload(t, context.Background(), "/d/a.bin")
+	ChunkUploadTaskManager.Cancel(tsk.GetID())
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect the upload canceled, got %v", err)
+	}
+
+	// the request gives up
+	ctx, cancel := context.WithCancel(context.Background())
+	_, _, result = runningChunkUpload(t, ctx, "/d/b.bin")
+	cancel()
+	if err := <-result; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect the upload canceled with the request, got %v", err)
+	}
+}
+
+// TestChunkUploadCanceledPending a request giving up on an upload waiting for a worker doesn't run it
+func TestChunkUploadCanceledPending(t *testing.T) {
+	newChunkUploadManager(t)
+	_, release, result := runningChunkUpload(t, context.Background(), "/d/a.bin")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	ran := false
+	err := RunChunkUpload(ctx, "/d/b.bin", 100, 1, func(ctx context.Context, t *ChunkUploadTask) error {
+		ran = true
+		return nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect the pending upload canceled, got %v", err)
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+	ChunkUploadTaskManager.Wait()
+	if ran {
+		t.Fatal("expect the canceled upload not run")
+	}
+}
+
+func TestChunkUploadPause(t *testing.T) {
+	newChunkUploadManager(t)
+	resumed := make(chan error, 1)
+	tsk, release, result := runningChunkUpload(t, context.Background(), "/d/a.bin")
+	tsk.SetChunk(1, 3)
+	if err := tsk.WaitResume(context.Background(), "kept"); err != nil {
+		t.Fatalf("expect a running upload not to wait, got %v", err)
+	}
+	tsk.Pause()
+	tsk.Pause()
+	go func() { resumed <- tsk.WaitResume(context.Background(), "the chunks are kept") }()
+	// the status shows the note once the upload waits
+	deadline := time.Now().Add(5 * time.Second)
+	for tsk.GetStatus() != "paused before chunk 2/3, the chunks are kept" {
+		if time.Now().After(deadline) {
+			t.Fatalf("expect the paused status, got %s", tsk.GetStatus())
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case err := <-resumed:
+		t.Fatalf("expect the upload to wait while paused, got %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+	tsk.Resume()
+	if err := <-resumed; err != nil || tsk.Paused() {
+		t.Fatalf("expect the upload resumed, got %v", err)
+	}
+	close(release)
+	if err := <-result; err != nil {
+		t.Fatal(err)
+	}
+
+	// canceling a paused upload stops it
+	tsk, _, result = runningChunkUpload(t, context.Background(), "/d/b.bin")
+	tsk.Pause()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() { resumed <- tsk.WaitResume(ctx, "") }()
+	cancel()
+	if err := <-resumed; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expect the wait canceled, got %v", err)
+	}
+	ChunkUploadTaskManager.Cancel(tsk.GetID())
+	<-result
+}
+
+func TestCountUpload(t *testing.T) {
+	r := strings.NewReader("data")
+	if got := CountUpload(context.Background(), r); got != r {
+		t.Fatal("expect the reader kept without a task")
+	}
+	newChunkUploadManager(t)
+	var status string
+	err := RunChunkUpload(context.Background(), "/d/a.bin", 2*1024*1024, 2, func(ctx context.Context, t *ChunkUploadTask) error {
+		t.SetChunk(0, 2)
+		if _, err := io.Copy(io.Discard, CountUpload(ctx, bytes.NewReader(make([]byte, 1024*1024)))); err != nil {
+			return err
+		}
+		t.SetProgress(50)
+		status = t.GetStatus()
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(status, "chunk 1/2, 1.00/2.00 MB, ") || !strings.Contains(status, "MB/s average, ETA ") {
+		t.Fatalf("expect the bytes, speed and ETA shown, got %s", status)
+	}
+}
+
+func TestChunkUploadSpeed(t *testing.T) {
+	tsk := &ChunkUploadTask{}
+	start := time.Now().Add(-10 * time.Second)
+	if current, average := tsk.speed(start); current != 0 || average != 0 {
+		t.Fatalf("expect no speed before sending, got %v %v", current, average)
+	}
+	tsk.AddSent(0)
+	tsk.AddSent(-1)
+	if len(tsk.samples) != 0 {
+		t.Fatalf("expect nothing sent recorded, got %d samples", len(tsk.samples))
+	}
+	// the samples of quick reads are merged
+	for i := 0; i < 100; i++ {
+		tsk.AddSent(1000)
+	}
+	if len(tsk.samples) > 2 || tsk.sent.Load() != 100000 {
+		t.Fatalf("expect the samples merged, got %d samples and %d bytes", len(tsk.samples), tsk.sent.Load())
+	}
+	// the samples older than the window are dropped but the last one of them
+	tsk.samples = []speedSample{
+		{at: time.Now().Add(-20 * time.Second), sent: 0},
+		{at: time.Now().Add(-8 * time.Second), sent: 10000},
+		{at: time.Now().Add(-4 * time.Second), sent: 50000},
+	}
+	tsk.AddSent(1000)
+	if len(tsk.samples) != 3 || tsk.samples[0].sent != 10000 {
+		t.Fatalf("expect the samples in the window and one before it kept, got %+v", tsk.samples)
+	}
+	current, average := tsk.speed(start)
+	// 91000 bytes in the last 8 seconds, 101000 bytes in 10 seconds
+	if current < 11000 || current > 11500 || average < 10000 || average > 10200 {
+		t.Fatalf("expect about 11375 B/s now and 10100 B/s on average, got %v %v", current, average)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"github.com/alist-org/alist/v3/internal/errs"
 	"net/http"
 	stdpath "path"
+	"strings"
 	"time"
 
 	"github.com/alist-org/alist/v3/internal/conf"
@@ -75,6 +76,12 @@ func _copy(ctx context.Context, srcObjPath, dstDirPath string, lazyCache ...bool
 		if !errors.Is(err, errs.NotImplement) && !errors.Is(err, errs.NotSupport) {
 			return nil, err
 		}
+	} else {
+		// copy without transferring the data if the dst storage shares the backend of the src storage
+		err = op.CopyFrom(ctx, srcStorage, dstStorage, srcObjActualPath, dstDirActualPath, lazyCache...)
+		if !errors.Is(err, errs.NotImplement) && !errors.Is(err, errs.NotSupport) {
+			return nil, err
+		}
 	}
 	if ctx.Value(conf.NoTaskKey) != nil {
 		srcObj, err := op.Get(ctx, srcStorage, srcObjActualPath)
@@ -90,8 +97,9 @@ func _copy(ctx context.Context, srcObjPath, dstDirPath string, lazyCache ...bool
 				return nil, errors.WithMessagef(err, "failed get [%s] link", srcObjPath)
 			}
 			fs := stream.FileStream{
-				Obj: srcObj,
-				Ctx: ctx,
+				Obj:         srcObj,
+				Ctx:         ctx,
+				TrustedHash: true,
 			}
 			// any link provided is seekable
 			ss, err := stream.NewSeekableStream(fs, link)
@@ -130,6 +138,13 @@ func copyBetween2Storages(t *CopyTask, srcStorage, dstStorage driver.Driver, src
 		if err != nil {
 			return errors.WithMessagef(err, "failed list src [%s] objs", srcObjPath)
 		}
+		if srcStorage.GetStorage() == dstStorage.GetStorage() {
+			// children are copied by driver.Copy, which needs the dst dir to exist
+			dstObjPath := stdpath.Join(dstDirPath, srcObj.GetName())
+			if err = op.MakeDir(t.Ctx(), dstStorage, dstObjPath); err != nil {
+				return errors.WithMessagef(err, "failed make dst dir [%s]", dstObjPath)
+			}
+		}
 		for _, obj := range objs {
 			if utils.IsCanceled(t.Ctx()) {
 				return nil
@@ -160,6 +175,18 @@ func copyFileBetween2Storages(tsk *CopyTask, srcStorage, dstStorage driver.Drive
 		return errors.WithMessagef(err, "failed get src [%s] file", srcFilePath)
 	}
 	tsk.SetTotalBytes(srcFile.GetSize())
+	if srcStorage.GetStorage() == dstStorage.GetStorage() {
+		// recursive copy inside one storage, copy each file by driver.Copy if supported
+		err = op.Copy(tsk.Ctx(), srcStorage, srcFilePath, dstDirPath)
+	} else {
+		err = op.CopyFrom(tsk.Ctx(), srcStorage, dstStorage, srcFilePath, dstDirPath)
+	}
+	if !errors.Is(err, errs.NotImplement) && !errors.Is(err, errs.NotSupport) {
+		if err == nil {
+			tsk.SetProgress(100)
+		}
+		return err
+	}
 	link, _, err := op.Link(tsk.Ctx(), srcStorage, srcFilePath, model.LinkArgs{
 		Header: http.Header{},
 	})
@@ -167,13 +194,37 @@ func copyFileBetween2Storages(tsk *CopyTask, srcStorage, dstStorage driver.Drive
 		return errors.WithMessagef(err, "failed get [%s] link", srcFilePath)
 	}
 	fs := stream.FileStream{
-		Obj: srcFile,
-		Ctx: tsk.Ctx(),
+		Obj:         srcFile,
+		Ctx:         tsk.Ctx(),
+		TrustedHash: true,
 	}
 	// any link provided is seekable
 	ss, err := stream.NewSeekableStream(fs, link)
 	if err != nil {
 		return errors.WithMessagef(err, "failed get [%s] stream", srcFilePath)
 	}
-	return op.Put(tsk.Ctx(), dstStorage, dstDirPath, ss, tsk.SetProgress, true)
+	if err = op.Put(tsk.Ctx(), dstStorage, dstDirPath, ss, tsk.SetProgress, true); err != nil {
+		return err
+	}
+	tsk.Status = "verifying"
+	return verifyCopy(tsk.Ctx(), srcFile, dstStorage, stdpath.Join(dstDirPath, srcFile.GetName()))
+}
+
+// verifyCopy compares the hashes of the copied file with the ones of the source, for the hash types
+// provided by both storages without reading the file, so a copy corrupted in transfer fails
+func verifyCopy(ctx context.Context, srcObj model.Obj, dstStorage driver.Driver, dstPath string) error {
+	srcHashes := srcObj.GetHash().Export()
+	if len(srcHashes) == 0 {
+		return nil
+	}
+	dstObj, err := op.Get(ctx, dstStorage, dstPath)
+	if err != nil {
+		return errors.WithMessagef(err, "failed get copied file [%s]", dstPath)
+	}
+	for ht, dstHash := range dstObj.GetHash().All() {
+		if srcHash := srcHashes[ht]; srcHash != "" && dstHash != "" && !strings.EqualFold(srcHash, dstHash) {
+			return errors.Errorf("%s mismatch after copy, source %s, destination %s", ht.Name, srcHash, dstHash)
+		}
+	}
+	return nil
 }
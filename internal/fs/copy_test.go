@@ -0,0 +1,202 @@
+package fs
+
+import (
+	"context"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/xhofe/tache"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// copyDriver keeps a tree in memory, the ID of an object is its path.
+// It copies files by metadata but not folders, and has no links to transfer the data
+type copyDriver struct {
+	model.Storage
+	Addition struct{}
+	mu       sync.Mutex
+	children map[string][]model.Obj
+}
+
+func (d *copyDriver) Config() driver.Config {
+	return driver.Config{Name: "CopyTaskTest", NoCache: true}
+}
+
+func (d *copyDriver) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *copyDriver) Init(ctx context.Context) error {
+	return nil
+}
+
+func (d *copyDriver) Drop(ctx context.Context) error {
+	return nil
+}
+
+func (d *copyDriver) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{ID: "/", Name: "root", IsFolder: true}, nil
+}
+
+func (d *copyDriver) add(dir string, obj *model.Object) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	obj.ID = path.Join(dir, obj.Name)
+	d.children[dir] = append(d.children[dir], obj)
+}
+
+func (d *copyDriver) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return append([]model.Obj(nil), d.children[dir.GetID()]...), nil
+}
+
+func (d *copyDriver) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	return nil, errs.NotImplement
+}
+
+func (d *copyDriver) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	d.add(parentDir.GetID(), &model.Object{Name: dirName, IsFolder: true})
+	return nil
+}
+
+func (d *copyDriver) Copy(ctx context.Context, srcObj, dstDir model.Obj) error {
+	if srcObj.IsDir() {
+		return errs.NotSupport
+	}
+	d.add(dstDir.GetID(), &model.Object{Name: srcObj.GetName(), Size: srcObj.GetSize()})
+	return nil
+}
+
+// copyFromDriver copies files from other storages by metadata, as if they shared its backend
+type copyFromDriver struct {
+	copyDriver
+}
+
+func (d *copyFromDriver) Config() driver.Config {
+	return driver.Config{Name: "CopyFromTest", NoCache: true}
+}
+
+func (d *copyFromDriver) CopyFrom(ctx context.Context, src driver.Driver, srcObj, dstDir model.Obj) (model.Obj, error) {
+	if srcObj.IsDir() {
+		return nil, errs.NotSupport
+	}
+	obj := &model.Object{Name: srcObj.GetName(), Size: srcObj.GetSize()}
+	d.add(dstDir.GetID(), obj)
+	return obj, nil
+}
+
+// mountTestStorage mounts d at mountPath on a fresh database
+func mountTestStorage(t *testing.T, d driver.Driver, mountPath string) {
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	db.Init(dB)
+	addTestStorage(t, d, mountPath)
+}
+
+// addTestStorage mounts d at mountPath besides the storages already mounted
+func addTestStorage(t *testing.T, d driver.Driver, mountPath string) {
+	op.RegisterDriver(func() driver.Driver { return d })
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: d.Config().Name, MountPath: mountPath, Addition: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestCopyDirAsTasks(t *testing.T) {
+	d := &copyDriver{children: map[string][]model.Obj{}}
+	mountTestStorage(t, d, "/m")
+	d.add("/", &model.Object{Name: "src", IsFolder: true})
+	d.add("/", &model.Object{Name: "dst", IsFolder: true})
+	d.add("/src", &model.Object{Name: "a.txt", Size: 1})
+	d.add("/src", &model.Object{Name: "sub", IsFolder: true})
+	d.add("/src/sub", &model.Object{Name: "b.txt", Size: 2})
+	CopyTaskManager = tache.NewManager[*CopyTask](tache.WithWorks(2))
+
+	tsk, err := Copy(context.Background(), "/m/src", "/m/dst")
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if tsk == nil {
+		t.Fatal("expect a folder the driver can't copy to be copied by a task")
+	}
+	CopyTaskManager.Wait()
+	for _, tk := range CopyTaskManager.GetAll() {
+		if tk.GetState() != tache.StateSucceeded {
+			t.Fatalf("task %s: state %d, %v", tk.GetName(), tk.GetState(), tk.GetErr())
+		}
+	}
+	// every file is copied by the driver, into the folders made by the tasks
+	for dir, want := range map[string][]string{
+		"/dst":         {"src"},
+		"/dst/src":     {"a.txt", "sub"},
+		"/dst/src/sub": {"b.txt"},
+	} {
+		objs, _ := d.List(context.Background(), &model.Object{ID: dir}, model.ListArgs{})
+		names := map[string]bool{}
+		for _, obj := range objs {
+			names[obj.GetName()] = true
+		}
+		if len(names) != len(want) {
+			t.Fatalf("%s: expect %v, got %v", dir, want, names)
+		}
+		for _, name := range want {
+			if !names[name] {
+				t.Fatalf("%s: expect %v, got %v", dir, want, names)
+			}
+		}
+	}
+}
+
+// TestCopyFrom files copied to a storage sharing the backend aren't transferred,
+// the drivers have no links so a download would fail
+func TestCopyFrom(t *testing.T) {
+	src := &copyDriver{children: map[string][]model.Obj{}}
+	dst := &copyFromDriver{copyDriver{children: map[string][]model.Obj{}}}
+	mountTestStorage(t, src, "/src")
+	addTestStorage(t, dst, "/dst")
+	src.add("/", &model.Object{Name: "a.txt", Size: 1})
+	src.add("/", &model.Object{Name: "dir", IsFolder: true})
+	src.add("/dir", &model.Object{Name: "b.txt", Size: 2})
+	CopyTaskManager = tache.NewManager[*CopyTask](tache.WithWorks(2))
+
+	tsk, err := Copy(context.Background(), "/src/a.txt", "/dst")
+	if err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	if tsk != nil {
+		t.Fatal("expect a file copied without a task")
+	}
+	// a folder is copied by tasks, each file without transferring it
+	if _, err := Copy(context.Background(), "/src/dir", "/dst"); err != nil {
+		t.Fatalf("copy: %v", err)
+	}
+	CopyTaskManager.Wait()
+	for _, tk := range CopyTaskManager.GetAll() {
+		if tk.GetState() != tache.StateSucceeded {
+			t.Fatalf("task %s: state %d, %v", tk.GetName(), tk.GetState(), tk.GetErr())
+		}
+	}
+	for dir, want := range map[string]string{"/": "a.txt,dir", "/dir": "b.txt"} {
+		objs, _ := dst.List(context.Background(), &model.Object{ID: dir}, model.ListArgs{})
+		var names []string
+		for _, obj := range objs {
+			names = append(names, obj.GetName())
+		}
+		if got := strings.Join(names, ","); got != want {
+			t.Fatalf("%s: expect %s, got %s", dir, want, got)
+		}
+	}
+}
@@ -33,6 +33,16 @@ func List(ctx context.Context, path string, args *ListArgs) ([]model.Obj, error)
 	return res, nil
 }
 
+// ListStream calls fn with the objects of path like List, but as they're read from storages
+// implementing driver.ListStream, so that the caller can send a huge folder before it's fully read
+func ListStream(ctx context.Context, path string, args *ListArgs, fn func(obj model.Obj) error) error {
+	err := listStream(ctx, path, args, fn)
+	if err != nil && !args.NoLog {
+		log.Errorf("failed list %s: %+v", path, err)
+	}
+	return err
+}
+
 type GetArgs struct {
 	NoLog bool
 }
@@ -105,6 +115,42 @@ func PutDirectly(ctx context.Context, dstDirPath string, file model.FileStreamer
 	return err
 }
 
+// AppendDirectly writes the data of file after the end of the file at dstPath
+func AppendDirectly(ctx context.Context, dstPath string, file model.FileStreamer) error {
+	err := appendDirectly(ctx, dstPath, file)
+	if err != nil {
+		log.Errorf("failed append %s: %+v", dstPath, err)
+	}
+	return err
+}
+
+// ZipDir writes the files under the folder at path included by filter to w as a zip archive
+func ZipDir(ctx context.Context, path string, filter model.WalkFilter, w io.Writer) error {
+	err := zipDir(ctx, path, filter, w)
+	if err != nil {
+		log.Errorf("failed zip %s: %+v", path, err)
+	}
+	return err
+}
+
+// ExportList writes the list of the files under the folder at path included by filter to w in format
+func ExportList(ctx context.Context, path, format string, filter model.WalkFilter, w io.Writer) error {
+	err := exportList(ctx, path, format, filter, w)
+	if err != nil {
+		log.Errorf("failed export list of %s: %+v", path, err)
+	}
+	return err
+}
+
+// VerifyShare checks the share token of the file at path
+func VerifyShare(ctx context.Context, path, token string) error {
+	err := verifyShare(ctx, path, token)
+	if err != nil {
+		log.Warnf("failed verify share of %s: %s", path, err)
+	}
+	return err
+}
+
 func PutAsTask(ctx context.Context, dstDirPath string, file model.FileStreamer) (task.TaskExtensionInfo, error) {
 	t, err := putAsTask(ctx, dstDirPath, file)
 	if err != nil {
@@ -164,6 +210,14 @@ func GetStorage(path string, args *GetStoragesArgs) (driver.Driver, error) {
 	return storageDriver, nil
 }
 
+func GetStorageDetails(ctx context.Context, path string) (*model.StorageDetails, error) {
+	storage, _, err := op.GetStorageAndActualPath(path)
+	if err != nil {
+		return nil, err
+	}
+	return op.GetStorageDetails(ctx, storage)
+}
+
 func Other(ctx context.Context, args model.FsOtherArgs) (interface{}, error) {
 	res, err := other(ctx, args)
 	if err != nil {
@@ -0,0 +1,277 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	stdpath "path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/xhofe/tache"
+)
+
+// HashManifestName is the name of the manifest written into the folder
+const HashManifestName = "checksums.txt"
+
+// manifestHashTypes are the hash types a manifest can be generated with
+var manifestHashTypes = []*utils.HashType{utils.SHA1, utils.SHA256}
+
+type manifestEntry struct {
+	path string // relative to the manifest folder
+	obj  model.Obj
+	hash string
+}
+
+type HashManifestTask struct {
+	task.TaskExtension
+	Status    string        `json:"-"`
+	DirPath   string        `json:"dir_path"`
+	StorageMp string        `json:"storage_mp"`
+	HashName  string        `json:"hash"`
+	storage   driver.Driver `json:"-"`
+	entries   []manifestEntry
+	// filter skips the objects the creator can't access
+	filter model.WalkFilter
+}
+
+func (t *HashManifestTask) GetName() string {
+	return fmt.Sprintf("generate %s manifest of [%s](%s)", t.HashName, t.StorageMp, t.DirPath)
+}
+
+func (t *HashManifestTask) GetStatus() string {
+	return t.Status
+}
+
+func (t *HashManifestTask) Run() error {
+	t.ReinitCtx()
+	t.ClearEndTime()
+	t.SetStartTime(time.Now())
+	defer func() { t.SetEndTime(time.Now()) }()
+	var err error
+	if t.storage == nil {
+		t.storage, err = op.GetStorageByMountPath(t.StorageMp)
+		if err != nil {
+			return errors.WithMessage(err, "failed get storage")
+		}
+	}
+	ht, err := manifestHashType(t.HashName)
+	if err != nil {
+		return err
+	}
+	if t.entries == nil {
+		t.Status = "listing objs"
+		t.entries, err = listManifestEntries(t.Ctx(), t.storage, t.DirPath, "", ht, t.filter)
+		if err != nil {
+			return err
+		}
+	}
+	var totalBytes, doneBytes int64
+	for _, e := range t.entries {
+		if e.hash == "" {
+			totalBytes += e.obj.GetSize()
+		}
+	}
+	t.SetTotalBytes(totalBytes)
+	for i := range t.entries {
+		e := &t.entries[i]
+		if e.hash != "" {
+			continue
+		}
+		if utils.IsCanceled(t.Ctx()) {
+			return t.Ctx().Err()
+		}
+		t.Status = fmt.Sprintf("hashing %s", e.path)
+		size := e.obj.GetSize()
+		e.hash, err = hashObj(t.Ctx(), t.storage, stdpath.Join(t.DirPath, e.path), e.obj, ht, func(p float64) {
+			if totalBytes > 0 {
+				t.SetProgress(float64(doneBytes)/float64(totalBytes)*100 + p*float64(size)/float64(totalBytes))
+			}
+		})
+		if err != nil {
+			return errors.WithMessagef(err, "failed hash [%s]", e.path)
+		}
+		doneBytes += size
+	}
+	t.Status = "writing manifest"
+	if err = writeManifest(t.Ctx(), t.storage, t.DirPath, formatManifest(t.entries)); err != nil {
+		return err
+	}
+	t.SetProgress(100)
+	t.Status = "done"
+	return nil
+}
+
+var HashTaskManager *tache.Manager[*HashManifestTask]
+
+// HashManifest generates a manifest of the files of the folder subtree included by filter in the sha1sum/sha256sum format.
+// If every file already has the hash stored by the driver, the manifest is returned directly
+// and written into the folder if write is true. Otherwise the missing hashes are computed by
+// a task which writes the manifest into the folder when finished, so write must be true.
+func HashManifest(ctx context.Context, dirPath, hashName string, write bool, filter model.WalkFilter) (string, task.TaskExtensionInfo, error) {
+	ht, err := manifestHashType(hashName)
+	if err != nil {
+		return "", nil, err
+	}
+	storage, dirActualPath, err := op.GetStorageAndActualPath(dirPath)
+	if err != nil {
+		return "", nil, errors.WithMessage(err, "failed get storage")
+	}
+	dir, err := op.Get(ctx, storage, dirActualPath)
+	if err != nil {
+		return "", nil, errors.WithMessagef(err, "failed get [%s]", dirPath)
+	}
+	if !dir.IsDir() {
+		return "", nil, errs.NotFolder
+	}
+	entries, err := listManifestEntries(ctx, storage, dirActualPath, "", ht, filter)
+	if err != nil {
+		return "", nil, err
+	}
+	complete := true
+	for _, e := range entries {
+		if e.hash == "" {
+			complete = false
+			break
+		}
+	}
+	if complete {
+		manifest := formatManifest(entries)
+		if write {
+			if err = writeManifest(ctx, storage, dirActualPath, manifest); err != nil {
+				return "", nil, err
+			}
+		}
+		return manifest, nil, nil
+	}
+	if !write {
+		return "", nil, errors.New("some files have no stored hash, the manifest can only be generated by a task with write enabled")
+	}
+	if storage.Config().NoUpload {
+		return "", nil, errors.WithStack(errs.UploadNotSupported)
+	}
+	taskCreator, _ := ctx.Value("user").(*model.User)
+	t := &HashManifestTask{
+		TaskExtension: task.TaskExtension{
+			Creator: taskCreator,
+		},
+		DirPath:   dirActualPath,
+		StorageMp: storage.GetStorage().MountPath,
+		HashName:  ht.Name,
+		storage:   storage,
+		entries:   entries,
+		filter:    filter,
+	}
+	HashTaskManager.Add(t)
+	return "", t, nil
+}
+
+func manifestHashType(name string) (*utils.HashType, error) {
+	for _, ht := range manifestHashTypes {
+		if strings.EqualFold(ht.Name, name) {
+			return ht, nil
+		}
+	}
+	return nil, errors.Errorf("unsupported hash type: %s", name)
+}
+
+func listManifestEntries(ctx context.Context, storage driver.Driver, dirPath, relPath string, ht *utils.HashType, filter model.WalkFilter) ([]manifestEntry, error) {
+	objs, err := op.List(ctx, storage, stdpath.Join(dirPath, relPath), model.ListArgs{})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed list [%s]", relPath)
+	}
+	var entries []manifestEntry
+	for _, obj := range objs {
+		if utils.IsCanceled(ctx) {
+			return nil, ctx.Err()
+		}
+		p := stdpath.Join(relPath, obj.GetName())
+		if filter != nil && !filter(p) {
+			continue
+		}
+		if obj.IsDir() {
+			sub, err := listManifestEntries(ctx, storage, dirPath, p, ht, filter)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, sub...)
+			continue
+		}
+		// skip the manifest itself
+		if p == HashManifestName {
+			continue
+		}
+		entries = append(entries, manifestEntry{
+			path: p,
+			obj:  obj,
+			hash: obj.GetHash().GetHash(ht),
+		})
+	}
+	return entries, nil
+}
+
+func hashObj(ctx context.Context, storage driver.Driver, path string, obj model.Obj, ht *utils.HashType, up model.UpdateProgress) (string, error) {
+	link, _, err := op.Link(ctx, storage, path, model.LinkArgs{
+		Header: http.Header{},
+	})
+	if err != nil {
+		return "", errors.WithMessage(err, "failed get link")
+	}
+	ss, err := stream.NewSeekableStream(stream.FileStream{
+		Obj: obj,
+		Ctx: ctx,
+	}, link)
+	if err != nil {
+		return "", errors.WithMessage(err, "failed get stream")
+	}
+	defer ss.Close()
+	r, err := ss.RangeRead(http_range.Range{Length: -1})
+	if err != nil {
+		return "", err
+	}
+	h := ht.NewFunc()
+	if err = utils.CopyWithCtx(ctx, h, r, obj.GetSize(), up); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
+
+func formatManifest(entries []manifestEntry) string {
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].path < entries[j].path
+	})
+	var sb strings.Builder
+	for _, e := range entries {
+		sb.WriteString(strings.ToLower(e.hash))
+		sb.WriteString("  ")
+		sb.WriteString(e.path)
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func writeManifest(ctx context.Context, storage driver.Driver, dirPath, manifest string) error {
+	if storage.Config().NoUpload {
+		return errors.WithStack(errs.UploadNotSupported)
+	}
+	s := &stream.FileStream{
+		Obj: &model.Object{
+			Name:     HashManifestName,
+			Size:     int64(len(manifest)),
+			Modified: time.Now(),
+		},
+		Reader:   strings.NewReader(manifest),
+		Mimetype: "text/plain",
+	}
+	return op.Put(ctx, storage, dirPath, s, nil)
+}
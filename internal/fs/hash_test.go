@@ -0,0 +1,131 @@
+package fs
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/xhofe/tache"
+)
+
+// hashDriver keeps the content of the files in memory, the files put are added to the tree
+type hashDriver struct {
+	copyDriver
+	data map[string][]byte
+}
+
+func (d *hashDriver) Config() driver.Config {
+	return driver.Config{Name: "HashManifestTest", NoCache: true}
+}
+
+func (d *hashDriver) addFile(dir, name string, data []byte, storeHash bool) {
+	obj := &model.Object{Name: name, Size: int64(len(data))}
+	if storeHash {
+		obj.HashInfo = utils.NewHashInfo(utils.SHA1, sha1Hex(data))
+	}
+	d.add(dir, obj)
+	d.mu.Lock()
+	d.data[obj.ID] = data
+	d.mu.Unlock()
+}
+
+func (d *hashDriver) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return &model.Link{MFile: nopCloser{bytes.NewReader(d.data[file.GetID()])}}, nil
+}
+
+func (d *hashDriver) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	d.addFile(dstDir.GetID(), file.GetName(), data, false)
+	return nil
+}
+
+// content returns the content of the last file put at path
+func (d *hashDriver) content(path string) string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return string(d.data[path])
+}
+
+type nopCloser struct {
+	*bytes.Reader
+}
+
+func (nopCloser) Close() error { return nil }
+
+func sha1Hex(data []byte) string {
+	return fmt.Sprintf("%x", sha1.Sum(data))
+}
+
+func newHashDriver(t *testing.T, storeHash bool) *hashDriver {
+	d := &hashDriver{copyDriver: copyDriver{children: map[string][]model.Obj{}}, data: map[string][]byte{}}
+	mountTestStorage(t, d, "/h")
+	d.add("/", &model.Object{Name: "dir", IsFolder: true})
+	d.add("/dir", &model.Object{Name: "sub", IsFolder: true})
+	d.add("/dir", &model.Object{Name: "hidden", IsFolder: true})
+	d.addFile("/dir", "b.txt", []byte("b"), storeHash)
+	d.addFile("/dir/sub", "a.txt", []byte("a"), storeHash)
+	d.addFile("/dir/hidden", "c.txt", []byte("c"), storeHash)
+	d.addFile("/dir", HashManifestName, []byte("old manifest"), storeHash)
+	return d
+}
+
+// skipHidden leaves out the hidden folder
+func skipHidden(p string) bool {
+	return !strings.HasPrefix(p, "hidden")
+}
+
+var wantManifest = sha1Hex([]byte("b")) + "  b.txt\n" + sha1Hex([]byte("a")) + "  sub/a.txt\n"
+
+func TestHashManifestStoredHashes(t *testing.T) {
+	d := newHashDriver(t, true)
+	manifest, tsk, err := HashManifest(context.Background(), "/h/dir", "sha1", false, skipHidden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tsk != nil {
+		t.Fatal("expect the stored hashes used without a task")
+	}
+	if manifest != wantManifest {
+		t.Fatalf("expect manifest\n%s\ngot\n%s", wantManifest, manifest)
+	}
+	if got := d.content("/dir/" + HashManifestName); got != "old manifest" {
+		t.Fatalf("expect nothing written, got %q", got)
+	}
+	if _, _, err := HashManifest(context.Background(), "/h/dir", "md5", false, nil); err == nil {
+		t.Fatal("expect an unsupported hash type to fail")
+	}
+}
+
+func TestHashManifestTask(t *testing.T) {
+	d := newHashDriver(t, false)
+	HashTaskManager = tache.NewManager[*HashManifestTask](tache.WithWorks(1))
+	if _, _, err := HashManifest(context.Background(), "/h/dir", "sha1", false, skipHidden); err == nil {
+		t.Fatal("expect missing hashes to need a task writing the manifest")
+	}
+	_, tsk, err := HashManifest(context.Background(), "/h/dir", "sha1", true, skipHidden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tsk == nil {
+		t.Fatal("expect a task computing the missing hashes")
+	}
+	HashTaskManager.Wait()
+	if err := tsk.GetErr(); err != nil {
+		t.Fatalf("task: %v", err)
+	}
+	if got := d.content("/dir/" + HashManifestName); got != wantManifest {
+		t.Fatalf("expect manifest\n%s\ngot\n%s", wantManifest, got)
+	}
+}
@@ -44,6 +44,56 @@ func list(ctx context.Context, path string, args *ListArgs) ([]model.Obj, error)
 	return objs, nil
 }
 
+// listStream calls fn with the objects of path as they're read from the storage,
+// followed by the virtual files of the storages mounted under path
+func listStream(ctx context.Context, path string, args *ListArgs, fn func(obj model.Obj) error) error {
+	meta, _ := ctx.Value("meta").(*model.Meta)
+	user, _ := ctx.Value("user").(*model.User)
+	virtualFiles := op.GetStorageVirtualFilesByPath(path)
+	storage, actualPath, err := op.GetStorageAndActualPath(path)
+	if err != nil && len(virtualFiles) == 0 {
+		return errors.WithMessage(err, "failed get storage")
+	}
+
+	om := model.NewObjMerge()
+	if whetherHide(user, meta, path) {
+		om.InitHideReg(meta.Hide)
+	}
+	if storage != nil {
+		var fnErr error
+		err = op.ListStream(ctx, storage, actualPath, model.ListArgs{
+			ReqPath: path,
+			Refresh: args.Refresh,
+		}, func(obj model.Obj) error {
+			if !om.Add(obj) {
+				return nil
+			}
+			fnErr = fn(obj)
+			return fnErr
+		})
+		if fnErr != nil {
+			return fnErr
+		}
+		if err != nil {
+			if !args.NoLog {
+				log.Errorf("fs/list: %+v", err)
+			}
+			if len(virtualFiles) == 0 {
+				return errors.WithMessage(err, "failed get objs")
+			}
+		}
+	}
+	for _, obj := range virtualFiles {
+		if !om.Add(obj) {
+			continue
+		}
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 func whetherHide(user *model.User, meta *model.Meta, path string) bool {
 	// if is admin, don't hide
 	if user == nil || user.CanSeeHides() {
@@ -0,0 +1,57 @@
+package fs
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// streamDriver is a copyDriver listing its folders by ListStream
+type streamDriver struct {
+	copyDriver
+	streamed int
+}
+
+func (d *streamDriver) Config() driver.Config {
+	return driver.Config{Name: "ListStreamTest", NoCache: true}
+}
+
+func (d *streamDriver) ListStream(ctx context.Context, dir model.Obj, args model.ListArgs, fn func(obj model.Obj) error) error {
+	d.streamed++
+	objs, _ := d.List(ctx, dir, args)
+	for _, obj := range objs {
+		if err := fn(obj); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// TestListStream the objects of the storage come first, then the storages mounted under the path,
+// with the hidden objects and the duplicate names left out
+func TestListStream(t *testing.T) {
+	d := &streamDriver{copyDriver: copyDriver{children: map[string][]model.Obj{}}}
+	mountTestStorage(t, d, "/s")
+	addTestStorage(t, &copyDriver{children: map[string][]model.Obj{}}, "/s/sub")
+	addTestStorage(t, &copyDriver{children: map[string][]model.Obj{}}, "/s/v")
+	d.add("/", &model.Object{Name: "a.txt", Size: 1})
+	d.add("/", &model.Object{Name: ".hidden", Size: 1})
+	d.add("/", &model.Object{Name: "sub", IsFolder: true})
+
+	ctx := context.WithValue(context.Background(), "user", &model.User{})
+	ctx = context.WithValue(ctx, "meta", &model.Meta{Path: "/s", Hide: `^\.hidden$`})
+	var names []string
+	err := ListStream(ctx, "/s", &ListArgs{}, func(obj model.Obj) error {
+		names = append(names, obj.GetName())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(names, ","); got != "a.txt,sub,v" || d.streamed != 1 {
+		t.Fatalf("expect a.txt,sub,v streamed, got %s by %d streams", got, d.streamed)
+	}
+}
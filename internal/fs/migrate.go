@@ -0,0 +1,271 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	stdpath "path"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/xhofe/tache"
+)
+
+// MigrateTask copies a whole folder tree to another storage and verifies the SHA1 of every copied file.
+// Files already at the destination with the same size and SHA1 are skipped, so an interrupted
+// migration can be resumed by retrying the task or starting it again.
+type MigrateTask struct {
+	task.TaskExtension
+	Status     string   `json:"-"`
+	SrcPath    string   `json:"src_path"`
+	DstPath    string   `json:"dst_path"`
+	Copied     int      `json:"copied"`
+	Skipped    int      `json:"skipped"`
+	Mismatches []string `json:"mismatches"`
+	Failed     []string `json:"failed"`
+	// verified files, kept across retries of the task
+	done map[string]struct{}
+}
+
+type migrateEntry struct {
+	path string // relative to SrcPath
+	obj  model.Obj
+}
+
+func (t *MigrateTask) GetName() string {
+	return fmt.Sprintf("migrate [%s] to [%s]", t.SrcPath, t.DstPath)
+}
+
+func (t *MigrateTask) GetStatus() string {
+	return t.Status
+}
+
+func (t *MigrateTask) Run() error {
+	t.ReinitCtx()
+	t.ClearEndTime()
+	t.SetStartTime(time.Now())
+	defer func() { t.SetEndTime(time.Now()) }()
+	srcStorage, srcActualPath, err := op.GetStorageAndActualPath(t.SrcPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed get src storage")
+	}
+	dstStorage, dstActualPath, err := op.GetStorageAndActualPath(t.DstPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed get dst storage")
+	}
+	if t.done == nil {
+		t.done = make(map[string]struct{})
+	}
+	t.Copied, t.Skipped, t.Mismatches, t.Failed = 0, 0, nil, nil
+	t.Status = "listing objs"
+	entries, err := listMigrateEntries(t.Ctx(), srcStorage, srcActualPath, "")
+	if err != nil {
+		return err
+	}
+	var totalBytes, doneBytes int64
+	for _, e := range entries {
+		if _, ok := t.done[e.path]; !ok && !e.obj.IsDir() {
+			totalBytes += e.obj.GetSize()
+		}
+	}
+	t.SetTotalBytes(totalBytes)
+	for _, e := range entries {
+		if _, ok := t.done[e.path]; ok {
+			t.Skipped++
+			continue
+		}
+		if utils.IsCanceled(t.Ctx()) {
+			return t.Ctx().Err()
+		}
+		if e.obj.IsDir() {
+			// the folders are listed before their content, so empty folders are kept too
+			if err := op.MakeDir(t.Ctx(), dstStorage, stdpath.Join(dstActualPath, e.path)); err != nil {
+				t.Failed = append(t.Failed, fmt.Sprintf("%s: %v", e.path, err))
+			}
+			continue
+		}
+		t.Status = fmt.Sprintf("migrating %s", e.path)
+		size := e.obj.GetSize()
+		// hashing the source, copying and verifying each take a third of the file progress
+		step := 0
+		up := func(p float64) {
+			if totalBytes > 0 {
+				t.SetProgress(float64(doneBytes)/float64(totalBytes)*100 + (float64(step)*100+p)/3*float64(size)/float64(totalBytes))
+			}
+		}
+		next := func() { step++ }
+		copied, err := migrateFile(t.Ctx(), srcStorage, stdpath.Join(srcActualPath, e.path), e.obj,
+			dstStorage, stdpath.Join(dstActualPath, stdpath.Dir(e.path)), up, next)
+		doneBytes += size
+		if totalBytes > 0 {
+			t.SetProgress(float64(doneBytes) / float64(totalBytes) * 100)
+		}
+		var mismatch *migrateMismatch
+		switch {
+		case errors.As(err, &mismatch):
+			t.Mismatches = append(t.Mismatches, fmt.Sprintf("%s: %v", e.path, err))
+		case err != nil:
+			t.Failed = append(t.Failed, fmt.Sprintf("%s: %v", e.path, err))
+		case copied:
+			t.Copied++
+			t.done[e.path] = struct{}{}
+		default:
+			t.Skipped++
+			t.done[e.path] = struct{}{}
+		}
+	}
+	t.SetProgress(100)
+	t.Status = fmt.Sprintf("copied %d, already migrated %d, mismatched %d, failed %d",
+		t.Copied, t.Skipped, len(t.Mismatches), len(t.Failed))
+	if len(t.Mismatches) > 0 || len(t.Failed) > 0 {
+		return errors.Errorf("failed to migrate %d files:\n%s", len(t.Mismatches)+len(t.Failed),
+			strings.Join(append(append([]string{}, t.Mismatches...), t.Failed...), "\n"))
+	}
+	return nil
+}
+
+var MigrateTaskManager *tache.Manager[*MigrateTask]
+
+// Migrate starts a task to copy the folder srcPath into the folder dstPath of another storage
+func Migrate(ctx context.Context, srcPath, dstPath string) (task.TaskExtensionInfo, error) {
+	srcPath, dstPath = utils.FixAndCleanPath(srcPath), utils.FixAndCleanPath(dstPath)
+	srcStorage, srcActualPath, err := op.GetStorageAndActualPath(srcPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed get src storage")
+	}
+	dstStorage, _, err := op.GetStorageAndActualPath(dstPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed get dst storage")
+	}
+	if srcStorage.GetStorage() == dstStorage.GetStorage() {
+		return nil, errors.New("the source and the destination must be on different storages")
+	}
+	if dstStorage.Config().NoUpload {
+		return nil, errors.WithStack(errs.UploadNotSupported)
+	}
+	src, err := op.Get(ctx, srcStorage, srcActualPath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed get [%s]", srcPath)
+	}
+	if !src.IsDir() {
+		return nil, errs.NotFolder
+	}
+	taskCreator, _ := ctx.Value("user").(*model.User)
+	t := &MigrateTask{
+		TaskExtension: task.TaskExtension{
+			Creator: taskCreator,
+		},
+		SrcPath: srcPath,
+		DstPath: dstPath,
+	}
+	MigrateTaskManager.Add(t)
+	return t, nil
+}
+
+// migrateMismatch is returned when the copied file doesn't have the SHA1 of the source
+type migrateMismatch struct {
+	src, dst string
+}
+
+func (e *migrateMismatch) Error() string {
+	return fmt.Sprintf("sha1 mismatch, source %s, destination %s", e.src, e.dst)
+}
+
+// migrateFile copies the file to dstDirPath unless it's already there, and verifies its SHA1.
+// next is called when moving from hashing the source to copying and from copying to verifying.
+func migrateFile(ctx context.Context, srcStorage driver.Driver, srcPath string, srcObj model.Obj,
+	dstStorage driver.Driver, dstDirPath string, up model.UpdateProgress, next func()) (bool, error) {
+	srcHash := srcObj.GetHash().GetHash(utils.SHA1)
+	if srcHash == "" {
+		var err error
+		srcHash, err = hashObj(ctx, srcStorage, srcPath, srcObj, utils.SHA1, up)
+		if err != nil {
+			return false, errors.WithMessage(err, "failed hash source")
+		}
+	}
+	next()
+	dstPath := stdpath.Join(dstDirPath, srcObj.GetName())
+	if dstObj, err := op.Get(ctx, dstStorage, dstPath); err == nil && !dstObj.IsDir() && dstObj.GetSize() == srcObj.GetSize() {
+		dstHash, err := objSHA1(ctx, dstStorage, dstPath, dstObj, up)
+		if err == nil && strings.EqualFold(dstHash, srcHash) {
+			return false, nil
+		}
+	}
+	if err := putObj(ctx, srcStorage, srcPath, srcObj, dstStorage, dstDirPath, up); err != nil {
+		return false, err
+	}
+	next()
+	dstObj, err := op.Get(ctx, dstStorage, dstPath)
+	if err != nil {
+		return true, errors.WithMessage(err, "failed get copied file")
+	}
+	dstHash, err := objSHA1(ctx, dstStorage, dstPath, dstObj, up)
+	if err != nil {
+		return true, errors.WithMessage(err, "failed hash copied file")
+	}
+	if !strings.EqualFold(dstHash, srcHash) {
+		return true, &migrateMismatch{src: srcHash, dst: dstHash}
+	}
+	return true, nil
+}
+
+// putObj uploads the file at srcPath into dstDirPath of dstStorage, replacing the file of the same name
+func putObj(ctx context.Context, srcStorage driver.Driver, srcPath string, srcObj model.Obj,
+	dstStorage driver.Driver, dstDirPath string, up model.UpdateProgress) error {
+	link, _, err := op.Link(ctx, srcStorage, srcPath, model.LinkArgs{
+		Header: http.Header{},
+	})
+	if err != nil {
+		return errors.WithMessage(err, "failed get link")
+	}
+	ss, err := stream.NewSeekableStream(stream.FileStream{
+		Obj: srcObj,
+		Ctx: ctx,
+	}, link)
+	if err != nil {
+		return errors.WithMessage(err, "failed get stream")
+	}
+	if err = op.Put(ctx, dstStorage, dstDirPath, ss, up, true); err != nil {
+		return errors.WithMessage(err, "failed put")
+	}
+	return nil
+}
+
+// objSHA1 returns the SHA1 stored by the driver, or computes it by reading the file
+func objSHA1(ctx context.Context, storage driver.Driver, path string, obj model.Obj, up model.UpdateProgress) (string, error) {
+	if h := obj.GetHash().GetHash(utils.SHA1); h != "" {
+		return h, nil
+	}
+	return hashObj(ctx, storage, path, obj, utils.SHA1, up)
+}
+
+func listMigrateEntries(ctx context.Context, storage driver.Driver, dirPath, relPath string) ([]migrateEntry, error) {
+	objs, err := op.List(ctx, storage, stdpath.Join(dirPath, relPath), model.ListArgs{Refresh: true})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed list [%s]", relPath)
+	}
+	var entries []migrateEntry
+	for _, obj := range objs {
+		if utils.IsCanceled(ctx) {
+			return nil, ctx.Err()
+		}
+		p := stdpath.Join(relPath, obj.GetName())
+		entries = append(entries, migrateEntry{path: p, obj: obj})
+		if obj.IsDir() {
+			sub, err := listMigrateEntries(ctx, storage, dirPath, p)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, sub...)
+		}
+	}
+	return entries, nil
+}
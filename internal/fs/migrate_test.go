@@ -0,0 +1,130 @@
+package fs
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/xhofe/tache"
+)
+
+// migrateDriver replaces the files put with the same name, and corrupts the content of the names in corrupt
+type migrateDriver struct {
+	hashDriver
+	corrupt map[string]bool
+}
+
+func (d *migrateDriver) Config() driver.Config {
+	return driver.Config{Name: "MigrateTest", NoCache: true}
+}
+
+func (d *migrateDriver) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	if d.corrupt[file.GetName()] {
+		data = append(data, '!')
+	}
+	children := d.children[dstDir.GetID()][:0]
+	for _, obj := range d.children[dstDir.GetID()] {
+		if obj.GetName() != file.GetName() {
+			children = append(children, obj)
+		}
+	}
+	d.children[dstDir.GetID()] = children
+	d.mu.Unlock()
+	d.addFile(dstDir.GetID(), file.GetName(), data, false)
+	return nil
+}
+
+// newMigrateDrivers mounts the tree of newHashDriver at /h and an empty storage at /m
+func newMigrateDrivers(t *testing.T) (*hashDriver, *migrateDriver) {
+	src := newHashDriver(t, false)
+	dst := &migrateDriver{
+		hashDriver: hashDriver{copyDriver: copyDriver{children: map[string][]model.Obj{}}, data: map[string][]byte{}},
+		corrupt:    map[string]bool{},
+	}
+	op.RegisterDriver(func() driver.Driver { return dst })
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: "MigrateTest", MountPath: "/m", Addition: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+	MigrateTaskManager = tache.NewManager[*MigrateTask](tache.WithWorks(1), tache.WithMaxRetry(0))
+	return src, dst
+}
+
+func runMigrate(t *testing.T, srcPath, dstPath string) *MigrateTask {
+	tsk, err := Migrate(context.Background(), srcPath, dstPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	MigrateTaskManager.Wait()
+	return tsk.(*MigrateTask)
+}
+
+func TestMigrate(t *testing.T) {
+	src, dst := newMigrateDrivers(t)
+	tsk := runMigrate(t, "/h/dir", "/m/out")
+	if err := tsk.GetErr(); err != nil {
+		t.Fatalf("task: %v", err)
+	}
+	if tsk.Copied != 4 || tsk.Skipped != 0 {
+		t.Fatalf("expect 4 files copied, got %d copied and %d skipped", tsk.Copied, tsk.Skipped)
+	}
+	for _, p := range []string{"b.txt", "sub/a.txt", "hidden/c.txt", HashManifestName} {
+		if got, want := dst.content("/out/"+p), src.content("/dir/"+p); got != want {
+			t.Fatalf("%s: expect %q, got %q", p, want, got)
+		}
+	}
+
+	// the files already at the destination with the same SHA1 aren't copied again
+	tsk = runMigrate(t, "/h/dir", "/m/out")
+	if err := tsk.GetErr(); err != nil {
+		t.Fatalf("task: %v", err)
+	}
+	if tsk.Copied != 0 || tsk.Skipped != 4 {
+		t.Fatalf("expect 4 files skipped, got %d copied and %d skipped", tsk.Copied, tsk.Skipped)
+	}
+
+	if _, err := Migrate(context.Background(), "/h/dir", "/h/out"); err == nil {
+		t.Fatal("expect a migration within the same storage to be refused")
+	}
+	if _, err := Migrate(context.Background(), "/h/dir/b.txt", "/m/out"); err == nil {
+		t.Fatal("expect a migration of a file to be refused")
+	}
+}
+
+func TestMigrateMismatchRetry(t *testing.T) {
+	_, dst := newMigrateDrivers(t)
+	dst.corrupt["b.txt"] = true
+	tsk := runMigrate(t, "/h/dir", "/m/out")
+	if tsk.GetState() != tache.StateFailed {
+		t.Fatalf("expect the task failed, got state %d", tsk.GetState())
+	}
+	if len(tsk.Mismatches) != 1 || !strings.HasPrefix(tsk.Mismatches[0], "b.txt: sha1 mismatch") {
+		t.Fatalf("expect a mismatch of b.txt, got %v", tsk.Mismatches)
+	}
+	if tsk.Copied != 3 || len(tsk.Failed) != 0 {
+		t.Fatalf("expect the other 3 files copied, got %d copied and failed %v", tsk.Copied, tsk.Failed)
+	}
+
+	// a retry skips the verified files and copies the mismatched one again
+	dst.corrupt["b.txt"] = false
+	MigrateTaskManager.Retry(tsk.GetID())
+	MigrateTaskManager.Wait()
+	if err := tsk.GetErr(); err != nil {
+		t.Fatalf("retry: %v", err)
+	}
+	if tsk.Copied != 1 || tsk.Skipped != 3 || len(tsk.Mismatches) != 0 {
+		t.Fatalf("expect b.txt copied again only, got %d copied, %d skipped and mismatches %v",
+			tsk.Copied, tsk.Skipped, tsk.Mismatches)
+	}
+	if got := dst.content("/out/b.txt"); got != "b" {
+		t.Fatalf("expect b.txt fixed, got %q", got)
+	}
+}
@@ -2,6 +2,7 @@ package fs
 
 import (
 	"context"
+	"io"
 
 	"github.com/alist-org/alist/v3/internal/errs"
 	"github.com/alist-org/alist/v3/internal/model"
@@ -56,3 +57,27 @@ func other(ctx context.Context, args model.FsOtherArgs) (interface{}, error) {
 	args.Path = actualPath
 	return op.Other(ctx, storage, args)
 }
+
+func zipDir(ctx context.Context, path string, filter model.WalkFilter, w io.Writer) error {
+	storage, actualPath, err := op.GetStorageAndActualPath(path)
+	if err != nil {
+		return errors.WithMessage(err, "failed get storage")
+	}
+	return op.ZipDir(ctx, storage, actualPath, filter, w)
+}
+
+func exportList(ctx context.Context, path, format string, filter model.WalkFilter, w io.Writer) error {
+	storage, actualPath, err := op.GetStorageAndActualPath(path)
+	if err != nil {
+		return errors.WithMessage(err, "failed get storage")
+	}
+	return op.ExportList(ctx, storage, actualPath, format, filter, w)
+}
+
+func verifyShare(ctx context.Context, path, token string) error {
+	storage, actualPath, err := op.GetStorageAndActualPath(path)
+	if err != nil {
+		return errors.WithMessage(err, "failed get storage")
+	}
+	return op.VerifyShare(ctx, storage, actualPath, token)
+}
@@ -7,7 +7,9 @@ import (
 	"github.com/alist-org/alist/v3/internal/errs"
 	"github.com/alist-org/alist/v3/internal/model"
 	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/stream"
 	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/alist-org/alist/v3/pkg/utils"
 	"github.com/pkg/errors"
 	"github.com/xhofe/tache"
 	"time"
@@ -47,7 +49,13 @@ func putAsTask(ctx context.Context, dstDirPath string, file model.FileStreamer)
 		return nil, errors.WithStack(errs.UploadNotSupported)
 	}
 	if file.NeedStore() {
-		_, err := file.CacheFullInTempFile()
+		var err error
+		if s, ok := file.(*stream.FileStream); ok {
+			// the file is read once anyway, hash it here so the driver doesn't have to read it again
+			_, err = s.StageInTempFile(utils.MD5, utils.SHA1)
+		} else {
+			_, err = file.CacheFullInTempFile()
+		}
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to create temp file")
 		}
@@ -79,3 +87,14 @@ func putDirectly(ctx context.Context, dstDirPath string, file model.FileStreamer
 	}
 	return op.Put(ctx, storage, dstDirActualPath, file, nil, lazyCache...)
 }
+
+func appendDirectly(ctx context.Context, dstPath string, file model.FileStreamer) error {
+	storage, dstActualPath, err := op.GetStorageAndActualPath(dstPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed get storage")
+	}
+	if storage.Config().NoUpload {
+		return errors.WithStack(errs.UploadNotSupported)
+	}
+	return op.Append(ctx, storage, dstActualPath, file, nil)
+}
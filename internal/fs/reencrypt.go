@@ -0,0 +1,152 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	stdpath "path"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+	"github.com/xhofe/tache"
+)
+
+// ReencryptTask re-encrypts every object of a storage which is rotating its key.
+// Failed objects don't stop the task, they are reported and can be migrated by retrying the task.
+type ReencryptTask struct {
+	task.TaskExtension
+	Status    string        `json:"-"`
+	StorageMp string        `json:"storage_mp"`
+	Migrated  int           `json:"migrated"`
+	Skipped   int           `json:"skipped"`
+	Failed    []string      `json:"failed"`
+	storage   driver.Driver `json:"-"`
+}
+
+func (t *ReencryptTask) GetName() string {
+	return fmt.Sprintf("re-encrypt [%s]", t.StorageMp)
+}
+
+func (t *ReencryptTask) GetStatus() string {
+	return t.Status
+}
+
+func (t *ReencryptTask) Run() error {
+	t.ReinitCtx()
+	t.ClearEndTime()
+	t.SetStartTime(time.Now())
+	defer func() { t.SetEndTime(time.Now()) }()
+	var err error
+	if t.storage == nil {
+		t.storage, err = op.GetStorageByMountPath(t.StorageMp)
+		if err != nil {
+			return errors.WithMessage(err, "failed get storage")
+		}
+	}
+	r, ok := t.storage.(driver.Reencrypt)
+	if !ok {
+		return errs.NotImplement
+	}
+	t.Migrated, t.Skipped, t.Failed = 0, 0, nil
+	t.Status = "listing objs"
+	objs, err := listReencryptObjs(t.Ctx(), t.storage, "/")
+	if err != nil {
+		return err
+	}
+	var totalBytes, doneBytes int64
+	for _, obj := range objs {
+		totalBytes += obj.GetSize()
+	}
+	t.SetTotalBytes(totalBytes)
+	for _, obj := range objs {
+		if utils.IsCanceled(t.Ctx()) {
+			return t.Ctx().Err()
+		}
+		t.Status = fmt.Sprintf("re-encrypting %s", obj.GetPath())
+		size := obj.GetSize()
+		migrated, err := r.Reencrypt(t.Ctx(), obj, func(p float64) {
+			if totalBytes > 0 {
+				t.SetProgress(float64(doneBytes)/float64(totalBytes)*100 + p*float64(size)/float64(totalBytes))
+			}
+		})
+		doneBytes += size
+		if totalBytes > 0 {
+			t.SetProgress(float64(doneBytes) / float64(totalBytes) * 100)
+		}
+		switch {
+		case err != nil:
+			t.Failed = append(t.Failed, fmt.Sprintf("%s: %v", obj.GetPath(), err))
+		case migrated:
+			t.Migrated++
+		default:
+			t.Skipped++
+		}
+	}
+	t.SetProgress(100)
+	t.Status = fmt.Sprintf("migrated %d, already current %d, failed %d", t.Migrated, t.Skipped, len(t.Failed))
+	if len(t.Failed) > 0 {
+		return errors.Errorf("failed to re-encrypt %d objs:\n%s", len(t.Failed), strings.Join(t.Failed, "\n"))
+	}
+	return nil
+}
+
+var ReencryptTaskManager *tache.Manager[*ReencryptTask]
+
+// Reencrypt starts a task to re-encrypt the storage mounted at mountPath with its current key
+func Reencrypt(ctx context.Context, mountPath string) (task.TaskExtensionInfo, error) {
+	storage, err := op.GetStorageByMountPath(mountPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed get storage")
+	}
+	if _, ok := storage.(driver.Reencrypt); !ok {
+		return nil, errors.WithStack(errs.NotImplement)
+	}
+	taskCreator, _ := ctx.Value("user").(*model.User)
+	t := &ReencryptTask{
+		TaskExtension: task.TaskExtension{
+			Creator: taskCreator,
+		},
+		StorageMp: mountPath,
+		storage:   storage,
+	}
+	ReencryptTaskManager.Add(t)
+	return t, nil
+}
+
+// listReencryptObjs lists the objs under dirPath with the children before their folder,
+// so that a folder is renamed only after everything in it has been migrated
+func listReencryptObjs(ctx context.Context, storage driver.Driver, dirPath string) ([]model.Obj, error) {
+	objs, err := op.List(ctx, storage, dirPath, model.ListArgs{Refresh: true})
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed list [%s]", dirPath)
+	}
+	var res []model.Obj
+	for _, obj := range objs {
+		if utils.IsCanceled(ctx) {
+			return nil, ctx.Err()
+		}
+		p := stdpath.Join(dirPath, obj.GetName())
+		if obj.IsDir() {
+			sub, err := listReencryptObjs(ctx, storage, p)
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, sub...)
+		}
+		res = append(res, &model.Object{
+			Path:     p,
+			Name:     obj.GetName(),
+			Size:     obj.GetSize(),
+			Modified: obj.ModTime(),
+			Ctime:    obj.CreateTime(),
+			IsFolder: obj.IsDir(),
+		})
+	}
+	return res, nil
+}
@@ -0,0 +1,318 @@
+package fs
+
+import (
+	"context"
+	"fmt"
+	stdpath "path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/alist-org/alist/v3/pkg/cron"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/xhofe/tache"
+)
+
+// SyncTask mirrors the folder SrcPath into DstPath of another storage, one way.
+// Files missing at the destination or differing in size (and SHA1 with CompareHash) are copied,
+// and with Delete the objects only at the destination are removed.
+// In two way mode the changes of both sides since the last run are copied instead, see runTwoWay.
+// Files synced before a failure are skipped when the task is retried.
+type SyncTask struct {
+	task.TaskExtension
+	Status      string   `json:"-"`
+	JobID       uint     `json:"job_id"`
+	SrcPath     string   `json:"src_path"`
+	DstPath     string   `json:"dst_path"`
+	Mode        string   `json:"mode"`
+	Conflict    string   `json:"conflict"`
+	Delete      bool     `json:"delete"`
+	CompareHash bool     `json:"compare_hash"`
+	Copied      int      `json:"copied"`
+	Deleted     int      `json:"deleted"`
+	Skipped     int      `json:"skipped"`
+	Conflicts   []string `json:"conflicts"`
+	Failed      []string `json:"failed"`
+	// synced paths, kept across retries of the task
+	done map[string]struct{}
+	// states of the last run of a two way sync by path
+	states map[string]*model.SyncState
+}
+
+func (t *SyncTask) GetName() string {
+	return fmt.Sprintf("sync [%s] to [%s]", t.SrcPath, t.DstPath)
+}
+
+func (t *SyncTask) GetStatus() string {
+	return t.Status
+}
+
+func (t *SyncTask) Run() error {
+	t.ReinitCtx()
+	t.ClearEndTime()
+	t.SetStartTime(time.Now())
+	defer func() { t.SetEndTime(time.Now()) }()
+	srcStorage, srcActualPath, err := op.GetStorageAndActualPath(t.SrcPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed get src storage")
+	}
+	dstStorage, dstActualPath, err := op.GetStorageAndActualPath(t.DstPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed get dst storage")
+	}
+	if t.done == nil {
+		t.done = make(map[string]struct{})
+	}
+	t.Copied, t.Deleted, t.Skipped, t.Conflicts, t.Failed = 0, 0, 0, nil, nil
+	t.Status = "listing objs"
+	srcEntries, err := listMigrateEntries(t.Ctx(), srcStorage, srcActualPath, "")
+	if err != nil {
+		return err
+	}
+	if err = op.MakeDir(t.Ctx(), dstStorage, dstActualPath); err != nil {
+		return errors.WithMessage(err, "failed make dst dir")
+	}
+	dstEntries, err := listMigrateEntries(t.Ctx(), dstStorage, dstActualPath, "")
+	if err != nil {
+		return err
+	}
+	dstObjs := make(map[string]model.Obj, len(dstEntries))
+	for _, e := range dstEntries {
+		dstObjs[e.path] = e.obj
+	}
+	if t.Mode == model.SyncModeTwoWay {
+		srcObjs := make(map[string]model.Obj, len(srcEntries))
+		for _, e := range srcEntries {
+			srcObjs[e.path] = e.obj
+		}
+		return t.runTwoWay(&syncSide{storage: srcStorage, root: srcActualPath, objs: srcObjs},
+			&syncSide{storage: dstStorage, root: dstActualPath, objs: dstObjs})
+	}
+
+	var totalBytes, doneBytes int64
+	for _, e := range srcEntries {
+		if _, ok := t.done[e.path]; !ok && !e.obj.IsDir() {
+			totalBytes += e.obj.GetSize()
+		}
+	}
+	t.SetTotalBytes(totalBytes)
+	srcPaths := make(map[string]struct{}, len(srcEntries))
+	for _, e := range srcEntries {
+		srcPaths[e.path] = struct{}{}
+		if _, ok := t.done[e.path]; ok {
+			t.Skipped++
+			continue
+		}
+		if utils.IsCanceled(t.Ctx()) {
+			return t.Ctx().Err()
+		}
+		t.Status = fmt.Sprintf("syncing %s", e.path)
+		size := e.obj.GetSize()
+		up := func(p float64) {
+			if totalBytes > 0 {
+				t.SetProgress((float64(doneBytes) + p/100*float64(size)) / float64(totalBytes) * 100)
+			}
+		}
+		copied, err := t.syncEntry(srcStorage, srcActualPath, dstStorage, dstActualPath, e, dstObjs[e.path], up)
+		if !e.obj.IsDir() {
+			doneBytes += size
+			if totalBytes > 0 {
+				t.SetProgress(float64(doneBytes) / float64(totalBytes) * 100)
+			}
+		}
+		switch {
+		case err != nil:
+			t.Failed = append(t.Failed, fmt.Sprintf("%s: %v", e.path, err))
+			continue
+		case copied:
+			t.Copied++
+		default:
+			t.Skipped++
+		}
+		t.done[e.path] = struct{}{}
+	}
+	if t.Delete {
+		t.deleteExtra(dstStorage, dstActualPath, dstEntries, srcPaths)
+	}
+	t.SetProgress(100)
+	t.Status = fmt.Sprintf("copied %d, in sync %d, deleted %d, failed %d", t.Copied, t.Skipped, t.Deleted, len(t.Failed))
+	if len(t.Failed) > 0 {
+		return errors.Errorf("failed to sync %d objs:\n%s", len(t.Failed), strings.Join(t.Failed, "\n"))
+	}
+	return nil
+}
+
+// syncEntry makes the destination of e the same as the source, returns whether a file was copied
+func (t *SyncTask) syncEntry(srcStorage driver.Driver, srcRoot string, dstStorage driver.Driver, dstRoot string,
+	e migrateEntry, dstObj model.Obj, up model.UpdateProgress) (bool, error) {
+	dstPath := stdpath.Join(dstRoot, e.path)
+	if dstObj != nil && dstObj.IsDir() != e.obj.IsDir() {
+		if err := op.Remove(t.Ctx(), dstStorage, dstPath); err != nil {
+			return false, errors.WithMessage(err, "failed remove the dst obj of another type")
+		}
+		dstObj = nil
+	}
+	if e.obj.IsDir() {
+		if dstObj != nil {
+			return false, nil
+		}
+		return false, op.MakeDir(t.Ctx(), dstStorage, dstPath)
+	}
+	srcPath := stdpath.Join(srcRoot, e.path)
+	if dstObj != nil && dstObj.GetSize() == e.obj.GetSize() {
+		if !t.CompareHash {
+			return false, nil
+		}
+		srcHash, err := objSHA1(t.Ctx(), srcStorage, srcPath, e.obj, up)
+		if err != nil {
+			return false, errors.WithMessage(err, "failed hash source")
+		}
+		dstHash, err := objSHA1(t.Ctx(), dstStorage, dstPath, dstObj, up)
+		if err != nil {
+			return false, errors.WithMessage(err, "failed hash destination")
+		}
+		if strings.EqualFold(srcHash, dstHash) {
+			return false, nil
+		}
+	}
+	if err := putObj(t.Ctx(), srcStorage, srcPath, e.obj, dstStorage, stdpath.Dir(dstPath), up); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// deleteExtra removes the objs only at the destination, the content of a removed folder is skipped
+func (t *SyncTask) deleteExtra(dstStorage driver.Driver, dstRoot string, dstEntries []migrateEntry, srcPaths map[string]struct{}) {
+	var extra []string
+	for _, e := range dstEntries {
+		if _, ok := srcPaths[e.path]; !ok {
+			extra = append(extra, e.path)
+		}
+	}
+	sort.Strings(extra)
+	var removed []string
+	for _, p := range extra {
+		if underAny(removed, p) {
+			continue
+		}
+		if utils.IsCanceled(t.Ctx()) {
+			return
+		}
+		t.Status = fmt.Sprintf("deleting %s", p)
+		if err := op.Remove(t.Ctx(), dstStorage, stdpath.Join(dstRoot, p)); err != nil {
+			t.Failed = append(t.Failed, fmt.Sprintf("%s: %v", p, err))
+			continue
+		}
+		t.Deleted++
+		removed = append(removed, p)
+	}
+}
+
+// underAny reports whether p is one of dirs or inside one of them
+func underAny(dirs []string, p string) bool {
+	for _, dir := range dirs {
+		if utils.IsSubPath(dir, p) {
+			return true
+		}
+	}
+	return false
+}
+
+var SyncTaskManager *tache.Manager[*SyncTask]
+
+// RunSyncJob starts a task for the sync job, unless the last task of the job hasn't finished
+func RunSyncJob(ctx context.Context, id uint) (task.TaskExtensionInfo, error) {
+	job, err := op.GetSyncJobById(id)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range SyncTaskManager.GetByState(tache.StatePending, tache.StateRunning, tache.StateWaitingRetry, tache.StateBeforeRetry) {
+		if t.JobID == id {
+			return nil, errors.Errorf("the last run of sync job %d hasn't finished", id)
+		}
+	}
+	srcStorage, _, err := op.GetStorageAndActualPath(job.SrcPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed get src storage")
+	}
+	dstStorage, _, err := op.GetStorageAndActualPath(job.DstPath)
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed get dst storage")
+	}
+	if srcStorage.GetStorage() == dstStorage.GetStorage() {
+		return nil, errors.New("the source and the destination must be on different storages")
+	}
+	if dstStorage.Config().NoUpload {
+		return nil, errors.WithStack(errs.UploadNotSupported)
+	}
+	taskCreator, _ := ctx.Value("user").(*model.User)
+	t := &SyncTask{
+		TaskExtension: task.TaskExtension{
+			Creator: taskCreator,
+		},
+		JobID:       job.ID,
+		SrcPath:     job.SrcPath,
+		DstPath:     job.DstPath,
+		Mode:        job.Mode,
+		Conflict:    job.Conflict,
+		Delete:      job.Delete,
+		CompareHash: job.CompareHash,
+	}
+	SyncTaskManager.Add(t)
+	if err := op.SetSyncJobLastRun(job.ID, time.Now()); err != nil {
+		log.Warnf("failed save the last run of sync job %d: %+v", job.ID, err)
+	}
+	return t, nil
+}
+
+// syncCrons the schedules of the enabled sync jobs
+var syncCrons = struct {
+	sync.Mutex
+	m map[uint]*cron.Cron
+}{m: make(map[uint]*cron.Cron)}
+
+// StartSyncJobs schedules the saved sync jobs and follows their changes
+func StartSyncJobs() {
+	op.RegisterSyncJobHook(func(job *model.SyncJob, deleted bool) {
+		if deleted {
+			job.Disabled = true
+		}
+		scheduleSyncJob(job)
+	})
+	jobs, err := op.GetSyncJobs()
+	if err != nil {
+		log.Errorf("failed get sync jobs: %+v", err)
+		return
+	}
+	for i := range jobs {
+		scheduleSyncJob(&jobs[i])
+	}
+}
+
+func scheduleSyncJob(job *model.SyncJob) {
+	syncCrons.Lock()
+	defer syncCrons.Unlock()
+	if c, ok := syncCrons.m[job.ID]; ok {
+		c.Stop()
+		delete(syncCrons.m, job.ID)
+	}
+	if job.Disabled || job.Interval <= 0 {
+		return
+	}
+	id := job.ID
+	c := cron.NewCron(time.Duration(job.Interval) * time.Minute)
+	c.Do(func() {
+		if _, err := RunSyncJob(context.Background(), id); err != nil {
+			log.Errorf("failed run sync job %d: %+v", id, err)
+		}
+	})
+	syncCrons.m[id] = c
+}
@@ -0,0 +1,224 @@
+package fs
+
+import (
+	"context"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/xhofe/tache"
+)
+
+// syncDriver is a hashDriver that replaces the files put with the same name and can remove and rename objects,
+// the files put are modified now
+type syncDriver struct {
+	hashDriver
+	name string
+}
+
+func (d *syncDriver) Config() driver.Config {
+	return driver.Config{Name: d.name, NoCache: true}
+}
+
+// put adds or replaces the file name in dir
+func (d *syncDriver) put(dir, name string, data []byte, modified time.Time) {
+	id := path.Join(dir, name)
+	d.mu.Lock()
+	d.children[dir] = removeObj(d.children[dir], id)
+	d.mu.Unlock()
+	d.add(dir, &model.Object{Name: name, Size: int64(len(data)), Modified: modified})
+	d.mu.Lock()
+	d.data[id] = data
+	d.mu.Unlock()
+}
+
+func (d *syncDriver) Put(ctx context.Context, dstDir model.Obj, file model.FileStreamer, up driver.UpdateProgress) error {
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	d.put(dstDir.GetID(), file.GetName(), data, time.Now())
+	return nil
+}
+
+func (d *syncDriver) Remove(ctx context.Context, obj model.Obj) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	id := obj.GetID()
+	d.children[path.Dir(id)] = removeObj(d.children[path.Dir(id)], id)
+	for dir := range d.children {
+		if dir == id || strings.HasPrefix(dir, id+"/") {
+			delete(d.children, dir)
+		}
+	}
+	for p := range d.data {
+		if p == id || strings.HasPrefix(p, id+"/") {
+			delete(d.data, p)
+		}
+	}
+	return nil
+}
+
+func (d *syncDriver) Rename(ctx context.Context, srcObj model.Obj, newName string) error {
+	if srcObj.IsDir() {
+		return errs.NotSupport
+	}
+	d.mu.Lock()
+	id, dir := srcObj.GetID(), path.Dir(srcObj.GetID())
+	data := d.data[id]
+	delete(d.data, id)
+	d.children[dir] = removeObj(d.children[dir], id)
+	d.mu.Unlock()
+	d.put(dir, newName, data, srcObj.ModTime())
+	return nil
+}
+
+// names returns the sorted names in dir
+func (d *syncDriver) names(dir string) []string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	var names []string
+	for _, obj := range d.children[dir] {
+		names = append(names, obj.GetName())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func removeObj(objs []model.Obj, id string) []model.Obj {
+	kept := objs[:0]
+	for _, obj := range objs {
+		if obj.GetID() != id {
+			kept = append(kept, obj)
+		}
+	}
+	return kept
+}
+
+func newSyncDriver(name string) *syncDriver {
+	return &syncDriver{
+		hashDriver: hashDriver{copyDriver: copyDriver{children: map[string][]model.Obj{}}, data: map[string][]byte{}},
+		name:       name,
+	}
+}
+
+// newSyncDrivers mounts a storage with the folder dir at /s and an empty storage at /d
+func newSyncDrivers(t *testing.T) (*syncDriver, *syncDriver) {
+	src, dst := newSyncDriver("SyncSrcTest"), newSyncDriver("SyncDstTest")
+	mountTestStorage(t, src, "/s")
+	op.RegisterDriver(func() driver.Driver { return dst })
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: dst.name, MountPath: "/d", Addition: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+	modified := time.Now().Add(-time.Hour)
+	src.add("/", &model.Object{Name: "dir", IsFolder: true, Modified: modified})
+	src.add("/dir", &model.Object{Name: "sub", IsFolder: true, Modified: modified})
+	src.put("/dir", "a.txt", []byte("a"), modified)
+	src.put("/dir/sub", "b.txt", []byte("bb"), modified)
+	SyncTaskManager = tache.NewManager[*SyncTask](tache.WithWorks(1), tache.WithMaxRetry(0))
+	return src, dst
+}
+
+func createSyncJob(t *testing.T, job model.SyncJob) *model.SyncJob {
+	if err := op.CreateSyncJob(&job); err != nil {
+		t.Fatal(err)
+	}
+	return &job
+}
+
+func runSync(t *testing.T, id uint) *SyncTask {
+	tsk, err := RunSyncJob(context.Background(), id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	SyncTaskManager.Wait()
+	return tsk.(*SyncTask)
+}
+
+func TestSyncOneWay(t *testing.T) {
+	src, dst := newSyncDrivers(t)
+	job := createSyncJob(t, model.SyncJob{SrcPath: "/s/dir", DstPath: "/d/out"})
+	tsk := runSync(t, job.ID)
+	if err := tsk.GetErr(); err != nil {
+		t.Fatalf("task: %v", err)
+	}
+	if tsk.Copied != 2 || tsk.Skipped != 1 {
+		t.Fatalf("expect 2 files copied and the folder made, got %d copied and %d skipped", tsk.Copied, tsk.Skipped)
+	}
+	if dst.content("/out/a.txt") != "a" || dst.content("/out/sub/b.txt") != "bb" {
+		t.Fatal("expect the files copied")
+	}
+	if saved, err := op.GetSyncJobById(job.ID); err != nil || saved.LastRunAt == nil {
+		t.Fatalf("expect the last run saved, got %+v %v", saved, err)
+	}
+
+	// a file changed in size is copied again, the others are in sync
+	src.put("/dir", "a.txt", []byte("aaa"), time.Now())
+	tsk = runSync(t, job.ID)
+	if tsk.Copied != 1 || tsk.Skipped != 2 || dst.content("/out/a.txt") != "aaa" {
+		t.Fatalf("expect a.txt copied again, got %d copied and %d skipped", tsk.Copied, tsk.Skipped)
+	}
+
+	// the objs only at the destination are kept unless Delete is set
+	dst.put("/out", "extra.txt", []byte("x"), time.Now())
+	dst.add("/out", &model.Object{Name: "old", IsFolder: true})
+	dst.put("/out/old", "c.txt", []byte("c"), time.Now())
+	if tsk = runSync(t, job.ID); tsk.Deleted != 0 || len(dst.names("/out")) != 4 {
+		t.Fatalf("expect nothing deleted, got %d deleted and %v", tsk.Deleted, dst.names("/out"))
+	}
+	job.Delete = true
+	if err := op.UpdateSyncJob(job); err != nil {
+		t.Fatal(err)
+	}
+	tsk = runSync(t, job.ID)
+	// the content of a removed folder isn't removed again
+	if tsk.Deleted != 2 || strings.Join(dst.names("/out"), ",") != "a.txt,sub" {
+		t.Fatalf("expect extra.txt and old deleted, got %d deleted and %v", tsk.Deleted, dst.names("/out"))
+	}
+}
+
+func TestSyncCompareHash(t *testing.T) {
+	src, dst := newSyncDrivers(t)
+	job := createSyncJob(t, model.SyncJob{SrcPath: "/s/dir", DstPath: "/d/out"})
+	runSync(t, job.ID)
+	// the same size with another content is only found by the SHA1
+	src.put("/dir", "a.txt", []byte("z"), time.Now())
+	if tsk := runSync(t, job.ID); tsk.Copied != 0 || dst.content("/out/a.txt") != "a" {
+		t.Fatalf("expect files of the same size in sync, got %d copied", tsk.Copied)
+	}
+	job.CompareHash = true
+	if err := op.UpdateSyncJob(job); err != nil {
+		t.Fatal(err)
+	}
+	if tsk := runSync(t, job.ID); tsk.Copied != 1 || dst.content("/out/a.txt") != "z" {
+		t.Fatalf("expect a.txt copied by its SHA1, got %d copied", tsk.Copied)
+	}
+}
+
+func TestSyncJobInvalid(t *testing.T) {
+	newSyncDrivers(t)
+	for _, job := range []model.SyncJob{
+		{SrcPath: "/s/dir", DstPath: "/s/dir/sub"},
+		{SrcPath: "/s/dir", DstPath: "/d/out", Interval: -1},
+		{SrcPath: "/s/dir", DstPath: "/d/out", Mode: "mirror"},
+		{SrcPath: "/s/dir", DstPath: "/d/out", Conflict: "ask"},
+	} {
+		if err := op.CreateSyncJob(&job); err == nil {
+			t.Fatalf("expect %+v refused", job)
+		}
+	}
+	job := createSyncJob(t, model.SyncJob{SrcPath: "/s/dir", DstPath: "/s/out"})
+	if job.Mode != model.SyncModeOneWay || job.Conflict != model.SyncConflictNewest {
+		t.Fatalf("expect the default mode and conflict policy, got %+v", job)
+	}
+	if _, err := RunSyncJob(context.Background(), job.ID); err == nil {
+		t.Fatal("expect a sync within the same storage refused")
+	}
+}
@@ -0,0 +1,319 @@
+package fs
+
+import (
+	"fmt"
+	stdpath "path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// syncSide is one of the folders of a two way sync, objs are keyed by the path relative to root
+type syncSide struct {
+	storage driver.Driver
+	root    string
+	objs    map[string]model.Obj
+	// src is whether the side is the SrcPath of the job
+	src bool
+}
+
+func (s *syncSide) path(p string) string {
+	return stdpath.Join(s.root, p)
+}
+
+// modified returns the modified time of the side recorded in state
+func (s *syncSide) modified(state *model.SyncState) time.Time {
+	if s.src {
+		return state.SrcModified
+	}
+	return state.DstModified
+}
+
+// changed reports whether obj differs from the state of the last run, a new obj is changed.
+// The SHA1 is compared when both have it, otherwise the modified time in seconds
+// since some databases don't keep a finer precision.
+func (s *syncSide) changed(obj model.Obj, state *model.SyncState) bool {
+	if state == nil || obj.IsDir() != state.IsDir {
+		return true
+	}
+	if obj.IsDir() {
+		return false
+	}
+	if obj.GetSize() != state.Size {
+		return true
+	}
+	if h := obj.GetHash().GetHash(utils.SHA1); h != "" && state.SHA1 != "" {
+		return !strings.EqualFold(h, state.SHA1)
+	}
+	return obj.ModTime().Unix() != s.modified(state).Unix()
+}
+
+// runTwoWay copies the objs changed on one side since the last run to the other side.
+// An obj deleted on one side and unchanged on the other is deleted with Delete, otherwise copied back.
+// An obj changed on both sides, or on both sides without a state of the last run, is a conflict
+// unless both have the same content, resolved by the Conflict policy of the job.
+func (t *SyncTask) runTwoWay(src, dst *syncSide) error {
+	src.src = true
+	states, err := op.GetSyncStates(t.JobID)
+	if err != nil {
+		return err
+	}
+	old := make(map[string]*model.SyncState, len(states))
+	for i := range states {
+		old[states[i].Path] = &states[i]
+	}
+	t.states = old
+	var paths []string
+	seen := make(map[string]struct{})
+	for _, m := range []map[string]model.Obj{src.objs, dst.objs} {
+		for p := range m {
+			if _, ok := seen[p]; !ok {
+				seen[p] = struct{}{}
+				paths = append(paths, p)
+			}
+		}
+	}
+	for p := range old {
+		if _, ok := seen[p]; !ok {
+			seen[p] = struct{}{}
+			paths = append(paths, p)
+		}
+	}
+	sort.Strings(paths)
+
+	next := make(map[string]model.SyncState)
+	var removed []string
+	for i, p := range paths {
+		if utils.IsCanceled(t.Ctx()) {
+			return t.Ctx().Err()
+		}
+		if underAny(removed, p) {
+			continue
+		}
+		if _, ok := t.done[p]; ok {
+			if s, ok := old[p]; ok {
+				next[p] = *s
+			}
+			t.Skipped++
+			continue
+		}
+		t.Status = fmt.Sprintf("syncing %s", p)
+		t.SetProgress(float64(i) / float64(len(paths)) * 100)
+		action, err := t.syncTwoWayPath(src, dst, p, old[p], next)
+		switch {
+		case err != nil:
+			t.Failed = append(t.Failed, fmt.Sprintf("%s: %v", p, err))
+			// keep the state so the next run sees the same changes
+			if s, ok := old[p]; ok {
+				next[p] = *s
+			}
+			continue
+		case action == "copied":
+			t.Copied++
+		case action == "deleted":
+			t.Deleted++
+			removed = append(removed, p)
+		case action == "conflict":
+			t.Conflicts = append(t.Conflicts, p)
+		default:
+			t.Skipped++
+		}
+		t.done[p] = struct{}{}
+	}
+
+	states = make([]model.SyncState, 0, len(next))
+	for p, s := range next {
+		s.ID = 0
+		s.JobID = t.JobID
+		s.Path = p
+		states = append(states, s)
+	}
+	if err := op.ReplaceSyncStates(t.JobID, states); err != nil {
+		return errors.WithMessage(err, "failed save sync states")
+	}
+	t.SetProgress(100)
+	t.Status = fmt.Sprintf("copied %d, in sync %d, deleted %d, conflicts %d, failed %d",
+		t.Copied, t.Skipped, t.Deleted, len(t.Conflicts), len(t.Failed))
+	if len(t.Failed) > 0 {
+		return errors.Errorf("failed to sync %d objs:\n%s", len(t.Failed), strings.Join(t.Failed, "\n"))
+	}
+	return nil
+}
+
+// syncTwoWayPath syncs the objs at p and records their new state in next,
+// returns "copied", "deleted", "conflict" or "" when nothing was done
+func (t *SyncTask) syncTwoWayPath(src, dst *syncSide, p string, state *model.SyncState, next map[string]model.SyncState) (string, error) {
+	a, b := src.objs[p], dst.objs[p]
+	switch {
+	case a == nil && b == nil:
+		return "", nil
+	case a != nil && b != nil:
+		changedA, changedB := src.changed(a, state), dst.changed(b, state)
+		switch {
+		case a.IsDir() && b.IsDir():
+		case !changedA && !changedB:
+		case changedA && !changedB:
+			return t.copyTwoWay(src, dst, p, a, next)
+		case !changedA && changedB:
+			return t.copyTwoWay(dst, src, p, b, next)
+		default:
+			same, err := t.sameContent(src, dst, p, a, b)
+			if err != nil {
+				return "", err
+			}
+			if !same {
+				return t.resolveConflict(src, dst, p, a, b, state, next)
+			}
+		}
+		next[p] = newSyncState(a, b)
+		return "", nil
+	case a != nil:
+		return t.syncOneSide(src, dst, p, a, state, next)
+	default:
+		return t.syncOneSide(dst, src, p, b, state, next)
+	}
+}
+
+// syncOneSide syncs an obj only on side from: a new or changed obj is copied, an unchanged one
+// was deleted on the other side and is deleted with Delete, or copied back.
+// A folder is only deleted if nothing in it changed either.
+func (t *SyncTask) syncOneSide(from, to *syncSide, p string, obj model.Obj, state *model.SyncState, next map[string]model.SyncState) (string, error) {
+	if state != nil && !from.changed(obj, state) && t.Delete && !(obj.IsDir() && t.changedUnder(from, p)) {
+		if err := op.Remove(t.Ctx(), from.storage, from.path(p)); err != nil {
+			return "", errors.WithMessage(err, "failed remove")
+		}
+		return "deleted", nil
+	}
+	return t.copyTwoWay(from, to, p, obj, next)
+}
+
+// changedUnder reports whether an obj in the folder p of side s changed since the last run
+func (t *SyncTask) changedUnder(s *syncSide, p string) bool {
+	for sub, obj := range s.objs {
+		if sub != p && utils.IsSubPath(p, sub) && s.changed(obj, t.states[sub]) {
+			return true
+		}
+	}
+	return false
+}
+
+// copyTwoWay copies obj at p from one side to the other and records the state of both copies
+func (t *SyncTask) copyTwoWay(from, to *syncSide, p string, obj model.Obj, next map[string]model.SyncState) (string, error) {
+	if old, ok := to.objs[p]; ok && old.IsDir() != obj.IsDir() {
+		if err := op.Remove(t.Ctx(), to.storage, to.path(p)); err != nil {
+			return "", errors.WithMessage(err, "failed remove the obj of another type")
+		}
+	}
+	if obj.IsDir() {
+		if err := op.MakeDir(t.Ctx(), to.storage, to.path(p)); err != nil {
+			return "", err
+		}
+	} else if err := putObj(t.Ctx(), from.storage, from.path(p), obj, to.storage, stdpath.Dir(to.path(p)), noProgress); err != nil {
+		return "", err
+	}
+	copied, err := op.Get(t.Ctx(), to.storage, to.path(p))
+	if err != nil {
+		return "", errors.WithMessage(err, "failed get the copy")
+	}
+	to.objs[p] = copied
+	if from.src {
+		next[p] = newSyncState(obj, copied)
+	} else {
+		next[p] = newSyncState(copied, obj)
+	}
+	if obj.IsDir() {
+		return "", nil
+	}
+	return "copied", nil
+}
+
+// sameContent compares the files of both sides with the same size by SHA1,
+// computed only with CompareHash if a storage doesn't keep it, otherwise they're considered the same
+func (t *SyncTask) sameContent(src, dst *syncSide, p string, a, b model.Obj) (bool, error) {
+	if a.IsDir() || b.IsDir() || a.GetSize() != b.GetSize() {
+		return false, nil
+	}
+	ha, hb := a.GetHash().GetHash(utils.SHA1), b.GetHash().GetHash(utils.SHA1)
+	if (ha == "" || hb == "") && !t.CompareHash {
+		return true, nil
+	}
+	var err error
+	if ha, err = objSHA1(t.Ctx(), src.storage, src.path(p), a, noProgress); err != nil {
+		return false, errors.WithMessage(err, "failed hash source")
+	}
+	if hb, err = objSHA1(t.Ctx(), dst.storage, dst.path(p), b, noProgress); err != nil {
+		return false, errors.WithMessage(err, "failed hash destination")
+	}
+	return strings.EqualFold(ha, hb), nil
+}
+
+// resolveConflict applies the conflict policy of the job to the different objs at p
+func (t *SyncTask) resolveConflict(src, dst *syncSide, p string, a, b model.Obj, state *model.SyncState, next map[string]model.SyncState) (string, error) {
+	switch {
+	case t.Conflict == model.SyncConflictSkip || a.IsDir() || b.IsDir():
+		// left as they are, the conflict is reported again by the next run
+		if state != nil {
+			next[p] = *state
+		}
+		return "conflict", nil
+	case t.Conflict == model.SyncConflictKeepBoth:
+		// the destination's version is renamed and copied to the source, then the source's version is copied
+		name := conflictName(stdpath.Base(p), time.Now())
+		if err := op.Rename(t.Ctx(), dst.storage, dst.path(p), name); err != nil {
+			return "", errors.WithMessage(err, "failed rename the destination's version")
+		}
+		delete(dst.objs, p)
+		renamedPath := stdpath.Join(stdpath.Dir(p), name)
+		renamed, err := op.Get(t.Ctx(), dst.storage, dst.path(renamedPath))
+		if err != nil {
+			return "", errors.WithMessage(err, "failed get the destination's version")
+		}
+		dst.objs[renamedPath] = renamed
+		if _, err := t.copyTwoWay(dst, src, renamedPath, renamed, next); err != nil {
+			return "", err
+		}
+		if _, err := t.copyTwoWay(src, dst, p, a, next); err != nil {
+			return "", err
+		}
+		return "conflict", nil
+	case a.ModTime().Before(b.ModTime()):
+		if _, err := t.copyTwoWay(dst, src, p, b, next); err != nil {
+			return "", err
+		}
+		return "conflict", nil
+	default:
+		if _, err := t.copyTwoWay(src, dst, p, a, next); err != nil {
+			return "", err
+		}
+		return "conflict", nil
+	}
+}
+
+func noProgress(float64) {}
+
+// conflictName is the name of the renamed version of a conflict, such as "a (conflict 20060102-150405).txt"
+func conflictName(name string, now time.Time) string {
+	ext := stdpath.Ext(name)
+	return fmt.Sprintf("%s (conflict %s)%s", strings.TrimSuffix(name, ext), now.Format("20060102-150405"), ext)
+}
+
+// newSyncState records a, the obj of the source, and b, the obj of the destination
+func newSyncState(a, b model.Obj) model.SyncState {
+	sha1 := a.GetHash().GetHash(utils.SHA1)
+	if sha1 == "" {
+		sha1 = b.GetHash().GetHash(utils.SHA1)
+	}
+	return model.SyncState{
+		IsDir:       a.IsDir(),
+		Size:        a.GetSize(),
+		SHA1:        sha1,
+		SrcModified: a.ModTime(),
+		DstModified: b.ModTime(),
+	}
+}
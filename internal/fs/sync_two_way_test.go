@@ -0,0 +1,133 @@
+package fs
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+)
+
+func TestSyncTwoWay(t *testing.T) {
+	src, dst := newSyncDrivers(t)
+	job := createSyncJob(t, model.SyncJob{SrcPath: "/s/dir", DstPath: "/d/out", Mode: model.SyncModeTwoWay, Delete: true})
+	tsk := runSync(t, job.ID)
+	if err := tsk.GetErr(); err != nil {
+		t.Fatalf("task: %v", err)
+	}
+	if tsk.Copied != 2 || dst.content("/out/sub/b.txt") != "bb" {
+		t.Fatalf("expect the files copied to the destination, got %d copied", tsk.Copied)
+	}
+	if states, err := op.GetSyncStates(job.ID); err != nil || len(states) != 3 {
+		t.Fatalf("expect the states of 3 objs saved, got %d %v", len(states), err)
+	}
+	if tsk = runSync(t, job.ID); tsk.Copied != 0 || tsk.Skipped != 3 {
+		t.Fatalf("expect everything in sync, got %d copied and %d skipped", tsk.Copied, tsk.Skipped)
+	}
+
+	// the changes of each side are copied to the other
+	dst.put("/out", "new.txt", []byte("new"), time.Now())
+	src.put("/dir", "a.txt", []byte("aaa"), time.Now())
+	tsk = runSync(t, job.ID)
+	if tsk.Copied != 2 || src.content("/dir/new.txt") != "new" || dst.content("/out/a.txt") != "aaa" {
+		t.Fatalf("expect new.txt and a.txt copied, got %d copied", tsk.Copied)
+	}
+
+	// a deletion is propagated to the unchanged copy
+	if err := src.Remove(context.Background(), &model.Object{ID: "/dir/sub/b.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	tsk = runSync(t, job.ID)
+	if tsk.Deleted != 1 || len(dst.names("/out/sub")) != 0 {
+		t.Fatalf("expect b.txt deleted at the destination, got %d deleted and %v", tsk.Deleted, dst.names("/out/sub"))
+	}
+
+	// the newest version of a conflict wins
+	src.put("/dir", "a.txt", []byte("src!"), time.Now().Add(-time.Minute))
+	dst.put("/out", "a.txt", []byte("dst!!"), time.Now())
+	tsk = runSync(t, job.ID)
+	if len(tsk.Conflicts) != 1 || tsk.Conflicts[0] != "a.txt" {
+		t.Fatalf("expect a conflict of a.txt, got %v", tsk.Conflicts)
+	}
+	if src.content("/dir/a.txt") != "dst!!" || dst.content("/out/a.txt") != "dst!!" {
+		t.Fatalf("expect the newer version on both sides, got %q and %q", src.content("/dir/a.txt"), dst.content("/out/a.txt"))
+	}
+}
+
+func TestSyncTwoWayNoDelete(t *testing.T) {
+	src, dst := newSyncDrivers(t)
+	job := createSyncJob(t, model.SyncJob{SrcPath: "/s/dir", DstPath: "/d/out", Mode: model.SyncModeTwoWay})
+	runSync(t, job.ID)
+	// without Delete a deleted file is copied back
+	if err := dst.Remove(context.Background(), &model.Object{ID: "/out/a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+	if tsk := runSync(t, job.ID); tsk.Copied != 1 || tsk.Deleted != 0 || dst.content("/out/a.txt") != "a" {
+		t.Fatalf("expect a.txt copied back, got %d copied and %d deleted", tsk.Copied, tsk.Deleted)
+	}
+	if src.content("/dir/a.txt") != "a" {
+		t.Fatal("expect the source kept")
+	}
+}
+
+func TestSyncTwoWayConflictPolicies(t *testing.T) {
+	t.Run("keep_both", func(t *testing.T) {
+		src, dst := newSyncDrivers(t)
+		job := createSyncJob(t, model.SyncJob{SrcPath: "/s/dir", DstPath: "/d/out", Mode: model.SyncModeTwoWay,
+			Conflict: model.SyncConflictKeepBoth})
+		runSync(t, job.ID)
+		src.put("/dir", "a.txt", []byte("src!"), time.Now())
+		dst.put("/out", "a.txt", []byte("dst!!"), time.Now())
+		tsk := runSync(t, job.ID)
+		if err := tsk.GetErr(); err != nil || len(tsk.Conflicts) != 1 {
+			t.Fatalf("expect a conflict, got %v and %v", tsk.Conflicts, err)
+		}
+		// the destination's version is kept under another name on both sides
+		for _, d := range []*syncDriver{src, dst} {
+			root := "/dir"
+			if d == dst {
+				root = "/out"
+			}
+			names := d.names(root)
+			if len(names) != 3 || !strings.HasPrefix(names[0], "a (conflict ") || !strings.HasSuffix(names[0], ").txt") {
+				t.Fatalf("%s: expect the renamed version, got %v", d.name, names)
+			}
+			if d.content(root+"/a.txt") != "src!" || d.content(root+"/"+names[0]) != "dst!!" {
+				t.Fatalf("%s: expect both versions kept, got %q and %q", d.name, d.content(root+"/a.txt"), d.content(root+"/"+names[0]))
+			}
+		}
+	})
+	t.Run("skip", func(t *testing.T) {
+		src, dst := newSyncDrivers(t)
+		job := createSyncJob(t, model.SyncJob{SrcPath: "/s/dir", DstPath: "/d/out", Mode: model.SyncModeTwoWay,
+			Conflict: model.SyncConflictSkip})
+		runSync(t, job.ID)
+		src.put("/dir", "a.txt", []byte("src!"), time.Now())
+		dst.put("/out", "a.txt", []byte("dst!!"), time.Now())
+		// both versions are left and the conflict is reported again
+		for i := 0; i < 2; i++ {
+			tsk := runSync(t, job.ID)
+			if strings.Join(tsk.Conflicts, ",") != "a.txt" || tsk.Copied != 0 {
+				t.Fatalf("run %d: expect a conflict of a.txt, got %v and %d copied", i, tsk.Conflicts, tsk.Copied)
+			}
+		}
+		if src.content("/dir/a.txt") != "src!" || dst.content("/out/a.txt") != "dst!!" {
+			t.Fatal("expect both versions left as they are")
+		}
+	})
+}
+
+func TestConflictName(t *testing.T) {
+	now := time.Date(2024, 5, 6, 7, 8, 9, 0, time.UTC)
+	for name, want := range map[string]string{
+		"a.txt":    "a (conflict 20240506-070809).txt",
+		"archive":  "archive (conflict 20240506-070809)",
+		"a.tar.gz": "a.tar (conflict 20240506-070809).gz",
+	} {
+		if got := conflictName(name, now); got != want {
+			t.Fatalf("%s: expect %s, got %s", name, want, got)
+		}
+	}
+}
@@ -95,4 +95,8 @@ func (r *RangeReadCloser) RangeRead(ctx context.Context, httpRange http_range.Ra
 }
 
 // type WriterFunc func(w io.Writer) error
+// WalkFilter reports whether the object at path, relative to the walked folder, is included.
+// Nothing under a folder not included is walked, a nil WalkFilter includes everything.
+type WalkFilter func(path string) bool
+
 type RangeReaderFunc func(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error)
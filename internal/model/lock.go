@@ -0,0 +1,15 @@
+package model
+
+import "time"
+
+// Lock is a WebDAV lock on a path of a storage, kept by the storage when it implements driver.Locker
+type Lock struct {
+	// Token identifies the lock, it's the lock token returned to the client
+	Token string `json:"token"`
+	// Path is the locked path relative to the storage
+	Path string `json:"path"`
+	// ZeroDepth locks only Path, otherwise its descendants are locked too
+	ZeroDepth bool      `json:"zero_depth"`
+	OwnerXML  string    `json:"owner_xml"`
+	Expires   time.Time `json:"expires"`
+}
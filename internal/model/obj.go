@@ -63,6 +63,31 @@ type Thumb interface {
 	Thumb() string
 }
 
+// HashTrusted is implemented by the streams whose hashes were read from a storage or computed by the server,
+// rather than claimed by the client sending the data
+type HashTrusted interface {
+	HashTrusted() bool
+}
+
+// StagedHash is implemented by the streams that the upload layer cached before handing them to the driver,
+// with the hashes it computed from the cached data in the same pass
+type StagedHash interface {
+	// StagedHash returns the hashes computed from the cached data, empty when the stream was not staged
+	StagedHash() utils.HashInfo
+}
+
+// Versioned is implemented by the objects carrying the version of their data in the storage,
+// increased by every change, so that the changes made since they were read can be detected
+type Versioned interface {
+	Version() int64
+}
+
+// Alias is an object listed as a link to another object of the same storage
+type Alias interface {
+	// AliasTarget returns the path of the target in the storage
+	AliasTarget() string
+}
+
 type SetPath interface {
 	SetPath(path string)
 }
@@ -143,6 +168,28 @@ func GetThumb(obj Obj) (thumb string, ok bool) {
 	return thumb, false
 }
 
+// GetVersion returns the version of the data of obj if it carries one
+func GetVersion(obj Obj) (version int64, ok bool) {
+	if obj, ok := obj.(Versioned); ok {
+		return obj.Version(), true
+	}
+	if unwrap, ok := obj.(ObjUnwrap); ok {
+		return GetVersion(unwrap.Unwrap())
+	}
+	return version, false
+}
+
+// GetAliasTarget returns the path of the target if obj is an alias
+func GetAliasTarget(obj Obj) (target string, ok bool) {
+	if obj, ok := obj.(Alias); ok {
+		return obj.AliasTarget(), true
+	}
+	if unwrap, ok := obj.(ObjUnwrap); ok {
+		return GetAliasTarget(unwrap.Unwrap())
+	}
+	return target, false
+}
+
 func GetUrl(obj Obj) (url string, ok bool) {
 	if obj, ok := obj.(URL); ok {
 		return obj.URL(), true
@@ -203,6 +250,12 @@ func (om *ObjMerge) clickObj(obj Obj) bool {
 	return om.set.Add(obj.GetName())
 }
 
+// Add reports whether obj is kept in the merged list, that's it's not hidden
+// and no object of the same name was added before
+func (om *ObjMerge) Add(obj Obj) bool {
+	return om.clickObj(obj)
+}
+
 func (om *ObjMerge) InitHideReg(hides string) {
 	rs := strings.Split(hides, "\n")
 	om.regs = make([]*regexp2.Regexp, 0, len(rs))
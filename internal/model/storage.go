@@ -57,3 +57,13 @@ func (p Proxy) WebdavProxy() bool {
 func (p Proxy) WebdavNative() bool {
 	return !p.Webdav302() && !p.WebdavProxy()
 }
+
+type StorageDetails struct {
+	DiskUsage
+}
+
+type DiskUsage struct {
+	TotalSpace  int64 `json:"total_space"` // 0 if the storage has no known capacity
+	UsedSpace   int64 `json:"used_space"`
+	ObjectCount int64 `json:"object_count"`
+}
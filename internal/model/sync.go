@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+const (
+	// SyncModeOneWay mirrors the source into the destination
+	SyncModeOneWay = "one_way"
+	// SyncModeTwoWay copies the changes made on either side since the last run to the other side
+	SyncModeTwoWay = "two_way"
+)
+
+// how a two way sync resolves a file changed on both sides since the last run
+const (
+	SyncConflictNewest   = "newest"
+	SyncConflictKeepBoth = "keep_both"
+	SyncConflictSkip     = "skip"
+)
+
+// SyncJob syncs SrcPath and DstPath every Interval minutes
+type SyncJob struct {
+	ID      uint   `json:"id" gorm:"primaryKey"`
+	SrcPath string `json:"src_path" binding:"required"`
+	DstPath string `json:"dst_path" binding:"required"`
+	Mode    string `json:"mode"`
+	// Conflict is the policy of a two way sync for conflicts
+	Conflict string `json:"conflict"`
+	// Interval in minutes between runs, 0 to only run on demand
+	Interval int `json:"interval"`
+	// Delete removes the files and folders of DstPath that aren't in SrcPath,
+	// or for a two way sync, propagates the deletions of either side
+	Delete bool `json:"delete"`
+	// CompareHash compares the SHA1 of files of the same size, computing it if a storage doesn't keep it,
+	// otherwise files of the same size are considered in sync
+	CompareHash bool       `json:"compare_hash"`
+	Disabled    bool       `json:"disabled"`
+	LastRunAt   *time.Time `json:"last_run_at"`
+}
+
+// SyncState is an obj of a two way sync job as both sides had it after the last run,
+// a side whose obj differs from the state has changed since
+type SyncState struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	JobID       uint      `json:"job_id" gorm:"index"`
+	Path        string    `json:"path"`
+	IsDir       bool      `json:"is_dir"`
+	Size        int64     `json:"size"`
+	SHA1        string    `json:"sha1"`
+	SrcModified time.Time `json:"src_modified"`
+	DstModified time.Time `json:"dst_modified"`
+}
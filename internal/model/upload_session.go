@@ -0,0 +1,16 @@
+package model
+
+import "time"
+
+// UploadSession is the upload of a large file whose parts are sent by separate requests
+type UploadSession struct {
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Size     int64  `json:"size"`
+	PartSize int64  `json:"part_size"`
+	// Parts is the number of parts, the last part may be smaller than PartSize
+	Parts int `json:"parts"`
+	// Received is the indexes of the parts already uploaded, in ascending order
+	Received []int     `json:"received"`
+	Expires  time.Time `json:"expires"`
+}
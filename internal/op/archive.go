@@ -30,7 +30,7 @@ func GetArchiveMeta(ctx context.Context, storage driver.Driver, path string, arg
 		return nil, errors.Errorf("storage not init: %s", storage.GetStorage().Status)
 	}
 	path = utils.FixAndCleanPath(path)
-	key := Key(storage, path)
+	key := scopedKey(ctx, storage, path)
 	if !args.Refresh {
 		if meta, ok := archiveMetaCache.Get(key); ok {
 			log.Debugf("use cache when get %s archive meta", path)
@@ -189,7 +189,7 @@ func ListArchive(ctx context.Context, storage driver.Driver, path string, args m
 		return nil, errors.Errorf("storage not init: %s", storage.GetStorage().Status)
 	}
 	path = utils.FixAndCleanPath(path)
-	metaKey := Key(storage, path)
+	metaKey := scopedKey(ctx, storage, path)
 	key := stdpath.Join(metaKey, args.InnerPath)
 	if !args.Refresh {
 		if files, ok := archiveListCache.Get(key); ok {
@@ -389,7 +389,7 @@ func DriverExtract(ctx context.Context, storage driver.Driver, path string, args
 	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
 		return nil, nil, errors.Errorf("storage not init: %s", storage.GetStorage().Status)
 	}
-	key := stdpath.Join(Key(storage, path), args.InnerPath)
+	key := stdpath.Join(scopedKey(ctx, storage, path), args.InnerPath)
 	if link, ok := extractCache.Get(key); ok {
 		return link.Link, link.Obj, nil
 	} else if link, ok := extractCache.Get(key + ":" + args.IP); ok {
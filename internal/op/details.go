@@ -0,0 +1,41 @@
+package op
+
+import (
+	"context"
+	"time"
+
+	"github.com/Xhofe/go-cache"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/singleflight"
+	"github.com/pkg/errors"
+)
+
+// details are summed up by the driver and may be queried for every PROPFIND entry, so cache them for a while
+var detailsCache = cache.NewMemCache(cache.WithShards[*model.StorageDetails](4))
+var detailsG singleflight.Group[*model.StorageDetails]
+
+// GetStorageDetails get the space usage of the storage, return errs.NotImplement if the driver can't report it
+func GetStorageDetails(ctx context.Context, storage driver.Driver) (*model.StorageDetails, error) {
+	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
+		return nil, errors.Errorf("storage not init: %s", storage.GetStorage().Status)
+	}
+	wd, ok := storage.(driver.WithDetails)
+	if !ok {
+		return nil, errs.NotImplement
+	}
+	key := storage.GetStorage().MountPath
+	if details, ok := detailsCache.Get(key); ok {
+		return details, nil
+	}
+	details, err, _ := detailsG.Do(key, func() (*model.StorageDetails, error) {
+		details, err := wd.GetDetails(ctx)
+		if err != nil {
+			return nil, err
+		}
+		detailsCache.Set(key, details, cache.WithEx[*model.StorageDetails](time.Minute))
+		return details, nil
+	})
+	return details, err
+}
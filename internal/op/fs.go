@@ -2,6 +2,7 @@ package op
 
 import (
 	"context"
+	"io"
 	stdpath "path"
 	"slices"
 	"time"
@@ -107,6 +108,18 @@ func Key(storage driver.Driver, path string) string {
 	return stdpath.Join(storage.GetStorage().MountPath, utils.FixAndCleanPath(path))
 }
 
+// scopedKey is Key with the user scope of a driver.UserScoped storage appended,
+// used for the results that depend on the user in ctx
+func scopedKey(ctx context.Context, storage driver.Driver, path string) string {
+	key := Key(storage, path)
+	if s, ok := storage.(driver.UserScoped); ok {
+		if scope := s.UserScope(ctx); scope != "" {
+			key += "@" + scope
+		}
+	}
+	return key
+}
+
 // List files in storage, not contains virtual file
 func List(ctx context.Context, storage driver.Driver, path string, args model.ListArgs) ([]model.Obj, error) {
 	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
@@ -114,7 +127,7 @@ func List(ctx context.Context, storage driver.Driver, path string, args model.Li
 	}
 	path = utils.FixAndCleanPath(path)
 	log.Debugf("op.List %s", path)
-	key := Key(storage, path)
+	key := scopedKey(ctx, storage, path)
 	if !args.Refresh {
 		if files, ok := listCache.Get(key); ok {
 			log.Debugf("use cache when list %s", path)
@@ -130,7 +143,7 @@ func List(ctx context.Context, storage driver.Driver, path string, args model.Li
 		return nil, errors.WithStack(errs.NotFolder)
 	}
 	objs, err, _ := listG.Do(key, func() ([]model.Obj, error) {
-		files, err := storage.List(ctx, dir, args)
+		files, err := listWithStats(ctx, storage, dir, args)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed to list objs")
 		}
@@ -167,6 +180,48 @@ func List(ctx context.Context, storage driver.Driver, path string, args model.Li
 	return objs, err
 }
 
+// ListStream calls fn with the objects in the dir at path. Unless the list is cached or sorted locally,
+// they're read by driver.ListStream while fn is called, and such a list isn't cached or passed to the hooks.
+func ListStream(ctx context.Context, storage driver.Driver, path string, args model.ListArgs, fn func(obj model.Obj) error) error {
+	path = utils.FixAndCleanPath(path)
+	s, ok := storage.(driver.ListStream)
+	if ok && !args.Refresh {
+		// the cached list is used as is
+		if _, cached := listCache.Get(Key(storage, path)); cached {
+			ok = false
+		}
+	}
+	if !ok || storage.Config().LocalSort || storage.GetStorage().ExtractFolder != "" {
+		objs, err := List(ctx, storage, path, args)
+		if err != nil {
+			return err
+		}
+		for _, obj := range objs {
+			if err := fn(obj); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
+		return errors.Errorf("storage not init: %s", storage.GetStorage().Status)
+	}
+	log.Debugf("op.ListStream %s", path)
+	dir, err := GetUnwrap(ctx, storage, path)
+	if err != nil {
+		return errors.WithMessage(err, "failed get dir")
+	}
+	if !dir.IsDir() {
+		return errors.WithStack(errs.NotFolder)
+	}
+	return errors.WithStack(listStreamWithStats(ctx, storage, s, dir, args, func(obj model.Obj) error {
+		if s, ok := obj.(model.SetPath); ok && obj.GetPath() == "" && dir.GetPath() != "" {
+			s.SetPath(stdpath.Join(dir.GetPath(), obj.GetName()))
+		}
+		return fn(model.WrapObjName(obj))
+	}))
+}
+
 // Get object from list of files
 func Get(ctx context.Context, storage driver.Driver, path string) (model.Obj, error) {
 	path = utils.FixAndCleanPath(path)
@@ -258,12 +313,17 @@ func Link(ctx context.Context, storage driver.Driver, path string, args model.Li
 	if file.IsDir() {
 		return nil, nil, errors.WithStack(errs.NotFile)
 	}
-	key := Key(storage, path)
+	key := scopedKey(ctx, storage, path)
+	if v, ok := storage.(driver.LinkVariant); ok {
+		if variant := v.LinkVariant(args); variant != "" {
+			key += "#" + variant
+		}
+	}
 	if link, ok := linkCache.Get(key); ok {
 		return link, file, nil
 	}
 	fn := func() (*model.Link, error) {
-		link, err := storage.Link(ctx, file, args)
+		link, err := linkWithStats(ctx, storage, file, args)
 		if err != nil {
 			return nil, errors.Wrapf(err, "failed get link")
 		}
@@ -309,7 +369,7 @@ func MakeDir(ctx context.Context, storage driver.Driver, path string, lazyCache
 		return errors.Errorf("storage not init: %s", storage.GetStorage().Status)
 	}
 	path = utils.FixAndCleanPath(path)
-	key := Key(storage, path)
+	key := scopedKey(ctx, storage, path)
 	_, err, _ := mkdirG.Do(key, func() (interface{}, error) {
 		// check if dir exists
 		f, err := GetUnwrap(ctx, storage, path)
@@ -474,6 +534,41 @@ func Copy(ctx context.Context, storage driver.Driver, srcPath, dstDirPath string
 	return errors.WithStack(err)
 }
 
+// CopyFrom copies srcPath of srcStorage into dstDirPath of dstStorage by driver.CopyFrom,
+// it returns errs.NotImplement if dstStorage can't copy from other storages
+func CopyFrom(ctx context.Context, srcStorage, dstStorage driver.Driver, srcPath, dstDirPath string, lazyCache ...bool) error {
+	s, ok := dstStorage.(driver.CopyFrom)
+	if !ok {
+		return errs.NotImplement
+	}
+	if dstStorage.Config().CheckStatus && dstStorage.GetStorage().Status != WORK {
+		return errors.Errorf("storage not init: %s", dstStorage.GetStorage().Status)
+	}
+	srcPath = utils.FixAndCleanPath(srcPath)
+	dstDirPath = utils.FixAndCleanPath(dstDirPath)
+	srcObj, err := GetUnwrap(ctx, srcStorage, srcPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get src object")
+	}
+	// the dst dir may not exist yet when copying a folder recursively, as with Put
+	if err = MakeDir(ctx, dstStorage, dstDirPath); err != nil {
+		return errors.WithMessagef(err, "failed to make dir [%s]", dstDirPath)
+	}
+	dstDir, err := GetUnwrap(ctx, dstStorage, dstDirPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get dst dir")
+	}
+	newObj, err := s.CopyFrom(ctx, srcStorage, srcObj, dstDir)
+	if err == nil {
+		if newObj != nil {
+			addCacheObj(dstStorage, dstDirPath, model.WrapObjName(newObj))
+		} else if !utils.IsBool(lazyCache...) {
+			ClearCache(dstStorage, dstDirPath)
+		}
+	}
+	return errors.WithStack(err)
+}
+
 func Remove(ctx context.Context, storage driver.Driver, path string) error {
 	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
 		return errors.Errorf("storage not init: %s", storage.GetStorage().Status)
@@ -560,6 +655,7 @@ func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file mod
 		up = func(p float64) {}
 	}
 
+	start := time.Now()
 	switch s := storage.(type) {
 	case driver.PutResult:
 		var newObj model.Obj
@@ -579,6 +675,7 @@ func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file mod
 	default:
 		return errs.NotImplement
 	}
+	recordOp(storage, "put", start, &err)
 	log.Debugf("put file [%s] done", file.GetName())
 	if storage.Config().NoOverwriteUpload && fi != nil && fi.GetSize() > 0 {
 		if err != nil {
@@ -593,7 +690,7 @@ func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file mod
 			if err != nil {
 				return err
 			} else {
-				key := Key(storage, stdpath.Join(dstDirPath, file.GetName()))
+				key := scopedKey(ctx, storage, stdpath.Join(dstDirPath, file.GetName()))
 				linkCache.Del(key)
 			}
 		}
@@ -601,6 +698,103 @@ func Put(ctx context.Context, storage driver.Driver, dstDirPath string, file mod
 	return errors.WithStack(err)
 }
 
+// Append writes the data of file after the end of the file at dstPath, the storage must implement driver.Append
+func Append(ctx context.Context, storage driver.Driver, dstPath string, file model.FileStreamer, up driver.UpdateProgress) error {
+	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
+		return errors.Errorf("storage not init: %s", storage.GetStorage().Status)
+	}
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Errorf("failed to close file streamer, %v", err)
+		}
+	}()
+	s, ok := storage.(driver.Append)
+	if !ok {
+		return errs.NotImplement
+	}
+	dstPath = utils.FixAndCleanPath(dstPath)
+	obj, err := GetUnwrap(ctx, storage, dstPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get file")
+	}
+	if obj.IsDir() {
+		return errors.WithStack(errs.NotFile)
+	}
+	if up == nil {
+		up = func(p float64) {}
+	}
+	start := time.Now()
+	_, err = s.Append(ctx, obj, file, up)
+	recordOp(storage, "append", start, &err)
+	if err == nil {
+		ClearCache(storage, stdpath.Dir(dstPath))
+		linkCache.Del(scopedKey(ctx, storage, dstPath))
+	}
+	return errors.WithStack(err)
+}
+
+// ZipDir writes the files under dirPath included by filter to w as a zip archive, the storage must implement driver.ZipDir
+func ZipDir(ctx context.Context, storage driver.Driver, dirPath string, filter model.WalkFilter, w io.Writer) error {
+	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
+		return errors.Errorf("storage not init: %s", storage.GetStorage().Status)
+	}
+	s, ok := storage.(driver.ZipDir)
+	if !ok {
+		return errs.NotImplement
+	}
+	dirPath = utils.FixAndCleanPath(dirPath)
+	obj, err := GetUnwrap(ctx, storage, dirPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get dir")
+	}
+	if !obj.IsDir() {
+		return errors.WithStack(errs.NotFolder)
+	}
+	start := time.Now()
+	err = s.ZipDir(ctx, obj, filter, w)
+	recordOp(storage, "zip", start, &err)
+	return errors.WithStack(err)
+}
+
+// ExportList writes the list of the files under dirPath included by filter to w, the storage must implement driver.ExportList
+func ExportList(ctx context.Context, storage driver.Driver, dirPath, format string, filter model.WalkFilter, w io.Writer) error {
+	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
+		return errors.Errorf("storage not init: %s", storage.GetStorage().Status)
+	}
+	s, ok := storage.(driver.ExportList)
+	if !ok {
+		return errs.NotImplement
+	}
+	dirPath = utils.FixAndCleanPath(dirPath)
+	obj, err := GetUnwrap(ctx, storage, dirPath)
+	if err != nil {
+		return errors.WithMessage(err, "failed to get dir")
+	}
+	if !obj.IsDir() {
+		return errors.WithStack(errs.NotFolder)
+	}
+	start := time.Now()
+	err = s.ExportList(ctx, obj, format, filter, w)
+	recordOp(storage, "export", start, &err)
+	return errors.WithStack(err)
+}
+
+// VerifyShare checks the share token of the file at path, storages not implementing driver.Share reject all tokens
+func VerifyShare(ctx context.Context, storage driver.Driver, path, token string) error {
+	s, ok := storage.(driver.Share)
+	if !ok {
+		return errs.NotImplement
+	}
+	obj, err := GetUnwrap(ctx, storage, utils.FixAndCleanPath(path))
+	if err != nil {
+		return errors.WithMessage(err, "failed to get file")
+	}
+	if obj.IsDir() {
+		return errors.WithStack(errs.NotFile)
+	}
+	return s.VerifyShare(ctx, obj, token)
+}
+
 func PutURL(ctx context.Context, storage driver.Driver, dstDirPath, dstName, url string, lazyCache ...bool) error {
 	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
 		return errors.Errorf("storage not init: %s", storage.GetStorage().Status)
@@ -57,6 +57,16 @@ var settingItemHooks = map[string]SettingItemHook{
 		conf.SlicesMap[conf.ProxyIgnoreHeaders] = strings.Split(item.Value, ",")
 		return nil
 	},
+	conf.WatermarkPaths: func(item *model.SettingItem) error {
+		paths := make([]string, 0)
+		for _, p := range strings.Split(item.Value, "\n") {
+			if p = strings.TrimSpace(p); p != "" {
+				paths = append(paths, utils.FixAndCleanPath(p))
+			}
+		}
+		conf.SlicesMap[conf.WatermarkPaths] = paths
+		return nil
+	},
 	conf.PrivacyRegs: func(item *model.SettingItem) error {
 		regStrs := strings.Split(item.Value, "\n")
 		regs := make([]*regexp.Regexp, 0, len(regStrs))
@@ -0,0 +1,88 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// streamDriver lists b.txt and a.txt in any folder, by List or by ListStream
+type streamDriver struct {
+	statsDriver
+	config   driver.Config
+	lists    int
+	streamed int
+}
+
+func (d *streamDriver) Config() driver.Config { return d.config }
+
+func (d *streamDriver) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	d.lists++
+	return []model.Obj{&model.Object{Name: "b.txt"}, &model.Object{Name: "a.txt"}}, nil
+}
+
+func (d *streamDriver) ListStream(ctx context.Context, dir model.Obj, args model.ListArgs, fn func(obj model.Obj) error) error {
+	d.streamed++
+	for _, name := range []string{"b.txt", "a.txt"} {
+		if err := fn(&model.Object{Name: name}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func listStreamNames(t *testing.T, d driver.Driver, args model.ListArgs) string {
+	t.Helper()
+	var names []string
+	err := ListStream(context.Background(), d, "/", args, func(obj model.Obj) error {
+		names = append(names, obj.GetName())
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestListStream(t *testing.T) {
+	d := &streamDriver{statsDriver: statsDriver{Storage: model.Storage{MountPath: "/stream"}}, config: driver.Config{Name: "StreamTest", NoCache: true}}
+	if names := listStreamNames(t, d, model.ListArgs{}); names != "b.txt,a.txt" || d.streamed != 1 || d.lists != 0 {
+		t.Fatalf("expect the objects streamed, got %s by %d lists", names, d.lists)
+	}
+	// the error of fn stops the list
+	stop := errors.New("stop")
+	n := 0
+	err := ListStream(context.Background(), d, "/", model.ListArgs{}, func(obj model.Obj) error {
+		n++
+		return stop
+	})
+	if !errors.Is(err, stop) || n != 1 {
+		t.Fatalf("expect the list stopped at the first object, got %v after %d", err, n)
+	}
+
+	// a list sorted locally is read as a whole
+	d.config.LocalSort = true
+	d.OrderBy, d.OrderDirection = "name", "asc"
+	if names := listStreamNames(t, d, model.ListArgs{}); names != "a.txt,b.txt" || d.lists != 1 {
+		t.Fatalf("expect the sorted list, got %s by %d lists", names, d.lists)
+	}
+}
+
+func TestListStreamCached(t *testing.T) {
+	d := &streamDriver{statsDriver: statsDriver{Storage: model.Storage{MountPath: "/stream-cached", CacheExpiration: 1}}, config: driver.Config{Name: "StreamTest"}}
+	defer ClearCache(d, "/")
+	if _, err := List(context.Background(), d, "/", model.ListArgs{}); err != nil {
+		t.Fatal(err)
+	}
+	if names := listStreamNames(t, d, model.ListArgs{}); names != "b.txt,a.txt" || d.lists != 1 || d.streamed != 0 {
+		t.Fatalf("expect the cached list, got %s by %d lists and %d streams", names, d.lists, d.streamed)
+	}
+	// a refresh streams the storage again
+	if listStreamNames(t, d, model.ListArgs{Refresh: true}); d.streamed != 1 {
+		t.Fatalf("expect a refresh streamed, got %d streams", d.streamed)
+	}
+}
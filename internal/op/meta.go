@@ -94,3 +94,81 @@ func GetMetaById(id uint) (*model.Meta, error) {
 func GetMetas(pageIndex, pageSize int) (metas []model.Meta, count int64, err error) {
 	return db.GetMetas(pageIndex, pageSize)
 }
+
+const (
+	MetaConflictSkip      = "skip"
+	MetaConflictOverwrite = "overwrite"
+	MetaConflictAbort     = "abort"
+)
+
+type MetaImportResult struct {
+	Created     int      `json:"created"`
+	Overwritten int      `json:"overwritten"`
+	Skipped     []string `json:"skipped"`
+}
+
+// ExportMetas returns all meta rules, without ids so that they can be imported into another instance
+func ExportMetas() ([]model.Meta, error) {
+	metas, err := db.GetAllMetas()
+	if err != nil {
+		return nil, err
+	}
+	for i := range metas {
+		metas[i].ID = 0
+	}
+	return metas, nil
+}
+
+// ImportMetas imports meta rules matched by path, conflict decides what to do when a path already has a rule:
+// skip keeps the existing rule, overwrite replaces it, abort stops before anything is written
+func ImportMetas(metas []model.Meta, conflict string) (*MetaImportResult, error) {
+	switch conflict {
+	case "":
+		conflict = MetaConflictSkip
+	case MetaConflictSkip, MetaConflictOverwrite, MetaConflictAbort:
+	default:
+		return nil, errors.Errorf("invalid conflict mode: %s", conflict)
+	}
+	existing := make(map[string]uint, len(metas))
+	for i := range metas {
+		metas[i].Path = utils.FixAndCleanPath(metas[i].Path)
+		old, err := db.GetMetaByPath(metas[i].Path)
+		if err == nil {
+			existing[metas[i].Path] = old.ID
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+	if conflict == MetaConflictAbort && len(existing) > 0 {
+		paths := make([]string, 0, len(existing))
+		for p := range existing {
+			paths = append(paths, p)
+		}
+		return nil, errors.Errorf("meta already exists: %v", paths)
+	}
+	res := &MetaImportResult{Skipped: []string{}}
+	for i := range metas {
+		m := metas[i]
+		id, ok := existing[m.Path]
+		if ok && conflict == MetaConflictSkip {
+			res.Skipped = append(res.Skipped, m.Path)
+			continue
+		}
+		metaCache.Del(m.Path)
+		if ok {
+			m.ID = id
+			if err := db.UpdateMeta(&m); err != nil {
+				return res, errors.WithMessagef(err, "failed update meta [%s]", m.Path)
+			}
+			res.Overwritten++
+			continue
+		}
+		m.ID = 0
+		if err := db.CreateMeta(&m); err != nil {
+			return res, errors.WithMessagef(err, "failed create meta [%s]", m.Path)
+		}
+		existing[m.Path] = m.ID
+		res.Created++
+	}
+	return res, nil
+}
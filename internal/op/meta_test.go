@@ -0,0 +1,79 @@
+package op_test
+
+import (
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+)
+
+func TestImportMetas(t *testing.T) {
+	if err := op.CreateMeta(&model.Meta{Path: "/import/a", Password: "old"}); err != nil {
+		t.Fatal(err)
+	}
+	metas := func() []model.Meta {
+		return []model.Meta{{Path: "/import/a/", Password: "new"}, {Path: "/import/b", Password: "b"}}
+	}
+	password := func(path string) string {
+		m, err := op.GetMetaByPath(path)
+		if err != nil {
+			t.Fatalf("get meta %s: %v", path, err)
+		}
+		return m.Password
+	}
+
+	// abort writes nothing when a path already has a rule
+	if _, err := op.ImportMetas(metas(), op.MetaConflictAbort); err == nil {
+		t.Fatal("expect the conflict to abort the import")
+	}
+	if _, err := op.GetMetaByPath("/import/b"); err == nil {
+		t.Fatal("expect nothing imported after abort")
+	}
+	// skip keeps the existing rule, the paths are matched after cleaning
+	res, err := op.ImportMetas(metas(), op.MetaConflictSkip)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Created != 1 || res.Overwritten != 0 || len(res.Skipped) != 1 || res.Skipped[0] != "/import/a" {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if p := password("/import/a"); p != "old" {
+		t.Fatalf("expect the existing rule kept, got password %q", p)
+	}
+	// overwrite replaces the existing rules
+	res, err = op.ImportMetas(metas(), op.MetaConflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Created != 0 || res.Overwritten != 2 {
+		t.Fatalf("unexpected result %+v", res)
+	}
+	if p := password("/import/a"); p != "new" {
+		t.Fatalf("expect the rule overwritten, got password %q", p)
+	}
+	if _, err := op.ImportMetas(metas(), "merge"); err == nil {
+		t.Fatal("expect an unknown conflict mode to fail")
+	}
+}
+
+func TestExportMetas(t *testing.T) {
+	if err := op.CreateMeta(&model.Meta{Path: "/export/a", Readme: "hi"}); err != nil {
+		t.Fatal(err)
+	}
+	metas, err := op.ExportMetas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	found := false
+	for _, m := range metas {
+		if m.ID != 0 {
+			t.Fatalf("expect the ids left out, got %d for %s", m.ID, m.Path)
+		}
+		if m.Path == "/export/a" && m.Readme == "hi" {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("expect /export/a exported, got %+v", metas)
+	}
+}
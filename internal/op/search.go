@@ -0,0 +1,41 @@
+package op
+
+import (
+	"context"
+	stdpath "path"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// Search searches the folder dirPath of the storage by the driver itself, the parents of the
+// returned nodes are full paths like those of the search index
+func Search(ctx context.Context, storage driver.Driver, dirPath string, req model.SearchReq) ([]model.SearchNode, int64, error) {
+	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
+		return nil, 0, errors.Errorf("storage not init: %s", storage.GetStorage().Status)
+	}
+	s, ok := storage.(driver.Search)
+	if !ok {
+		return nil, 0, errs.NotImplement
+	}
+	dirPath = utils.FixAndCleanPath(dirPath)
+	dir, err := GetUnwrap(ctx, storage, dirPath)
+	if err != nil {
+		return nil, 0, errors.WithMessagef(err, "failed to get dir [%s]", dirPath)
+	}
+	if !dir.IsDir() {
+		return nil, 0, errors.WithStack(errs.NotFolder)
+	}
+	nodes, total, err := s.Search(ctx, dir, req)
+	if err != nil {
+		return nil, 0, errors.WithStack(err)
+	}
+	base := stdpath.Join(storage.GetStorage().MountPath, dirPath)
+	for i := range nodes {
+		nodes[i].Parent = stdpath.Join(base, nodes[i].Parent)
+	}
+	return nodes, total, nil
+}
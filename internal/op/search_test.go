@@ -0,0 +1,55 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// searchDriver has a folder docs and a file a.txt in the root, and finds b.txt in the folder sub of any folder
+type searchDriver struct {
+	statsDriver
+	searched string
+}
+
+func (d *searchDriver) Config() driver.Config { return driver.Config{Name: "SearchTest", NoCache: true} }
+
+func (d *searchDriver) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	return []model.Obj{
+		&model.Object{Name: "docs", IsFolder: true},
+		&model.Object{Name: "a.txt"},
+	}, nil
+}
+
+func (d *searchDriver) Search(ctx context.Context, dir model.Obj, req model.SearchReq) ([]model.SearchNode, int64, error) {
+	d.searched = dir.GetName()
+	return []model.SearchNode{{Parent: "/sub", Name: "b.txt", Size: 1}}, 1, nil
+}
+
+func TestSearch(t *testing.T) {
+	d := &searchDriver{statsDriver: statsDriver{Storage: model.Storage{MountPath: "/search"}}}
+	ctx := context.Background()
+	nodes, total, err := Search(ctx, d, "/docs", model.SearchReq{Keywords: "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.searched != "docs" {
+		t.Fatalf("expect docs searched, got %q", d.searched)
+	}
+	// the parents become full paths
+	if total != 1 || len(nodes) != 1 || nodes[0].Parent != "/search/docs/sub" {
+		t.Fatalf("expect /search/docs/sub/b.txt, got %+v of %d", nodes, total)
+	}
+
+	if _, _, err := Search(ctx, d, "/a.txt", model.SearchReq{}); !errors.Is(err, errs.NotFolder) {
+		t.Fatalf("expect a file not searched, got %v", err)
+	}
+	plain := &statsDriver{Storage: model.Storage{MountPath: "/plain"}}
+	if _, _, err := Search(ctx, plain, "/", model.SearchReq{}); !errors.Is(err, errs.NotImplement) {
+		t.Fatalf("expect a driver without search to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+package op
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/generic_sync"
+)
+
+const (
+	statsBucketDuration = time.Minute
+	statsBucketCount    = 10
+)
+
+// OpStat is the statistics of one kind of driver operation in the recent window
+type OpStat struct {
+	Count      int64   `json:"count"`
+	Errors     int64   `json:"errors"`
+	ErrorRate  float64 `json:"error_rate"`
+	AvgLatency int64   `json:"avg_latency"` // milliseconds
+	MaxLatency int64   `json:"max_latency"` // milliseconds
+}
+
+type statsBucket struct {
+	slot       int64
+	count      int64
+	errors     int64
+	latency    time.Duration
+	maxLatency time.Duration
+}
+
+// opRecorder keeps a ring of per-minute buckets, so that only the last
+// statsBucketCount minutes are taken into account
+type opRecorder struct {
+	mu      sync.Mutex
+	buckets [statsBucketCount]statsBucket
+}
+
+func (r *opRecorder) record(now time.Time, latency time.Duration, failed bool) {
+	slot := now.UnixNano() / int64(statsBucketDuration)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	b := &r.buckets[slot%statsBucketCount]
+	if b.slot != slot {
+		*b = statsBucket{slot: slot}
+	}
+	b.count++
+	if failed {
+		b.errors++
+	}
+	b.latency += latency
+	b.maxLatency = max(b.maxLatency, latency)
+}
+
+func (r *opRecorder) snapshot(now time.Time) OpStat {
+	oldest := now.UnixNano()/int64(statsBucketDuration) - statsBucketCount + 1
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var stat OpStat
+	var latency, maxLatency time.Duration
+	for _, b := range r.buckets {
+		if b.slot < oldest {
+			continue
+		}
+		stat.Count += b.count
+		stat.Errors += b.errors
+		latency += b.latency
+		maxLatency = max(maxLatency, b.maxLatency)
+	}
+	if stat.Count > 0 {
+		stat.ErrorRate = float64(stat.Errors) / float64(stat.Count)
+		stat.AvgLatency = (latency / time.Duration(stat.Count)).Milliseconds()
+	}
+	stat.MaxLatency = maxLatency.Milliseconds()
+	return stat
+}
+
+// storageStats mount path -> operation name -> recorder
+var storageStats generic_sync.MapOf[string, *generic_sync.MapOf[string, *opRecorder]]
+
+// recordOp record the result of a driver operation, should be called with defer
+// right before calling the driver, e.g. defer recordOp(storage, "list", time.Now(), &err)
+func recordOp(storage driver.Driver, op string, start time.Time, err *error) {
+	ops, _ := storageStats.LoadOrStore(storage.GetStorage().MountPath, &generic_sync.MapOf[string, *opRecorder]{})
+	r, _ := ops.LoadOrStore(op, &opRecorder{})
+	now := time.Now()
+	r.record(now, now.Sub(start), *err != nil)
+}
+
+// GetStorageStats returns the operation statistics of the storage in the recent window
+func GetStorageStats(mountPath string) map[string]OpStat {
+	res := make(map[string]OpStat)
+	ops, ok := storageStats.Load(mountPath)
+	if !ok {
+		return res
+	}
+	now := time.Now()
+	ops.Range(func(op string, r *opRecorder) bool {
+		res[op] = r.snapshot(now)
+		return true
+	})
+	return res
+}
+
+func listWithStats(ctx context.Context, storage driver.Driver, dir model.Obj, args model.ListArgs) (objs []model.Obj, err error) {
+	defer recordOp(storage, "list", time.Now(), &err)
+	return storage.List(ctx, dir, args)
+}
+
+func listStreamWithStats(ctx context.Context, storage driver.Driver, s driver.ListStream, dir model.Obj, args model.ListArgs, fn func(obj model.Obj) error) (err error) {
+	defer recordOp(storage, "list", time.Now(), &err)
+	return s.ListStream(ctx, dir, args, fn)
+}
+
+func linkWithStats(ctx context.Context, storage driver.Driver, file model.Obj, args model.LinkArgs) (link *model.Link, err error) {
+	defer recordOp(storage, "link", time.Now(), &err)
+	return storage.Link(ctx, file, args)
+}
@@ -0,0 +1,78 @@
+package op
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestOpRecorderWindow(t *testing.T) {
+	var r opRecorder
+	now := time.Unix(1700000000, 0)
+	r.record(now, 100*time.Millisecond, false)
+	r.record(now, 300*time.Millisecond, true)
+	stat := r.snapshot(now)
+	if stat.Count != 2 || stat.Errors != 1 || stat.ErrorRate != 0.5 || stat.AvgLatency != 200 || stat.MaxLatency != 300 {
+		t.Fatalf("unexpected stat %+v", stat)
+	}
+	// the buckets older than the window are left out
+	later := now.Add(statsBucketCount * statsBucketDuration)
+	r.record(later, 50*time.Millisecond, false)
+	stat = r.snapshot(later)
+	if stat.Count != 1 || stat.Errors != 0 || stat.MaxLatency != 50 {
+		t.Fatalf("expect only the recent operation, got %+v", stat)
+	}
+	if stat = r.snapshot(later.Add(statsBucketCount * statsBucketDuration)); stat.Count != 0 || stat.ErrorRate != 0 {
+		t.Fatalf("expect no operation, got %+v", stat)
+	}
+}
+
+// statsDriver fails listing the folder named "broken"
+type statsDriver struct {
+	model.Storage
+}
+
+func (d *statsDriver) Config() driver.Config          { return driver.Config{Name: "StatsTest"} }
+func (d *statsDriver) GetAddition() driver.Additional { return &struct{}{} }
+func (d *statsDriver) Init(ctx context.Context) error { return nil }
+func (d *statsDriver) Drop(ctx context.Context) error { return nil }
+func (d *statsDriver) GetRoot(context.Context) (model.Obj, error) {
+	return &model.Object{IsFolder: true}, nil
+}
+
+func (d *statsDriver) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	if dir.GetName() == "broken" {
+		return nil, errors.New("list failed")
+	}
+	return nil, nil
+}
+
+func (d *statsDriver) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	return &model.Link{URL: "http://example.com"}, nil
+}
+
+func TestStorageStats(t *testing.T) {
+	d := &statsDriver{Storage: model.Storage{MountPath: "/stats"}}
+	defer storageStats.Delete("/stats")
+	ctx := context.Background()
+	_, _ = listWithStats(ctx, d, &model.Object{Name: "ok", IsFolder: true}, model.ListArgs{})
+	_, _ = listWithStats(ctx, d, &model.Object{Name: "broken", IsFolder: true}, model.ListArgs{})
+	_, _ = linkWithStats(ctx, d, &model.Object{Name: "a"}, model.LinkArgs{})
+	stats := GetStorageStats("/stats")
+	if s := stats["list"]; s.Count != 2 || s.Errors != 1 {
+		t.Fatalf("expect 2 lists with 1 error, got %+v", s)
+	}
+	if s := stats["link"]; s.Count != 1 || s.Errors != 0 {
+		t.Fatalf("expect 1 link, got %+v", s)
+	}
+	if _, ok := stats["put"]; ok {
+		t.Fatal("expect no put recorded")
+	}
+	if stats = GetStorageStats("/other"); len(stats) != 0 {
+		t.Fatalf("expect no stats for another storage, got %v", stats)
+	}
+}
@@ -0,0 +1,115 @@
+package op
+
+import (
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// SyncJobHook is called after a sync job is saved, or with deleted set after it's deleted
+type SyncJobHook func(job *model.SyncJob, deleted bool)
+
+var syncJobHooks = make([]SyncJobHook, 0)
+
+func callSyncJobHooks(job *model.SyncJob, deleted bool) {
+	for _, hook := range syncJobHooks {
+		hook(job, deleted)
+	}
+}
+
+func RegisterSyncJobHook(hook SyncJobHook) {
+	syncJobHooks = append(syncJobHooks, hook)
+}
+
+func validSyncJob(j *model.SyncJob) error {
+	j.SrcPath = utils.FixAndCleanPath(j.SrcPath)
+	j.DstPath = utils.FixAndCleanPath(j.DstPath)
+	if utils.IsSubPath(j.SrcPath, j.DstPath) || utils.IsSubPath(j.DstPath, j.SrcPath) {
+		return errors.New("the source and the destination can't contain each other")
+	}
+	if j.Interval < 0 {
+		return errors.New("the interval can't be negative")
+	}
+	if j.Mode == "" {
+		j.Mode = model.SyncModeOneWay
+	}
+	if j.Mode != model.SyncModeOneWay && j.Mode != model.SyncModeTwoWay {
+		return errors.Errorf("invalid sync mode: %s", j.Mode)
+	}
+	switch j.Conflict {
+	case "":
+		j.Conflict = model.SyncConflictNewest
+	case model.SyncConflictNewest, model.SyncConflictKeepBoth, model.SyncConflictSkip:
+	default:
+		return errors.Errorf("invalid conflict policy: %s", j.Conflict)
+	}
+	return nil
+}
+
+func CreateSyncJob(j *model.SyncJob) error {
+	if err := validSyncJob(j); err != nil {
+		return err
+	}
+	if err := db.CreateSyncJob(j); err != nil {
+		return err
+	}
+	callSyncJobHooks(j, false)
+	return nil
+}
+
+func UpdateSyncJob(j *model.SyncJob) error {
+	if err := validSyncJob(j); err != nil {
+		return err
+	}
+	old, err := db.GetSyncJobById(j.ID)
+	if err != nil {
+		return err
+	}
+	j.LastRunAt = old.LastRunAt
+	if err := db.UpdateSyncJob(j); err != nil {
+		return err
+	}
+	// the states of other paths would make every obj look changed or deleted
+	if j.SrcPath != old.SrcPath || j.DstPath != old.DstPath || j.Mode != old.Mode {
+		if err := db.ReplaceSyncStates(j.ID, nil); err != nil {
+			return err
+		}
+	}
+	callSyncJobHooks(j, false)
+	return nil
+}
+
+func DeleteSyncJobById(id uint) error {
+	old, err := db.GetSyncJobById(id)
+	if err != nil {
+		return err
+	}
+	if err := db.DeleteSyncJobById(id); err != nil {
+		return err
+	}
+	callSyncJobHooks(old, true)
+	return nil
+}
+
+func GetSyncJobById(id uint) (*model.SyncJob, error) {
+	return db.GetSyncJobById(id)
+}
+
+func GetSyncJobs() ([]model.SyncJob, error) {
+	return db.GetSyncJobs()
+}
+
+func SetSyncJobLastRun(id uint, t time.Time) error {
+	return db.SetSyncJobLastRun(id, t)
+}
+
+func GetSyncStates(jobID uint) ([]model.SyncState, error) {
+	return db.GetSyncStates(jobID)
+}
+
+func ReplaceSyncStates(jobID uint, states []model.SyncState) error {
+	return db.ReplaceSyncStates(jobID, states)
+}
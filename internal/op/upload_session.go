@@ -0,0 +1,88 @@
+package op
+
+import (
+	"context"
+	stdpath "path"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+func uploadSessionDriver(storage driver.Driver) (driver.UploadSession, error) {
+	if storage.Config().CheckStatus && storage.GetStorage().Status != WORK {
+		return nil, errors.Errorf("storage not init: %s", storage.GetStorage().Status)
+	}
+	s, ok := storage.(driver.UploadSession)
+	if !ok {
+		return nil, errs.NotImplement
+	}
+	return s, nil
+}
+
+// CreateUploadSession starts the upload of the file at dstPath whose parts are sent by separate requests
+func CreateUploadSession(ctx context.Context, storage driver.Driver, dstPath string, size int64) (*model.UploadSession, error) {
+	s, err := uploadSessionDriver(storage)
+	if err != nil {
+		return nil, err
+	}
+	dstPath = utils.FixAndCleanPath(dstPath)
+	dstDirPath, name := stdpath.Split(dstPath)
+	err = MakeDir(ctx, storage, dstDirPath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to make dir [%s]", dstDirPath)
+	}
+	dstDir, err := GetUnwrap(ctx, storage, dstDirPath)
+	if err != nil {
+		return nil, errors.WithMessagef(err, "failed to get dir [%s]", dstDirPath)
+	}
+	session, err := s.CreateUploadSession(ctx, dstDir, name, size)
+	return session, errors.WithStack(err)
+}
+
+func GetUploadSession(ctx context.Context, storage driver.Driver, id string) (*model.UploadSession, error) {
+	s, err := uploadSessionDriver(storage)
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.GetUploadSession(ctx, id)
+	return session, errors.WithStack(err)
+}
+
+func PutUploadPart(ctx context.Context, storage driver.Driver, id string, index int, file model.FileStreamer, up driver.UpdateProgress) error {
+	defer func() {
+		if err := file.Close(); err != nil {
+			log.Errorf("failed to close file streamer, %v", err)
+		}
+	}()
+	s, err := uploadSessionDriver(storage)
+	if err != nil {
+		return err
+	}
+	if up == nil {
+		up = func(p float64) {}
+	}
+	start := time.Now()
+	err = s.PutUploadPart(ctx, id, index, file, up)
+	recordOp(storage, "put_part", start, &err)
+	return errors.WithStack(err)
+}
+
+// CompleteUploadSession creates the file at dstPath from the parts of the session
+func CompleteUploadSession(ctx context.Context, storage driver.Driver, dstPath, id string) error {
+	s, err := uploadSessionDriver(storage)
+	if err != nil {
+		return err
+	}
+	dstPath = utils.FixAndCleanPath(dstPath)
+	_, err = s.CompleteUploadSession(ctx, id)
+	if err == nil {
+		ClearCache(storage, stdpath.Dir(dstPath))
+		linkCache.Del(scopedKey(ctx, storage, dstPath))
+	}
+	return errors.WithStack(err)
+}
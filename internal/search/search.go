@@ -5,6 +5,7 @@ import (
 	"fmt"
 
 	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/driver"
 	"github.com/alist-org/alist/v3/internal/errs"
 	"github.com/alist-org/alist/v3/internal/model"
 	"github.com/alist-org/alist/v3/internal/op"
@@ -47,7 +48,17 @@ func Init(mode string) error {
 	return err
 }
 
+// Search searches by the driver if the parent is inside a storage that supports it,
+// otherwise by the search index
 func Search(ctx context.Context, req model.SearchReq) ([]model.SearchNode, int64, error) {
+	if storage, actualPath, err := op.GetStorageAndActualPath(req.Parent); err == nil {
+		if _, ok := storage.(driver.Search); ok {
+			return op.Search(ctx, storage, actualPath, req)
+		}
+	}
+	if instance == nil {
+		return nil, 0, errs.SearchNotAvailable
+	}
 	return instance.Search(ctx, req)
 }
 
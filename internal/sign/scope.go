@@ -0,0 +1,47 @@
+package sign
+
+import (
+	"encoding/base64"
+	"strings"
+
+	"github.com/alist-org/alist/v3/pkg/sign"
+)
+
+// A scoped sign carries the name of the user the link was signed for:
+// <base64 of the user name>.<sign of the data and the user name>.
+// The requests of a link carry no login, the storages showing different trees to different users
+// serve them in the tree of the user carried by the sign.
+const scopeSep = "."
+
+func scopedData(data, scope string) string {
+	return data + "\n" + scope
+}
+
+// Scoped signs data with signFunc for the user named scope, an empty scope is signFunc(data)
+func Scoped(data, scope string, signFunc func(string) string) string {
+	if scope == "" {
+		return signFunc(data)
+	}
+	return base64.RawURLEncoding.EncodeToString([]byte(scope)) + scopeSep + signFunc(scopedData(data, scope))
+}
+
+// IsScoped tells if s is a sign made by Scoped for a user
+func IsScoped(s string) bool {
+	return strings.Contains(s, scopeSep)
+}
+
+// VerifyScoped verifies s, a sign made by Scoped, with verifyFunc and returns the name of the user it carries
+func VerifyScoped(data, s string, verifyFunc func(string, string) error) (string, error) {
+	encoded, rest, ok := strings.Cut(s, scopeSep)
+	if !ok {
+		return "", verifyFunc(data, s)
+	}
+	scope, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(scope) == 0 {
+		return "", sign.ErrSignInvalid
+	}
+	if err := verifyFunc(scopedData(data, string(scope)), rest); err != nil {
+		return "", err
+	}
+	return string(scope), nil
+}
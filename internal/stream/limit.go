@@ -34,6 +34,27 @@ var (
 	ServerUploadLimit   Limiter
 )
 
+// BlockBurstLimiter waits for n tokens in steps of the burst size,
+// so a single read larger than the burst doesn't fail
+type BlockBurstLimiter struct {
+	*rate.Limiter
+}
+
+func (l BlockBurstLimiter) WaitN(ctx context.Context, total int) error {
+	for total > 0 {
+		n := l.Burst()
+		if l.Limiter.Limit() == rate.Inf || n > total {
+			n = total
+		}
+		err := l.Limiter.WaitN(ctx, n)
+		if err != nil {
+			return err
+		}
+		total -= n
+	}
+	return nil
+}
+
 type RateLimitReader struct {
 	io.Reader
 	Limiter Limiter
@@ -0,0 +1,22 @@
+package stream
+
+import (
+	"context"
+	"testing"
+
+	"golang.org/x/time/rate"
+)
+
+func TestBlockBurstLimiterWaitsBeyondBurst(t *testing.T) {
+	l := rate.NewLimiter(rate.Limit(1<<20), 1024)
+	if err := l.WaitN(context.Background(), 4096); err == nil {
+		t.Fatal("expect the plain limiter to refuse more than its burst")
+	}
+	if err := (BlockBurstLimiter{Limiter: l}).WaitN(context.Background(), 4096); err != nil {
+		t.Fatalf("expect the wait split into bursts, got %v", err)
+	}
+	inf := BlockBurstLimiter{Limiter: rate.NewLimiter(rate.Inf, 0)}
+	if err := inf.WaitN(context.Background(), 4096); err != nil {
+		t.Fatalf("expect no wait without a limit, got %v", err)
+	}
+}
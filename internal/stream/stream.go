@@ -25,9 +25,12 @@ type FileStream struct {
 	WebPutAsTask      bool
 	ForceStreamUpload bool
 	Exist             model.Obj //the file existed in the destination, we can reuse some info since we wil overwrite it
+	// TrustedHash tells the hashes of Obj come from a storage, not from the client uploading the file
+	TrustedHash bool
 	utils.Closers
-	tmpFile  *os.File //if present, tmpFile has full content, it will be deleted at last
-	peekBuff *bytes.Reader
+	tmpFile    *os.File //if present, tmpFile has full content, it will be deleted at last
+	peekBuff   *bytes.Reader
+	stagedHash utils.HashInfo
 }
 
 func (f *FileStream) GetSize() int64 {
@@ -48,6 +51,14 @@ func (f *FileStream) NeedStore() bool {
 	return f.WebPutAsTask
 }
 
+func (f *FileStream) HashTrusted() bool {
+	return f.TrustedHash
+}
+
+func (f *FileStream) StagedHash() utils.HashInfo {
+	return f.stagedHash
+}
+
 func (f *FileStream) IsForceStreamUpload() bool {
 	return f.ForceStreamUpload
 }
@@ -60,7 +71,7 @@ func (f *FileStream) Close() error {
 		err1 = nil
 	}
 	if f.tmpFile != nil {
-		err2 = os.RemoveAll(f.tmpFile.Name())
+		err2 = utils.RemoveTempFile(f.tmpFile)
 		if err2 != nil {
 			err2 = errs.NewErr(err2, "failed to remove tmpFile [%s]", f.tmpFile.Name())
 		} else {
@@ -97,6 +108,18 @@ func (f *FileStream) CacheFullInTempFile() (model.File, error) {
 	return tmpF, nil
 }
 
+// StageInTempFile caches all data into tmpFile like CacheFullInTempFile and computes the hashes of
+// the cached data in the same pass, so the driver can get them from StagedHash without reading the file again
+func (f *FileStream) StageInTempFile(types ...*utils.HashType) (model.File, error) {
+	hasher := utils.NewMultiHasher(types)
+	tmpF, err := CacheFullInTempFileAndWriter(f, hasher)
+	if err != nil {
+		return nil, err
+	}
+	f.stagedHash = *hasher.GetHashInfo()
+	return tmpF, nil
+}
+
 func (f *FileStream) GetFile() model.File {
 	if f.tmpFile != nil {
 		return f.tmpFile
@@ -0,0 +1,66 @@
+package chunkstore
+
+import (
+	"bufio"
+	"io"
+	"math/bits"
+)
+
+// gear is the table of the gear hash. It's generated from a fixed seed so that the same data
+// is always cut at the same offsets, which the dedup of chunks between files relies on.
+var gear = func() [256]uint64 {
+	var table [256]uint64
+	seed := uint64(0x9e3779b97f4a7c15)
+	for i := range table {
+		// splitmix64
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return table
+}()
+
+// NewCDCSizer creates a Sizer cutting the chunks where the rolling hash of the data matches,
+// so that the chunks of the data shared by two files are the same even if it's at different offsets.
+// The chunks are between min and max bytes, avg on average, except the last one.
+func NewCDCSizer(min, avg, max int64) *Sizer {
+	if max <= 0 {
+		max = avg
+	}
+	min = clamp(min, 1, max)
+	avg = clamp(avg, min+1, max)
+	n := bits.Len64(uint64(avg-min)) - 1
+	if n < 1 {
+		n = 1
+	}
+	return &Sizer{
+		size: max,
+		min:  min,
+		max:  max,
+		mask: (uint64(1)<<n - 1) << (64 - n),
+	}
+}
+
+// cdcCut returns the length of the chunk starting at start, at most remaining.
+// The first min bytes are skipped, the gear hash only depends on the last 64 bytes anyway.
+func (s *Sizer) cdcCut(r io.ReaderAt, start, remaining int64) (int64, error) {
+	if remaining <= s.min {
+		return remaining, nil
+	}
+	limit := min(remaining, s.max)
+	br := bufio.NewReaderSize(io.NewSectionReader(r, start+s.min, limit-s.min), 1024*1024)
+	var h uint64
+	for n := s.min; n < limit; n++ {
+		b, err := br.ReadByte()
+		if err != nil {
+			return 0, err
+		}
+		h = h<<1 + gear[b]
+		if h&s.mask == 0 {
+			return n + 1, nil
+		}
+	}
+	return limit, nil
+}
@@ -0,0 +1,50 @@
+// Package chunkstore stores files larger than the size cap of a storage as several chunks,
+// and reads them back as a single file with range requests across the chunks.
+// The storage of the chunks is abstracted by Backend, and the chunk table is kept by the driver.
+package chunkstore
+
+import (
+	"context"
+	"io"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+// Chunk is a part of a file stored as a single object in the backend
+type Chunk struct {
+	Index int
+	// Start is the offset of the first byte of the chunk in the file
+	Start int64
+	// End is the offset after the last byte of the chunk in the file
+	End int64
+	// Key identifies the chunk in the backend
+	Key string
+	// Hash is the hash of the data stored in the backend, set by Backend.Upload if it's computed
+	Hash string
+	// Nonce and KeyID are set when the chunk is encrypted, see NewEncryptedBackend
+	Nonce string
+	KeyID string
+}
+
+func (c Chunk) Size() int64 {
+	return c.End - c.Start
+}
+
+type Backend interface {
+	// NewChunk creates the object of the index-th chunk and returns its key,
+	// the object is reused when the upload of the chunk is retried
+	NewChunk(ctx context.Context, index int) (string, error)
+	// Upload writes size bytes of r as the data of the chunk into the object of chunk.Key,
+	// size differs from chunk.Size() if the data is transformed such as encrypted
+	Upload(ctx context.Context, chunk *Chunk, r io.Reader, size int64, up model.UpdateProgress) error
+	// Open reads length bytes of the chunk from offset, relative to the start of the chunk.
+	// refresh is true when retrying, anything cached for the chunk such as a download url should be dropped
+	Open(ctx context.Context, chunk Chunk, offset, length int64, refresh bool) (io.ReadCloser, error)
+}
+
+// Deduper is implemented by the backends that store identical chunks once
+type Deduper interface {
+	// Find returns the key of a stored object with the same data as the size bytes of r
+	// and the hash of the data, or an empty key if the chunk has to be uploaded
+	Find(ctx context.Context, r io.Reader, size int64) (key, hash string, err error)
+}
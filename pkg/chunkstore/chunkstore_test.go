@@ -0,0 +1,259 @@
+package chunkstore
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+)
+
+// memBackend keeps the chunks in memory
+type memBackend struct {
+	data map[string][]byte
+}
+
+func (b *memBackend) NewChunk(ctx context.Context, index int) (string, error) {
+	return fmt.Sprintf("chunk%d", index), nil
+}
+
+func (b *memBackend) Upload(ctx context.Context, chunk *Chunk, r io.Reader, size int64, up model.UpdateProgress) error {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+	if int64(len(data)) != size {
+		return fmt.Errorf("expect %d bytes, got %d", size, len(data))
+	}
+	b.data[chunk.Key] = data
+	return nil
+}
+
+func (b *memBackend) Open(ctx context.Context, chunk Chunk, offset, length int64, refresh bool) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(b.data[chunk.Key][offset : offset+length])), nil
+}
+
+func TestSplitAndRangeRead(t *testing.T) {
+	testSplitAndRangeRead(t, &memBackend{data: map[string][]byte{}}, 30)
+}
+
+func TestEncryptedSplitAndRangeRead(t *testing.T) {
+	b, err := NewEncryptedBackend(&memBackend{data: map[string][]byte{}}, ParseKey("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testSplitAndRangeRead(t, b, SegmentSize*2+100)
+}
+
+func testSplitAndRangeRead(t *testing.T, b Backend, chunkSize int64) {
+	content := strings.Repeat("0123456789", int(chunkSize*3/10+2))[:chunkSize*3+10]
+	size := int64(len(content))
+	chunks, err := Split(context.Background(), b, strings.NewReader(content), size, NewSizer(chunkSize, 0, false), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 4 || chunks[3].Size() != 10 {
+		t.Fatalf("unexpected chunks: %+v", chunks)
+	}
+	rrc := NewRangeReadCloser(b, chunks, size)
+	ranges := []http_range.Range{
+		{Start: 0, Length: -1},
+		{Start: chunkSize - 5, Length: 10},
+		{Start: chunkSize, Length: chunkSize},
+		{Start: size - 5, Length: 100},
+	}
+	for _, r := range ranges {
+		rc, err := rrc.RangeRead(context.Background(), r)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(rc)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := size
+		if r.Length >= 0 {
+			end = min(r.Start+r.Length, end)
+		}
+		if string(got) != content[r.Start:end] {
+			t.Errorf("range %+v: got %d bytes, want %d", r, len(got), end-r.Start)
+		}
+	}
+}
+
+func TestAppend(t *testing.T) {
+	conf.Conf = &conf.Config{TempDir: t.TempDir()}
+	tests := []struct {
+		name      string
+		initial   int
+		appended  int
+		wantFirst int
+		wantLen   int
+	}{
+		// the last chunk is 10 bytes, rewritten with 20 bytes appended
+		{name: "rewrite last chunk", initial: 40, appended: 35, wantFirst: 1, wantLen: 3},
+		// the last chunk is full, new chunks follow it
+		{name: "new chunks", initial: 60, appended: 35, wantFirst: 2, wantLen: 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := &memBackend{data: map[string][]byte{}}
+			content := strings.Repeat("0123456789", 10)[:tt.initial+tt.appended]
+			sizer := NewSizer(30, 0, false)
+			chunks, err := Split(context.Background(), b, strings.NewReader(content[:tt.initial]), int64(tt.initial), sizer, func(float64) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			appended, err := Append(context.Background(), b, chunks, strings.NewReader(content[tt.initial:]), int64(tt.appended), sizer, func(float64) {})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if appended[0].Index != tt.wantFirst {
+				t.Fatalf("first appended chunk is %d, want %d", appended[0].Index, tt.wantFirst)
+			}
+			chunks = append(chunks[:appended[0].Index], appended...)
+			if len(chunks) != tt.wantLen {
+				t.Fatalf("unexpected chunks: %+v", chunks)
+			}
+			rc, err := NewRangeReadCloser(b, chunks, int64(len(content))).RangeRead(context.Background(), http_range.Range{Length: -1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, err := io.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != content {
+				t.Errorf("got %q, want %q", got, content)
+			}
+		})
+	}
+}
+
+// dedupBackend finds the chunks uploaded before by the data
+type dedupBackend struct {
+	memBackend
+	created int
+}
+
+func (b *dedupBackend) NewChunk(ctx context.Context, index int) (string, error) {
+	b.created++
+	return b.memBackend.NewChunk(ctx, index)
+}
+
+func (b *dedupBackend) Find(ctx context.Context, r io.Reader, size int64) (string, string, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return "", "", err
+	}
+	for key, stored := range b.data {
+		if bytes.Equal(stored, data) {
+			return key, "", nil
+		}
+	}
+	return "", "", nil
+}
+
+func TestSplitDedup(t *testing.T) {
+	b := &dedupBackend{memBackend: memBackend{data: map[string][]byte{}}}
+	// the first two chunks have the same data
+	content := strings.Repeat("0123456789", 6) + "tail"
+	size := int64(len(content))
+	chunks, err := Split(context.Background(), b, strings.NewReader(content), size, NewSizer(30, 0, false), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(chunks) != 3 || b.created != 2 || chunks[0].Key != chunks[1].Key {
+		t.Fatalf("unexpected chunks: %+v, %d created", chunks, b.created)
+	}
+	rc, err := NewRangeReadCloser(b, chunks, size).RangeRead(context.Background(), http_range.Range{Length: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != content {
+		t.Errorf("got %q, want %q", got, content)
+	}
+}
+
+func TestCDCSplit(t *testing.T) {
+	data := make([]byte, 512*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	b := &dedupBackend{memBackend: memBackend{data: map[string][]byte{}}}
+	newSizer := func() *Sizer { return NewCDCSizer(2*1024, 8*1024, 32*1024) }
+	chunks, err := Split(context.Background(), b, bytes.NewReader(data), int64(len(data)), newSizer(), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range chunks[:len(chunks)-1] {
+		if c.Size() < 2*1024 || c.Size() > 32*1024 {
+			t.Fatalf("chunk %d has %d bytes", c.Index, c.Size())
+		}
+	}
+	// the data after an insertion at the head is cut at the same offsets, so most chunks are found
+	created := b.created
+	shifted := append([]byte("inserted at the head"), data...)
+	if _, err := Split(context.Background(), b, bytes.NewReader(shifted), int64(len(shifted)), newSizer(), func(float64) {}); err != nil {
+		t.Fatal(err)
+	}
+	if added := b.created - created; added > 2 {
+		t.Errorf("%d of %d chunks uploaded again after the insertion", added, len(chunks))
+	}
+}
+
+func TestParityReconstruct(t *testing.T) {
+	data := make([]byte, 1000)
+	rand.New(rand.NewSource(2)).Read(data)
+	b := &memBackend{data: map[string][]byte{}}
+	pb := &memBackend{data: map[string][]byte{}}
+	chunks, err := Split(context.Background(), b, bytes.NewReader(data), int64(len(data)), NewSizer(150, 0, false), func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 7 chunks in groups of 3, the last group has one chunk of 100 bytes
+	p := Parity{Data: 3, Shards: 2}
+	parity, err := p.Encode(context.Background(), pb, bytes.NewReader(data), chunks, func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(parity) != 6 || parity[5].Size() != 100 {
+		t.Fatalf("unexpected parity: %+v", parity)
+	}
+	lost := []int{0, 2, 6}
+	for _, i := range lost {
+		delete(b.data, chunks[i].Key)
+	}
+	rebuilt, err := p.Reconstruct(context.Background(), b, pb, chunks, parity, lost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rebuilt) != len(lost) {
+		t.Fatalf("%d chunks rebuilt, want %d", len(rebuilt), len(lost))
+	}
+	for _, c := range rebuilt {
+		chunks[c.Index] = c
+	}
+	rc, err := NewRangeReadCloser(b, chunks, int64(len(data))).RangeRead(context.Background(), http_range.Range{Length: -1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := io.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Error("rebuilt data differs")
+	}
+	if _, err := p.Reconstruct(context.Background(), b, pb, chunks, parity, []int{0, 1, 2}); err == nil {
+		t.Error("expect an error when more chunks of a group are lost than the parity shards")
+	}
+}
@@ -0,0 +1,210 @@
+package chunkstore
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+const (
+	// SegmentSize is the size of the plaintext sealed at once, a range read decrypts whole segments
+	SegmentSize = 64 * 1024
+	// SegmentOverhead is the size of the GCM tag appended to every segment
+	SegmentOverhead = 16
+	// noncePrefixSize is the random part of the nonce, the rest is the index of the segment
+	noncePrefixSize = 8
+)
+
+// EncryptedSize returns the size of a chunk of size bytes after encrypted
+func EncryptedSize(size int64) int64 {
+	segments := (size + SegmentSize - 1) / SegmentSize
+	return size + segments*SegmentOverhead
+}
+
+// ParseKey parses a 256-bit key in hex, any other string is taken as a passphrase and hashed into a key
+func ParseKey(s string) []byte {
+	if key, err := hex.DecodeString(s); err == nil && len(key) == 32 {
+		return key
+	}
+	key := sha256.Sum256([]byte(s))
+	return key[:]
+}
+
+type encryptedBackend struct {
+	Backend
+	aead  cipher.AEAD
+	keyID string
+}
+
+// NewEncryptedBackend encrypts the chunks with AES-256-GCM before they are uploaded to b.
+// A chunk is sealed in segments of SegmentSize so that it can be read by range,
+// the nonce of a segment is a random prefix saved in Chunk.Nonce followed by the index of the segment.
+// Chunks without Nonce, uploaded before the encryption is enabled, are read as they are.
+func NewEncryptedBackend(b Backend, key []byte) (Backend, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("invalid key size %d, AES-256 needs 32 bytes", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+	return &encryptedBackend{
+		Backend: b,
+		aead:    aead,
+		keyID:   KeyID(key),
+	}, nil
+}
+
+// KeyID identifies the key without revealing it
+func KeyID(key []byte) string {
+	sum := sha256.Sum256(append([]byte("chunkstore key id:"), key...))
+	return hex.EncodeToString(sum[:8])
+}
+
+func (b *encryptedBackend) Upload(ctx context.Context, chunk *Chunk, r io.Reader, size int64, up model.UpdateProgress) error {
+	// a new nonce for every attempt, the data of a retry may differ
+	prefix := make([]byte, noncePrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return err
+	}
+	chunk.Nonce = hex.EncodeToString(prefix)
+	chunk.KeyID = b.keyID
+	return b.Backend.Upload(ctx, chunk, &encryptReader{
+		r:      r,
+		aead:   b.aead,
+		prefix: prefix,
+		plain:  make([]byte, SegmentSize),
+		sealed: make([]byte, 0, SegmentSize+SegmentOverhead),
+	}, EncryptedSize(size), up)
+}
+
+func (b *encryptedBackend) Open(ctx context.Context, chunk Chunk, offset, length int64, refresh bool) (io.ReadCloser, error) {
+	if chunk.Nonce == "" {
+		return b.Backend.Open(ctx, chunk, offset, length, refresh)
+	}
+	if chunk.KeyID != b.keyID {
+		return nil, fmt.Errorf("chunk %d is encrypted with another key", chunk.Index)
+	}
+	prefix, err := hex.DecodeString(chunk.Nonce)
+	if err != nil || len(prefix) != noncePrefixSize {
+		return nil, fmt.Errorf("invalid nonce of chunk %d", chunk.Index)
+	}
+	size := chunk.Size()
+	if length < 0 || offset+length > size {
+		length = size - offset
+	}
+	first := offset / SegmentSize
+	last := (offset + length + SegmentSize - 1) / SegmentSize
+	start := first * (SegmentSize + SegmentOverhead)
+	end := min(last*(SegmentSize+SegmentOverhead), EncryptedSize(size))
+	rc, err := b.Backend.Open(ctx, chunk, start, end-start, refresh)
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		rc:        rc,
+		aead:      b.aead,
+		prefix:    prefix,
+		segment:   uint32(first),
+		sealed:    make([]byte, SegmentSize+SegmentOverhead),
+		skip:      offset - first*SegmentSize,
+		remaining: length,
+	}, nil
+}
+
+func segmentNonce(prefix []byte, segment uint32) []byte {
+	nonce := make([]byte, noncePrefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[noncePrefixSize:], segment)
+	return nonce
+}
+
+type encryptReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	segment uint32
+	plain   []byte
+	sealed  []byte
+	buf     []byte
+	eof     bool
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for len(e.buf) == 0 {
+		if e.eof {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(e.r, e.plain)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			e.eof = true
+		} else if err != nil {
+			return 0, err
+		}
+		if n == 0 {
+			continue
+		}
+		e.buf = e.aead.Seal(e.sealed[:0], segmentNonce(e.prefix, e.segment), e.plain[:n], nil)
+		e.segment++
+	}
+	n := copy(p, e.buf)
+	e.buf = e.buf[n:]
+	return n, nil
+}
+
+type decryptReader struct {
+	rc        io.ReadCloser
+	aead      cipher.AEAD
+	prefix    []byte
+	segment   uint32
+	sealed    []byte
+	buf       []byte
+	skip      int64
+	remaining int64
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.remaining <= 0 {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(d.rc, d.sealed)
+		if err == io.EOF {
+			return 0, io.ErrUnexpectedEOF
+		}
+		if err != nil && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		plain, err := d.aead.Open(d.sealed[:0], segmentNonce(d.prefix, d.segment), d.sealed[:n], nil)
+		if err != nil {
+			return 0, fmt.Errorf("failed to decrypt segment %d: %w", d.segment, err)
+		}
+		d.segment++
+		if d.skip > 0 {
+			skip := min(d.skip, int64(len(plain)))
+			plain = plain[skip:]
+			d.skip -= skip
+		}
+		d.buf = plain[:min(int64(len(plain)), d.remaining)]
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	d.remaining -= int64(n)
+	return n, nil
+}
+
+func (d *decryptReader) Close() error {
+	return d.rc.Close()
+}
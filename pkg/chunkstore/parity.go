@@ -0,0 +1,245 @@
+package chunkstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/klauspost/reedsolomon"
+)
+
+// Parity describes the Reed-Solomon parity of the chunks of a file.
+// Every Data chunks in order form a group protected by Shards parity chunks,
+// so that any Shards chunks of a group can be rebuilt from the rest.
+// The chunks of a group are padded with zeros to the size of the largest one,
+// and a partial last group is filled with empty chunks.
+type Parity struct {
+	Data   int
+	Shards int
+}
+
+func (p Parity) groups(chunks int) int {
+	return (chunks + p.Data - 1) / p.Data
+}
+
+func (p Parity) encoder() (reedsolomon.StreamEncoder, error) {
+	if p.Data <= 0 || p.Shards <= 0 {
+		return nil, fmt.Errorf("invalid parity %d+%d", p.Data, p.Shards)
+	}
+	return reedsolomon.NewStream(p.Data, p.Shards)
+}
+
+// group returns the chunks of the g-th group and the size of its shards
+func (p Parity) group(chunks []Chunk, g int) ([]Chunk, int64) {
+	group := chunks[g*p.Data : min((g+1)*p.Data, len(chunks))]
+	var size int64
+	for _, c := range group {
+		size = max(size, c.Size())
+	}
+	return group, size
+}
+
+// Encode computes the parity of the chunks read from r, which starts at the first chunk, and uploads it to b,
+// the parity chunks of the g-th group have the indexes from g*Shards,
+// and their End is the size of the shards of the group.
+func (p Parity) Encode(ctx context.Context, b Backend, r io.ReaderAt, chunks []Chunk, up model.UpdateProgress) ([]Chunk, error) {
+	enc, err := p.encoder()
+	if err != nil {
+		return nil, err
+	}
+	groups := p.groups(len(chunks))
+	parity := make([]Chunk, 0, groups*p.Shards)
+	for g := 0; g < groups; g++ {
+		if utils.IsCanceled(ctx) {
+			return nil, ctx.Err()
+		}
+		group, size := p.group(chunks, g)
+		data := make([]io.Reader, p.Data)
+		for i := range data {
+			if i < len(group) {
+				data[i] = padded(io.NewSectionReader(r, group[i].Start-chunks[0].Start, group[i].Size()), group[i].Size(), size)
+			} else {
+				data[i] = padded(nil, 0, size)
+			}
+		}
+		chunksOfGroup, err := p.encodeGroup(ctx, enc, b, g, data, size)
+		if err != nil {
+			return nil, err
+		}
+		parity = append(parity, chunksOfGroup...)
+		up(float64(g+1) / float64(groups) * 100.0)
+	}
+	return parity, nil
+}
+
+func (p Parity) encodeGroup(ctx context.Context, enc reedsolomon.StreamEncoder, b Backend, g int, data []io.Reader, size int64) ([]Chunk, error) {
+	files := make([]*os.File, p.Shards)
+	writers := make([]io.Writer, p.Shards)
+	defer func() {
+		for _, f := range files {
+			if f != nil {
+				utils.RemoveTempFile(f)
+			}
+		}
+	}()
+	for i := range files {
+		f, err := os.CreateTemp("", "parity-*")
+		if err != nil {
+			return nil, err
+		}
+		files[i], writers[i] = f, f
+	}
+	if err := enc.Encode(data, writers); err != nil {
+		return nil, fmt.Errorf("failed to encode parity of group %d: %w", g, err)
+	}
+	chunks := make([]Chunk, p.Shards)
+	for i, f := range files {
+		index := g*p.Shards + i
+		chunk, err := uploadShard(ctx, b, index, io.NewSectionReader(f, 0, size), size)
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload parity chunk %d: %w", index, err)
+		}
+		chunks[i] = chunk
+	}
+	return chunks, nil
+}
+
+// Reconstruct rebuilds the chunks with the indexes in lost from the other chunks of their groups
+// read from b and the parity read from pb, and uploads them to b as new objects.
+// It returns the rebuilt chunks, which replace the chunks of the same indexes.
+func (p Parity) Reconstruct(ctx context.Context, b, pb Backend, chunks, parity []Chunk, lost []int) ([]Chunk, error) {
+	enc, err := p.encoder()
+	if err != nil {
+		return nil, err
+	}
+	if len(parity) != p.groups(len(chunks))*p.Shards {
+		return nil, fmt.Errorf("expect %d parity chunks, got %d", p.groups(len(chunks))*p.Shards, len(parity))
+	}
+	base := 0
+	if len(chunks) > 0 {
+		base = chunks[0].Index
+	}
+	byGroup := make(map[int][]int)
+	for _, index := range lost {
+		i := index - base
+		if i < 0 || i >= len(chunks) {
+			return nil, fmt.Errorf("chunk %d doesn't exist", index)
+		}
+		byGroup[i/p.Data] = append(byGroup[i/p.Data], i)
+	}
+	var rebuilt []Chunk
+	for g, missing := range byGroup {
+		if len(missing) > p.Shards {
+			return nil, fmt.Errorf("%d chunks of group %d are lost, at most %d can be rebuilt", len(missing), g, p.Shards)
+		}
+		chunksOfGroup, err := p.reconstructGroup(ctx, enc, b, pb, chunks, parity, g, missing)
+		if err != nil {
+			return nil, err
+		}
+		rebuilt = append(rebuilt, chunksOfGroup...)
+	}
+	return rebuilt, nil
+}
+
+func (p Parity) reconstructGroup(ctx context.Context, enc reedsolomon.StreamEncoder, b, pb Backend,
+	chunks, parity []Chunk, g int, missing []int) ([]Chunk, error) {
+	group, size := p.group(chunks, g)
+	valid := make([]io.Reader, p.Data+p.Shards)
+	fill := make([]io.Writer, p.Data+p.Shards)
+	files := make(map[int]*os.File, len(missing))
+	defer func() {
+		for _, f := range files {
+			utils.RemoveTempFile(f)
+		}
+	}()
+	for _, i := range missing {
+		f, err := os.CreateTemp("", "rebuild-*")
+		if err != nil {
+			return nil, err
+		}
+		files[i] = f
+		fill[i-g*p.Data] = f
+	}
+	var closers []io.Closer
+	defer func() {
+		for _, c := range closers {
+			_ = c.Close()
+		}
+	}()
+	for i := range valid {
+		if fill[i] != nil {
+			continue
+		}
+		if i >= p.Data {
+			chunk := parity[g*p.Shards+i-p.Data]
+			rc, err := pb.Open(ctx, chunk, 0, chunk.Size(), false)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read parity chunk %d: %w", chunk.Index, err)
+			}
+			closers = append(closers, rc)
+			valid[i] = rc
+			continue
+		}
+		if i >= len(group) {
+			valid[i] = padded(nil, 0, size)
+			continue
+		}
+		rc, err := b.Open(ctx, group[i], 0, group[i].Size(), false)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read chunk %d: %w", group[i].Index, err)
+		}
+		closers = append(closers, rc)
+		valid[i] = padded(rc, group[i].Size(), size)
+	}
+	if err := enc.Reconstruct(valid, fill); err != nil {
+		return nil, fmt.Errorf("failed to reconstruct group %d: %w", g, err)
+	}
+	rebuilt := make([]Chunk, 0, len(missing))
+	for _, i := range missing {
+		old := chunks[i]
+		chunk, err := uploadShard(ctx, b, old.Index, io.NewSectionReader(files[i], 0, old.Size()), old.Size())
+		if err != nil {
+			return nil, fmt.Errorf("failed to upload rebuilt chunk %d: %w", old.Index, err)
+		}
+		chunk.Start, chunk.End = old.Start, old.End
+		rebuilt = append(rebuilt, chunk)
+	}
+	return rebuilt, nil
+}
+
+// uploadShard uploads size bytes of r as a new object of the index-th chunk, retried as Split does
+func uploadShard(ctx context.Context, b Backend, index int, r io.ReadSeeker, size int64) (Chunk, error) {
+	key, err := b.NewChunk(ctx, index)
+	if err != nil {
+		return Chunk{}, err
+	}
+	chunk := Chunk{Index: index, End: size, Key: key}
+	for retry := 0; ; retry++ {
+		if _, err = r.Seek(0, io.SeekStart); err != nil {
+			return Chunk{}, err
+		}
+		err = b.Upload(ctx, &chunk, r, size, func(float64) {})
+		if err == nil || retry+1 >= UploadRetries || utils.IsCanceled(ctx) {
+			return chunk, err
+		}
+	}
+}
+
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+// padded reads length bytes of r followed by zeros up to size, r may be nil if length is 0
+func padded(r io.Reader, length, size int64) io.Reader {
+	pad := io.LimitReader(zeros{}, size-length)
+	if r == nil {
+		return pad
+	}
+	return io.MultiReader(r, pad)
+}
@@ -0,0 +1,147 @@
+package chunkstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/http_range"
+	"github.com/alist-org/alist/v3/pkg/utils"
+)
+
+// OpenRetries is the max times to open a chunk when reading
+const OpenRetries = 3
+
+// RangeReadCloser reads the file stored as chunks
+type RangeReadCloser struct {
+	backend Backend
+	chunks  []Chunk
+	size    int64
+	utils.Closers
+}
+
+var _ model.RangeReadCloserIF = (*RangeReadCloser)(nil)
+
+// NewRangeReadCloser creates a RangeReadCloser of the file of size bytes, the chunks must be sorted by Index
+func NewRangeReadCloser(backend Backend, chunks []Chunk, size int64) *RangeReadCloser {
+	return &RangeReadCloser{
+		backend: backend,
+		chunks:  chunks,
+		size:    size,
+		Closers: utils.EmptyClosers(),
+	}
+}
+
+func (c *RangeReadCloser) RangeRead(ctx context.Context, httpRange http_range.Range) (io.ReadCloser, error) {
+	if httpRange.Start < 0 {
+		return nil, fmt.Errorf("invalid range start: %d", httpRange.Start)
+	}
+	if httpRange.Start >= c.size {
+		return nil, fmt.Errorf("range start %d exceeds file size %d", httpRange.Start, c.size)
+	}
+	end := c.size
+	if httpRange.Length >= 0 {
+		end = min(httpRange.Start+httpRange.Length, c.size)
+	}
+	chunks := Locate(c.chunks, httpRange.Start, end)
+	if len(chunks) == 0 {
+		return nil, fmt.Errorf("no chunks found for range %d-%d (file size: %d, total chunks: %d)",
+			httpRange.Start, end-1, c.size, len(c.chunks))
+	}
+	return &reader{
+		ctx:     ctx,
+		backend: c.backend,
+		chunks:  chunks,
+		start:   httpRange.Start,
+		end:     end,
+	}, nil
+}
+
+// Locate returns the chunks overlapping with [start, end)
+func Locate(chunks []Chunk, start, end int64) []Chunk {
+	var res []Chunk
+	for _, chunk := range chunks {
+		if chunk.Start < end && chunk.End > start {
+			res = append(res, chunk)
+		}
+	}
+	return res
+}
+
+// reader reads [start, end) of the file across the chunks
+type reader struct {
+	ctx     context.Context
+	backend Backend
+	chunks  []Chunk
+	start   int64
+	end     int64
+	current int
+	rc      io.ReadCloser
+	read    int64
+}
+
+func (r *reader) Read(p []byte) (n int, err error) {
+	remaining := r.end - r.start - r.read
+	if remaining <= 0 {
+		return 0, io.EOF
+	}
+	if r.rc == nil {
+		if r.current >= len(r.chunks) {
+			return 0, io.EOF
+		}
+		if r.rc, err = r.open(r.chunks[r.current]); err != nil {
+			return 0, err
+		}
+	}
+	if int64(len(p)) > remaining {
+		p = p[:remaining]
+	}
+	n, err = r.rc.Read(p)
+	r.read += int64(n)
+	if err != nil {
+		// the chunk will be opened again from the current offset on the next read if it's not EOF
+		_ = r.rc.Close()
+		r.rc = nil
+		if err == io.EOF {
+			if r.start+r.read < min(r.end, r.chunks[r.current].End) {
+				// the chunk ended early, it's not the end of the file
+				return n, io.ErrUnexpectedEOF
+			}
+			r.current++
+			if r.read < r.end-r.start && r.current < len(r.chunks) {
+				err = nil
+			}
+		}
+	}
+	return n, err
+}
+
+// open opens the rest of chunk to read, retrying with increasing delay
+func (r *reader) open(chunk Chunk) (io.ReadCloser, error) {
+	offset := max(r.start+r.read, chunk.Start) - chunk.Start
+	length := min(r.end, chunk.End) - chunk.Start - offset
+	var err error
+	for retry := 0; retry < OpenRetries; retry++ {
+		if retry > 0 {
+			time.Sleep(time.Second * time.Duration(retry))
+		}
+		var rc io.ReadCloser
+		rc, err = r.backend.Open(r.ctx, chunk, offset, length, retry > 0)
+		if err == nil {
+			return rc, nil
+		}
+		if utils.IsCanceled(r.ctx) {
+			return nil, r.ctx.Err()
+		}
+	}
+	return nil, fmt.Errorf("failed to open chunk %d after %d tries: %w", chunk.Index, OpenRetries, err)
+}
+
+func (r *reader) Close() error {
+	if r.rc != nil {
+		return r.rc.Close()
+	}
+	return nil
+}
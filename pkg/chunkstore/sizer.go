@@ -0,0 +1,76 @@
+package chunkstore
+
+import (
+	"io"
+	"time"
+)
+
+const (
+	// AdaptiveInitialSize is the size of the first chunk when the size is adaptive
+	AdaptiveInitialSize = 1024 * 1024 * 1024 // 1GB
+	// AdaptiveTargetDuration is the expected upload duration of a single chunk when the size is adaptive
+	AdaptiveTargetDuration = 10 * time.Minute
+)
+
+// Sizer decides the size of the next chunk.
+// When adaptive, the size grows with the measured throughput and shrinks after a failure,
+// so that a retry costs less. A Sizer made by NewCDCSizer cuts the chunks by their content instead.
+type Sizer struct {
+	adaptive bool
+	size     int64
+	min      int64
+	max      int64
+	// mask selects the bits of the rolling hash that are zero at a cut, 0 if not content defined
+	mask uint64
+}
+
+// NewSizer creates a Sizer, the chunks are always max bytes if not adaptive
+func NewSizer(max, min int64, adaptive bool) *Sizer {
+	s := &Sizer{
+		adaptive: adaptive,
+		size:     max,
+		min:      min,
+		max:      max,
+	}
+	if s.min <= 0 || s.min > s.max {
+		s.min = s.max
+	}
+	if adaptive {
+		s.size = clamp(AdaptiveInitialSize, s.min, s.max)
+	}
+	return s
+}
+
+// Next returns the size of the next chunk, the max size if it's content defined
+func (s *Sizer) Next() int64 {
+	return s.size
+}
+
+// Cut returns the length of the chunk of r starting at start, at most remaining
+func (s *Sizer) Cut(r io.ReaderAt, start, remaining int64) (int64, error) {
+	if s.mask != 0 {
+		return s.cdcCut(r, start, remaining)
+	}
+	return min(s.size, remaining), nil
+}
+
+// OnSuccess adjusts the size to the throughput of the chunk uploaded, at most doubled each time
+func (s *Sizer) OnSuccess(size int64, elapsed time.Duration) {
+	if !s.adaptive || elapsed <= 0 {
+		return
+	}
+	ideal := int64(float64(size) / elapsed.Seconds() * AdaptiveTargetDuration.Seconds())
+	s.size = clamp(ideal, s.min, min(s.size*2, s.max))
+}
+
+// OnFailure halves the size
+func (s *Sizer) OnFailure() {
+	if !s.adaptive {
+		return
+	}
+	s.size = clamp(s.size/2, s.min, s.max)
+}
+
+func clamp(v, lo, hi int64) int64 {
+	return max(lo, min(v, hi))
+}
@@ -0,0 +1,117 @@
+package chunkstore
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+func TestSizerFixed(t *testing.T) {
+	s := NewSizer(100, 10, false)
+	s.OnSuccess(100, time.Hour)
+	s.OnFailure()
+	if got := s.Next(); got != 100 {
+		t.Fatalf("expect the fixed size 100, got %d", got)
+	}
+}
+
+func TestSizerAdaptive(t *testing.T) {
+	const mb = 1024 * 1024
+	s := NewSizer(4096*mb, 256*mb, true)
+	if got := s.Next(); got != AdaptiveInitialSize {
+		t.Fatalf("expect the initial size %d, got %d", AdaptiveInitialSize, got)
+	}
+	// fast uploads grow the size, at most doubled each time and never over the max
+	s.OnSuccess(AdaptiveInitialSize, time.Second)
+	if got := s.Next(); got != 2*AdaptiveInitialSize {
+		t.Fatalf("expect the size doubled, got %d", got)
+	}
+	s.OnSuccess(s.Next(), time.Second)
+	s.OnSuccess(s.Next(), time.Second)
+	if got := s.Next(); got != 4096*mb {
+		t.Fatalf("expect the max size, got %d", got)
+	}
+	// a slow upload shrinks the size to what's uploaded in the target duration
+	s.OnSuccess(512*mb, 2*AdaptiveTargetDuration)
+	if got := s.Next(); got != 256*mb {
+		t.Fatalf("expect 256MB, got %d", got)
+	}
+	// failures halve the size, never under the min
+	s = NewSizer(4096*mb, 256*mb, true)
+	s.OnFailure()
+	if got := s.Next(); got != 512*mb {
+		t.Fatalf("expect the size halved, got %d", got)
+	}
+	s.OnFailure()
+	s.OnFailure()
+	if got := s.Next(); got != 256*mb {
+		t.Fatalf("expect the min size, got %d", got)
+	}
+}
+
+// flakyBackend fails the first upload of each chunk
+type flakyBackend struct {
+	memBackend
+	sizes  []int64
+	failed map[int]bool
+}
+
+func (b *flakyBackend) Upload(ctx context.Context, chunk *Chunk, r io.Reader, size int64, up model.UpdateProgress) error {
+	b.sizes = append(b.sizes, size)
+	if !b.failed[chunk.Index] {
+		b.failed[chunk.Index] = true
+		return errors.New("upload failed")
+	}
+	return b.memBackend.Upload(ctx, chunk, r, size, up)
+}
+
+func TestSplitRetriesSmallerChunk(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	b := &flakyBackend{memBackend: memBackend{data: map[string][]byte{}}, failed: map[int]bool{}}
+	sizer := NewSizer(1000, 100, true)
+	chunks, err := Split(context.Background(), b, bytes.NewReader(data), int64(len(data)), sizer, func(float64) {})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the failed chunk is retried with half the size
+	if len(b.sizes) < 2 || b.sizes[0] != 1000 || b.sizes[1] != 500 {
+		t.Fatalf("expect a retry with 500 bytes, got %v", b.sizes)
+	}
+	var end int64
+	for _, c := range chunks {
+		if c.Start != end {
+			t.Fatalf("expect chunk %d to start at %d, got %d", c.Index, end, c.Start)
+		}
+		end = c.End
+	}
+	if end != int64(len(data)) {
+		t.Fatalf("expect the chunks to cover %d bytes, got %d", len(data), end)
+	}
+}
+
+func TestSplitGivesUpAfterRetries(t *testing.T) {
+	b := &failingBackend{}
+	_, err := Split(context.Background(), b, bytes.NewReader(make([]byte, 10)), 10, NewSizer(10, 0, false), func(float64) {})
+	if err == nil || b.tries != UploadRetries {
+		t.Fatalf("expect failing after %d tries, got %d tries: %v", UploadRetries, b.tries, err)
+	}
+}
+
+// failingBackend fails every upload
+type failingBackend struct {
+	memBackend
+	tries int
+}
+
+func (b *failingBackend) Upload(ctx context.Context, chunk *Chunk, r io.Reader, size int64, up model.UpdateProgress) error {
+	b.tries++
+	return errors.New("upload failed")
+}
@@ -0,0 +1,110 @@
+package chunkstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/pkg/errors"
+)
+
+// UploadRetries is the max times to upload a single chunk
+const UploadRetries = 3
+
+// Split uploads size bytes of r as chunks with the sizes decided by sizer.
+// A failed chunk is retried, with a smaller size if sizer is adaptive.
+func Split(ctx context.Context, b Backend, r io.ReaderAt, size int64, sizer *Sizer, up model.UpdateProgress) ([]Chunk, error) {
+	return split(ctx, b, r, 0, 0, size, sizer, up)
+}
+
+// split uploads size bytes of r as the chunks from the index first,
+// the offsets of the chunks in the file start from base.
+// If b is a Deduper, a chunk whose data is already stored refers to the stored object instead.
+func split(ctx context.Context, b Backend, r io.ReaderAt, first int, base, size int64, sizer *Sizer, up model.UpdateProgress) ([]Chunk, error) {
+	var chunks []Chunk
+	deduper, _ := b.(Deduper)
+	for i, start := first, int64(0); start < size; i++ {
+		if deduper != nil {
+			length, err := sizer.Cut(r, start, size-start)
+			if err != nil {
+				return nil, fmt.Errorf("failed to cut chunk %d: %w", i, err)
+			}
+			end := start + length
+			key, hash, err := deduper.Find(ctx, io.NewSectionReader(r, start, end-start), end-start)
+			if err != nil {
+				return nil, fmt.Errorf("failed to find chunk %d: %w", i, err)
+			}
+			if key != "" {
+				chunks = append(chunks, Chunk{Index: i, Start: base + start, End: base + end, Key: key, Hash: hash})
+				up(float64(end) / float64(size) * 100.0)
+				start = end
+				continue
+			}
+		}
+		key, err := b.NewChunk(ctx, i)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create chunk %d: %w", i, err)
+		}
+		chunk := Chunk{
+			Index: i,
+			Start: base + start,
+			Key:   key,
+		}
+		for retry := 0; ; retry++ {
+			if utils.IsCanceled(ctx) {
+				return nil, ctx.Err()
+			}
+			length, err := sizer.Cut(r, start, size-start)
+			if err != nil {
+				return nil, fmt.Errorf("failed to cut chunk %d: %w", i, err)
+			}
+			end := start + length
+			chunk.End = base + end
+			begin := time.Now()
+			err = b.Upload(ctx, &chunk, io.NewSectionReader(r, start, end-start), chunk.Size(), func(percentage float64) {
+				up((float64(start) + percentage/100.0*float64(chunk.Size())) / float64(size) * 100.0)
+			})
+			if err == nil {
+				sizer.OnSuccess(chunk.Size(), time.Since(begin))
+				break
+			}
+			if retry+1 >= UploadRetries || errors.Is(err, context.Canceled) {
+				return nil, fmt.Errorf("failed to upload chunk %d after %d tries: %w", i, retry+1, err)
+			}
+			sizer.OnFailure()
+			time.Sleep(time.Second * time.Duration(retry+1))
+		}
+		chunks = append(chunks, chunk)
+		start = chunk.End - base
+	}
+	return chunks, nil
+}
+
+// Append uploads size bytes of r after the end of a file stored as chunks.
+// If the last chunk is smaller than the next size of sizer, it's rewritten as a new object
+// with the head of r appended, so that appending small pieces doesn't leave many tiny chunks.
+// The returned chunks replace the chunks from the index of the first returned one,
+// which is the index of the last chunk if it's rewritten.
+func Append(ctx context.Context, b Backend, chunks []Chunk, r io.ReaderAt, size int64, sizer *Sizer, up model.UpdateProgress) ([]Chunk, error) {
+	if len(chunks) == 0 {
+		return Split(ctx, b, r, size, sizer, up)
+	}
+	last := chunks[len(chunks)-1]
+	if last.Size() >= sizer.Next() {
+		return split(ctx, b, r, last.Index+1, last.End, size, sizer, up)
+	}
+	rc, err := b.Open(ctx, last, 0, last.Size(), false)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read chunk %d: %w", last.Index, err)
+	}
+	defer rc.Close()
+	tmp, err := utils.CreateTempFile(io.MultiReader(rc, io.NewSectionReader(r, 0, size)), last.Size()+size)
+	if err != nil {
+		return nil, fmt.Errorf("failed to cache chunk %d: %w", last.Index, err)
+	}
+	defer utils.RemoveTempFile(tmp)
+	return split(ctx, b, tmp, last.Index, last.Start, last.Size()+size, sizer, up)
+}
@@ -117,22 +117,26 @@ func CreateTempFile(r io.Reader, size int64) (*os.File, error) {
 	if f, ok := r.(*os.File); ok {
 		return f, nil
 	}
-	f, err := os.CreateTemp(conf.Conf.TempDir, "file-*")
+	f, err := os.CreateTemp(conf.Conf.TempDir, tempFilePrefix+"*")
 	if err != nil {
 		return nil, err
 	}
+	trackTempFile(f.Name(), size)
 	readBytes, err := CopyWithBuffer(f, r)
 	if err != nil {
-		_ = os.Remove(f.Name())
+		_ = RemoveTempFile(f)
 		return nil, errs.NewErr(err, "CreateTempFile failed")
 	}
 	if size > 0 && readBytes != size {
-		_ = os.Remove(f.Name())
+		_ = RemoveTempFile(f)
 		return nil, errs.NewErr(err, "CreateTempFile failed, incoming stream actual size= %d, expect = %d ", readBytes, size)
 	}
+	if size <= 0 {
+		trackTempFile(f.Name(), readBytes)
+	}
 	_, err = f.Seek(0, io.SeekStart)
 	if err != nil {
-		_ = os.Remove(f.Name())
+		_ = RemoveTempFile(f)
 		return nil, errs.NewErr(err, "CreateTempFile failed, can't seek to 0 ")
 	}
 	return f, nil
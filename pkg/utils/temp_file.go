@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	log "github.com/sirupsen/logrus"
+)
+
+// tempFilePrefix is the name prefix of the staging files created by CreateTempFile
+const tempFilePrefix = "file-"
+
+// tempFiles tracks the staging files that are still owned by a stream,
+// so that the usage of the temp dir can be reported and orphans can be told apart
+var tempFiles = struct {
+	sync.Mutex
+	m map[string]int64
+}{m: make(map[string]int64)}
+
+func trackTempFile(name string, size int64) {
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	tempFiles.m[name] = size
+}
+
+func untrackTempFile(name string) {
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	delete(tempFiles.m, name)
+}
+
+// RemoveTempFile close and delete a staging file created by CreateTempFile
+func RemoveTempFile(f *os.File) error {
+	name := f.Name()
+	defer untrackTempFile(name)
+	if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) {
+		log.Warnf("failed to close temp file [%s]: %+v", name, err)
+	}
+	if err := os.Remove(name); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// TempFileUsage returns the count and total size of the staging files in use
+func TempFileUsage() (count int, size int64) {
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	for _, s := range tempFiles.m {
+		size += s
+	}
+	return len(tempFiles.m), size
+}
+
+// SweepTempFiles delete the staging files which are not owned by any stream,
+// e.g. the ones left behind by a crashed or killed process
+func SweepTempFiles() {
+	entries, err := os.ReadDir(conf.Conf.TempDir)
+	if err != nil {
+		log.Errorln("failed list temp file: ", err)
+		return
+	}
+	tempFiles.Lock()
+	defer tempFiles.Unlock()
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), tempFilePrefix) {
+			continue
+		}
+		name := filepath.Join(conf.Conf.TempDir, entry.Name())
+		if _, ok := tempFiles.m[name]; ok {
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			log.Errorln("failed delete temp file: ", err)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package utils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+)
+
+func TestTempFileLifecycle(t *testing.T) {
+	if conf.Conf == nil {
+		conf.Conf = conf.DefaultConfig()
+	}
+	dir := t.TempDir()
+	oldDir := conf.Conf.TempDir
+	conf.Conf.TempDir = dir
+	defer func() { conf.Conf.TempDir = oldDir }()
+
+	f, err := CreateTempFile(strings.NewReader("hello"), 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count, size := TempFileUsage(); count != 1 || size != 5 {
+		t.Fatalf("expect 1 file of 5 bytes in use, got %d files of %d bytes", count, size)
+	}
+	// a staging file left by another process, and a file not created by CreateTempFile
+	orphan := filepath.Join(dir, tempFilePrefix+"orphan")
+	other := filepath.Join(dir, "other")
+	for _, name := range []string{orphan, other} {
+		if err := os.WriteFile(name, []byte("x"), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	SweepTempFiles()
+	if _, err := os.Stat(orphan); !os.IsNotExist(err) {
+		t.Fatalf("expect the orphan swept, got %v", err)
+	}
+	for _, name := range []string{f.Name(), other} {
+		if _, err := os.Stat(name); err != nil {
+			t.Fatalf("expect %s kept: %v", name, err)
+		}
+	}
+
+	if err := RemoveTempFile(f); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(f.Name()); !os.IsNotExist(err) {
+		t.Fatalf("expect the temp file deleted, got %v", err)
+	}
+	if count, _ := TempFileUsage(); count != 0 {
+		t.Fatalf("expect no file in use, got %d", count)
+	}
+	// a short stream doesn't leave its staging file behind
+	if _, err := CreateTempFile(strings.NewReader("hi"), 5); err == nil {
+		t.Fatal("expect the size mismatch to fail")
+	}
+	if count, _ := TempFileUsage(); count != 0 {
+		t.Fatalf("expect no file in use, got %d", count)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "other" {
+		t.Fatalf("expect only the other file left, got %v", entries)
+	}
+}
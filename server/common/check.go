@@ -6,10 +6,12 @@ import (
 
 	"github.com/alist-org/alist/v3/internal/conf"
 	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
 	"github.com/alist-org/alist/v3/internal/model"
 	"github.com/alist-org/alist/v3/internal/op"
 	"github.com/alist-org/alist/v3/pkg/utils"
 	"github.com/dlclark/regexp2"
+	"github.com/pkg/errors"
 )
 
 func IsStorageSignEnabled(rawPath string) bool {
@@ -31,6 +33,20 @@ func IsApply(metaPath, reqPath string, applySub bool) bool {
 	return utils.IsSubPath(metaPath, reqPath) && applySub
 }
 
+// AccessFilter returns the filter of the objects under dirPath the user can access with the password
+// of dirPath, checking the nearest meta of each of them, so the folders with another password and
+// the hidden objects are skipped
+func AccessFilter(user *model.User, dirPath, password string) model.WalkFilter {
+	return func(p string) bool {
+		p = path.Join(dirPath, p)
+		meta, err := op.GetNearestMeta(p)
+		if err != nil && !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+			return false
+		}
+		return CanAccess(user, meta, p, password)
+	}
+}
+
 func CanAccess(user *model.User, meta *model.Meta, reqPath string, password string) bool {
 	// if the reqPath is in hide (only can check the nearest meta) and user can't see hides, can't access
 	if meta != nil && !user.CanSeeHides() && meta.Hide != "" &&
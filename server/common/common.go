@@ -2,11 +2,13 @@ package common
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strings"
 
 	"github.com/alist-org/alist/v3/cmd/flags"
 	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/errs"
 	"github.com/gin-gonic/gin"
 	log "github.com/sirupsen/logrus"
 )
@@ -39,6 +41,32 @@ func ErrorResp(c *gin.Context, err error, code int, l ...bool) {
 	//c.Abort()
 }
 
+// typedCodes are the codes of the typed errors that the handlers can return with ErrorTypedResp
+var typedCodes = map[error]int{
+	errs.ObjectNotFound:      404,
+	errs.StorageNotFound:     404,
+	errs.PermissionDenied:    403,
+	errs.ObjectAlreadyExists: 409,
+	errs.TooManyRequests:     429,
+	errs.QuotaExceeded:       507,
+}
+
+// errCode returns the code of the first error of typed that err wraps, or code if it wraps none of them
+func errCode(err error, code int, typed []error) int {
+	for _, t := range typed {
+		if c, ok := typedCodes[t]; ok && errors.Is(err, t) {
+			return c
+		}
+	}
+	return code
+}
+
+// ErrorTypedResp is used to return error response like ErrorResp, but with the code of the typed error err wraps.
+// Only the errors in typed are mapped, so a handler opts into the codes its clients can handle
+func ErrorTypedResp(c *gin.Context, err error, code int, typed ...error) {
+	ErrorResp(c, err, errCode(err, code, typed))
+}
+
 func ErrorWithDataResp(c *gin.Context, err error, code int, data interface{}, l ...bool) {
 	if len(l) > 0 && l[0] {
 		if flags.Debug || flags.Dev {
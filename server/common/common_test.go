@@ -0,0 +1,35 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	pkgerr "github.com/pkg/errors"
+)
+
+func TestErrCode(t *testing.T) {
+	all := []error{errs.ObjectNotFound, errs.PermissionDenied, errs.ObjectAlreadyExists, errs.TooManyRequests, errs.QuotaExceeded}
+	cases := []struct {
+		err   error
+		typed []error
+		want  int
+	}{
+		{fmt.Errorf("获取文件信息失败: %w", errs.ObjectNotFound), all, 404},
+		{pkgerr.WithMessage(errs.PermissionDenied, "failed link"), all, 403},
+		{fmt.Errorf("wrapped: %w", fmt.Errorf("quota: %w", errs.QuotaExceeded)), all, 507},
+		{errs.TooManyRequests, all, 429},
+		{errs.ObjectAlreadyExists, all, 409},
+		{fmt.Errorf("plain"), all, 500},
+		// the errors the handler didn't opt into keep the code
+		{errs.ObjectNotFound, []error{errs.PermissionDenied}, 500},
+		{errs.ObjectNotFound, nil, 500},
+		// the errors without a code are ignored
+		{errs.NotFile, []error{errs.NotFile}, 500},
+	}
+	for _, c := range cases {
+		if got := errCode(c.err, 500, c.typed); got != c.want {
+			t.Errorf("errCode(%v, %v) = %d, want %d", c.err, c.typed, got, c.want)
+		}
+	}
+}
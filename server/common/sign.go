@@ -1,17 +1,42 @@
 package common
 
 import (
+	"context"
 	stdpath "path"
 
 	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/driver"
 	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
 	"github.com/alist-org/alist/v3/internal/setting"
 	"github.com/alist-org/alist/v3/internal/sign"
 )
 
-func Sign(obj model.Obj, parent string, encrypt bool) string {
-	if obj.IsDir() || (!encrypt && !setting.GetBool(conf.SignAll)) {
+func Sign(ctx context.Context, obj model.Obj, parent string, encrypt bool) string {
+	path := stdpath.Join(parent, obj.GetName())
+	if obj.IsDir() || (!encrypt && !setting.GetBool(conf.SignAll) && !IsUserScoped(path)) {
 		return ""
 	}
-	return sign.Sign(stdpath.Join(parent, obj.GetName()))
+	return SignFor(ctx, path, sign.Sign)
+}
+
+// IsUserScoped judge whether the storage of rawPath shows different trees to different users,
+// the links to it are always signed for the user, see SignFor
+func IsUserScoped(rawPath string) bool {
+	s, ok := op.GetBalancedStorage(rawPath).(driver.UserScoped)
+	return ok && s.HasUserScopes()
+}
+
+// LinkScope returns the name of the user in ctx if the storage of rawPath shows the user its own tree
+func LinkScope(ctx context.Context, rawPath string) string {
+	if s, ok := op.GetBalancedStorage(rawPath).(driver.UserScoped); ok && s.HasUserScopes() {
+		return s.UserScope(ctx)
+	}
+	return ""
+}
+
+// SignFor signs rawPath with signFunc for the user in ctx, so that the requests of the link
+// are served in the tree of the user, see sign.Scoped
+func SignFor(ctx context.Context, rawPath string, signFunc func(string) string) string {
+	return sign.Scoped(rawPath, LinkScope(ctx, rawPath), signFunc)
 }
@@ -0,0 +1,183 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/disintegration/imaging"
+	"github.com/pkg/errors"
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/basicfont"
+	"golang.org/x/image/math/fixed"
+)
+
+// watermarkTypes are the image types that can be decoded and re-encoded
+var watermarkTypes = []string{"jpg", "jpeg", "png"}
+
+const (
+	// MaxWatermarkSize caps the images kept in memory to be watermarked,
+	// larger images are refused rather than served without the watermark
+	MaxWatermarkSize = 32 * utils.MB
+	// maxWatermarkPixels caps the decoded images, a small file may decode to a huge image
+	maxWatermarkPixels = 64 << 20
+)
+
+var ErrWatermarkTooLarge = errors.New("the image is too large to watermark")
+
+// NeedWatermark judge whether the file at path should be served with a watermark
+func NeedWatermark(path string) bool {
+	if !utils.SliceContains(watermarkTypes, utils.Ext(path)) {
+		return false
+	}
+	for _, p := range conf.SlicesMap[conf.WatermarkPaths] {
+		if utils.IsSubPath(p, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// WatermarkText is the text overlaid on the images served to the user, or to ip for guests
+func WatermarkText(user *model.User, ip string) string {
+	visitor := ip
+	if user != nil {
+		visitor = user.Username
+	}
+	return fmt.Sprintf("%s %s", visitor, time.Now().Format("2006-01-02 15:04:05"))
+}
+
+// watermarkWriter keeps the response of the source until the image is watermarked
+type watermarkWriter struct {
+	header http.Header
+	status int
+	buf    bytes.Buffer
+	// err is kept since http.ServeContent drops the errors of Write
+	err error
+}
+
+func (w *watermarkWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *watermarkWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
+
+func (w *watermarkWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	if w.buf.Len()+len(p) > MaxWatermarkSize {
+		w.err = ErrWatermarkTooLarge
+		return 0, w.err
+	}
+	return w.buf.Write(p)
+}
+
+// WatermarkProxy proxies the whole image of link, overlays text on it and writes it to w.
+// Images larger than MaxWatermarkSize are refused with ErrWatermarkTooLarge.
+func WatermarkProxy(w http.ResponseWriter, r *http.Request, link *model.Link, file model.Obj, text string) error {
+	if link.MFile != nil {
+		defer link.MFile.Close()
+	}
+	if file.GetSize() > MaxWatermarkSize {
+		return ErrWatermarkTooLarge
+	}
+	// the watermarked image differs from the source, so ranges of the source make no sense
+	r.Header.Del("Range")
+	src := &watermarkWriter{header: http.Header{}}
+	if err := Proxy(src, r, link, file); err != nil {
+		return err
+	}
+	if src.err != nil {
+		return src.err
+	}
+	header := w.Header()
+	for k, v := range src.header {
+		header[k] = v
+	}
+	if src.status == 0 {
+		src.status = http.StatusOK
+	}
+	if src.status < 200 || src.status >= 300 || src.buf.Len() == 0 {
+		w.WriteHeader(src.status)
+		_, err := w.Write(src.buf.Bytes())
+		return err
+	}
+	marked, err := Watermark(src.buf.Bytes(), utils.Ext(file.GetName()), text)
+	if err != nil {
+		return err
+	}
+	header.Del("Content-Range")
+	header.Del("Accept-Ranges")
+	header.Del("Etag")
+	header.Set("Cache-Control", "no-store")
+	header.Set("Content-Length", strconv.Itoa(marked.Len()))
+	w.WriteHeader(http.StatusOK)
+	_, err = utils.CopyWithBuffer(w, marked)
+	return err
+}
+
+// Watermark decodes the image in data, tiles text over it and encodes it again in the format of ext
+func Watermark(data []byte, ext, text string) (*bytes.Buffer, error) {
+	format, err := imaging.FormatFromExtension(ext)
+	if err != nil {
+		return nil, err
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Width*cfg.Height > maxWatermarkPixels {
+		return nil, ErrWatermarkTooLarge
+	}
+	img, err := imaging.Decode(bytes.NewReader(data), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, err
+	}
+	dst := imaging.Clone(img)
+	mark := watermarkMark(text, dst.Bounds().Dx())
+	markW, markH := mark.Bounds().Dx(), mark.Bounds().Dy()
+	mask := image.NewUniform(color.Alpha{A: 96})
+	for row, y := 0, markH; y < dst.Bounds().Dy(); row, y = row+1, y+markH*4 {
+		// stagger every other row so that the marks can't be cropped out by a single column
+		x := (row % 2) * markW / 2
+		for ; x < dst.Bounds().Dx(); x += markW * 3 / 2 {
+			rect := image.Rect(x, y, x+markW, y+markH)
+			draw.DrawMask(dst, rect, mark, image.Point{}, mask, image.Point{}, draw.Over)
+		}
+	}
+	buf := bytes.NewBuffer(nil)
+	if err = imaging.Encode(buf, dst, format); err != nil {
+		return nil, err
+	}
+	return buf, nil
+}
+
+// watermarkMark renders text with a shadow and scales it to about a quarter of the image width
+func watermarkMark(text string, width int) image.Image {
+	face := basicfont.Face7x13
+	w := font.MeasureString(face, text).Ceil() + 2
+	h := face.Metrics().Height.Ceil() + 2
+	mark := image.NewNRGBA(image.Rect(0, 0, w, h))
+	d := &font.Drawer{Dst: mark, Face: face}
+	d.Src = image.NewUniform(color.Black)
+	d.Dot = fixed.P(1, face.Metrics().Ascent.Ceil()+1)
+	d.DrawString(text)
+	d.Src = image.NewUniform(color.White)
+	d.Dot = fixed.P(0, face.Metrics().Ascent.Ceil())
+	d.DrawString(text)
+	if width/4 <= w {
+		return mark
+	}
+	return imaging.Resize(mark, width/4, 0, imaging.NearestNeighbor)
+}
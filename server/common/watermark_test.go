@@ -0,0 +1,59 @@
+package common
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/png"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/model"
+)
+
+type nopCloseReader struct {
+	*bytes.Reader
+}
+
+func (nopCloseReader) Close() error { return nil }
+
+func TestWatermarkProxy(t *testing.T) {
+	var src bytes.Buffer
+	if err := png.Encode(&src, image.NewNRGBA(image.Rect(0, 0, 64, 64))); err != nil {
+		t.Fatal(err)
+	}
+	file := &model.Object{Name: "a.png", Size: int64(src.Len()), Modified: time.Now()}
+	link := &model.Link{MFile: nopCloseReader{bytes.NewReader(src.Bytes())}}
+	r := httptest.NewRequest(http.MethodGet, "/p/a.png", nil)
+	r.Header.Set("Range", "bytes=0-9")
+	w := httptest.NewRecorder()
+	if err := WatermarkProxy(w, r, link, file, "guest"); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	if w.Header().Get("Content-Length") != strconv.Itoa(w.Body.Len()) || w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("unexpected headers %v", w.Header())
+	}
+	if bytes.Equal(w.Body.Bytes(), src.Bytes()) {
+		t.Error("the image isn't watermarked")
+	}
+	if _, err := png.Decode(w.Body); err != nil {
+		t.Errorf("watermarked image: %v", err)
+	}
+
+	file.Size = MaxWatermarkSize + 1
+	link = &model.Link{MFile: nopCloseReader{bytes.NewReader(src.Bytes())}}
+	w = httptest.NewRecorder()
+	err := WatermarkProxy(w, httptest.NewRequest(http.MethodGet, "/p/a.png", nil), link, file, "guest")
+	if !errors.Is(err, ErrWatermarkTooLarge) {
+		t.Errorf("got %v, want ErrWatermarkTooLarge", err)
+	}
+	if w.Body.Len() > 0 {
+		t.Error("the image too large is served")
+	}
+}
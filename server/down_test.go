@@ -0,0 +1,127 @@
+package server_test
+
+import (
+	"context"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/sign"
+	"github.com/alist-org/alist/v3/server/common"
+	"github.com/alist-org/alist/v3/server/handles"
+	"github.com/alist-org/alist/v3/server/middlewares"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// scopedDriver shows each user a file linking to the user's own tree, like a storage with home directories
+type scopedDriver struct {
+	model.Storage
+	Addition struct{}
+}
+
+func (d *scopedDriver) Config() driver.Config {
+	return driver.Config{Name: "ScopedTest", NoCache: true}
+}
+
+func (d *scopedDriver) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *scopedDriver) Init(ctx context.Context) error {
+	return nil
+}
+
+func (d *scopedDriver) Drop(ctx context.Context) error {
+	return nil
+}
+
+func (d *scopedDriver) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{ID: "/", Name: "root", IsFolder: true}, nil
+}
+
+func (d *scopedDriver) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	return []model.Obj{&model.Object{ID: "/a.txt", Name: "a.txt", Size: 1}}, nil
+}
+
+func (d *scopedDriver) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	tree := "root"
+	if scope := d.UserScope(ctx); scope != "" {
+		tree = scope
+	}
+	return &model.Link{URL: "https://example.com/" + tree + "/a.txt"}, nil
+}
+
+func (d *scopedDriver) UserScope(ctx context.Context) string {
+	user, ok := ctx.Value("user").(*model.User)
+	if !ok || user.IsAdmin() {
+		return ""
+	}
+	return user.Username
+}
+
+func (d *scopedDriver) HasUserScopes() bool {
+	return true
+}
+
+func TestDownUserScope(t *testing.T) {
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	db.Init(dB)
+	op.RegisterDriver(func() driver.Driver { return &scopedDriver{} })
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: "ScopedTest", MountPath: "/home", Addition: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"alice", "bob"} {
+		if err := op.CreateUser(&model.User{Username: name, Password: "password", Role: model.GENERAL, BasePath: "/"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	alice, err := op.GetUserByName("alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.GET("/d/*path", middlewares.DownOrShare(sign.Verify), handles.Down)
+	get := func(query string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/d/home/a.txt"+query, nil))
+		return w
+	}
+
+	// the link signed for a user is served in the tree of the user, though the request carries no login
+	userCtx := context.WithValue(context.Background(), "user", alice)
+	s := common.Sign(userCtx, &model.Object{Name: "a.txt"}, "/home", false)
+	if w := get("?sign=" + s); w.Code != http.StatusFound || w.Header().Get("Location") != "https://example.com/alice/a.txt" {
+		t.Fatalf("expect the file of alice, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+	// the links without the user are refused rather than served from the root of the storage
+	if w := get(""); w.Code == http.StatusFound {
+		t.Fatalf("expect the unsigned link refused, got %s", w.Header().Get("Location"))
+	}
+	// the user carried by the sign can't be changed
+	_, aliceSign, _ := strings.Cut(s, ".")
+	forged := base64.RawURLEncoding.EncodeToString([]byte("bob")) + "." + aliceSign
+	if w := get("?sign=" + forged); w.Code == http.StatusFound {
+		t.Fatalf("expect the forged sign refused, got %s", w.Header().Get("Location"))
+	}
+	// the links of the admin see the whole storage
+	admin := context.WithValue(context.Background(), "user", &model.User{Username: "admin", Role: model.ADMIN})
+	if w := get("?sign=" + common.Sign(admin, &model.Object{Name: "a.txt"}, "/home", false)); w.Header().Get("Location") != "https://example.com/root/a.txt" {
+		t.Fatalf("expect the file in the root, got %d %s", w.Code, w.Header().Get("Location"))
+	}
+}
@@ -120,8 +120,8 @@ func FsArchiveMeta(c *gin.Context) {
 		return
 	}
 	s := ""
-	if isEncrypt(meta, reqPath) || setting.GetBool(conf.SignAll) {
-		s = sign.SignArchive(reqPath)
+	if isEncrypt(meta, reqPath) || setting.GetBool(conf.SignAll) || common.IsUserScoped(reqPath) {
+		s = common.SignFor(c, reqPath, sign.SignArchive)
 	}
 	api := "/ae"
 	if ret.DriverProviding {
@@ -2,6 +2,7 @@ package handles
 
 import (
 	"bytes"
+	"errors"
 	"fmt"
 	"io"
 	stdpath "path"
@@ -30,7 +31,7 @@ func Down(c *gin.Context) {
 		common.ErrorResp(c, err, 500)
 		return
 	}
-	if common.ShouldProxy(storage, filename) {
+	if common.ShouldProxy(storage, filename) || common.NeedWatermark(rawPath) || c.GetBool("share") {
 		Proxy(c)
 		return
 	} else {
@@ -57,9 +58,13 @@ func Proxy(c *gin.Context) {
 		common.ErrorResp(c, err, 500)
 		return
 	}
-	if canProxy(storage, filename) {
+	watermark := common.NeedWatermark(rawPath)
+	share := c.GetBool("share")
+	if canProxy(storage, filename) || watermark || share {
 		downProxyUrl := storage.GetStorage().DownProxyUrl
-		if downProxyUrl != "" {
+		// the download proxy can't add the watermark, its sign wouldn't expire with the share token,
+		// and it can't serve the tree of the user
+		if downProxyUrl != "" && !watermark && !share && !common.IsUserScoped(rawPath) {
 			_, ok := c.GetQuery("d")
 			if !ok {
 				URL := fmt.Sprintf("%s%s?sign=%s",
@@ -79,6 +84,10 @@ func Proxy(c *gin.Context) {
 			common.ErrorResp(c, err, 500)
 			return
 		}
+		if watermark {
+			watermarkProxy(c, link, file)
+			return
+		}
 		localProxy(c, link, file, storage.GetStorage().ProxyRange)
 	} else {
 		common.ErrorStrResp(c, "proxy not allowed", 403)
@@ -136,7 +145,7 @@ func localProxy(c *gin.Context, link *model.Link, file model.Obj, proxyRange boo
 		w := &common.InterceptResponseWriter{ResponseWriter: Writer, Writer: buf}
 		err = common.Proxy(w, c.Request, link, file)
 		if err == nil && buf.Len() > 0 {
-			if c.Writer.Status() < 200 || c.Writer.Status() > 300 {
+			if c.Writer.Status() < 200 || c.Writer.Status() >= 300 {
 				c.Writer.Write(buf.Bytes())
 				return
 			}
@@ -167,6 +176,23 @@ func localProxy(c *gin.Context, link *model.Link, file model.Obj, proxyRange boo
 	}
 }
 
+// watermarkProxy proxies the whole image and overlays the visitor and the current time on it
+func watermarkProxy(c *gin.Context, link *model.Link, file model.Obj) {
+	user, _ := c.Value("user").(*model.User)
+	Writer := &common.WrittenResponseWriter{ResponseWriter: c.Writer}
+	err := common.WatermarkProxy(Writer, c.Request, link, file, common.WatermarkText(user, c.ClientIP()))
+	if err == nil {
+		return
+	}
+	if Writer.IsWritten() {
+		log.Errorf("%s %s watermark proxy error: %+v", c.Request.Method, c.Request.URL.Path, err)
+	} else if errors.Is(err, common.ErrWatermarkTooLarge) {
+		common.ErrorResp(c, err, 403)
+	} else {
+		common.ErrorResp(c, err, 500, true)
+	}
+}
+
 // TODO need optimize
 // when can be proxy?
 // 1. text file
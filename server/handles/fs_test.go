@@ -0,0 +1,129 @@
+package handles_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/server/handles"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// typedErrDriver fails with typed errors: listing "denied" is refused, "broken" fails with a plain error,
+// new folders exceed the quota and every file is already gone when removed
+type typedErrDriver struct {
+	model.Storage
+	Addition struct{}
+}
+
+func (d *typedErrDriver) Config() driver.Config {
+	return driver.Config{Name: "TypedErrTest", NoCache: true}
+}
+
+func (d *typedErrDriver) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *typedErrDriver) Init(ctx context.Context) error {
+	return nil
+}
+
+func (d *typedErrDriver) Drop(ctx context.Context) error {
+	return nil
+}
+
+func (d *typedErrDriver) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{ID: "/", Name: "root", IsFolder: true}, nil
+}
+
+func (d *typedErrDriver) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	switch dir.GetName() {
+	case "denied":
+		return nil, fmt.Errorf("list denied: %w", errs.PermissionDenied)
+	case "broken":
+		return nil, fmt.Errorf("list broken")
+	}
+	return []model.Obj{
+		&model.Object{ID: "/denied", Name: "denied", IsFolder: true},
+		&model.Object{ID: "/broken", Name: "broken", IsFolder: true},
+	}, nil
+}
+
+func (d *typedErrDriver) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	return nil, errs.NotImplement
+}
+
+func (d *typedErrDriver) MakeDir(ctx context.Context, parentDir model.Obj, dirName string) error {
+	return fmt.Errorf("make dir: %w", errs.QuotaExceeded)
+}
+
+func (d *typedErrDriver) Remove(ctx context.Context, obj model.Obj) error {
+	return fmt.Errorf("remove: %w", errs.ObjectNotFound)
+}
+
+func TestFsTypedErrorCodes(t *testing.T) {
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	db.Init(dB)
+	op.RegisterDriver(func() driver.Driver { return &typedErrDriver{} })
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: "TypedErrTest", MountPath: "/t", Addition: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user", &model.User{Username: "admin", Role: model.ADMIN, BasePath: "/", Permission: 0xffff})
+	})
+	r.POST("/fs/list", handles.FsList)
+	r.POST("/fs/get", handles.FsGet)
+	r.POST("/fs/mkdir", handles.FsMkdir)
+	r.POST("/fs/remove", handles.FsRemove)
+	code := func(path, body string) int {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Code int `json:"code"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s: %v", path, err)
+		}
+		return resp.Code
+	}
+
+	cases := []struct {
+		name, path, body string
+		want             int
+	}{
+		{"missing file", "/fs/get", `{"path":"/t/missing"}`, 404},
+		{"refused list", "/fs/list", `{"path":"/t/denied"}`, 403},
+		{"quota", "/fs/mkdir", `{"path":"/t/new"}`, 507},
+		// the plain errors keep the generic code
+		{"plain error", "/fs/list", `{"path":"/t/broken"}`, 500},
+		// the handlers not opting into the typed errors keep the generic code
+		{"not opted in", "/fs/remove", `{"dir":"/t","names":["denied"]}`, 500},
+	}
+	for _, c := range cases {
+		if got := code(c.path, c.body); got != c.want {
+			t.Errorf("%s: got code %d, want %d", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package handles
+
+import (
+	stdpath "path"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/fs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type ExportReq struct {
+	Path     string `json:"path" form:"path"`
+	Password string `json:"password" form:"password"`
+	// Format is csv or json, csv by default
+	Format string `json:"format" form:"format"`
+}
+
+var exportContentTypes = map[string]string{
+	"csv":  "text/csv; charset=utf-8",
+	"json": "application/json; charset=utf-8",
+}
+
+// FsExport streams the list of the files under the folder, for storages implementing driver.ExportList
+func FsExport(c *gin.Context) {
+	var req ExportReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if req.Format == "" {
+		req.Format = "csv"
+	}
+	contentType, ok := exportContentTypes[req.Format]
+	if !ok {
+		common.ErrorStrResp(c, "format must be csv or json", 400)
+		return
+	}
+	user := c.MustGet("user").(*model.User)
+	reqPath, err := user.JoinPath(req.Path)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil {
+		if !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+			common.ErrorResp(c, err, 500, true)
+			return
+		}
+	}
+	c.Set("meta", meta)
+	if !common.CanAccess(user, meta, reqPath, req.Password) {
+		common.ErrorStrResp(c, "password is incorrect or you have no permission", 403)
+		return
+	}
+	name := stdpath.Base(reqPath)
+	if name == "/" {
+		name = "root"
+	}
+	w := &downloadWriter{c: c, name: name + "." + req.Format, contentType: contentType}
+	if err := fs.ExportList(c, reqPath, req.Format, common.AccessFilter(user, reqPath, req.Password), w); err != nil && !w.started {
+		common.ErrorResp(c, err, 500)
+	}
+}
@@ -0,0 +1,70 @@
+package handles
+
+import (
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/fs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/alist-org/alist/v3/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type HashManifestReq struct {
+	Path     string `json:"path"`
+	Password string `json:"password"`
+	HashType string `json:"hash_type"`
+	// Write the manifest into the folder, required if some hashes need to be computed
+	Write bool `json:"write"`
+}
+
+type HashManifestResp struct {
+	Manifest string    `json:"manifest"`
+	Task     *TaskInfo `json:"task"`
+}
+
+func FsHashManifest(c *gin.Context) {
+	var req HashManifestReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	if req.HashType == "" {
+		req.HashType = "sha1"
+	}
+	user := c.MustGet("user").(*model.User)
+	reqPath, err := user.JoinPath(req.Path)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil {
+		if !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+			common.ErrorResp(c, err, 500, true)
+			return
+		}
+	}
+	c.Set("meta", meta)
+	if !common.CanAccess(user, meta, reqPath, req.Password) {
+		common.ErrorStrResp(c, "password is incorrect or you have no permission", 403)
+		return
+	}
+	if req.Write && !user.CanWrite() && !common.CanWrite(meta, reqPath) {
+		common.ErrorResp(c, errs.PermissionDenied, 403)
+		return
+	}
+	manifest, t, err := fs.HashManifest(c, reqPath, req.HashType, req.Write, common.AccessFilter(user, reqPath, req.Password))
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	var resp HashManifestResp
+	resp.Manifest = manifest
+	if t != nil {
+		info := getTaskInfo[task.TaskExtensionInfo](t)
+		resp.Task = &info
+	}
+	common.SuccessResp(c, resp)
+}
@@ -19,6 +19,9 @@ import (
 	log "github.com/sirupsen/logrus"
 )
 
+// writeErrs are the typed errors returned with their own codes by the handlers changing the files
+var writeErrs = []error{errs.ObjectAlreadyExists, errs.PermissionDenied, errs.QuotaExceeded, errs.TooManyRequests}
+
 type MkdirOrLinkReq struct {
 	Path string `json:"path" form:"path"`
 }
@@ -49,7 +52,7 @@ func FsMkdir(c *gin.Context) {
 		}
 	}
 	if err := fs.MakeDir(c, reqPath); err != nil {
-		common.ErrorResp(c, err, 500)
+		common.ErrorTypedResp(c, err, 500, writeErrs...)
 		return
 	}
 	common.SuccessResp(c)
@@ -186,7 +189,7 @@ func FsRename(c *gin.Context) {
 		}
 	}
 	if err := fs.Rename(c, reqPath, req.Name); err != nil {
-		common.ErrorResp(c, err, 500)
+		common.ErrorTypedResp(c, err, 500, writeErrs...)
 		return
 	}
 	common.SuccessResp(c)
@@ -343,7 +346,8 @@ func Link(c *gin.Context) {
 		common.ErrorResp(c, err, 500)
 		return
 	}
-	if storage.Config().OnlyLocal {
+	// the watermark is added only by the proxy of alist
+	if storage.Config().OnlyLocal || common.NeedWatermark(rawPath) {
 		common.SuccessResp(c, model.Link{
 			URL: fmt.Sprintf("%s/p%s?d&sign=%s",
 				common.GetApiUrl(c.Request),
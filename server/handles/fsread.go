@@ -1,6 +1,7 @@
 package handles
 
 import (
+	"context"
 	"fmt"
 	stdpath "path"
 	"strings"
@@ -19,6 +20,9 @@ import (
 	"github.com/pkg/errors"
 )
 
+// readErrs are the typed errors returned with their own codes by the handlers reading the files
+var readErrs = []error{errs.ObjectNotFound, errs.StorageNotFound, errs.PermissionDenied, errs.TooManyRequests}
+
 type ListReq struct {
 	model.PageReq
 	Path     string `json:"path" form:"path"`
@@ -45,6 +49,8 @@ type ObjResp struct {
 	Type        int                        `json:"type"`
 	HashInfoStr string                     `json:"hashinfo"`
 	HashInfo    map[*utils.HashType]string `json:"hash_info"`
+	// Alias is the path of the target in the storage when the object is an alias
+	Alias string `json:"alias,omitempty"`
 }
 
 type FsListResp struct {
@@ -87,7 +93,7 @@ func FsList(c *gin.Context) {
 	}
 	objs, err := fs.List(c, reqPath, &fs.ListArgs{Refresh: req.Refresh})
 	if err != nil {
-		common.ErrorResp(c, err, 500)
+		common.ErrorTypedResp(c, err, 500, readErrs...)
 		return
 	}
 	total, objs := pagination(objs, &req.PageReq)
@@ -97,7 +103,7 @@ func FsList(c *gin.Context) {
 		provider = storage.GetStorage().Driver
 	}
 	common.SuccessResp(c, FsListResp{
-		Content:  toObjsResp(objs, reqPath, isEncrypt(meta, reqPath)),
+		Content:  toObjsResp(c, objs, reqPath, isEncrypt(meta, reqPath)),
 		Total:    int64(total),
 		Readme:   getReadme(meta, reqPath),
 		Header:   getHeader(meta, reqPath),
@@ -207,10 +213,11 @@ func pagination(objs []model.Obj, req *model.PageReq) (int, []model.Obj) {
 	return total, objs[start:end]
 }
 
-func toObjsResp(objs []model.Obj, parent string, encrypt bool) []ObjResp {
+func toObjsResp(ctx context.Context, objs []model.Obj, parent string, encrypt bool) []ObjResp {
 	var resp []ObjResp
 	for _, obj := range objs {
 		thumb, _ := model.GetThumb(obj)
+		alias, _ := model.GetAliasTarget(obj)
 		resp = append(resp, ObjResp{
 			Id:          obj.GetID(),
 			Path:        obj.GetPath(),
@@ -221,9 +228,10 @@ func toObjsResp(objs []model.Obj, parent string, encrypt bool) []ObjResp {
 			Created:     obj.CreateTime(),
 			HashInfoStr: obj.GetHash().String(),
 			HashInfo:    obj.GetHash().Export(),
-			Sign:        common.Sign(obj, parent, encrypt),
+			Sign:        common.Sign(ctx, obj, parent, encrypt),
 			Thumb:       thumb,
 			Type:        utils.GetObjType(obj.GetName(), obj.IsDir()),
+			Alias:       alias,
 		})
 	}
 	return resp
@@ -269,7 +277,7 @@ func FsGet(c *gin.Context) {
 	}
 	obj, err := fs.Get(c, reqPath, &fs.GetArgs{})
 	if err != nil {
-		common.ErrorResp(c, err, 500)
+		common.ErrorTypedResp(c, err, 500, readErrs...)
 		return
 	}
 	var rawURL string
@@ -284,12 +292,16 @@ func FsGet(c *gin.Context) {
 			common.ErrorResp(c, err, 500)
 			return
 		}
-		if storage.Config().MustProxy() || storage.GetStorage().WebProxy {
+		// the watermark is added only by the proxy of alist
+		watermark := common.NeedWatermark(reqPath)
+		// the download proxy can't serve the tree of the user
+		scoped := common.IsUserScoped(reqPath)
+		if storage.Config().MustProxy() || storage.GetStorage().WebProxy || watermark {
 			query := ""
-			if isEncrypt(meta, reqPath) || setting.GetBool(conf.SignAll) {
-				query = "?sign=" + sign.Sign(reqPath)
+			if isEncrypt(meta, reqPath) || setting.GetBool(conf.SignAll) || scoped {
+				query = "?sign=" + common.SignFor(c, reqPath, sign.Sign)
 			}
-			if storage.GetStorage().DownProxyUrl != "" {
+			if storage.GetStorage().DownProxyUrl != "" && !watermark && !scoped {
 				rawURL = fmt.Sprintf("%s%s?sign=%s",
 					strings.Split(storage.GetStorage().DownProxyUrl, "\n")[0],
 					utils.EncodePath(reqPath, true),
@@ -339,7 +351,7 @@ func FsGet(c *gin.Context) {
 			Created:     obj.CreateTime(),
 			HashInfoStr: obj.GetHash().String(),
 			HashInfo:    obj.GetHash().Export(),
-			Sign:        common.Sign(obj, parentPath, isEncrypt(meta, reqPath)),
+			Sign:        common.Sign(c, obj, parentPath, isEncrypt(meta, reqPath)),
 			Type:        utils.GetFileType(obj.GetName()),
 			Thumb:       thumb,
 		},
@@ -347,7 +359,7 @@ func FsGet(c *gin.Context) {
 		Readme:   getReadme(meta, reqPath),
 		Header:   getHeader(meta, reqPath),
 		Provider: provider,
-		Related:  toObjsResp(related, parentPath, isEncrypt(parentMeta, parentPath)),
+		Related:  toObjsResp(c, related, parentPath, isEncrypt(parentMeta, parentPath)),
 	})
 }
 
@@ -89,7 +89,7 @@ func FsStream(c *gin.Context) {
 	}
 	defer c.Request.Body.Close()
 	if err != nil {
-		common.ErrorResp(c, err, 500)
+		common.ErrorTypedResp(c, err, 500, writeErrs...)
 		return
 	}
 	if t == nil {
@@ -182,7 +182,7 @@ func FsForm(c *gin.Context) {
 		err = fs.PutDirectly(c, dir, &s, true)
 	}
 	if err != nil {
-		common.ErrorResp(c, err, 500)
+		common.ErrorTypedResp(c, err, 500, writeErrs...)
 		return
 	}
 	if t == nil {
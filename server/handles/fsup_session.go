@@ -0,0 +1,120 @@
+package handles
+
+import (
+	"net/url"
+	stdpath "path"
+	"strconv"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/internal/stream"
+	"github.com/alist-org/alist/v3/pkg/utils"
+	"github.com/alist-org/alist/v3/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// uploadSessionStorage gets the storage and the actual path of the File-Path header
+func uploadSessionStorage(c *gin.Context) (driver.Driver, string, bool) {
+	path, err := url.PathUnescape(c.GetHeader("File-Path"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, "", false
+	}
+	user := c.MustGet("user").(*model.User)
+	path, err = user.JoinPath(path)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return nil, "", false
+	}
+	storage, actualPath, err := op.GetStorageAndActualPath(path)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return nil, "", false
+	}
+	if storage.Config().NoUpload {
+		common.ErrorResp(c, errs.UploadNotSupported, 403)
+		return nil, "", false
+	}
+	return storage, actualPath, true
+}
+
+// FsUploadSessionCreate starts the upload of the file at File-Path of X-File-Size bytes,
+// the response tells the size of the parts to send with FsUploadSessionPart
+func FsUploadSessionCreate(c *gin.Context) {
+	storage, actualPath, ok := uploadSessionStorage(c)
+	if !ok {
+		return
+	}
+	size, err := strconv.ParseInt(c.GetHeader("X-File-Size"), 10, 64)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	session, err := op.CreateUploadSession(c, storage, actualPath, size)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, session)
+}
+
+func FsUploadSessionGet(c *gin.Context) {
+	storage, _, ok := uploadSessionStorage(c)
+	if !ok {
+		return
+	}
+	session, err := op.GetUploadSession(c, storage, c.GetHeader("Session-Id"))
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, session)
+}
+
+// FsUploadSessionPart uploads the body as the part Part-Index of the session Session-Id
+func FsUploadSessionPart(c *gin.Context) {
+	defer c.Request.Body.Close()
+	storage, actualPath, ok := uploadSessionStorage(c)
+	if !ok {
+		return
+	}
+	index, err := strconv.Atoi(c.GetHeader("Part-Index"))
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	size, err := strconv.ParseInt(c.GetHeader("Content-Length"), 10, 64)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	s := &stream.FileStream{
+		Obj: &model.Object{
+			Name: stdpath.Base(actualPath),
+			Size: size,
+		},
+		Reader:   c.Request.Body,
+		Mimetype: utils.GetMimeType(actualPath),
+	}
+	err = op.PutUploadPart(c, storage, c.GetHeader("Session-Id"), index, s, nil)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
+
+func FsUploadSessionComplete(c *gin.Context) {
+	storage, actualPath, ok := uploadSessionStorage(c)
+	if !ok {
+		return
+	}
+	err := op.CompleteUploadSession(c, storage, actualPath, c.GetHeader("Session-Id"))
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c)
+}
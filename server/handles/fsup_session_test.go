@@ -0,0 +1,193 @@
+package handles_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/server/handles"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sessionDriver keeps the parts of its upload sessions in memory, parts are 4 bytes
+type sessionDriver struct {
+	model.Storage
+	Addition struct{}
+	sessions map[string]*model.UploadSession
+	parts    map[string]map[int][]byte
+	files    map[string][]byte
+}
+
+func (d *sessionDriver) Config() driver.Config {
+	return driver.Config{Name: "SessionTest", NoCache: true}
+}
+
+func (d *sessionDriver) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *sessionDriver) Init(ctx context.Context) error {
+	return nil
+}
+
+func (d *sessionDriver) Drop(ctx context.Context) error {
+	return nil
+}
+
+func (d *sessionDriver) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{ID: "/", Name: "root", IsFolder: true}, nil
+}
+
+func (d *sessionDriver) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	return nil, nil
+}
+
+func (d *sessionDriver) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	return nil, errs.NotImplement
+}
+
+func (d *sessionDriver) CreateUploadSession(ctx context.Context, dstDir model.Obj, name string, size int64) (*model.UploadSession, error) {
+	s := &model.UploadSession{
+		ID:       fmt.Sprintf("s%d", len(d.sessions)),
+		Name:     name,
+		Size:     size,
+		PartSize: 4,
+		Parts:    int((size + 3) / 4),
+		Expires:  time.Now().Add(time.Hour),
+	}
+	d.sessions[s.ID] = s
+	d.parts[s.ID] = make(map[int][]byte)
+	return s, nil
+}
+
+func (d *sessionDriver) GetUploadSession(ctx context.Context, id string) (*model.UploadSession, error) {
+	s, ok := d.sessions[id]
+	if !ok {
+		return nil, errs.ObjectNotFound
+	}
+	res := *s
+	res.Received = []int{}
+	for i := 0; i < s.Parts; i++ {
+		if _, ok := d.parts[id][i]; ok {
+			res.Received = append(res.Received, i)
+		}
+	}
+	return &res, nil
+}
+
+func (d *sessionDriver) PutUploadPart(ctx context.Context, id string, index int, file model.FileStreamer, up driver.UpdateProgress) error {
+	if _, ok := d.sessions[id]; !ok {
+		return errs.ObjectNotFound
+	}
+	data, err := io.ReadAll(file)
+	if err != nil {
+		return err
+	}
+	d.parts[id][index] = data
+	return nil
+}
+
+func (d *sessionDriver) CompleteUploadSession(ctx context.Context, id string) (model.Obj, error) {
+	s, ok := d.sessions[id]
+	if !ok {
+		return nil, errs.ObjectNotFound
+	}
+	var data []byte
+	for i := 0; i < s.Parts; i++ {
+		part, ok := d.parts[id][i]
+		if !ok {
+			return nil, fmt.Errorf("part %d missing", i)
+		}
+		data = append(data, part...)
+	}
+	d.files[s.Name] = data
+	delete(d.sessions, id)
+	return &model.Object{Name: s.Name, Size: s.Size}, nil
+}
+
+func TestFsUploadSession(t *testing.T) {
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	db.Init(dB)
+	d := &sessionDriver{
+		sessions: map[string]*model.UploadSession{},
+		parts:    map[string]map[int][]byte{},
+		files:    map[string][]byte{},
+	}
+	op.RegisterDriver(func() driver.Driver { return d })
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: "SessionTest", MountPath: "/s", Addition: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		c.Set("user", &model.User{Username: "admin", Role: model.ADMIN, BasePath: "/", Permission: 0xffff})
+	})
+	r.POST("/upload_session", handles.FsUploadSessionCreate)
+	r.GET("/upload_session", handles.FsUploadSessionGet)
+	r.PUT("/upload_session/part", handles.FsUploadSessionPart)
+	r.POST("/upload_session/complete", handles.FsUploadSessionComplete)
+	call := func(method, path string, header map[string]string, body []byte) *model.UploadSession {
+		t.Helper()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, bytes.NewReader(body))
+		req.Header.Set("File-Path", "/s/a%20b.bin")
+		req.Header.Set("Content-Length", fmt.Sprint(len(body)))
+		for k, v := range header {
+			req.Header.Set(k, v)
+		}
+		r.ServeHTTP(w, req)
+		var resp struct {
+			Code    int                  `json:"code"`
+			Message string               `json:"message"`
+			Data    *model.UploadSession `json:"data"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+			t.Fatalf("%s %s: %v", method, path, err)
+		}
+		if resp.Code != 200 {
+			t.Fatalf("%s %s: %d %s", method, path, resp.Code, resp.Message)
+		}
+		return resp.Data
+	}
+
+	s := call(http.MethodPost, "/upload_session", map[string]string{"X-File-Size": "10"}, nil)
+	if s.ID == "" || s.Name != "a b.bin" || s.Parts != 3 {
+		t.Fatalf("unexpected session %+v", s)
+	}
+	// the parts can be sent in any order
+	data := []byte("0123456789")
+	for _, i := range []int{2, 0, 1} {
+		part := data[i*4 : min(i*4+4, len(data))]
+		call(http.MethodPut, "/upload_session/part", map[string]string{"Session-Id": s.ID, "Part-Index": fmt.Sprint(i)}, part)
+		if i == 0 {
+			got := call(http.MethodGet, "/upload_session", map[string]string{"Session-Id": s.ID}, nil)
+			if fmt.Sprint(got.Received) != "[0 2]" {
+				t.Fatalf("expect parts 0 and 2 received, got %v", got.Received)
+			}
+		}
+	}
+	call(http.MethodPost, "/upload_session/complete", map[string]string{"Session-Id": s.ID}, nil)
+	if got := string(d.files["a b.bin"]); got != string(data) {
+		t.Fatalf("expect the file assembled from the parts, got %q", got)
+	}
+}
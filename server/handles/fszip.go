@@ -0,0 +1,74 @@
+package handles
+
+import (
+	"fmt"
+	"net/url"
+	stdpath "path"
+
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/fs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/alist-org/alist/v3/server/common"
+	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
+)
+
+type ZipReq struct {
+	Path     string `json:"path" form:"path"`
+	Password string `json:"password" form:"password"`
+}
+
+// downloadWriter sends the response headers of the file name on the first write,
+// so errors before anything is written are still returned as json
+type downloadWriter struct {
+	c           *gin.Context
+	name        string
+	contentType string
+	started     bool
+}
+
+func (w *downloadWriter) Write(p []byte) (int, error) {
+	if !w.started {
+		w.started = true
+		w.c.Header("Content-Type", w.contentType)
+		w.c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"; filename*=UTF-8''%s`, w.name, url.PathEscape(w.name)))
+		w.c.Status(200)
+	}
+	return w.c.Writer.Write(p)
+}
+
+// FsZip streams the folder as a zip archive without compression, for storages implementing driver.ZipDir
+func FsZip(c *gin.Context) {
+	var req ZipReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	user := c.MustGet("user").(*model.User)
+	reqPath, err := user.JoinPath(req.Path)
+	if err != nil {
+		common.ErrorResp(c, err, 403)
+		return
+	}
+	meta, err := op.GetNearestMeta(reqPath)
+	if err != nil {
+		if !errors.Is(errors.Cause(err), errs.MetaNotFound) {
+			common.ErrorResp(c, err, 500, true)
+			return
+		}
+	}
+	c.Set("meta", meta)
+	if !common.CanAccess(user, meta, reqPath, req.Password) {
+		common.ErrorStrResp(c, "password is incorrect or you have no permission", 403)
+		return
+	}
+	name := stdpath.Base(reqPath)
+	if name == "/" {
+		name = "root"
+	}
+	w := &downloadWriter{c: c, name: name + ".zip", contentType: "application/zip"}
+	if err := fs.ZipDir(c, reqPath, common.AccessFilter(user, reqPath, req.Password), w); err != nil && !w.started {
+		common.ErrorResp(c, err, 500)
+	}
+}
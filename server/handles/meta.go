@@ -107,3 +107,38 @@ func GetMeta(c *gin.Context) {
 	}
 	common.SuccessResp(c, meta)
 }
+
+func ExportMetas(c *gin.Context) {
+	metas, err := op.ExportMetas()
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, metas)
+}
+
+type ImportMetasReq struct {
+	Metas    []model.Meta `json:"metas" binding:"required"`
+	Conflict string       `json:"conflict"`
+}
+
+func ImportMetas(c *gin.Context) {
+	var req ImportMetasReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	for _, m := range req.Metas {
+		r, err := validHide(m.Hide)
+		if err != nil {
+			common.ErrorStrResp(c, fmt.Sprintf("%s: %s is illegal: %s", m.Path, r, err.Error()), 400)
+			return
+		}
+	}
+	res, err := op.ImportMetas(req.Metas, req.Conflict)
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	common.SuccessResp(c, res)
+}
@@ -6,13 +6,22 @@ import (
 
 	"github.com/alist-org/alist/v3/internal/conf"
 	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/fs"
 	"github.com/alist-org/alist/v3/internal/model"
 	"github.com/alist-org/alist/v3/internal/op"
 	"github.com/alist-org/alist/v3/server/common"
 	"github.com/gin-gonic/gin"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
+type StorageResp struct {
+	model.Storage
+	Stats   map[string]op.OpStat  `json:"stats"`
+	Details *model.StorageDetails `json:"details,omitempty"`
+}
+
 func ListStorages(c *gin.Context) {
 	var req model.PageReq
 	if err := c.ShouldBind(&req); err != nil {
@@ -116,7 +125,19 @@ func GetStorage(c *gin.Context) {
 		common.ErrorResp(c, err, 500, true)
 		return
 	}
-	common.SuccessResp(c, storage)
+	resp := StorageResp{
+		Storage: *storage,
+		Stats:   op.GetStorageStats(storage.MountPath),
+	}
+	if storageDriver, err := op.GetStorageByMountPath(storage.MountPath); err == nil {
+		details, err := op.GetStorageDetails(c, storageDriver)
+		if err == nil {
+			resp.Details = details
+		} else if !errors.Is(err, errs.NotImplement) {
+			log.Warnf("failed get details of storage [%s]: %+v", storage.MountPath, err)
+		}
+	}
+	common.SuccessResp(c, resp)
 }
 
 func LoadAllStorages(c *gin.Context) {
@@ -150,3 +171,48 @@ func LoadAllStorages(c *gin.Context) {
 	}(storages)
 	common.SuccessResp(c)
 }
+
+// ReencryptStorage starts a task to re-encrypt the objects encrypted with the old key of the storage
+func ReencryptStorage(c *gin.Context) {
+	idStr := c.Query("id")
+	id, err := strconv.Atoi(idStr)
+	if err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	storage, err := db.GetStorageById(uint(id))
+	if err != nil {
+		common.ErrorResp(c, err, 500, true)
+		return
+	}
+	t, err := fs.Reencrypt(c, storage.MountPath)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, gin.H{
+		"task": getTaskInfo(t),
+	})
+}
+
+type MigrateStorageReq struct {
+	SrcPath string `json:"src_path" binding:"required"`
+	DstPath string `json:"dst_path" binding:"required"`
+}
+
+// MigrateStorage starts a task to copy a folder tree to another storage, verifying the SHA1 of every file
+func MigrateStorage(c *gin.Context) {
+	var req MigrateStorageReq
+	if err := c.ShouldBind(&req); err != nil {
+		common.ErrorResp(c, err, 400)
+		return
+	}
+	t, err := fs.Migrate(c, req.SrcPath, req.DstPath)
+	if err != nil {
+		common.ErrorResp(c, err, 500)
+		return
+	}
+	common.SuccessResp(c, gin.H{
+		"task": getTaskInfo(t),
+	})
+}
@@ -215,6 +215,15 @@ func taskRoute[T task.TaskExtensionInfo](g *gin.RouterGroup, manager task.Manage
 	})
 }
 
+// GetTempUsage report the staging files currently held by uploads in the temp dir
+func GetTempUsage(c *gin.Context) {
+	count, size := utils.TempFileUsage()
+	common.SuccessResp(c, gin.H{
+		"count": count,
+		"size":  size,
+	})
+}
+
 func SetupTaskRoute(g *gin.RouterGroup) {
 	taskRoute(g.Group("/upload"), fs.UploadTaskManager)
 	taskRoute(g.Group("/copy"), fs.CopyTaskManager)
@@ -222,4 +231,18 @@ func SetupTaskRoute(g *gin.RouterGroup) {
 	taskRoute(g.Group("/offline_download_transfer"), tool.TransferTaskManager)
 	taskRoute(g.Group("/decompress"), fs.ArchiveDownloadTaskManager)
 	taskRoute(g.Group("/decompress_upload"), fs.ArchiveContentUploadTaskManager)
+	taskRoute(g.Group("/hash"), fs.HashTaskManager)
+	taskRoute(g.Group("/reencrypt"), fs.ReencryptTaskManager)
+	taskRoute(g.Group("/migrate"), fs.MigrateTaskManager)
+	taskRoute(g.Group("/sync"), fs.SyncTaskManager)
+	chunkUpload := g.Group("/chunk_upload")
+	taskRoute(chunkUpload, fs.ChunkUploadTaskManager)
+	chunkUpload.POST("/pause", getTargetedHandler(fs.ChunkUploadTaskManager, func(c *gin.Context, task *fs.ChunkUploadTask) {
+		task.Pause()
+		common.SuccessResp(c)
+	}))
+	chunkUpload.POST("/resume", getTargetedHandler(fs.ChunkUploadTaskManager, func(c *gin.Context, task *fs.ChunkUploadTask) {
+		task.Resume()
+		common.SuccessResp(c)
+	}))
 }
@@ -0,0 +1,129 @@
+package handles
+
+import (
+	"io"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/fs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/offline_download/tool"
+	"github.com/alist-org/alist/v3/internal/search"
+	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/alist-org/alist/v3/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// taskEventsInterval is how often the task managers are checked for changes
+const taskEventsInterval = time.Second
+
+// TaskEvent is the payload of the "task" and "remove" events, Type is the
+// task group the task belongs to, the same as its route under /api/task
+type TaskEvent struct {
+	Type string    `json:"type"`
+	Task *TaskInfo `json:"task,omitempty"`
+	ID   string    `json:"id,omitempty"`
+}
+
+type taskSource struct {
+	typ   string
+	infos func(isAdmin bool, uid uint) []TaskInfo
+}
+
+func newTaskSource[T task.TaskExtensionInfo](typ string, manager task.Manager[T]) taskSource {
+	return taskSource{
+		typ: typ,
+		infos: func(isAdmin bool, uid uint) []TaskInfo {
+			return getTaskInfos(manager.GetByCondition(func(task T) bool {
+				return isAdmin || uid == task.GetCreator().ID
+			}))
+		},
+	}
+}
+
+func taskSources() []taskSource {
+	return []taskSource{
+		newTaskSource("upload", fs.UploadTaskManager),
+		newTaskSource("copy", fs.CopyTaskManager),
+		newTaskSource("offline_download", tool.DownloadTaskManager),
+		newTaskSource("offline_download_transfer", tool.TransferTaskManager),
+		newTaskSource("decompress", fs.ArchiveDownloadTaskManager),
+		newTaskSource("decompress_upload", fs.ArchiveContentUploadTaskManager),
+		newTaskSource("hash", fs.HashTaskManager),
+		newTaskSource("reencrypt", fs.ReencryptTaskManager),
+		newTaskSource("migrate", fs.MigrateTaskManager),
+		newTaskSource("sync", fs.SyncTaskManager),
+		newTaskSource("chunk_upload", fs.ChunkUploadTaskManager),
+	}
+}
+
+// TaskEvents streams the progress of all tasks the user can see as server-sent events.
+// All current tasks are sent on connect, after that a "task" event is sent whenever a
+// task changes and a "remove" event when it is removed. Admins also get "index" events
+// with the progress of the search index.
+func TaskEvents(c *gin.Context) {
+	isAdmin, uid, ok := getUserInfo(c)
+	if !ok {
+		// if there is no bug, here is unreachable
+		common.ErrorStrResp(c, "user invalid", 401)
+		return
+	}
+	sources := taskSources()
+	sent := make(map[string]TaskInfo)
+	var lastIndex *model.IndexProgress
+	ticker := time.NewTicker(taskEventsInterval)
+	defer ticker.Stop()
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.Stream(func(w io.Writer) bool {
+		seen := make(map[string]struct{}, len(sent))
+		for _, s := range sources {
+			for _, info := range s.infos(isAdmin, uid) {
+				seen[info.ID] = struct{}{}
+				if last, ok := sent[info.ID]; ok && taskInfoEqual(last, info) {
+					continue
+				}
+				sent[info.ID] = info
+				c.SSEvent("task", TaskEvent{Type: s.typ, Task: &info})
+			}
+		}
+		for id := range sent {
+			if _, ok := seen[id]; !ok {
+				delete(sent, id)
+				c.SSEvent("remove", TaskEvent{ID: id})
+			}
+		}
+		if isAdmin {
+			if p, err := search.Progress(); err == nil && !indexProgressEqual(lastIndex, p) {
+				lastIndex = p
+				c.SSEvent("index", p)
+			}
+		}
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-ticker.C:
+			return true
+		}
+	})
+}
+
+func taskInfoEqual(a, b TaskInfo) bool {
+	return a.State == b.State && a.Status == b.Status && a.Progress == b.Progress &&
+		a.TotalBytes == b.TotalBytes && a.Error == b.Error &&
+		timeEqual(a.StartTime, b.StartTime) && timeEqual(a.EndTime, b.EndTime)
+}
+
+func indexProgressEqual(a, b *model.IndexProgress) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.ObjCount == b.ObjCount && a.IsDone == b.IsDone && a.Error == b.Error &&
+		timeEqual(a.LastDoneTime, b.LastDoneTime)
+}
+
+func timeEqual(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
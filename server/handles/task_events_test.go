@@ -0,0 +1,116 @@
+package handles_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/fs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/offline_download/tool"
+	"github.com/alist-org/alist/v3/internal/search"
+	"github.com/alist-org/alist/v3/internal/task"
+	"github.com/alist-org/alist/v3/server/handles"
+	"github.com/gin-gonic/gin"
+	"github.com/xhofe/tache"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func initTaskManagers() {
+	fs.UploadTaskManager = tache.NewManager[*fs.UploadTask](tache.WithWorks(1))
+	fs.CopyTaskManager = tache.NewManager[*fs.CopyTask](tache.WithWorks(1), tache.WithMaxRetry(0))
+	tool.DownloadTaskManager = tache.NewManager[*tool.DownloadTask](tache.WithWorks(1))
+	tool.TransferTaskManager = tache.NewManager[*tool.TransferTask](tache.WithWorks(1))
+	fs.ArchiveDownloadTaskManager = tache.NewManager[*fs.ArchiveDownloadTask](tache.WithWorks(1))
+	fs.ArchiveContentUploadTaskManager.Manager = tache.NewManager[*fs.ArchiveContentUploadTask](tache.WithWorks(1))
+	fs.HashTaskManager = tache.NewManager[*fs.HashManifestTask](tache.WithWorks(1))
+	fs.ReencryptTaskManager = tache.NewManager[*fs.ReencryptTask](tache.WithWorks(1))
+	fs.MigrateTaskManager = tache.NewManager[*fs.MigrateTask](tache.WithWorks(1))
+	fs.SyncTaskManager = tache.NewManager[*fs.SyncTask](tache.WithWorks(1))
+	fs.ChunkUploadTaskManager = tache.NewManager[*fs.ChunkUploadTask](tache.WithWorks(1))
+}
+
+// streamRecorder is a ResponseRecorder gin can stream to
+type streamRecorder struct {
+	*httptest.ResponseRecorder
+}
+
+func (w streamRecorder) CloseNotify() <-chan bool {
+	return make(chan bool)
+}
+
+func TestTaskEvents(t *testing.T) {
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	db.Init(dB)
+	initTaskManagers()
+	search.WriteProgress(&model.IndexProgress{ObjCount: 3, IsDone: true})
+
+	alice := &model.User{ID: 2, Username: "alice", BasePath: "/"}
+	bob := &model.User{ID: 3, Username: "bob", BasePath: "/"}
+	admin := &model.User{ID: 1, Username: "admin", Role: model.ADMIN, BasePath: "/"}
+	// the copy fails at once as there is no storage, it's kept in the manager anyway
+	copyTask := &fs.CopyTask{TaskExtension: task.TaskExtension{Creator: alice}}
+	fs.CopyTaskManager.Add(copyTask)
+	fs.CopyTaskManager.Wait()
+
+	events := func(user *model.User, timeout time.Duration, during func()) string {
+		gin.SetMode(gin.TestMode)
+		r := gin.New()
+		r.Use(func(c *gin.Context) { c.Set("user", user) })
+		r.GET("/task/events", handles.TaskEvents)
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/task/events", nil).WithContext(ctx)
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			r.ServeHTTP(streamRecorder{w}, req)
+		}()
+		if during != nil {
+			during()
+		}
+		<-done
+		return w.Body.String()
+	}
+	copyEvent := `"type":"copy","task":{"id":"` + copyTask.GetID() + `"`
+
+	// the tasks are sent on connect to the creator and the admins only
+	body := events(alice, 100*time.Millisecond, nil)
+	if !strings.Contains(body, "event:task") || !strings.Contains(body, copyEvent) {
+		t.Fatalf("expect the copy task sent to its creator, got %q", body)
+	}
+	if strings.Contains(body, "event:index") {
+		t.Fatalf("expect no index progress for a user, got %q", body)
+	}
+	if body = events(bob, 100*time.Millisecond, nil); strings.Contains(body, copyTask.GetID()) {
+		t.Fatalf("expect the task of alice hidden from bob, got %q", body)
+	}
+	body = events(admin, 100*time.Millisecond, nil)
+	if !strings.Contains(body, copyEvent) || !strings.Contains(body, `"obj_count":3`) {
+		t.Fatalf("expect the copy task and the index progress sent to the admin, got %q", body)
+	}
+
+	// an unchanged task isn't sent again, a removed one is announced
+	body = events(alice, 1500*time.Millisecond, func() {
+		time.Sleep(300 * time.Millisecond)
+		fs.CopyTaskManager.Remove(copyTask.GetID())
+	})
+	if n := strings.Count(body, copyEvent); n != 1 {
+		t.Fatalf("expect the copy task sent once, got %d times in %q", n, body)
+	}
+	if !strings.Contains(body, "event:remove") || !strings.Contains(body, `"id":"`+copyTask.GetID()+`"}`) {
+		t.Fatalf("expect the removal of the copy task, got %q", body)
+	}
+}
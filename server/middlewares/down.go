@@ -5,8 +5,10 @@ import (
 
 	"github.com/alist-org/alist/v3/internal/conf"
 	"github.com/alist-org/alist/v3/internal/setting"
+	"github.com/alist-org/alist/v3/internal/sign"
 
 	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/fs"
 	"github.com/alist-org/alist/v3/internal/model"
 	"github.com/alist-org/alist/v3/internal/op"
 	"github.com/alist-org/alist/v3/pkg/utils"
@@ -16,6 +18,16 @@ import (
 )
 
 func Down(verifyFunc func(string, string) error) func(c *gin.Context) {
+	return down(verifyFunc, false)
+}
+
+// DownOrShare is Down that also accepts a share token created by the storage of the file in place of the sign,
+// requests with a valid share token are always proxied so the storage's direct link isn't exposed
+func DownOrShare(verifyFunc func(string, string) error) func(c *gin.Context) {
+	return down(verifyFunc, true)
+}
+
+func down(verifyFunc func(string, string) error, allowShare bool) func(c *gin.Context) {
 	return func(c *gin.Context) {
 		rawPath := parsePath(c.Param("path"))
 		c.Set("path", rawPath)
@@ -27,15 +39,34 @@ func Down(verifyFunc func(string, string) error) func(c *gin.Context) {
 			}
 		}
 		c.Set("meta", meta)
-		// verify sign
-		if needSign(meta, rawPath) {
-			s := c.Query("sign")
-			err = verifyFunc(rawPath, strings.TrimSuffix(s, "/"))
+		if share := c.Query("share"); allowShare && share != "" {
+			if err = fs.VerifyShare(c, rawPath, share); err != nil {
+				common.ErrorResp(c, err, 401)
+				c.Abort()
+				return
+			}
+			c.Set("share", true)
+			c.Next()
+			return
+		}
+		// verify sign, the request of a sign carrying a user is served for the user
+		s := strings.TrimSuffix(c.Query("sign"), "/")
+		if needSign(meta, rawPath) || sign.IsScoped(s) {
+			username, err := sign.VerifyScoped(rawPath, s, verifyFunc)
 			if err != nil {
 				common.ErrorResp(c, err, 401)
 				c.Abort()
 				return
 			}
+			if username != "" {
+				user, err := op.GetUserByName(username)
+				if err != nil || user.Disabled {
+					common.ErrorStrResp(c, "the user of the sign is not available", 401)
+					c.Abort()
+					return
+				}
+				c.Set("user", user)
+			}
 		}
 		c.Next()
 	}
@@ -54,6 +85,10 @@ func needSign(meta *model.Meta, path string) bool {
 	if common.IsStorageSignEnabled(path) {
 		return true
 	}
+	// the links to a storage showing different trees to different users are signed for the user
+	if common.IsUserScoped(path) {
+		return true
+	}
 	if meta == nil || meta.Password == "" {
 		return false
 	}
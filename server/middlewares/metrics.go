@@ -0,0 +1,26 @@
+package middlewares
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/server/common"
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsAuth checks the bearer token of the metrics scraper if metrics.token is set
+func MetricsAuth(c *gin.Context) {
+	token := conf.Conf.Metrics.Token
+	if token == "" {
+		c.Next()
+		return
+	}
+	got := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+		common.ErrorStrResp(c, "invalid metrics token", 401)
+		c.Abort()
+		return
+	}
+	c.Next()
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/alist-org/alist/v3/server/static"
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 )
 
 func Init(e *gin.Engine) {
@@ -32,6 +33,9 @@ func Init(e *gin.Engine) {
 	g.GET("/favicon.ico", handles.Favicon)
 	g.GET("/robots.txt", handles.Robots)
 	g.GET("/i/:link_name", handles.Plist)
+	if conf.Conf.Metrics.Enable {
+		g.GET("/metrics", middlewares.MetricsAuth, gin.WrapH(promhttp.Handler()))
+	}
 	common.SecretKey = []byte(conf.Conf.JwtSecret)
 	g.Use(middlewares.StoragesLoaded)
 	if conf.Conf.MaxConnections > 0 {
@@ -41,7 +45,7 @@ func Init(e *gin.Engine) {
 	S3(g.Group("/s3"))
 
 	downloadLimiter := middlewares.DownloadRateLimiter(stream.ClientDownloadLimit)
-	signCheck := middlewares.Down(sign.Verify)
+	signCheck := middlewares.DownOrShare(sign.Verify)
 	g.GET("/d/*path", signCheck, downloadLimiter, handles.Down)
 	g.GET("/p/*path", signCheck, downloadLimiter, handles.Proxy)
 	g.HEAD("/d/*path", signCheck, handles.Down)
@@ -108,6 +112,8 @@ func admin(g *gin.RouterGroup) {
 	meta.POST("/create", handles.CreateMeta)
 	meta.POST("/update", handles.UpdateMeta)
 	meta.POST("/delete", handles.DeleteMeta)
+	meta.GET("/export", handles.ExportMetas)
+	meta.POST("/import", handles.ImportMetas)
 
 	user := g.Group("/user")
 	user.GET("/list", handles.ListUsers)
@@ -129,6 +135,15 @@ func admin(g *gin.RouterGroup) {
 	storage.POST("/enable", handles.EnableStorage)
 	storage.POST("/disable", handles.DisableStorage)
 	storage.POST("/load_all", handles.LoadAllStorages)
+	storage.POST("/reencrypt", handles.ReencryptStorage)
+	storage.POST("/migrate", handles.MigrateStorage)
+
+	syncJob := g.Group("/sync")
+	syncJob.GET("/list", handles.ListSyncJobs)
+	syncJob.POST("/create", handles.CreateSyncJob)
+	syncJob.POST("/update", handles.UpdateSyncJob)
+	syncJob.POST("/delete", handles.DeleteSyncJob)
+	syncJob.POST("/run", handles.RunSyncJob)
 
 	driver := g.Group("/driver")
 	driver.GET("/list", handles.ListDriverInfo)
@@ -150,6 +165,7 @@ func admin(g *gin.RouterGroup) {
 
 	// retain /admin/task API to ensure compatibility with legacy automation scripts
 	_task(g.Group("/task"))
+	g.GET("/temp_usage", handles.GetTempUsage)
 
 	ms := g.Group("/message")
 	ms.POST("/get", message.HttpInstance.GetHandle)
@@ -178,9 +194,17 @@ func _fs(g *gin.RouterGroup) {
 	g.POST("/copy", handles.FsCopy)
 	g.POST("/remove", handles.FsRemove)
 	g.POST("/remove_empty_directory", handles.FsRemoveEmptyDirectory)
+	g.POST("/hash_manifest", handles.FsHashManifest)
+	g.GET("/zip", handles.FsZip)
+	g.GET("/export", handles.FsExport)
 	uploadLimiter := middlewares.UploadRateLimiter(stream.ClientUploadLimit)
 	g.PUT("/put", middlewares.FsUp, uploadLimiter, handles.FsStream)
 	g.PUT("/form", middlewares.FsUp, uploadLimiter, handles.FsForm)
+	us := g.Group("/upload_session", middlewares.FsUp)
+	us.POST("", handles.FsUploadSessionCreate)
+	us.GET("", handles.FsUploadSessionGet)
+	us.PUT("/part", uploadLimiter, handles.FsUploadSessionPart)
+	us.POST("/complete", handles.FsUploadSessionComplete)
 	g.POST("/link", middlewares.AuthAdmin, handles.Link)
 	// g.POST("/add_aria2", handles.AddOfflineDownload)
 	// g.POST("/add_qbit", handles.AddQbittorrent)
@@ -194,6 +218,7 @@ func _fs(g *gin.RouterGroup) {
 
 func _task(g *gin.RouterGroup) {
 	handles.SetupTaskRoute(g)
+	g.GET("/events", handles.TaskEvents)
 }
 
 func Cors(r *gin.Engine) {
@@ -22,8 +22,9 @@ var handler *webdav.Handler
 
 func WebDav(dav *gin.RouterGroup) {
 	handler = &webdav.Handler{
-		Prefix:     path.Join(conf.URL.Path, "/dav"),
-		LockSystem: webdav.NewMemLS(),
+		Prefix:       path.Join(conf.URL.Path, "/dav"),
+		LockSystem:   webdav.NewMemLS(),
+		StorageLocks: webdav.NewStorageLS(),
 		Logger: func(request *http.Request, err error) {
 			log.Errorf("%s %s %+v", request.Method, request.URL.Path, err)
 		},
@@ -102,7 +103,7 @@ func WebDAVAuth(c *gin.Context) {
 		c.Abort()
 		return
 	}
-	if (c.Request.Method == "PUT" || c.Request.Method == "MKCOL") && (!user.CanWebdavManage() || !user.CanWrite()) {
+	if (c.Request.Method == "PUT" || c.Request.Method == "PATCH" || c.Request.Method == "MKCOL") && (!user.CanWebdavManage() || !user.CanWrite()) {
 		c.Status(http.StatusForbidden)
 		c.Abort()
 		return
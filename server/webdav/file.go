@@ -88,10 +88,17 @@ func walkFS(ctx context.Context, depth int, name string, info model.Obj, walkFn
 	if !info.IsDir() || depth == 0 {
 		return nil
 	}
+	meta, _ := op.GetNearestMeta(name)
 	if depth == 1 {
-		depth = 0
+		// the children aren't walked into, so they're written as they're read from the storage
+		return fs.ListStream(context.WithValue(ctx, "meta", meta), name, &fs.ListArgs{}, func(obj model.Obj) error {
+			err := walkFn(path.Join(name, obj.GetName()), obj, nil)
+			if err != nil && (!obj.IsDir() || err != filepath.SkipDir) {
+				return err
+			}
+			return nil
+		})
 	}
-	meta, _ := op.GetNearestMeta(name)
 	// Read directory names.
 	objs, err := fs.List(context.WithValue(ctx, "meta", meta), name, &fs.ListArgs{})
 	//f, err := fs.OpenFile(ctx, name, os.O_RDONLY, 0)
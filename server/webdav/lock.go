@@ -109,6 +109,9 @@ type LockDetails struct {
 	// ZeroDepth is whether the lock has zero depth. If it does not have zero
 	// depth, it has infinite depth.
 	ZeroDepth bool
+	// temporary is whether the lock is taken by a request without an If header,
+	// it's unlocked at the end of the request.
+	temporary bool
 }
 
 // NewMemLS returns a new in-memory LockSystem.
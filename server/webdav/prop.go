@@ -17,6 +17,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alist-org/alist/v3/internal/fs"
 	"github.com/alist-org/alist/v3/internal/model"
 	"github.com/alist-org/alist/v3/server/common"
 )
@@ -109,6 +110,9 @@ var liveProps = map[xml.Name]struct {
 	findFn func(context.Context, LockSystem, string, model.Obj) (string, error)
 	// dir is true if the property applies to directories.
 	dir bool
+	// explicit is true if the property is only returned when it is named
+	// in the request, like the quota properties of RFC 4331.
+	explicit bool
 }{
 	{Space: "DAV:", Local: "resourcetype"}: {
 		findFn: findResourceType,
@@ -165,6 +169,16 @@ var liveProps = map[xml.Name]struct {
 		findFn: findChecksums,
 		dir:    false,
 	},
+	{Space: "DAV:", Local: "quota-used-bytes"}: {
+		findFn:   findQuotaUsedBytes,
+		dir:      true,
+		explicit: true,
+	},
+	{Space: "DAV:", Local: "quota-available-bytes"}: {
+		findFn:   findQuotaAvailableBytes,
+		dir:      true,
+		explicit: true,
+	},
 }
 
 // TODO(nigeltao) merge props and allprop?
@@ -205,6 +219,12 @@ func props(ctx context.Context, ls LockSystem, fi model.Obj, pnames []xml.Name)
 		// Otherwise, it must either be a live property or we don't know it.
 		if prop := liveProps[pn]; prop.findFn != nil && (prop.dir || !isDir) {
 			innerXML, err := prop.findFn(ctx, ls, fi.GetName(), fi)
+			if errors.Is(err, ErrNotImplemented) {
+				pstatNotFound.Props = append(pstatNotFound.Props, Property{
+					XMLName: pn,
+				})
+				continue
+			}
 			if err != nil {
 				return nil, err
 			}
@@ -245,7 +265,7 @@ func propnames(ctx context.Context, ls LockSystem, fi model.Obj) ([]xml.Name, er
 
 	pnames := make([]xml.Name, 0, len(liveProps)+len(deadProps))
 	for pn, prop := range liveProps {
-		if prop.findFn != nil && (prop.dir || !isDir) {
+		if prop.findFn != nil && !prop.explicit && (prop.dir || !isDir) {
 			pnames = append(pnames, pn)
 		}
 	}
@@ -496,3 +516,37 @@ func findChecksums(ctx context.Context, ls LockSystem, name string, fi model.Obj
 	}
 	return checksums, nil
 }
+
+// findQuotaUsedBytes reports the space used by the whole storage holding the
+// resource, or ErrNotImplemented if the storage can't report it.
+func findQuotaUsedBytes(ctx context.Context, ls LockSystem, name string, fi model.Obj) (string, error) {
+	details, err := storageDetails(ctx)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(details.UsedSpace, 10), nil
+}
+
+func findQuotaAvailableBytes(ctx context.Context, ls LockSystem, name string, fi model.Obj) (string, error) {
+	details, err := storageDetails(ctx)
+	if err != nil {
+		return "", err
+	}
+	if details.TotalSpace <= 0 {
+		return "", ErrNotImplemented
+	}
+	return strconv.FormatInt(max(details.TotalSpace-details.UsedSpace, 0), 10), nil
+}
+
+func storageDetails(ctx context.Context) (*model.StorageDetails, error) {
+	reqPath, ok := ctx.Value("reqPath").(string)
+	if !ok {
+		return nil, ErrNotImplemented
+	}
+	details, err := fs.GetStorageDetails(ctx, reqPath)
+	if err != nil {
+		// virtual folders and storages without details have no quota
+		return nil, ErrNotImplemented
+	}
+	return details, nil
+}
@@ -0,0 +1,76 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// detailsDriver is a storage reporting its space usage
+type detailsDriver struct {
+	plainDriver
+}
+
+func (d *detailsDriver) Config() driver.Config {
+	return driver.Config{Name: "DetailsTest", NoCache: true}
+}
+
+func (d *detailsDriver) GetRoot(ctx context.Context) (model.Obj, error) {
+	return &model.Object{ID: "/", Name: "root", IsFolder: true}, nil
+}
+
+func (d *detailsDriver) GetDetails(ctx context.Context) (*model.StorageDetails, error) {
+	return &model.StorageDetails{DiskUsage: model.DiskUsage{TotalSpace: 1000, UsedSpace: 300, ObjectCount: 3}}, nil
+}
+
+func TestQuotaProps(t *testing.T) {
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	db.Init(dB)
+	op.RegisterDriver(func() driver.Driver { return &detailsDriver{} })
+	if _, err := op.CreateStorage(context.Background(), model.Storage{Driver: "DetailsTest", MountPath: "/quota", Addition: "{}"}); err != nil {
+		t.Fatal(err)
+	}
+
+	h := &Handler{LockSystem: NewMemLS(), StorageLocks: NewStorageLS()}
+	admin := &model.User{Username: "admin", Role: model.ADMIN, BasePath: "/"}
+	propfind := func(body string) string {
+		r := httptest.NewRequest("PROPFIND", "/quota", strings.NewReader(body))
+		r.Header.Set("Depth", "0")
+		r = r.WithContext(context.WithValue(r.Context(), "user", admin))
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		h.ServeHTTP(c.Writer, r)
+		if w.Code != http.StatusMultiStatus {
+			t.Fatalf("PROPFIND: %d %s", w.Code, w.Body.String())
+		}
+		return w.Body.String()
+	}
+
+	body := propfind(`<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:prop>` +
+		`<D:quota-used-bytes/><D:quota-available-bytes/></D:prop></D:propfind>`)
+	for _, want := range []string{"quota-used-bytes>300<", "quota-available-bytes>700<"} {
+		if !strings.Contains(body, want) {
+			t.Fatalf("expect %s in %s", want, body)
+		}
+	}
+	// the quota is only returned when it's named
+	if body = propfind(`<?xml version="1.0"?><D:propfind xmlns:D="DAV:"><D:allprop/></D:propfind>`); strings.Contains(body, "quota-used-bytes") {
+		t.Fatalf("expect no quota in allprop, got %s", body)
+	}
+}
@@ -0,0 +1,278 @@
+package webdav
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/google/uuid"
+	pkgerr "github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// maxStorageLockDuration caps the locks kept by the storages, including the infinite ones
+	maxStorageLockDuration = 24 * time.Hour
+	// temporaryLockDuration is the expiry of the temporary locks of the requests kept by the storages,
+	// refreshed while the request runs, so that the locks of a request interrupted by a crash
+	// don't stay long
+	temporaryLockDuration = time.Minute
+	// storageTokenPrefix starts the tokens of the locks kept by the storages,
+	// the token ends with the id of the storage: opaquelocktoken:<uuid>/<storage id>
+	storageTokenPrefix = "opaquelocktoken:"
+)
+
+// NewStorageLS returns a LockSystem keeping the locks of the paths on the storages implementing
+// driver.Locker in the storages, the names are the paths joined with the base path of the user.
+// The locks kept by a storage are kept across restarts and shared by the servers using it.
+// It's the StorageLocks of Handler, the other paths are locked by its LockSystem.
+func NewStorageLS() LockSystem {
+	return &storageLS{
+		state: &storageLockState{held: make(map[string]bool), temporary: make(map[string]chan struct{})},
+		ctx:   context.Background(),
+	}
+}
+
+type storageLS struct {
+	state *storageLockState
+	// ctx is the context of the request, the storages keep the locks of its user
+	ctx context.Context
+}
+
+type storageLockState struct {
+	mu sync.Mutex
+	// held is the tokens of the storage locks confirmed by the requests in progress
+	held map[string]bool
+	// temporary stops refreshing the temporary locks of the requests in progress
+	temporary map[string]chan struct{}
+}
+
+// withContext returns the LockSystem for the request of ctx, sharing the locks of s
+func (s *storageLS) withContext(ctx context.Context) LockSystem {
+	return &storageLS{state: s.state, ctx: ctx}
+}
+
+// locker returns the storage of name if it keeps the locks, and the path in it
+func locker(name string) (driver.Driver, string, bool) {
+	storage, actualPath, err := op.GetStorageAndActualPath(name)
+	if err != nil {
+		return nil, "", false
+	}
+	_, ok := storage.(driver.Locker)
+	return storage, actualPath, ok
+}
+
+func storageToken(storage driver.Driver) string {
+	return fmt.Sprintf("%s%s/%d", storageTokenPrefix, uuid.NewString(), storage.GetStorage().ID)
+}
+
+// findLock returns the storage keeping the lock of token by the id in the token,
+// nil if it's not kept by a storage
+func findLock(token string) driver.Driver {
+	rest, ok := strings.CutPrefix(token, storageTokenPrefix)
+	if !ok {
+		return nil
+	}
+	id, err := strconv.ParseUint(rest[strings.LastIndex(rest, "/")+1:], 10, 64)
+	if err != nil {
+		return nil
+	}
+	for _, storage := range op.GetAllStorages() {
+		if _, ok := storage.(driver.Locker); ok && storage.GetStorage().ID == uint(id) {
+			return storage
+		}
+	}
+	return nil
+}
+
+func lockExpires(now time.Time, duration time.Duration) time.Time {
+	if duration < 0 || duration > maxStorageLockDuration {
+		duration = maxStorageLockDuration
+	}
+	return now.Add(duration)
+}
+
+func lockDetails(storage driver.Driver, lock *model.Lock, duration time.Duration) LockDetails {
+	return LockDetails{
+		Root:      path.Join(storage.GetStorage().MountPath, lock.Path),
+		Duration:  duration,
+		OwnerXML:  lock.OwnerXML,
+		ZeroDepth: lock.ZeroDepth,
+	}
+}
+
+func (s *storageLS) Confirm(now time.Time, name0, name1 string, conditions ...Condition) (func(), error) {
+	var tokens []string
+	for _, name := range []string{name0, name1} {
+		if name == "" {
+			continue
+		}
+		storage, p, ok := locker(name)
+		if !ok {
+			return nil, ErrConfirmationFailed
+		}
+		token := s.lookupStorageLock(storage.(driver.Locker), p, conditions)
+		if token == "" {
+			return nil, ErrConfirmationFailed
+		}
+		if len(tokens) == 0 || tokens[0] != token {
+			tokens = append(tokens, token)
+		}
+	}
+	if !s.hold(tokens) {
+		return nil, ErrConfirmationFailed
+	}
+	return func() {
+		s.unhold(tokens)
+	}, nil
+}
+
+// lookupStorageLock returns the token of the conditions whose lock covers p
+func (s *storageLS) lookupStorageLock(l driver.Locker, p string, conditions []Condition) string {
+	for _, c := range conditions {
+		if c.Token == "" || c.Not {
+			continue
+		}
+		lock, err := l.GetLock(s.ctx, c.Token)
+		if err != nil {
+			continue
+		}
+		if p == lock.Path || !lock.ZeroDepth && (lock.Path == "/" || strings.HasPrefix(p, lock.Path+"/")) {
+			return c.Token
+		}
+	}
+	return ""
+}
+
+func (s *storageLS) hold(tokens []string) bool {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	for _, token := range tokens {
+		if s.state.held[token] {
+			return false
+		}
+	}
+	for _, token := range tokens {
+		s.state.held[token] = true
+	}
+	return true
+}
+
+func (s *storageLS) unhold(tokens []string) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	for _, token := range tokens {
+		delete(s.state.held, token)
+	}
+}
+
+func (s *storageLS) isHeld(token string) bool {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	return s.state.held[token]
+}
+
+// keepTemporary refreshes the temporary lock until it's unlocked
+func (s *storageLS) keepTemporary(l driver.Locker, token string) {
+	stop := make(chan struct{})
+	s.state.mu.Lock()
+	s.state.temporary[token] = stop
+	s.state.mu.Unlock()
+	go func() {
+		ticker := time.NewTicker(temporaryLockDuration / 3)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case now := <-ticker.C:
+				if _, err := l.RefreshLock(s.ctx, token, now.Add(temporaryLockDuration)); err != nil {
+					log.Warnf("failed to refresh the temporary lock %s: %+v", token, err)
+					return
+				}
+			}
+		}
+	}()
+}
+
+func (s *storageLS) stopTemporary(token string) {
+	s.state.mu.Lock()
+	defer s.state.mu.Unlock()
+	if stop, ok := s.state.temporary[token]; ok {
+		close(stop)
+		delete(s.state.temporary, token)
+	}
+}
+
+func (s *storageLS) Create(now time.Time, details LockDetails) (string, error) {
+	storage, p, ok := locker(details.Root)
+	if !ok {
+		return "", fmt.Errorf("webdav: %s is not on a storage keeping the locks", details.Root)
+	}
+	l := storage.(driver.Locker)
+	token := storageToken(storage)
+	expires := lockExpires(now, details.Duration)
+	if details.temporary {
+		expires = now.Add(temporaryLockDuration)
+	}
+	err := l.CreateLock(s.ctx, model.Lock{
+		Token:     token,
+		Path:      p,
+		ZeroDepth: details.ZeroDepth,
+		OwnerXML:  details.OwnerXML,
+		Expires:   expires,
+	})
+	if errors.Is(pkgerr.Cause(err), errs.Locked) {
+		return "", ErrLocked
+	}
+	if err != nil {
+		return "", err
+	}
+	if details.temporary {
+		s.keepTemporary(l, token)
+	}
+	return token, nil
+}
+
+func (s *storageLS) Refresh(now time.Time, token string, duration time.Duration) (LockDetails, error) {
+	storage := findLock(token)
+	if storage == nil {
+		return LockDetails{}, ErrNoSuchLock
+	}
+	if s.isHeld(token) {
+		return LockDetails{}, ErrLocked
+	}
+	lock, err := storage.(driver.Locker).RefreshLock(s.ctx, token, lockExpires(now, duration))
+	if errs.IsObjectNotFound(err) {
+		return LockDetails{}, ErrNoSuchLock
+	}
+	if err != nil {
+		return LockDetails{}, err
+	}
+	return lockDetails(storage, lock, duration), nil
+}
+
+func (s *storageLS) Unlock(now time.Time, token string) error {
+	storage := findLock(token)
+	if storage == nil {
+		return ErrNoSuchLock
+	}
+	if s.isHeld(token) {
+		return ErrLocked
+	}
+	s.stopTemporary(token)
+	err := storage.(driver.Locker).DeleteLock(s.ctx, token)
+	if errs.IsObjectNotFound(err) {
+		return ErrNoSuchLock
+	}
+	return err
+}
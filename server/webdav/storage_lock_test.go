@@ -0,0 +1,175 @@
+package webdav
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alist-org/alist/v3/internal/conf"
+	"github.com/alist-org/alist/v3/internal/db"
+	"github.com/alist-org/alist/v3/internal/driver"
+	"github.com/alist-org/alist/v3/internal/errs"
+	"github.com/alist-org/alist/v3/internal/model"
+	"github.com/alist-org/alist/v3/internal/op"
+	"github.com/gin-gonic/gin"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// plainDriver is a storage keeping no locks
+type plainDriver struct {
+	model.Storage
+	Addition struct{}
+}
+
+func (d *plainDriver) Config() driver.Config {
+	return driver.Config{Name: "PlainLockTest", NoCache: true}
+}
+
+func (d *plainDriver) GetAddition() driver.Additional {
+	return &d.Addition
+}
+
+func (d *plainDriver) Init(ctx context.Context) error {
+	return nil
+}
+
+func (d *plainDriver) Drop(ctx context.Context) error {
+	return nil
+}
+
+func (d *plainDriver) List(ctx context.Context, dir model.Obj, args model.ListArgs) ([]model.Obj, error) {
+	return nil, nil
+}
+
+func (d *plainDriver) Link(ctx context.Context, file model.Obj, args model.LinkArgs) (*model.Link, error) {
+	return nil, errs.NotImplement
+}
+
+// lockerDriver is a storage keeping the locks in memory
+type lockerDriver struct {
+	plainDriver
+	mu    sync.Mutex
+	locks map[string]model.Lock
+}
+
+func (d *lockerDriver) Config() driver.Config {
+	return driver.Config{Name: "LockerTest", NoCache: true}
+}
+
+func (d *lockerDriver) CreateLock(ctx context.Context, lock model.Lock) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, l := range d.locks {
+		if l.Path == lock.Path {
+			return errs.Locked
+		}
+	}
+	d.locks[lock.Token] = lock
+	return nil
+}
+
+func (d *lockerDriver) GetLock(ctx context.Context, token string) (*model.Lock, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	l, ok := d.locks[token]
+	if !ok {
+		return nil, errs.ObjectNotFound
+	}
+	return &l, nil
+}
+
+func (d *lockerDriver) RefreshLock(ctx context.Context, token string, expires time.Time) (*model.Lock, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	l, ok := d.locks[token]
+	if !ok {
+		return nil, errs.ObjectNotFound
+	}
+	l.Expires = expires
+	d.locks[token] = l
+	return &l, nil
+}
+
+func (d *lockerDriver) DeleteLock(ctx context.Context, token string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if _, ok := d.locks[token]; !ok {
+		return errs.ObjectNotFound
+	}
+	delete(d.locks, token)
+	return nil
+}
+
+func TestStorageLocksOnlyForLockers(t *testing.T) {
+	dB, err := gorm.Open(sqlite.Open("file::memory:"), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	conf.Conf = conf.DefaultConfig()
+	db.Init(dB)
+	locker := &lockerDriver{locks: map[string]model.Lock{}}
+	op.RegisterDriver(func() driver.Driver { return locker })
+	op.RegisterDriver(func() driver.Driver { return &plainDriver{} })
+	for _, s := range []model.Storage{
+		{Driver: "LockerTest", MountPath: "/locked", Addition: "{}"},
+		{Driver: "PlainLockTest", MountPath: "/plain", Addition: "{}"},
+	} {
+		if _, err := op.CreateStorage(context.Background(), s); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h := &Handler{LockSystem: NewMemLS(), StorageLocks: NewStorageLS()}
+	admin := &model.User{Username: "admin", Role: model.ADMIN, BasePath: "/"}
+	lock := func(user *model.User, p string) string {
+		body := `<?xml version="1.0"?><D:lockinfo xmlns:D="DAV:"><D:lockscope><D:exclusive/></D:lockscope>` +
+			`<D:locktype><D:write/></D:locktype><D:owner>me</D:owner></D:lockinfo>`
+		r := httptest.NewRequest("LOCK", p, strings.NewReader(body))
+		r = r.WithContext(context.WithValue(r.Context(), "user", user))
+		// the handler is served by gin, which writes 200 for the status 0 of the buffered writer
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		h.ServeHTTP(c.Writer, r)
+		if w.Code != http.StatusOK {
+			t.Fatalf("LOCK %s: %d %s", p, w.Code, w.Body.String())
+		}
+		return strings.Trim(w.Header().Get("Lock-Token"), "<>")
+	}
+
+	// the paths on a Locker storage are locked by the storage
+	token := lock(admin, "/locked/a.txt")
+	if !strings.HasPrefix(token, storageTokenPrefix) {
+		t.Fatalf("expect a storage lock, got %s", token)
+	}
+	if l, err := locker.GetLock(context.Background(), token); err != nil || l.Path != "/a.txt" {
+		t.Fatalf("expect the lock kept by the storage, got %+v %v", l, err)
+	}
+	// the other paths are locked in memory as before
+	token = lock(admin, "/plain/a.txt")
+	if strings.HasPrefix(token, storageTokenPrefix) || len(locker.locks) != 1 {
+		t.Fatalf("expect a lock in memory, got %s", token)
+	}
+	release, err := h.LockSystem.Confirm(time.Now(), "/plain/a.txt", "", Condition{Token: token})
+	if err != nil {
+		t.Fatalf("expect the lock in memory, got %v", err)
+	}
+	release()
+
+	// the names of the memory locks are kept as they're given, only the storage paths are joined
+	user := &model.User{Username: "alice", BasePath: "/locked"}
+	r := httptest.NewRequest("PUT", "/a.txt", nil)
+	r = r.WithContext(context.WithValue(r.Context(), "user", user))
+	if ls, name := h.lockSystem(r, "/a.txt", false); ls != h.StorageLocks || name != "/locked/a.txt" {
+		t.Fatalf("expect the storage lock of /locked/a.txt, got %s", name)
+	}
+	user.BasePath = "/plain"
+	if ls, name := h.lockSystem(r, "/a.txt", false); ls != h.LockSystem || name != "/a.txt" {
+		t.Fatalf("expect the memory lock of /a.txt, got %s", name)
+	}
+}
@@ -1,10 +1,13 @@
 package webdav
 
 import (
-	log "github.com/sirupsen/logrus"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/alist-org/alist/v3/pkg/utils"
+	log "github.com/sirupsen/logrus"
 )
 
 func (h *Handler) getModTime(r *http.Request) time.Time {
@@ -32,3 +35,30 @@ func (h *Handler) getHeaderTime(r *http.Request, header, alternative string) tim
 	}
 	return time.Now()
 }
+
+// getHashInfo parse checksums sent by owncloud/nextcloud clients, e.g. "SHA1:xxx MD5:yyy"
+// so that drivers can reuse them instead of hashing the stream again
+func (h *Handler) getHashInfo(r *http.Request) utils.HashInfo {
+	hashes := make(map[*utils.HashType]string)
+	for _, checksum := range strings.Fields(r.Header.Get("OC-Checksum")) {
+		name, value, ok := strings.Cut(checksum, ":")
+		if !ok {
+			continue
+		}
+		var ht *utils.HashType
+		switch strings.ToLower(name) {
+		case "md5":
+			ht = utils.MD5
+		case "sha1":
+			ht = utils.SHA1
+		case "sha256":
+			ht = utils.SHA256
+		default:
+			continue
+		}
+		if len(value) == ht.Width {
+			hashes[ht] = strings.ToLower(value)
+		}
+	}
+	return utils.NewHashInfoByMap(hashes)
+}
@@ -13,6 +13,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"time"
 
@@ -22,6 +23,7 @@ import (
 	"github.com/alist-org/alist/v3/internal/fs"
 	"github.com/alist-org/alist/v3/internal/model"
 	"github.com/alist-org/alist/v3/internal/sign"
+	"github.com/alist-org/alist/v3/pkg/http_range"
 	"github.com/alist-org/alist/v3/pkg/utils"
 	"github.com/alist-org/alist/v3/server/common"
 )
@@ -31,6 +33,9 @@ type Handler struct {
 	Prefix string
 	// LockSystem is the lock management system.
 	LockSystem LockSystem
+	// StorageLocks keeps the locks of the paths on the storages implementing driver.Locker,
+	// if nil all the locks are kept by LockSystem.
+	StorageLocks LockSystem
 	// Logger is an optional error logger. If non-nil, it will be called
 	// for all HTTP requests.
 	Logger func(*http.Request, error)
@@ -50,6 +55,10 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	status, err := http.StatusBadRequest, errUnsupportedMethod
 	brw := newBufferedResponseWriter()
 	useBufferedWriter := true
+	if ls, ok := h.StorageLocks.(*storageLS); ok {
+		// the storages keep the locks of the user of the request
+		h = &Handler{Prefix: h.Prefix, LockSystem: h.LockSystem, StorageLocks: ls.withContext(r.Context()), Logger: h.Logger}
+	}
 	if h.LockSystem == nil {
 		status, err = http.StatusInternalServerError, errNoLockSystem
 	} else {
@@ -67,6 +76,8 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			status, err = h.handleDelete(brw, r)
 		case "PUT":
 			status, err = h.handlePut(brw, r)
+		case "PATCH":
+			status, err = h.handlePatch(brw, r)
 		case "MKCOL":
 			status, err = h.handleMkcol(brw, r)
 		case "COPY", "MOVE":
@@ -99,11 +110,41 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-func (h *Handler) lock(now time.Time, root string) (token string, status int, err error) {
-	token, err = h.LockSystem.Create(now, LockDetails{
+// lockSystem returns the LockSystem keeping the locks of name, and the name to lock in it. The paths on the
+// storages implementing driver.Locker are locked by StorageLocks with the path joined with the base path of the
+// user, the other names by LockSystem as they are. joined is whether name is joined with the base path already
+func (h *Handler) lockSystem(r *http.Request, name string, joined bool) (LockSystem, string) {
+	if h.StorageLocks == nil || name == "" {
+		return h.LockSystem, name
+	}
+	full := name
+	if !joined {
+		user := r.Context().Value("user").(*model.User)
+		var err error
+		if full, err = user.JoinPath(name); err != nil {
+			return h.LockSystem, name
+		}
+	}
+	if _, _, ok := locker(full); ok {
+		return h.StorageLocks, full
+	}
+	return h.LockSystem, name
+}
+
+// tokenLockSystem returns the LockSystem keeping the lock of token
+func (h *Handler) tokenLockSystem(token string) LockSystem {
+	if h.StorageLocks != nil && findLock(token) != nil {
+		return h.StorageLocks
+	}
+	return h.LockSystem
+}
+
+func (h *Handler) lock(now time.Time, ls LockSystem, root string) (token string, status int, err error) {
+	token, err = ls.Create(now, LockDetails{
 		Root:      root,
 		Duration:  infiniteTimeout,
 		ZeroDepth: true,
+		temporary: true,
 	})
 	if err != nil {
 		if err == ErrLocked {
@@ -114,7 +155,33 @@ func (h *Handler) lock(now time.Time, root string) (token string, status int, er
 	return token, 0, nil
 }
 
-func (h *Handler) confirmLocks(r *http.Request, src, dst string) (release func(), status int, err error) {
+// confirm confirms name0 in ls0 and name1 in ls1, with a single confirmation if they're kept by the same LockSystem
+func confirm(now time.Time, ls0 LockSystem, name0 string, ls1 LockSystem, name1 string, conditions []Condition) (func(), error) {
+	if name0 == "" || name1 == "" || ls0 == ls1 {
+		if name0 == "" {
+			ls0 = ls1
+		}
+		return ls0.Confirm(now, name0, name1, conditions...)
+	}
+	release0, err := ls0.Confirm(now, name0, "", conditions...)
+	if err != nil {
+		return nil, err
+	}
+	release1, err := ls1.Confirm(now, "", name1, conditions...)
+	if err != nil {
+		release0()
+		return nil, err
+	}
+	return func() {
+		release1()
+		release0()
+	}, nil
+}
+
+// confirmLocks confirms the locks of src and dst, joined is whether they're joined with the base path of the user
+func (h *Handler) confirmLocks(r *http.Request, src, dst string, joined bool) (release func(), status int, err error) {
+	srcLS, srcName := h.lockSystem(r, src, joined)
+	dstLS, dstName := h.lockSystem(r, dst, joined)
 	hdr := r.Header.Get("If")
 	if hdr == "" {
 		// An empty If header means that the client hasn't previously created locks.
@@ -124,16 +191,16 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string) (release func()
 		// locks are unlocked at the end of the HTTP request.
 		now, srcToken, dstToken := time.Now(), "", ""
 		if src != "" {
-			srcToken, status, err = h.lock(now, src)
+			srcToken, status, err = h.lock(now, srcLS, srcName)
 			if err != nil {
 				return nil, status, err
 			}
 		}
 		if dst != "" {
-			dstToken, status, err = h.lock(now, dst)
+			dstToken, status, err = h.lock(now, dstLS, dstName)
 			if err != nil {
 				if srcToken != "" {
-					h.LockSystem.Unlock(now, srcToken)
+					srcLS.Unlock(now, srcToken)
 				}
 				return nil, status, err
 			}
@@ -141,10 +208,10 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string) (release func()
 
 		return func() {
 			if dstToken != "" {
-				h.LockSystem.Unlock(now, dstToken)
+				dstLS.Unlock(now, dstToken)
 			}
 			if srcToken != "" {
-				h.LockSystem.Unlock(now, srcToken)
+				srcLS.Unlock(now, srcToken)
 			}
 		}, 0, nil
 	}
@@ -155,23 +222,22 @@ func (h *Handler) confirmLocks(r *http.Request, src, dst string) (release func()
 	}
 	// ih is a disjunction (OR) of ifLists, so any ifList will do.
 	for _, l := range ih.lists {
-		lsrc := l.resourceTag
-		if lsrc == "" {
-			lsrc = src
-		} else {
-			u, err := url.Parse(lsrc)
+		lsrcLS, lsrc := srcLS, srcName
+		if l.resourceTag != "" {
+			u, err := url.Parse(l.resourceTag)
 			if err != nil {
 				continue
 			}
 			if u.Host != r.Host {
 				continue
 			}
-			lsrc, status, err = h.stripPrefix(u.Path)
+			tag, status, err := h.stripPrefix(u.Path)
 			if err != nil {
 				return nil, status, err
 			}
+			lsrcLS, lsrc = h.lockSystem(r, tag, false)
 		}
-		release, err = h.LockSystem.Confirm(time.Now(), lsrc, dst, l.conditions...)
+		release, err = confirm(time.Now(), lsrcLS, lsrc, dstLS, dstName, l.conditions)
 		if err == ErrConfirmationFailed {
 			continue
 		}
@@ -203,7 +269,7 @@ func (h *Handler) handleOptions(w http.ResponseWriter, r *http.Request) (status
 		if fi.IsDir() {
 			allow = "OPTIONS, LOCK, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND"
 		} else {
-			allow = "OPTIONS, LOCK, GET, HEAD, POST, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND, PUT"
+			allow = "OPTIONS, LOCK, GET, HEAD, POST, DELETE, PROPPATCH, COPY, MOVE, UNLOCK, PROPFIND, PUT, PATCH"
 		}
 	}
 	w.Header().Set("Allow", allow)
@@ -240,7 +306,24 @@ func (h *Handler) handleGetHeadPost(w http.ResponseWriter, r *http.Request) (sta
 	// Let ServeContent determine the Content-Type header.
 	storage, _ := fs.GetStorage(reqPath, &fs.GetStoragesArgs{})
 	downProxyUrl := storage.GetStorage().DownProxyUrl
-	if storage.GetStorage().WebdavNative() || (storage.GetStorage().WebdavProxy() && downProxyUrl == "") {
+	// the download proxy can't serve the tree of the user
+	if common.IsUserScoped(reqPath) {
+		downProxyUrl = ""
+	}
+	if common.NeedWatermark(reqPath) {
+		// the watermark is added only by the proxy of alist
+		link, _, err := fs.Link(ctx, reqPath, model.LinkArgs{Header: r.Header, HttpReq: r})
+		if err != nil {
+			return http.StatusInternalServerError, err
+		}
+		err = common.WatermarkProxy(w, r, link, fi, common.WatermarkText(user, utils.ClientIP(r)))
+		if errors.Is(err, common.ErrWatermarkTooLarge) {
+			return http.StatusForbidden, err
+		}
+		if err != nil {
+			return http.StatusInternalServerError, fmt.Errorf("webdav watermark proxy error: %+v", err)
+		}
+	} else if storage.GetStorage().WebdavNative() || (storage.GetStorage().WebdavProxy() && downProxyUrl == "") {
 		link, _, err := fs.Link(ctx, reqPath, model.LinkArgs{Header: r.Header, HttpReq: r})
 		if err != nil {
 			return http.StatusInternalServerError, err
@@ -274,7 +357,7 @@ func (h *Handler) handleDelete(w http.ResponseWriter, r *http.Request) (status i
 	if err != nil {
 		return status, err
 	}
-	release, status, err := h.confirmLocks(r, reqPath, "")
+	release, status, err := h.confirmLocks(r, reqPath, "", false)
 	if err != nil {
 		return status, err
 	}
@@ -312,24 +395,36 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request) (status int,
 	if reqPath == "" {
 		return http.StatusMethodNotAllowed, nil
 	}
-	release, status, err := h.confirmLocks(r, reqPath, "")
+	release, status, err := h.confirmLocks(r, reqPath, "", false)
 	if err != nil {
 		return status, err
 	}
 	defer release()
-	// TODO(rost): Support the If-Match, If-None-Match headers? See bradfitz'
-	// comments in http.checkEtag.
+
 	ctx := r.Context()
 	user := ctx.Value("user").(*model.User)
 	reqPath, err = user.JoinPath(reqPath)
 	if err != nil {
 		return http.StatusForbidden, err
 	}
+	// TODO(rost): Support the If-Match, If-None-Match headers? See bradfitz'
+	// comments in http.checkEtag.
+	// a segment of the file starting after its head, only appending at the end is supported
+	if contentRange := r.Header.Get("Content-Range"); contentRange != "" {
+		start, _, err := http_range.ParseContentRange(contentRange)
+		if err != nil {
+			return http.StatusBadRequest, err
+		}
+		if start > 0 {
+			return h.appendFile(w, r, reqPath, start)
+		}
+	}
 	obj := model.Object{
 		Name:     path.Base(reqPath),
 		Size:     r.ContentLength,
 		Modified: h.getModTime(r),
 		Ctime:    h.getCreateTime(r),
+		HashInfo: h.getHashInfo(r),
 	}
 	fsStream := &stream.FileStream{
 		Obj:      &obj,
@@ -362,12 +457,93 @@ func (h *Handler) handlePut(w http.ResponseWriter, r *http.Request) (status int,
 	return http.StatusCreated, nil
 }
 
+// handlePatch supports the append of the sabredav partial update,
+// the X-Update-Range header is "append" or "bytes=<start>-" with start being the size of the file
+func (h *Handler) handlePatch(w http.ResponseWriter, r *http.Request) (status int, err error) {
+	reqPath, status, err := h.stripPrefix(r.URL.Path)
+	if err != nil {
+		return status, err
+	}
+	release, status, err := h.confirmLocks(r, reqPath, "", false)
+	if err != nil {
+		return status, err
+	}
+	defer release()
+
+	ctx := r.Context()
+	user := ctx.Value("user").(*model.User)
+	reqPath, err = user.JoinPath(reqPath)
+	if err != nil {
+		return http.StatusForbidden, err
+	}
+	updateRange := r.Header.Get("X-Update-Range")
+	if updateRange == "append" {
+		return h.appendFile(w, r, reqPath, -1)
+	}
+	if !strings.HasPrefix(updateRange, "bytes=") {
+		return http.StatusBadRequest, fmt.Errorf("invalid X-Update-Range: %s", updateRange)
+	}
+	start, err := strconv.ParseInt(strings.TrimSpace(strings.SplitN(updateRange[len("bytes="):], "-", 2)[0]), 10, 64)
+	if err != nil || start < 0 {
+		return http.StatusBadRequest, fmt.Errorf("invalid X-Update-Range: %s", updateRange)
+	}
+	return h.appendFile(w, r, reqPath, start)
+}
+
+// appendFile writes the request body after the end of the file at reqPath,
+// offset is where the client expects the file to end, -1 to append at whatever the size is
+func (h *Handler) appendFile(w http.ResponseWriter, r *http.Request, reqPath string, offset int64) (status int, err error) {
+	ctx := r.Context()
+	if r.ContentLength < 0 {
+		return http.StatusLengthRequired, nil
+	}
+	fi, err := fs.Get(ctx, reqPath, &fs.GetArgs{})
+	if err != nil {
+		return http.StatusNotFound, err
+	}
+	if fi.IsDir() {
+		return http.StatusMethodNotAllowed, nil
+	}
+	if offset >= 0 && offset != fi.GetSize() {
+		return http.StatusRequestedRangeNotSatisfiable, fmt.Errorf("can only append at the end of the file, size: %d, offset: %d", fi.GetSize(), offset)
+	}
+	obj := model.Object{
+		Name:     path.Base(reqPath),
+		Size:     r.ContentLength,
+		Modified: h.getModTime(r),
+	}
+	fsStream := &stream.FileStream{
+		Obj:      &obj,
+		Reader:   r.Body,
+		Mimetype: utils.GetMimeType(reqPath),
+	}
+	err = fs.AppendDirectly(ctx, reqPath, fsStream)
+	_ = r.Body.Close()
+	_ = fsStream.Close()
+	if errors.Is(err, errs.NotImplement) {
+		return http.StatusNotImplemented, err
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	fi, err = fs.Get(ctx, reqPath, &fs.GetArgs{})
+	if err != nil {
+		fi = &obj
+	}
+	etag, err := findETag(ctx, h.LockSystem, reqPath, fi)
+	if err != nil {
+		return http.StatusInternalServerError, err
+	}
+	w.Header().Set("Etag", etag)
+	return http.StatusNoContent, nil
+}
+
 func (h *Handler) handleMkcol(w http.ResponseWriter, r *http.Request) (status int, err error) {
 	reqPath, status, err := h.stripPrefix(r.URL.Path)
 	if err != nil {
 		return status, err
 	}
-	release, status, err := h.confirmLocks(r, reqPath, "")
+	release, status, err := h.confirmLocks(r, reqPath, "", false)
 	if err != nil {
 		return status, err
 	}
@@ -454,7 +630,7 @@ func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request) (status
 		// even though a COPY doesn't modify the source, if a concurrent
 		// operation modifies the source. However, the litmus test explicitly
 		// checks that COPYing a locked-by-another source is OK.
-		release, status, err := h.confirmLocks(r, "", dst)
+		release, status, err := h.confirmLocks(r, "", dst, true)
 		if err != nil {
 			return status, err
 		}
@@ -474,7 +650,7 @@ func (h *Handler) handleCopyMove(w http.ResponseWriter, r *http.Request) (status
 		return copyFiles(ctx, src, dst, r.Header.Get("Overwrite") != "F")
 	}
 
-	release, status, err := h.confirmLocks(r, src, dst)
+	release, status, err := h.confirmLocks(r, src, dst, true)
 	if err != nil {
 		return status, err
 	}
@@ -516,7 +692,7 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request) (retStatus
 		if token == "" {
 			return http.StatusBadRequest, errInvalidLockToken
 		}
-		ld, err = h.LockSystem.Refresh(now, token, duration)
+		ld, err = h.tokenLockSystem(token).Refresh(now, token, duration)
 		if err != nil {
 			if err == ErrNoSuchLock {
 				return http.StatusPreconditionFailed, err
@@ -544,13 +720,14 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request) (retStatus
 		if err != nil {
 			return 403, err
 		}
+		ls, root := h.lockSystem(r, reqPath, true)
 		ld = LockDetails{
-			Root:      reqPath,
+			Root:      root,
 			Duration:  duration,
 			OwnerXML:  li.Owner.InnerXML,
 			ZeroDepth: depth == 0,
 		}
-		token, err = h.LockSystem.Create(now, ld)
+		token, err = ls.Create(now, ld)
 		if err != nil {
 			if err == ErrLocked {
 				return StatusLocked, err
@@ -559,7 +736,7 @@ func (h *Handler) handleLock(w http.ResponseWriter, r *http.Request) (retStatus
 		}
 		defer func() {
 			if retErr != nil {
-				h.LockSystem.Unlock(now, token)
+				ls.Unlock(now, token)
 			}
 		}()
 
@@ -600,7 +777,7 @@ func (h *Handler) handleUnlock(w http.ResponseWriter, r *http.Request) (status i
 	}
 	t = t[1 : len(t)-1]
 
-	switch err = h.LockSystem.Unlock(time.Now(), t); err {
+	switch err = h.tokenLockSystem(t).Unlock(time.Now(), t); err {
 	case nil:
 		return http.StatusNoContent, err
 	case ErrForbidden:
@@ -652,6 +829,7 @@ func (h *Handler) handlePropfind(w http.ResponseWriter, r *http.Request) (status
 		if err != nil {
 			return err
 		}
+		ctx := context.WithValue(ctx, "reqPath", reqPath)
 		var pstats []Propstat
 		if pf.Propname != nil {
 			pnames, err := propnames(ctx, h.LockSystem, info)
@@ -694,7 +872,7 @@ func (h *Handler) handleProppatch(w http.ResponseWriter, r *http.Request) (statu
 	if err != nil {
 		return status, err
 	}
-	release, status, err := h.confirmLocks(r, reqPath, "")
+	release, status, err := h.confirmLocks(r, reqPath, "", false)
 	if err != nil {
 		return status, err
 	}